@@ -14,6 +14,7 @@ import (
 	"net/http"
 	"net/http/cookiejar"
 	"net/textproto"
+	"net/url"
 	"strconv"
 	"strings"
 
@@ -190,12 +191,28 @@ func (c *HTTPClient) FetchTx(stateURI string, txID types.ID) (*Tx, error) {
 }
 
 func (c *HTTPClient) Get(stateURI string, version *types.ID, keypath tree.Keypath, rng *tree.Range, raw bool) (io.ReadCloser, int64, []types.ID, error) {
-	client := c.client()
-	url := c.dialAddr + "/" + string(keypath)
+	params := make(url.Values)
 	if raw {
-		url += "?raw=true"
+		params.Set("raw", "true")
+	}
+	return c.get(stateURI, version, keypath, rng, params)
+}
+
+// GetQuery evaluates query against the state at keypath (see the query
+// package).  The response body is a JSON list of the query's results.
+func (c *HTTPClient) GetQuery(stateURI string, version *types.ID, keypath tree.Keypath, query string) (io.ReadCloser, int64, []types.ID, error) {
+	params := make(url.Values)
+	params.Set("query", query)
+	return c.get(stateURI, version, keypath, nil, params)
+}
+
+func (c *HTTPClient) get(stateURI string, version *types.ID, keypath tree.Keypath, rng *tree.Range, params url.Values) (io.ReadCloser, int64, []types.ID, error) {
+	client := c.client()
+	reqURL := c.dialAddr + "/" + string(keypath)
+	if len(params) > 0 {
+		reqURL += "?" + params.Encode()
 	}
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequest("GET", reqURL, nil)
 	if err != nil {
 		return nil, 0, nil, errors.WithStack(err)
 	}
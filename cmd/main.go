@@ -21,6 +21,7 @@ import (
 	rw "redwood.dev"
 	"redwood.dev/ctx"
 	"redwood.dev/identity"
+	"redwood.dev/remotestore"
 	"redwood.dev/tree"
 	"redwood.dev/utils"
 )
@@ -135,11 +136,9 @@ func run(configPath, passwordFile string, gui, dev bool) error {
 	}
 
 	var (
-		txStore       = rw.NewBadgerTxStore(config.TxDBRoot())
-		keyStore      = identity.NewBadgerKeyStore(db, identity.DefaultScryptParams)
-		refStore      = rw.NewRefStore(config.RefDataRoot())
-		peerStore     = rw.NewPeerStore(db)
-		controllerHub = rw.NewControllerHub(config.StateDBRoot(), txStore, refStore)
+		keyStore  = identity.NewBadgerKeyStore(db, identity.DefaultScryptParams)
+		refStore  = rw.NewRefStore(config.RefDataRoot())
+		peerStore = rw.NewPeerStore(db)
 	)
 
 	err = keyStore.Unlock(string(passwordBytes))
@@ -147,6 +146,12 @@ func run(configPath, passwordFile string, gui, dev bool) error {
 		return err
 	}
 
+	txStore, err := makeTxStore(config, keyStore)
+	if err != nil {
+		return err
+	}
+	controllerHub := rw.NewControllerHub(config.StateDBRoot(), config.Node.StateDBEngine, txStore, refStore)
+
 	err = refStore.Start()
 	if err != nil {
 		return err
@@ -212,6 +217,59 @@ func run(configPath, passwordFile string, gui, dev bool) error {
 		transports = append(transports, httpTransport)
 	}
 
+	if config.WebSocketTransport.Enabled {
+		wsTransport, err := rw.NewWebSocketTransport(
+			config.WebSocketTransport.ListenHost,
+			config.WebSocketTransport.ReachableAt,
+			controllerHub,
+			keyStore,
+			refStore,
+			peerStore,
+		)
+		if err != nil {
+			return err
+		}
+		transports = append(transports, wsTransport)
+
+		// Websockets have no peer discovery of their own
+		var bootstrapPeers []rw.PeerDialInfo
+		for _, bp := range config.Node.BootstrapPeers {
+			if bp.Transport != wsTransport.Name() {
+				continue
+			}
+			for _, dialAddr := range bp.DialAddresses {
+				bootstrapPeers = append(bootstrapPeers, rw.PeerDialInfo{TransportName: wsTransport.Name(), DialAddr: dialAddr})
+			}
+		}
+		peerStore.AddDialInfos(bootstrapPeers)
+	}
+
+	if config.WebRTCTransport.Enabled {
+		webrtcTransport, err := rw.NewWebRTCTransport(
+			config.WebRTCTransport.ICEServers,
+			controllerHub,
+			keyStore,
+			refStore,
+			peerStore,
+		)
+		if err != nil {
+			return err
+		}
+		transports = append(transports, webrtcTransport)
+
+		// Like websockets, WebRTC has no peer discovery of its own
+		var bootstrapPeers []rw.PeerDialInfo
+		for _, bp := range config.Node.BootstrapPeers {
+			if bp.Transport != webrtcTransport.Name() {
+				continue
+			}
+			for _, dialAddr := range bp.DialAddresses {
+				bootstrapPeers = append(bootstrapPeers, rw.PeerDialInfo{TransportName: webrtcTransport.Name(), DialAddr: dialAddr})
+			}
+		}
+		peerStore.AddDialInfos(bootstrapPeers)
+	}
+
 	host, err := rw.NewHost(transports, controllerHub, keyStore, refStore, peerStore, config)
 	if err != nil {
 		return err
@@ -233,6 +291,16 @@ func run(configPath, passwordFile string, gui, dev bool) error {
 		defer rpcServer.Close()
 	}
 
+	if config.TrustedRPC.Enabled {
+		trustedRPC := rw.NewTrustedRPCServer(host, refStore)
+
+		rpcServer, err := rw.StartTrustedRPC(trustedRPC, config.TrustedRPC)
+		if err != nil {
+			return err
+		}
+		defer rpcServer.GracefulStop()
+	}
+
 	for _, bootstrapPeer := range config.Node.BootstrapPeers {
 		bootstrapPeer := bootstrapPeer
 		go func() {
@@ -298,6 +366,57 @@ func run(configPath, passwordFile string, gui, dev bool) error {
 	return nil
 }
 
+func makeTxStore(config *rw.Config, keyStore identity.KeyStore) (rw.TxStore, error) {
+	// Txs are encrypted under the node's default identity before they're sent
+	// to a remote store
+	newRemoteTxStore := func(host string) (rw.TxStore, error) {
+		identity, err := keyStore.DefaultPublicIdentity()
+		if err != nil {
+			return nil, err
+		}
+		return remotestore.NewClient(host, identity), nil
+	}
+
+	var primary rw.TxStore = rw.NewBadgerTxStore(config.TxDBRoot())
+	var replicas []rw.TxStoreReplica
+
+	if config.RemoteTxStore.Enabled {
+		remoteTxStore, err := newRemoteTxStore(config.RemoteTxStore.Host)
+		if err != nil {
+			return nil, err
+		}
+
+		switch config.RemoteTxStore.Role {
+		case rw.RemoteTxStoreRolePrimary:
+			primary = remoteTxStore
+		case rw.RemoteTxStoreRoleReplica:
+			replicas = append(replicas, rw.TxStoreReplica{Name: config.RemoteTxStore.Host, TxStore: remoteTxStore})
+		default:
+			return nil, errors.Errorf("bad value for RemoteTxStore.Role: %v", config.RemoteTxStore.Role)
+		}
+	}
+
+	for _, replicaConfig := range config.TxStoreReplicas {
+		switch replicaConfig.Type {
+		case rw.TxStoreReplicaTypeBadger:
+			replicas = append(replicas, rw.TxStoreReplica{Name: replicaConfig.Path, TxStore: rw.NewBadgerTxStore(replicaConfig.Path)})
+		case rw.TxStoreReplicaTypeRemote:
+			remoteTxStore, err := newRemoteTxStore(replicaConfig.Host)
+			if err != nil {
+				return nil, err
+			}
+			replicas = append(replicas, rw.TxStoreReplica{Name: replicaConfig.Host, TxStore: remoteTxStore})
+		default:
+			return nil, errors.Errorf("bad value for TxStoreReplicas.Type: %v", replicaConfig.Type)
+		}
+	}
+
+	if len(replicas) == 0 {
+		return primary, nil
+	}
+	return rw.NewReplicatedTxStore(primary, replicas, rw.DefaultReplicatedTxStoreOpts), nil
+}
+
 func ensureDataDirs(config *rw.Config) error {
 	err := os.MkdirAll(config.RefDataRoot(), 0777|os.ModeDir)
 	if err != nil {
@@ -12,14 +12,20 @@ import (
 	"gopkg.in/yaml.v3"
 
 	"redwood.dev/crypto"
+	"redwood.dev/tree"
 	"redwood.dev/utils"
 )
 
 type Config struct {
-	Node          *NodeConfig          `yaml:"Node"`
-	P2PTransport  *P2PTransportConfig  `yaml:"P2PTransport"`
-	HTTPTransport *HTTPTransportConfig `yaml:"HTTPTransport"`
-	HTTPRPC       *HTTPRPCConfig       `yaml:"HTTPRPC"`
+	Node               *NodeConfig               `yaml:"Node"`
+	P2PTransport       *P2PTransportConfig       `yaml:"P2PTransport"`
+	HTTPTransport      *HTTPTransportConfig      `yaml:"HTTPTransport"`
+	WebSocketTransport *WebSocketTransportConfig `yaml:"WebSocketTransport"`
+	WebRTCTransport    *WebRTCTransportConfig    `yaml:"WebRTCTransport"`
+	HTTPRPC            *HTTPRPCConfig            `yaml:"HTTPRPC"`
+	TrustedRPC         *TrustedRPCConfig         `yaml:"TrustedRPC"`
+	RemoteTxStore      *RemoteTxStoreConfig      `yaml:"RemoteTxStore"`
+	TxStoreReplicas    []TxStoreReplicaConfig    `yaml:"TxStoreReplicas"`
 
 	configPath string       `yaml:"-"`
 	mu         sync.RWMutex `yaml:"-"`
@@ -32,6 +38,13 @@ type NodeConfig struct {
 	MaxPeersPerSubscription uint64          `yaml:"MaxPeersPerSubscription"`
 	DataRoot                string          `yaml:"DataRoot"`
 	DevMode                 bool            `yaml:"DevMode"`
+	// Retention maps state URIs to the history retention policy used when
+	// pruning them.  The "*" entry applies to every state URI that isn't
+	// listed.  By default, nothing is pruned.
+	Retention map[string]RetentionPolicy `yaml:"Retention"`
+	// StateDBEngine is the storage engine ("badger", "bolt" or "memory") used
+	// for the state trees.  Changing it doesn't migrate existing state.
+	StateDBEngine tree.KVEngine `yaml:"StateDBEngine"`
 }
 
 type BootstrapPeer struct {
@@ -55,12 +68,59 @@ type HTTPTransportConfig struct {
 	ReachableAt     string `yaml:"ReachableAt"`
 }
 
+type WebSocketTransportConfig struct {
+	Enabled     bool   `yaml:"Enabled"`
+	ListenHost  string `yaml:"ListenHost"`
+	ReachableAt string `yaml:"ReachableAt"`
+}
+
+type WebRTCTransportConfig struct {
+	Enabled bool `yaml:"Enabled"`
+	// ICEServers are the STUN/TURN URLs used to connect to peers behind NATs
+	ICEServers []string `yaml:"ICEServers"`
+}
+
 type HTTPRPCConfig struct {
 	Enabled    bool            `yaml:"Enabled"`
 	ListenHost string          `yaml:"ListenHost"`
 	Whitelist  WhitelistConfig `yaml:"Whitelist"`
 }
 
+// TrustedRPCConfig configures the gRPC server defined in pb/rpc.grpc.proto
+type TrustedRPCConfig struct {
+	Enabled    bool            `yaml:"Enabled"`
+	ListenHost string          `yaml:"ListenHost"`
+	Whitelist  WhitelistConfig `yaml:"Whitelist"`
+}
+
+// RemoteTxStoreConfig configures a blind tx store (see the remotestore
+// package).  As the "primary" store, it replaces the local tx DB entirely.  As
+// a "replica", every write to the local tx DB is mirrored to it.
+type RemoteTxStoreConfig struct {
+	Enabled bool   `yaml:"Enabled"`
+	Host    string `yaml:"Host"`
+	Role    string `yaml:"Role"`
+}
+
+const (
+	RemoteTxStoreRolePrimary = "primary"
+	RemoteTxStoreRoleReplica = "replica"
+)
+
+// TxStoreReplicaConfig configures an extra tx store that every write to the
+// primary tx store is asynchronously mirrored to.  A "badger" replica is a tx
+// DB at Path, and a "remote" replica is a blind tx store at Host.
+type TxStoreReplicaConfig struct {
+	Type string `yaml:"Type"`
+	Path string `yaml:"Path"`
+	Host string `yaml:"Host"`
+}
+
+const (
+	TxStoreReplicaTypeBadger = "badger"
+	TxStoreReplicaTypeRemote = "remote"
+)
+
 func DefaultConfig(appName string) Config {
 	configRoot, err := DefaultConfigRoot(appName)
 	if err != nil {
@@ -95,6 +155,7 @@ func DefaultConfig(appName string) Config {
 			MaxPeersPerSubscription: 4,
 			DataRoot:                dataRoot,
 			DevMode:                 false,
+			StateDBEngine:           tree.DefaultKVEngine,
 		},
 		P2PTransport: &P2PTransportConfig{
 			Enabled:    true,
@@ -108,10 +169,27 @@ func DefaultConfig(appName string) Config {
 			CookieSecret:    string(httpCookieSecret),
 			DefaultStateURI: "",
 		},
+		WebSocketTransport: &WebSocketTransportConfig{
+			Enabled:    false,
+			ListenHost: ":8082",
+		},
+		WebRTCTransport: &WebRTCTransportConfig{
+			Enabled:    false,
+			ICEServers: []string{"stun:stun.l.google.com:19302"},
+		},
 		HTTPRPC: &HTTPRPCConfig{
 			Enabled:    false,
 			ListenHost: ":8081",
 		},
+		TrustedRPC: &TrustedRPCConfig{
+			Enabled:    false,
+			ListenHost: ":8083",
+		},
+		RemoteTxStore: &RemoteTxStoreConfig{
+			Enabled: false,
+			Host:    "localhost:4567",
+			Role:    RemoteTxStoreRoleReplica,
+		},
 	}
 }
 
@@ -223,6 +301,14 @@ func (c *Config) save() error {
 	return nil
 }
 
+// RetentionPolicy returns the retention policy for the given state URI.
+func (c *NodeConfig) RetentionPolicy(stateURI string) RetentionPolicy {
+	if policy, exists := c.Retention[stateURI]; exists {
+		return policy
+	}
+	return c.Retention["*"]
+}
+
 func (c *Config) Path() string {
 	return c.configPath
 }
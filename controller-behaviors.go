@@ -2,9 +2,14 @@ package redwood
 
 import (
 	"bytes"
+	"io"
 	"sort"
 
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+
 	"redwood.dev/ctx"
+	"redwood.dev/nelson"
 	"redwood.dev/tree"
 	"redwood.dev/types"
 )
@@ -18,28 +23,108 @@ type Validator interface {
 	ValidateTx(state tree.Node, tx *Tx) error
 }
 
+// ReadValidator is implemented by validators that also restrict who may read
+// the subtree they govern.  The keypath is relative to the validator's node.
+type ReadValidator interface {
+	ValidateRead(state tree.Node, keypath tree.Keypath, requester types.Address) error
+}
+
+// ScopedValidator is implemented by validators that need to know the state URI
+// and keypath of the node they govern.  The controller calls SetScope before
+// the validator sees any txs.
+type ScopedValidator interface {
+	SetScope(stateURI string, keypath tree.Keypath)
+}
+
 type Indexer interface {
 	IndexNode(relKeypath tree.Keypath, state tree.Node) (tree.Keypath, tree.Node, error)
 }
 
+// RangeIndexer is implemented by indexers that keep their entries in order.
+// Their indices are queried for a range of entries, described by the query
+// param, rather than by exact key.
+type RangeIndexer interface {
+	Indexer
+	ParseKeyRange(queryParam tree.Keypath) (tree.KeyRange, error)
+}
+
+// SearchIndexer is implemented by indexers whose indices answer free-form
+// queries, like full-text searches, rather than lookups by key.  indexed is
+// the node that the index was built from.
+type SearchIndexer interface {
+	Indexer
+	Search(index *tree.DBNode, indexed tree.Node, query tree.Keypath) ([]interface{}, error)
+}
+
+// Resolvers, validators, and indexers that hold resources which must be
+// released once the controller stops using them (like a WASM runtime) also
+// implement io.Closer.  The controller closes them when they're replaced or
+// removed, or when it shuts down.
+
 type ResolverConstructor func(config tree.Node, internalState map[string]interface{}) (Resolver, error)
 type ValidatorConstructor func(config tree.Node) (Validator, error)
 type IndexerConstructor func(config tree.Node) (Indexer, error)
 
 var resolverRegistry = map[string]ResolverConstructor{
-	"resolver/dumb": NewDumbResolver,
-	"resolver/lua":  NewLuaResolver,
-	"resolver/js":   NewJSResolver,
-	// "resolver/git":  NewGitResolver,
-	//"resolver/stack": NewStackResolver,
+	"resolver/dumb":  NewDumbResolver,
+	"resolver/lua":   NewLuaResolver,
+	"resolver/js":    NewJSResolver,
+	"resolver/sync9": NewSync9Resolver,
+	"resolver/git":   NewGitResolver,
+	"resolver/wasm":  NewWASMResolver,
 }
 var validatorRegistry = map[string]ValidatorConstructor{
 	"validator/permissions": NewPermissionsValidator,
-	// "stack":       NewStackValidator,
+	"validator/schema":      NewSchemaValidator,
+	"validator/wasm":        NewWASMValidator,
 }
 var indexerRegistry = map[string]IndexerConstructor{
-	"indexer/keypath": NewKeypathIndexer,
-	"indexer/js":      NewJSIndexer,
+	"indexer/keypath":  NewKeypathIndexer,
+	"indexer/sorted":   NewSortedIndexer,
+	"indexer/fulltext": NewFulltextIndexer,
+	"indexer/js":       NewJSIndexer,
+	"indexer/wasm":     NewWASMIndexer,
+}
+
+func init() {
+	// The stack constructors look up their children in the registries, so they
+	// can't appear in the map literals without creating an initialization cycle.
+	resolverRegistry["resolver/stack"] = NewStackResolver
+	validatorRegistry["validator/stack"] = NewStackValidator
+}
+
+// initResolverFromConfig constructs a resolver from a config node whose refs
+// have already been resolved by nelson.
+func initResolverFromConfig(config tree.Node, internalState map[string]interface{}) (Resolver, error) {
+	contentType, err := nelson.GetContentType(config)
+	if err != nil {
+		return nil, err
+	} else if contentType == "" {
+		return nil, errors.New("cannot initialize resolver without a 'Content-Type' key")
+	}
+
+	ctor, exists := resolverRegistry[contentType]
+	if !exists {
+		return nil, errors.Errorf("unknown resolver type '%v'", contentType)
+	}
+	return ctor(config, internalState)
+}
+
+// initValidatorFromConfig constructs a validator from a config node whose refs
+// have already been resolved by nelson.
+func initValidatorFromConfig(config tree.Node) (Validator, error) {
+	contentType, err := nelson.GetContentType(config)
+	if err != nil {
+		return nil, err
+	} else if contentType == "" {
+		return nil, errors.New("cannot initialize validator without a 'Content-Type' key")
+	}
+
+	ctor, exists := validatorRegistry[contentType]
+	if !exists {
+		return nil, errors.Errorf("unknown validator type '%v'", contentType)
+	}
+	return ctor(config)
 }
 
 type behaviorTree struct {
@@ -49,6 +134,7 @@ type behaviorTree struct {
 	resolverKeypaths  []tree.Keypath
 	resolvers         map[string]Resolver
 	indexers          map[string]map[string]Indexer
+	replaced          []io.Closer // behaviors that have been replaced or removed since the tree was copied
 }
 
 func newBehaviorTree() *behaviorTree {
@@ -106,7 +192,9 @@ func (t *behaviorTree) debugPrint() {
 }
 
 func (t *behaviorTree) addResolver(keypath tree.Keypath, resolver Resolver) {
-	if _, exists := t.resolvers[string(keypath)]; !exists {
+	if old, exists := t.resolvers[string(keypath)]; exists {
+		t.noteReplaced(old)
+	} else {
 		t.resolverKeypaths = append(t.resolverKeypaths, keypath)
 		// @@TODO: sucks
 		sort.Slice(t.resolverKeypaths, func(i, j int) bool { return bytes.Compare(t.resolverKeypaths[i], t.resolverKeypaths[j]) < 0 })
@@ -115,9 +203,11 @@ func (t *behaviorTree) addResolver(keypath tree.Keypath, resolver Resolver) {
 }
 
 func (t *behaviorTree) removeResolver(keypath tree.Keypath) {
-	if _, exists := t.resolvers[string(keypath)]; !exists {
+	old, exists := t.resolvers[string(keypath)]
+	if !exists {
 		return
 	}
+	t.noteReplaced(old)
 	delete(t.resolvers, string(keypath))
 	var idx int
 	for i, kp := range t.resolverKeypaths {
@@ -131,7 +221,9 @@ func (t *behaviorTree) removeResolver(keypath tree.Keypath) {
 }
 
 func (t *behaviorTree) addValidator(keypath tree.Keypath, validator Validator) {
-	if _, exists := t.validators[string(keypath)]; !exists {
+	if old, exists := t.validators[string(keypath)]; exists {
+		t.noteReplaced(old)
+	} else {
 		t.validatorKeypaths = append(t.validatorKeypaths, keypath)
 		// @@TODO: sucks
 		sort.Slice(t.validatorKeypaths, func(i, j int) bool { return bytes.Compare(t.validatorKeypaths[i], t.validatorKeypaths[j]) < 0 })
@@ -140,9 +232,11 @@ func (t *behaviorTree) addValidator(keypath tree.Keypath, validator Validator) {
 }
 
 func (t *behaviorTree) removeValidator(keypath tree.Keypath) {
-	if _, exists := t.validators[string(keypath)]; !exists {
+	old, exists := t.validators[string(keypath)]
+	if !exists {
 		return
 	}
+	t.noteReplaced(old)
 	delete(t.validators, string(keypath))
 	var idx int
 	for i, kp := range t.validatorKeypaths {
@@ -158,17 +252,68 @@ func (t *behaviorTree) removeValidator(keypath tree.Keypath) {
 func (t *behaviorTree) addIndexer(keypath tree.Keypath, indexName tree.Keypath, indexer Indexer) {
 	if _, exists := t.indexers[string(keypath)]; !exists {
 		t.indexers[string(keypath)] = make(map[string]Indexer)
+	} else if old, exists := t.indexers[string(keypath)][string(indexName)]; exists {
+		t.noteReplaced(old)
 	}
 	t.indexers[string(keypath)][string(indexName)] = indexer
 }
 
 func (t *behaviorTree) removeIndexer(keypath tree.Keypath, indexName tree.Keypath) {
-	if _, exists := t.indexers[string(keypath)]; !exists {
+	old, exists := t.indexers[string(keypath)][string(indexName)]
+	if !exists {
 		return
 	}
+	t.noteReplaced(old)
 	delete(t.indexers[string(keypath)], string(indexName))
 }
 
+func (t *behaviorTree) noteReplaced(behavior interface{}) {
+	if closer, is := behavior.(io.Closer); is {
+		t.replaced = append(t.replaced, closer)
+	}
+}
+
+// closers returns the behaviors in the tree that implement io.Closer.
+func (t *behaviorTree) closers() []io.Closer {
+	var closers []io.Closer
+	for _, validator := range t.validators {
+		if closer, is := validator.(io.Closer); is {
+			closers = append(closers, closer)
+		}
+	}
+	for _, resolver := range t.resolvers {
+		if closer, is := resolver.(io.Closer); is {
+			closers = append(closers, closer)
+		}
+	}
+	for _, indexers := range t.indexers {
+		for _, indexer := range indexers {
+			if closer, is := indexer.(io.Closer); is {
+				closers = append(closers, closer)
+			}
+		}
+	}
+	return closers
+}
+
+// closeBehaviorsNotIn closes each of the given behaviors once, skipping any
+// that are still in use by keep.
+func closeBehaviorsNotIn(closers []io.Closer, keep *behaviorTree) error {
+	skip := make(map[io.Closer]bool)
+	for _, closer := range keep.closers() {
+		skip[closer] = true
+	}
+	var errs error
+	for _, closer := range closers {
+		if skip[closer] {
+			continue
+		}
+		skip[closer] = true
+		errs = multierr.Append(errs, closer.Close())
+	}
+	return errs
+}
+
 func (t *behaviorTree) nearestResolverForKeypath(keypath tree.Keypath) (Resolver, tree.Keypath) {
 	for i := len(t.resolverKeypaths) - 1; i >= 0; i-- {
 		kp := t.resolverKeypaths[i]
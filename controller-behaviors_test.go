@@ -0,0 +1,85 @@
+package redwood
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"redwood.dev/tree"
+	"redwood.dev/types"
+)
+
+type behaviorMock struct {
+	closed int
+}
+
+func (b *behaviorMock) ResolveState(state tree.Node, refStore RefStore, sender types.Address, txID types.ID, parents []types.ID, patches []Patch) error {
+	return nil
+}
+func (b *behaviorMock) InternalState() map[string]interface{}    { return nil }
+func (b *behaviorMock) ValidateTx(state tree.Node, tx *Tx) error { return nil }
+func (b *behaviorMock) IndexNode(relKeypath tree.Keypath, state tree.Node) (tree.Keypath, tree.Node, error) {
+	return nil, nil, nil
+}
+func (b *behaviorMock) Close() error {
+	b.closed++
+	return nil
+}
+
+func TestBehaviorTree_ClosesReplacedBehaviors(t *testing.T) {
+	var (
+		oldResolver  = &behaviorMock{}
+		oldValidator = &behaviorMock{}
+		oldIndexer   = &behaviorMock{}
+		keptResolver = &behaviorMock{}
+		newResolver  = &behaviorMock{}
+		newIndexer   = &behaviorMock{}
+	)
+
+	setup := func() (*behaviorTree, *behaviorTree) {
+		oldTree := newBehaviorTree()
+		oldTree.addResolver(tree.Keypath("foo"), oldResolver)
+		oldTree.addResolver(tree.Keypath("bar"), keptResolver)
+		oldTree.addValidator(tree.Keypath("foo"), oldValidator)
+		oldTree.addIndexer(tree.Keypath("foo"), tree.Keypath("idx"), oldIndexer)
+
+		newTree := oldTree.copy()
+		newTree.addResolver(tree.Keypath("foo"), newResolver)
+		newTree.removeValidator(tree.Keypath("foo"))
+		newTree.addIndexer(tree.Keypath("foo"), tree.Keypath("idx"), newIndexer)
+		return oldTree, newTree
+	}
+	reset := func() {
+		for _, b := range []*behaviorMock{oldResolver, oldValidator, oldIndexer, keptResolver, newResolver, newIndexer} {
+			b.closed = 0
+		}
+	}
+
+	t.Run("when the new tree is committed", func(t *testing.T) {
+		defer reset()
+		_, newTree := setup()
+
+		err := closeBehaviorsNotIn(newTree.replaced, newTree)
+		require.NoError(t, err)
+		require.Equal(t, 1, oldResolver.closed)
+		require.Equal(t, 1, oldValidator.closed)
+		require.Equal(t, 1, oldIndexer.closed)
+		require.Equal(t, 0, keptResolver.closed)
+		require.Equal(t, 0, newResolver.closed)
+		require.Equal(t, 0, newIndexer.closed)
+	})
+
+	t.Run("when the new tree is discarded", func(t *testing.T) {
+		defer reset()
+		oldTree, newTree := setup()
+
+		err := closeBehaviorsNotIn(append(newTree.replaced, newTree.closers()...), oldTree)
+		require.NoError(t, err)
+		require.Equal(t, 0, oldResolver.closed)
+		require.Equal(t, 0, oldValidator.closed)
+		require.Equal(t, 0, oldIndexer.closed)
+		require.Equal(t, 0, keptResolver.closed)
+		require.Equal(t, 1, newResolver.closed)
+		require.Equal(t, 1, newIndexer.closed)
+	})
+}
@@ -30,6 +30,13 @@ type ControllerHub interface {
 	IsPrivate(stateURI string) (bool, error)
 	IsMember(stateURI string, addr types.Address) (bool, error)
 	Members(stateURI string) ([]types.Address, error)
+	MempoolStats(stateURI string) (MempoolStats, error)
+	ExportSnapshot(stateURI string, checkpointID *types.ID, requesters []types.Address) (*Snapshot, error)
+	ImportSnapshot(snapshot *Snapshot) error
+	PruneHistory(stateURI string, policy RetentionPolicy) (PruneStats, error)
+	ValidateRead(stateURI string, keypath tree.Keypath, requesters []types.Address) error
+	ValidateReadSubtree(stateURI string, keypath tree.Keypath, requesters []types.Address) error
+	RedactUnreadable(stateURI string, node tree.Node, keypath tree.Keypath, requesters []types.Address) error
 
 	RefObjectReader(refID types.RefID) (io.ReadCloser, int64, error)
 
@@ -45,6 +52,7 @@ type controllerHub struct {
 	txStore       TxStore
 	refStore      RefStore
 	dbRootPath    string
+	dbEngine      tree.KVEngine
 
 	newStateListeners   []func(tx *Tx, state tree.Node, leaves []types.ID)
 	newStateListenersMu sync.RWMutex
@@ -54,12 +62,13 @@ var (
 	ErrNoController = errors.New("no controller for that stateURI")
 )
 
-func NewControllerHub(dbRootPath string, txStore TxStore, refStore RefStore) ControllerHub {
+func NewControllerHub(dbRootPath string, dbEngine tree.KVEngine, txStore TxStore, refStore RefStore) ControllerHub {
 	return &controllerHub{
 		Logger:      ctx.NewLogger("controller hub"),
 		chStop:      make(chan struct{}),
 		controllers: make(map[string]Controller),
 		dbRootPath:  dbRootPath,
+		dbEngine:    dbEngine,
 		txStore:     txStore,
 		refStore:    refStore,
 	}
@@ -105,7 +114,7 @@ func (m *controllerHub) EnsureController(stateURI string) (Controller, error) {
 	if ctrl == nil {
 		// Set up the controller
 		var err error
-		ctrl, err = NewController(stateURI, m.dbRootPath, m, m.txStore, m.refStore)
+		ctrl, err = NewController(stateURI, m.dbRootPath, m.dbEngine, m, m.txStore, m.refStore)
 		if err != nil {
 			return nil, err
 		}
@@ -228,6 +237,136 @@ func (m *controllerHub) Members(stateURI string) ([]types.Address, error) {
 	return ctrl.Members(), nil
 }
 
+func (m *controllerHub) MempoolStats(stateURI string) (MempoolStats, error) {
+	m.controllersMu.RLock()
+	defer m.controllersMu.RUnlock()
+
+	ctrl := m.controllers[stateURI]
+	if ctrl == nil {
+		return MempoolStats{}, errors.Wrapf(ErrNoController, stateURI)
+	}
+	return ctrl.MempoolStats()
+}
+
+func (m *controllerHub) ExportSnapshot(stateURI string, checkpointID *types.ID, requesters []types.Address) (*Snapshot, error) {
+	m.controllersMu.RLock()
+	defer m.controllersMu.RUnlock()
+
+	ctrl := m.controllers[stateURI]
+	if ctrl == nil {
+		return nil, errors.Wrapf(ErrNoController, stateURI)
+	}
+	return ctrl.ExportSnapshot(checkpointID, requesters)
+}
+
+func (m *controllerHub) ImportSnapshot(snapshot *Snapshot) error {
+	ctrl, err := m.EnsureController(snapshot.StateURI)
+	if err != nil {
+		return err
+	}
+	return ctrl.ImportSnapshot(snapshot)
+}
+
+// PruneHistory prunes a state URI's history according to the given policy and
+// then deletes any refs that were only linked from the pruned history.
+func (m *controllerHub) PruneHistory(stateURI string, policy RetentionPolicy) (PruneStats, error) {
+	// Don't hold the lock while pruning, as that would block EnsureController
+	m.controllersMu.RLock()
+	ctrl := m.controllers[stateURI]
+	m.controllersMu.RUnlock()
+	if ctrl == nil {
+		return PruneStats{}, errors.Wrapf(ErrNoController, stateURI)
+	}
+
+	stats, candidateRefs, err := ctrl.PruneHistory(policy)
+	if err != nil {
+		return stats, err
+	} else if len(candidateRefs) == 0 {
+		return stats, nil
+	}
+
+	// Refs are shared across state URIs, so a ref can only be deleted once it's
+	// unreachable from every retained state and mempool tx that this node knows
+	// about.  A tx received after this check that links one of these refs will
+	// have to fetch it again, like any other ref we don't have.
+	m.controllersMu.RLock()
+	controllers := make([]Controller, 0, len(m.controllers))
+	for _, c := range m.controllers {
+		controllers = append(controllers, c)
+	}
+	m.controllersMu.RUnlock()
+
+	retained := make(map[string]struct{})
+	for _, c := range controllers {
+		refs, err := c.RetainedRefs()
+		if err != nil {
+			return stats, err
+		}
+		for _, refID := range refs {
+			path, err := m.refStore.ObjectFilepath(refID)
+			if err != nil {
+				// We don't have it, so there's nothing to protect
+				continue
+			}
+			retained[path] = struct{}{}
+		}
+	}
+
+	deleted := make(map[string]struct{})
+	for _, refID := range candidateRefs {
+		path, err := m.refStore.ObjectFilepath(refID)
+		if err != nil {
+			continue
+		} else if _, isRetained := retained[path]; isRetained {
+			continue
+		} else if _, isDeleted := deleted[path]; isDeleted {
+			continue
+		}
+
+		err = m.refStore.DeleteObject(refID)
+		if err != nil {
+			m.Errorf("error deleting unreferenced ref %v: %v", refID, err)
+			continue
+		}
+		deleted[path] = struct{}{}
+		stats.NumRefs++
+	}
+	return stats, nil
+}
+
+func (m *controllerHub) ValidateRead(stateURI string, keypath tree.Keypath, requesters []types.Address) error {
+	m.controllersMu.RLock()
+	defer m.controllersMu.RUnlock()
+
+	ctrl := m.controllers[stateURI]
+	if ctrl == nil {
+		return errors.Wrapf(ErrNoController, stateURI)
+	}
+	return ctrl.ValidateRead(keypath, requesters)
+}
+
+func (m *controllerHub) ValidateReadSubtree(stateURI string, keypath tree.Keypath, requesters []types.Address) error {
+	m.controllersMu.RLock()
+	defer m.controllersMu.RUnlock()
+
+	ctrl := m.controllers[stateURI]
+	if ctrl == nil {
+		return errors.Wrapf(ErrNoController, stateURI)
+	}
+	return ctrl.ValidateReadSubtree(keypath, requesters)
+}
+
+func (m *controllerHub) RedactUnreadable(stateURI string, node tree.Node, keypath tree.Keypath, requesters []types.Address) error {
+	m.controllersMu.RLock()
+	defer m.controllersMu.RUnlock()
+
+	ctrl := m.controllers[stateURI]
+	if ctrl == nil {
+		return errors.Wrapf(ErrNoController, stateURI)
+	}
+	return ctrl.RedactUnreadable(node, keypath, requesters)
+}
+
 func (m *controllerHub) OnNewState(fn func(tx *Tx, state tree.Node, leaves []types.ID)) {
 	m.newStateListenersMu.Lock()
 	defer m.newStateListenersMu.Unlock()
@@ -0,0 +1,272 @@
+package redwood
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+
+	"redwood.dev/tree"
+	"redwood.dev/types"
+	"redwood.dev/utils"
+)
+
+// RetentionPolicy determines how much of a state URI's history is kept.  Every
+// checkpoint that satisfies either limit is retained (as is the most recent
+// one), and txs and state versions older than the oldest retained checkpoint
+// are pruned.  The zero value keeps everything.
+type RetentionPolicy struct {
+	KeepCheckpoints uint          `yaml:"KeepCheckpoints"`
+	KeepFor         time.Duration `yaml:"KeepFor"`
+}
+
+func (p RetentionPolicy) IsZero() bool {
+	return p.KeepCheckpoints == 0 && p.KeepFor == 0
+}
+
+// oldestRetained returns the index of the oldest checkpoint that the policy
+// retains, given checkpoints sorted oldest first.
+func (p RetentionPolicy) oldestRetained(checkpoints []Checkpoint, now time.Time) int {
+	oldest := len(checkpoints) - 1
+	if p.KeepCheckpoints > 0 {
+		i := len(checkpoints) - int(p.KeepCheckpoints)
+		if i < 0 {
+			i = 0
+		}
+		if i < oldest {
+			oldest = i
+		}
+	}
+	if p.KeepFor > 0 {
+		for i, checkpoint := range checkpoints {
+			if now.Sub(checkpoint.Created) <= p.KeepFor {
+				if i < oldest {
+					oldest = i
+				}
+				break
+			}
+		}
+	}
+	return oldest
+}
+
+type PruneStats struct {
+	NumTxs         int
+	NumCheckpoints int
+	NumRefs        int
+}
+
+const pruneBatchSize = 1000
+
+// PruneHistory deletes the txs and checkpoint state versions that precede the
+// oldest checkpoint retained by the policy.  It returns the refs that were
+// linked from the pruned history, which may no longer be needed.
+//
+// Pruning doesn't hold any of the locks that AddTx and tryApplyTx use.  A tx
+// that arrives late and builds on pruned history is still accepted, because
+// tryApplyTx treats pruned parents as present.
+func (c *controller) PruneHistory(policy RetentionPolicy) (_ PruneStats, _ []types.RefID, err error) {
+	defer utils.Annotate(&err, "stateURI=%v", c.stateURI)
+
+	c.pruneMu.Lock()
+	defer c.pruneMu.Unlock()
+
+	var stats PruneStats
+	if policy.IsZero() {
+		return stats, nil, nil
+	}
+
+	checkpoints, err := c.txStore.Checkpoints(c.stateURI)
+	if err != nil {
+		return stats, nil, err
+	} else if len(checkpoints) == 0 {
+		return stats, nil, nil
+	}
+	oldest := policy.oldestRetained(checkpoints, time.Now())
+	cutoff := checkpoints[oldest]
+
+	var refs []types.RefID
+
+	// Walk back from the oldest retained checkpoint's frontier, pruning every
+	// ancestor along the way
+	{
+		retained := make(map[types.ID]struct{}, len(cutoff.Frontier))
+		for _, txID := range cutoff.Frontier {
+			retained[txID] = struct{}{}
+		}
+
+		var stack []types.ID
+		for _, txID := range cutoff.Frontier {
+			tx, err := c.txStore.FetchTx(c.stateURI, txID)
+			if err != nil {
+				return stats, nil, errors.Wrapf(err, "frontier tx %v", txID.Pretty())
+			}
+			stack = append(stack, tx.Parents...)
+		}
+
+		visited := make(map[types.ID]struct{})
+		var batch []types.ID
+		for len(stack) > 0 {
+			txID := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			if _, seen := visited[txID]; seen {
+				continue
+			} else if _, isRetained := retained[txID]; isRetained {
+				continue
+			}
+			visited[txID] = struct{}{}
+
+			tx, err := c.txStore.FetchTx(c.stateURI, txID)
+			if errors.Cause(err) == types.Err404 {
+				// Pruned already
+				continue
+			} else if err != nil {
+				return stats, nil, err
+			}
+
+			txRefs, err := refsInPatches(tx.Patches)
+			if err != nil {
+				c.Warnf("error finding refs in tx %v: %v", txID.Pretty(), err)
+			}
+			refs = append(refs, txRefs...)
+
+			batch = append(batch, txID)
+			stack = append(stack, tx.Parents...)
+
+			if len(batch) >= pruneBatchSize {
+				err = c.txStore.PruneTxs(c.stateURI, batch)
+				if err != nil {
+					return stats, nil, err
+				}
+				stats.NumTxs += len(batch)
+				batch = nil
+			}
+		}
+		if len(batch) > 0 {
+			err = c.txStore.PruneTxs(c.stateURI, batch)
+			if err != nil {
+				return stats, nil, err
+			}
+			stats.NumTxs += len(batch)
+		}
+	}
+
+	// Delete the state versions of older checkpoints
+	for _, checkpoint := range checkpoints[:oldest] {
+		state := c.states.StateAtVersion(&checkpoint.TxID, false)
+		stateRefs, err := refsInState(state)
+		state.Close()
+		if err != nil {
+			c.Warnf("error finding refs in checkpoint %v: %v", checkpoint.TxID.Pretty(), err)
+		}
+		refs = append(refs, stateRefs...)
+
+		err = c.states.DeleteVersion(checkpoint.TxID)
+		if err != nil {
+			return stats, nil, err
+		}
+		err = c.indices.DeleteVersion(checkpoint.TxID)
+		if err != nil {
+			return stats, nil, err
+		}
+		err = c.txStore.RemoveCheckpoint(c.stateURI, checkpoint)
+		if err != nil {
+			return stats, nil, err
+		}
+		stats.NumCheckpoints++
+	}
+
+	return stats, refs, nil
+}
+
+// RetainedRefs returns every ref linked from a tx waiting in the mempool, from
+// the current state, or from any retained checkpoint.
+//
+// The mempool is read first.  A tx stays in the stored mempool until its state
+// has been saved, so a tx that's applied while we're checking is found in one
+// place or the other.
+func (c *controller) RetainedRefs() ([]types.RefID, error) {
+	var refs []types.RefID
+
+	entries, err := c.txStore.MempoolTxs(c.stateURI)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		txRefs, err := refsInPatches(entry.Tx.Patches)
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, txRefs...)
+	}
+
+	checkpoints, err := c.txStore.Checkpoints(c.stateURI)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := []*types.ID{nil}
+	for i := range checkpoints {
+		versions = append(versions, &checkpoints[i].TxID)
+	}
+
+	for _, version := range versions {
+		state := c.states.StateAtVersion(version, false)
+		stateRefs, err := refsInState(state)
+		state.Close()
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, stateRefs...)
+	}
+	return refs, nil
+}
+
+// refsInState returns every ref linked from the given state.
+func refsInState(state tree.Node) ([]types.RefID, error) {
+	iter := state.DepthFirstIterator(nil, false, 0)
+	defer iter.Close()
+
+	var refs []types.RefID
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		keypath := iter.Node().Keypath().RelativeTo(state.Keypath())
+		refID, isRef, err := refAtKeypath(state, keypath)
+		if err != nil {
+			return nil, err
+		} else if isRef {
+			refs = append(refs, refID)
+		}
+	}
+	return refs, nil
+}
+
+// refsInPatches returns the refs that a tx's patches link to.  Links split
+// across several txs aren't found, and neither are moves and copies of links
+// set by earlier txs (those txs report the refs themselves).
+func refsInPatches(patches []Patch) ([]types.RefID, error) {
+	node := tree.NewMemoryNode()
+	for _, patch := range patches {
+		if patch.Range != nil {
+			continue
+		}
+		switch patch.Op {
+		case PatchOpSet:
+		case PatchOpMove, PatchOpCopy:
+			exists, err := node.Exists(patch.From)
+			if err != nil {
+				return nil, err
+			} else if !exists {
+				continue
+			}
+		default:
+			// Deletes, increments and tests don't link anything, and a delete
+			// shouldn't hide a ref that an earlier patch linked
+			continue
+		}
+		err := patch.Apply(node)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return refsInState(node)
+}
@@ -0,0 +1,206 @@
+package redwood_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"redwood.dev"
+	"redwood.dev/crypto"
+	"redwood.dev/tree"
+	"redwood.dev/types"
+)
+
+func TestControllerHub_PruneHistory(t *testing.T) {
+	signer, err := crypto.GenerateSigningKeypair()
+	require.NoError(t, err)
+
+	stateURI := "foo.com/bar"
+
+	mustSignTx := func(tx *redwood.Tx) *redwood.Tx {
+		t.Helper()
+		tx.StateURI = stateURI
+		tx.From = signer.Address()
+		sig, err := signer.SignHash(tx.Hash())
+		require.NoError(t, err)
+		tx.Sig = sig
+		return tx
+	}
+
+	hub, refStore, chNewState, cleanup := setupControllerHub(t)
+	defer cleanup()
+
+	_, oldRefHash, err := refStore.StoreObject(ioutil.NopCloser(bytes.NewReader([]byte("old"))))
+	require.NoError(t, err)
+	_, currentRefHash, err := refStore.StoreObject(ioutil.NopCloser(bytes.NewReader([]byte("current"))))
+	require.NoError(t, err)
+	oldRef := types.RefID{HashAlg: types.SHA3, Hash: oldRefHash}
+	currentRef := types.RefID{HashAlg: types.SHA3, Hash: currentRefHash}
+
+	// The old ref is linked through a copy, and its original link is deleted
+	genesis := mustSignTx(&redwood.Tx{
+		ID: redwood.GenesisTxID,
+		Patches: mustParsePatches(t,
+			`.tmp = {"Content-Type": "link", "value": "ref:sha3:`+oldRefHash.Hex()+`"}`,
+			`copy .tmp to .file`,
+			`delete .tmp`,
+		),
+	})
+	checkpoint1 := mustSignTx(&redwood.Tx{ID: types.RandomID(), Parents: []types.ID{genesis.ID}, Checkpoint: true, Patches: mustParsePatches(t, `.count = 1`)})
+	tx2 := mustSignTx(&redwood.Tx{
+		ID:      types.RandomID(),
+		Parents: []types.ID{checkpoint1.ID},
+		Patches: mustParsePatches(t, `.file = {"Content-Type": "link", "value": "ref:sha3:`+currentRefHash.Hex()+`"}`),
+	})
+	checkpoint2 := mustSignTx(&redwood.Tx{ID: types.RandomID(), Parents: []types.ID{tx2.ID}, Checkpoint: true, Patches: mustParsePatches(t, `.count = 2`)})
+	tx3 := mustSignTx(&redwood.Tx{ID: types.RandomID(), Parents: []types.ID{checkpoint2.ID}, Patches: mustParsePatches(t, `.after = true`)})
+
+	for _, tx := range []*redwood.Tx{genesis, checkpoint1, tx2, checkpoint2, tx3} {
+		require.NoError(t, hub.AddTx(tx, false))
+		func() {
+			for {
+				select {
+				case id := <-chNewState:
+					if id == tx.ID {
+						return
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatalf("timed out waiting for tx %v", tx.ID.Pretty())
+				}
+			}
+		}()
+	}
+
+	// The zero policy keeps everything
+	stats, err := hub.PruneHistory(stateURI, redwood.RetentionPolicy{})
+	require.NoError(t, err)
+	require.Equal(t, redwood.PruneStats{}, stats)
+
+	stats, err = hub.PruneHistory(stateURI, redwood.RetentionPolicy{KeepCheckpoints: 1})
+	require.NoError(t, err)
+	require.Equal(t, redwood.PruneStats{NumTxs: 3, NumCheckpoints: 1, NumRefs: 1}, stats)
+
+	// Pruned txs are gone, but are still remembered so that they aren't accepted again
+	for _, tx := range []*redwood.Tx{genesis, checkpoint1, tx2} {
+		_, err := hub.FetchTx(stateURI, tx.ID)
+		require.Equal(t, types.Err404, errors.Cause(err))
+
+		have, err := hub.HaveTx(stateURI, tx.ID)
+		require.NoError(t, err)
+		require.True(t, have)
+	}
+	for _, tx := range []*redwood.Tx{checkpoint2, tx3} {
+		_, err := hub.FetchTx(stateURI, tx.ID)
+		require.NoError(t, err)
+	}
+
+	// History fetches that start at the pruned genesis tx start at the oldest
+	// retained checkpoint's frontier instead
+	for _, fromTxID := range []types.ID{{}, genesis.ID, tx2.ID} {
+		iter := hub.FetchTxs(stateURI, fromTxID)
+		var fetched []types.ID
+		for tx := iter.Next(); tx != nil; tx = iter.Next() {
+			fetched = append(fetched, tx.ID)
+		}
+		require.NoError(t, iter.Error())
+		require.Equal(t, []types.ID{checkpoint2.ID, tx3.ID}, fetched)
+	}
+
+	// The older checkpoint's state version is deleted
+	state, err := hub.StateAtVersion(stateURI, &checkpoint1.ID)
+	require.NoError(t, err)
+	_, exists, err := state.Value(tree.Keypath("count"), nil)
+	require.NoError(t, err)
+	require.False(t, exists)
+	state.Close()
+
+	state, err = hub.StateAtVersion(stateURI, &checkpoint2.ID)
+	require.NoError(t, err)
+	count, _, err := state.Value(tree.Keypath("count"), nil)
+	require.NoError(t, err)
+	require.Equal(t, float64(2), count)
+	state.Close()
+
+	// Only refs that are no longer linked from any retained state are deleted
+	have, err := refStore.HaveObject(oldRef)
+	require.NoError(t, err)
+	require.False(t, have)
+
+	have, err = refStore.HaveObject(currentRef)
+	require.NoError(t, err)
+	require.True(t, have)
+
+	// A late branch off of pruned history is still accepted
+	lateTx := mustSignTx(&redwood.Tx{ID: types.RandomID(), Parents: []types.ID{tx2.ID}, Patches: mustParsePatches(t, `.late = true`)})
+	require.NoError(t, hub.AddTx(lateTx, false))
+	require.Eventually(t, func() bool {
+		tx, err := hub.FetchTx(stateURI, lateTx.ID)
+		return err == nil && tx.Status == redwood.TxStatusValid
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// Pruning again is a no-op
+	stats, err = hub.PruneHistory(stateURI, redwood.RetentionPolicy{KeepCheckpoints: 1})
+	require.NoError(t, err)
+	require.Equal(t, redwood.PruneStats{}, stats)
+}
+
+func TestControllerHub_PruneHistoryKeepsRefsLinkedFromTheMempool(t *testing.T) {
+	signer, err := crypto.GenerateSigningKeypair()
+	require.NoError(t, err)
+
+	stateURI := "foo.com/bar"
+
+	mustSignTx := func(tx *redwood.Tx) *redwood.Tx {
+		t.Helper()
+		tx.StateURI = stateURI
+		tx.From = signer.Address()
+		sig, err := signer.SignHash(tx.Hash())
+		require.NoError(t, err)
+		tx.Sig = sig
+		return tx
+	}
+
+	hub, refStore, chNewState, cleanup := setupControllerHub(t)
+	defer cleanup()
+
+	_, refHash, err := refStore.StoreObject(ioutil.NopCloser(bytes.NewReader([]byte("pending"))))
+	require.NoError(t, err)
+	ref := types.RefID{HashAlg: types.SHA3, Hash: refHash}
+	link := `{"Content-Type": "link", "value": "ref:sha3:` + refHash.Hex() + `"}`
+
+	// The ref is only linked from pruned history...
+	genesis := mustSignTx(&redwood.Tx{ID: redwood.GenesisTxID, Patches: mustParsePatches(t, `.file = `+link)})
+	checkpoint := mustSignTx(&redwood.Tx{ID: types.RandomID(), Parents: []types.ID{genesis.ID}, Checkpoint: true, Patches: mustParsePatches(t, `delete .file`)})
+
+	for _, tx := range []*redwood.Tx{genesis, checkpoint} {
+		require.NoError(t, hub.AddTx(tx, false))
+		func() {
+			for {
+				select {
+				case id := <-chNewState:
+					if id == tx.ID {
+						return
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatalf("timed out waiting for tx %v", tx.ID.Pretty())
+				}
+			}
+		}()
+	}
+
+	// ...and from a tx that's waiting in the mempool for a parent
+	pending := mustSignTx(&redwood.Tx{ID: types.RandomID(), Parents: []types.ID{types.RandomID()}, Patches: mustParsePatches(t, `.file = `+link)})
+	require.NoError(t, hub.AddTx(pending, false))
+
+	stats, err := hub.PruneHistory(stateURI, redwood.RetentionPolicy{KeepCheckpoints: 1})
+	require.NoError(t, err)
+	require.Equal(t, redwood.PruneStats{NumTxs: 1}, stats)
+
+	have, err := refStore.HaveObject(ref)
+	require.NoError(t, err)
+	require.True(t, have)
+}
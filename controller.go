@@ -1,10 +1,12 @@
 package redwood
 
 import (
+	"encoding/json"
 	"fmt"
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -31,6 +33,18 @@ type Controller interface {
 	IsMember(addr types.Address) (bool, error)
 	Members() []types.Address
 
+	MempoolStats() (MempoolStats, error)
+
+	ExportSnapshot(checkpointID *types.ID, requesters []types.Address) (*Snapshot, error)
+	ImportSnapshot(snapshot *Snapshot) error
+
+	PruneHistory(policy RetentionPolicy) (PruneStats, []types.RefID, error)
+	RetainedRefs() ([]types.RefID, error)
+
+	ValidateRead(keypath tree.Keypath, requesters []types.Address) error
+	ValidateReadSubtree(keypath tree.Keypath, requesters []types.Address) error
+	RedactUnreadable(node tree.Node, keypath tree.Keypath, requesters []types.Address) error
+
 	OnNewState(fn func(tx *Tx, state tree.Node, leaves []types.ID))
 }
 
@@ -40,32 +54,39 @@ type controller struct {
 
 	stateURI        string
 	stateDBRootPath string
+	stateDBEngine   tree.KVEngine
 
 	controllerHub ControllerHub
 	txStore       TxStore
 	refStore      RefStore
 
-	behaviorTree *behaviorTree
+	behaviorTree   *behaviorTree
+	behaviorTreeMu sync.RWMutex
 
-	states  *tree.VersionedDBTree
-	indices *tree.VersionedDBTree
+	states    *tree.VersionedDBTree
+	indices   *tree.VersionedDBTree
+	indicesMu sync.Mutex
 
 	newStateListeners   []func(tx *Tx, state tree.Node, leaves []types.ID)
 	newStateListenersMu sync.RWMutex
 
-	mempool Mempool
-	addTxMu sync.Mutex
+	mempool   Mempool
+	addTxMu   sync.Mutex
+	applyTxMu sync.Mutex
+	pruneMu   sync.Mutex
 }
 
 var (
 	MergeTypeKeypath = tree.Keypath("Merge-Type")
 	ValidatorKeypath = tree.Keypath("Validator")
+	IndicesKeypath   = tree.Keypath("Indices")
 	MembersKeypath   = tree.Keypath("Members")
 )
 
 func NewController(
 	stateURI string,
 	stateDBRootPath string,
+	stateDBEngine tree.KVEngine,
 	controllerHub ControllerHub,
 	txStore TxStore,
 	refStore RefStore,
@@ -75,6 +96,7 @@ func NewController(
 		chStop:          make(chan struct{}),
 		stateURI:        stateURI,
 		stateDBRootPath: stateDBRootPath,
+		stateDBEngine:   stateDBEngine,
 		controllerHub:   controllerHub,
 		txStore:         txStore,
 		refStore:        refStore,
@@ -91,13 +113,13 @@ func (c *controller) Start() (err error) {
 	}()
 
 	stateURIClean := strings.NewReplacer(":", "_", "/", "_").Replace(c.stateURI)
-	states, err := tree.NewVersionedDBTree(filepath.Join(c.stateDBRootPath, stateURIClean))
+	states, err := tree.NewVersionedDBTreeWithEngine(c.stateDBEngine, filepath.Join(c.stateDBRootPath, stateURIClean))
 	if err != nil {
 		return err
 	}
 	c.states = states
 
-	indices, err := tree.NewVersionedDBTree(filepath.Join(c.stateDBRootPath, stateURIClean+"_indices"))
+	indices, err := tree.NewVersionedDBTreeWithEngine(c.stateDBEngine, filepath.Join(c.stateDBRootPath, stateURIClean+"_indices"))
 	if err != nil {
 		return err
 	}
@@ -107,12 +129,22 @@ func (c *controller) Start() (err error) {
 	c.behaviorTree.addResolver(tree.Keypath(nil), &dumbResolver{})
 
 	// Start mempool
-	c.mempool = NewMempool(c.processMempoolTx)
+	c.mempool = NewMempool(c.processMempoolTx, c.evictMempoolTx, DefaultMempoolOpts)
 	err = c.mempool.Start()
 	if err != nil {
 		return err
 	}
 
+	// Reload any txs that were waiting in the mempool when we last shut down
+	mempoolEntries, err := c.txStore.MempoolTxs(c.stateURI)
+	if err != nil {
+		return err
+	}
+	if len(mempoolEntries) > 0 {
+		c.Infof(0, "restoring %v txs to the mempool (%v)", len(mempoolEntries), c.stateURI)
+		c.mempool.Restore(mempoolEntries)
+	}
+
 	// Listen for new refs
 	c.refStore.OnRefsSaved(c.mempool.ForceReprocess)
 
@@ -139,6 +171,13 @@ func (c *controller) Close() {
 			c.Errorf("error closing index db: %v", err)
 		}
 	}
+
+	c.behaviorTreeMu.RLock()
+	err := closeBehaviorsNotIn(c.behaviorTree.closers(), newBehaviorTree())
+	c.behaviorTreeMu.RUnlock()
+	if err != nil {
+		c.Errorf("error closing behaviors: %v", err)
+	}
 }
 
 func (c *controller) StateAtVersion(version *types.ID) tree.Node {
@@ -149,6 +188,27 @@ func (c *controller) Leaves() ([]types.ID, error) {
 	return c.txStore.Leaves(c.stateURI)
 }
 
+// newestLeafTimestamp returns the latest timestamp among the current leaves,
+// or 0 if none of them has one.
+func (c *controller) newestLeafTimestamp() (int64, error) {
+	leaves, err := c.txStore.Leaves(c.stateURI)
+	if err != nil {
+		return 0, err
+	}
+	var newest int64
+	for _, leafID := range leaves {
+		leaf, err := c.txStore.FetchTx(c.stateURI, leafID)
+		if errors.Cause(err) == types.Err404 {
+			continue
+		} else if err != nil {
+			return 0, err
+		} else if leaf.Timestamp > newest {
+			newest = leaf.Timestamp
+		}
+	}
+	return newest, nil
+}
+
 func (c *controller) IsPrivate() (bool, error) {
 	state := c.StateAtVersion(nil)
 	defer state.Close()
@@ -233,6 +293,40 @@ func (c *controller) Mempool() *txSortedSet {
 	return c.mempool.Get()
 }
 
+func (c *controller) MempoolStats() (MempoolStats, error) {
+	stats := c.mempool.Stats()
+
+	inMempool := make(map[types.ID]struct{})
+	entries := c.mempool.Entries()
+	for _, entry := range entries {
+		inMempool[entry.Tx.ID] = struct{}{}
+	}
+
+	for _, entry := range entries {
+		for _, parentID := range entry.Tx.Parents {
+			if _, exists := inMempool[parentID]; exists {
+				continue
+			}
+			exists, err := c.txStore.TxExists(c.stateURI, parentID)
+			if err != nil {
+				return MempoolStats{}, err
+			} else if !exists {
+				stats.MissingParents[parentID]++
+			}
+		}
+	}
+	return stats, nil
+}
+
+// evictMempoolTx forgets a tx that waited in the mempool for too long, so that
+// it will be accepted again if a peer resends it.
+func (c *controller) evictMempoolTx(tx *Tx) {
+	err := c.txStore.RemoveTx(tx.StateURI, tx.ID)
+	if err != nil {
+		c.Errorf("error removing evicted tx %v: %v", tx.ID.Pretty(), err)
+	}
+}
+
 var (
 	ErrNoParentYet         = errors.New("no parent yet")
 	ErrPendingParent       = errors.New("parent pending validation")
@@ -241,10 +335,17 @@ var (
 	ErrInvalidTx           = errors.New("invalid tx")
 	ErrTxMissingParents    = errors.New("tx must have parents")
 	ErrMissingCriticalRefs = errors.New("missing critical refs")
+	ErrTxFromFuture        = errors.New("tx is from the future")
 )
 
+// txTimestampSkew is how far a tx's timestamp may stray from our own notion of
+// the time (see tryApplyTx).
+const txTimestampSkew = 5 * time.Minute
+
 func (c *controller) processMempoolTx(tx *Tx) processTxOutcome {
+	c.applyTxMu.Lock()
 	err := c.tryApplyTx(tx)
+	c.applyTxMu.Unlock()
 
 	if err == nil {
 		c.Successf("tx added to chain (%v) %v", tx.StateURI, tx.ID.Pretty())
@@ -254,9 +355,18 @@ func (c *controller) processMempoolTx(tx *Tx) processTxOutcome {
 	switch errors.Cause(err) {
 	case ErrTxMissingParents, ErrInvalidParent, ErrInvalidSignature, ErrInvalidTx:
 		c.Errorf("invalid tx %v: %+v: %v", tx.ID.Pretty(), err, PrettyJSON(tx))
+
+		// Mark the tx invalid so that it isn't restored to the mempool on restart
+		if tx.Status != TxStatusInvalid {
+			tx.Status = TxStatusInvalid
+			err = c.txStore.AddTx(tx)
+			if err != nil {
+				c.Errorf("error marking tx %v invalid: %v", tx.ID.Pretty(), err)
+			}
+		}
 		return processTxOutcome_Failed
 
-	case ErrPendingParent, ErrMissingCriticalRefs, ErrNoParentYet:
+	case ErrPendingParent, ErrMissingCriticalRefs, ErrNoParentYet, ErrTxFromFuture:
 		c.Infof(0, "readding to mempool %v (%v)", tx.ID.Pretty(), err)
 		return processTxOutcome_Retry
 
@@ -276,16 +386,64 @@ func (c *controller) tryApplyTx(tx *Tx) (err error) {
 		return ErrTxMissingParents
 	}
 
+	existing, err := c.txStore.FetchTx(tx.StateURI, tx.ID)
+	if err != nil && errors.Cause(err) != types.Err404 {
+		return err
+	} else if existing != nil && existing.Status == TxStatusValid {
+		// This tx was part of a snapshot that was imported while it was waiting in the mempool
+		return nil
+	}
+
+	if tx.ID == GenesisTxID {
+		leaves, err := c.txStore.Leaves(c.stateURI)
+		if err != nil {
+			return err
+		} else if len(leaves) > 0 {
+			// This only happens when the state was bootstrapped from a snapshot
+			// and a peer then sends us the history that preceded it
+			return errors.Wrap(ErrInvalidTx, "genesis tx arrived after state was initialized")
+		}
+	}
+
 	for _, parentID := range tx.Parents {
 		parentTx, err := c.txStore.FetchTx(tx.StateURI, parentID)
 		if errors.Cause(err) == types.Err404 {
-			return errors.Wrapf(ErrNoParentYet, "parent=%v", parentID.Pretty())
+			// A parent that was pruned was valid, so late branches off of
+			// pruned history can still be applied
+			pruned, err := c.txStore.TxExists(tx.StateURI, parentID)
+			if err != nil {
+				return errors.Wrapf(err, "parent=%v", parentID.Pretty())
+			} else if !pruned {
+				return errors.Wrapf(ErrNoParentYet, "parent=%v", parentID.Pretty())
+			}
+			continue
 		} else if err != nil {
 			return errors.Wrapf(err, "parent=%v", parentID.Pretty())
 		} else if parentTx.Status == TxStatusInvalid {
 			return errors.Wrapf(ErrInvalidParent, "parent=%v", parentID.Pretty())
 		} else if parentTx.Status == TxStatusInMempool {
 			return errors.Wrapf(ErrPendingParent, "parent=%v", parentID.Pretty())
+		} else if tx.Timestamp != 0 && tx.Timestamp < parentTx.Timestamp {
+			// Validators evaluate time-limited permissions against the tx's
+			// timestamp, so it may not be backdated past its history
+			return errors.Wrapf(ErrInvalidTx, "tx is older than its parent %v", parentID.Pretty())
+		}
+	}
+
+	// A sender could still backdate a tx by choosing old parents, so validators
+	// see a timestamp no earlier than our newest leaf (less an allowance for
+	// clock skew).  Txs from the future wait in the mempool, so that they can't
+	// push that floor forward.
+	validationTimestamp := tx.Timestamp
+	if tx.Timestamp != 0 {
+		if tx.Timestamp > time.Now().Add(txTimestampSkew).Unix() {
+			return errors.Wrapf(ErrTxFromFuture, "timestamp=%v", tx.Timestamp)
+		}
+		newest, err := c.newestLeafTimestamp()
+		if err != nil {
+			return err
+		} else if floor := newest - int64(txTimestampSkew/time.Second); floor > validationTimestamp {
+			validationTimestamp = floor
 		}
 	}
 
@@ -311,25 +469,15 @@ func (c *controller) tryApplyTx(tx *Tx) (err error) {
 		for i := len(c.behaviorTree.validatorKeypaths) - 1; i >= 0; i-- {
 			validatorKeypath := c.behaviorTree.validatorKeypaths[i]
 
-			var unprocessedPatches []Patch
-			var patchesTrimmed []Patch
-			for _, patch := range patches {
-				if patch.Keypath.StartsWith(validatorKeypath) {
-					patchesTrimmed = append(patchesTrimmed, Patch{
-						Keypath: patch.Keypath.RelativeTo(validatorKeypath),
-						Range:   patch.Range,
-						Val:     patch.Val,
-					})
-				} else {
-					unprocessedPatches = append(unprocessedPatches, patch)
-				}
-			}
-
-			txCopy := *tx
-			txCopy.Patches = patchesTrimmed
+			patchesTrimmed, unprocessedPatches, err := trimPatches(patches, validatorKeypath)
+			if err == nil {
+				txCopy := *tx
+				txCopy.Patches = patchesTrimmed
+				txCopy.Timestamp = validationTimestamp
 
-			validator := c.behaviorTree.validators[string(validatorKeypath)]
-			err := validator.ValidateTx(state.NodeAt(validatorKeypath, nil), &txCopy)
+				validator := c.behaviorTree.validators[string(validatorKeypath)]
+				err = validator.ValidateTx(state.NodeAt(validatorKeypath, nil), &txCopy)
+			}
 			if err != nil {
 				// Mark the tx invalid and save it to the DB
 				tx.Status = TxStatusInvalid
@@ -354,20 +502,10 @@ func (c *controller) tryApplyTx(tx *Tx) (err error) {
 		for i := len(c.behaviorTree.resolverKeypaths) - 1; i >= 0; i-- {
 			resolverKeypath := c.behaviorTree.resolverKeypaths[i]
 
-			var unprocessedPatches []Patch
-			var patchesTrimmed []Patch
-			for _, patch := range patches {
-				if patch.Keypath.StartsWith(resolverKeypath) {
-					patchesTrimmed = append(patchesTrimmed, Patch{
-						Keypath: patch.Keypath.RelativeTo(resolverKeypath),
-						Range:   patch.Range,
-						Val:     patch.Val,
-					})
-				} else {
-					unprocessedPatches = append(unprocessedPatches, patch)
-				}
-			}
-			if len(patchesTrimmed) == 0 {
+			patchesTrimmed, unprocessedPatches, err := trimPatches(patches, resolverKeypath)
+			if err != nil {
+				return errors.Wrap(ErrInvalidTx, err.Error())
+			} else if len(patchesTrimmed) == 0 {
 				patches = unprocessedPatches
 				continue
 			}
@@ -426,18 +564,25 @@ func (c *controller) tryApplyTx(tx *Tx) (err error) {
 		return err
 	}
 
-	err = state.Save()
+	err = c.saveState(state)
 	if err != nil {
 		return err
 	}
 
 	if tx.Checkpoint {
-		err = c.states.CopyVersion(tx.ID, tree.CurrentVersion)
+		err = c.saveCheckpoint(tx.ID)
 		if err != nil {
 			return err
 		}
 	}
 
+	// Mark this tx as a leaf before unmarking its parents, so that a tx
+	// created in the meantime never sees an empty set of leaves
+	err = c.txStore.MarkLeaf(c.stateURI, tx.ID)
+	if err != nil {
+		return err
+	}
+
 	// Unmark parents as leaves
 	for _, parentID := range tx.Parents {
 		err := c.txStore.UnmarkLeaf(c.stateURI, parentID)
@@ -446,12 +591,6 @@ func (c *controller) tryApplyTx(tx *Tx) (err error) {
 		}
 	}
 
-	// Mark this tx as a leaf
-	err = c.txStore.MarkLeaf(c.stateURI, tx.ID)
-	if err != nil {
-		return err
-	}
-
 	// Mark the tx valid and save it to the DB
 	tx.Status = TxStatusValid
 	err = c.txStore.AddTx(tx)
@@ -464,6 +603,13 @@ func (c *controller) tryApplyTx(tx *Tx) (err error) {
 		return err
 	}
 
+	if tx.Checkpoint {
+		err = c.txStore.AddCheckpoint(c.stateURI, Checkpoint{TxID: tx.ID, Frontier: leaves, Created: time.Now()})
+		if err != nil {
+			return err
+		}
+	}
+
 	state = c.states.StateAtVersion(nil, false)
 	defer state.Close()
 	c.notifyNewStateListeners(tx, state, leaves)
@@ -483,43 +629,68 @@ func (c *controller) handleNewRefs(state tree.Node) {
 
 	// Find all refs in the tree and notify the Host to start fetching them
 	for kp := range diff.Added {
-		keypath := tree.Keypath(kp)
-		parentKeypath, key := keypath.Pop()
-		switch {
-		case key.Equals(nelson.ValueKey):
-			contentType, err := nelson.GetContentType(state.NodeAt(parentKeypath, nil))
-			if err != nil && errors.Cause(err) != types.Err404 {
-				c.Errorf("error getting ref content type: %v", err)
-				continue
-			} else if contentType != "link" {
-				continue
-			}
-
-			linkStr, _, err := state.StringValue(keypath)
-			if err != nil {
-				c.Errorf("error getting ref link value: %v", err)
-				continue
-			}
-			linkType, linkValue := nelson.DetermineLinkType(linkStr)
-			if linkType == nelson.LinkTypeRef {
-				var refID types.RefID
-				err := refID.UnmarshalText([]byte(linkValue))
-				if err != nil {
-					c.Errorf("error unmarshaling refID: %v", err)
-					continue
-				}
-				refs = append(refs, refID)
-			}
+		refID, isRef, err := refAtKeypath(state, tree.Keypath(kp))
+		if err != nil {
+			c.Errorf("%v", err)
+			continue
+		} else if !isRef {
+			continue
 		}
+		refs = append(refs, refID)
 	}
 }
 
-func (c *controller) updateBehaviorTree(state tree.Node) error {
+// refAtKeypath returns the ref that a NelSON link frame points to, if keypath
+// is the `value` of such a frame.
+func refAtKeypath(state tree.Node, keypath tree.Keypath) (types.RefID, bool, error) {
+	parentKeypath, key := keypath.Pop()
+	if !key.Equals(nelson.ValueKey) {
+		return types.RefID{}, false, nil
+	}
+
+	contentType, err := nelson.GetContentType(state.NodeAt(parentKeypath, nil))
+	if err != nil && errors.Cause(err) != types.Err404 {
+		return types.RefID{}, false, errors.Wrap(err, "error getting ref content type")
+	} else if contentType != "link" {
+		return types.RefID{}, false, nil
+	}
+
+	linkStr, _, err := state.StringValue(keypath)
+	if err != nil {
+		return types.RefID{}, false, errors.Wrap(err, "error getting ref link value")
+	}
+	linkType, linkValue := nelson.DetermineLinkType(linkStr)
+	if linkType != nelson.LinkTypeRef {
+		return types.RefID{}, false, nil
+	}
+
+	var refID types.RefID
+	err = refID.UnmarshalText([]byte(linkValue))
+	if err != nil {
+		return types.RefID{}, false, errors.Wrap(err, "error unmarshaling refID")
+	}
+	return refID, true, nil
+}
+
+func (c *controller) updateBehaviorTree(state tree.Node) (err error) {
 	// Walk the tree and initialize validators and resolvers (@@TODO: inefficient)
 
 	// We need to be able to roll back in case of error, so we make a copy
 	newBehaviorTree := c.behaviorTree.copy()
 
+	// Whichever tree loses, close the behaviors that only it was using
+	defer func() {
+		var closeErr error
+		if err != nil {
+			closeErr = closeBehaviorsNotIn(append(newBehaviorTree.replaced, newBehaviorTree.closers()...), c.behaviorTree)
+		} else {
+			closeErr = closeBehaviorsNotIn(newBehaviorTree.replaced, newBehaviorTree)
+		}
+		if closeErr != nil {
+			c.Errorf("error closing replaced behaviors: %v", closeErr)
+		}
+	}()
+
 	diff := state.Diff()
 
 	// Remove deleted resolvers and validators
@@ -527,10 +698,10 @@ func (c *controller) updateBehaviorTree(state tree.Node) error {
 		parentKeypath, key := tree.Keypath(kp).Pop()
 		switch {
 		case key.Equals(MergeTypeKeypath):
-			c.behaviorTree.removeResolver(parentKeypath)
+			newBehaviorTree.removeResolver(parentKeypath)
 		case key.Equals(ValidatorKeypath):
-			c.behaviorTree.removeValidator(parentKeypath)
-		case parentKeypath.Part(-1).Equals(tree.Keypath("Indices")):
+			newBehaviorTree.removeValidator(parentKeypath)
+		case parentKeypath.Part(-1).Equals(IndicesKeypath):
 			//indicesKeypath, _ := parentKeypath.Pop()
 			//c.behaviorTree.removeIndexer()
 		}
@@ -570,7 +741,7 @@ func (c *controller) updateBehaviorTree(state tree.Node) error {
 				return err
 			}
 
-		case key.Equals(tree.Keypath("Indices")):
+		case key.Equals(IndicesKeypath):
 			err := c.initializeIndexer(newBehaviorTree, state, keypath)
 			if err != nil {
 				return err
@@ -594,7 +765,9 @@ func (c *controller) updateBehaviorTree(state tree.Node) error {
 			parentKeypath = nextParentKeypath
 		}
 	}
+	c.behaviorTreeMu.Lock()
 	c.behaviorTree = newBehaviorTree
+	c.behaviorTreeMu.Unlock()
 	return nil
 }
 
@@ -613,18 +786,6 @@ func (c *controller) initializeResolver(behaviorTree *behaviorTree, state tree.N
 		return errors.WithStack(ErrMissingCriticalRefs)
 	}
 
-	contentType, err := nelson.GetContentType(config)
-	if err != nil {
-		return err
-	} else if contentType == "" {
-		return errors.New("cannot initialize resolver without a 'Content-Type' key")
-	}
-
-	ctor, exists := resolverRegistry[contentType]
-	if !exists {
-		return errors.Errorf("unknown resolver type '%v'", contentType)
-	}
-
 	// @@TODO: if the resolver type changes, this totally breaks everything
 	var internalState map[string]interface{}
 	oldResolver, oldResolverKeypath := behaviorTree.nearestResolverForKeypath(resolverConfigKeypath)
@@ -634,7 +795,7 @@ func (c *controller) initializeResolver(behaviorTree *behaviorTree, state tree.N
 		internalState = oldResolver.InternalState()
 	}
 
-	resolver, err := ctor(config, internalState)
+	resolver, err := initResolverFromConfig(config, internalState)
 	if err != nil {
 		return err
 	}
@@ -664,25 +825,17 @@ func (c *controller) initializeValidator(behaviorTree *behaviorTree, state tree.
 		return errors.WithStack(ErrMissingCriticalRefs)
 	}
 
-	contentType, err := nelson.GetContentType(config)
+	validator, err := initValidatorFromConfig(config)
 	if err != nil {
 		return err
-	} else if contentType == "" {
-		return errors.New("cannot initialize validator without a 'Content-Type' key")
 	}
 
-	ctor, exists := validatorRegistry[contentType]
-	if !exists {
-		return errors.Errorf("unknown validator type '%v'", contentType)
-	}
+	validatorNodeKeypath, _ := validatorConfigKeypath.Pop()
 
-	validator, err := ctor(config)
-	if err != nil {
-		return err
+	if scoped, is := validator.(ScopedValidator); is {
+		scoped.SetScope(c.stateURI, validatorNodeKeypath)
 	}
 
-	validatorNodeKeypath, _ := validatorConfigKeypath.Pop()
-
 	behaviorTree.addValidator(validatorNodeKeypath, validator)
 	return nil
 }
@@ -761,51 +914,566 @@ func (c *controller) QueryIndex(version *types.ID, keypath tree.Keypath, indexNa
 
 	indexNode := c.indices.IndexAtVersion(version, keypath, indexName, false)
 
-	exists, err := indexNode.Exists(queryParam)
+	// Indices are kept up to date as txs are applied, but one that doesn't
+	// exist yet (or that couldn't be updated) is built the first time it's queried
+	exists, err := indexNode.Exists(nil)
 	if err != nil {
+		indexNode.Close()
 		return nil, err
 
 	} else if !exists {
 		indexNode.Close()
-		indexNode = c.indices.IndexAtVersion(version, keypath, indexName, true)
 
-		indices, exists := c.behaviorTree.indexers[string(keypath)]
-		if !exists {
-			return nil, types.Err404
+		err = c.buildIndex(version, keypath, indexName)
+		if err != nil {
+			return nil, err
 		}
-		indexer, exists := indices[string(indexName)]
-		if !exists {
-			return nil, types.Err404
+		indexNode = c.indices.IndexAtVersion(version, keypath, indexName, false)
+	}
+
+	c.behaviorTreeMu.RLock()
+	indexer := c.behaviorTree.indexers[string(keypath)][string(indexName)]
+	c.behaviorTreeMu.RUnlock()
+
+	if searchIndexer, is := indexer.(SearchIndexer); is {
+		defer indexNode.Close()
+		return c.searchIndex(version, keypath, indexNode, searchIndexer, queryParam, rng)
+	} else if rangeIndexer, is := indexer.(RangeIndexer); is {
+		defer indexNode.Close()
+		return queryIndexRange(indexNode, rangeIndexer, queryParam, rng)
+	}
+
+	exists, err = indexNode.Exists(queryParam)
+	if err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, types.Err404
+	}
+	return indexNode.NodeAt(queryParam, rng), nil
+}
+
+// queryIndexRange copies the entries of an ordered index that fall within the
+// range described by queryParam into a slice.
+func queryIndexRange(indexNode *tree.DBNode, indexer RangeIndexer, queryParam tree.Keypath, rng *tree.Range) (tree.Node, error) {
+	keyRange, err := indexer.ParseKeyRange(queryParam)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := indexNode.ChildKeysInRange(keyRange)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		val, exists, err := indexNode.Value(key, nil)
+		if err != nil {
+			return nil, err
+		} else if exists {
+			entries = append(entries, val)
 		}
+	}
+
+	result := tree.NewMemoryNode()
+	err = result.Set(nil, nil, entries)
+	if err != nil {
+		return nil, err
+	}
+	return result.NodeAt(nil, rng), nil
+}
+
+// searchIndex runs a search against an index and returns its results as a slice.
+func (c *controller) searchIndex(version *types.ID, keypath tree.Keypath, indexNode *tree.DBNode, indexer SearchIndexer, query tree.Keypath, rng *tree.Range) (tree.Node, error) {
+	state := c.states.StateAtVersion(version, false)
+	defer state.Close()
+
+	indexedKeypath, err := unwrappedKeypath(state, keypath)
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := indexer.Search(indexNode, state.NodeAt(indexedKeypath, nil), query)
+	if err != nil {
+		return nil, err
+	}
+
+	result := tree.NewMemoryNode()
+	err = result.Set(nil, nil, matches)
+	if err != nil {
+		return nil, err
+	}
+	return result.NodeAt(nil, rng), nil
+}
+
+func (c *controller) buildIndex(version *types.ID, keypath tree.Keypath, indexName tree.Keypath) error {
+	c.behaviorTreeMu.RLock()
+	indexer, exists := c.behaviorTree.indexers[string(keypath)][string(indexName)]
+	c.behaviorTreeMu.RUnlock()
+	if !exists {
+		return types.Err404
+	}
+
+	c.indicesMu.Lock()
+	defer c.indicesMu.Unlock()
+
+	state := c.states.StateAtVersion(version, false)
+	defer state.Close()
+
+	indexedKeypath, err := unwrappedKeypath(state, keypath)
+	if err != nil {
+		return err
+	}
+	return c.indices.RebuildIndex(version, keypath, state.NodeAt(indexedKeypath, nil), indexName, indexer)
+}
+
+// saveState commits changes to the current version of the state and brings
+// its indices up to date with them.
+func (c *controller) saveState(state *tree.DBNode) error {
+	oldState := c.states.StateAtVersion(nil, false)
+	defer oldState.Close()
+
+	c.indicesMu.Lock()
+	defer c.indicesMu.Unlock()
+
+	err := state.Save()
+	if err != nil {
+		return err
+	}
+	c.updateIndices(oldState, state.Diff())
+	return nil
+}
+
+// saveCheckpoint saves the current version of the state and its indices under
+// the given version.
+func (c *controller) saveCheckpoint(version types.ID) error {
+	err := c.states.CopyVersion(version, tree.CurrentVersion)
+	if err != nil {
+		return err
+	}
+
+	c.indicesMu.Lock()
+	defer c.indicesMu.Unlock()
+	return c.indices.CopyVersion(version, tree.CurrentVersion)
+}
 
-		if version == nil {
-			version = &tree.CurrentVersion
+// updateIndices applies a diff to every index on the current version of the
+// state.  oldState is the state from before the diff.  An index that can't be
+// updated is deleted so that QueryIndex will rebuild it.
+func (c *controller) updateIndices(oldState tree.Node, diff *tree.Diff) {
+	c.behaviorTreeMu.RLock()
+	indexers := c.behaviorTree.indexers
+	c.behaviorTreeMu.RUnlock()
+	if len(indexers) == 0 {
+		return
+	}
+
+	newState := c.states.StateAtVersion(nil, false)
+	defer newState.Close()
+
+	changed := make([]tree.Keypath, 0, len(diff.AddedList)+len(diff.RemovedList))
+	changed = append(changed, diff.AddedList...)
+	changed = append(changed, diff.RemovedList...)
+
+	for keypath, indices := range indexers {
+		for indexName, indexer := range indices {
+			err := c.updateIndex(oldState, newState, tree.Keypath(keypath), tree.Keypath(indexName), indexer, changed)
+			if err != nil {
+				c.Errorf("error updating index %v on %v: %v", indexName, keypath, err)
+
+				err = c.indices.DeleteIndex(nil, tree.Keypath(keypath), tree.Keypath(indexName))
+				if err != nil {
+					c.Errorf("error deleting index %v on %v: %v", indexName, keypath, err)
+				}
+			}
+		}
+	}
+}
+
+func (c *controller) updateIndex(oldState, newState tree.Node, keypath, indexName tree.Keypath, indexer Indexer, changed []tree.Keypath) error {
+	oldIndexedKeypath, err := unwrappedKeypath(oldState, keypath)
+	if err != nil {
+		return err
+	}
+	indexedKeypath, err := unwrappedKeypath(newState, keypath)
+	if err != nil {
+		return err
+	}
+	indexedNode := newState.NodeAt(indexedKeypath, nil)
+
+	if !oldIndexedKeypath.Equals(indexedKeypath) {
+		return c.indices.RebuildIndex(nil, keypath, indexedNode, indexName, indexer)
+	}
+
+	indexerConfigKeypath := keypath.Push(IndicesKeypath).Push(indexName)
+
+	var changedKeypaths []tree.Keypath
+	for _, kp := range changed {
+		if indexedKeypath.StartsWith(kp) || indexerConfigKeypath.StartsWith(kp) || kp.StartsWith(indexerConfigKeypath) {
+			// The indexed node was replaced, or the indexer was (re)configured
+			return c.indices.RebuildIndex(nil, keypath, indexedNode, indexName, indexer)
+		} else if kp.StartsWith(indexedKeypath) {
+			changedKeypaths = append(changedKeypaths, kp.RelativeTo(indexedKeypath))
 		}
+	}
+	if len(changedKeypaths) == 0 {
+		return nil
+	}
+	return c.indices.UpdateIndex(nil, keypath, indexName, oldState.NodeAt(indexedKeypath, nil), indexedNode, changedKeypaths, indexer)
+}
 
-		nodeToIndex, err := c.states.StateAtVersion(version, false).NodeAt(keypath, nil).CopyToMemory(nil, nil)
+// unwrappedKeypath follows a keypath through any NelSON frames to the node
+// they wrap.
+func unwrappedKeypath(state tree.Node, keypath tree.Keypath) (tree.Keypath, error) {
+	for {
+		exists, err := state.Exists(keypath.Push(nelson.ValueKey))
 		if err != nil {
 			return nil, err
+		} else if !exists {
+			return keypath, nil
+		}
+		keypath = keypath.Push(nelson.ValueKey)
+	}
+}
+
+// trimPatches splits off the patches under keypath, making their keypaths
+// relative to it.  Moves and copies may not cross into or out of keypath,
+// because the validator or resolver there would only see one side of them.
+func trimPatches(patches []Patch, keypath tree.Keypath) (trimmed []Patch, rest []Patch, err error) {
+	for _, patch := range patches {
+		under := patch.Keypath.StartsWith(keypath)
+		if patch.Op == PatchOpMove || patch.Op == PatchOpCopy {
+			if patch.From.StartsWith(keypath) != under {
+				return nil, nil, errors.Errorf("patch crosses %v: %v", keypath, patch.String())
+			}
+		}
+		if !under {
+			rest = append(rest, patch)
+			continue
+		}
+
+		trimmedPatch := Patch{
+			Op:      patch.Op,
+			Keypath: patch.Keypath.RelativeTo(keypath),
+			Range:   patch.Range,
+			Val:     patch.Val,
+		}
+		if patch.From != nil {
+			trimmedPatch.From = patch.From.RelativeTo(keypath)
+		}
+		trimmed = append(trimmed, trimmedPatch)
+	}
+	return trimmed, rest, nil
+}
+
+// ValidateRead returns types.Err403 unless, for every validator governing the
+// given keypath that restricts reads, at least one of the requesters may read it.
+func (c *controller) ValidateRead(keypath tree.Keypath, requesters []types.Address) error {
+	c.behaviorTreeMu.RLock()
+	defer c.behaviorTreeMu.RUnlock()
+
+	state := c.states.StateAtVersion(nil, false)
+	defer state.Close()
+
+	return c.validateRead(state, keypath, requesters)
+}
+
+func (c *controller) validateRead(state tree.Node, keypath tree.Keypath, requesters []types.Address) error {
+	if len(requesters) == 0 {
+		requesters = []types.Address{{}}
+	}
+
+	for _, validatorKeypath := range c.behaviorTree.validatorKeypaths {
+		if !keypath.StartsWith(validatorKeypath) {
+			continue
+		}
+		readValidator, is := c.behaviorTree.validators[string(validatorKeypath)].(ReadValidator)
+		if !is {
+			continue
 		}
 
-		nodeToIndex, relKeypath, err := nelson.Unwrap(nodeToIndex)
+		var err error
+		for _, requester := range requesters {
+			err = readValidator.ValidateRead(state.NodeAt(validatorKeypath, nil), keypath.RelativeTo(validatorKeypath), requester)
+			if err == nil {
+				break
+			}
+		}
 		if err != nil {
-			return nil, err
+			return err
 		}
+	}
+	return nil
+}
+
+// ValidateReadSubtree is like ValidateRead, but also requires that the
+// requesters may read everything beneath keypath.
+func (c *controller) ValidateReadSubtree(keypath tree.Keypath, requesters []types.Address) error {
+	c.behaviorTreeMu.RLock()
+	defer c.behaviorTreeMu.RUnlock()
+
+	state := c.states.StateAtVersion(nil, false)
+	defer state.Close()
+
+	err := c.validateRead(state, keypath, requesters)
+	if err != nil {
+		return err
+	} else if !c.hasReadValidators(keypath) {
+		return nil
+	}
 
-		err = c.indices.BuildIndex(version, relKeypath, nodeToIndex, indexName, indexer)
+	node, err := state.CopyToMemory(keypath, nil)
+	if errors.Cause(err) == types.Err404 {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return c.validateReadSubtree(state, node, keypath, nil, requesters)
+}
+
+func (c *controller) validateReadSubtree(state tree.Node, node tree.Node, keypath tree.Keypath, relKeypath tree.Keypath, requesters []types.Address) error {
+	for _, subkey := range node.NodeAt(relKeypath, nil).Subkeys() {
+		childKeypath := relKeypath.Push(subkey)
+
+		err := c.validateRead(state, keypath.Push(childKeypath), requesters)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		err = c.validateReadSubtree(state, node, keypath, childKeypath, requesters)
+		if err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		indexNode = c.indices.IndexAtVersion(version, keypath, indexName, false)
+// RedactUnreadable deletes everything from node (an in-memory copy of the state
+// at keypath) that none of the requesters may read.
+func (c *controller) RedactUnreadable(node tree.Node, keypath tree.Keypath, requesters []types.Address) error {
+	c.behaviorTreeMu.RLock()
+	defer c.behaviorTreeMu.RUnlock()
+
+	if !c.hasReadValidators(keypath) {
+		return nil
+	}
+
+	state := c.states.StateAtVersion(nil, false)
+	defer state.Close()
 
-		exists, err = indexNode.Exists(queryParam)
+	return c.redactUnreadable(state, node, keypath, nil, requesters)
+}
+
+// hasReadValidators returns true if any validator above or below keypath
+// restricts reads.
+func (c *controller) hasReadValidators(keypath tree.Keypath) bool {
+	for _, validatorKeypath := range c.behaviorTree.validatorKeypaths {
+		if _, is := c.behaviorTree.validators[string(validatorKeypath)].(ReadValidator); !is {
+			continue
+		} else if validatorKeypath.StartsWith(keypath) || keypath.StartsWith(validatorKeypath) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *controller) redactUnreadable(state tree.Node, node tree.Node, keypath tree.Keypath, relKeypath tree.Keypath, requesters []types.Address) error {
+	for _, subkey := range node.NodeAt(relKeypath, nil).Subkeys() {
+		childKeypath := relKeypath.Push(subkey)
+
+		err := c.validateRead(state, keypath.Push(childKeypath), requesters)
+		if errors.Cause(err) == types.Err403 {
+			err = node.Delete(childKeypath, nil)
+			if err != nil {
+				return err
+			}
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		err = c.redactUnreadable(state, node, keypath, childKeypath, requesters)
 		if err != nil {
-			return nil, err
-		} else if !exists {
-			return nil, types.Err404
+			return err
 		}
 	}
+	return nil
+}
 
-	return indexNode.NodeAt(queryParam, rng), nil
+// validateReadAll returns types.Err403 unless the requesters may read every
+// leaf of node at or below keypath.
+func (c *controller) validateReadAll(state tree.Node, node tree.Node, keypath tree.Keypath, requesters []types.Address) error {
+	subkeys := node.NodeAt(keypath, nil).Subkeys()
+	if len(subkeys) == 0 {
+		return c.validateRead(state, keypath, requesters)
+	}
+	for _, subkey := range subkeys {
+		err := c.validateReadAll(state, node, keypath.Push(subkey), requesters)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportSnapshot returns an unsigned snapshot of the state as of the given
+// checkpoint tx, or of the most recent checkpoint if checkpointID is nil.  The
+// requesters must be able to read the entire state, since a partial snapshot
+// can't be used to bootstrap a node.
+func (c *controller) ExportSnapshot(checkpointID *types.ID, requesters []types.Address) (_ *Snapshot, err error) {
+	defer utils.Annotate(&err, "stateURI=%v", c.stateURI)
+
+	checkpoints, err := c.txStore.Checkpoints(c.stateURI)
+	if err != nil {
+		return nil, err
+	}
+
+	var checkpoint *Checkpoint
+	for i := len(checkpoints) - 1; i >= 0; i-- {
+		if checkpointID == nil || checkpoints[i].TxID == *checkpointID {
+			checkpoint = &checkpoints[i]
+			break
+		}
+	}
+	if checkpoint == nil {
+		return nil, errors.Wrap(types.Err404, "no such checkpoint")
+	}
+
+	state := c.states.StateAtVersion(&checkpoint.TxID, false)
+	defer state.Close()
+
+	err = func() error {
+		c.behaviorTreeMu.RLock()
+		defer c.behaviorTreeMu.RUnlock()
+
+		if !c.hasReadValidators(nil) {
+			return nil
+		}
+
+		currentState := c.states.StateAtVersion(nil, false)
+		defer currentState.Close()
+
+		return c.validateReadAll(currentState, state, nil, requesters)
+	}()
+	if err != nil {
+		return nil, err
+	}
+
+	value, _, err := state.Value(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	stateBytes, err := json.Marshal(value)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	frontier := make([]*Tx, len(checkpoint.Frontier))
+	for i, txID := range checkpoint.Frontier {
+		frontier[i], err = c.txStore.FetchTx(c.stateURI, txID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "frontier tx %v", txID.Pretty())
+		}
+		frontier[i].Children = nil
+	}
+
+	return &Snapshot{
+		StateURI:     c.stateURI,
+		CheckpointID: checkpoint.TxID,
+		Frontier:     frontier,
+		State:        stateBytes,
+	}, nil
+}
+
+// ImportSnapshot bootstraps a controller that has no history yet from a
+// snapshot exported by another node.  Once it's imported, txs that descend from
+// the snapshot's frontier are applied as usual.
+func (c *controller) ImportSnapshot(snapshot *Snapshot) (err error) {
+	defer utils.Annotate(&err, "stateURI=%v checkpoint=%v", c.stateURI, snapshot.CheckpointID.Pretty())
+
+	if snapshot.StateURI != c.stateURI {
+		return errors.Wrapf(ErrInvalidSnapshot, "wrong state URI (%v)", snapshot.StateURI)
+	}
+	err = snapshot.Validate()
+	if err != nil {
+		return err
+	}
+
+	var value interface{}
+	err = json.Unmarshal(snapshot.State, &value)
+	if err != nil {
+		return errors.Wrap(ErrInvalidSnapshot, err.Error())
+	}
+
+	c.addTxMu.Lock()
+	defer c.addTxMu.Unlock()
+	c.applyTxMu.Lock()
+	defer c.applyTxMu.Unlock()
+
+	leaves, err := c.txStore.Leaves(c.stateURI)
+	if err != nil {
+		return err
+	} else if len(leaves) > 0 {
+		return ErrSnapshotUnnecessary
+	}
+
+	state := c.states.StateAtVersion(nil, true)
+	defer state.Close()
+
+	err = state.Set(nil, nil, value)
+	if err != nil {
+		return err
+	}
+
+	c.handleNewRefs(state)
+
+	err = c.updateBehaviorTree(state)
+	if err != nil {
+		return err
+	}
+
+	err = c.saveState(state)
+	if err != nil {
+		return err
+	}
+
+	err = c.saveCheckpoint(snapshot.CheckpointID)
+	if err != nil {
+		return err
+	}
+
+	var checkpointTx *Tx
+	frontier := make([]types.ID, len(snapshot.Frontier))
+	for i, tx := range snapshot.Frontier {
+		txCopy := *tx
+		txCopy.Children = nil
+		txCopy.Status = TxStatusValid
+		err = c.txStore.AddTx(&txCopy)
+		if err != nil {
+			return err
+		}
+		err = c.txStore.MarkLeaf(c.stateURI, tx.ID)
+		if err != nil {
+			return err
+		}
+		frontier[i] = tx.ID
+		if tx.ID == snapshot.CheckpointID {
+			checkpointTx = &txCopy
+		}
+	}
+
+	err = c.txStore.AddCheckpoint(c.stateURI, Checkpoint{TxID: snapshot.CheckpointID, Frontier: frontier, Created: time.Now()})
+	if err != nil {
+		return err
+	}
+
+	c.Successf("imported snapshot (%v) at checkpoint %v", c.stateURI, snapshot.CheckpointID.Pretty())
+
+	// Txs that were waiting on the snapshot's frontier can now be applied
+	c.mempool.ForceReprocess()
+
+	state = c.states.StateAtVersion(nil, false)
+	defer state.Close()
+	c.notifyNewStateListeners(checkpointTx, state, frontier)
+
+	return nil
 }
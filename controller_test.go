@@ -0,0 +1,464 @@
+package redwood_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"redwood.dev"
+	"redwood.dev/crypto"
+	"redwood.dev/testutils"
+	"redwood.dev/tree"
+	"redwood.dev/types"
+)
+
+func TestControllerHub_QueryIndex(t *testing.T) {
+	signer, err := crypto.GenerateSigningKeypair()
+	require.NoError(t, err)
+
+	stateURI := "foo.com/bar"
+
+	hub, _, chNewState, cleanup := setupControllerHub(t)
+	defer cleanup()
+
+	var parent *types.ID
+	applyTx := func(checkpoint bool, patches ...string) types.ID {
+		t.Helper()
+
+		tx := &redwood.Tx{
+			ID:         redwood.GenesisTxID,
+			StateURI:   stateURI,
+			From:       signer.Address(),
+			Checkpoint: checkpoint,
+			Patches:    mustParsePatches(t, patches...),
+		}
+		if parent != nil {
+			tx.ID = types.RandomID()
+			tx.Parents = []types.ID{*parent}
+		}
+		sig, err := signer.SignHash(tx.Hash())
+		require.NoError(t, err)
+		tx.Sig = sig
+
+		require.NoError(t, hub.AddTx(tx, false))
+		for {
+			select {
+			case id := <-chNewState:
+				if id == tx.ID {
+					parent = &tx.ID
+					return tx.ID
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("timed out waiting for tx %v", tx.ID.Pretty())
+			}
+		}
+	}
+
+	queryIndex := func(version *types.ID, keypath, indexName, queryParam string) interface{} {
+		t.Helper()
+
+		node, err := hub.QueryIndex(stateURI, version, tree.Keypath(keypath), tree.Keypath(indexName), tree.Keypath(queryParam), nil)
+		if errors.Cause(err) == types.Err404 {
+			return nil
+		}
+		require.NoError(t, err)
+
+		val, exists, err := node.Value(nil, nil)
+		require.NoError(t, err)
+		require.True(t, exists)
+		return val
+	}
+
+	user := func(name string) interface{} {
+		return map[string]interface{}{"name": name}
+	}
+	msg := func(author, text string) interface{} {
+		return map[string]interface{}{"author": author, "text": text}
+	}
+
+	applyTx(false,
+		`.users = {
+			"Indices": {"byName": {"Content-Type": "indexer/keypath", "keypath": "name"}},
+			"u1": {"name": "alice"},
+			"u2": {"name": "bob"}
+		}`,
+		`.chat = {
+			"Indices": {"byAuthor": {"Content-Type": "indexer/keypath", "keypath": "author"}},
+			"value": [{"author": "alice", "text": "hi"}]
+		}`,
+	)
+
+	require.Equal(t, user("alice"), queryIndex(nil, "users", "byName", "alice"))
+	require.Equal(t, user("bob"), queryIndex(nil, "users", "byName", "bob"))
+	require.Equal(t, []interface{}{msg("alice", "hi")}, queryIndex(nil, "chat", "byAuthor", "alice"))
+
+	// Map entries are moved and added as the indexed children change
+	applyTx(false, `.users.u1.name = "carol"`, `.users.u3 = {"name": "dave"}`)
+
+	require.Nil(t, queryIndex(nil, "users", "byName", "alice"))
+	require.Equal(t, user("carol"), queryIndex(nil, "users", "byName", "carol"))
+	require.Equal(t, user("bob"), queryIndex(nil, "users", "byName", "bob"))
+	require.Equal(t, user("dave"), queryIndex(nil, "users", "byName", "dave"))
+
+	// Appended slice items are added to the end of their groups
+	applyTx(false, `.chat.value[1:1] = [{"author": "bob", "text": "yo"}, {"author": "alice", "text": "again"}]`)
+
+	require.Equal(t, []interface{}{msg("alice", "hi"), msg("alice", "again")}, queryIndex(nil, "chat", "byAuthor", "alice"))
+	require.Equal(t, []interface{}{msg("bob", "yo")}, queryIndex(nil, "chat", "byAuthor", "bob"))
+
+	// Other splices rebuild the index
+	checkpoint := applyTx(true, `delete .users.u2`, `.chat.value[0:1] = []`)
+
+	require.Nil(t, queryIndex(nil, "users", "byName", "bob"))
+	require.Equal(t, []interface{}{msg("alice", "again")}, queryIndex(nil, "chat", "byAuthor", "alice"))
+
+	// The indices at a checkpoint are kept along with its state
+	applyTx(false, `.users.u4 = {"name": "bob"}`)
+
+	require.Equal(t, user("bob"), queryIndex(nil, "users", "byName", "bob"))
+	require.Nil(t, queryIndex(&checkpoint, "users", "byName", "bob"))
+	require.Equal(t, user("carol"), queryIndex(&checkpoint, "users", "byName", "carol"))
+}
+
+func TestControllerHub_QueryIndex_Sorted(t *testing.T) {
+	signer, err := crypto.GenerateSigningKeypair()
+	require.NoError(t, err)
+
+	stateURI := "foo.com/bar"
+
+	hub, _, chNewState, cleanup := setupControllerHub(t)
+	defer cleanup()
+
+	var parent *types.ID
+	applyTx := func(patches ...string) {
+		t.Helper()
+
+		tx := &redwood.Tx{
+			ID:       redwood.GenesisTxID,
+			StateURI: stateURI,
+			From:     signer.Address(),
+			Patches:  mustParsePatches(t, patches...),
+		}
+		if parent != nil {
+			tx.ID = types.RandomID()
+			tx.Parents = []types.ID{*parent}
+		}
+		sig, err := signer.SignHash(tx.Hash())
+		require.NoError(t, err)
+		tx.Sig = sig
+
+		require.NoError(t, hub.AddTx(tx, false))
+		for {
+			select {
+			case id := <-chNewState:
+				if id == tx.ID {
+					parent = &tx.ID
+					return
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("timed out waiting for tx %v", tx.ID.Pretty())
+			}
+		}
+	}
+
+	queryTexts := func(indexName string, query redwood.SortedIndexQuery) []string {
+		t.Helper()
+
+		node, err := hub.QueryIndex(stateURI, nil, tree.Keypath("chat"), tree.Keypath(indexName), query.Keypath(), nil)
+		require.NoError(t, err)
+
+		val, exists, err := node.Value(nil, nil)
+		require.NoError(t, err)
+		require.True(t, exists)
+
+		texts := []string{}
+		for _, msg := range val.([]interface{}) {
+			texts = append(texts, msg.(map[string]interface{})["text"].(string))
+		}
+		return texts
+	}
+	type Q = redwood.SortedIndexQuery
+	type A = []interface{}
+
+	applyTx(`.chat = {
+		"Indices": {
+			"byTime": {"Content-Type": "indexer/sorted", "fields": [{"keypath": "time", "type": "number"}]},
+			"byRoom": {"Content-Type": "indexer/sorted", "fields": [{"keypath": "room"}, {"keypath": "sent", "type": "timestamp"}]}
+		},
+		"value": [
+			{"time": 30, "room": "b", "sent": "2021-01-01T00:00:03Z", "text": "three"},
+			{"time": 10, "room": "a", "sent": "2021-01-01T00:00:01Z", "text": "one"},
+			{"time": -5, "room": "a", "sent": "2020-12-31T23:59:59Z", "text": "minus five"},
+			{"time": 20, "room": "ab", "sent": "2021-01-01T00:00:02Z", "text": "two"},
+			{"room": "a", "text": "no time"}
+		]
+	}`)
+
+	require.Equal(t, []string{"minus five", "one", "two", "three"}, queryTexts("byTime", Q{}))
+	require.Equal(t, []string{"three", "two", "one", "minus five"}, queryTexts("byTime", Q{Reverse: true}))
+	require.Equal(t, []string{"one", "two"}, queryTexts("byTime", Q{Start: A{0}, End: A{30}}))
+	require.Equal(t, []string{"two", "one"}, queryTexts("byTime", Q{Start: A{0}, End: A{30}, Reverse: true}))
+	require.Equal(t, []string{"three", "two"}, queryTexts("byTime", Q{Limit: 2, Reverse: true}))
+
+	// Compound keys compare field by field, so room "a" doesn't match room "ab"
+	require.Equal(t, []string{"minus five", "one"}, queryTexts("byRoom", Q{Prefix: A{"a"}}))
+	require.Equal(t, []string{"one"}, queryTexts("byRoom", Q{Prefix: A{"a"}, Start: A{"a", "2021-01-01T00:00:00Z"}}))
+	require.Equal(t, []string{"minus five", "one", "two", "three"}, queryTexts("byRoom", Q{}))
+
+	// New messages are added to the index in order
+	applyTx(`.chat.value[5:5] = [{"time": 15, "room": "a", "sent": "2021-01-01T00:00:04Z", "text": "fifteen"}]`)
+
+	require.Equal(t, []string{"one", "fifteen", "two"}, queryTexts("byTime", Q{Start: A{0}, End: A{30}}))
+	require.Equal(t, []string{"minus five", "one", "fifteen"}, queryTexts("byRoom", Q{Prefix: A{"a"}}))
+
+	// Queries whose values don't match the fields' types are rejected
+	_, err = hub.QueryIndex(stateURI, nil, tree.Keypath("chat"), tree.Keypath("byTime"), Q{Start: A{"x"}}.Keypath(), nil)
+	require.Equal(t, redwood.ErrInvalidIndexQuery, errors.Cause(err))
+	_, err = hub.QueryIndex(stateURI, nil, tree.Keypath("chat"), tree.Keypath("byTime"), tree.Keypath("not json"), nil)
+	require.Equal(t, redwood.ErrInvalidIndexQuery, errors.Cause(err))
+}
+
+func TestControllerHub_QueryIndex_Fulltext(t *testing.T) {
+	signer, err := crypto.GenerateSigningKeypair()
+	require.NoError(t, err)
+
+	stateURI := "foo.com/bar"
+
+	hub, _, chNewState, cleanup := setupControllerHub(t)
+	defer cleanup()
+
+	var parent *types.ID
+	applyTx := func(patches ...string) {
+		t.Helper()
+
+		tx := &redwood.Tx{
+			ID:       redwood.GenesisTxID,
+			StateURI: stateURI,
+			From:     signer.Address(),
+			Patches:  mustParsePatches(t, patches...),
+		}
+		if parent != nil {
+			tx.ID = types.RandomID()
+			tx.Parents = []types.ID{*parent}
+		}
+		sig, err := signer.SignHash(tx.Hash())
+		require.NoError(t, err)
+		tx.Sig = sig
+
+		require.NoError(t, hub.AddTx(tx, false))
+		for {
+			select {
+			case id := <-chNewState:
+				if id == tx.ID {
+					parent = &tx.ID
+					return
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("timed out waiting for tx %v", tx.ID.Pretty())
+			}
+		}
+	}
+
+	search := func(keypath, query string) []interface{} {
+		t.Helper()
+
+		node, err := hub.QueryIndex(stateURI, nil, tree.Keypath(keypath), tree.Keypath("search"), tree.Keypath(query), nil)
+		require.NoError(t, err)
+
+		val, exists, err := node.Value(nil, nil)
+		require.NoError(t, err)
+		require.True(t, exists)
+
+		keypaths := []interface{}{}
+		for _, match := range val.([]interface{}) {
+			keypaths = append(keypaths, match.(map[string]interface{})["keypath"])
+		}
+		return keypaths
+	}
+	type A = []interface{}
+
+	applyTx(
+		`.docs = {
+			"Indices": {"search": {"Content-Type": "indexer/fulltext"}},
+			"d1": {"title": "Go concurrency", "body": "Goroutines and channels.  Channels, channels."},
+			"d2": {"title": "Cooking", "body": "Pasta with tomato"},
+			"d3": {"title": "Channels in depth", "body": "Buffered ones"}
+		}`,
+		`.chat = {
+			"Indices": {"search": {"Content-Type": "indexer/fulltext", "keypath": "text"}},
+			"value": [{"author": "alice", "text": "hello world"}, {"author": "bob", "text": "Hello, alice!"}]
+		}`,
+	)
+
+	// Leaves that contain a word more often, or rarer words, rank higher
+	require.Equal(t, A{A{"docs", "d1", "body"}, A{"docs", "d3", "title"}}, search("docs", "CHANNELS"))
+	require.Equal(t, A{A{"docs", "d1", "body"}, A{"docs", "d2", "body"}, A{"docs", "d3", "title"}}, search("docs", "tomato channels"))
+	require.Equal(t, A{}, search("docs", "nothing"))
+
+	// Only the configured keypath of each child is indexed
+	require.Equal(t, A{A{"chat", "value", float64(1), "text"}}, search("chat", "alice"))
+	require.Equal(t, A{A{"chat", "value", float64(0), "text"}, A{"chat", "value", float64(1), "text"}}, search("chat", "hello"))
+
+	// Words are moved and added as the indexed children change
+	applyTx(`.docs.d2.body = "Pasta with channels"`, `.chat.value[2:2] = [{"author": "carol", "text": "hello hello"}]`)
+
+	require.Equal(t, A{}, search("docs", "tomato"))
+	require.Equal(t, A{A{"docs", "d1", "body"}, A{"docs", "d2", "body"}, A{"docs", "d3", "title"}}, search("docs", "channels"))
+	require.Equal(t, A{A{"chat", "value", float64(2), "text"}, A{"chat", "value", float64(0), "text"}, A{"chat", "value", float64(1), "text"}}, search("chat", "hello"))
+}
+
+func TestController_RejectsTxOlderThanParent(t *testing.T) {
+	signer, err := crypto.GenerateSigningKeypair()
+	require.NoError(t, err)
+
+	stateURI := "foo.com/bar"
+
+	hub, _, chNewState, cleanup := setupControllerHub(t)
+	defer cleanup()
+
+	addTx := func(id types.ID, parents []types.ID, timestamp int64) *redwood.Tx {
+		t.Helper()
+		tx := &redwood.Tx{
+			ID:        id,
+			Parents:   parents,
+			StateURI:  stateURI,
+			From:      signer.Address(),
+			Timestamp: timestamp,
+			Patches:   mustParsePatches(t, `.foo = 1`),
+		}
+		sig, err := signer.SignHash(tx.Hash())
+		require.NoError(t, err)
+		tx.Sig = sig
+		require.NoError(t, hub.AddTx(tx, false))
+		return tx
+	}
+
+	genesis := addTx(redwood.GenesisTxID, nil, 1000)
+	select {
+	case <-chNewState:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for genesis tx")
+	}
+
+	backdated := addTx(types.RandomID(), []types.ID{genesis.ID}, 999)
+	untimed := addTx(types.RandomID(), []types.ID{genesis.ID}, 0)
+
+	require.Eventually(t, func() bool {
+		tx, err := hub.FetchTx(stateURI, backdated.ID)
+		return err == nil && tx.Status == redwood.TxStatusInvalid
+	}, 5*time.Second, 50*time.Millisecond)
+	require.Eventually(t, func() bool {
+		tx, err := hub.FetchTx(stateURI, untimed.ID)
+		return err == nil && tx.Status == redwood.TxStatusValid
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestController_RejectsBackdatedTxFromExpiredGrantee(t *testing.T) {
+	owner, err := crypto.GenerateSigningKeypair()
+	require.NoError(t, err)
+	grantee, err := crypto.GenerateSigningKeypair()
+	require.NoError(t, err)
+
+	stateURI := "foo.com/bar"
+	now := time.Now().Unix()
+	expires := now - 3600
+
+	hub, _, chNewState, cleanup := setupControllerHub(t)
+	defer cleanup()
+
+	addTx := func(signer *crypto.SigningKeypair, id types.ID, parents []types.ID, timestamp int64, patch string) *redwood.Tx {
+		t.Helper()
+		tx := &redwood.Tx{
+			ID:        id,
+			Parents:   parents,
+			StateURI:  stateURI,
+			From:      signer.Address(),
+			Timestamp: timestamp,
+			Patches:   mustParsePatches(t, patch),
+		}
+		sig, err := signer.SignHash(tx.Hash())
+		require.NoError(t, err)
+		tx.Sig = sig
+		require.NoError(t, hub.AddTx(tx, false))
+		return tx
+	}
+	requireStatus := func(tx *redwood.Tx, status redwood.TxStatus) {
+		t.Helper()
+		require.Eventually(t, func() bool {
+			tx, err := hub.FetchTx(stateURI, tx.ID)
+			return err == nil && tx.Status == status
+		}, 5*time.Second, 50*time.Millisecond)
+	}
+
+	genesis := addTx(owner, redwood.GenesisTxID, nil, expires-100, fmt.Sprintf(`.Validator = {
+		"Content-Type": "validator/permissions",
+		"value": {
+			"%v": {"^.*$": {"write": true}},
+			"roles": {"editor": {"^\\.posts(\\..*)?$": {"write": true}}},
+			"grants": {"%v": [{"role": "editor", "expires": %v}]}
+		}
+	}`, owner.Address().Hex(), grantee.Address().Hex(), expires))
+	select {
+	case <-chNewState:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for genesis tx")
+	}
+
+	// Until anything newer is applied, a tx from within the grant's window is fine
+	inWindow := addTx(grantee, types.RandomID(), []types.ID{genesis.ID}, expires-50, `.posts.a = 1`)
+	requireStatus(inWindow, redwood.TxStatusValid)
+
+	recent := addTx(owner, types.RandomID(), []types.ID{inWindow.ID}, now, `.foo = 1`)
+	requireStatus(recent, redwood.TxStatusValid)
+
+	// Branching off of old history doesn't let the grantee claim an old timestamp
+	backdated := addTx(grantee, types.RandomID(), []types.ID{inWindow.ID}, expires-10, `.posts.b = 1`)
+	requireStatus(backdated, redwood.TxStatusInvalid)
+}
+
+func TestController_ValidateReadSubtree(t *testing.T) {
+	owner, err := crypto.GenerateSigningKeypair()
+	require.NoError(t, err)
+	anon := testutils.RandomAddress(t)
+
+	stateURI := "foo.com/bar"
+
+	hub, _, chNewState, cleanup := setupControllerHub(t)
+	defer cleanup()
+
+	tx := &redwood.Tx{
+		ID:       redwood.GenesisTxID,
+		StateURI: stateURI,
+		From:     owner.Address(),
+		Patches: mustParsePatches(t,
+			fmt.Sprintf(`.Validator = {
+				"Content-Type": "validator/permissions",
+				"value": {
+					"*": {"^\\.(public(\\..*)?)?$": {"read": true}},
+					"%v": {"^.*$": {"read": true, "write": true}}
+				}
+			}`, owner.Address().Hex()),
+			`.public = {"a": 1}`,
+			`.secret = {"b": 2}`,
+		),
+	}
+	tx.Sig, err = owner.SignHash(tx.Hash())
+	require.NoError(t, err)
+	require.NoError(t, hub.AddTx(tx, false))
+	select {
+	case <-chNewState:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for genesis tx")
+	}
+
+	// Anyone may read the root node itself, but not everything beneath it
+	require.NoError(t, hub.ValidateRead(stateURI, nil, []types.Address{anon}))
+	err = hub.ValidateReadSubtree(stateURI, nil, []types.Address{anon})
+	require.Equal(t, types.Err403, errors.Cause(err))
+
+	require.NoError(t, hub.ValidateReadSubtree(stateURI, tree.Keypath("public"), []types.Address{anon}))
+	require.NoError(t, hub.ValidateReadSubtree(stateURI, nil, []types.Address{owner.Address()}))
+}
@@ -0,0 +1,24 @@
+package redwood
+
+// Internals that the tests in package redwood_test need.
+
+const (
+	WebRTCChunkSize        = webrtcChunkSize
+	TrustedRPCRefChunkSize = trustedRPCRefChunkSize
+)
+
+func PeerStoreOf(h Host) PeerStore {
+	return h.(*host).peerStore
+}
+
+func RefStoreOf(h Host) RefStore {
+	return h.(*host).refStore
+}
+
+func WebSocketOwnURL(h Host) string {
+	return h.Transport("websocket").(*websocketTransport).ownURL
+}
+
+func WebRTCOwnURL(h Host) string {
+	return h.Transport("webrtc").(*webrtcTransport).ownURL
+}
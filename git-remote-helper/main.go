@@ -458,10 +458,10 @@ func push(srcRefName string, destRefName string, client *redwood.HTTPClient) err
 		}
 	}
 
-	return pushRef(destRefName, headCommitId, client)
+	return pushRef(destRefName, headCommitId, force, client)
 }
 
-func pushRef(destRefName string, commitId *git.Oid, client *redwood.HTTPClient) error {
+func pushRef(destRefName string, commitId *git.Oid, force bool, client *redwood.HTTPClient) error {
 	branchKeypath := RootKeypath.Push(tree.Keypath(destRefName))
 
 	parentID, err := types.IDFromHex(commitId.String())
@@ -489,6 +489,12 @@ func pushRef(destRefName string, commitId *git.Oid, client *redwood.HTTPClient)
 			},
 		}},
 	}
+	if force {
+		tx.Patches = append(tx.Patches, redwood.Patch{
+			Keypath: branchKeypath.Push(tree.Keypath("force")),
+			Val:     true,
+		})
+	}
 
 	return client.Put(context.Background(), tx, types.Address{}, nil)
 }
@@ -688,6 +694,7 @@ func pushCommit(commitId *git.Oid, destRefName string, client *redwood.HTTPClien
 				"Content-Type": "link",
 				"value":        "ref:sha1:" + uploaded.hash.Hex()[:40],
 				"mode":         int(uploaded.mode),
+				"oid":          uploaded.gitOid,
 			}, true)
 		}
 		tx.Patches = append(tx.Patches, redwood.Patch{
@@ -28,19 +28,27 @@ type Host interface {
 	AddRef(reader io.ReadCloser) (types.Hash, types.Hash, error)
 	FetchRef(ctx context.Context, ref types.RefID)
 	AddPeer(dialInfo PeerDialInfo)
+	RemovePeers(dialInfos []PeerDialInfo)
+	BanPeers(dialInfos []PeerDialInfo)
 	Transport(name string) Transport
 	Controllers() ControllerHub
 	ChallengePeerIdentity(ctx context.Context, peer Peer) error
 
 	Identities() ([]identity.Identity, error)
 	NewIdentity(public bool) (identity.Identity, error)
+	SetHDMnemonic(mnemonic string) error
 
 	Peers() []PeerDetails
 	ProvidersOfStateURI(ctx context.Context, stateURI string) <-chan Peer
 	ProvidersOfRef(ctx context.Context, refID types.RefID) <-chan Peer
 	PeersClaimingAddress(ctx context.Context, address types.Address) <-chan Peer
 
+	BootstrapFromSnapshot(ctx context.Context, stateURI string, peer Peer) error
+	ReconcileWithPeer(ctx context.Context, stateURI string, peer Peer) error
+
 	HandleFetchHistoryRequest(stateURI string, opts FetchHistoryOpts, writeSub WritableSubscription) error
+	HandleFetchSnapshotRequest(stateURI string, peer Peer) (*Snapshot, error)
+	HandleReconcileRequest(req ReconcileRequest, peer Peer) (ReconcileResponse, error)
 	HandleWritableSubscriptionOpened(writeSub WritableSubscription, fetchHistoryOpts *FetchHistoryOpts)
 	HandleWritableSubscriptionClosed(writeSub WritableSubscription)
 	HandleReadableSubscriptionClosed(stateURI string)
@@ -65,6 +73,7 @@ type host struct {
 	peerSeenTxsMu           sync.RWMutex
 
 	processPeersTask *utils.PeriodicTask
+	pruneHistoryTask *utils.PeriodicTask
 
 	controllerHub ControllerHub
 	transports    map[string]Transport
@@ -72,7 +81,13 @@ type host struct {
 	refStore      RefStore
 	keyStore      identity.KeyStore
 
-	chRefsNeeded chan []types.RefID
+	chRefsNeeded    chan []types.RefID
+	chParentsNeeded chan parentsNeeded
+
+	// Missing parent txs that we've recently asked for, so that we don't ask
+	// for the same ones over and over while a fetch is in flight
+	parentsRequested   map[types.ID]time.Time
+	parentsRequestedMu sync.Mutex
 }
 
 var (
@@ -106,6 +121,8 @@ func NewHost(
 		refStore:              refStore,
 		keyStore:              keyStore,
 		chRefsNeeded:          make(chan []types.RefID, 100),
+		chParentsNeeded:       make(chan parentsNeeded, 100),
+		parentsRequested:      make(map[types.ID]time.Time),
 		config:                config,
 	}
 	return h, nil
@@ -138,6 +155,9 @@ func (h *host) Start() error {
 	}
 
 	go h.periodicallyFetchMissingRefs()
+	go h.periodicallyFetchMissingParents()
+
+	h.pruneHistoryTask = utils.NewPeriodicTask(pruneHistoryInterval, h.pruneHistory)
 
 	return nil
 }
@@ -146,6 +166,9 @@ func (h *host) Close() {
 	close(h.chStop)
 
 	h.processPeersTask.Close()
+	if h.pruneHistoryTask != nil {
+		h.pruneHistoryTask.Close()
+	}
 
 	var writableSubs []WritableSubscription
 	func() {
@@ -206,6 +229,10 @@ func (h *host) NewIdentity(public bool) (identity.Identity, error) {
 	return h.keyStore.NewIdentity(public)
 }
 
+func (h *host) SetHDMnemonic(mnemonic string) error {
+	return h.keyStore.SetHDMnemonic(mnemonic)
+}
+
 func (h *host) StateAtVersion(stateURI string, version *types.ID) (tree.Node, error) {
 	return h.Controllers().StateAtVersion(stateURI, version)
 }
@@ -222,9 +249,11 @@ func (h *host) ProvidersOfStateURI(ctx context.Context, stateURI string) <-chan
 
 	ctx, cancel := utils.CombinedContext(ctx, h.chStop)
 
+	// The wait group isn't tied to ctx, so that `ch` is only closed once every
+	// goroutine that might send on it has returned
 	var (
 		ch          = make(chan Peer)
-		wg          = utils.NewWaitGroupChan(ctx)
+		wg          = utils.NewWaitGroupChan(nil)
 		alreadySent sync.Map
 	)
 
@@ -393,7 +422,22 @@ func (h *host) PeersClaimingAddress(ctx context.Context, address types.Address)
 }
 
 func (h *host) HandleTxReceived(tx Tx, peer Peer) {
+	if h.peerStore.IsBanned(peer.DialInfo()) {
+		h.Warnf("ignoring tx %v from banned peer %v", tx.ID.Pretty(), peer.DialInfo())
+		return
+	}
 	h.Infof(0, "tx received: tx=%v peer=%v", tx.ID.Pretty(), peer.DialInfo())
+	h.addReceivedTx(tx, peer)
+
+	err := peer.Ack(tx.StateURI, tx.ID)
+	if err != nil {
+		h.Errorf("error ACKing peer: %v", err)
+	}
+}
+
+// addReceivedTx adds a tx that a peer sent us to the controller hub (if it's
+// new), and requests any of its parents that we're missing from that peer.
+func (h *host) addReceivedTx(tx Tx, peer Peer) {
 	h.markTxSeenByPeer(peer, tx.StateURI, tx.ID)
 
 	have, err := h.controllerHub.HaveTx(tx.StateURI, tx.ID)
@@ -408,11 +452,21 @@ func (h *host) HandleTxReceived(tx Tx, peer Peer) {
 		if err != nil {
 			h.Errorf("error adding tx to controllerHub: %v", err)
 		}
-	}
 
-	err = peer.Ack(tx.StateURI, tx.ID)
-	if err != nil {
-		h.Errorf("error ACKing peer: %v", err)
+		// If we don't have the tx's parents yet, ask the peer that sent it
+		var missingParents []types.ID
+		for _, parentID := range tx.Parents {
+			have, err := h.controllerHub.HaveTx(tx.StateURI, parentID)
+			if err != nil {
+				h.Errorf("error fetching tx %v from store: %v", parentID.Pretty(), err)
+				continue
+			} else if !have {
+				missingParents = append(missingParents, parentID)
+			}
+		}
+		if len(missingParents) > 0 {
+			h.handleParentsNeeded(tx.StateURI, missingParents, peer)
+		}
 	}
 }
 
@@ -461,6 +515,14 @@ func (h *host) AddPeer(dialInfo PeerDialInfo) {
 	h.processPeersTask.Enqueue()
 }
 
+func (h *host) RemovePeers(dialInfos []PeerDialInfo) {
+	h.peerStore.RemovePeers(dialInfos)
+}
+
+func (h *host) BanPeers(dialInfos []PeerDialInfo) {
+	h.peerStore.BanPeers(dialInfos)
+}
+
 func (h *host) handleNewUnverifiedPeer(dialInfo PeerDialInfo) {
 	h.processPeersTask.Enqueue()
 }
@@ -557,14 +619,72 @@ func (h *host) processPeers(ctx context.Context) {
 	<-wg.Wait()
 }
 
+// FetchHistoryOpts determines which txs are sent in response to a history
+// request.  Unless TxIDs is set, txs are always sent in topological order
+// (every tx after all of its parents).
 type FetchHistoryOpts struct {
-	FromTxID types.ID
-	ToTxID   types.ID
+	// FromTxID is the oldest tx to send.  Only it and its descendants are sent.
+	// Defaults to the genesis tx.
+	FromTxID types.ID `json:"fromTxID,omitempty"`
+	// ToTxID, if set, is the newest tx to send.  Only it and its ancestors are
+	// sent.
+	ToTxID types.ID `json:"toTxID,omitempty"`
+	// If SinceLeaves is set, FromTxID and ToTxID are ignored, and only txs that
+	// aren't ancestors of (or equal to) the given leaves are sent.  This lets a
+	// peer request exactly the txs that it's missing.
+	SinceLeaves []types.ID `json:"sinceLeaves,omitempty"`
+	// Limit, if nonzero, is the maximum number of txs to send.
+	Limit uint64 `json:"limit,omitempty"`
+	// If TxIDs is set, only those txs are sent (in the given order), and the
+	// other fields are ignored.
+	TxIDs []types.ID `json:"txIDs,omitempty"`
 }
 
 func (h *host) HandleFetchHistoryRequest(stateURI string, opts FetchHistoryOpts, writeSub WritableSubscription) error {
-	// @@TODO: respect the `opts.ToTxID` param
-	// @@TODO: if .FromTxID == 0, set it to GenesisTxID
+	sendTx, err := h.historicalTxSender(stateURI, writeSub)
+	if err != nil {
+		return err
+	}
+
+	if len(opts.TxIDs) > 0 {
+		for _, txID := range opts.TxIDs {
+			tx, err := h.controllerHub.FetchTx(stateURI, txID)
+			if errors.Cause(err) == types.Err404 {
+				continue
+			} else if err != nil {
+				return err
+			}
+
+			_, err = sendTx(tx)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var numSent uint64
+	send := func(tx *Tx) (done bool, _ error) {
+		sent, err := sendTx(tx)
+		if err != nil {
+			return true, err
+		} else if sent {
+			numSent++
+		}
+		return opts.Limit > 0 && numSent >= opts.Limit, nil
+	}
+
+	if len(opts.SinceLeaves) > 0 {
+		return h.forEachTxSinceLeaves(stateURI, opts.SinceLeaves, send)
+	}
+
+	var wanted map[types.ID]struct{}
+	if opts.ToTxID != (types.ID{}) {
+		wanted, err = h.ancestorsOf(stateURI, []types.ID{opts.ToTxID})
+		if err != nil {
+			return err
+		}
+	}
 
 	iter := h.controllerHub.FetchTxs(stateURI, opts.FromTxID)
 	defer iter.Cancel()
@@ -577,45 +697,235 @@ func (h *host) HandleFetchHistoryRequest(stateURI string, opts FetchHistoryOpts,
 			return nil
 		}
 
-		leaves, err := h.controllerHub.Leaves(stateURI)
-		if err != nil {
-			return err
+		if wanted != nil {
+			if _, isWanted := wanted[tx.ID]; !isWanted {
+				continue
+			}
 		}
 
-		isPrivate, err := h.controllerHub.IsPrivate(tx.StateURI)
-		if err != nil {
+		done, err := send(tx)
+		if err != nil || done {
 			return err
+		} else if tx.ID == opts.ToTxID {
+			// Everything else in the iterator comes after ToTxID
+			return nil
 		}
+	}
+}
 
-		if isPrivate {
-			var isAllowed bool
-			if peer, isPeer := writeSub.(Peer); isPeer {
-				for _, addr := range peer.Addresses() {
-					isAllowed, err = h.controllerHub.IsMember(tx.StateURI, addr)
-					if err != nil {
-						h.Errorf("error determining if peer '%v' is a member of private state URI '%v': %v", addr, tx.StateURI, err)
-						return err
-					}
-					if isAllowed {
-						break
-					}
+// historicalTxSender returns a function that writes a tx to a subscriber that
+// has requested history, provided that the subscriber is allowed to see it.
+// The checks that apply to the whole state URI are only made once.
+func (h *host) historicalTxSender(stateURI string, writeSub WritableSubscription) (func(tx *Tx) (bool, error), error) {
+	leaves, err := h.controllerHub.Leaves(stateURI)
+	if err != nil {
+		return nil, err
+	}
+
+	isPrivate, err := h.controllerHub.IsPrivate(stateURI)
+	if err != nil {
+		return nil, err
+	}
+
+	if isPrivate {
+		var isAllowed bool
+		if peer, isPeer := subscriberPeer(writeSub); isPeer {
+			for _, addr := range peer.Addresses() {
+				isAllowed, err = h.controllerHub.IsMember(stateURI, addr)
+				if err != nil {
+					h.Errorf("error determining if peer '%v' is a member of private state URI '%v': %v", addr, stateURI, err)
+					return nil, err
+				}
+				if isAllowed {
+					break
 				}
-			} else {
-				// In-process subscriptions are trusted
-				isAllowed = true
 			}
+		} else {
+			// In-process subscriptions are trusted
+			isAllowed = true
+		}
 
-			if isAllowed {
-				writeSub.EnqueueWrite(tx, nil, leaves)
-			}
+		if !isAllowed {
+			return func(tx *Tx) (bool, error) { return false, nil }, nil
+		}
+	}
 
-		} else {
-			writeSub.EnqueueWrite(tx, nil, leaves)
+	return func(tx *Tx) (bool, error) {
+		isReadable, err := h.txIsReadableBy(writeSub, tx)
+		if err != nil {
+			return false, err
+		} else if !isReadable {
+			return false, nil
+		}
+		writeSub.EnqueueWrite(tx, nil, leaves)
+		return true, nil
+	}, nil
+}
+
+// ancestorsOf returns the given txs and all of their ancestors that are in the
+// tx store.  Unknown txs are ignored.
+func (h *host) ancestorsOf(stateURI string, txIDs []types.ID) (map[types.ID]struct{}, error) {
+	ancestors := make(map[types.ID]struct{})
+	stack := append([]types.ID(nil), txIDs...)
+	for len(stack) > 0 {
+		txID := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if _, seen := ancestors[txID]; seen {
+			continue
+		}
+
+		tx, err := h.controllerHub.FetchTx(stateURI, txID)
+		if errors.Cause(err) == types.Err404 {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		ancestors[txID] = struct{}{}
+		stack = append(stack, tx.Parents...)
+	}
+	return ancestors, nil
+}
+
+// forEachTxSinceLeaves calls fn with each tx in our history that a peer with
+// the given leaves doesn't have, in topological order, until fn returns true.
+// Only the IDs of the missing txs are held in memory, so a caller that stops
+// early never loads the rest of them.
+func (h *host) forEachTxSinceLeaves(stateURI string, theirLeaves []types.ID, fn func(tx *Tx) (done bool, _ error)) error {
+	theirs, err := h.ancestorsOf(stateURI, theirLeaves)
+	if err != nil {
+		return err
+	}
+
+	ourLeaves, err := h.controllerHub.Leaves(stateURI)
+	if err != nil {
+		return err
+	}
+
+	// Walk back from our leaves until we reach history that they already have
+	var txIDs []types.ID
+	parents := make(map[types.ID][]types.ID)
+	stack := append([]types.ID(nil), ourLeaves...)
+	for len(stack) > 0 {
+		txID := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if _, isSeen := parents[txID]; isSeen {
+			continue
+		} else if _, isTheirs := theirs[txID]; isTheirs {
+			continue
+		}
+
+		tx, err := h.controllerHub.FetchTx(stateURI, txID)
+		if errors.Cause(err) == types.Err404 {
+			continue
+		} else if err != nil {
+			return err
+		}
+		txIDs = append(txIDs, txID)
+		parents[txID] = tx.Parents
+		stack = append(stack, tx.Parents...)
+	}
+
+	for _, txID := range sortTopologically(txIDs, parents) {
+		tx, err := h.controllerHub.FetchTx(stateURI, txID)
+		if errors.Cause(err) == types.Err404 {
+			// Pruned while we were walking
+			continue
+		} else if err != nil {
+			return err
+		}
+		done, err := fn(tx)
+		if err != nil || done {
+			return err
 		}
 	}
 	return nil
 }
 
+// sortTopologically orders txIDs so that each comes after any of its parents
+// that are also in the slice.
+func sortTopologically(txIDs []types.ID, parents map[types.ID][]types.ID) []types.ID {
+	sorted := make([]types.ID, 0, len(txIDs))
+	visited := make(map[types.ID]bool, len(txIDs))
+	var visit func(txID types.ID)
+	visit = func(txID types.ID) {
+		if visited[txID] {
+			return
+		}
+		visited[txID] = true
+		for _, parentID := range parents[txID] {
+			if _, exists := parents[parentID]; exists {
+				visit(parentID)
+			}
+		}
+		sorted = append(sorted, txID)
+	}
+
+	for _, txID := range txIDs {
+		visit(txID)
+	}
+	return sorted
+}
+
+// txIsReadableBy returns true if the subscriber may read every keypath that the
+// tx touches.  In-process subscriptions are trusted.
+func (h *host) txIsReadableBy(writeSub WritableSubscription, tx *Tx) (bool, error) {
+	peer, isPeer := subscriberPeer(writeSub)
+	if !isPeer {
+		return true, nil
+	}
+	return h.txIsReadableByPeer(peer, tx)
+}
+
+// txIsReadableByPeer returns true if the peer may read every keypath that the
+// tx touches.
+func (h *host) txIsReadableByPeer(peer Peer, tx *Tx) (bool, error) {
+	for _, patch := range tx.Patches {
+		err := h.controllerHub.ValidateRead(tx.StateURI, patch.Keypath, peer.Addresses())
+		if errors.Cause(err) == types.Err403 {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// peerIsMember returns true if the state URI is public, or if one of the peer's
+// verified addresses is a member of it.
+func (h *host) peerIsMember(stateURI string, peer Peer) (bool, error) {
+	isPrivate, err := h.controllerHub.IsPrivate(stateURI)
+	if err != nil {
+		return false, err
+	} else if !isPrivate {
+		return true, nil
+	}
+	for _, addr := range peer.Addresses() {
+		isMember, err := h.controllerHub.IsMember(stateURI, addr)
+		if err != nil {
+			return false, err
+		} else if isMember {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// redactUnreadableFor removes anything the subscriber may not read from node (an
+// in-memory copy of the state at keypath).  In-process subscriptions are trusted.
+func (h *host) redactUnreadableFor(writeSub WritableSubscription, node tree.Node, keypath tree.Keypath) error {
+	peer, isPeer := subscriberPeer(writeSub)
+	if !isPeer {
+		return nil
+	}
+	err := h.controllerHub.ValidateRead(writeSub.StateURI(), keypath, peer.Addresses())
+	if err != nil {
+		return err
+	}
+	return h.controllerHub.RedactUnreadable(writeSub.StateURI(), node, keypath, peer.Addresses())
+}
+
 func (h *host) HandleWritableSubscriptionOpened(writeSub WritableSubscription, fetchHistoryOpts *FetchHistoryOpts) {
 	if writeSub.Type().Includes(SubscriptionType_Txs) && fetchHistoryOpts != nil {
 		h.HandleFetchHistoryRequest(writeSub.StateURI(), *fetchHistoryOpts, writeSub)
@@ -647,6 +957,15 @@ func (h *host) HandleWritableSubscriptionOpened(writeSub WritableSubscription, f
 				h.Errorf("error writing initial state to peer: %v", err)
 				return
 			}
+
+			err = h.redactUnreadableFor(writeSub, node, keypath)
+			if errors.Cause(err) == types.Err403 {
+				h.Warnf("peer may not read %v %v", writeSub.StateURI(), keypath)
+				return
+			} else if err != nil {
+				h.Errorf("error writing initial state to peer: %v", err)
+				return
+			}
 			writeSub.EnqueueWrite(nil, node, leaves)
 		}
 	}
@@ -926,7 +1245,7 @@ func (h *host) broadcastToWritableSubscribers(
 	defer h.writableSubscriptionsMu.RUnlock()
 
 	for writeSub := range h.writableSubscriptions[tx.StateURI] {
-		if peer, isPeer := writeSub.(Peer); isPeer {
+		if peer, isPeer := subscriberPeer(writeSub); isPeer {
 			// If the subscriber wants us to send states, we never skip sending
 			if h.txSeenByPeer(peer, tx.StateURI, tx.ID) && !writeSub.Type().Includes(SubscriptionType_States) {
 				continue
@@ -949,16 +1268,9 @@ func (h *host) broadcastToWritableSubscribers(
 				return
 			}
 
-			// Drill down to the part of the state that the subscriber is interested in
-			keypath := writeSub.Keypath()
-			if keypath.Equals(tree.KeypathSeparator) {
-				keypath = nil
-			}
-			state = state.NodeAt(keypath, nil)
-
 			if isPrivate {
 				var isAllowed bool
-				if peer, isPeer := writeSub.(Peer); isPeer {
+				if peer, isPeer := subscriberPeer(writeSub); isPeer {
 					for _, addr := range peer.Addresses() {
 						isAllowed, err = h.controllerHub.IsMember(tx.StateURI, addr)
 						if err != nil {
@@ -974,13 +1286,42 @@ func (h *host) broadcastToWritableSubscribers(
 					isAllowed = true
 				}
 
-				if isAllowed {
-					writeSub.EnqueueWrite(tx, state, leaves)
+				if !isAllowed {
+					return
 				}
+			}
 
-			} else {
-				writeSub.EnqueueWrite(tx, state, leaves)
+			isReadable, err := h.txIsReadableBy(writeSub, tx)
+			if err != nil {
+				h.Errorf("error determining if tx %v is readable by subscriber: %v", tx.ID.Pretty(), err)
+				return
+			} else if !isReadable {
+				return
+			}
+
+			// Drill down to the part of the state that the subscriber is interested in
+			keypath := writeSub.Keypath()
+			if keypath.Equals(tree.KeypathSeparator) {
+				keypath = nil
+			}
+			subState := state.NodeAt(keypath, nil)
+
+			// Peers only receive the parts of the state that they may read
+			if _, isPeer := subscriberPeer(writeSub); isPeer && writeSub.Type().Includes(SubscriptionType_States) {
+				subState, err = state.CopyToMemory(keypath, nil)
+				if err != nil {
+					h.Errorf("error copying state for subscriber: %v", err)
+					return
+				}
+				err = h.redactUnreadableFor(writeSub, subState, keypath)
+				if errors.Cause(err) == types.Err403 {
+					return
+				} else if err != nil {
+					h.Errorf("error redacting state for subscriber: %v", err)
+					return
+				}
 			}
+			writeSub.EnqueueWrite(tx, subState, leaves)
 		}()
 	}
 }
@@ -1023,6 +1364,18 @@ func (h *host) SendTx(ctx context.Context, tx Tx) (err error) {
 		tx.Parents = parents
 	}
 
+	// A tx can't be older than its parents, so if our clock is behind one of
+	// theirs, we use their timestamp instead
+	if tx.Timestamp == 0 && len(tx.Sig) == 0 {
+		tx.Timestamp = time.Now().Unix()
+		for _, parentID := range tx.Parents {
+			parent, err := h.controllerHub.FetchTx(tx.StateURI, parentID)
+			if err == nil && parent.Timestamp > tx.Timestamp {
+				tx.Timestamp = parent.Timestamp
+			}
+		}
+	}
+
 	if len(tx.Sig) == 0 {
 		tx.Sig, err = h.keyStore.SignHash(tx.From, tx.Hash())
 		if err != nil {
@@ -1037,6 +1390,310 @@ func (h *host) SendTx(ctx context.Context, tx Tx) (err error) {
 	return nil
 }
 
+type parentsNeeded struct {
+	stateURI string
+	txIDs    []types.ID
+	peer     Peer
+}
+
+const (
+	fetchParentsTimeout   = 10 * time.Second // @@TODO: make configurable
+	fetchParentsRetryTime = 30 * time.Second
+	bootstrapTimeout      = 30 * time.Second
+	pruneHistoryInterval  = 1 * time.Hour
+)
+
+func (h *host) handleParentsNeeded(stateURI string, txIDs []types.ID, peer Peer) {
+	select {
+	case <-h.chStop:
+	case h.chParentsNeeded <- parentsNeeded{stateURI, txIDs, peer}:
+	default:
+		// The periodic check will pick these up
+		h.Warnf("dropping request for missing parents of %v (queue full)", stateURI)
+	}
+}
+
+func (h *host) periodicallyFetchMissingParents() {
+	tick := time.NewTicker(fetchParentsRetryTime)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-h.chStop:
+			return
+
+		case needed := <-h.chParentsNeeded:
+			h.fetchMissingParents(needed.stateURI, needed.txIDs, needed.peer)
+
+		case <-tick.C:
+			stateURIs, err := h.controllerHub.KnownStateURIs()
+			if err != nil {
+				h.Errorf("error fetching list of known state URIs: %v", err)
+				continue
+			}
+
+			for _, stateURI := range stateURIs {
+				stats, err := h.controllerHub.MempoolStats(stateURI)
+				if errors.Cause(err) == ErrNoController {
+					continue
+				} else if err != nil {
+					h.Errorf("error fetching mempool stats for %v: %v", stateURI, err)
+					continue
+				}
+
+				var txIDs []types.ID
+				for txID := range stats.MissingParents {
+					txIDs = append(txIDs, txID)
+				}
+				if len(txIDs) > 0 {
+					h.fetchMissingParents(stateURI, txIDs, nil)
+				}
+			}
+		}
+	}
+}
+
+// fetchMissingParents requests the given txs from the peer that sent us their
+// children (if any), and then from any other providers of the state URI.  The
+// received txs are handled like any other incoming tx, so if their own parents
+// are missing, those are requested in turn, walking back until we reach history
+// that we already have.
+func (h *host) fetchMissingParents(stateURI string, txIDs []types.ID, fromPeer Peer) {
+	ctx, cancel := utils.CombinedContext(h.chStop, fetchParentsTimeout)
+	defer cancel()
+
+	wanted := make(map[types.ID]struct{})
+	func() {
+		h.parentsRequestedMu.Lock()
+		defer h.parentsRequestedMu.Unlock()
+
+		now := time.Now()
+		for txID, requestedAt := range h.parentsRequested {
+			if now.Sub(requestedAt) > fetchParentsRetryTime {
+				delete(h.parentsRequested, txID)
+			}
+		}
+		for _, txID := range txIDs {
+			if _, requested := h.parentsRequested[txID]; !requested {
+				wanted[txID] = struct{}{}
+				h.parentsRequested[txID] = now
+			}
+		}
+	}()
+	if len(wanted) == 0 {
+		return
+	}
+
+	if fromPeer != nil {
+		h.fetchTxsFromPeer(ctx, stateURI, wanted, fromPeer)
+		if len(wanted) == 0 {
+			return
+		}
+	}
+
+	for peer := range h.ProvidersOfStateURI(ctx, stateURI) {
+		h.fetchTxsFromPeer(ctx, stateURI, wanted, peer)
+		if len(wanted) == 0 {
+			return
+		}
+	}
+}
+
+// fetchTxsFromPeer requests the wanted txs from a single peer and removes the
+// ones it receives from the set.
+func (h *host) fetchTxsFromPeer(ctx context.Context, stateURI string, wanted map[types.ID]struct{}, peer Peer) {
+	err := peer.EnsureConnected(ctx)
+	if err != nil {
+		h.Errorf("error connecting to peer: %v", err)
+		return
+	}
+
+	txIDs := make([]types.ID, 0, len(wanted))
+	for txID := range wanted {
+		txIDs = append(txIDs, txID)
+	}
+
+	sub, err := peer.FetchHistory(ctx, stateURI, FetchHistoryOpts{TxIDs: txIDs})
+	if err != nil {
+		h.Errorf("error fetching txs from peer: %v", err)
+		return
+	}
+
+	// Read() blocks, so closing the subscription is the only way to give up on it
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		sub.Close()
+	}()
+
+	for len(wanted) > 0 {
+		msg, err := sub.Read()
+		if err != nil {
+			if ctx.Err() == nil {
+				h.Errorf("error reading txs from peer: %v", err)
+			}
+			return
+		} else if msg.Tx == nil {
+			continue
+		}
+
+		// Only accept the txs we asked for, so that a peer can't use this path
+		// to push arbitrary txs at us
+		if _, isWanted := wanted[msg.Tx.ID]; !isWanted || msg.Tx.StateURI != stateURI {
+			h.Warnf("ignoring unrequested tx %v (%v) from peer %v", msg.Tx.ID.Pretty(), msg.Tx.StateURI, peer.DialInfo())
+			continue
+		}
+
+		delete(wanted, msg.Tx.ID)
+		h.HandleTxReceived(*msg.Tx, peer)
+	}
+}
+
+// pruneHistory applies the configured retention policy to every known state URI.
+func (h *host) pruneHistory(ctx context.Context) {
+	stateURIs, err := h.controllerHub.KnownStateURIs()
+	if err != nil {
+		h.Errorf("error fetching list of known state URIs: %v", err)
+		return
+	}
+
+	policies := make(map[string]RetentionPolicy, len(stateURIs))
+	h.config.Read(func() {
+		for _, stateURI := range stateURIs {
+			policies[stateURI] = h.config.Node.RetentionPolicy(stateURI)
+		}
+	})
+
+	for _, stateURI := range stateURIs {
+		select {
+		case <-ctx.Done():
+			return
+		case <-h.chStop:
+			return
+		default:
+		}
+
+		policy := policies[stateURI]
+		if policy.IsZero() {
+			continue
+		}
+
+		stats, err := h.controllerHub.PruneHistory(stateURI, policy)
+		if errors.Cause(err) == ErrNoController {
+			continue
+		} else if err != nil {
+			h.Errorf("error pruning history of %v: %v", stateURI, err)
+			continue
+		}
+		if stats != (PruneStats{}) {
+			h.Successf("pruned %v (txs: %v, checkpoints: %v, refs: %v)", stateURI, stats.NumTxs, stats.NumCheckpoints, stats.NumRefs)
+		}
+	}
+}
+
+// HandleFetchSnapshotRequest exports and signs a snapshot of the most recent
+// checkpoint of the given state URI for a peer that wants to bootstrap from it.
+func (h *host) HandleFetchSnapshotRequest(stateURI string, peer Peer) (*Snapshot, error) {
+	isMember, err := h.peerIsMember(stateURI, peer)
+	if err != nil {
+		return nil, err
+	} else if !isMember {
+		return nil, errors.WithStack(types.Err403)
+	}
+
+	snapshot, err := h.controllerHub.ExportSnapshot(stateURI, nil, peer.Addresses())
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot.Leaves, err = h.controllerHub.Leaves(stateURI)
+	if err != nil {
+		return nil, err
+	}
+
+	publicIdentities, err := h.keyStore.PublicIdentities()
+	if err != nil {
+		return nil, err
+	} else if len(publicIdentities) == 0 {
+		return nil, errors.New("keystore has no public identities")
+	}
+	snapshot.From = publicIdentities[0].Address()
+
+	snapshot.Sig, err = h.keyStore.SignHash(snapshot.From, snapshot.Hash())
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// BootstrapFromSnapshot brings a state URI that we have no history for up to
+// date by importing the peer's most recent snapshot, and then fetching the txs
+// that came after it.  The snapshot must be signed by one of the peer's
+// verified addresses.  If we already have history, it does nothing.
+func (h *host) BootstrapFromSnapshot(ctx context.Context, stateURI string, peer Peer) error {
+	leaves, err := h.controllerHub.Leaves(stateURI)
+	if err != nil {
+		return err
+	} else if len(leaves) > 0 {
+		return nil
+	}
+
+	err = peer.EnsureConnected(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(peer.Addresses()) == 0 {
+		err = h.ChallengePeerIdentity(ctx, peer)
+		if err != nil {
+			return err
+		}
+	}
+
+	snapshot, err := peer.FetchSnapshot(ctx, stateURI)
+	if err != nil {
+		return err
+	} else if snapshot.StateURI != stateURI {
+		return errors.Wrapf(ErrInvalidSnapshot, "wrong state URI (%v)", snapshot.StateURI)
+	}
+
+	var signedByPeer bool
+	for _, addr := range peer.Addresses() {
+		if addr == snapshot.From {
+			signedByPeer = true
+			break
+		}
+	}
+	if !signedByPeer {
+		return errors.Wrapf(ErrInvalidSnapshot, "not signed by peer (signer: %v)", snapshot.From.Hex())
+	}
+
+	err = h.controllerHub.ImportSnapshot(snapshot)
+	if errors.Cause(err) == ErrSnapshotUnnecessary {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// Fetch the peer's current leaves.  Their missing ancestors are fetched in
+	// turn until we reach the snapshot's frontier.
+	haveTxs := make(map[types.ID]struct{}, len(snapshot.Frontier))
+	for _, tx := range snapshot.Frontier {
+		haveTxs[tx.ID] = struct{}{}
+	}
+	var txIDs []types.ID
+	for _, txID := range snapshot.Leaves {
+		if _, have := haveTxs[txID]; !have {
+			txIDs = append(txIDs, txID)
+		}
+	}
+	if len(txIDs) > 0 {
+		h.fetchMissingParents(stateURI, txIDs, peer)
+	}
+	return nil
+}
+
 func (h *host) AddRef(reader io.ReadCloser) (types.Hash, types.Hash, error) {
 	return h.refStore.StoreObject(reader)
 }
@@ -0,0 +1,307 @@
+package redwood
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"redwood.dev/types"
+)
+
+// Peers that have been out of touch reconcile their histories of a state URI
+// in three steps.  The txs that two nodes share are always the ancestors of
+// some set of txs that they both have, so once the requester has found the
+// most recent of those, the responder can work out exactly which txs the
+// requester is missing:
+//
+//  1. The requester sends its leaves as a `Probe`, and the responder replies
+//     with the ones that it has (`Known`).  The requester keeps walking back
+//     from the txs that the responder doesn't have, probing a few more
+//     generations each round, until it reaches history that they share.
+//  2. The requester sends the shared txs that it found as its `Leaves`.  The
+//     responder replies with its own leaves and the txs that the requester is
+//     missing, in topological order.  If there are too many to send at once,
+//     it sets `More`, and the requester asks again with the leaves it will
+//     have once it's applied them.
+//  3. The requester sends the txs that the responder is missing.  It has
+//     received the responder's leaves by now, so it doesn't need to probe.
+type ReconcileRequest struct {
+	StateURI string     `json:"stateURI"`
+	Leaves   []types.ID `json:"leaves"`
+	Probe    []types.ID `json:"probe,omitempty"`
+	Txs      []*Tx      `json:"txs,omitempty"`
+}
+
+type ReconcileResponse struct {
+	Leaves []types.ID `json:"leaves"`
+	Known  []types.ID `json:"known,omitempty"`
+	Txs    []*Tx      `json:"txs,omitempty"`
+	More   bool       `json:"more,omitempty"`
+}
+
+const (
+	reconcileMaxTxs   = 500
+	reconcileMinProbe = 16
+	reconcileTimeout  = 30 * time.Second
+)
+
+// ReconcileWithPeer exchanges every tx of the given state URI that one side has
+// and the other doesn't.
+func (h *host) ReconcileWithPeer(ctx context.Context, stateURI string, peer Peer) error {
+	err := peer.EnsureConnected(ctx)
+	if err != nil {
+		return err
+	}
+
+	ourLeaves, err := h.controllerHub.Leaves(stateURI)
+	if err != nil && errors.Cause(err) != ErrNoController {
+		return err
+	}
+
+	shared, err := h.findSharedHistory(ctx, stateURI, peer, ourLeaves)
+	if err != nil {
+		return err
+	}
+
+	// Receive the txs that we're missing
+	known := make(map[types.ID]struct{}, len(shared))
+	for _, txID := range shared {
+		known[txID] = struct{}{}
+	}
+	var theirLeaves []types.ID
+	var numReceived int
+	for {
+		leaves := make([]types.ID, 0, len(known))
+		for txID := range known {
+			leaves = append(leaves, txID)
+		}
+
+		resp, err := peer.Reconcile(ctx, ReconcileRequest{StateURI: stateURI, Leaves: leaves})
+		if err != nil {
+			return err
+		}
+		theirLeaves = resp.Leaves
+
+		for _, tx := range resp.Txs {
+			if tx == nil || tx.StateURI != stateURI {
+				return errors.Wrap(ErrProtocol, "peer sent a bad tx while reconciling")
+			}
+			h.addReceivedTx(*tx, peer)
+
+			for _, parentID := range tx.Parents {
+				delete(known, parentID)
+			}
+			known[tx.ID] = struct{}{}
+		}
+		numReceived += len(resp.Txs)
+
+		if !resp.More || len(resp.Txs) == 0 {
+			break
+		}
+	}
+
+	for _, txID := range theirLeaves {
+		h.markTxSeenByPeer(peer, stateURI, txID)
+	}
+
+	// Send the txs that they're missing
+	isMember, err := h.peerIsMember(stateURI, peer)
+	if errors.Cause(err) == ErrNoController {
+		return nil
+	} else if err != nil {
+		return err
+	} else if !isMember {
+		return nil
+	}
+
+	// They may have leaves that they couldn't send us, so the shared history
+	// that we found still counts
+	var batch []*Tx
+	sendBatch := func() error {
+		_, err := peer.Reconcile(ctx, ReconcileRequest{StateURI: stateURI, Leaves: ourLeaves, Txs: batch})
+		if err != nil {
+			return err
+		}
+		for _, tx := range batch {
+			h.markTxSeenByPeer(peer, stateURI, tx.ID)
+		}
+		batch = nil
+		return nil
+	}
+	err = h.forEachTxSinceLeaves(stateURI, append(theirLeaves, shared...), func(tx *Tx) (bool, error) {
+		isReadable, err := h.txIsReadableByPeer(peer, tx)
+		if err != nil {
+			return true, err
+		} else if !isReadable {
+			return false, nil
+		}
+		batch = append(batch, tx)
+		if len(batch) >= reconcileMaxTxs {
+			return false, sendBatch()
+		}
+		return false, nil
+	})
+	if err != nil {
+		return err
+	} else if len(batch) > 0 {
+		err = sendBatch()
+		if err != nil {
+			return err
+		}
+	}
+
+	if numReceived > 0 {
+		h.Infof(0, "reconciled %v with %v (received %v txs)", stateURI, peer.DialInfo(), numReceived)
+	}
+	return nil
+}
+
+// findSharedHistory returns txs in our history that the peer also has, such
+// that every tx we share with the peer is an ancestor of (or equal to) one of
+// them.  It walks back from our leaves, and only continues past the txs that
+// the peer doesn't have.
+func (h *host) findSharedHistory(ctx context.Context, stateURI string, peer Peer, ourLeaves []types.ID) ([]types.ID, error) {
+	var (
+		shared    []types.ID
+		peerHas   = make(map[types.ID]bool)
+		parents   = make(map[types.ID][]types.ID)
+		next      = ourLeaves
+		probeSize = reconcileMinProbe
+	)
+	for len(next) > 0 {
+		// Probe several generations at once.  Some of the older txs may turn
+		// out to be ancestors of txs that the peer has, but asking about them
+		// saves a round trip for every generation when our histories have
+		// diverged a long way.
+		var probe []types.ID
+		queued := make(map[types.ID]struct{})
+		queue := next
+		next = nil
+		for len(queue) > 0 && len(probe) < probeSize {
+			txID := queue[0]
+			queue = queue[1:]
+
+			if _, isProbed := peerHas[txID]; isProbed {
+				continue
+			} else if _, isQueued := queued[txID]; isQueued {
+				continue
+			}
+			queued[txID] = struct{}{}
+			probe = append(probe, txID)
+
+			tx, err := h.controllerHub.FetchTx(stateURI, txID)
+			if errors.Cause(err) == types.Err404 {
+				// Pruned, so there's nothing further back to ask about
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			parents[txID] = tx.Parents
+			queue = append(queue, tx.Parents...)
+		}
+		if len(probe) == 0 {
+			break
+		}
+
+		resp, err := peer.Reconcile(ctx, ReconcileRequest{StateURI: stateURI, Probe: probe})
+		if err != nil {
+			return nil, err
+		}
+
+		known := make(map[types.ID]struct{}, len(resp.Known))
+		for _, txID := range resp.Known {
+			known[txID] = struct{}{}
+		}
+		for _, txID := range probe {
+			_, has := known[txID]
+			peerHas[txID] = has
+			if has {
+				shared = append(shared, txID)
+			}
+		}
+		for _, txID := range probe {
+			if peerHas[txID] {
+				continue
+			}
+			for _, parentID := range parents[txID] {
+				if _, isProbed := peerHas[parentID]; !isProbed {
+					next = append(next, parentID)
+				}
+			}
+		}
+
+		if probeSize < reconcileMaxTxs {
+			probeSize *= 2
+		}
+	}
+	return shared, nil
+}
+
+// HandleReconcileRequest responds to a peer that's reconciling its history of a
+// state URI with ours.
+func (h *host) HandleReconcileRequest(req ReconcileRequest, peer Peer) (ReconcileResponse, error) {
+	isMember, err := h.peerIsMember(req.StateURI, peer)
+	if err != nil {
+		return ReconcileResponse{}, err
+	} else if !isMember {
+		return ReconcileResponse{}, errors.WithStack(types.Err403)
+	}
+
+	if len(req.Probe) > reconcileMaxTxs {
+		return ReconcileResponse{}, errors.Wrap(ErrProtocol, "too many probes")
+	}
+
+	for _, tx := range req.Txs {
+		if tx == nil || tx.StateURI != req.StateURI {
+			return ReconcileResponse{}, errors.Wrap(ErrProtocol, "bad tx")
+		}
+		h.addReceivedTx(*tx, peer)
+	}
+	for _, txID := range req.Leaves {
+		h.markTxSeenByPeer(peer, req.StateURI, txID)
+	}
+
+	leaves, err := h.controllerHub.Leaves(req.StateURI)
+	if err != nil {
+		return ReconcileResponse{}, err
+	}
+
+	if len(req.Probe) > 0 {
+		var known []types.ID
+		for _, txID := range req.Probe {
+			have, err := h.controllerHub.HaveTx(req.StateURI, txID)
+			if err != nil {
+				return ReconcileResponse{}, err
+			} else if have {
+				known = append(known, txID)
+			}
+		}
+		return ReconcileResponse{Leaves: leaves, Known: known}, nil
+	}
+
+	// A request that carries txs means that the peer has already received
+	// everything it was missing from us
+	if len(req.Txs) > 0 {
+		return ReconcileResponse{Leaves: leaves}, nil
+	}
+
+	resp := ReconcileResponse{Leaves: leaves}
+	err = h.forEachTxSinceLeaves(req.StateURI, req.Leaves, func(tx *Tx) (bool, error) {
+		isReadable, err := h.txIsReadableByPeer(peer, tx)
+		if err != nil {
+			return true, err
+		} else if !isReadable {
+			return false, nil
+		} else if len(resp.Txs) == reconcileMaxTxs {
+			resp.More = true
+			return true, nil
+		}
+		resp.Txs = append(resp.Txs, tx)
+		return false, nil
+	})
+	if err != nil {
+		return ReconcileResponse{}, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,123 @@
+package redwood_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"redwood.dev"
+	"redwood.dev/testutils"
+	"redwood.dev/types"
+)
+
+func TestHost_ReconcilesWhenSubscriptionConnects(t *testing.T) {
+	swarm := testutils.NewSwarm(t, 2, 8)
+	defer swarm.Close()
+
+	stateURI := "foo.com/bar"
+	sendGenesis(t, swarm, stateURI)
+
+	// Both hosts write while they can't reach each other
+	swarm.Network.Partition([]string{swarm.Addr(0)}, []string{swarm.Addr(1)})
+	writeFromHost(t, swarm, 0, 3, stateURI)
+	writeFromHost(t, swarm, 1, 2, stateURI)
+	swarm.Network.Heal()
+
+	leaves0, err := swarm.Hosts[0].Controllers().Leaves(stateURI)
+	require.NoError(t, err)
+	leaves1, err := swarm.Hosts[1].Controllers().Leaves(stateURI)
+	require.NoError(t, err)
+	require.NotEqual(t, leaves0, leaves1)
+
+	// Nothing is gossiped after the partition heals, so only reconciling can
+	// bring the hosts back together
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	sub, err := swarm.Hosts[1].Subscribe(ctx, stateURI, redwood.SubscriptionType_Txs, nil, nil)
+	require.NoError(t, err)
+	defer sub.Close()
+
+	swarm.RequireConverged(stateURI, 20*time.Second)
+
+	for _, h := range swarm.Hosts {
+		for _, txID := range append(leaves0, leaves1...) {
+			have, err := h.Controllers().HaveTx(stateURI, txID)
+			require.NoError(t, err)
+			require.True(t, have)
+		}
+	}
+}
+
+// reconcilePeer hands reconcile requests straight to another host, and counts
+// the txs that travel each way.
+type reconcilePeer struct {
+	redwood.Peer
+	remote   redwood.Host
+	self     redwood.Peer
+	dialInfo redwood.PeerDialInfo
+
+	numProbes   int
+	numReceived int
+	numSent     int
+}
+
+func (p *reconcilePeer) EnsureConnected(ctx context.Context) error { return nil }
+func (p *reconcilePeer) DialInfo() redwood.PeerDialInfo            { return p.dialInfo }
+func (p *reconcilePeer) Addresses() []types.Address                { return nil }
+
+func (p *reconcilePeer) Reconcile(ctx context.Context, req redwood.ReconcileRequest) (redwood.ReconcileResponse, error) {
+	if len(req.Probe) > 0 {
+		p.numProbes++
+	}
+	p.numSent += len(req.Txs)
+	resp, err := p.remote.HandleReconcileRequest(req, p.self)
+	p.numReceived += len(resp.Txs)
+	return resp, err
+}
+
+func TestHost_ReconcileOnlyTransfersMissingTxs(t *testing.T) {
+	swarm := testutils.NewSwarm(t, 2, 8)
+	defer swarm.Close()
+
+	stateURI := "foo.com/bar"
+	sendGenesis(t, swarm, stateURI)
+	writeFromHost(t, swarm, 0, 20, stateURI)
+	swarm.RequireConverged(stateURI, 10*time.Second)
+
+	// The hosts diverge after a long shared history
+	swarm.Network.Partition([]string{swarm.Addr(0)}, []string{swarm.Addr(1)})
+	writeFromHost(t, swarm, 0, 3, stateURI)
+	writeFromHost(t, swarm, 1, 2, stateURI)
+
+	leaves0, err := swarm.Hosts[0].Controllers().Leaves(stateURI)
+	require.NoError(t, err)
+	leaves1, err := swarm.Hosts[1].Controllers().Leaves(stateURI)
+	require.NoError(t, err)
+
+	peer := &reconcilePeer{
+		remote:   swarm.Hosts[0],
+		self:     &reconcilePeer{dialInfo: redwood.PeerDialInfo{TransportName: "test", DialAddr: swarm.Addr(1)}},
+		dialInfo: redwood.PeerDialInfo{TransportName: "test", DialAddr: swarm.Addr(0)},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	err = swarm.Hosts[1].ReconcileWithPeer(ctx, stateURI, peer)
+	require.NoError(t, err)
+
+	// One probe reaches back past the point where the histories diverged
+	require.Equal(t, 1, peer.numProbes)
+	require.Equal(t, 3, peer.numReceived)
+	require.Equal(t, 2, peer.numSent)
+
+	for _, h := range swarm.Hosts {
+		for _, txID := range append(leaves0, leaves1...) {
+			require.Eventually(t, func() bool {
+				have, err := h.Controllers().HaveTx(stateURI, txID)
+				return err == nil && have
+			}, 10*time.Second, 10*time.Millisecond)
+		}
+	}
+}
@@ -91,6 +91,7 @@ type writableSubscription struct {
 	chErrored        chan struct{}
 	chStop           chan struct{}
 	chDone           chan struct{}
+	stopOnce         sync.Once
 }
 
 type WritableSubscriptionImpl interface {
@@ -176,6 +177,17 @@ func (sub *writableSubscription) destroy() {
 	}
 }
 
+// subscriberPeer returns the remote peer on the other end of a writable
+// subscription.  In-process subscriptions have no peer.
+func subscriberPeer(writeSub WritableSubscription) (Peer, bool) {
+	if sub, is := writeSub.(*writableSubscription); is {
+		peer, isPeer := sub.subImpl.(Peer)
+		return peer, isPeer
+	}
+	peer, isPeer := writeSub.(Peer)
+	return peer, isPeer
+}
+
 func (sub *writableSubscription) StateURI() string       { return sub.stateURI }
 func (sub *writableSubscription) Type() SubscriptionType { return sub.subscriptionType }
 func (sub *writableSubscription) Keypath() tree.Keypath  { return sub.keypath }
@@ -184,9 +196,13 @@ func (sub *writableSubscription) EnqueueWrite(tx *Tx, state tree.Node, leaves []
 	sub.messages.Deliver(&SubscriptionMsg{Tx: tx, State: state, Leaves: leaves})
 }
 
+// Close may be called both by the host and by the transport that opened the
+// subscription.
 func (sub *writableSubscription) Close() error {
-	sub.chMsgNotif = nil
-	close(sub.chStop)
+	sub.stopOnce.Do(func() {
+		sub.chMsgNotif = nil
+		close(sub.chStop)
+	})
 	<-sub.chDone
 	return nil
 }
@@ -200,6 +216,7 @@ type inProcessSubscription struct {
 	chMessages       chan SubscriptionMsg
 	chStop           chan struct{}
 	chDone           chan struct{}
+	stopOnce         sync.Once
 }
 
 var _ ReadableSubscription = (*inProcessSubscription)(nil)
@@ -271,9 +288,11 @@ func (sub *inProcessSubscription) Read() (*SubscriptionMsg, error) {
 }
 
 func (sub *inProcessSubscription) Close() error {
-	sub.host.HandleWritableSubscriptionClosed(sub)
-	sub.messages.Clear()
-	close(sub.chStop)
+	sub.stopOnce.Do(func() {
+		sub.host.HandleWritableSubscriptionClosed(sub)
+		sub.messages.Clear()
+		close(sub.chStop)
+	})
 	<-sub.chDone
 	return nil
 }
@@ -344,6 +363,27 @@ func (s *multiReaderSubscription) Start() {
 					return
 				}
 
+				// If we have no history for this state URI yet, start from the
+				// peer's most recent snapshot rather than replaying all of it
+				func() {
+					ctx, cancel := utils.CombinedContext(s.chStop, bootstrapTimeout)
+					defer cancel()
+					err := s.host.BootstrapFromSnapshot(ctx, s.stateURI, peer)
+					if err != nil {
+						s.host.Warnf("could not bootstrap %v from snapshot: %v", s.stateURI, err)
+					}
+				}()
+
+				// Exchange whatever either side missed while we weren't connected
+				func() {
+					ctx, cancel := utils.CombinedContext(s.chStop, reconcileTimeout)
+					defer cancel()
+					err := s.host.ReconcileWithPeer(ctx, s.stateURI, peer)
+					if err != nil {
+						s.host.Warnf("could not reconcile %v with peer: %v", s.stateURI, err)
+					}
+				}()
+
 				peerSub, err := peer.Subscribe(context.TODO(), s.stateURI)
 				if err != nil {
 					s.host.Errorf("error subscribing to %v peer (stateURI: %v): %v", peer.Transport().Name(), s.stateURI, err)
@@ -352,6 +392,17 @@ func (s *multiReaderSubscription) Start() {
 				}
 				defer peerSub.Close()
 
+				// Don't let a quiet peer block the subscription from closing
+				chReadDone := make(chan struct{})
+				defer close(chReadDone)
+				go func() {
+					select {
+					case <-s.chStop:
+						peerSub.Close()
+					case <-chReadDone:
+					}
+				}()
+
 				for {
 					select {
 					case <-s.chStop:
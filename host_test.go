@@ -0,0 +1,173 @@
+package redwood_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"redwood.dev"
+	"redwood.dev/crypto"
+	"redwood.dev/testutils"
+	"redwood.dev/types"
+)
+
+// historyPeerMock answers every FetchHistory request with the same txs, no
+// matter which ones were asked for.  If unreachable is set, it can't be
+// connected to at all.
+type historyPeerMock struct {
+	redwood.Peer
+	dialAddr    string
+	txs         []redwood.Tx
+	unreachable bool
+
+	mu        sync.Mutex
+	requested []types.ID
+}
+
+func (p *historyPeerMock) DialInfo() redwood.PeerDialInfo {
+	return redwood.PeerDialInfo{TransportName: "mock", DialAddr: p.dialAddr}
+}
+
+func (p *historyPeerMock) EnsureConnected(ctx context.Context) error {
+	if p.unreachable {
+		return errors.WithStack(types.ErrConnection)
+	}
+	return nil
+}
+
+func (p *historyPeerMock) Ack(stateURI string, txID types.ID) error {
+	return nil
+}
+
+func (p *historyPeerMock) FetchHistory(ctx context.Context, stateURI string, opts redwood.FetchHistoryOpts) (redwood.ReadableSubscription, error) {
+	p.mu.Lock()
+	p.requested = append(p.requested, opts.TxIDs...)
+	p.mu.Unlock()
+	return &historySubscriptionMock{txs: p.txs, chClosed: make(chan struct{})}, nil
+}
+
+func (p *historyPeerMock) Requested() []types.ID {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]types.ID(nil), p.requested...)
+}
+
+type historySubscriptionMock struct {
+	txs       []redwood.Tx
+	chClosed  chan struct{}
+	closeOnce sync.Once
+}
+
+func (s *historySubscriptionMock) Read() (*redwood.SubscriptionMsg, error) {
+	if len(s.txs) > 0 {
+		tx := s.txs[0]
+		s.txs = s.txs[1:]
+		return &redwood.SubscriptionMsg{Tx: &tx}, nil
+	}
+	<-s.chClosed
+	return nil, errors.New("closed")
+}
+
+func (s *historySubscriptionMock) Close() error {
+	s.closeOnce.Do(func() { close(s.chClosed) })
+	return nil
+}
+
+func mustSignTx(t *testing.T, signer *crypto.SigningKeypair, tx redwood.Tx) redwood.Tx {
+	t.Helper()
+	tx.From = signer.Address()
+	sig, err := signer.SignHash(tx.Hash())
+	require.NoError(t, err)
+	tx.Sig = sig
+	return tx
+}
+
+func requireHasTx(t *testing.T, h redwood.Host, stateURI string, txID types.ID) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		have, err := h.Controllers().HaveTx(stateURI, txID)
+		return err == nil && have
+	}, 10*time.Second, 50*time.Millisecond)
+}
+
+func TestHost_FetchesMissingParentsFromSender(t *testing.T) {
+	// With only one host on the network, the sender is the only place that the
+	// missing parent can come from
+	swarm := testutils.NewSwarm(t, 1, 5)
+	defer swarm.Close()
+
+	stateURI := "foo.com/bar"
+	sendGenesis(t, swarm, stateURI)
+
+	signer, err := crypto.GenerateSigningKeypair()
+	require.NoError(t, err)
+	parent := mustSignTx(t, signer, redwood.Tx{ID: types.RandomID(), StateURI: stateURI, Parents: []types.ID{redwood.GenesisTxID}, Patches: mustParsePatches(t, `.a = 1`)})
+	child := mustSignTx(t, signer, redwood.Tx{ID: types.RandomID(), StateURI: stateURI, Parents: []types.ID{parent.ID}, Patches: mustParsePatches(t, `.b = 2`)})
+
+	sender := &historyPeerMock{dialAddr: "sender", txs: []redwood.Tx{parent}}
+	swarm.Hosts[0].HandleTxReceived(child, sender)
+
+	requireHasTx(t, swarm.Hosts[0], stateURI, parent.ID)
+	requireHasTx(t, swarm.Hosts[0], stateURI, child.ID)
+	require.Equal(t, []types.ID{parent.ID}, sender.Requested())
+}
+
+func TestHost_FetchesMissingParentsFromProviders(t *testing.T) {
+	swarm := testutils.NewSwarm(t, 2, 6)
+	defer swarm.Close()
+
+	stateURI := "foo.com/bar"
+	sendGenesis(t, swarm, stateURI)
+
+	// Host 1 misses host 0's writes
+	swarm.Network.Partition([]string{swarm.Addr(0)}, []string{swarm.Addr(1)})
+	writeFromHost(t, swarm, 0, 2, stateURI)
+	swarm.Network.Heal()
+
+	leaves, err := swarm.Hosts[0].Controllers().Leaves(stateURI)
+	require.NoError(t, err)
+	require.Len(t, leaves, 1)
+	child, err := swarm.Hosts[0].Controllers().FetchTx(stateURI, leaves[0])
+	require.NoError(t, err)
+
+	// The peer that sent the child can't be reached, so host 1 asks the other
+	// providers of the state URI for its parent
+	sender := &historyPeerMock{dialAddr: "sender", unreachable: true}
+	swarm.Hosts[1].HandleTxReceived(*child, sender)
+
+	requireHasTx(t, swarm.Hosts[1], stateURI, child.Parents[0])
+	requireHasTx(t, swarm.Hosts[1], stateURI, child.ID)
+}
+
+func TestHost_IgnoresUnrequestedTxsWhenFetchingParents(t *testing.T) {
+	swarm := testutils.NewSwarm(t, 1, 7)
+	defer swarm.Close()
+
+	stateURI := "foo.com/bar"
+	sendGenesis(t, swarm, stateURI)
+
+	signer, err := crypto.GenerateSigningKeypair()
+	require.NoError(t, err)
+	parent := mustSignTx(t, signer, redwood.Tx{ID: types.RandomID(), StateURI: stateURI, Parents: []types.ID{redwood.GenesisTxID}, Patches: mustParsePatches(t, `.a = 1`)})
+	child := mustSignTx(t, signer, redwood.Tx{ID: types.RandomID(), StateURI: stateURI, Parents: []types.ID{parent.ID}, Patches: mustParsePatches(t, `.b = 2`)})
+	unrequested := mustSignTx(t, signer, redwood.Tx{ID: types.RandomID(), StateURI: stateURI, Parents: []types.ID{redwood.GenesisTxID}, Patches: mustParsePatches(t, `.c = 3`)})
+	wrongStateURI := parent
+	wrongStateURI.StateURI = "foo.com/other"
+	wrongStateURI = mustSignTx(t, signer, wrongStateURI)
+
+	sender := &historyPeerMock{dialAddr: "sender", txs: []redwood.Tx{unrequested, wrongStateURI, parent}}
+	swarm.Hosts[0].HandleTxReceived(child, sender)
+
+	requireHasTx(t, swarm.Hosts[0], stateURI, parent.ID)
+	requireHasTx(t, swarm.Hosts[0], stateURI, child.ID)
+
+	have, err := swarm.Hosts[0].Controllers().HaveTx(stateURI, unrequested.ID)
+	require.NoError(t, err)
+	require.False(t, have)
+	_, err = swarm.Hosts[0].Controllers().FetchTx("foo.com/other", parent.ID)
+	require.Error(t, err)
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -83,5 +84,8 @@ func PutRequestFromTx(
 	if tx.Checkpoint {
 		req.Header.Set("Checkpoint", "true")
 	}
+	if tx.Timestamp != 0 {
+		req.Header.Set("Timestamp", strconv.FormatInt(tx.Timestamp, 10))
+	}
 	return req, nil
 }
@@ -203,6 +203,47 @@ func (ks *BadgerKeyStore) NewIdentity(public bool) (_ Identity, err error) {
 	return identity, nil
 }
 
+// SetHDMnemonic replaces the mnemonic from which the signing keys of every
+// identity are derived.  Identities keep their index, their encrypting keys and
+// whether they're public, but their addresses change.
+func (ks *BadgerKeyStore) SetHDMnemonic(mnemonic string) (err error) {
+	defer utils.WithStack(&err)
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if ks.unlockedUser == nil {
+		return errors.WithStack(ErrLocked)
+	}
+
+	identities := make([]Identity, len(ks.unlockedUser.Identities))
+	addressesToIndices := make(map[types.Address]uint32, len(ks.unlockedUser.Identities))
+	for i, identity := range ks.unlockedUser.Identities {
+		sigkeys, err := crypto.SigningKeypairFromHDMnemonic(mnemonic, uint32(i))
+		if err != nil {
+			return err
+		}
+		identities[i] = Identity{
+			Public:     identity.Public,
+			Signing:    sigkeys,
+			Encrypting: identity.Encrypting,
+		}
+		addressesToIndices[sigkeys.Address()] = uint32(i)
+	}
+
+	user := *ks.unlockedUser
+	user.Mnemonic = mnemonic
+	user.Identities = identities
+	user.AddressesToIndices = addressesToIndices
+
+	err = ks.saveUser(&user, user.Password)
+	if err != nil {
+		return err
+	}
+	ks.unlockedUser = &user
+	return nil
+}
+
 func (ks *BadgerKeyStore) SignHash(usingIdentity types.Address, data types.Hash) (_ []byte, err error) {
 	defer utils.WithStack(&err)
 
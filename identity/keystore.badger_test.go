@@ -8,6 +8,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 
+	"redwood.dev/crypto"
 	"redwood.dev/identity"
 	"redwood.dev/testutils"
 	"redwood.dev/tree"
@@ -38,6 +39,9 @@ func TestBadgerKeyStore_ErrorsWhenLocked(t *testing.T) {
 	_, err = ks.NewIdentity(true)
 	require.True(t, errors.Cause(err) == identity.ErrLocked)
 
+	err = ks.SetHDMnemonic("")
+	require.True(t, errors.Cause(err) == identity.ErrLocked)
+
 	_, err = ks.SignHash(types.Address{}, types.Hash{})
 	require.True(t, errors.Cause(err) == identity.ErrLocked)
 
@@ -193,6 +197,71 @@ func TestBadgerKeyStore_NewIdentity(t *testing.T) {
 	})
 }
 
+func TestBadgerKeyStore_SetHDMnemonic(t *testing.T) {
+	db := testutils.SetupDBTree(t)
+	defer db.DeleteDB()
+
+	ks := identity.NewBadgerKeyStore(db, identity.FastScryptParams)
+	err := ks.Unlock("password")
+	require.NoError(t, err)
+
+	_, err = ks.NewIdentity(false)
+	require.NoError(t, err)
+
+	before, err := ks.Identities()
+	require.NoError(t, err)
+
+	t.Run("rejects invalid mnemonics", func(t *testing.T) {
+		err := ks.SetHDMnemonic("not a mnemonic")
+		require.Error(t, err)
+
+		ids, err := ks.Identities()
+		require.NoError(t, err)
+		require.Equal(t, before, ids)
+	})
+
+	mnemonic, err := crypto.GenerateMnemonic()
+	require.NoError(t, err)
+
+	t.Run("derives every identity from the new mnemonic", func(t *testing.T) {
+		err := ks.SetHDMnemonic(mnemonic)
+		require.NoError(t, err)
+
+		ids, err := ks.Identities()
+		require.NoError(t, err)
+		require.Len(t, ids, 2)
+
+		for i, id := range ids {
+			sigkeys, err := crypto.SigningKeypairFromHDMnemonic(mnemonic, uint32(i))
+			require.NoError(t, err)
+			require.Equal(t, sigkeys.Address(), id.Address())
+			require.Equal(t, before[i].Public, id.Public)
+			require.Equal(t, before[i].Encrypting, id.Encrypting)
+
+			exists, err := ks.IdentityExists(id.Address())
+			require.NoError(t, err)
+			require.True(t, exists)
+
+			exists, err = ks.IdentityExists(before[i].Address())
+			require.NoError(t, err)
+			require.False(t, exists)
+		}
+	})
+
+	t.Run("persists the new mnemonic", func(t *testing.T) {
+		ids, err := ks.Identities()
+		require.NoError(t, err)
+
+		ks2 := identity.NewBadgerKeyStore(db, identity.FastScryptParams)
+		err = ks2.Unlock("password")
+		require.NoError(t, err)
+
+		ids2, err := ks2.Identities()
+		require.NoError(t, err)
+		require.Equal(t, ids, ids2)
+	})
+}
+
 func TestBadgerKeyStore_SignHash(t *testing.T) {
 	db := testutils.SetupDBTree(t)
 	defer db.DeleteDB()
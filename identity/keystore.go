@@ -15,6 +15,7 @@ type KeyStore interface {
 	IdentityWithAddress(address types.Address) (Identity, error)
 	IdentityExists(address types.Address) (bool, error)
 	NewIdentity(public bool) (Identity, error)
+	SetHDMnemonic(mnemonic string) error
 	SignHash(usingIdentity types.Address, data types.Hash) ([]byte, error)
 	VerifySignature(usingIdentity types.Address, hash types.Hash, signature []byte) (bool, error)
 	SealMessageFor(usingIdentity types.Address, recipientPubKey crypto.EncryptingPublicKey, msg []byte) ([]byte, error)
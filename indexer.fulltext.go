@@ -0,0 +1,228 @@
+package redwood
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+
+	"redwood.dev/tree"
+	"redwood.dev/types"
+)
+
+// fulltextIndexer builds an inverted index of the words in the string leaves
+// of each child (or of the subtree at 'keypath' in each child).  A word's entry
+// for a child lists the leaves that contain it, and how many times:
+//
+//	<word>/<child key>: [{"keypath": ["text"], "count": 2}, ...]
+//
+// Keypaths are stored as lists of parts, with slice indices as numbers.
+type fulltextIndexer struct {
+	keypath tree.Keypath
+}
+
+// Words longer than this aren't indexed
+const maxFulltextWordLen = 64
+
+// Ensure fulltextIndexer conforms to the SearchIndexer and tree.MultiKeyIndexer interfaces
+var _ SearchIndexer = (*fulltextIndexer)(nil)
+var _ tree.MultiKeyIndexer = (*fulltextIndexer)(nil)
+
+func NewFulltextIndexer(config tree.Node) (Indexer, error) {
+	keypath, _, err := config.StringValue(tree.Keypath("keypath"))
+	if err != nil {
+		return nil, err
+	}
+	return &fulltextIndexer{keypath: tree.Keypath(keypath)}, nil
+}
+
+// IndexNode is never called, because fulltextIndexer is a tree.MultiKeyIndexer.
+func (i *fulltextIndexer) IndexNode(relKeypath tree.Keypath, node tree.Node) (tree.Keypath, tree.Node, error) {
+	return nil, nil, nil
+}
+
+func (i *fulltextIndexer) IndexNodeKeys(relKeypath tree.Keypath, node tree.Node) ([]tree.IndexEntry, error) {
+	val, exists, err := node.Value(i.keypath, nil)
+	if err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, nil
+	}
+
+	var keypath []interface{}
+	for _, part := range i.keypath.Parts() {
+		keypath = append(keypath, string(part))
+	}
+
+	var words []string
+	leaves := make(map[string][]interface{})
+	walkStringLeaves(keypath, val, func(keypath []interface{}, s string) {
+		counts := make(map[string]int)
+		for _, word := range tokenizeFulltext(s) {
+			if counts[word] == 0 && len(leaves[word]) == 0 {
+				words = append(words, word)
+			}
+			counts[word]++
+		}
+		for word, count := range counts {
+			leaves[word] = append(leaves[word], map[string]interface{}{
+				"keypath": keypath,
+				"count":   float64(count),
+			})
+		}
+	})
+
+	entries := make([]tree.IndexEntry, 0, len(words))
+	for _, word := range words {
+		entry := tree.NewMemoryNode()
+		err := entry.Set(nil, nil, leaves[word])
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, tree.IndexEntry{Key: tree.Keypath(word), Node: entry})
+	}
+	return entries, nil
+}
+
+// Search ranks the leaves containing any of the query's words by TF-IDF, and
+// returns their keypaths (relative to the state root) and scores.
+func (i *fulltextIndexer) Search(index *tree.DBNode, indexed tree.Node, query tree.Keypath) ([]interface{}, error) {
+	nodeType, _, numChildren, err := indexed.NodeInfo(nil)
+	if errors.Cause(err) == types.Err404 {
+		return []interface{}{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var indexedKeypath []interface{}
+	for _, part := range indexed.Keypath().Parts() {
+		indexedKeypath = append(indexedKeypath, string(part))
+	}
+
+	type result struct {
+		id      string
+		keypath []interface{}
+		score   float64
+	}
+	results := make(map[string]*result)
+
+	seen := make(map[string]bool)
+	for _, word := range tokenizeFulltext(string(query)) {
+		if seen[word] {
+			continue
+		}
+		seen[word] = true
+
+		wordNode := index.NodeAt(tree.Keypath(word), nil).(*tree.DBNode)
+		childKeys, err := wordNode.ChildKeysInRange(tree.KeyRange{})
+		if err != nil {
+			return nil, err
+		} else if len(childKeys) == 0 {
+			continue
+		}
+		idf := math.Log(1 + float64(numChildren)/float64(len(childKeys)))
+
+		for _, childKeyHex := range childKeys {
+			childKey, err := hex.DecodeString(string(childKeyHex))
+			if err != nil {
+				continue
+			}
+			var childPart interface{} = string(childKey)
+			if nodeType == tree.NodeTypeSlice {
+				childPart = float64(tree.DecodeSliceIndex(childKey))
+			}
+
+			val, _, err := wordNode.Value(childKeyHex, nil)
+			if err != nil {
+				return nil, err
+			}
+			leaves, _ := val.([]interface{})
+
+			for _, x := range leaves {
+				leaf, _ := x.(map[string]interface{})
+				leafKeypath, _ := leaf["keypath"].([]interface{})
+				count, _ := leaf["count"].(float64)
+				if count <= 0 {
+					continue
+				}
+
+				keypath := make([]interface{}, 0, len(indexedKeypath)+1+len(leafKeypath))
+				keypath = append(keypath, indexedKeypath...)
+				keypath = append(keypath, childPart)
+				keypath = append(keypath, leafKeypath...)
+
+				idBytes, err := json.Marshal(keypath)
+				if err != nil {
+					return nil, err
+				}
+				id := string(idBytes)
+				if results[id] == nil {
+					results[id] = &result{id: id, keypath: keypath}
+				}
+				results[id].score += (1 + math.Log(count)) * idf
+			}
+		}
+	}
+
+	sorted := make([]*result, 0, len(results))
+	for _, r := range results {
+		sorted = append(sorted, r)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].score != sorted[j].score {
+			return sorted[i].score > sorted[j].score
+		}
+		return sorted[i].id < sorted[j].id
+	})
+
+	matches := make([]interface{}, len(sorted))
+	for i, r := range sorted {
+		matches[i] = map[string]interface{}{"keypath": r.keypath, "score": r.score}
+	}
+	return matches, nil
+}
+
+// tokenizeFulltext splits text into lowercase words made of letters and digits.
+func tokenizeFulltext(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	n := 0
+	for _, word := range words {
+		if len(word) <= maxFulltextWordLen {
+			words[n] = word
+			n++
+		}
+	}
+	return words[:n]
+}
+
+// walkStringLeaves calls fn with every string in a Go value, in a consistent
+// order, along with its keypath.
+func walkStringLeaves(keypath []interface{}, val interface{}, fn func(keypath []interface{}, s string)) {
+	push := func(part interface{}) []interface{} {
+		return append(append([]interface{}(nil), keypath...), part)
+	}
+
+	switch v := val.(type) {
+	case string:
+		fn(keypath, v)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			walkStringLeaves(push(key), v[key], fn)
+		}
+	case []interface{}:
+		for idx, x := range v {
+			walkStringLeaves(push(float64(idx)), x, fn)
+		}
+	}
+}
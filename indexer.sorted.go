@@ -0,0 +1,220 @@
+package redwood
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"redwood.dev/tree"
+)
+
+// sortedIndexer keeps a node's children in the order of one or more of their
+// fields.  Each entry's key is made of the fields' values, encoded so that
+// they sort bytewise, followed by the child's own key to keep it unique:
+//
+//	<field 1>.<field 2>.<child key>
+//
+// Every part is hex encoded, and '.' sorts before any hex digit, so shorter
+// strings sort before longer ones that they're a prefix of.
+type sortedIndexer struct {
+	fields []sortedIndexField
+}
+
+type sortedIndexField struct {
+	keypath   tree.Keypath
+	fieldType string
+}
+
+const (
+	SortedIndexFieldString    = "string"
+	SortedIndexFieldNumber    = "number"
+	SortedIndexFieldTimestamp = "timestamp" // an RFC 3339 string
+)
+
+// SortedIndexQuery selects a run of entries from an "indexer/sorted" index.
+// Start, End and Prefix hold values for the index's leading fields, and are
+// compared field by field.  Start is inclusive and End is exclusive.
+type SortedIndexQuery struct {
+	Start   []interface{} `json:"start,omitempty"`
+	End     []interface{} `json:"end,omitempty"`
+	Prefix  []interface{} `json:"prefix,omitempty"`
+	Limit   uint64        `json:"limit,omitempty"`
+	Reverse bool          `json:"reverse,omitempty"`
+}
+
+// Keypath encodes the query for use as QueryIndex's queryParam (or the HTTP
+// index_arg param).
+func (q SortedIndexQuery) Keypath() tree.Keypath {
+	bs, _ := json.Marshal(q)
+	return tree.Keypath(bs)
+}
+
+var ErrInvalidIndexQuery = errors.New("invalid index query")
+
+// Ensure sortedIndexer conforms to the RangeIndexer and tree.UniqueKeyIndexer interfaces
+var _ RangeIndexer = (*sortedIndexer)(nil)
+var _ tree.UniqueKeyIndexer = (*sortedIndexer)(nil)
+
+func NewSortedIndexer(config tree.Node) (Indexer, error) {
+	fieldsVal, exists, err := config.Value(tree.Keypath("fields"), nil)
+	if err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, errors.New("sorted indexer needs a 'fields' param")
+	}
+
+	fieldConfigs, is := fieldsVal.([]interface{})
+	if !is || len(fieldConfigs) == 0 {
+		return nil, errors.Errorf("sorted indexer needs a 'fields' param containing a list of fields (got %T)", fieldsVal)
+	}
+
+	var fields []sortedIndexField
+	for _, x := range fieldConfigs {
+		fieldConfig, is := x.(map[string]interface{})
+		if !is {
+			return nil, errors.Errorf("bad sorted indexer field: %v", x)
+		}
+		keypath, _ := fieldConfig["keypath"].(string)
+		if keypath == "" {
+			return nil, errors.Errorf("sorted indexer field needs a 'keypath': %v", x)
+		}
+		fieldType, _ := fieldConfig["type"].(string)
+		if fieldType == "" {
+			fieldType = SortedIndexFieldString
+		}
+
+		switch fieldType {
+		case SortedIndexFieldString, SortedIndexFieldNumber, SortedIndexFieldTimestamp:
+		default:
+			return nil, errors.Errorf("unknown sorted indexer field type '%v'", fieldType)
+		}
+		fields = append(fields, sortedIndexField{keypath: tree.Keypath(keypath), fieldType: fieldType})
+	}
+	return &sortedIndexer{fields: fields}, nil
+}
+
+func (i *sortedIndexer) IndexNode(relKeypath tree.Keypath, node tree.Node) (tree.Keypath, tree.Node, error) {
+	vals := make([]interface{}, len(i.fields))
+	for idx, field := range i.fields {
+		val, exists, err := node.Value(field.keypath, nil)
+		if err != nil {
+			return nil, nil, err
+		} else if !exists {
+			return nil, nil, nil
+		}
+		vals[idx] = val
+	}
+
+	key, err := i.encodeKey(vals)
+	if err != nil {
+		// Children whose fields have the wrong types are left out of the index
+		return nil, nil, nil
+	}
+	key = append(key, hex.EncodeToString(relKeypath)...)
+	return key, node, nil
+}
+
+func (i *sortedIndexer) UniqueKeys() bool {
+	return true
+}
+
+func (i *sortedIndexer) ParseKeyRange(queryParam tree.Keypath) (tree.KeyRange, error) {
+	var query SortedIndexQuery
+	if len(queryParam) > 0 {
+		err := json.Unmarshal(queryParam, &query)
+		if err != nil {
+			return tree.KeyRange{}, errors.Wrap(ErrInvalidIndexQuery, err.Error())
+		}
+	}
+
+	start, err := i.encodeKey(query.Start)
+	if err != nil {
+		return tree.KeyRange{}, errors.Wrapf(ErrInvalidIndexQuery, "start: %v", err)
+	}
+	end, err := i.encodeKey(query.End)
+	if err != nil {
+		return tree.KeyRange{}, errors.Wrapf(ErrInvalidIndexQuery, "end: %v", err)
+	}
+	prefix, err := i.encodeKey(query.Prefix)
+	if err != nil {
+		return tree.KeyRange{}, errors.Wrapf(ErrInvalidIndexQuery, "prefix: %v", err)
+	}
+	return tree.KeyRange{Start: start, End: end, Prefix: prefix, Limit: query.Limit, Reverse: query.Reverse}, nil
+}
+
+func (i *sortedIndexer) encodeKey(vals []interface{}) (tree.Keypath, error) {
+	if len(vals) > len(i.fields) {
+		return nil, errors.Errorf("index only has %v fields", len(i.fields))
+	}
+
+	var key tree.Keypath
+	for idx, val := range vals {
+		encoded, err := i.fields[idx].encode(val)
+		if err != nil {
+			return nil, err
+		}
+		key = append(key, hex.EncodeToString(encoded)...)
+		key = append(key, '.')
+	}
+	return key, nil
+}
+
+func (f sortedIndexField) encode(val interface{}) ([]byte, error) {
+	switch f.fieldType {
+	case SortedIndexFieldString:
+		s, is := val.(string)
+		if !is {
+			return nil, errors.Errorf("field %v: expected a string (got %T)", f.keypath, val)
+		}
+		return []byte(s), nil
+
+	case SortedIndexFieldNumber:
+		var n float64
+		switch v := val.(type) {
+		case float64:
+			n = v
+		case int:
+			n = float64(v)
+		case int64:
+			n = float64(v)
+		case uint64:
+			n = float64(v)
+		default:
+			return nil, errors.Errorf("field %v: expected a number (got %T)", f.keypath, val)
+		}
+		if n == 0 {
+			n = 0 // Normalize -0
+		}
+		// Flip the sign bit of positive numbers and every bit of negative
+		// ones, so that they sort correctly as unsigned integers
+		bits := math.Float64bits(n)
+		if bits&(1<<63) == 0 {
+			bits ^= 1 << 63
+		} else {
+			bits = ^bits
+		}
+		encoded := make([]byte, 8)
+		binary.BigEndian.PutUint64(encoded, bits)
+		return encoded, nil
+
+	case SortedIndexFieldTimestamp:
+		s, is := val.(string)
+		if !is {
+			return nil, errors.Errorf("field %v: expected an RFC 3339 timestamp (got %T)", f.keypath, val)
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, errors.Errorf("field %v: %v", f.keypath, err)
+		}
+		encoded := make([]byte, 8)
+		binary.BigEndian.PutUint64(encoded, uint64(t.UnixNano())^(1<<63))
+		return encoded, nil
+
+	default:
+		return nil, errors.Errorf("unknown field type '%v'", f.fieldType)
+	}
+}
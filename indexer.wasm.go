@@ -0,0 +1,68 @@
+package redwood
+
+import (
+	"redwood.dev/tree"
+	"redwood.dev/utils"
+)
+
+// wasmIndexer hands each node to a WASM module's `index_node` export.  The
+// module reads the node with `state_get` and calls `emit_index_key` to have it
+// indexed.  If the module also emits patches, they're applied to an empty
+// node, which is indexed in place of the original.
+//
+// The input passed to `index_node` looks like:
+//
+//	{ "keypath": "foo/bar" }
+type wasmIndexer struct {
+	sandbox *wasmSandbox
+}
+
+// Ensure wasmIndexer conforms to the Indexer interface
+var _ Indexer = (*wasmIndexer)(nil)
+
+func NewWASMIndexer(config tree.Node) (_ Indexer, err error) {
+	defer utils.Annotate(&err, "NewWASMIndexer")
+
+	sandbox, err := newWasmSandbox(config, "indexer:wasm", "index_node")
+	if err != nil {
+		return nil, err
+	}
+	return &wasmIndexer{sandbox: sandbox}, nil
+}
+
+// Close releases the indexer's WASM runtime.
+func (i *wasmIndexer) Close() error {
+	return i.sandbox.close()
+}
+
+func (i *wasmIndexer) IndexNode(relKeypath tree.Keypath, node tree.Node) (_ tree.Keypath, _ tree.Node, err error) {
+	defer utils.Annotate(&err, "wasmIndexer.IndexNode")
+
+	exists, err := node.Exists(nil)
+	if err != nil {
+		return nil, nil, err
+	} else if !exists {
+		return nil, nil, nil
+	}
+
+	call := &wasmCall{state: node, allowPatches: true}
+	err = i.sandbox.call(call, map[string]interface{}{
+		"keypath": relKeypath.String(),
+	})
+	if err != nil {
+		return nil, nil, err
+	} else if call.indexKey == nil {
+		return nil, nil, nil
+	} else if len(call.patches) == 0 {
+		return call.indexKey, node, nil
+	}
+
+	nodeToIndex := tree.NewMemoryNode()
+	for _, patch := range call.patches {
+		err = patch.Apply(nodeToIndex)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return call.indexKey, nodeToIndex, nil
+}
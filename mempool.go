@@ -1,7 +1,9 @@
 package redwood
 
 import (
+	"sort"
 	"sync"
+	"time"
 
 	"redwood.dev/ctx"
 	"redwood.dev/types"
@@ -12,32 +14,70 @@ type Mempool interface {
 	Start() error
 	Close()
 	Add(tx *Tx)
+	Restore(entries []MempoolEntry)
 	Get() *txSortedSet
+	Entries() []MempoolEntry
+	Stats() MempoolStats
 	ForceReprocess()
 }
 
+// MempoolEntry is a tx waiting in the mempool, along with the time that it was
+// first received.
+type MempoolEntry struct {
+	Tx       *Tx
+	Received time.Time
+}
+
+// MempoolOpts limits how long txs may wait in the mempool and how many may wait
+// at once.  Zero values mean no limit.  When there are too many txs, the oldest
+// are evicted first.
+type MempoolOpts struct {
+	MaxAge time.Duration
+	MaxTxs int
+}
+
+var DefaultMempoolOpts = MempoolOpts{
+	MaxAge: 7 * 24 * time.Hour,
+	MaxTxs: 10000,
+}
+
+type MempoolStats struct {
+	NumTxs     int
+	NumEvicted uint64
+	Oldest     time.Time
+	// The number of txs waiting on each parent that hasn't been received yet
+	MissingParents map[types.ID]int
+	// The number of txs waiting on each parent that is itself in the mempool
+	PendingParents map[types.ID]int
+}
+
 type mempool struct {
 	ctx.Logger
 	chStop chan struct{}
 	chDone chan struct{}
 
 	sync.RWMutex
-	txs   *txSortedSet
-	chAdd chan *Tx
+	txs        *txSortedSet
+	entries    map[types.Hash]MempoolEntry
+	numEvicted uint64
+	opts       MempoolOpts
 
 	processMempoolWorkQueue *utils.Mailbox
 	processCallback         func(tx *Tx) processTxOutcome
+	evictCallback           func(tx *Tx)
 }
 
-func NewMempool(processCallback func(tx *Tx) processTxOutcome) *mempool {
+func NewMempool(processCallback func(tx *Tx) processTxOutcome, evictCallback func(tx *Tx), opts MempoolOpts) *mempool {
 	return &mempool{
 		Logger:                  ctx.NewLogger("mempool"),
 		chStop:                  make(chan struct{}),
 		chDone:                  make(chan struct{}),
 		txs:                     newTxSortedSet(),
-		chAdd:                   make(chan *Tx, 100),
-		processMempoolWorkQueue: utils.NewMailbox(1),
+		entries:                 make(map[types.Hash]MempoolEntry),
+		opts:                    opts,
+		processMempoolWorkQueue: utils.NewMailbox(0),
 		processCallback:         processCallback,
+		evictCallback:           evictCallback,
 	}
 }
 
@@ -54,9 +94,13 @@ func (m *mempool) Start() error {
 					if x == nil {
 						break
 					}
-					switch tx := x.(type) {
-					case *Tx:
-						m.txs.add(tx)
+					switch entry := x.(type) {
+					case MempoolEntry:
+						m.add(entry)
+					case []MempoolEntry:
+						for _, e := range entry {
+							m.add(e)
+						}
 					case struct{}:
 					}
 				}
@@ -78,8 +122,74 @@ func (m *mempool) Get() *txSortedSet {
 	return m.txs.copy()
 }
 
+func (m *mempool) Entries() []MempoolEntry {
+	m.RLock()
+	defer m.RUnlock()
+
+	entries := make([]MempoolEntry, 0, len(m.entries))
+	for _, entry := range m.entries {
+		entries = append(entries, MempoolEntry{Tx: entry.Tx.Copy(), Received: entry.Received})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Received.Before(entries[j].Received) })
+	return entries
+}
+
+// Stats reports on the txs in the mempool.  Because the mempool can't tell
+// whether a parent that it doesn't contain has been received, MissingParents
+// is left empty.
+func (m *mempool) Stats() MempoolStats {
+	m.RLock()
+	defer m.RUnlock()
+
+	stats := MempoolStats{
+		NumTxs:         len(m.entries),
+		NumEvicted:     m.numEvicted,
+		MissingParents: make(map[types.ID]int),
+		PendingParents: make(map[types.ID]int),
+	}
+
+	inMempool := make(map[types.ID]struct{}, len(m.entries))
+	for _, entry := range m.entries {
+		inMempool[entry.Tx.ID] = struct{}{}
+		if stats.Oldest.IsZero() || entry.Received.Before(stats.Oldest) {
+			stats.Oldest = entry.Received
+		}
+	}
+	for _, entry := range m.entries {
+		for _, parentID := range entry.Tx.Parents {
+			if _, exists := inMempool[parentID]; exists {
+				stats.PendingParents[parentID]++
+			}
+		}
+	}
+	return stats
+}
+
 func (m *mempool) Add(tx *Tx) {
-	m.processMempoolWorkQueue.Deliver(tx)
+	m.processMempoolWorkQueue.Deliver(MempoolEntry{Tx: tx, Received: time.Now()})
+}
+
+// Restore re-adds txs that were waiting in the mempool before a restart,
+// preserving the time that they were first received.
+func (m *mempool) Restore(entries []MempoolEntry) {
+	m.processMempoolWorkQueue.Deliver(entries)
+}
+
+func (m *mempool) add(entry MempoolEntry) {
+	m.Lock()
+	defer m.Unlock()
+
+	hash := entry.Tx.Hash()
+	if _, exists := m.entries[hash]; !exists {
+		m.entries[hash] = MempoolEntry{Tx: entry.Tx.Copy(), Received: entry.Received}
+	}
+	m.txs.add(entry.Tx)
+}
+
+func (m *mempool) remove(tx *Tx) {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.entries, tx.Hash())
 }
 
 func (m *mempool) ForceReprocess() {
@@ -120,6 +230,7 @@ func (m *mempool) processMempool() {
 			switch outcome {
 			case processTxOutcome_Failed:
 				// Discard it
+				m.remove(tx)
 
 			case processTxOutcome_Retry:
 				// Leave it in the mempool
@@ -127,6 +238,7 @@ func (m *mempool) processMempool() {
 
 			case processTxOutcome_Succeeded:
 				anySucceeded = true
+				m.remove(tx)
 
 			default:
 				panic("this should never happen")
@@ -137,11 +249,63 @@ func (m *mempool) processMempool() {
 			break
 		}
 	}
+	retry = m.evict(retry)
 	if len(retry) > 0 {
 		m.txs.addMany(retry)
 	}
 }
 
+// evict removes txs that have been waiting longer than the configured maximum
+// age, and then the oldest txs until the mempool is within its maximum size.
+// It returns the txs that remain.
+func (m *mempool) evict(txs []*Tx) []*Tx {
+	m.Lock()
+
+	now := time.Now()
+	evict := make(map[types.Hash]struct{})
+	if m.opts.MaxAge > 0 {
+		for _, tx := range txs {
+			if now.Sub(m.entries[tx.Hash()].Received) > m.opts.MaxAge {
+				evict[tx.Hash()] = struct{}{}
+			}
+		}
+	}
+	if m.opts.MaxTxs > 0 && len(txs)-len(evict) > m.opts.MaxTxs {
+		byAge := make([]*Tx, 0, len(txs))
+		for _, tx := range txs {
+			if _, evicted := evict[tx.Hash()]; !evicted {
+				byAge = append(byAge, tx)
+			}
+		}
+		sort.SliceStable(byAge, func(i, j int) bool {
+			return m.entries[byAge[i].Hash()].Received.Before(m.entries[byAge[j].Hash()].Received)
+		})
+		for _, tx := range byAge[:len(byAge)-m.opts.MaxTxs] {
+			evict[tx.Hash()] = struct{}{}
+		}
+	}
+
+	var remaining, evicted []*Tx
+	for _, tx := range txs {
+		if _, exists := evict[tx.Hash()]; exists {
+			evicted = append(evicted, tx)
+			delete(m.entries, tx.Hash())
+			m.numEvicted++
+		} else {
+			remaining = append(remaining, tx)
+		}
+	}
+	m.Unlock()
+
+	for _, tx := range evicted {
+		m.Warnf("evicting tx %v from mempool", tx.ID.Pretty())
+		if m.evictCallback != nil {
+			m.evictCallback(tx)
+		}
+	}
+	return remaining
+}
+
 type txSortedSet struct {
 	sync.RWMutex
 	txs   map[types.Hash]*Tx
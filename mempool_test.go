@@ -0,0 +1,120 @@
+package redwood
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"redwood.dev/types"
+)
+
+func setupTxStore(t *testing.T) (TxStore, func()) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "txstore-test-")
+	require.NoError(t, err)
+
+	txStore := NewBadgerTxStore(dir)
+	err = txStore.Start()
+	require.NoError(t, err)
+
+	return txStore, func() {
+		txStore.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestBadgerTxStore_MempoolTxs(t *testing.T) {
+	txStore, cleanup := setupTxStore(t)
+	defer cleanup()
+
+	stateURI := "foo.com/bar"
+	tx1 := &Tx{ID: types.RandomID(), StateURI: stateURI, Parents: []types.ID{GenesisTxID}, Status: TxStatusInMempool}
+	tx2 := &Tx{ID: types.RandomID(), StateURI: stateURI, Parents: []types.ID{tx1.ID}, Status: TxStatusInMempool}
+
+	before := time.Now()
+	require.NoError(t, txStore.AddTx(tx1))
+	require.NoError(t, txStore.AddTx(tx2))
+
+	entries, err := txStore.MempoolTxs(stateURI)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	received := make(map[types.ID]time.Time)
+	for _, entry := range entries {
+		require.False(t, entry.Received.Before(before))
+		received[entry.Tx.ID] = entry.Received
+	}
+
+	// Re-adding a tx that's still in the mempool keeps its original receipt time
+	require.NoError(t, txStore.AddTx(tx2))
+	entries, err = txStore.MempoolTxs(stateURI)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		require.True(t, received[entry.Tx.ID].Equal(entry.Received))
+	}
+
+	// Txs leave the mempool once they've been processed or removed
+	tx2.Status = TxStatusInvalid
+	require.NoError(t, txStore.AddTx(tx2))
+	require.NoError(t, txStore.RemoveTx(stateURI, tx1.ID))
+
+	entries, err = txStore.MempoolTxs(stateURI)
+	require.NoError(t, err)
+	require.Len(t, entries, 0)
+}
+
+func TestMempool_Eviction(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		evicted []types.ID
+	)
+	chEvicted := make(chan struct{}, 10)
+
+	mempool := NewMempool(
+		func(tx *Tx) processTxOutcome { return processTxOutcome_Retry },
+		func(tx *Tx) {
+			mu.Lock()
+			defer mu.Unlock()
+			evicted = append(evicted, tx.ID)
+			chEvicted <- struct{}{}
+		},
+		MempoolOpts{MaxAge: time.Hour, MaxTxs: 2},
+	)
+	require.NoError(t, mempool.Start())
+	defer mempool.Close()
+
+	now := time.Now()
+	stale := &Tx{ID: types.RandomID(), Parents: []types.ID{types.RandomID()}}
+	oldest := &Tx{ID: types.RandomID(), Parents: []types.ID{types.RandomID()}}
+	older := &Tx{ID: types.RandomID(), Parents: []types.ID{oldest.ID}}
+	newest := &Tx{ID: types.RandomID(), Parents: []types.ID{oldest.ID}}
+
+	mempool.Restore([]MempoolEntry{
+		{Tx: stale, Received: now.Add(-2 * time.Hour)},
+		{Tx: oldest, Received: now.Add(-3 * time.Minute)},
+		{Tx: older, Received: now.Add(-2 * time.Minute)},
+		{Tx: newest, Received: now.Add(-1 * time.Minute)},
+	})
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-chEvicted:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for eviction")
+		}
+	}
+
+	mu.Lock()
+	require.ElementsMatch(t, []types.ID{stale.ID, oldest.ID}, evicted)
+	mu.Unlock()
+
+	stats := mempool.Stats()
+	require.Equal(t, 2, stats.NumTxs)
+	require.Equal(t, uint64(2), stats.NumEvicted)
+	require.True(t, stats.Oldest.Equal(now.Add(-2*time.Minute)))
+	require.Len(t, stats.PendingParents, 0)
+}
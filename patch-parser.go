@@ -12,56 +12,146 @@ import (
 
 var ErrBadPatch = errors.New("bad patch string")
 
+// ParsePatch parses a patch in one of these forms:
+//
+//	.a.b[1:2] = <json>       set (with an optional splice range)
+//	.a.b += <number>         increment
+//	delete .a.b[1:2]         delete
+//	move .a.b to .c.d[1:1]   move
+//	copy .a.b to .c.d[1:1]   copy
+//	test .a.b == <json>      reject the tx unless .a.b equals the value
 func ParsePatch(s []byte) (Patch, error) {
-	patch := Patch{}
-
 	s = bytes.TrimSpace(s)
 
+	var op PatchOp
+	if len(s) > 0 && s[0] != '.' && s[0] != '[' && s[0] != '=' && s[0] != '+' {
+		word := s
+		if i := bytes.IndexByte(s, ' '); i > -1 {
+			word = s[:i]
+		}
+		switch PatchOp(word) {
+		case PatchOpDelete, PatchOpMove, PatchOpCopy, PatchOpTest:
+			op = PatchOp(word)
+		default:
+			return Patch{}, errors.WithStack(ErrBadPatch)
+		}
+		s = bytes.TrimSpace(s[len(word):])
+	}
+
+	patch := Patch{Op: op}
+
+	if op == PatchOpMove || op == PatchOpCopy {
+		from, rng, n, err := parsePatchKeypath(s)
+		if err != nil {
+			return Patch{}, err
+		} else if rng != nil {
+			return Patch{}, errors.Wrapf(ErrBadPatch, "can't %v a range", op)
+		}
+		patch.From = from
+
+		s = bytes.TrimSpace(s[n:])
+		if !bytes.HasPrefix(s, []byte("to ")) {
+			return Patch{}, errors.WithStack(ErrBadPatch)
+		}
+		s = bytes.TrimSpace(s[len("to "):])
+	}
+
+	keypath, rng, n, err := parsePatchKeypath(s)
+	if err != nil {
+		return Patch{}, err
+	}
+	patch.Keypath = keypath
+	patch.Range = rng
+	s = bytes.TrimSpace(s[n:])
+
+	var valStr []byte
+	switch op {
+	case PatchOpDelete, PatchOpMove, PatchOpCopy:
+		if len(s) > 0 {
+			return Patch{}, errors.WithStack(ErrBadPatch)
+		}
+		return patch, nil
+
+	case PatchOpTest:
+		if !bytes.HasPrefix(s, []byte("==")) {
+			return Patch{}, errors.WithStack(ErrBadPatch)
+		}
+		valStr = s[len("=="):]
+
+	default:
+		if bytes.HasPrefix(s, []byte("+=")) {
+			if rng != nil {
+				return Patch{}, errors.Wrap(ErrBadPatch, "can't increment a range")
+			}
+			patch.Op = PatchOpIncrement
+			valStr = s[len("+="):]
+		} else if bytes.HasPrefix(s, []byte("=")) {
+			valStr = s[len("="):]
+		} else {
+			return Patch{}, errors.WithStack(ErrBadPatch)
+		}
+	}
+
+	err = json.Unmarshal(valStr, &patch.Val)
+	if err != nil {
+		return Patch{}, errors.Wrapf(ErrBadPatch, err.Error())
+	}
+	if _, is := patch.Val.(float64); patch.Op == PatchOpIncrement && !is {
+		return Patch{}, errors.Wrap(ErrBadPatch, "can only increment by a number")
+	}
+	return patch, nil
+}
+
+// parsePatchKeypath parses the keypath (and optional range) at the start of a
+// patch, and returns the number of bytes it took up.
+func parsePatchKeypath(s []byte) (tree.Keypath, *tree.Range, int, error) {
+	var keypath tree.Keypath
+	var rng *tree.Range
+
 	for i := 0; i < len(s); {
 		switch s[i] {
 		case '.':
 			key, err := parseDotKey(s[i:])
 			if err != nil {
-				return Patch{}, err
+				return nil, nil, 0, err
 			}
-			patch.Keypath = patch.Keypath.Push(key)
+			keypath = keypath.Push(key)
 			i += len(key) + 1
 
 		case '[':
+			if i+1 == len(s) {
+				return nil, nil, 0, errors.WithStack(ErrBadPatch)
+			}
 			switch s[i+1] {
 			case '"', '\'':
 				key, err := parseBracketKey(s[i:])
 				if err != nil {
-					return Patch{}, err
+					return nil, nil, 0, err
 				}
-				patch.Keypath = patch.Keypath.Push(key)
+				keypath = keypath.Push(key)
 				i += len(key) + 4
 
 			case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
-				rng, length, err := parseRange(s[i:])
+				var length int
+				var err error
+				rng, length, err = parseRange(s[i:])
 				if err != nil {
-					return Patch{}, err
+					return nil, nil, 0, err
 				}
-				patch.Range = rng
 				i += length
-			}
 
-		case ' ', '=':
-			for s[i] != '=' {
-				i++
-				if i == len(s) {
-					return Patch{}, ErrBadPatch
-				}
-			}
-			i++
-			err := json.Unmarshal([]byte(s[i:]), &patch.Val)
-			if err != nil {
-				return Patch{}, errors.Wrapf(ErrBadPatch, err.Error())
+			default:
+				return nil, nil, 0, errors.WithStack(ErrBadPatch)
 			}
-			return patch, nil
+
+		case ' ', '=', '+':
+			return keypath, rng, i, nil
+
+		default:
+			return nil, nil, 0, errors.WithStack(ErrBadPatch)
 		}
 	}
-	return Patch{}, errors.WithStack(ErrBadPatch)
+	return keypath, rng, len(s), nil
 }
 
 func ParsePatchPath(s []byte) ([]byte, tree.Keypath, *tree.Range, error) {
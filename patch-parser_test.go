@@ -18,3 +18,49 @@ func TestParsePatch(t *testing.T) {
 	require.Equal(t, int64(0), patch.Range.End)
 	require.Equal(t, "a", patch.Val)
 }
+
+func TestParsePatch_Malformed(t *testing.T) {
+	for _, s := range []string{`text = 1`, `.text[x] = 1`, `.text[`, `.text`} {
+		_, err := ParsePatch([]byte(s))
+		require.Error(t, err, s)
+	}
+}
+
+func TestParsePatch_Ops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Patch
+	}{
+		{`.a.b = null`, Patch{Keypath: tree.Keypath("a/b")}},
+		{`.a += -2.5`, Patch{Op: PatchOpIncrement, Keypath: tree.Keypath("a"), Val: float64(-2.5)}},
+		{`delete .a[1:2]`, Patch{Op: PatchOpDelete, Keypath: tree.Keypath("a"), Range: &tree.Range{Start: 1, End: 2}}},
+		{`move .a.b to .c[0:0]`, Patch{Op: PatchOpMove, Keypath: tree.Keypath("c"), Range: &tree.Range{Start: 0, End: 0}, From: tree.Keypath("a/b")}},
+		{`copy .a to .b`, Patch{Op: PatchOpCopy, Keypath: tree.Keypath("b"), From: tree.Keypath("a")}},
+		{`test .a == {"x": 1}`, Patch{Op: PatchOpTest, Keypath: tree.Keypath("a"), Val: map[string]interface{}{"x": float64(1)}}},
+	}
+
+	for _, test := range tests {
+		patch, err := ParsePatch([]byte(test.input))
+		require.NoError(t, err, test.input)
+		require.Equal(t, test.expected, patch, test.input)
+
+		roundTripped, err := ParsePatch([]byte(patch.String()))
+		require.NoError(t, err, patch.String())
+		require.Equal(t, patch, roundTripped, patch.String())
+	}
+}
+
+func TestParsePatch_MalformedOps(t *testing.T) {
+	for _, s := range []string{
+		`.a += "x"`,
+		`delete .a = 1`,
+		`move .a`,
+		`move .a .b`,
+		`copy .a to`,
+		`test .a = 1`,
+		`bogus .a`,
+	} {
+		_, err := ParsePatch([]byte(s))
+		require.Error(t, err, s)
+	}
+}
@@ -1,3 +1,4 @@
 package pb
 
 //go:generate protoc -I . tx.proto --go_out=.
+//go:generate protoc -I . rpc.grpc.proto --go_out=plugins=grpc:.
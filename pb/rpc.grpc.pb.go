@@ -0,0 +1,2148 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: rpc.grpc.proto
+
+package pb
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type Transport int32
+
+const (
+	Transport_LIBP2P    Transport = 0
+	Transport_HTTPS     Transport = 1
+	Transport_WEBSOCKET Transport = 2
+	Transport_WEBRTC    Transport = 3
+)
+
+var Transport_name = map[int32]string{
+	0: "LIBP2P",
+	1: "HTTPS",
+	2: "WEBSOCKET",
+	3: "WEBRTC",
+}
+
+var Transport_value = map[string]int32{
+	"LIBP2P":    0,
+	"HTTPS":     1,
+	"WEBSOCKET": 2,
+	"WEBRTC":    3,
+}
+
+func (x Transport) String() string {
+	return proto.EnumName(Transport_name, int32(x))
+}
+
+func (Transport) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_0b9c9df842740786, []int{0}
+}
+
+type TxPacket_TxStatus int32
+
+const (
+	TxPacket_UNKNOWN    TxPacket_TxStatus = 0
+	TxPacket_IN_MEMPOOL TxPacket_TxStatus = 1
+	TxPacket_INVALID    TxPacket_TxStatus = 2
+	TxPacket_VALID      TxPacket_TxStatus = 3
+)
+
+var TxPacket_TxStatus_name = map[int32]string{
+	0: "UNKNOWN",
+	1: "IN_MEMPOOL",
+	2: "INVALID",
+	3: "VALID",
+}
+
+var TxPacket_TxStatus_value = map[string]int32{
+	"UNKNOWN":    0,
+	"IN_MEMPOOL": 1,
+	"INVALID":    2,
+	"VALID":      3,
+}
+
+func (x TxPacket_TxStatus) String() string {
+	return proto.EnumName(TxPacket_TxStatus_name, int32(x))
+}
+
+func (TxPacket_TxStatus) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_0b9c9df842740786, []int{9, 0}
+}
+
+type AuthenticateMessage struct {
+	// Types that are valid to be assigned to Payload:
+	//	*AuthenticateMessage_AuthenticateChallenge_
+	//	*AuthenticateMessage_AuthenticateSignature_
+	//	*AuthenticateMessage_AuthenticateResponse_
+	Payload              isAuthenticateMessage_Payload `protobuf_oneof:"payload"`
+	XXX_NoUnkeyedLiteral struct{}                      `json:"-"`
+	XXX_unrecognized     []byte                        `json:"-"`
+	XXX_sizecache        int32                         `json:"-"`
+}
+
+func (m *AuthenticateMessage) Reset()         { *m = AuthenticateMessage{} }
+func (m *AuthenticateMessage) String() string { return proto.CompactTextString(m) }
+func (*AuthenticateMessage) ProtoMessage()    {}
+func (*AuthenticateMessage) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0b9c9df842740786, []int{0}
+}
+
+func (m *AuthenticateMessage) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AuthenticateMessage.Unmarshal(m, b)
+}
+func (m *AuthenticateMessage) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AuthenticateMessage.Marshal(b, m, deterministic)
+}
+func (m *AuthenticateMessage) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AuthenticateMessage.Merge(m, src)
+}
+func (m *AuthenticateMessage) XXX_Size() int {
+	return xxx_messageInfo_AuthenticateMessage.Size(m)
+}
+func (m *AuthenticateMessage) XXX_DiscardUnknown() {
+	xxx_messageInfo_AuthenticateMessage.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AuthenticateMessage proto.InternalMessageInfo
+
+type isAuthenticateMessage_Payload interface {
+	isAuthenticateMessage_Payload()
+}
+
+type AuthenticateMessage_AuthenticateChallenge_ struct {
+	AuthenticateChallenge *AuthenticateMessage_AuthenticateChallenge `protobuf:"bytes,1,opt,name=authenticateChallenge,proto3,oneof"`
+}
+
+type AuthenticateMessage_AuthenticateSignature_ struct {
+	AuthenticateSignature *AuthenticateMessage_AuthenticateSignature `protobuf:"bytes,2,opt,name=authenticateSignature,proto3,oneof"`
+}
+
+type AuthenticateMessage_AuthenticateResponse_ struct {
+	AuthenticateResponse *AuthenticateMessage_AuthenticateResponse `protobuf:"bytes,3,opt,name=authenticateResponse,proto3,oneof"`
+}
+
+func (*AuthenticateMessage_AuthenticateChallenge_) isAuthenticateMessage_Payload() {}
+
+func (*AuthenticateMessage_AuthenticateSignature_) isAuthenticateMessage_Payload() {}
+
+func (*AuthenticateMessage_AuthenticateResponse_) isAuthenticateMessage_Payload() {}
+
+func (m *AuthenticateMessage) GetPayload() isAuthenticateMessage_Payload {
+	if m != nil {
+		return m.Payload
+	}
+	return nil
+}
+
+func (m *AuthenticateMessage) GetAuthenticateChallenge() *AuthenticateMessage_AuthenticateChallenge {
+	if x, ok := m.GetPayload().(*AuthenticateMessage_AuthenticateChallenge_); ok {
+		return x.AuthenticateChallenge
+	}
+	return nil
+}
+
+func (m *AuthenticateMessage) GetAuthenticateSignature() *AuthenticateMessage_AuthenticateSignature {
+	if x, ok := m.GetPayload().(*AuthenticateMessage_AuthenticateSignature_); ok {
+		return x.AuthenticateSignature
+	}
+	return nil
+}
+
+func (m *AuthenticateMessage) GetAuthenticateResponse() *AuthenticateMessage_AuthenticateResponse {
+	if x, ok := m.GetPayload().(*AuthenticateMessage_AuthenticateResponse_); ok {
+		return x.AuthenticateResponse
+	}
+	return nil
+}
+
+// XXX_OneofWrappers is for the internal use of the proto package.
+func (*AuthenticateMessage) XXX_OneofWrappers() []interface{} {
+	return []interface{}{
+		(*AuthenticateMessage_AuthenticateChallenge_)(nil),
+		(*AuthenticateMessage_AuthenticateSignature_)(nil),
+		(*AuthenticateMessage_AuthenticateResponse_)(nil),
+	}
+}
+
+type AuthenticateMessage_AuthenticateChallenge struct {
+	Challenge            []byte   `protobuf:"bytes,1,opt,name=challenge,proto3" json:"challenge,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AuthenticateMessage_AuthenticateChallenge) Reset() {
+	*m = AuthenticateMessage_AuthenticateChallenge{}
+}
+func (m *AuthenticateMessage_AuthenticateChallenge) String() string {
+	return proto.CompactTextString(m)
+}
+func (*AuthenticateMessage_AuthenticateChallenge) ProtoMessage() {}
+func (*AuthenticateMessage_AuthenticateChallenge) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0b9c9df842740786, []int{0, 0}
+}
+
+func (m *AuthenticateMessage_AuthenticateChallenge) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AuthenticateMessage_AuthenticateChallenge.Unmarshal(m, b)
+}
+func (m *AuthenticateMessage_AuthenticateChallenge) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AuthenticateMessage_AuthenticateChallenge.Marshal(b, m, deterministic)
+}
+func (m *AuthenticateMessage_AuthenticateChallenge) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AuthenticateMessage_AuthenticateChallenge.Merge(m, src)
+}
+func (m *AuthenticateMessage_AuthenticateChallenge) XXX_Size() int {
+	return xxx_messageInfo_AuthenticateMessage_AuthenticateChallenge.Size(m)
+}
+func (m *AuthenticateMessage_AuthenticateChallenge) XXX_DiscardUnknown() {
+	xxx_messageInfo_AuthenticateMessage_AuthenticateChallenge.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AuthenticateMessage_AuthenticateChallenge proto.InternalMessageInfo
+
+func (m *AuthenticateMessage_AuthenticateChallenge) GetChallenge() []byte {
+	if m != nil {
+		return m.Challenge
+	}
+	return nil
+}
+
+type AuthenticateMessage_AuthenticateSignature struct {
+	Signature            []byte   `protobuf:"bytes,1,opt,name=signature,proto3" json:"signature,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AuthenticateMessage_AuthenticateSignature) Reset() {
+	*m = AuthenticateMessage_AuthenticateSignature{}
+}
+func (m *AuthenticateMessage_AuthenticateSignature) String() string {
+	return proto.CompactTextString(m)
+}
+func (*AuthenticateMessage_AuthenticateSignature) ProtoMessage() {}
+func (*AuthenticateMessage_AuthenticateSignature) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0b9c9df842740786, []int{0, 1}
+}
+
+func (m *AuthenticateMessage_AuthenticateSignature) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AuthenticateMessage_AuthenticateSignature.Unmarshal(m, b)
+}
+func (m *AuthenticateMessage_AuthenticateSignature) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AuthenticateMessage_AuthenticateSignature.Marshal(b, m, deterministic)
+}
+func (m *AuthenticateMessage_AuthenticateSignature) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AuthenticateMessage_AuthenticateSignature.Merge(m, src)
+}
+func (m *AuthenticateMessage_AuthenticateSignature) XXX_Size() int {
+	return xxx_messageInfo_AuthenticateMessage_AuthenticateSignature.Size(m)
+}
+func (m *AuthenticateMessage_AuthenticateSignature) XXX_DiscardUnknown() {
+	xxx_messageInfo_AuthenticateMessage_AuthenticateSignature.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AuthenticateMessage_AuthenticateSignature proto.InternalMessageInfo
+
+func (m *AuthenticateMessage_AuthenticateSignature) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+type AuthenticateMessage_AuthenticateResponse struct {
+	Jwt                  string   `protobuf:"bytes,1,opt,name=jwt,proto3" json:"jwt,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AuthenticateMessage_AuthenticateResponse) Reset() {
+	*m = AuthenticateMessage_AuthenticateResponse{}
+}
+func (m *AuthenticateMessage_AuthenticateResponse) String() string { return proto.CompactTextString(m) }
+func (*AuthenticateMessage_AuthenticateResponse) ProtoMessage()    {}
+func (*AuthenticateMessage_AuthenticateResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0b9c9df842740786, []int{0, 2}
+}
+
+func (m *AuthenticateMessage_AuthenticateResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AuthenticateMessage_AuthenticateResponse.Unmarshal(m, b)
+}
+func (m *AuthenticateMessage_AuthenticateResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AuthenticateMessage_AuthenticateResponse.Marshal(b, m, deterministic)
+}
+func (m *AuthenticateMessage_AuthenticateResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AuthenticateMessage_AuthenticateResponse.Merge(m, src)
+}
+func (m *AuthenticateMessage_AuthenticateResponse) XXX_Size() int {
+	return xxx_messageInfo_AuthenticateMessage_AuthenticateResponse.Size(m)
+}
+func (m *AuthenticateMessage_AuthenticateResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_AuthenticateMessage_AuthenticateResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AuthenticateMessage_AuthenticateResponse proto.InternalMessageInfo
+
+func (m *AuthenticateMessage_AuthenticateResponse) GetJwt() string {
+	if m != nil {
+		return m.Jwt
+	}
+	return ""
+}
+
+type SetHDMnemonicReq struct {
+	Mnemonic             string   `protobuf:"bytes,1,opt,name=mnemonic,proto3" json:"mnemonic,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SetHDMnemonicReq) Reset()         { *m = SetHDMnemonicReq{} }
+func (m *SetHDMnemonicReq) String() string { return proto.CompactTextString(m) }
+func (*SetHDMnemonicReq) ProtoMessage()    {}
+func (*SetHDMnemonicReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0b9c9df842740786, []int{1}
+}
+
+func (m *SetHDMnemonicReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetHDMnemonicReq.Unmarshal(m, b)
+}
+func (m *SetHDMnemonicReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetHDMnemonicReq.Marshal(b, m, deterministic)
+}
+func (m *SetHDMnemonicReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetHDMnemonicReq.Merge(m, src)
+}
+func (m *SetHDMnemonicReq) XXX_Size() int {
+	return xxx_messageInfo_SetHDMnemonicReq.Size(m)
+}
+func (m *SetHDMnemonicReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetHDMnemonicReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetHDMnemonicReq proto.InternalMessageInfo
+
+func (m *SetHDMnemonicReq) GetMnemonic() string {
+	if m != nil {
+		return m.Mnemonic
+	}
+	return ""
+}
+
+type SetHDMnemonicResp struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SetHDMnemonicResp) Reset()         { *m = SetHDMnemonicResp{} }
+func (m *SetHDMnemonicResp) String() string { return proto.CompactTextString(m) }
+func (*SetHDMnemonicResp) ProtoMessage()    {}
+func (*SetHDMnemonicResp) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0b9c9df842740786, []int{2}
+}
+
+func (m *SetHDMnemonicResp) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetHDMnemonicResp.Unmarshal(m, b)
+}
+func (m *SetHDMnemonicResp) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetHDMnemonicResp.Marshal(b, m, deterministic)
+}
+func (m *SetHDMnemonicResp) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetHDMnemonicResp.Merge(m, src)
+}
+func (m *SetHDMnemonicResp) XXX_Size() int {
+	return xxx_messageInfo_SetHDMnemonicResp.Size(m)
+}
+func (m *SetHDMnemonicResp) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetHDMnemonicResp.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetHDMnemonicResp proto.InternalMessageInfo
+
+type SubscribeToStatesReq struct {
+	StateURI             string   `protobuf:"bytes,1,opt,name=stateURI,proto3" json:"stateURI,omitempty"`
+	Keypath              string   `protobuf:"bytes,2,opt,name=keypath,proto3" json:"keypath,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SubscribeToStatesReq) Reset()         { *m = SubscribeToStatesReq{} }
+func (m *SubscribeToStatesReq) String() string { return proto.CompactTextString(m) }
+func (*SubscribeToStatesReq) ProtoMessage()    {}
+func (*SubscribeToStatesReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0b9c9df842740786, []int{3}
+}
+
+func (m *SubscribeToStatesReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SubscribeToStatesReq.Unmarshal(m, b)
+}
+func (m *SubscribeToStatesReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SubscribeToStatesReq.Marshal(b, m, deterministic)
+}
+func (m *SubscribeToStatesReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SubscribeToStatesReq.Merge(m, src)
+}
+func (m *SubscribeToStatesReq) XXX_Size() int {
+	return xxx_messageInfo_SubscribeToStatesReq.Size(m)
+}
+func (m *SubscribeToStatesReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_SubscribeToStatesReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SubscribeToStatesReq proto.InternalMessageInfo
+
+func (m *SubscribeToStatesReq) GetStateURI() string {
+	if m != nil {
+		return m.StateURI
+	}
+	return ""
+}
+
+func (m *SubscribeToStatesReq) GetKeypath() string {
+	if m != nil {
+		return m.Keypath
+	}
+	return ""
+}
+
+type StatePacket struct {
+	State                []*KeypathValue `protobuf:"bytes,1,rep,name=state,proto3" json:"state,omitempty"`
+	Leaves               [][]byte        `protobuf:"bytes,2,rep,name=leaves,proto3" json:"leaves,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}        `json:"-"`
+	XXX_unrecognized     []byte          `json:"-"`
+	XXX_sizecache        int32           `json:"-"`
+}
+
+func (m *StatePacket) Reset()         { *m = StatePacket{} }
+func (m *StatePacket) String() string { return proto.CompactTextString(m) }
+func (*StatePacket) ProtoMessage()    {}
+func (*StatePacket) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0b9c9df842740786, []int{4}
+}
+
+func (m *StatePacket) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StatePacket.Unmarshal(m, b)
+}
+func (m *StatePacket) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StatePacket.Marshal(b, m, deterministic)
+}
+func (m *StatePacket) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StatePacket.Merge(m, src)
+}
+func (m *StatePacket) XXX_Size() int {
+	return xxx_messageInfo_StatePacket.Size(m)
+}
+func (m *StatePacket) XXX_DiscardUnknown() {
+	xxx_messageInfo_StatePacket.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StatePacket proto.InternalMessageInfo
+
+func (m *StatePacket) GetState() []*KeypathValue {
+	if m != nil {
+		return m.State
+	}
+	return nil
+}
+
+func (m *StatePacket) GetLeaves() [][]byte {
+	if m != nil {
+		return m.Leaves
+	}
+	return nil
+}
+
+type KeypathValue struct {
+	Keypath              string   `protobuf:"bytes,1,opt,name=keypath,proto3" json:"keypath,omitempty"`
+	Value                []byte   `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *KeypathValue) Reset()         { *m = KeypathValue{} }
+func (m *KeypathValue) String() string { return proto.CompactTextString(m) }
+func (*KeypathValue) ProtoMessage()    {}
+func (*KeypathValue) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0b9c9df842740786, []int{5}
+}
+
+func (m *KeypathValue) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_KeypathValue.Unmarshal(m, b)
+}
+func (m *KeypathValue) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_KeypathValue.Marshal(b, m, deterministic)
+}
+func (m *KeypathValue) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_KeypathValue.Merge(m, src)
+}
+func (m *KeypathValue) XXX_Size() int {
+	return xxx_messageInfo_KeypathValue.Size(m)
+}
+func (m *KeypathValue) XXX_DiscardUnknown() {
+	xxx_messageInfo_KeypathValue.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_KeypathValue proto.InternalMessageInfo
+
+func (m *KeypathValue) GetKeypath() string {
+	if m != nil {
+		return m.Keypath
+	}
+	return ""
+}
+
+func (m *KeypathValue) GetValue() []byte {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+type UnsubscribeFromStatesReq struct {
+	StateURI             string   `protobuf:"bytes,1,opt,name=stateURI,proto3" json:"stateURI,omitempty"`
+	Keypath              string   `protobuf:"bytes,2,opt,name=keypath,proto3" json:"keypath,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *UnsubscribeFromStatesReq) Reset()         { *m = UnsubscribeFromStatesReq{} }
+func (m *UnsubscribeFromStatesReq) String() string { return proto.CompactTextString(m) }
+func (*UnsubscribeFromStatesReq) ProtoMessage()    {}
+func (*UnsubscribeFromStatesReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0b9c9df842740786, []int{6}
+}
+
+func (m *UnsubscribeFromStatesReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_UnsubscribeFromStatesReq.Unmarshal(m, b)
+}
+func (m *UnsubscribeFromStatesReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_UnsubscribeFromStatesReq.Marshal(b, m, deterministic)
+}
+func (m *UnsubscribeFromStatesReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_UnsubscribeFromStatesReq.Merge(m, src)
+}
+func (m *UnsubscribeFromStatesReq) XXX_Size() int {
+	return xxx_messageInfo_UnsubscribeFromStatesReq.Size(m)
+}
+func (m *UnsubscribeFromStatesReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_UnsubscribeFromStatesReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_UnsubscribeFromStatesReq proto.InternalMessageInfo
+
+func (m *UnsubscribeFromStatesReq) GetStateURI() string {
+	if m != nil {
+		return m.StateURI
+	}
+	return ""
+}
+
+func (m *UnsubscribeFromStatesReq) GetKeypath() string {
+	if m != nil {
+		return m.Keypath
+	}
+	return ""
+}
+
+type UnsubscribeFromStatesResp struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *UnsubscribeFromStatesResp) Reset()         { *m = UnsubscribeFromStatesResp{} }
+func (m *UnsubscribeFromStatesResp) String() string { return proto.CompactTextString(m) }
+func (*UnsubscribeFromStatesResp) ProtoMessage()    {}
+func (*UnsubscribeFromStatesResp) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0b9c9df842740786, []int{7}
+}
+
+func (m *UnsubscribeFromStatesResp) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_UnsubscribeFromStatesResp.Unmarshal(m, b)
+}
+func (m *UnsubscribeFromStatesResp) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_UnsubscribeFromStatesResp.Marshal(b, m, deterministic)
+}
+func (m *UnsubscribeFromStatesResp) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_UnsubscribeFromStatesResp.Merge(m, src)
+}
+func (m *UnsubscribeFromStatesResp) XXX_Size() int {
+	return xxx_messageInfo_UnsubscribeFromStatesResp.Size(m)
+}
+func (m *UnsubscribeFromStatesResp) XXX_DiscardUnknown() {
+	xxx_messageInfo_UnsubscribeFromStatesResp.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_UnsubscribeFromStatesResp proto.InternalMessageInfo
+
+type SubscribeToTxsReq struct {
+	StateURI             string   `protobuf:"bytes,1,opt,name=stateURI,proto3" json:"stateURI,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SubscribeToTxsReq) Reset()         { *m = SubscribeToTxsReq{} }
+func (m *SubscribeToTxsReq) String() string { return proto.CompactTextString(m) }
+func (*SubscribeToTxsReq) ProtoMessage()    {}
+func (*SubscribeToTxsReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0b9c9df842740786, []int{8}
+}
+
+func (m *SubscribeToTxsReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SubscribeToTxsReq.Unmarshal(m, b)
+}
+func (m *SubscribeToTxsReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SubscribeToTxsReq.Marshal(b, m, deterministic)
+}
+func (m *SubscribeToTxsReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SubscribeToTxsReq.Merge(m, src)
+}
+func (m *SubscribeToTxsReq) XXX_Size() int {
+	return xxx_messageInfo_SubscribeToTxsReq.Size(m)
+}
+func (m *SubscribeToTxsReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_SubscribeToTxsReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SubscribeToTxsReq proto.InternalMessageInfo
+
+func (m *SubscribeToTxsReq) GetStateURI() string {
+	if m != nil {
+		return m.StateURI
+	}
+	return ""
+}
+
+type TxPacket struct {
+	StateURI             string            `protobuf:"bytes,1,opt,name=stateURI,proto3" json:"stateURI,omitempty"`
+	Id                   []byte            `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	Parents              [][]byte          `protobuf:"bytes,3,rep,name=parents,proto3" json:"parents,omitempty"`
+	Children             [][]byte          `protobuf:"bytes,4,rep,name=children,proto3" json:"children,omitempty"`
+	From                 []byte            `protobuf:"bytes,5,opt,name=from,proto3" json:"from,omitempty"`
+	Sig                  []byte            `protobuf:"bytes,6,opt,name=sig,proto3" json:"sig,omitempty"`
+	Patches              []string          `protobuf:"bytes,7,rep,name=patches,proto3" json:"patches,omitempty"`
+	Recipients           [][]byte          `protobuf:"bytes,8,rep,name=recipients,proto3" json:"recipients,omitempty"`
+	Attachment           []byte            `protobuf:"bytes,9,opt,name=attachment,proto3" json:"attachment,omitempty"`
+	Status               TxPacket_TxStatus `protobuf:"varint,10,opt,name=status,proto3,enum=trustedrpc.TxPacket_TxStatus" json:"status,omitempty"`
+	Hash                 []byte            `protobuf:"bytes,11,opt,name=hash,proto3" json:"hash,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *TxPacket) Reset()         { *m = TxPacket{} }
+func (m *TxPacket) String() string { return proto.CompactTextString(m) }
+func (*TxPacket) ProtoMessage()    {}
+func (*TxPacket) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0b9c9df842740786, []int{9}
+}
+
+func (m *TxPacket) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TxPacket.Unmarshal(m, b)
+}
+func (m *TxPacket) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TxPacket.Marshal(b, m, deterministic)
+}
+func (m *TxPacket) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TxPacket.Merge(m, src)
+}
+func (m *TxPacket) XXX_Size() int {
+	return xxx_messageInfo_TxPacket.Size(m)
+}
+func (m *TxPacket) XXX_DiscardUnknown() {
+	xxx_messageInfo_TxPacket.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TxPacket proto.InternalMessageInfo
+
+func (m *TxPacket) GetStateURI() string {
+	if m != nil {
+		return m.StateURI
+	}
+	return ""
+}
+
+func (m *TxPacket) GetId() []byte {
+	if m != nil {
+		return m.Id
+	}
+	return nil
+}
+
+func (m *TxPacket) GetParents() [][]byte {
+	if m != nil {
+		return m.Parents
+	}
+	return nil
+}
+
+func (m *TxPacket) GetChildren() [][]byte {
+	if m != nil {
+		return m.Children
+	}
+	return nil
+}
+
+func (m *TxPacket) GetFrom() []byte {
+	if m != nil {
+		return m.From
+	}
+	return nil
+}
+
+func (m *TxPacket) GetSig() []byte {
+	if m != nil {
+		return m.Sig
+	}
+	return nil
+}
+
+func (m *TxPacket) GetPatches() []string {
+	if m != nil {
+		return m.Patches
+	}
+	return nil
+}
+
+func (m *TxPacket) GetRecipients() [][]byte {
+	if m != nil {
+		return m.Recipients
+	}
+	return nil
+}
+
+func (m *TxPacket) GetAttachment() []byte {
+	if m != nil {
+		return m.Attachment
+	}
+	return nil
+}
+
+func (m *TxPacket) GetStatus() TxPacket_TxStatus {
+	if m != nil {
+		return m.Status
+	}
+	return TxPacket_UNKNOWN
+}
+
+func (m *TxPacket) GetHash() []byte {
+	if m != nil {
+		return m.Hash
+	}
+	return nil
+}
+
+type UnsubscribeFromTxsReq struct {
+	StateURI             string   `protobuf:"bytes,1,opt,name=stateURI,proto3" json:"stateURI,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *UnsubscribeFromTxsReq) Reset()         { *m = UnsubscribeFromTxsReq{} }
+func (m *UnsubscribeFromTxsReq) String() string { return proto.CompactTextString(m) }
+func (*UnsubscribeFromTxsReq) ProtoMessage()    {}
+func (*UnsubscribeFromTxsReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0b9c9df842740786, []int{10}
+}
+
+func (m *UnsubscribeFromTxsReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_UnsubscribeFromTxsReq.Unmarshal(m, b)
+}
+func (m *UnsubscribeFromTxsReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_UnsubscribeFromTxsReq.Marshal(b, m, deterministic)
+}
+func (m *UnsubscribeFromTxsReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_UnsubscribeFromTxsReq.Merge(m, src)
+}
+func (m *UnsubscribeFromTxsReq) XXX_Size() int {
+	return xxx_messageInfo_UnsubscribeFromTxsReq.Size(m)
+}
+func (m *UnsubscribeFromTxsReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_UnsubscribeFromTxsReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_UnsubscribeFromTxsReq proto.InternalMessageInfo
+
+func (m *UnsubscribeFromTxsReq) GetStateURI() string {
+	if m != nil {
+		return m.StateURI
+	}
+	return ""
+}
+
+type UnsubscribeFromTxsResp struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *UnsubscribeFromTxsResp) Reset()         { *m = UnsubscribeFromTxsResp{} }
+func (m *UnsubscribeFromTxsResp) String() string { return proto.CompactTextString(m) }
+func (*UnsubscribeFromTxsResp) ProtoMessage()    {}
+func (*UnsubscribeFromTxsResp) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0b9c9df842740786, []int{11}
+}
+
+func (m *UnsubscribeFromTxsResp) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_UnsubscribeFromTxsResp.Unmarshal(m, b)
+}
+func (m *UnsubscribeFromTxsResp) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_UnsubscribeFromTxsResp.Marshal(b, m, deterministic)
+}
+func (m *UnsubscribeFromTxsResp) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_UnsubscribeFromTxsResp.Merge(m, src)
+}
+func (m *UnsubscribeFromTxsResp) XXX_Size() int {
+	return xxx_messageInfo_UnsubscribeFromTxsResp.Size(m)
+}
+func (m *UnsubscribeFromTxsResp) XXX_DiscardUnknown() {
+	xxx_messageInfo_UnsubscribeFromTxsResp.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_UnsubscribeFromTxsResp proto.InternalMessageInfo
+
+type GetStateReq struct {
+	StateURI             string   `protobuf:"bytes,1,opt,name=stateURI,proto3" json:"stateURI,omitempty"`
+	Keypath              string   `protobuf:"bytes,2,opt,name=keypath,proto3" json:"keypath,omitempty"`
+	Version              []byte   `protobuf:"bytes,3,opt,name=version,proto3" json:"version,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetStateReq) Reset()         { *m = GetStateReq{} }
+func (m *GetStateReq) String() string { return proto.CompactTextString(m) }
+func (*GetStateReq) ProtoMessage()    {}
+func (*GetStateReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0b9c9df842740786, []int{12}
+}
+
+func (m *GetStateReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetStateReq.Unmarshal(m, b)
+}
+func (m *GetStateReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetStateReq.Marshal(b, m, deterministic)
+}
+func (m *GetStateReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetStateReq.Merge(m, src)
+}
+func (m *GetStateReq) XXX_Size() int {
+	return xxx_messageInfo_GetStateReq.Size(m)
+}
+func (m *GetStateReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetStateReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetStateReq proto.InternalMessageInfo
+
+func (m *GetStateReq) GetStateURI() string {
+	if m != nil {
+		return m.StateURI
+	}
+	return ""
+}
+
+func (m *GetStateReq) GetKeypath() string {
+	if m != nil {
+		return m.Keypath
+	}
+	return ""
+}
+
+func (m *GetStateReq) GetVersion() []byte {
+	if m != nil {
+		return m.Version
+	}
+	return nil
+}
+
+type SendTxReq struct {
+	StateURI             string   `protobuf:"bytes,1,opt,name=stateURI,proto3" json:"stateURI,omitempty"`
+	Id                   []byte   `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	Parents              [][]byte `protobuf:"bytes,3,rep,name=parents,proto3" json:"parents,omitempty"`
+	Patches              []string `protobuf:"bytes,4,rep,name=patches,proto3" json:"patches,omitempty"`
+	Attachment           []byte   `protobuf:"bytes,5,opt,name=attachment,proto3" json:"attachment,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SendTxReq) Reset()         { *m = SendTxReq{} }
+func (m *SendTxReq) String() string { return proto.CompactTextString(m) }
+func (*SendTxReq) ProtoMessage()    {}
+func (*SendTxReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0b9c9df842740786, []int{13}
+}
+
+func (m *SendTxReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SendTxReq.Unmarshal(m, b)
+}
+func (m *SendTxReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SendTxReq.Marshal(b, m, deterministic)
+}
+func (m *SendTxReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SendTxReq.Merge(m, src)
+}
+func (m *SendTxReq) XXX_Size() int {
+	return xxx_messageInfo_SendTxReq.Size(m)
+}
+func (m *SendTxReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_SendTxReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SendTxReq proto.InternalMessageInfo
+
+func (m *SendTxReq) GetStateURI() string {
+	if m != nil {
+		return m.StateURI
+	}
+	return ""
+}
+
+func (m *SendTxReq) GetId() []byte {
+	if m != nil {
+		return m.Id
+	}
+	return nil
+}
+
+func (m *SendTxReq) GetParents() [][]byte {
+	if m != nil {
+		return m.Parents
+	}
+	return nil
+}
+
+func (m *SendTxReq) GetPatches() []string {
+	if m != nil {
+		return m.Patches
+	}
+	return nil
+}
+
+func (m *SendTxReq) GetAttachment() []byte {
+	if m != nil {
+		return m.Attachment
+	}
+	return nil
+}
+
+type SendTxResp struct {
+	StateURI             string   `protobuf:"bytes,1,opt,name=stateURI,proto3" json:"stateURI,omitempty"`
+	Id                   []byte   `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SendTxResp) Reset()         { *m = SendTxResp{} }
+func (m *SendTxResp) String() string { return proto.CompactTextString(m) }
+func (*SendTxResp) ProtoMessage()    {}
+func (*SendTxResp) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0b9c9df842740786, []int{14}
+}
+
+func (m *SendTxResp) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SendTxResp.Unmarshal(m, b)
+}
+func (m *SendTxResp) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SendTxResp.Marshal(b, m, deterministic)
+}
+func (m *SendTxResp) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SendTxResp.Merge(m, src)
+}
+func (m *SendTxResp) XXX_Size() int {
+	return xxx_messageInfo_SendTxResp.Size(m)
+}
+func (m *SendTxResp) XXX_DiscardUnknown() {
+	xxx_messageInfo_SendTxResp.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SendTxResp proto.InternalMessageInfo
+
+func (m *SendTxResp) GetStateURI() string {
+	if m != nil {
+		return m.StateURI
+	}
+	return ""
+}
+
+func (m *SendTxResp) GetId() []byte {
+	if m != nil {
+		return m.Id
+	}
+	return nil
+}
+
+type RefPacket struct {
+	Data                 []byte   `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	End                  bool     `protobuf:"varint,2,opt,name=end,proto3" json:"end,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RefPacket) Reset()         { *m = RefPacket{} }
+func (m *RefPacket) String() string { return proto.CompactTextString(m) }
+func (*RefPacket) ProtoMessage()    {}
+func (*RefPacket) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0b9c9df842740786, []int{15}
+}
+
+func (m *RefPacket) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RefPacket.Unmarshal(m, b)
+}
+func (m *RefPacket) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RefPacket.Marshal(b, m, deterministic)
+}
+func (m *RefPacket) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RefPacket.Merge(m, src)
+}
+func (m *RefPacket) XXX_Size() int {
+	return xxx_messageInfo_RefPacket.Size(m)
+}
+func (m *RefPacket) XXX_DiscardUnknown() {
+	xxx_messageInfo_RefPacket.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RefPacket proto.InternalMessageInfo
+
+func (m *RefPacket) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (m *RefPacket) GetEnd() bool {
+	if m != nil {
+		return m.End
+	}
+	return false
+}
+
+type AddRefResp struct {
+	Sha1Hash             []byte   `protobuf:"bytes,1,opt,name=sha1Hash,proto3" json:"sha1Hash,omitempty"`
+	Sha3Hash             []byte   `protobuf:"bytes,2,opt,name=sha3Hash,proto3" json:"sha3Hash,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AddRefResp) Reset()         { *m = AddRefResp{} }
+func (m *AddRefResp) String() string { return proto.CompactTextString(m) }
+func (*AddRefResp) ProtoMessage()    {}
+func (*AddRefResp) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0b9c9df842740786, []int{16}
+}
+
+func (m *AddRefResp) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AddRefResp.Unmarshal(m, b)
+}
+func (m *AddRefResp) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AddRefResp.Marshal(b, m, deterministic)
+}
+func (m *AddRefResp) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AddRefResp.Merge(m, src)
+}
+func (m *AddRefResp) XXX_Size() int {
+	return xxx_messageInfo_AddRefResp.Size(m)
+}
+func (m *AddRefResp) XXX_DiscardUnknown() {
+	xxx_messageInfo_AddRefResp.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AddRefResp proto.InternalMessageInfo
+
+func (m *AddRefResp) GetSha1Hash() []byte {
+	if m != nil {
+		return m.Sha1Hash
+	}
+	return nil
+}
+
+func (m *AddRefResp) GetSha3Hash() []byte {
+	if m != nil {
+		return m.Sha3Hash
+	}
+	return nil
+}
+
+type FetchRefReq struct {
+	// Types that are valid to be assigned to Hash:
+	//	*FetchRefReq_Sha1
+	//	*FetchRefReq_Sha3
+	Hash                 isFetchRefReq_Hash `protobuf_oneof:"hash"`
+	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
+	XXX_unrecognized     []byte             `json:"-"`
+	XXX_sizecache        int32              `json:"-"`
+}
+
+func (m *FetchRefReq) Reset()         { *m = FetchRefReq{} }
+func (m *FetchRefReq) String() string { return proto.CompactTextString(m) }
+func (*FetchRefReq) ProtoMessage()    {}
+func (*FetchRefReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0b9c9df842740786, []int{17}
+}
+
+func (m *FetchRefReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_FetchRefReq.Unmarshal(m, b)
+}
+func (m *FetchRefReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_FetchRefReq.Marshal(b, m, deterministic)
+}
+func (m *FetchRefReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_FetchRefReq.Merge(m, src)
+}
+func (m *FetchRefReq) XXX_Size() int {
+	return xxx_messageInfo_FetchRefReq.Size(m)
+}
+func (m *FetchRefReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_FetchRefReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_FetchRefReq proto.InternalMessageInfo
+
+type isFetchRefReq_Hash interface {
+	isFetchRefReq_Hash()
+}
+
+type FetchRefReq_Sha1 struct {
+	Sha1 []byte `protobuf:"bytes,1,opt,name=sha1,proto3,oneof"`
+}
+
+type FetchRefReq_Sha3 struct {
+	Sha3 []byte `protobuf:"bytes,2,opt,name=sha3,proto3,oneof"`
+}
+
+func (*FetchRefReq_Sha1) isFetchRefReq_Hash() {}
+
+func (*FetchRefReq_Sha3) isFetchRefReq_Hash() {}
+
+func (m *FetchRefReq) GetHash() isFetchRefReq_Hash {
+	if m != nil {
+		return m.Hash
+	}
+	return nil
+}
+
+func (m *FetchRefReq) GetSha1() []byte {
+	if x, ok := m.GetHash().(*FetchRefReq_Sha1); ok {
+		return x.Sha1
+	}
+	return nil
+}
+
+func (m *FetchRefReq) GetSha3() []byte {
+	if x, ok := m.GetHash().(*FetchRefReq_Sha3); ok {
+		return x.Sha3
+	}
+	return nil
+}
+
+// XXX_OneofWrappers is for the internal use of the proto package.
+func (*FetchRefReq) XXX_OneofWrappers() []interface{} {
+	return []interface{}{
+		(*FetchRefReq_Sha1)(nil),
+		(*FetchRefReq_Sha3)(nil),
+	}
+}
+
+type AddPeerReq struct {
+	Transport            Transport `protobuf:"varint,1,opt,name=transport,proto3,enum=trustedrpc.Transport" json:"transport,omitempty"`
+	DialAddrs            []string  `protobuf:"bytes,2,rep,name=dialAddrs,proto3" json:"dialAddrs,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *AddPeerReq) Reset()         { *m = AddPeerReq{} }
+func (m *AddPeerReq) String() string { return proto.CompactTextString(m) }
+func (*AddPeerReq) ProtoMessage()    {}
+func (*AddPeerReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0b9c9df842740786, []int{18}
+}
+
+func (m *AddPeerReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AddPeerReq.Unmarshal(m, b)
+}
+func (m *AddPeerReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AddPeerReq.Marshal(b, m, deterministic)
+}
+func (m *AddPeerReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AddPeerReq.Merge(m, src)
+}
+func (m *AddPeerReq) XXX_Size() int {
+	return xxx_messageInfo_AddPeerReq.Size(m)
+}
+func (m *AddPeerReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_AddPeerReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AddPeerReq proto.InternalMessageInfo
+
+func (m *AddPeerReq) GetTransport() Transport {
+	if m != nil {
+		return m.Transport
+	}
+	return Transport_LIBP2P
+}
+
+func (m *AddPeerReq) GetDialAddrs() []string {
+	if m != nil {
+		return m.DialAddrs
+	}
+	return nil
+}
+
+type AddPeerResp struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AddPeerResp) Reset()         { *m = AddPeerResp{} }
+func (m *AddPeerResp) String() string { return proto.CompactTextString(m) }
+func (*AddPeerResp) ProtoMessage()    {}
+func (*AddPeerResp) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0b9c9df842740786, []int{19}
+}
+
+func (m *AddPeerResp) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AddPeerResp.Unmarshal(m, b)
+}
+func (m *AddPeerResp) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AddPeerResp.Marshal(b, m, deterministic)
+}
+func (m *AddPeerResp) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AddPeerResp.Merge(m, src)
+}
+func (m *AddPeerResp) XXX_Size() int {
+	return xxx_messageInfo_AddPeerResp.Size(m)
+}
+func (m *AddPeerResp) XXX_DiscardUnknown() {
+	xxx_messageInfo_AddPeerResp.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AddPeerResp proto.InternalMessageInfo
+
+type DropPeerReq struct {
+	// Types that are valid to be assigned to Identifier:
+	//	*DropPeerReq_PeerDialInfo_
+	//	*DropPeerReq_Address
+	Identifier           isDropPeerReq_Identifier `protobuf_oneof:"identifier"`
+	XXX_NoUnkeyedLiteral struct{}                 `json:"-"`
+	XXX_unrecognized     []byte                   `json:"-"`
+	XXX_sizecache        int32                    `json:"-"`
+}
+
+func (m *DropPeerReq) Reset()         { *m = DropPeerReq{} }
+func (m *DropPeerReq) String() string { return proto.CompactTextString(m) }
+func (*DropPeerReq) ProtoMessage()    {}
+func (*DropPeerReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0b9c9df842740786, []int{20}
+}
+
+func (m *DropPeerReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DropPeerReq.Unmarshal(m, b)
+}
+func (m *DropPeerReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DropPeerReq.Marshal(b, m, deterministic)
+}
+func (m *DropPeerReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DropPeerReq.Merge(m, src)
+}
+func (m *DropPeerReq) XXX_Size() int {
+	return xxx_messageInfo_DropPeerReq.Size(m)
+}
+func (m *DropPeerReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_DropPeerReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DropPeerReq proto.InternalMessageInfo
+
+type isDropPeerReq_Identifier interface {
+	isDropPeerReq_Identifier()
+}
+
+type DropPeerReq_PeerDialInfo_ struct {
+	PeerDialInfo *DropPeerReq_PeerDialInfo `protobuf:"bytes,1,opt,name=peerDialInfo,proto3,oneof"`
+}
+
+type DropPeerReq_Address struct {
+	Address []byte `protobuf:"bytes,2,opt,name=address,proto3,oneof"`
+}
+
+func (*DropPeerReq_PeerDialInfo_) isDropPeerReq_Identifier() {}
+
+func (*DropPeerReq_Address) isDropPeerReq_Identifier() {}
+
+func (m *DropPeerReq) GetIdentifier() isDropPeerReq_Identifier {
+	if m != nil {
+		return m.Identifier
+	}
+	return nil
+}
+
+func (m *DropPeerReq) GetPeerDialInfo() *DropPeerReq_PeerDialInfo {
+	if x, ok := m.GetIdentifier().(*DropPeerReq_PeerDialInfo_); ok {
+		return x.PeerDialInfo
+	}
+	return nil
+}
+
+func (m *DropPeerReq) GetAddress() []byte {
+	if x, ok := m.GetIdentifier().(*DropPeerReq_Address); ok {
+		return x.Address
+	}
+	return nil
+}
+
+// XXX_OneofWrappers is for the internal use of the proto package.
+func (*DropPeerReq) XXX_OneofWrappers() []interface{} {
+	return []interface{}{
+		(*DropPeerReq_PeerDialInfo_)(nil),
+		(*DropPeerReq_Address)(nil),
+	}
+}
+
+type DropPeerReq_PeerDialInfo struct {
+	Transport            Transport `protobuf:"varint,1,opt,name=transport,proto3,enum=trustedrpc.Transport" json:"transport,omitempty"`
+	DialAddrs            []string  `protobuf:"bytes,2,rep,name=dialAddrs,proto3" json:"dialAddrs,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *DropPeerReq_PeerDialInfo) Reset()         { *m = DropPeerReq_PeerDialInfo{} }
+func (m *DropPeerReq_PeerDialInfo) String() string { return proto.CompactTextString(m) }
+func (*DropPeerReq_PeerDialInfo) ProtoMessage()    {}
+func (*DropPeerReq_PeerDialInfo) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0b9c9df842740786, []int{20, 0}
+}
+
+func (m *DropPeerReq_PeerDialInfo) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DropPeerReq_PeerDialInfo.Unmarshal(m, b)
+}
+func (m *DropPeerReq_PeerDialInfo) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DropPeerReq_PeerDialInfo.Marshal(b, m, deterministic)
+}
+func (m *DropPeerReq_PeerDialInfo) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DropPeerReq_PeerDialInfo.Merge(m, src)
+}
+func (m *DropPeerReq_PeerDialInfo) XXX_Size() int {
+	return xxx_messageInfo_DropPeerReq_PeerDialInfo.Size(m)
+}
+func (m *DropPeerReq_PeerDialInfo) XXX_DiscardUnknown() {
+	xxx_messageInfo_DropPeerReq_PeerDialInfo.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DropPeerReq_PeerDialInfo proto.InternalMessageInfo
+
+func (m *DropPeerReq_PeerDialInfo) GetTransport() Transport {
+	if m != nil {
+		return m.Transport
+	}
+	return Transport_LIBP2P
+}
+
+func (m *DropPeerReq_PeerDialInfo) GetDialAddrs() []string {
+	if m != nil {
+		return m.DialAddrs
+	}
+	return nil
+}
+
+type DropPeerResp struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DropPeerResp) Reset()         { *m = DropPeerResp{} }
+func (m *DropPeerResp) String() string { return proto.CompactTextString(m) }
+func (*DropPeerResp) ProtoMessage()    {}
+func (*DropPeerResp) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0b9c9df842740786, []int{21}
+}
+
+func (m *DropPeerResp) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DropPeerResp.Unmarshal(m, b)
+}
+func (m *DropPeerResp) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DropPeerResp.Marshal(b, m, deterministic)
+}
+func (m *DropPeerResp) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DropPeerResp.Merge(m, src)
+}
+func (m *DropPeerResp) XXX_Size() int {
+	return xxx_messageInfo_DropPeerResp.Size(m)
+}
+func (m *DropPeerResp) XXX_DiscardUnknown() {
+	xxx_messageInfo_DropPeerResp.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DropPeerResp proto.InternalMessageInfo
+
+func init() {
+	proto.RegisterEnum("trustedrpc.Transport", Transport_name, Transport_value)
+	proto.RegisterEnum("trustedrpc.TxPacket_TxStatus", TxPacket_TxStatus_name, TxPacket_TxStatus_value)
+	proto.RegisterType((*AuthenticateMessage)(nil), "trustedrpc.AuthenticateMessage")
+	proto.RegisterType((*AuthenticateMessage_AuthenticateChallenge)(nil), "trustedrpc.AuthenticateMessage.AuthenticateChallenge")
+	proto.RegisterType((*AuthenticateMessage_AuthenticateSignature)(nil), "trustedrpc.AuthenticateMessage.AuthenticateSignature")
+	proto.RegisterType((*AuthenticateMessage_AuthenticateResponse)(nil), "trustedrpc.AuthenticateMessage.AuthenticateResponse")
+	proto.RegisterType((*SetHDMnemonicReq)(nil), "trustedrpc.SetHDMnemonicReq")
+	proto.RegisterType((*SetHDMnemonicResp)(nil), "trustedrpc.SetHDMnemonicResp")
+	proto.RegisterType((*SubscribeToStatesReq)(nil), "trustedrpc.SubscribeToStatesReq")
+	proto.RegisterType((*StatePacket)(nil), "trustedrpc.StatePacket")
+	proto.RegisterType((*KeypathValue)(nil), "trustedrpc.KeypathValue")
+	proto.RegisterType((*UnsubscribeFromStatesReq)(nil), "trustedrpc.UnsubscribeFromStatesReq")
+	proto.RegisterType((*UnsubscribeFromStatesResp)(nil), "trustedrpc.UnsubscribeFromStatesResp")
+	proto.RegisterType((*SubscribeToTxsReq)(nil), "trustedrpc.SubscribeToTxsReq")
+	proto.RegisterType((*TxPacket)(nil), "trustedrpc.TxPacket")
+	proto.RegisterType((*UnsubscribeFromTxsReq)(nil), "trustedrpc.UnsubscribeFromTxsReq")
+	proto.RegisterType((*UnsubscribeFromTxsResp)(nil), "trustedrpc.UnsubscribeFromTxsResp")
+	proto.RegisterType((*GetStateReq)(nil), "trustedrpc.GetStateReq")
+	proto.RegisterType((*SendTxReq)(nil), "trustedrpc.SendTxReq")
+	proto.RegisterType((*SendTxResp)(nil), "trustedrpc.SendTxResp")
+	proto.RegisterType((*RefPacket)(nil), "trustedrpc.RefPacket")
+	proto.RegisterType((*AddRefResp)(nil), "trustedrpc.AddRefResp")
+	proto.RegisterType((*FetchRefReq)(nil), "trustedrpc.FetchRefReq")
+	proto.RegisterType((*AddPeerReq)(nil), "trustedrpc.AddPeerReq")
+	proto.RegisterType((*AddPeerResp)(nil), "trustedrpc.AddPeerResp")
+	proto.RegisterType((*DropPeerReq)(nil), "trustedrpc.DropPeerReq")
+	proto.RegisterType((*DropPeerReq_PeerDialInfo)(nil), "trustedrpc.DropPeerReq.PeerDialInfo")
+	proto.RegisterType((*DropPeerResp)(nil), "trustedrpc.DropPeerResp")
+}
+
+func init() { proto.RegisterFile("rpc.grpc.proto", fileDescriptor_0b9c9df842740786) }
+
+var fileDescriptor_0b9c9df842740786 = []byte{
+	// 1148 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x57, 0x5b, 0x6f, 0xe2, 0xc6,
+	0x17, 0xc7, 0x40, 0x00, 0x1f, 0x58, 0xc4, 0xce, 0x92, 0x5d, 0xff, 0xfd, 0x4f, 0x5a, 0x6a, 0xb5,
+	0x12, 0xea, 0x03, 0xcd, 0x42, 0xa3, 0x5e, 0xd4, 0x6d, 0x05, 0x49, 0x76, 0xa1, 0xb9, 0x21, 0xe3,
+	0x6c, 0xa4, 0x56, 0xd5, 0x6a, 0x62, 0x4f, 0x82, 0x77, 0xc1, 0x9e, 0xf5, 0x98, 0x34, 0xfb, 0xda,
+	0xd7, 0x7e, 0xcf, 0x7e, 0x86, 0xf6, 0xad, 0x9a, 0xf1, 0x05, 0x9b, 0x38, 0xb7, 0x56, 0x7d, 0x89,
+	0xe6, 0xdc, 0x7e, 0xe7, 0xcc, 0x99, 0xe3, 0xdf, 0x09, 0x50, 0xf7, 0xa8, 0xd9, 0xb9, 0xe0, 0x7f,
+	0xa8, 0xe7, 0xfa, 0x2e, 0x02, 0xdf, 0x5b, 0x30, 0x9f, 0x58, 0x1e, 0x35, 0xb5, 0xdf, 0x8a, 0xf0,
+	0xa4, 0xbf, 0xf0, 0xa7, 0xc4, 0xf1, 0x6d, 0x13, 0xfb, 0xe4, 0x90, 0x30, 0x86, 0x2f, 0x08, 0x9a,
+	0xc3, 0x3a, 0x4e, 0xa8, 0x77, 0xa6, 0x78, 0x36, 0x23, 0xce, 0x05, 0x51, 0xa4, 0x96, 0xd4, 0xae,
+	0x76, 0xb7, 0x3b, 0x4b, 0x8c, 0x4e, 0x46, 0x7c, 0xa7, 0x9f, 0x15, 0x3c, 0xcc, 0xe9, 0xd9, 0xa8,
+	0xab, 0xe9, 0x26, 0xf6, 0x85, 0x83, 0xfd, 0x85, 0x47, 0x94, 0xfc, 0xc3, 0xd3, 0xc5, 0xc1, 0xab,
+	0xe9, 0x62, 0x03, 0x7a, 0x0b, 0xcd, 0xa4, 0x41, 0x27, 0x8c, 0xba, 0x0e, 0x23, 0x4a, 0x41, 0x64,
+	0xfb, 0xf2, 0x21, 0xd9, 0xa2, 0xd8, 0x61, 0x4e, 0xcf, 0xc4, 0x54, 0xb7, 0x61, 0x3d, 0xb3, 0x19,
+	0x68, 0x03, 0x64, 0x33, 0xd5, 0xd6, 0x9a, 0xbe, 0x54, 0xac, 0x86, 0x2d, 0x6b, 0xdf, 0x00, 0x99,
+	0xc5, 0xed, 0x09, 0xc3, 0x62, 0x85, 0xda, 0x86, 0x66, 0x56, 0x75, 0xa8, 0x01, 0x85, 0xb7, 0xbf,
+	0xfa, 0xc2, 0x5f, 0xd6, 0xf9, 0x71, 0x20, 0x43, 0x99, 0xe2, 0x0f, 0x33, 0x17, 0x5b, 0x5a, 0x07,
+	0x1a, 0x13, 0xe2, 0x0f, 0x77, 0x0f, 0x1d, 0x32, 0x77, 0x1d, 0xdb, 0xd4, 0xc9, 0x7b, 0xa4, 0x42,
+	0x65, 0x1e, 0x8a, 0x61, 0x54, 0x2c, 0x6b, 0x4f, 0xe0, 0xf1, 0x8a, 0x3f, 0xa3, 0xda, 0x01, 0x34,
+	0x27, 0x8b, 0x33, 0x66, 0x7a, 0xf6, 0x19, 0x31, 0xdc, 0x89, 0x8f, 0x7d, 0xc2, 0x42, 0x20, 0xc6,
+	0x85, 0x13, 0x7d, 0x14, 0x01, 0x45, 0x32, 0x52, 0xa0, 0xfc, 0x8e, 0x7c, 0xa0, 0xd8, 0x9f, 0x8a,
+	0x87, 0x96, 0xf5, 0x48, 0xd4, 0x4e, 0xa0, 0x2a, 0x20, 0xc6, 0xd8, 0x7c, 0x47, 0x7c, 0xd4, 0x81,
+	0x35, 0x11, 0xa4, 0x48, 0xad, 0x42, 0xbb, 0xda, 0x55, 0x92, 0x2f, 0xb4, 0x1f, 0x84, 0xbc, 0xc6,
+	0xb3, 0x05, 0xd1, 0x03, 0x37, 0xf4, 0x14, 0x4a, 0x33, 0x82, 0x2f, 0x09, 0x53, 0xf2, 0xad, 0x42,
+	0xbb, 0xa6, 0x87, 0x92, 0xf6, 0x3d, 0xd4, 0x92, 0xee, 0xc9, 0x02, 0xa4, 0x54, 0x01, 0xa8, 0x09,
+	0x6b, 0x97, 0xdc, 0x45, 0x14, 0x56, 0xd3, 0x03, 0x41, 0x1b, 0x83, 0x72, 0xe2, 0xb0, 0xe8, 0x9a,
+	0x2f, 0x3d, 0x77, 0xfe, 0x6f, 0x2f, 0xfa, 0x7f, 0xf8, 0xdf, 0x0d, 0x88, 0x8c, 0x6a, 0x5f, 0xc0,
+	0xe3, 0x44, 0x4f, 0x8d, 0xab, 0xbb, 0xf2, 0x68, 0x7f, 0xe6, 0xa1, 0x62, 0x5c, 0x85, 0x4d, 0xbb,
+	0xad, 0xa0, 0x3a, 0xe4, 0x6d, 0x2b, 0xbc, 0x5b, 0xde, 0xb6, 0x78, 0x81, 0x14, 0x7b, 0xc4, 0xf1,
+	0x99, 0x52, 0x10, 0x1d, 0x8b, 0x44, 0x8e, 0x62, 0x4e, 0xed, 0x99, 0xe5, 0x11, 0x47, 0x29, 0x0a,
+	0x53, 0x2c, 0x23, 0x04, 0xc5, 0x73, 0xcf, 0x9d, 0x2b, 0x6b, 0x02, 0x47, 0x9c, 0xf9, 0xa4, 0x31,
+	0xfb, 0x42, 0x29, 0x09, 0x15, 0x3f, 0x06, 0xd8, 0xbe, 0x39, 0x25, 0x4c, 0x29, 0xb7, 0x0a, 0xfc,
+	0xf2, 0xa1, 0x88, 0x3e, 0x02, 0xf0, 0x88, 0x69, 0x53, 0x5b, 0x24, 0xae, 0x08, 0xf4, 0x84, 0x86,
+	0xdb, 0xb1, 0xef, 0x63, 0x73, 0x3a, 0x27, 0x8e, 0xaf, 0xc8, 0x02, 0x32, 0xa1, 0x41, 0xdb, 0x50,
+	0xe2, 0x37, 0x5a, 0x30, 0x05, 0x5a, 0x52, 0xbb, 0xde, 0xdd, 0x4c, 0xce, 0x45, 0xd4, 0x87, 0x8e,
+	0x71, 0x35, 0x11, 0x4e, 0x7a, 0xe8, 0xcc, 0xcb, 0x9e, 0x62, 0x36, 0x55, 0xaa, 0x41, 0xd9, 0xfc,
+	0xac, 0xfd, 0x00, 0x95, 0xc8, 0x0f, 0x55, 0xa1, 0x7c, 0x72, 0xb4, 0x7f, 0x74, 0x7c, 0x7a, 0xd4,
+	0xc8, 0xa1, 0x3a, 0xc0, 0xe8, 0xe8, 0xcd, 0xe1, 0xde, 0xe1, 0xf8, 0xf8, 0xf8, 0xa0, 0x21, 0x71,
+	0xe3, 0xe8, 0xe8, 0x75, 0xff, 0x60, 0xb4, 0xdb, 0xc8, 0x23, 0x19, 0xd6, 0x82, 0x63, 0x41, 0xeb,
+	0xc1, 0xfa, 0xca, 0x43, 0xde, 0xe3, 0xbd, 0x14, 0x78, 0x9a, 0x15, 0xc4, 0xa8, 0xf6, 0x0b, 0x54,
+	0x5f, 0x11, 0x5f, 0xcc, 0xc2, 0x3f, 0x1e, 0x2e, 0x6e, 0xb9, 0x24, 0x1e, 0xb3, 0x5d, 0x47, 0x50,
+	0x5b, 0x4d, 0x8f, 0x44, 0xed, 0x77, 0x09, 0xe4, 0x09, 0x71, 0x2c, 0xe3, 0xea, 0x2e, 0xf4, 0xfb,
+	0x4f, 0x4a, 0xe2, 0x9d, 0x8b, 0xd7, 0xde, 0x39, 0xf1, 0x8e, 0x6b, 0xab, 0xef, 0xa8, 0x7d, 0x0d,
+	0x10, 0x15, 0xc3, 0xe8, 0x43, 0xaa, 0xd1, 0x9e, 0x83, 0xac, 0x93, 0xf3, 0x70, 0xe0, 0x11, 0x14,
+	0x2d, 0xec, 0xe3, 0x90, 0x15, 0xc5, 0x99, 0x8f, 0x23, 0x71, 0x82, 0x88, 0x8a, 0xce, 0x8f, 0xda,
+	0x2e, 0x40, 0xdf, 0xb2, 0x74, 0x72, 0x1e, 0x27, 0x9b, 0xe2, 0xe7, 0x43, 0x3e, 0x0f, 0x41, 0x5c,
+	0x2c, 0x87, 0xb6, 0x9e, 0xb0, 0xe5, 0x63, 0x9b, 0x90, 0xb5, 0x3e, 0x54, 0x5f, 0x12, 0xdf, 0x9c,
+	0x0a, 0x9c, 0xf7, 0xa8, 0x09, 0x45, 0x1e, 0x16, 0x40, 0x0c, 0x73, 0xba, 0x90, 0x42, 0x6d, 0x4f,
+	0xc9, 0x27, 0xb4, 0xbd, 0x41, 0x29, 0x18, 0x3f, 0xed, 0x8d, 0x28, 0x64, 0x4c, 0x88, 0xc7, 0x11,
+	0x7a, 0x20, 0xfb, 0x1e, 0x76, 0x18, 0x75, 0xbd, 0x80, 0xa7, 0xeb, 0xdd, 0xf5, 0xd4, 0x38, 0x47,
+	0x46, 0x7d, 0xe9, 0xc7, 0x97, 0x81, 0x65, 0xe3, 0x59, 0xdf, 0xb2, 0xbc, 0x80, 0xea, 0x64, 0x7d,
+	0xa9, 0xd0, 0x1e, 0x41, 0x35, 0x4e, 0xc0, 0xa8, 0xf6, 0x87, 0x04, 0xd5, 0x5d, 0xcf, 0xa5, 0x51,
+	0xc6, 0x1f, 0xa1, 0x46, 0x09, 0xf1, 0x76, 0x6d, 0x3c, 0x1b, 0x39, 0xe7, 0x6e, 0xb8, 0xda, 0x3f,
+	0x4d, 0x26, 0x4d, 0xb8, 0x77, 0xc6, 0x09, 0xdf, 0x61, 0x4e, 0x4f, 0xc5, 0x22, 0x15, 0xca, 0xd8,
+	0xb2, 0x3c, 0xc2, 0x58, 0x7c, 0xd9, 0x48, 0xa1, 0x62, 0xa8, 0x25, 0x63, 0xff, 0x83, 0x9b, 0x0e,
+	0x6a, 0x00, 0xb6, 0xc5, 0x97, 0xde, 0xb9, 0x4d, 0x3c, 0xad, 0x0e, 0xb5, 0x65, 0xe1, 0x8c, 0x7e,
+	0xfe, 0x02, 0xe4, 0x18, 0x13, 0x01, 0x94, 0x0e, 0x46, 0x83, 0x71, 0x77, 0xdc, 0xc8, 0xf1, 0xcf,
+	0x77, 0x68, 0x18, 0xe3, 0x49, 0x43, 0x42, 0x8f, 0x40, 0x3e, 0xdd, 0x1b, 0x4c, 0x8e, 0x77, 0xf6,
+	0xf7, 0x8c, 0x46, 0x9e, 0x7b, 0x9d, 0xee, 0x0d, 0x74, 0x63, 0xa7, 0x51, 0xe8, 0xfe, 0x55, 0x02,
+	0x30, 0x82, 0xf2, 0xf4, 0xf1, 0x0e, 0x32, 0xa0, 0x96, 0x5c, 0xb1, 0xe8, 0xe3, 0x3b, 0xfe, 0x5d,
+	0x50, 0xef, 0x72, 0x68, 0x4b, 0x5b, 0x12, 0x3a, 0x80, 0x47, 0xa9, 0x9d, 0x8a, 0x36, 0x92, 0x51,
+	0xab, 0xeb, 0x59, 0xdd, 0xbc, 0xc5, 0xca, 0x28, 0x1a, 0xa7, 0x16, 0x47, 0xb0, 0x51, 0x50, 0x2b,
+	0x15, 0x93, 0xb1, 0xab, 0xd5, 0x67, 0x29, 0x8f, 0xe5, 0xfe, 0xdd, 0x92, 0x90, 0x75, 0x8d, 0xde,
+	0x42, 0xd4, 0xd4, 0xbc, 0xdc, 0xb4, 0x1c, 0xd5, 0xcf, 0xee, 0xe1, 0xc5, 0x28, 0x7a, 0x05, 0xf5,
+	0xf4, 0xc2, 0x43, 0x9b, 0x37, 0x14, 0x1d, 0x90, 0xab, 0xda, 0xcc, 0x62, 0xfc, 0x2d, 0x09, 0xfd,
+	0x0c, 0xe8, 0x3a, 0xb1, 0xa2, 0x4f, 0x6e, 0xa9, 0x22, 0x04, 0xd4, 0xee, 0x72, 0x61, 0x14, 0x7d,
+	0x07, 0x95, 0x88, 0x9b, 0x51, 0xaa, 0x65, 0x09, 0xc6, 0xbe, 0xb1, 0x97, 0xe8, 0x2b, 0x28, 0x05,
+	0x64, 0x87, 0x52, 0x53, 0x1f, 0xb3, 0xb1, 0xfa, 0x34, 0x4b, 0xcd, 0x28, 0xfa, 0x06, 0x4a, 0x01,
+	0x71, 0xa5, 0x03, 0x63, 0xfe, 0x4b, 0x07, 0x2e, 0x39, 0xae, 0x2d, 0xf1, 0x8a, 0x23, 0xb6, 0x4a,
+	0x57, 0x9c, 0xe0, 0x30, 0x35, 0x1b, 0x75, 0x4b, 0x42, 0xdf, 0x42, 0x39, 0xe4, 0x11, 0xb4, 0x9a,
+	0x22, 0x24, 0x07, 0xf5, 0x59, 0xa6, 0x9e, 0x51, 0xf4, 0x02, 0x2a, 0xd1, 0xb7, 0x98, 0xce, 0x9c,
+	0xa0, 0x16, 0x55, 0xc9, 0x36, 0x30, 0x3a, 0x28, 0xfe, 0x94, 0xa7, 0x67, 0x67, 0x25, 0xf1, 0xc3,
+	0xa5, 0xf7, 0xf7, 0x00, 0x1d, 0xda, 0x7f, 0xb8, 0xca, 0x0c, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// TrustedRPCClient is the client API for TrustedRPC service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type TrustedRPCClient interface {
+	//
+	// Fundamental primitives
+	//
+	Authenticate(ctx context.Context, opts ...grpc.CallOption) (TrustedRPC_AuthenticateClient, error)
+	SetHDMnemonic(ctx context.Context, in *SetHDMnemonicReq, opts ...grpc.CallOption) (*SetHDMnemonicResp, error)
+	SubscribeToStates(ctx context.Context, in *SubscribeToStatesReq, opts ...grpc.CallOption) (TrustedRPC_SubscribeToStatesClient, error)
+	UnsubscribeFromStates(ctx context.Context, in *UnsubscribeFromStatesReq, opts ...grpc.CallOption) (*UnsubscribeFromStatesResp, error)
+	SubscribeToTxs(ctx context.Context, in *SubscribeToTxsReq, opts ...grpc.CallOption) (TrustedRPC_SubscribeToTxsClient, error)
+	UnsubscribeFromTxs(ctx context.Context, in *UnsubscribeFromTxsReq, opts ...grpc.CallOption) (*UnsubscribeFromTxsResp, error)
+	GetState(ctx context.Context, in *GetStateReq, opts ...grpc.CallOption) (*StatePacket, error)
+	SendTx(ctx context.Context, in *SendTxReq, opts ...grpc.CallOption) (*SendTxResp, error)
+	AddRef(ctx context.Context, opts ...grpc.CallOption) (TrustedRPC_AddRefClient, error)
+	FetchRef(ctx context.Context, in *FetchRefReq, opts ...grpc.CallOption) (TrustedRPC_FetchRefClient, error)
+	AddPeer(ctx context.Context, in *AddPeerReq, opts ...grpc.CallOption) (*AddPeerResp, error)
+	DropPeer(ctx context.Context, in *DropPeerReq, opts ...grpc.CallOption) (*DropPeerResp, error)
+}
+
+type trustedRPCClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewTrustedRPCClient(cc *grpc.ClientConn) TrustedRPCClient {
+	return &trustedRPCClient{cc}
+}
+
+func (c *trustedRPCClient) Authenticate(ctx context.Context, opts ...grpc.CallOption) (TrustedRPC_AuthenticateClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_TrustedRPC_serviceDesc.Streams[0], "/trustedrpc.TrustedRPC/Authenticate", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &trustedRPCAuthenticateClient{stream}
+	return x, nil
+}
+
+type TrustedRPC_AuthenticateClient interface {
+	Send(*AuthenticateMessage) error
+	Recv() (*AuthenticateMessage, error)
+	grpc.ClientStream
+}
+
+type trustedRPCAuthenticateClient struct {
+	grpc.ClientStream
+}
+
+func (x *trustedRPCAuthenticateClient) Send(m *AuthenticateMessage) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *trustedRPCAuthenticateClient) Recv() (*AuthenticateMessage, error) {
+	m := new(AuthenticateMessage)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *trustedRPCClient) SetHDMnemonic(ctx context.Context, in *SetHDMnemonicReq, opts ...grpc.CallOption) (*SetHDMnemonicResp, error) {
+	out := new(SetHDMnemonicResp)
+	err := c.cc.Invoke(ctx, "/trustedrpc.TrustedRPC/SetHDMnemonic", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trustedRPCClient) SubscribeToStates(ctx context.Context, in *SubscribeToStatesReq, opts ...grpc.CallOption) (TrustedRPC_SubscribeToStatesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_TrustedRPC_serviceDesc.Streams[1], "/trustedrpc.TrustedRPC/SubscribeToStates", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &trustedRPCSubscribeToStatesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TrustedRPC_SubscribeToStatesClient interface {
+	Recv() (*StatePacket, error)
+	grpc.ClientStream
+}
+
+type trustedRPCSubscribeToStatesClient struct {
+	grpc.ClientStream
+}
+
+func (x *trustedRPCSubscribeToStatesClient) Recv() (*StatePacket, error) {
+	m := new(StatePacket)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *trustedRPCClient) UnsubscribeFromStates(ctx context.Context, in *UnsubscribeFromStatesReq, opts ...grpc.CallOption) (*UnsubscribeFromStatesResp, error) {
+	out := new(UnsubscribeFromStatesResp)
+	err := c.cc.Invoke(ctx, "/trustedrpc.TrustedRPC/UnsubscribeFromStates", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trustedRPCClient) SubscribeToTxs(ctx context.Context, in *SubscribeToTxsReq, opts ...grpc.CallOption) (TrustedRPC_SubscribeToTxsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_TrustedRPC_serviceDesc.Streams[2], "/trustedrpc.TrustedRPC/SubscribeToTxs", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &trustedRPCSubscribeToTxsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TrustedRPC_SubscribeToTxsClient interface {
+	Recv() (*TxPacket, error)
+	grpc.ClientStream
+}
+
+type trustedRPCSubscribeToTxsClient struct {
+	grpc.ClientStream
+}
+
+func (x *trustedRPCSubscribeToTxsClient) Recv() (*TxPacket, error) {
+	m := new(TxPacket)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *trustedRPCClient) UnsubscribeFromTxs(ctx context.Context, in *UnsubscribeFromTxsReq, opts ...grpc.CallOption) (*UnsubscribeFromTxsResp, error) {
+	out := new(UnsubscribeFromTxsResp)
+	err := c.cc.Invoke(ctx, "/trustedrpc.TrustedRPC/UnsubscribeFromTxs", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trustedRPCClient) GetState(ctx context.Context, in *GetStateReq, opts ...grpc.CallOption) (*StatePacket, error) {
+	out := new(StatePacket)
+	err := c.cc.Invoke(ctx, "/trustedrpc.TrustedRPC/GetState", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trustedRPCClient) SendTx(ctx context.Context, in *SendTxReq, opts ...grpc.CallOption) (*SendTxResp, error) {
+	out := new(SendTxResp)
+	err := c.cc.Invoke(ctx, "/trustedrpc.TrustedRPC/SendTx", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trustedRPCClient) AddRef(ctx context.Context, opts ...grpc.CallOption) (TrustedRPC_AddRefClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_TrustedRPC_serviceDesc.Streams[3], "/trustedrpc.TrustedRPC/AddRef", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &trustedRPCAddRefClient{stream}
+	return x, nil
+}
+
+type TrustedRPC_AddRefClient interface {
+	Send(*RefPacket) error
+	CloseAndRecv() (*AddRefResp, error)
+	grpc.ClientStream
+}
+
+type trustedRPCAddRefClient struct {
+	grpc.ClientStream
+}
+
+func (x *trustedRPCAddRefClient) Send(m *RefPacket) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *trustedRPCAddRefClient) CloseAndRecv() (*AddRefResp, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(AddRefResp)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *trustedRPCClient) FetchRef(ctx context.Context, in *FetchRefReq, opts ...grpc.CallOption) (TrustedRPC_FetchRefClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_TrustedRPC_serviceDesc.Streams[4], "/trustedrpc.TrustedRPC/FetchRef", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &trustedRPCFetchRefClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TrustedRPC_FetchRefClient interface {
+	Recv() (*RefPacket, error)
+	grpc.ClientStream
+}
+
+type trustedRPCFetchRefClient struct {
+	grpc.ClientStream
+}
+
+func (x *trustedRPCFetchRefClient) Recv() (*RefPacket, error) {
+	m := new(RefPacket)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *trustedRPCClient) AddPeer(ctx context.Context, in *AddPeerReq, opts ...grpc.CallOption) (*AddPeerResp, error) {
+	out := new(AddPeerResp)
+	err := c.cc.Invoke(ctx, "/trustedrpc.TrustedRPC/AddPeer", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trustedRPCClient) DropPeer(ctx context.Context, in *DropPeerReq, opts ...grpc.CallOption) (*DropPeerResp, error) {
+	out := new(DropPeerResp)
+	err := c.cc.Invoke(ctx, "/trustedrpc.TrustedRPC/DropPeer", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// TrustedRPCServer is the server API for TrustedRPC service.
+type TrustedRPCServer interface {
+	//
+	// Fundamental primitives
+	//
+	Authenticate(TrustedRPC_AuthenticateServer) error
+	SetHDMnemonic(context.Context, *SetHDMnemonicReq) (*SetHDMnemonicResp, error)
+	SubscribeToStates(*SubscribeToStatesReq, TrustedRPC_SubscribeToStatesServer) error
+	UnsubscribeFromStates(context.Context, *UnsubscribeFromStatesReq) (*UnsubscribeFromStatesResp, error)
+	SubscribeToTxs(*SubscribeToTxsReq, TrustedRPC_SubscribeToTxsServer) error
+	UnsubscribeFromTxs(context.Context, *UnsubscribeFromTxsReq) (*UnsubscribeFromTxsResp, error)
+	GetState(context.Context, *GetStateReq) (*StatePacket, error)
+	SendTx(context.Context, *SendTxReq) (*SendTxResp, error)
+	AddRef(TrustedRPC_AddRefServer) error
+	FetchRef(*FetchRefReq, TrustedRPC_FetchRefServer) error
+	AddPeer(context.Context, *AddPeerReq) (*AddPeerResp, error)
+	DropPeer(context.Context, *DropPeerReq) (*DropPeerResp, error)
+}
+
+// UnimplementedTrustedRPCServer can be embedded to have forward compatible implementations.
+type UnimplementedTrustedRPCServer struct {
+}
+
+func (*UnimplementedTrustedRPCServer) Authenticate(srv TrustedRPC_AuthenticateServer) error {
+	return status.Errorf(codes.Unimplemented, "method Authenticate not implemented")
+}
+func (*UnimplementedTrustedRPCServer) SetHDMnemonic(ctx context.Context, req *SetHDMnemonicReq) (*SetHDMnemonicResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetHDMnemonic not implemented")
+}
+func (*UnimplementedTrustedRPCServer) SubscribeToStates(req *SubscribeToStatesReq, srv TrustedRPC_SubscribeToStatesServer) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeToStates not implemented")
+}
+func (*UnimplementedTrustedRPCServer) UnsubscribeFromStates(ctx context.Context, req *UnsubscribeFromStatesReq) (*UnsubscribeFromStatesResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnsubscribeFromStates not implemented")
+}
+func (*UnimplementedTrustedRPCServer) SubscribeToTxs(req *SubscribeToTxsReq, srv TrustedRPC_SubscribeToTxsServer) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeToTxs not implemented")
+}
+func (*UnimplementedTrustedRPCServer) UnsubscribeFromTxs(ctx context.Context, req *UnsubscribeFromTxsReq) (*UnsubscribeFromTxsResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnsubscribeFromTxs not implemented")
+}
+func (*UnimplementedTrustedRPCServer) GetState(ctx context.Context, req *GetStateReq) (*StatePacket, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetState not implemented")
+}
+func (*UnimplementedTrustedRPCServer) SendTx(ctx context.Context, req *SendTxReq) (*SendTxResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SendTx not implemented")
+}
+func (*UnimplementedTrustedRPCServer) AddRef(srv TrustedRPC_AddRefServer) error {
+	return status.Errorf(codes.Unimplemented, "method AddRef not implemented")
+}
+func (*UnimplementedTrustedRPCServer) FetchRef(req *FetchRefReq, srv TrustedRPC_FetchRefServer) error {
+	return status.Errorf(codes.Unimplemented, "method FetchRef not implemented")
+}
+func (*UnimplementedTrustedRPCServer) AddPeer(ctx context.Context, req *AddPeerReq) (*AddPeerResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddPeer not implemented")
+}
+func (*UnimplementedTrustedRPCServer) DropPeer(ctx context.Context, req *DropPeerReq) (*DropPeerResp, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DropPeer not implemented")
+}
+
+func RegisterTrustedRPCServer(s *grpc.Server, srv TrustedRPCServer) {
+	s.RegisterService(&_TrustedRPC_serviceDesc, srv)
+}
+
+func _TrustedRPC_Authenticate_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(TrustedRPCServer).Authenticate(&trustedRPCAuthenticateServer{stream})
+}
+
+type TrustedRPC_AuthenticateServer interface {
+	Send(*AuthenticateMessage) error
+	Recv() (*AuthenticateMessage, error)
+	grpc.ServerStream
+}
+
+type trustedRPCAuthenticateServer struct {
+	grpc.ServerStream
+}
+
+func (x *trustedRPCAuthenticateServer) Send(m *AuthenticateMessage) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *trustedRPCAuthenticateServer) Recv() (*AuthenticateMessage, error) {
+	m := new(AuthenticateMessage)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _TrustedRPC_SetHDMnemonic_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetHDMnemonicReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrustedRPCServer).SetHDMnemonic(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trustedrpc.TrustedRPC/SetHDMnemonic",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrustedRPCServer).SetHDMnemonic(ctx, req.(*SetHDMnemonicReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrustedRPC_SubscribeToStates_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeToStatesReq)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TrustedRPCServer).SubscribeToStates(m, &trustedRPCSubscribeToStatesServer{stream})
+}
+
+type TrustedRPC_SubscribeToStatesServer interface {
+	Send(*StatePacket) error
+	grpc.ServerStream
+}
+
+type trustedRPCSubscribeToStatesServer struct {
+	grpc.ServerStream
+}
+
+func (x *trustedRPCSubscribeToStatesServer) Send(m *StatePacket) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _TrustedRPC_UnsubscribeFromStates_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnsubscribeFromStatesReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrustedRPCServer).UnsubscribeFromStates(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trustedrpc.TrustedRPC/UnsubscribeFromStates",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrustedRPCServer).UnsubscribeFromStates(ctx, req.(*UnsubscribeFromStatesReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrustedRPC_SubscribeToTxs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeToTxsReq)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TrustedRPCServer).SubscribeToTxs(m, &trustedRPCSubscribeToTxsServer{stream})
+}
+
+type TrustedRPC_SubscribeToTxsServer interface {
+	Send(*TxPacket) error
+	grpc.ServerStream
+}
+
+type trustedRPCSubscribeToTxsServer struct {
+	grpc.ServerStream
+}
+
+func (x *trustedRPCSubscribeToTxsServer) Send(m *TxPacket) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _TrustedRPC_UnsubscribeFromTxs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnsubscribeFromTxsReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrustedRPCServer).UnsubscribeFromTxs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trustedrpc.TrustedRPC/UnsubscribeFromTxs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrustedRPCServer).UnsubscribeFromTxs(ctx, req.(*UnsubscribeFromTxsReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrustedRPC_GetState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStateReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrustedRPCServer).GetState(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trustedrpc.TrustedRPC/GetState",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrustedRPCServer).GetState(ctx, req.(*GetStateReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrustedRPC_SendTx_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SendTxReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrustedRPCServer).SendTx(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trustedrpc.TrustedRPC/SendTx",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrustedRPCServer).SendTx(ctx, req.(*SendTxReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrustedRPC_AddRef_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(TrustedRPCServer).AddRef(&trustedRPCAddRefServer{stream})
+}
+
+type TrustedRPC_AddRefServer interface {
+	SendAndClose(*AddRefResp) error
+	Recv() (*RefPacket, error)
+	grpc.ServerStream
+}
+
+type trustedRPCAddRefServer struct {
+	grpc.ServerStream
+}
+
+func (x *trustedRPCAddRefServer) SendAndClose(m *AddRefResp) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *trustedRPCAddRefServer) Recv() (*RefPacket, error) {
+	m := new(RefPacket)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _TrustedRPC_FetchRef_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(FetchRefReq)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TrustedRPCServer).FetchRef(m, &trustedRPCFetchRefServer{stream})
+}
+
+type TrustedRPC_FetchRefServer interface {
+	Send(*RefPacket) error
+	grpc.ServerStream
+}
+
+type trustedRPCFetchRefServer struct {
+	grpc.ServerStream
+}
+
+func (x *trustedRPCFetchRefServer) Send(m *RefPacket) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _TrustedRPC_AddPeer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddPeerReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrustedRPCServer).AddPeer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trustedrpc.TrustedRPC/AddPeer",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrustedRPCServer).AddPeer(ctx, req.(*AddPeerReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrustedRPC_DropPeer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DropPeerReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrustedRPCServer).DropPeer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trustedrpc.TrustedRPC/DropPeer",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrustedRPCServer).DropPeer(ctx, req.(*DropPeerReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _TrustedRPC_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "trustedrpc.TrustedRPC",
+	HandlerType: (*TrustedRPCServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SetHDMnemonic",
+			Handler:    _TrustedRPC_SetHDMnemonic_Handler,
+		},
+		{
+			MethodName: "UnsubscribeFromStates",
+			Handler:    _TrustedRPC_UnsubscribeFromStates_Handler,
+		},
+		{
+			MethodName: "UnsubscribeFromTxs",
+			Handler:    _TrustedRPC_UnsubscribeFromTxs_Handler,
+		},
+		{
+			MethodName: "GetState",
+			Handler:    _TrustedRPC_GetState_Handler,
+		},
+		{
+			MethodName: "SendTx",
+			Handler:    _TrustedRPC_SendTx_Handler,
+		},
+		{
+			MethodName: "AddPeer",
+			Handler:    _TrustedRPC_AddPeer_Handler,
+		},
+		{
+			MethodName: "DropPeer",
+			Handler:    _TrustedRPC_DropPeer_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Authenticate",
+			Handler:       _TrustedRPC_Authenticate_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "SubscribeToStates",
+			Handler:       _TrustedRPC_SubscribeToStates_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "SubscribeToTxs",
+			Handler:       _TrustedRPC_SubscribeToTxs_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "AddRef",
+			Handler:       _TrustedRPC_AddRef_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "FetchRef",
+			Handler:       _TrustedRPC_FetchRef_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "rpc.grpc.proto",
+}
@@ -33,6 +33,7 @@ type Tx struct {
 	Checkpoint           bool     `protobuf:"varint,9,opt,name=checkpoint,proto3" json:"checkpoint,omitempty"`
 	Attachment           []byte   `protobuf:"bytes,10,opt,name=attachment,proto3" json:"attachment,omitempty"`
 	Status               string   `protobuf:"bytes,11,opt,name=status,proto3" json:"status,omitempty"`
+	Timestamp            int64    `protobuf:"varint,12,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -140,10 +141,19 @@ func (m *Tx) GetStatus() string {
 	return ""
 }
 
+func (m *Tx) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
 type Patch struct {
 	Keypath              []byte   `protobuf:"bytes,1,opt,name=keypath,proto3" json:"keypath,omitempty"`
 	Range                *Range   `protobuf:"bytes,2,opt,name=range,proto3" json:"range,omitempty"`
 	Value                *any.Any `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+	Op                   string   `protobuf:"bytes,4,opt,name=op,proto3" json:"op,omitempty"`
+	From                 []byte   `protobuf:"bytes,5,opt,name=from,proto3" json:"from,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -195,6 +205,20 @@ func (m *Patch) GetValue() *any.Any {
 	return nil
 }
 
+func (m *Patch) GetOp() string {
+	if m != nil {
+		return m.Op
+	}
+	return ""
+}
+
+func (m *Patch) GetFrom() []byte {
+	if m != nil {
+		return m.From
+	}
+	return nil
+}
+
 type Range struct {
 	Start                int64    `protobuf:"varint,1,opt,name=start,proto3" json:"start,omitempty"`
 	End                  int64    `protobuf:"varint,2,opt,name=end,proto3" json:"end,omitempty"`
@@ -251,27 +275,29 @@ func init() {
 func init() { proto.RegisterFile("tx.proto", fileDescriptor_0fd2153dc07d3b5c) }
 
 var fileDescriptor_0fd2153dc07d3b5c = []byte{
-	// 341 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x54, 0x51, 0xb1, 0x6e, 0xc2, 0x30,
-	0x10, 0x55, 0x62, 0x02, 0xe1, 0x40, 0xa8, 0xb2, 0x50, 0xe5, 0x32, 0x54, 0x11, 0xea, 0x10, 0x75,
-	0x08, 0x52, 0xfb, 0x05, 0xed, 0xd6, 0xad, 0xb2, 0xda, 0xa5, 0x9b, 0x49, 0x4c, 0x62, 0x01, 0x76,
-	0x64, 0x9b, 0x16, 0x7e, 0xaa, 0xdf, 0x58, 0xf9, 0x42, 0x10, 0xdd, 0xee, 0xbd, 0x77, 0xbe, 0x7b,
-	0x7e, 0x07, 0xa9, 0x3f, 0x16, 0xad, 0x35, 0xde, 0xd0, 0x91, 0x95, 0xd5, 0x8f, 0x31, 0xd5, 0xe2,
-	0xae, 0x36, 0xa6, 0xde, 0xc9, 0x15, 0xd2, 0xeb, 0xc3, 0x66, 0x25, 0xf4, 0xa9, 0xeb, 0x59, 0xfe,
-	0xc6, 0x10, 0x7f, 0x1c, 0xe9, 0x0c, 0x62, 0x55, 0xb1, 0x28, 0x8b, 0xf2, 0x29, 0x8f, 0x55, 0x45,
-	0x19, 0x8c, 0x5a, 0x61, 0xa5, 0xf6, 0x8e, 0xc5, 0x19, 0xc9, 0xa7, 0xbc, 0x87, 0x74, 0x01, 0x69,
-	0xd9, 0xa8, 0x5d, 0x65, 0xa5, 0x66, 0x04, 0xa5, 0x0b, 0xa6, 0x14, 0x06, 0x1b, 0x6b, 0xf6, 0x6c,
-	0x80, 0x73, 0xb0, 0xa6, 0x37, 0x40, 0x9c, 0xaa, 0x59, 0x82, 0x54, 0x28, 0xc3, 0x04, 0xe7, 0x85,
-	0x97, 0x9f, 0xfc, 0x8d, 0x0d, 0xb3, 0x28, 0x1f, 0xf3, 0x0b, 0xa6, 0x79, 0xd8, 0xeb, 0xcb, 0x46,
-	0x3a, 0x36, 0xca, 0x48, 0x3e, 0x79, 0x9a, 0x15, 0xe7, 0x4f, 0x14, 0xef, 0x81, 0xe7, 0xbd, 0x4c,
-	0xef, 0x01, 0xac, 0x2c, 0x55, 0xab, 0xd0, 0x64, 0x8a, 0x4e, 0xae, 0x98, 0xa0, 0x97, 0x8d, 0x2c,
-	0xb7, 0xad, 0x51, 0xda, 0xb3, 0x71, 0x16, 0xe5, 0x29, 0xbf, 0x62, 0x82, 0x2e, 0xbc, 0x17, 0x65,
-	0xb3, 0x97, 0xda, 0x33, 0x40, 0x7b, 0x57, 0x0c, 0xbd, 0x85, 0x61, 0x70, 0x75, 0x70, 0x6c, 0x82,
-	0x1e, 0xcf, 0x68, 0xe9, 0x20, 0x41, 0x27, 0x21, 0xa2, 0xad, 0x3c, 0xb5, 0xc2, 0x37, 0xe7, 0xdc,
-	0x7a, 0x48, 0x1f, 0x20, 0xb1, 0x42, 0xd7, 0x92, 0xc5, 0x59, 0xf4, 0xef, 0x0b, 0x3c, 0xb0, 0xbc,
-	0x13, 0xe9, 0x23, 0x24, 0xdf, 0x62, 0x77, 0x90, 0x8c, 0x60, 0xd7, 0xbc, 0xe8, 0x8e, 0x54, 0xf4,
-	0x47, 0x2a, 0x5e, 0xf4, 0x89, 0x77, 0x2d, 0xcb, 0x15, 0x24, 0xf8, 0x96, 0xce, 0x21, 0x71, 0x5e,
-	0x58, 0x8f, 0x2b, 0x09, 0xef, 0x40, 0xc8, 0x58, 0xea, 0x0a, 0xd7, 0x11, 0x1e, 0xca, 0xd7, 0xc1,
-	0x57, 0xdc, 0xae, 0xd7, 0x43, 0x9c, 0xf5, 0xfc, 0x17, 0x00, 0x00, 0xff, 0xff, 0x52, 0x2b, 0x73,
-	0x7b, 0x13, 0x02, 0x00, 0x00,
+	// 372 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x54, 0x51, 0xc1, 0xae, 0xd3, 0x30,
+	0x10, 0x54, 0xec, 0xa6, 0x4d, 0xf6, 0x55, 0x15, 0xb2, 0x2a, 0x64, 0x2a, 0x84, 0xa2, 0x8a, 0x43,
+	0xc4, 0x21, 0x95, 0xe0, 0x0b, 0xe0, 0xc6, 0x0d, 0x59, 0x70, 0xe1, 0xe6, 0x26, 0x6e, 0x62, 0xb5,
+	0xb1, 0x2d, 0xc7, 0x85, 0xf6, 0x4b, 0xf8, 0x29, 0x3e, 0x0a, 0x79, 0xd3, 0xb4, 0xe5, 0xb6, 0x33,
+	0xb3, 0xda, 0x9d, 0xdd, 0x81, 0x2c, 0x5c, 0x2a, 0xe7, 0x6d, 0xb0, 0x6c, 0xe1, 0x55, 0xf3, 0xdb,
+	0xda, 0x66, 0xf3, 0xa6, 0xb5, 0xb6, 0x3d, 0xa9, 0x1d, 0xd2, 0xfb, 0xf3, 0x61, 0x27, 0xcd, 0x75,
+	0xec, 0xd9, 0xfe, 0x25, 0x40, 0xbe, 0x5f, 0xd8, 0x0a, 0x88, 0x6e, 0x78, 0x52, 0x24, 0xe5, 0x52,
+	0x10, 0xdd, 0x30, 0x0e, 0x0b, 0x27, 0xbd, 0x32, 0x61, 0xe0, 0xa4, 0xa0, 0xe5, 0x52, 0x4c, 0x90,
+	0x6d, 0x20, 0xab, 0x3b, 0x7d, 0x6a, 0xbc, 0x32, 0x9c, 0xa2, 0x74, 0xc7, 0x8c, 0xc1, 0xec, 0xe0,
+	0x6d, 0xcf, 0x67, 0x38, 0x07, 0x6b, 0xf6, 0x0a, 0xe8, 0xa0, 0x5b, 0x9e, 0x22, 0x15, 0xcb, 0x38,
+	0x61, 0x08, 0x32, 0xa8, 0x1f, 0xe2, 0x2b, 0x9f, 0x17, 0x49, 0x99, 0x8b, 0x3b, 0x66, 0x65, 0xdc,
+	0x1b, 0xea, 0x4e, 0x0d, 0x7c, 0x51, 0xd0, 0xf2, 0xe5, 0xe3, 0xaa, 0xba, 0x1d, 0x51, 0x7d, 0x8b,
+	0xbc, 0x98, 0x64, 0xf6, 0x0e, 0xc0, 0xab, 0x5a, 0x3b, 0x8d, 0x26, 0x33, 0x74, 0xf2, 0xc4, 0x44,
+	0xbd, 0xee, 0x54, 0x7d, 0x74, 0x56, 0x9b, 0xc0, 0xf3, 0x22, 0x29, 0x33, 0xf1, 0xc4, 0x44, 0x5d,
+	0x86, 0x20, 0xeb, 0xae, 0x57, 0x26, 0x70, 0x40, 0x7b, 0x4f, 0x0c, 0x7b, 0x0d, 0xf3, 0xe8, 0xea,
+	0x3c, 0xf0, 0x17, 0xf4, 0x78, 0x43, 0xec, 0x2d, 0xe4, 0x41, 0xf7, 0x6a, 0x08, 0xb2, 0x77, 0x7c,
+	0x59, 0x24, 0x25, 0x15, 0x0f, 0x62, 0xfb, 0x27, 0x81, 0x14, 0x8d, 0xc6, 0x0f, 0x1e, 0xd5, 0xd5,
+	0xc9, 0xd0, 0xdd, 0xde, 0x3a, 0x41, 0xf6, 0x1e, 0x52, 0x2f, 0x4d, 0xab, 0x38, 0x29, 0x92, 0xff,
+	0x2e, 0x14, 0x91, 0x15, 0xa3, 0xc8, 0x3e, 0x40, 0xfa, 0x4b, 0x9e, 0xce, 0x8a, 0x53, 0xec, 0x5a,
+	0x57, 0x63, 0x86, 0xd5, 0x94, 0x61, 0xf5, 0xd9, 0x5c, 0xc5, 0xd8, 0x12, 0xd3, 0xb3, 0x0e, 0xbf,
+	0x9e, 0x0b, 0x62, 0xdd, 0x3d, 0x87, 0xf4, 0x91, 0xc3, 0x76, 0x07, 0x29, 0xce, 0x67, 0x6b, 0x48,
+	0x87, 0x20, 0x7d, 0x40, 0x5b, 0x54, 0x8c, 0x20, 0xc6, 0xa4, 0x4c, 0x83, 0x96, 0xa8, 0x88, 0xe5,
+	0x97, 0xd9, 0x4f, 0xe2, 0xf6, 0xfb, 0x39, 0xee, 0xfb, 0xf4, 0x6f, 0x00, 0xbd, 0x20, 0xd5, 0x69,
+	0x56, 0x02, 0x00, 0x00,
 }
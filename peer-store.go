@@ -16,6 +16,9 @@ import (
 type PeerStore interface {
 	AddDialInfos(dialInfos []PeerDialInfo)
 	AddVerifiedCredentials(dialInfo PeerDialInfo, address types.Address, sigpubkey crypto.SigningPublicKey, encpubkey crypto.EncryptingPublicKey)
+	RemovePeers(dialInfos []PeerDialInfo)
+	BanPeers(dialInfos []PeerDialInfo)
+	IsBanned(dialInfo PeerDialInfo) bool
 	UnverifiedPeers() []PeerDetails
 	Peers() []PeerDetails
 	AllDialInfos() []PeerDialInfo
@@ -36,6 +39,7 @@ type peerStore struct {
 	peers            map[PeerDialInfo]*peerDetails
 	peersWithAddress map[types.Address]map[PeerDialInfo]*peerDetails
 	unverifiedPeers  map[PeerDialInfo]struct{}
+	bannedPeers      map[PeerDialInfo]struct{}
 
 	onNewUnverifiedPeer func(dialInfo PeerDialInfo)
 }
@@ -52,6 +56,7 @@ func NewPeerStore(state *tree.DBTree) *peerStore {
 		peers:            make(map[PeerDialInfo]*peerDetails),
 		peersWithAddress: make(map[types.Address]map[PeerDialInfo]*peerDetails),
 		unverifiedPeers:  make(map[PeerDialInfo]struct{}),
+		bannedPeers:      make(map[PeerDialInfo]struct{}),
 	}
 
 	pds, err := s.fetchAllPeerDetails()
@@ -75,6 +80,15 @@ func NewPeerStore(state *tree.DBTree) *peerStore {
 		}
 	}
 
+	banned, err := s.fetchBannedDialInfos()
+	if err != nil {
+		s.Warnf("could not fetch banned peers from DB: %v", err)
+	} else {
+		for _, dialInfo := range banned {
+			s.bannedPeers[dialInfo] = struct{}{}
+		}
+	}
+
 	return s
 }
 
@@ -100,6 +114,8 @@ func (s *peerStore) AddDialInfos(dialInfos []PeerDialInfo) {
 	for _, dialInfo := range dialInfos {
 		if dialInfo.DialAddr == "" {
 			continue
+		} else if _, banned := s.bannedPeers[dialInfo]; banned {
+			continue
 		}
 
 		_, exists := s.peers[dialInfo]
@@ -131,6 +147,10 @@ func (s *peerStore) AddVerifiedCredentials(
 	s.muPeers.Lock()
 	defer s.muPeers.Unlock()
 
+	if _, banned := s.bannedPeers[dialInfo]; banned {
+		return
+	}
+
 	var pd *peerDetails
 	if _, exists := s.peersWithAddress[address]; exists {
 		pd = s.peersWithAddress[address][dialInfo]
@@ -176,6 +196,67 @@ func (s *peerStore) AddVerifiedCredentials(
 	}
 }
 
+// RemovePeers forgets everything that we know about the peers with the given
+// dial infos.  They're added again if they're discovered later.
+func (s *peerStore) RemovePeers(dialInfos []PeerDialInfo) {
+	s.muPeers.Lock()
+	defer s.muPeers.Unlock()
+
+	for _, dialInfo := range dialInfos {
+		s.removePeer(dialInfo)
+	}
+}
+
+// BanPeers removes the peers with the given dial infos and refuses to add
+// them again, even if they're discovered later.
+func (s *peerStore) BanPeers(dialInfos []PeerDialInfo) {
+	s.muPeers.Lock()
+	defer s.muPeers.Unlock()
+
+	for _, dialInfo := range dialInfos {
+		if dialInfo.DialAddr == "" {
+			continue
+		}
+		s.removePeer(dialInfo)
+		s.bannedPeers[dialInfo] = struct{}{}
+
+		err := s.saveBannedDialInfo(dialInfo)
+		if err != nil {
+			s.Warnf("could not save modifications to peerstore DB: %v", err)
+		}
+	}
+}
+
+func (s *peerStore) IsBanned(dialInfo PeerDialInfo) bool {
+	s.muPeers.RLock()
+	defer s.muPeers.RUnlock()
+
+	_, banned := s.bannedPeers[dialInfo]
+	return banned
+}
+
+func (s *peerStore) removePeer(dialInfo PeerDialInfo) {
+	pd, exists := s.peers[dialInfo]
+	if !exists {
+		return
+	}
+	pd.removed = true
+
+	delete(s.peers, dialInfo)
+	delete(s.unverifiedPeers, dialInfo)
+	for addr := range pd.addresses {
+		delete(s.peersWithAddress[addr], dialInfo)
+		if len(s.peersWithAddress[addr]) == 0 {
+			delete(s.peersWithAddress, addr)
+		}
+	}
+
+	err := s.deletePeerDetails(dialInfo)
+	if err != nil {
+		s.Warnf("could not save modifications to peerstore DB: %v", err)
+	}
+}
+
 func (s *peerStore) PeerWithDialInfo(dialInfo PeerDialInfo) *peerDetails {
 	s.muPeers.RLock()
 	defer s.muPeers.RUnlock()
@@ -355,6 +436,11 @@ func (s *peerStore) peerDetailsCodecToPeerDetails(pd peerDetailsCodec) (*peerDet
 }
 
 func (s *peerStore) savePeerDetails(peerDetails *peerDetails) error {
+	// Transports may still hold on to the details of peers that we've removed
+	if peerDetails.removed {
+		return nil
+	}
+
 	state := s.state.State(true)
 	defer state.Close()
 
@@ -385,6 +471,51 @@ func (s *peerStore) savePeerDetails(peerDetails *peerDetails) error {
 	return state.Save()
 }
 
+func (s *peerStore) deletePeerDetails(dialInfo PeerDialInfo) error {
+	state := s.state.State(true)
+	defer state.Close()
+
+	dialInfoHash := s.dialInfoHash(dialInfo)
+	peerKeypath := tree.Keypath("peers").Pushs(dialInfoHash)
+
+	err := state.Delete(peerKeypath, nil)
+	if err != nil {
+		return err
+	}
+	return state.Save()
+}
+
+func (s *peerStore) fetchBannedDialInfos() ([]PeerDialInfo, error) {
+	state := s.state.State(false)
+	defer state.Close()
+
+	var banned map[string]PeerDialInfo
+	err := state.NodeAt(tree.Keypath("banned"), nil).Scan(&banned)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not fetch banned peers")
+	}
+
+	var dialInfos []PeerDialInfo
+	for _, dialInfo := range banned {
+		dialInfos = append(dialInfos, dialInfo)
+	}
+	return dialInfos, nil
+}
+
+func (s *peerStore) saveBannedDialInfo(dialInfo PeerDialInfo) error {
+	state := s.state.State(true)
+	defer state.Close()
+
+	dialInfoHash := s.dialInfoHash(dialInfo)
+	bannedKeypath := tree.Keypath("banned").Pushs(dialInfoHash)
+
+	err := state.Set(bannedKeypath, nil, dialInfo)
+	if err != nil {
+		return err
+	}
+	return state.Save()
+}
+
 type PeerDetails interface {
 	Addresses() []types.Address
 	DialInfo() PeerDialInfo
@@ -410,6 +541,7 @@ type peerDetails struct {
 	lastContact time.Time
 	lastFailure time.Time
 	failures    uint64
+	removed     bool
 }
 
 type peerDetailsCodec struct {
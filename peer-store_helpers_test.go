@@ -41,5 +41,6 @@ func NewPeerDetails(
 		lastContact,
 		lastFailure,
 		failures,
+		false,
 	}
 }
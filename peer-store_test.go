@@ -105,3 +105,63 @@ func requirePeerDetailsEqual(t *testing.T, pd1, pd2 redwood.PeerDetails) {
 	require.True(t, pd1.LastFailure().Equal(pd2.LastFailure()))
 	require.Equal(t, pd1.Failures(), pd2.Failures())
 }
+
+func TestPeerStore_RemovePeers(t *testing.T) {
+	db := testutils.SetupDBTree(t)
+	defer db.DeleteDB()
+
+	p := redwood.NewPeerStore(db)
+
+	addr1 := testutils.RandomAddress(t)
+	addr2 := testutils.RandomAddress(t)
+	dialInfo1 := redwood.PeerDialInfo{TransportName: "http", DialAddr: "http://asdf.dev:1234"}
+	dialInfo2 := redwood.PeerDialInfo{TransportName: "http", DialAddr: "http://xyzzy.dev:1234"}
+	p.AddVerifiedCredentials(dialInfo1, addr1, testutils.RandomSigningPublicKey(t), testutils.RandomEncryptingPublicKey(t))
+	p.AddVerifiedCredentials(dialInfo2, addr2, testutils.RandomSigningPublicKey(t), testutils.RandomEncryptingPublicKey(t))
+	removed := p.PeerWithDialInfo(dialInfo1)
+	require.NotNil(t, removed)
+
+	p.RemovePeers([]redwood.PeerDialInfo{dialInfo1})
+
+	require.False(t, p.IsKnownPeer(dialInfo1))
+	require.True(t, p.IsKnownPeer(dialInfo2))
+	require.Len(t, p.PeersWithAddress(addr1), 0)
+	require.Len(t, p.PeersWithAddress(addr2), 1)
+
+	// Transports may still hold the removed peer's details, but they shouldn't
+	// bring it back
+	removed.AddStateURI("foo.bar/blah")
+
+	pds, err := p.FetchAllPeerDetails()
+	require.NoError(t, err)
+	require.Len(t, pds, 1)
+	require.Equal(t, dialInfo2, pds[0].DialInfo())
+}
+
+func TestPeerStore_BanPeers(t *testing.T) {
+	db := testutils.SetupDBTree(t)
+	defer db.DeleteDB()
+
+	p := redwood.NewPeerStore(db)
+	p.OnNewUnverifiedPeer(func(dialInfo redwood.PeerDialInfo) {})
+
+	addr := testutils.RandomAddress(t)
+	dialInfo := redwood.PeerDialInfo{TransportName: "http", DialAddr: "http://asdf.dev:1234"}
+	p.AddVerifiedCredentials(dialInfo, addr, testutils.RandomSigningPublicKey(t), testutils.RandomEncryptingPublicKey(t))
+
+	p.BanPeers([]redwood.PeerDialInfo{dialInfo})
+
+	require.True(t, p.IsBanned(dialInfo))
+	require.False(t, p.IsKnownPeer(dialInfo))
+	require.Len(t, p.PeersWithAddress(addr), 0)
+
+	// Banned peers aren't added again when they're rediscovered
+	p.AddDialInfos([]redwood.PeerDialInfo{dialInfo})
+	p.AddVerifiedCredentials(dialInfo, addr, testutils.RandomSigningPublicKey(t), testutils.RandomEncryptingPublicKey(t))
+	require.False(t, p.IsKnownPeer(dialInfo))
+
+	// Bans are persisted
+	p2 := redwood.NewPeerStore(db)
+	require.True(t, p2.IsBanned(dialInfo))
+	require.False(t, p2.IsKnownPeer(dialInfo))
+}
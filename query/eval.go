@@ -0,0 +1,724 @@
+package query
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"redwood.dev/tree"
+	"redwood.dev/types"
+)
+
+type stepKind int
+
+const (
+	stepKey stepKind = iota
+	stepIndex
+	stepIterate
+)
+
+type step struct {
+	kind  stepKind
+	key   string
+	index int64
+}
+
+type path []step
+
+// An item is one value in a stream.  Items that come straight from the tree
+// hold a node, which isn't read until a stage needs it.  Projections produce
+// plain Go values.
+type item struct {
+	node  tree.Node
+	value interface{}
+}
+
+type stream interface {
+	Next() (item, bool, error)
+	Close()
+}
+
+type stage interface {
+	apply(ev *evaluator, upstream stream) stream
+}
+
+type evaluator struct {
+	redact Redactor
+}
+
+// value reads the value at p, relative to an item.  Missing values are nil.
+// If the value may not be read, it returns types.Err403.
+func (ev *evaluator) value(it item, p path) (interface{}, error) {
+	it, err := ev.resolve(it, p)
+	if err != nil {
+		return nil, err
+	} else if it.node == nil {
+		return it.value, nil
+	}
+
+	node := it.node
+	if ev.redact != nil {
+		copied, err := node.CopyToMemory(nil, nil)
+		if errors.Cause(err) == types.Err404 {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		err = ev.redact(copied, it.node.Keypath())
+		if err != nil {
+			return nil, err
+		}
+		node = copied
+	}
+
+	val, _, err := node.Value(nil, nil)
+	if errors.Cause(err) == types.Err404 {
+		return nil, nil
+	}
+	return val, err
+}
+
+// field is like value, but treats values that may not be read as missing.
+func (ev *evaluator) field(it item, p path) (interface{}, error) {
+	val, err := ev.value(it, p)
+	if errors.Cause(err) == types.Err403 {
+		return nil, nil
+	}
+	return val, err
+}
+
+// resolve follows a path without any iteration steps.  Nodes are descended
+// into without being read.
+func (ev *evaluator) resolve(it item, p path) (item, error) {
+	for _, step := range p {
+		if it.node == nil {
+			it.value = valueStep(it.value, step)
+			continue
+		}
+
+		switch step.kind {
+		case stepKey:
+			it.node = it.node.NodeAt(tree.Keypath(step.key), nil)
+
+		case stepIndex:
+			nodeType, _, length, err := it.node.NodeInfo(nil)
+			if errors.Cause(err) == types.Err404 {
+				return item{}, nil
+			} else if err != nil {
+				return item{}, err
+			} else if nodeType != tree.NodeTypeSlice {
+				return item{}, nil
+			}
+			idx := step.index
+			if idx < 0 {
+				idx += int64(length)
+			}
+			if idx < 0 || idx >= int64(length) {
+				return item{}, nil
+			}
+			it.node = it.node.NodeAt(tree.EncodeSliceIndex(uint64(idx)), nil)
+		}
+	}
+	return it, nil
+}
+
+func valueStep(val interface{}, step step) interface{} {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		if step.kind == stepKey {
+			return v[step.key]
+		}
+	case []interface{}:
+		if step.kind == stepIndex {
+			idx := step.index
+			if idx < 0 {
+				idx += int64(len(v))
+			}
+			if idx >= 0 && idx < int64(len(v)) {
+				return v[idx]
+			}
+		}
+	}
+	return nil
+}
+
+// expand follows a path that may contain iteration steps, and streams the
+// values that it reaches.
+func (ev *evaluator) expand(it item, p path) (stream, error) {
+	i := 0
+	for ; i < len(p); i++ {
+		if p[i].kind == stepIterate {
+			break
+		}
+	}
+
+	it, err := ev.resolve(it, p[:i])
+	if err != nil {
+		return nil, err
+	} else if i == len(p) {
+		return &singleStream{item: it}, nil
+	}
+
+	children, err := ev.children(it)
+	if err != nil {
+		return nil, err
+	}
+	rest := p[i+1:]
+	if len(rest) == 0 {
+		return children, nil
+	}
+	return &flatMapStream{upstream: children, fn: func(child item) (stream, error) {
+		return ev.expand(child, rest)
+	}}, nil
+}
+
+// children streams the children of a map or slice.  Other values have none.
+func (ev *evaluator) children(it item) (stream, error) {
+	if it.node == nil {
+		var items []item
+		switch v := it.value.(type) {
+		case map[string]interface{}:
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				items = append(items, item{value: v[key]})
+			}
+		case []interface{}:
+			for _, x := range v {
+				items = append(items, item{value: x})
+			}
+		}
+		return &sliceStream{items: items}, nil
+	}
+
+	nodeType, _, length, err := it.node.NodeInfo(nil)
+	if errors.Cause(err) == types.Err404 {
+		return &sliceStream{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	switch nodeType {
+	case tree.NodeTypeMap:
+		return &mapChildStream{node: it.node}, nil
+	case tree.NodeTypeSlice:
+		return &sliceChildStream{node: it.node, length: length}, nil
+	default:
+		return &sliceStream{}, nil
+	}
+}
+
+type pathStage struct {
+	path path
+}
+
+func (s pathStage) apply(ev *evaluator, upstream stream) stream {
+	return &flatMapStream{upstream: upstream, fn: func(it item) (stream, error) {
+		return ev.expand(it, s.path)
+	}}
+}
+
+type selectStage struct {
+	cond cond
+}
+
+func (s selectStage) apply(ev *evaluator, upstream stream) stream {
+	return &filterStream{upstream: upstream, fn: func(it item) (bool, error) {
+		return s.cond.test(ev, it)
+	}}
+}
+
+type sortStage struct {
+	keys []path
+}
+
+func (s sortStage) apply(ev *evaluator, upstream stream) stream {
+	return &bufferedStream{upstream: upstream, fn: func(items []item) ([]item, error) {
+		keys := make([][]interface{}, len(items))
+		for i, it := range items {
+			keys[i] = make([]interface{}, len(s.keys))
+			for j, keyPath := range s.keys {
+				key, err := ev.field(it, keyPath)
+				if err != nil {
+					return nil, err
+				}
+				keys[i][j] = key
+			}
+		}
+
+		idxs := make([]int, len(items))
+		for i := range idxs {
+			idxs[i] = i
+		}
+		sort.SliceStable(idxs, func(i, j int) bool {
+			for k := range s.keys {
+				if c := compareValues(keys[idxs[i]][k], keys[idxs[j]][k]); c != 0 {
+					return c < 0
+				}
+			}
+			return false
+		})
+
+		sorted := make([]item, len(items))
+		for i, idx := range idxs {
+			sorted[i] = items[idx]
+		}
+		return sorted, nil
+	}}
+}
+
+type reverseStage struct{}
+
+func (s reverseStage) apply(ev *evaluator, upstream stream) stream {
+	return &bufferedStream{upstream: upstream, fn: func(items []item) ([]item, error) {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+		return items, nil
+	}}
+}
+
+type skipStage struct {
+	n uint64
+}
+
+func (s skipStage) apply(ev *evaluator, upstream stream) stream {
+	return &skipStream{upstream: upstream, n: s.n}
+}
+
+type limitStage struct {
+	n uint64
+}
+
+func (s limitStage) apply(ev *evaluator, upstream stream) stream {
+	return &limitStream{upstream: upstream, n: s.n}
+}
+
+type projectStage struct {
+	fields []projectedField
+}
+
+type projectedField struct {
+	name string
+	path path
+}
+
+func (s projectStage) apply(ev *evaluator, upstream stream) stream {
+	return &flatMapStream{upstream: upstream, fn: func(it item) (stream, error) {
+		projected := make(map[string]interface{}, len(s.fields))
+		for _, field := range s.fields {
+			val, err := ev.field(it, field.path)
+			if err != nil {
+				return nil, err
+			}
+			projected[field.name] = val
+		}
+		return &singleStream{item: item{value: projected}}, nil
+	}}
+}
+
+type cond interface {
+	test(ev *evaluator, it item) (bool, error)
+}
+
+type andCond struct{ left, right cond }
+type orCond struct{ left, right cond }
+type notCond struct{ cond cond }
+type truthyCond struct{ operand operand }
+
+type compareCond struct {
+	op          string
+	left, right operand
+}
+
+type stringCond struct {
+	fn   string
+	path path
+	arg  string
+}
+
+type operand struct {
+	path    path
+	isPath  bool
+	literal interface{}
+}
+
+func (c andCond) test(ev *evaluator, it item) (bool, error) {
+	ok, err := c.left.test(ev, it)
+	if err != nil || !ok {
+		return false, err
+	}
+	return c.right.test(ev, it)
+}
+
+func (c orCond) test(ev *evaluator, it item) (bool, error) {
+	ok, err := c.left.test(ev, it)
+	if err != nil || ok {
+		return ok, err
+	}
+	return c.right.test(ev, it)
+}
+
+func (c notCond) test(ev *evaluator, it item) (bool, error) {
+	ok, err := c.cond.test(ev, it)
+	return !ok, err
+}
+
+func (c truthyCond) test(ev *evaluator, it item) (bool, error) {
+	val, err := c.operand.eval(ev, it)
+	if err != nil {
+		return false, err
+	}
+	return val != nil && val != false, nil
+}
+
+func (c compareCond) test(ev *evaluator, it item) (bool, error) {
+	left, err := c.left.eval(ev, it)
+	if err != nil {
+		return false, err
+	}
+	right, err := c.right.eval(ev, it)
+	if err != nil {
+		return false, err
+	}
+
+	cmp := compareValues(left, right)
+	switch c.op {
+	case "==":
+		return cmp == 0, nil
+	case "!=":
+		return cmp != 0, nil
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	case ">=":
+		return cmp >= 0, nil
+	default:
+		return false, errors.Errorf("unknown operator '%v'", c.op)
+	}
+}
+
+func (c stringCond) test(ev *evaluator, it item) (bool, error) {
+	val, err := ev.field(it, c.path)
+	if err != nil {
+		return false, err
+	}
+	s, is := val.(string)
+	if !is {
+		return false, nil
+	}
+
+	switch c.fn {
+	case "contains":
+		return strings.Contains(s, c.arg), nil
+	case "startswith":
+		return strings.HasPrefix(s, c.arg), nil
+	case "endswith":
+		return strings.HasSuffix(s, c.arg), nil
+	default:
+		return false, errors.Errorf("unknown function '%v'", c.fn)
+	}
+}
+
+func (o operand) eval(ev *evaluator, it item) (interface{}, error) {
+	if !o.isPath {
+		return o.literal, nil
+	}
+	return ev.field(it, o.path)
+}
+
+// compareValues orders values the way that jq does: null < false < true <
+// numbers < strings < slices < maps.
+func compareValues(a, b interface{}) int {
+	a, b = normalizeNumber(a), normalizeNumber(b)
+	if ra, rb := typeRank(a), typeRank(b); ra != rb {
+		if ra < rb {
+			return -1
+		}
+		return 1
+	}
+
+	switch a := a.(type) {
+	case nil:
+		return 0
+	case bool:
+		if a == b.(bool) {
+			return 0
+		} else if !a {
+			return -1
+		}
+		return 1
+	case float64:
+		if b := b.(float64); a < b {
+			return -1
+		} else if a > b {
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(a, b.(string))
+	case []interface{}:
+		b := b.([]interface{})
+		for i := 0; i < len(a) && i < len(b); i++ {
+			if c := compareValues(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+		return compareValues(float64(len(a)), float64(len(b)))
+	default:
+		// Maps (and anything else) are compared by their JSON encoding
+		aBytes, _ := json.Marshal(a)
+		bBytes, _ := json.Marshal(b)
+		return strings.Compare(string(aBytes), string(bBytes))
+	}
+}
+
+func typeRank(val interface{}) int {
+	switch v := val.(type) {
+	case nil:
+		return 0
+	case bool:
+		if !v {
+			return 1
+		}
+		return 2
+	case float64:
+		return 3
+	case string:
+		return 4
+	case []interface{}:
+		return 5
+	case map[string]interface{}:
+		return 6
+	default:
+		return 7
+	}
+}
+
+func normalizeNumber(val interface{}) interface{} {
+	switch v := val.(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	default:
+		return val
+	}
+}
+
+type singleStream struct {
+	item item
+	done bool
+}
+
+func (s *singleStream) Next() (item, bool, error) {
+	if s.done {
+		return item{}, false, nil
+	}
+	s.done = true
+	return s.item, true, nil
+}
+
+func (s *singleStream) Close() {}
+
+type sliceStream struct {
+	items []item
+}
+
+func (s *sliceStream) Next() (item, bool, error) {
+	if len(s.items) == 0 {
+		return item{}, false, nil
+	}
+	it := s.items[0]
+	s.items = s.items[1:]
+	return it, true, nil
+}
+
+func (s *sliceStream) Close() {}
+
+// mapChildStream streams the children of a map node from a tree.Iterator, so
+// that they're never all read at once.
+type mapChildStream struct {
+	node tree.Node
+	iter tree.Iterator
+}
+
+func (s *mapChildStream) Next() (item, bool, error) {
+	if s.iter == nil {
+		s.iter = s.node.ChildIterator(nil, false, 0)
+		s.iter.Rewind()
+	} else {
+		s.iter.Next()
+	}
+	if !s.iter.Valid() {
+		return item{}, false, nil
+	}
+	// The iterator reuses its node, so the child gets one of its own
+	key := s.iter.Node().Keypath().Part(-1).Copy()
+	return item{node: s.node.NodeAt(key, nil)}, true, nil
+}
+
+func (s *mapChildStream) Close() {
+	if s.iter != nil {
+		s.iter.Close()
+	}
+}
+
+// sliceChildStream streams the children of a slice node by index.  (Encoded
+// slice indices can contain the keypath separator, so they can't be read back
+// out of an iterator's keypaths.)
+type sliceChildStream struct {
+	node   tree.Node
+	length uint64
+	i      uint64
+}
+
+func (s *sliceChildStream) Next() (item, bool, error) {
+	if s.i >= s.length {
+		return item{}, false, nil
+	}
+	it := item{node: s.node.NodeAt(tree.EncodeSliceIndex(s.i), nil)}
+	s.i++
+	return it, true, nil
+}
+
+func (s *sliceChildStream) Close() {}
+
+type flatMapStream struct {
+	upstream stream
+	fn       func(item) (stream, error)
+	current  stream
+}
+
+func (s *flatMapStream) Next() (item, bool, error) {
+	for {
+		if s.current != nil {
+			it, ok, err := s.current.Next()
+			if err != nil || ok {
+				return it, ok, err
+			}
+			s.current.Close()
+			s.current = nil
+		}
+
+		it, ok, err := s.upstream.Next()
+		if err != nil || !ok {
+			return item{}, false, err
+		}
+		s.current, err = s.fn(it)
+		if err != nil {
+			return item{}, false, err
+		}
+	}
+}
+
+func (s *flatMapStream) Close() {
+	if s.current != nil {
+		s.current.Close()
+	}
+	s.upstream.Close()
+}
+
+type filterStream struct {
+	upstream stream
+	fn       func(item) (bool, error)
+}
+
+func (s *filterStream) Next() (item, bool, error) {
+	for {
+		it, ok, err := s.upstream.Next()
+		if err != nil || !ok {
+			return item{}, false, err
+		}
+		keep, err := s.fn(it)
+		if err != nil {
+			return item{}, false, err
+		} else if keep {
+			return it, true, nil
+		}
+	}
+}
+
+func (s *filterStream) Close() {
+	s.upstream.Close()
+}
+
+// bufferedStream collects its whole upstream before passing it through fn.
+type bufferedStream struct {
+	upstream stream
+	fn       func([]item) ([]item, error)
+	items    *sliceStream
+}
+
+func (s *bufferedStream) Next() (item, bool, error) {
+	if s.items == nil {
+		var items []item
+		for {
+			it, ok, err := s.upstream.Next()
+			if err != nil {
+				return item{}, false, err
+			} else if !ok {
+				break
+			}
+			items = append(items, it)
+		}
+		items, err := s.fn(items)
+		if err != nil {
+			return item{}, false, err
+		}
+		s.items = &sliceStream{items: items}
+	}
+	return s.items.Next()
+}
+
+func (s *bufferedStream) Close() {
+	s.upstream.Close()
+}
+
+type skipStream struct {
+	upstream stream
+	n        uint64
+}
+
+func (s *skipStream) Next() (item, bool, error) {
+	for ; s.n > 0; s.n-- {
+		_, ok, err := s.upstream.Next()
+		if err != nil || !ok {
+			return item{}, false, err
+		}
+	}
+	return s.upstream.Next()
+}
+
+func (s *skipStream) Close() {
+	s.upstream.Close()
+}
+
+type limitStream struct {
+	upstream stream
+	n        uint64
+}
+
+func (s *limitStream) Next() (item, bool, error) {
+	if s.n == 0 {
+		return item{}, false, nil
+	}
+	s.n--
+	return s.upstream.Next()
+}
+
+func (s *limitStream) Close() {
+	s.upstream.Close()
+}
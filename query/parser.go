@@ -0,0 +1,519 @@
+package query
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenDot
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenPunct // one of [ ] ( ) { } , : |
+	tokenOp    // one of == != < <= > >=
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+type lexer struct {
+	source string
+	pos    int
+	peeked *token
+}
+
+func newLexer(source string) *lexer {
+	return &lexer{source: source}
+}
+
+func (l *lexer) peek() (token, error) {
+	if l.peeked == nil {
+		tok, err := l.scan()
+		if err != nil {
+			return token{}, err
+		}
+		l.peeked = &tok
+	}
+	return *l.peeked, nil
+}
+
+func (l *lexer) next() (token, error) {
+	tok, err := l.peek()
+	l.peeked = nil
+	return tok, err
+}
+
+func (l *lexer) scan() (token, error) {
+	for l.pos < len(l.source) && unicode.IsSpace(rune(l.source[l.pos])) {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos >= len(l.source) {
+		return token{kind: tokenEOF, pos: start}, nil
+	}
+
+	c := l.source[l.pos]
+	switch {
+	case c == '.':
+		l.pos++
+		return token{kind: tokenDot, text: ".", pos: start}, nil
+
+	case strings.IndexByte("[](){},:|", c) >= 0:
+		l.pos++
+		return token{kind: tokenPunct, text: string(c), pos: start}, nil
+
+	case c == '=' || c == '!' || c == '<' || c == '>':
+		l.pos++
+		if l.pos < len(l.source) && l.source[l.pos] == '=' {
+			l.pos++
+		} else if c == '=' || c == '!' {
+			return token{}, errors.Errorf("unexpected '%c' at %v", c, start)
+		}
+		return token{kind: tokenOp, text: l.source[start:l.pos], pos: start}, nil
+
+	case c == '"':
+		l.pos++
+		for l.pos < len(l.source) && l.source[l.pos] != '"' {
+			if l.source[l.pos] == '\\' {
+				l.pos++
+			}
+			l.pos++
+		}
+		if l.pos >= len(l.source) {
+			return token{}, errors.Errorf("unterminated string at %v", start)
+		}
+		l.pos++
+		var s string
+		err := json.Unmarshal([]byte(l.source[start:l.pos]), &s)
+		if err != nil {
+			return token{}, errors.Errorf("bad string at %v: %v", start, err)
+		}
+		return token{kind: tokenString, text: s, pos: start}, nil
+
+	case c == '-' || isDigit(c):
+		l.pos++
+		for l.pos < len(l.source) && (isDigit(l.source[l.pos]) || strings.IndexByte(".eE+-", l.source[l.pos]) >= 0) {
+			l.pos++
+		}
+		return token{kind: tokenNumber, text: l.source[start:l.pos], pos: start}, nil
+
+	case isIdentChar(c):
+		for l.pos < len(l.source) && isIdentChar(l.source[l.pos]) {
+			l.pos++
+		}
+		return token{kind: tokenIdent, text: l.source[start:l.pos], pos: start}, nil
+
+	default:
+		return token{}, errors.Errorf("unexpected '%c' at %v", c, start)
+	}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+type parser struct {
+	lexer *lexer
+}
+
+func (p *parser) expect(kind tokenKind, text string) (token, error) {
+	tok, err := p.lexer.next()
+	if err != nil {
+		return token{}, err
+	} else if tok.kind != kind || (text != "" && tok.text != text) {
+		return token{}, unexpected(tok)
+	}
+	return tok, nil
+}
+
+// accept consumes the next token if it's a punctuation mark or operator
+// matching text.
+func (p *parser) accept(text string) (bool, error) {
+	tok, err := p.lexer.peek()
+	if err != nil {
+		return false, err
+	} else if (tok.kind != tokenPunct && tok.kind != tokenOp && tok.kind != tokenIdent) || tok.text != text {
+		return false, nil
+	}
+	p.lexer.next()
+	return true, nil
+}
+
+func unexpected(tok token) error {
+	if tok.kind == tokenEOF {
+		return errors.New("unexpected end of query")
+	}
+	return errors.Errorf("unexpected '%v' at %v", tok.text, tok.pos)
+}
+
+func (p *parser) parseQuery() ([]stage, error) {
+	var stages []stage
+	for {
+		stage, err := p.parseStage()
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, stage)
+
+		tok, err := p.lexer.next()
+		if err != nil {
+			return nil, err
+		} else if tok.kind == tokenEOF {
+			return stages, nil
+		} else if tok.kind != tokenPunct || tok.text != "|" {
+			return nil, unexpected(tok)
+		}
+	}
+}
+
+func (p *parser) parseStage() (stage, error) {
+	tok, err := p.lexer.peek()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case tok.kind == tokenDot:
+		path, err := p.parsePath(true)
+		if err != nil {
+			return nil, err
+		}
+		return pathStage{path}, nil
+
+	case tok.kind == tokenPunct && tok.text == "{":
+		return p.parseProjection()
+
+	case tok.kind == tokenIdent:
+		p.lexer.next()
+		switch tok.text {
+		case "select":
+			if _, err := p.expect(tokenPunct, "("); err != nil {
+				return nil, err
+			}
+			cond, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect(tokenPunct, ")"); err != nil {
+				return nil, err
+			}
+			return selectStage{cond}, nil
+
+		case "sort_by":
+			if _, err := p.expect(tokenPunct, "("); err != nil {
+				return nil, err
+			}
+			var keys []path
+			for {
+				key, err := p.parsePath(false)
+				if err != nil {
+					return nil, err
+				}
+				keys = append(keys, key)
+				if more, err := p.accept(","); err != nil {
+					return nil, err
+				} else if !more {
+					break
+				}
+			}
+			if _, err := p.expect(tokenPunct, ")"); err != nil {
+				return nil, err
+			}
+			return sortStage{keys}, nil
+
+		case "reverse":
+			return reverseStage{}, nil
+
+		case "skip", "limit":
+			if _, err := p.expect(tokenPunct, "("); err != nil {
+				return nil, err
+			}
+			numTok, err := p.expect(tokenNumber, "")
+			if err != nil {
+				return nil, err
+			}
+			n, err := strconv.ParseUint(numTok.text, 10, 64)
+			if err != nil {
+				return nil, errors.Errorf("bad count '%v' at %v", numTok.text, numTok.pos)
+			}
+			if _, err := p.expect(tokenPunct, ")"); err != nil {
+				return nil, err
+			}
+			if tok.text == "skip" {
+				return skipStage{n}, nil
+			}
+			return limitStage{n}, nil
+		}
+	}
+	return nil, unexpected(tok)
+}
+
+// parsePath parses a path like .a.b[0]."c d"[].  Iteration ([]) is only
+// allowed when the path is a stage of its own.
+func (p *parser) parsePath(allowIterate bool) (path, error) {
+	var steps []step
+
+	tok, err := p.expect(tokenDot, "")
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok, err = p.lexer.peek()
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case tok.kind == tokenIdent || tok.kind == tokenString:
+			p.lexer.next()
+			steps = append(steps, step{kind: stepKey, key: tok.text})
+
+		case tok.kind == tokenPunct && tok.text == "[":
+			p.lexer.next()
+			tok, err = p.lexer.next()
+			if err != nil {
+				return nil, err
+			}
+			switch tok.kind {
+			case tokenPunct:
+				if tok.text != "]" || !allowIterate {
+					return nil, unexpected(tok)
+				}
+				steps = append(steps, step{kind: stepIterate})
+			case tokenString:
+				steps = append(steps, step{kind: stepKey, key: tok.text})
+			case tokenNumber:
+				idx, err := strconv.ParseInt(tok.text, 10, 64)
+				if err != nil {
+					return nil, errors.Errorf("bad index '%v' at %v", tok.text, tok.pos)
+				}
+				steps = append(steps, step{kind: stepIndex, index: idx})
+			default:
+				return nil, unexpected(tok)
+			}
+			if tok.kind != tokenPunct {
+				if _, err := p.expect(tokenPunct, "]"); err != nil {
+					return nil, err
+				}
+			}
+
+		default:
+			return steps, nil
+		}
+
+		// After the first step, further steps start with '.' or '['
+		tok, err = p.lexer.peek()
+		if err != nil {
+			return nil, err
+		}
+		if tok.kind == tokenDot {
+			p.lexer.next()
+			tok, err = p.lexer.peek()
+			if err != nil {
+				return nil, err
+			} else if tok.kind != tokenIdent && tok.kind != tokenString && !(tok.kind == tokenPunct && tok.text == "[") {
+				return nil, unexpected(tok)
+			}
+		} else if !(tok.kind == tokenPunct && tok.text == "[") {
+			return steps, nil
+		}
+	}
+}
+
+func (p *parser) parseProjection() (stage, error) {
+	if _, err := p.expect(tokenPunct, "{"); err != nil {
+		return nil, err
+	}
+	var fields []projectedField
+	for {
+		tok, err := p.lexer.next()
+		if err != nil {
+			return nil, err
+		} else if tok.kind != tokenIdent && tok.kind != tokenString {
+			return nil, unexpected(tok)
+		}
+		field := projectedField{name: tok.text, path: path{{kind: stepKey, key: tok.text}}}
+
+		if colon, err := p.accept(":"); err != nil {
+			return nil, err
+		} else if colon {
+			field.path, err = p.parsePath(false)
+			if err != nil {
+				return nil, err
+			}
+		}
+		fields = append(fields, field)
+
+		tok, err = p.lexer.next()
+		if err != nil {
+			return nil, err
+		} else if tok.kind == tokenPunct && tok.text == "}" {
+			return projectStage{fields}, nil
+		} else if tok.kind != tokenPunct || tok.text != "," {
+			return nil, unexpected(tok)
+		}
+	}
+}
+
+func (p *parser) parseOr() (cond, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if or, err := p.accept("or"); err != nil {
+			return nil, err
+		} else if !or {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orCond{left, right}
+	}
+}
+
+func (p *parser) parseAnd() (cond, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if and, err := p.accept("and"); err != nil {
+			return nil, err
+		} else if !and {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andCond{left, right}
+	}
+}
+
+func (p *parser) parseUnary() (cond, error) {
+	tok, err := p.lexer.peek()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case tok.kind == tokenPunct && tok.text == "(":
+		p.lexer.next()
+		c, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		_, err = p.expect(tokenPunct, ")")
+		return c, err
+
+	case tok.kind == tokenIdent && tok.text == "not":
+		p.lexer.next()
+		if _, err := p.expect(tokenPunct, "("); err != nil {
+			return nil, err
+		}
+		c, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenPunct, ")"); err != nil {
+			return nil, err
+		}
+		return notCond{c}, nil
+
+	case tok.kind == tokenIdent && (tok.text == "contains" || tok.text == "startswith" || tok.text == "endswith"):
+		p.lexer.next()
+		if _, err := p.expect(tokenPunct, "("); err != nil {
+			return nil, err
+		}
+		path, err := p.parsePath(false)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenPunct, ","); err != nil {
+			return nil, err
+		}
+		strTok, err := p.expect(tokenString, "")
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenPunct, ")"); err != nil {
+			return nil, err
+		}
+		return stringCond{fn: tok.text, path: path, arg: strTok.text}, nil
+	}
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	tok, err = p.lexer.peek()
+	if err != nil {
+		return nil, err
+	} else if tok.kind != tokenOp {
+		return truthyCond{left}, nil
+	}
+	p.lexer.next()
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return compareCond{op: tok.text, left: left, right: right}, nil
+}
+
+func (p *parser) parseOperand() (operand, error) {
+	tok, err := p.lexer.peek()
+	if err != nil {
+		return operand{}, err
+	}
+
+	switch tok.kind {
+	case tokenDot:
+		path, err := p.parsePath(false)
+		if err != nil {
+			return operand{}, err
+		}
+		return operand{path: path, isPath: true}, nil
+
+	case tokenString:
+		p.lexer.next()
+		return operand{literal: tok.text}, nil
+
+	case tokenNumber:
+		p.lexer.next()
+		n, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return operand{}, errors.Errorf("bad number '%v' at %v", tok.text, tok.pos)
+		}
+		return operand{literal: n}, nil
+
+	case tokenIdent:
+		p.lexer.next()
+		switch tok.text {
+		case "true":
+			return operand{literal: true}, nil
+		case "false":
+			return operand{literal: false}, nil
+		case "null":
+			return operand{literal: nil}, nil
+		}
+	}
+	return operand{}, unexpected(tok)
+}
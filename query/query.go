@@ -0,0 +1,91 @@
+// Package query implements a small, jq-like language for reading from a state
+// tree.  A query is a pipeline of stages separated by '|'.  Each stage takes a
+// stream of values and produces another one:
+//
+//	.messages[] | select(.author == "alice" and .time >= 100) | sort_by(.time) | reverse | skip(10) | limit(5) | {text, sent: .time}
+//
+// The stages are:
+//
+//	.a.b, ."c d", .[0], .[-1]   descend into each value (null if there's nothing there)
+//	.[], .a[]                   stream each value's children (maps and slices only)
+//	select(cond)                keep the values for which cond is true
+//	sort_by(.a, .b)             sort the values (null < false < true < numbers < strings < slices < maps)
+//	reverse                     reverse the order of the values
+//	skip(n), limit(n)           skip the first n values, or stop after n
+//	{a, b: .c.d, "e f": .g}     replace each value with a map of some of its fields
+//
+// Conditions combine comparisons (==, !=, <, <=, >, >=) between paths and
+// literals (strings, numbers, true, false, null) with 'and', 'or', 'not(...)'
+// and parentheses.  contains(.a, "x"), startswith(.a, "x") and
+// endswith(.a, "x") test strings.  A bare path is true unless its value is
+// null or false.
+//
+// Queries are evaluated lazily against tree.Node iterators: only the fields
+// that a stage needs are read from the tree, and whole values are only read
+// for the results.
+package query
+
+import (
+	"github.com/pkg/errors"
+
+	"redwood.dev/tree"
+	"redwood.dev/types"
+)
+
+var ErrInvalidQuery = errors.New("invalid query")
+
+// A Query is a parsed query, ready to be evaluated against any number of trees.
+type Query struct {
+	source string
+	stages []stage
+}
+
+// Parse parses a query.  Syntax errors are wrapped ErrInvalidQuerys.
+func Parse(source string) (*Query, error) {
+	p := &parser{lexer: newLexer(source)}
+	stages, err := p.parseQuery()
+	if err != nil {
+		return nil, errors.Wrapf(ErrInvalidQuery, "%v", err)
+	}
+	return &Query{source: source, stages: stages}, nil
+}
+
+func (q *Query) String() string {
+	return q.source
+}
+
+// A Redactor removes everything that the caller may not read from node, an
+// in-memory copy of the tree at keypath.  If none of it may be read, it
+// returns types.Err403, and the query treats the value as missing.
+type Redactor func(node tree.Node, keypath tree.Keypath) error
+
+// Evaluate runs the query against node and returns its results.  If redact is
+// non-nil, every value that the query reads passes through it first, and
+// results that may not be read are left out.
+func (q *Query) Evaluate(node tree.Node, redact Redactor) ([]interface{}, error) {
+	ev := &evaluator{redact: redact}
+
+	var s stream = &singleStream{item: item{node: node}}
+	for _, stage := range q.stages {
+		s = stage.apply(ev, s)
+	}
+	defer s.Close()
+
+	results := []interface{}{}
+	for {
+		item, ok, err := s.Next()
+		if err != nil {
+			return nil, err
+		} else if !ok {
+			break
+		}
+		val, err := ev.value(item, nil)
+		if errors.Cause(err) == types.Err403 {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		results = append(results, val)
+	}
+	return results, nil
+}
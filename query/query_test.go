@@ -0,0 +1,144 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"redwood.dev/query"
+	"redwood.dev/testutils"
+	"redwood.dev/tree"
+	"redwood.dev/types"
+)
+
+type M = map[string]interface{}
+type S = []interface{}
+
+var fixture = M{
+	"title": "chat",
+	"messages": S{
+		M{"author": "alice", "text": "hi", "time": float64(3)},
+		M{"author": "bob", "text": "hello there", "time": float64(1)},
+		M{"author": "alice", "text": "again", "time": float64(2), "pinned": true},
+		M{"author": "carol", "text": "yo", "time": float64(5)},
+	},
+	"users": M{
+		"bob":   M{"age": float64(25)},
+		"alice": M{"age": float64(30)},
+	},
+}
+
+func TestQuery_Evaluate(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected S
+	}{
+		{`.`, S{fixture}},
+		{`.title`, S{"chat"}},
+		{`.nope.nope`, S{nil}},
+		{`.messages[-1].text`, S{"yo"}},
+		{`.messages[9]`, S{nil}},
+		{`.users[] | .age`, S{float64(30), float64(25)}},
+		{`.users."bob"["age"]`, S{float64(25)}},
+		{`.title[]`, S{}},
+		{`.messages[] | select(.author == "alice") | .text`, S{"hi", "again"}},
+		{`.messages[] | select(.pinned) | .text`, S{"again"}},
+		{`.messages[] | select(.time > 1 and not(.author == "carol")) | {text, who: .author}`, S{
+			M{"text": "hi", "who": "alice"},
+			M{"text": "again", "who": "alice"},
+		}},
+		{`.messages[] | select(contains(.text, "he") or startswith(.author, "c")) | .author`, S{"bob", "carol"}},
+		{`.messages[] | select((.time < 2 or .time >= 5) and endswith(.text, "o")) | .text`, S{"yo"}},
+		{`.messages[] | sort_by(.time) | .text`, S{"hello there", "again", "hi", "yo"}},
+		{`.messages[] | sort_by(.author, .time) | .text`, S{"again", "hi", "hello there", "yo"}},
+		{`.messages[] | sort_by(.time) | reverse | skip(1) | limit(2) | .text`, S{"hi", "again"}},
+		{`.messages[].text | limit(1)`, S{"hi"}},
+		{`. | {title, first: .messages[0].author} | .first`, S{"alice"}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.query, func(t *testing.T) {
+			q, err := query.Parse(test.query)
+			require.NoError(t, err)
+
+			t.Run("db", func(t *testing.T) {
+				db := testutils.SetupDBTreeWithValue(t, nil, fixture)
+				defer db.DeleteDB()
+
+				state := db.State(false)
+				defer state.Close()
+
+				results, err := q.Evaluate(state, nil)
+				require.NoError(t, err)
+				require.Equal(t, test.expected, results)
+			})
+
+			t.Run("memory", func(t *testing.T) {
+				state := tree.NewMemoryNode()
+				err := state.Set(nil, nil, fixture)
+				require.NoError(t, err)
+
+				results, err := q.Evaluate(state, nil)
+				require.NoError(t, err)
+				require.Equal(t, test.expected, results)
+			})
+		})
+	}
+}
+
+func TestQuery_Evaluate_Redact(t *testing.T) {
+	db := testutils.SetupDBTreeWithValue(t, nil, fixture)
+	defer db.DeleteDB()
+
+	state := db.State(false)
+	defer state.Close()
+
+	var read []string
+	redact := func(node tree.Node, keypath tree.Keypath) error {
+		read = append(read, keypath.String())
+		if keypath.StartsWith(tree.Keypath("messages").PushIndex(2)) {
+			return types.Err403
+		}
+		return node.Delete(tree.Keypath("time"), nil)
+	}
+
+	// Unreadable results are left out, and unreadable fields are missing
+	q, err := query.Parse(`.messages[] | select(.time != null) | {text}`)
+	require.NoError(t, err)
+	results, err := q.Evaluate(state, redact)
+	require.NoError(t, err)
+	require.Equal(t, S{M{"text": "hi"}, M{"text": "hello there"}, M{"text": "yo"}}, results)
+
+	// Only the values that the query needs are read
+	read = nil
+	q, err = query.Parse(`.messages[] | limit(2)`)
+	require.NoError(t, err)
+	results, err = q.Evaluate(state, redact)
+	require.NoError(t, err)
+	require.Equal(t, S{M{"author": "alice", "text": "hi"}, M{"author": "bob", "text": "hello there"}}, results)
+	require.Equal(t, []string{
+		tree.Keypath("messages").PushIndex(0).String(),
+		tree.Keypath("messages").PushIndex(1).String(),
+	}, read)
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, source := range []string{
+		``,
+		`messages`,
+		`.messages[`,
+		`.messages[] | select(.a ==)`,
+		`.messages[] | limit(x)`,
+		`.messages[] | bogus`,
+		`.a..b`,
+		`.a | {b: .c[]}`,
+		`select(.a[] == 1)`,
+		`.a = 1`,
+		`."unterminated`,
+	} {
+		_, err := query.Parse(source)
+		require.Equal(t, query.ErrInvalidQuery, errors.Cause(err), source)
+	}
+}
@@ -3,6 +3,7 @@ import * as sync9 from './sync9-src'
 import * as dumb from './dumb-src'
 import * as utils from './utils'
 import httpTransport from './transport.http'
+import websocketTransport from './transport.websocket'
 // import * as webrtcTransport from './transport.webrtc'
 import rpcTransport from './transport.rpc'
 
@@ -17,9 +18,12 @@ export default {
 }
 
 function createPeer(opts) {
-    const { httpHost, identity, webrtc, onFoundPeersCallback, rpcEndpoint } = opts
+    const { httpHost, websocketHost, identity, webrtc, onFoundPeersCallback, rpcEndpoint } = opts
 
     const transports = [ httpTransport({ onFoundPeers, httpHost, peerID: identity.peerID }) ]
+    if (websocketHost) {
+        transports.push(websocketTransport({ onFoundPeers, websocketHost, identity }))
+    }
     // if (webrtc === true) {
     //     transports.push(webrtcTransport({ onFoundPeers, peerID: identity.peerID }))
     // }
@@ -0,0 +1,178 @@
+// The websocket transport speaks the same protocol as Go nodes' websocket
+// transport.  Each connection is split into channels, and every request opens a
+// new one.  We dialed the connection, so we use odd channel IDs, and the node
+// uses even ones.
+
+export default function (opts) {
+    const { websocketHost, identity, onFoundPeers } = opts
+
+    let ws
+    let connected
+    let nextChannelID = 1
+    let channels = {}
+    let subscriptions = {}
+
+    function connect() {
+        if (connected) {
+            return connected
+        }
+        connected = new Promise((resolve, reject) => {
+            ws = new WebSocket(websocketHost)
+            ws.onopen = () => resolve()
+            ws.onerror = (err) => reject(err)
+            ws.onclose = () => {
+                connected = null
+                for (let id of Object.keys(channels)) {
+                    closeChannelLocally(id)
+                }
+            }
+            ws.onmessage = (event) => onFrame(JSON.parse(event.data))
+        })
+        return connected
+    }
+
+    function onFrame(frame) {
+        let channel = channels[frame.channel]
+        if (!channel) {
+            if (frame.channel % 2 === 1 || frame.close) {
+                return
+            }
+            // The node opened a channel
+            channel = openChannel(frame.channel)
+            channel.onMsg = (msg) => handleIncomingMsg(channel, msg)
+        }
+
+        if (frame.close) {
+            closeChannelLocally(frame.channel)
+        } else if (channel.onMsg) {
+            channel.onMsg(frame.msg)
+        }
+    }
+
+    function openChannel(id) {
+        if (id === undefined) {
+            id = nextChannelID
+            nextChannelID += 2
+        }
+        const channel = {
+            id,
+            onMsg: null,
+            onClose: null,
+            write: (msg) => ws.send(JSON.stringify({ channel: id, msg })),
+            close: () => {
+                if (channels[id]) {
+                    ws.send(JSON.stringify({ channel: id, close: true }))
+                    closeChannelLocally(id)
+                }
+            },
+        }
+        channels[id] = channel
+        return channel
+    }
+
+    function closeChannelLocally(id) {
+        const channel = channels[id]
+        if (!channel) {
+            return
+        }
+        delete channels[id]
+        if (channel.onClose) {
+            channel.onClose()
+        }
+    }
+
+    function handleIncomingMsg(channel, msg) {
+        switch (msg.type) {
+        case 'challenge identity':
+            // The node verifies our address as soon as we connect
+            const challenge = Buffer.from(msg.payload, 'hex')
+            const sigHex = identity.signBytes(challenge)
+            channel.write({
+                type: 'challenge identity response',
+                payload: [{ signature: Buffer.from(sigHex, 'hex').toString('base64'), encryptingPublicKey: null }],
+            })
+            channel.close()
+            break
+
+        case 'announce peers':
+            const peers = {}
+            for (let { TransportName, DialAddr } of msg.payload) {
+                peers[TransportName] = peers[TransportName] || {}
+                peers[TransportName][DialAddr] = true
+            }
+            onFoundPeers(peers)
+            channel.close()
+            break
+
+        default:
+            channel.close()
+        }
+    }
+
+    async function authorize() {
+        await connect()
+    }
+
+    async function subscribe({ stateURI, fromTxID, txs, callback }) {
+        if (!txs) {
+            return
+        }
+        try {
+            await connect()
+        } catch (err) {
+            callback('websocket transport: ' + err)
+            return
+        }
+
+        const channel = openChannel()
+        channel.onMsg = (msg) => {
+            if (msg.type !== 'put') {
+                return
+            }
+            const tx = msg.payload
+            channel.write({ type: 'ack', payload: { stateURI: tx.stateURI, txID: tx.id } })
+            callback(null, { tx })
+        }
+        channel.onClose = () => {
+            delete subscriptions[stateURI]
+            callback('websocket transport: subscription closed')
+        }
+        channel.write({
+            type: 'subscribe',
+            payload: { stateURI, fetchHistoryOpts: fromTxID ? { fromTxID } : {} },
+        })
+        subscriptions[stateURI] = channel
+
+        return () => {
+            channel.onClose = null
+            delete subscriptions[stateURI]
+            channel.close()
+        }
+    }
+
+    async function put(tx) {
+        await connect()
+
+        const msg = { type: 'put', payload: tx }
+
+        // If we're subscribed to the state URI, the tx goes out on the same channel
+        if (subscriptions[tx.stateURI]) {
+            subscriptions[tx.stateURI].write(msg)
+            return
+        }
+        const channel = openChannel()
+        channel.onMsg = () => channel.close()
+        channel.write(msg)
+    }
+
+    function foundPeers(peers) {}
+
+    return {
+        transportName:   () => 'websocket',
+        altSvcAddresses: () => [],
+        subscribe,
+        put,
+        authorize,
+        foundPeers,
+    }
+}
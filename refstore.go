@@ -29,6 +29,7 @@ type RefStore interface {
 	Object(refID types.RefID) (io.ReadCloser, int64, error)
 	ObjectFilepath(refID types.RefID) (string, error)
 	StoreObject(reader io.ReadCloser) (sha1Hash types.Hash, sha3Hash types.Hash, err error)
+	DeleteObject(refID types.RefID) error
 	AllHashes() ([]types.RefID, error)
 
 	RefsNeeded() ([]types.RefID, error)
@@ -242,6 +243,50 @@ func (s *refStore) StoreObject(reader io.ReadCloser) (sha1Hash types.Hash, sha3H
 	return sha1Hash, sha3Hash, nil
 }
 
+// DeleteObject removes a ref's blob and its sha1<->sha3 mapping.
+func (s *refStore) DeleteObject(refID types.RefID) (err error) {
+	s.fileMu.Lock()
+	defer s.fileMu.Unlock()
+	defer utils.Annotate(&err, "refStore.DeleteObject")
+
+	var sha1Hash, sha3Hash types.Hash
+	switch refID.HashAlg {
+	case types.SHA1:
+		sha1Hash = refID.Hash
+		sha3Hash, err = s.sha3ForSHA1(sha1Hash)
+		if err != nil {
+			return err
+		}
+	case types.SHA3:
+		sha3Hash = refID.Hash
+		sha1Hash, err = s.sha1ForSHA3(sha3Hash)
+		if err != nil {
+			return err
+		}
+	default:
+		return errors.Errorf("unknown hash type '%v'", refID.HashAlg)
+	}
+
+	err = os.Remove(s.filepathForSHA3Blob(sha3Hash))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.WithStack(err)
+	}
+
+	err = s.metadata.Update(func(txn *badger.Txn) error {
+		err := txn.Delete(append(sha1Hash[:20], []byte(":sha3")...))
+		if err != nil {
+			return err
+		}
+		return txn.Delete(append(sha3Hash[:], []byte(":sha1")...))
+	})
+	if err != nil {
+		return errors.Wrap(err, "error deleting sha1<->sha3 mapping for ref")
+	}
+
+	s.Successf("deleted ref (sha1: %v, sha3: %v)", sha1Hash.Hex(), sha3Hash.Hex())
+	return nil
+}
+
 func (s *refStore) AllHashes() ([]types.RefID, error) {
 	s.fileMu.Lock()
 	defer s.fileMu.Unlock()
@@ -2,48 +2,59 @@ package remotestore
 
 import (
 	"context"
+	"encoding/binary"
 	"encoding/json"
 	"io"
+	"sort"
+	"time"
 
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"redwood.dev"
 	"redwood.dev/crypto"
 	"redwood.dev/ctx"
+	"redwood.dev/identity"
 	"redwood.dev/types"
+	"redwood.dev/utils"
 )
 
+// client is a TxStore backed by a remote blind store.  Everything that it
+// sends to the server is either encrypted with the node's encrypting keypair
+// or replaced with an opaque key derived from that keypair, so the server
+// can't see which state URIs and txs it's holding.
 type client struct {
 	ctx.Logger
-	host       string
-	address    types.Address
-	sigprivkey crypto.SigningPrivateKey
-	client     RemoteStoreClient
-	conn       *grpc.ClientConn
-	jwt        string
+	host      string
+	sigkeys   *crypto.SigningKeypair
+	enckeys   *crypto.EncryptingKeypair
+	keySecret types.Hash
+	client    RemoteStoreClient
+	conn      *grpc.ClientConn
+	jwt       string
 }
 
 // client should conform to redwood.TxStore
 var _ redwood.TxStore = (*client)(nil)
 
-func NewClient(host string, address types.Address, sigprivkey crypto.SigningPrivateKey) *client {
+func NewClient(host string, identity identity.Identity) *client {
 	return &client{
-		Logger:     ctx.NewLogger("vault client"),
-		host:       host,
-		address:    address,
-		client:     nil,
-		conn:       nil,
-		sigprivkey: sigprivkey,
-		jwt:        "",
+		Logger:    ctx.NewLogger("vault client"),
+		host:      host,
+		sigkeys:   identity.Signing,
+		enckeys:   identity.Encrypting,
+		keySecret: types.HashBytes(append([]byte("redwood remotestore:"), identity.Encrypting.EncryptingPrivateKey.Bytes()...)),
+		client:    nil,
+		conn:      nil,
+		jwt:       "",
 	}
 }
 
 func (c *client) Start() error {
 	c.Infof(0, "opening remote store at %v", c.host)
 
-	// handshaker := newGrpcHandshaker(nil, c.sigprivkey)
-
 	conn, err := grpc.Dial(c.host,
 		UnaryClientJWT(c),
 		StreamClientJWT(c),
@@ -83,7 +94,7 @@ func (c *client) Authenticate() error {
 		return errors.New("protocol error")
 	}
 
-	sig, err := c.sigprivkey.SignHash(types.HashBytes(challenge.Challenge))
+	sig, err := c.sigkeys.SignHash(types.HashBytes(challenge.Challenge))
 	if err != nil {
 		return err
 	}
@@ -111,45 +122,160 @@ func (c *client) Authenticate() error {
 	return nil
 }
 
-func (c *client) AddTx(tx *redwood.Tx) error {
-	// @@TODO: don't use json
-	txBytes, err := json.Marshal(tx)
+func (c *client) AddTx(tx *redwood.Tx) (err error) {
+	defer utils.Annotate(&err, "remotestore client#AddTx")
+
+	err = c.putTx(tx)
 	if err != nil {
 		return err
 	}
 
-	//////////////////
-	////// encrypt tx bytes
-	//////////////////
+	// Keep track of which txs are waiting in the mempool, and since when, so
+	// that the mempool can be restored after a restart
+	mempool := c.opaqueKey("mempool", tx.StateURI)
+	txKey := c.txKey(tx.StateURI, tx.ID)
+	if tx.Status == redwood.TxStatusInMempool {
+		_, err := c.fetchRecord(mempool, txKey)
+		if errors.Cause(err) == types.Err404 {
+			received := make([]byte, 8)
+			binary.BigEndian.PutUint64(received, uint64(time.Now().UnixNano()))
+			err = c.setRecord(mempool, txKey, append(tx.ID.Bytes(), received...))
+		}
+		if err != nil {
+			return err
+		}
+	} else {
+		err := c.deleteRecord(mempool, txKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Add the new tx to the `.Children` slice on each of its parents
+	if tx.Status == redwood.TxStatusValid {
+		for _, parentID := range tx.Parents {
+			parentTx, err := c.FetchTx(tx.StateURI, parentID)
+			if errors.Cause(err) == types.Err404 {
+				// History older than an imported snapshot is never fetched
+				continue
+			} else if err != nil {
+				return errors.Wrapf(err, "can't find parent %v of tx %v", parentID, tx.ID)
+			}
+
+			parentTx.Children = utils.NewIDSet(parentTx.Children).Add(tx.ID).Slice()
 
-	txHash := tx.Hash()
-	_, err = c.client.AddTx(context.TODO(), &AddTxRequest{TxHash: txHash[:], TxBytes: txBytes})
+			err = c.putTx(parentTx)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// We need to keep track of all of the state URIs we know about
+	err = c.setRecord(c.opaqueKey("stateURIs"), c.stateURIKey(tx.StateURI), []byte(tx.StateURI))
 	if err != nil {
 		return err
 	}
 
-	c.Infof(0, "wrote tx %v", tx.Hash())
+	c.Infof(0, "wrote tx %v (status: %v)", tx.ID.Pretty(), tx.Status)
 	return nil
 }
 
+func (c *client) putTx(tx *redwood.Tx) error {
+	bs, err := tx.MarshalProto()
+	if err != nil {
+		return err
+	}
+	encrypted, err := c.encrypt(bs)
+	if err != nil {
+		return err
+	}
+	_, err = c.client.AddTx(context.TODO(), &AddTxRequest{
+		StateURIKey: c.stateURIKey(tx.StateURI),
+		TxKey:       c.txKey(tx.StateURI, tx.ID),
+		TxBytes:     encrypted,
+	})
+	return errors.WithStack(err)
+}
+
+func (c *client) RemoveTx(stateURI string, txID types.ID) error {
+	err := c.deleteRecord(c.opaqueKey("mempool", stateURI), c.txKey(stateURI, txID))
+	if err != nil {
+		return err
+	}
+	_, err = c.client.RemoveTx(context.TODO(), &RemoveTxRequest{
+		StateURIKey: c.stateURIKey(stateURI),
+		TxKey:       c.txKey(stateURI, txID),
+	})
+	return errors.WithStack(err)
+}
+
+// TxExists returns true if the tx is in the store, or if it was pruned from it.
+func (c *client) TxExists(stateURI string, txID types.ID) (bool, error) {
+	_, err := c.FetchTx(stateURI, txID)
+	if err == nil {
+		return true, nil
+	} else if errors.Cause(err) != types.Err404 {
+		return false, err
+	}
+
+	_, err = c.fetchRecord(c.opaqueKey("pruned", stateURI), c.txKey(stateURI, txID))
+	if errors.Cause(err) == types.Err404 {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (c *client) FetchTx(stateURI string, txID types.ID) (*redwood.Tx, error) {
+	resp, err := c.client.FetchTx(context.TODO(), &FetchTxRequest{
+		StateURIKey: c.stateURIKey(stateURI),
+		TxKey:       c.txKey(stateURI, txID),
+	})
+	if err != nil {
+		return nil, wrapNotFound(err)
+	}
+	return c.decodeTx(resp.TxBytes)
+}
+
+// AllTxsForStateURI iterates over fromTxID and all of its descendants in
+// topological order (every tx comes after all of its parents).  If fromTxID
+// has been pruned, iteration starts at the oldest retained checkpoint's
+// frontier instead (see redwood.HistoryRoots).
 func (c *client) AllTxsForStateURI(stateURI string, fromTxID types.ID) redwood.TxIterator {
 	txIter := &txIterator{
 		ch:       make(chan *redwood.Tx),
 		chCancel: make(chan struct{}),
 	}
 
-	resp, err := c.client.AllTxs(context.TODO(), &AllTxsRequest{})
+	roots, err := redwood.HistoryRoots(c, stateURI, fromTxID)
 	if err != nil {
-		return &txIterator{err: err}
+		txIter.err = err
+		close(txIter.ch)
+		return txIter
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	resp, err := c.client.AllTxs(ctx, &AllTxsRequest{StateURIKey: c.stateURIKey(stateURI)})
+	if err != nil {
+		cancel()
+		txIter.err = errors.WithStack(err)
+		close(txIter.ch)
+		return txIter
 	}
 
 	go func() {
 		defer close(txIter.ch)
+		defer cancel()
 
+		// The server can't order txs for us, so we fetch all of them first
+		txs := make(map[types.ID]*redwood.Tx)
 		for {
 			pkt, err := resp.Recv()
 			if err == io.EOF {
-				return
+				break
 			} else if err != nil {
 				txIter.err = err
 				return
@@ -160,28 +286,281 @@ func (c *client) AllTxsForStateURI(stateURI string, fromTxID types.ID) redwood.T
 				txIter.err = err
 				return
 			}
+			txs[tx.ID] = tx
+		}
+
+		// Count how many of each descendant's parents are also descendants
+		numParents := make(map[types.ID]int)
+		var queue []types.ID
+		for _, txID := range roots {
+			if _, exists := txs[txID]; !exists {
+				txIter.err = errors.Wrapf(types.Err404, "tx %v", txID.Pretty())
+				return
+			} else if _, seen := numParents[txID]; !seen {
+				numParents[txID] = 0
+				queue = append(queue, txID)
+			}
+		}
+		starts := append([]types.ID(nil), queue...)
+		for len(queue) > 0 {
+			txID := queue[0]
+			queue = queue[1:]
+
+			for _, childID := range txs[txID].Children {
+				if _, exists := txs[childID]; !exists {
+					continue
+				} else if _, seen := numParents[childID]; !seen {
+					queue = append(queue, childID)
+				}
+				numParents[childID]++
+			}
+		}
+
+		// Then send each tx once all of its parents have been sent
+		for _, txID := range starts {
+			if numParents[txID] == 0 {
+				queue = append(queue, txID)
+			}
+		}
+		for len(queue) > 0 {
+			txID := queue[0]
+			queue = queue[1:]
+
+			select {
+			case <-txIter.chCancel:
+				return
+			case txIter.ch <- txs[txID]:
+			}
 
-			txIter.ch <- tx
+			for _, childID := range txs[txID].Children {
+				if _, exists := numParents[childID]; !exists {
+					continue
+				}
+				numParents[childID]--
+				if numParents[childID] == 0 {
+					queue = append(queue, childID)
+				}
+			}
 		}
 	}()
 
 	return txIter
 }
 
-func (c *client) FetchTx(stateURI string, txID types.ID) (*redwood.Tx, error) { panic("unimplemented") }
-func (c *client) TxExists(stateURI string, txID types.ID) (bool, error)       { panic("unimplemented") }
-func (c *client) RemoveTx(stateURI string, txID types.ID) error               { panic("unimplemented") }
-func (c *client) KnownStateURIs() ([]string, error)                           { panic("unimplemented") }
-func (c *client) MarkLeaf(stateURI string, txID types.ID) error               { panic("unimplemented") }
-func (c *client) UnmarkLeaf(stateURI string, txID types.ID) error             { panic("unimplemented") }
-func (c *client) Leaves(stateURI string) ([]types.ID, error)                  { panic("unimplemented") }
+func (c *client) KnownStateURIs() ([]string, error) {
+	var stateURIs []string
+	err := c.allRecords(c.opaqueKey("stateURIs"), func(key, val []byte) error {
+		stateURIs = append(stateURIs, string(val))
+		return nil
+	})
+	return stateURIs, err
+}
+
+func (c *client) MarkLeaf(stateURI string, txID types.ID) error {
+	return c.setRecord(c.opaqueKey("leaves", stateURI), c.txKey(stateURI, txID), txID.Bytes())
+}
+
+func (c *client) UnmarkLeaf(stateURI string, txID types.ID) error {
+	return c.deleteRecord(c.opaqueKey("leaves", stateURI), c.txKey(stateURI, txID))
+}
+
+func (c *client) Leaves(stateURI string) ([]types.ID, error) {
+	var leaves []types.ID
+	err := c.allRecords(c.opaqueKey("leaves", stateURI), func(key, val []byte) error {
+		leaves = append(leaves, types.IDFromBytes(val))
+		return nil
+	})
+	return leaves, err
+}
+
+func (c *client) MempoolTxs(stateURI string) ([]redwood.MempoolEntry, error) {
+	type pending struct {
+		txID     types.ID
+		received time.Time
+	}
+	var pendings []pending
+	err := c.allRecords(c.opaqueKey("mempool", stateURI), func(key, val []byte) error {
+		if len(val) != len(types.ID{})+8 {
+			return errors.New("bad mempool entry")
+		}
+		pendings = append(pendings, pending{
+			txID:     types.IDFromBytes(val[:len(types.ID{})]),
+			received: time.Unix(0, int64(binary.BigEndian.Uint64(val[len(types.ID{}):]))),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []redwood.MempoolEntry
+	for _, p := range pendings {
+		tx, err := c.FetchTx(stateURI, p.txID)
+		if errors.Cause(err) == types.Err404 {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		entries = append(entries, redwood.MempoolEntry{Tx: tx, Received: p.received})
+	}
+	return entries, nil
+}
+
+func (c *client) checkpointKey(stateURI string, checkpoint redwood.Checkpoint) []byte {
+	created := make([]byte, 8)
+	binary.BigEndian.PutUint64(created, uint64(checkpoint.Created.UnixNano()))
+	return c.opaqueKey("checkpoint", stateURI, string(checkpoint.TxID[:]), string(created))
+}
+
+func (c *client) AddCheckpoint(stateURI string, checkpoint redwood.Checkpoint) error {
+	bs, err := json.Marshal(checkpoint)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return c.setRecord(c.opaqueKey("checkpoints", stateURI), c.checkpointKey(stateURI, checkpoint), bs)
+}
+
+func (c *client) RemoveCheckpoint(stateURI string, checkpoint redwood.Checkpoint) error {
+	return c.deleteRecord(c.opaqueKey("checkpoints", stateURI), c.checkpointKey(stateURI, checkpoint))
+}
+
+// Checkpoints returns the checkpoints recorded for the given state URI, oldest first.
+func (c *client) Checkpoints(stateURI string) ([]redwood.Checkpoint, error) {
+	var checkpoints []redwood.Checkpoint
+	err := c.allRecords(c.opaqueKey("checkpoints", stateURI), func(key, val []byte) error {
+		var checkpoint redwood.Checkpoint
+		err := json.Unmarshal(val, &checkpoint)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		checkpoints = append(checkpoints, checkpoint)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Keys are opaque, so the server returns them in no meaningful order
+	sort.SliceStable(checkpoints, func(i, j int) bool {
+		return checkpoints[i].Created.Before(checkpoints[j].Created)
+	})
+	return checkpoints, nil
+}
+
+// PruneTxs deletes the given txs, but remembers that they existed so that they
+// aren't accepted again if a peer resends them.
+func (c *client) PruneTxs(stateURI string, txIDs []types.ID) error {
+	for _, txID := range txIDs {
+		err := c.RemoveTx(stateURI, txID)
+		if err != nil {
+			return err
+		}
+		err = c.setRecord(c.opaqueKey("pruned", stateURI), c.txKey(stateURI, txID), nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setRecord seals val with the node's encrypting keypair before sending it,
+// so leaves, mempool entries and checkpoints are as opaque to the server as
+// tx bodies are.  allRecords and fetchRecord open them again.
+func (c *client) setRecord(collection, key, val []byte) error {
+	encrypted, err := c.encrypt(val)
+	if err != nil {
+		return err
+	}
+	_, err = c.client.SetRecord(context.TODO(), &SetRecordRequest{Collection: collection, Key: key, Value: encrypted})
+	return errors.WithStack(err)
+}
+
+func (c *client) deleteRecord(collection, key []byte) error {
+	_, err := c.client.DeleteRecord(context.TODO(), &DeleteRecordRequest{Collection: collection, Key: key})
+	return errors.WithStack(err)
+}
+
+func (c *client) fetchRecord(collection, key []byte) ([]byte, error) {
+	resp, err := c.client.FetchRecord(context.TODO(), &FetchRecordRequest{Collection: collection, Key: key})
+	if err != nil {
+		return nil, wrapNotFound(err)
+	}
+	return c.decrypt(resp.Value)
+}
+
+func (c *client) allRecords(collection []byte, fn func(key, val []byte) error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	resp, err := c.client.AllRecords(ctx, &AllRecordsRequest{Collection: collection})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	for {
+		pkt, err := resp.Recv()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return errors.WithStack(err)
+		}
+
+		val, err := c.decrypt(pkt.Value)
+		if err != nil {
+			return err
+		}
+
+		err = fn(pkt.Key, val)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// opaqueKey derives a key that the server can use to look up data without
+// learning anything about the state URIs or txs that it refers to.
+func (c *client) opaqueKey(parts ...string) []byte {
+	bs := append([]byte{}, c.keySecret[:]...)
+	for _, part := range parts {
+		bs = append(bs, 0)
+		bs = append(bs, part...)
+	}
+	h := types.HashBytes(bs)
+	return h[:]
+}
+
+func (c *client) stateURIKey(stateURI string) []byte {
+	return c.opaqueKey("stateURI", stateURI)
+}
+
+func (c *client) txKey(stateURI string, txID types.ID) []byte {
+	return c.opaqueKey("tx", stateURI, string(txID[:]))
+}
+
+func (c *client) encrypt(bs []byte) ([]byte, error) {
+	return c.enckeys.SealMessageFor(c.enckeys.EncryptingPublicKey, bs)
+}
+
+func (c *client) decrypt(bs []byte) ([]byte, error) {
+	return c.enckeys.OpenMessageFrom(c.enckeys.EncryptingPublicKey, bs)
+}
 
 func (c *client) decodeTx(txBytes []byte) (*redwood.Tx, error) {
+	bs, err := c.decrypt(txBytes)
+	if err != nil {
+		return nil, err
+	}
 	var tx redwood.Tx
-	err := json.Unmarshal(txBytes, &tx)
+	err = tx.UnmarshalProto(bs)
 	return &tx, err
 }
 
+func wrapNotFound(err error) error {
+	if status.Code(err) == codes.NotFound {
+		return errors.WithStack(types.Err404)
+	}
+	return errors.WithStack(err)
+}
+
 type txIterator struct {
 	ch       chan *redwood.Tx
 	chCancel chan struct{}
@@ -0,0 +1,304 @@
+package remotestore_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v2"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"redwood.dev"
+	"redwood.dev/crypto"
+	"redwood.dev/identity"
+	"redwood.dev/remotestore"
+	"redwood.dev/types"
+)
+
+func randomIdentity(t *testing.T) identity.Identity {
+	t.Helper()
+
+	sigkeys, err := crypto.GenerateSigningKeypair()
+	require.NoError(t, err)
+	enckeys, err := crypto.GenerateEncryptingKeypair()
+	require.NoError(t, err)
+	return identity.Identity{Signing: sigkeys, Encrypting: enckeys}
+}
+
+func setupServer(t *testing.T, allowed ...identity.Identity) (host string, dbPath string, closeServer func(), cleanup func()) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "remotestore-test-")
+	require.NoError(t, err)
+	dbPath = filepath.Join(dir, "db")
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	listenAddr := listener.Addr().String()
+	listener.Close()
+
+	var allowedAddrs []types.Address
+	for _, id := range allowed {
+		allowedAddrs = append(allowedAddrs, id.Address())
+	}
+
+	server := remotestore.NewServer("tcp", listenAddr, dbPath, allowedAddrs)
+	err = server.Start()
+	require.NoError(t, err)
+
+	var closed bool
+	closeServer = func() {
+		if !closed {
+			server.Close()
+			closed = true
+		}
+	}
+	cleanup = func() {
+		closeServer()
+		os.RemoveAll(dir)
+	}
+	return listenAddr, dbPath, closeServer, cleanup
+}
+
+func mustParsePatch(t *testing.T, s string) redwood.Patch {
+	t.Helper()
+	patch, err := redwood.ParsePatch([]byte(s))
+	require.NoError(t, err)
+	return patch
+}
+
+func TestClient_Authentication(t *testing.T) {
+	allowed := randomIdentity(t)
+
+	host, _, _, cleanup := setupServer(t, allowed)
+	defer cleanup()
+
+	c := remotestore.NewClient(host, randomIdentity(t))
+	err := c.Start()
+	require.Error(t, err)
+	c.Close()
+
+	c = remotestore.NewClient(host, allowed)
+	err = c.Start()
+	require.NoError(t, err)
+	c.Close()
+}
+
+func TestClient_TxStore(t *testing.T) {
+	id := randomIdentity(t)
+
+	host, dbPath, closeServer, cleanup := setupServer(t, id)
+	defer cleanup()
+
+	c := remotestore.NewClient(host, id)
+	err := c.Start()
+	require.NoError(t, err)
+	defer c.Close()
+
+	stateURI := "secret.com/blah"
+	genesis := &redwood.Tx{
+		StateURI: stateURI,
+		ID:       redwood.GenesisTxID,
+		Patches:  []redwood.Patch{mustParsePatch(t, `. = {"plaintext": "hunter2"}`)},
+		Status:   redwood.TxStatusValid,
+	}
+	tx1 := &redwood.Tx{
+		StateURI: stateURI,
+		ID:       types.RandomID(),
+		Parents:  []types.ID{genesis.ID},
+		Patches:  []redwood.Patch{mustParsePatch(t, `.foo = 1`)},
+		Status:   redwood.TxStatusValid,
+	}
+	tx2 := &redwood.Tx{
+		StateURI: stateURI,
+		ID:       types.RandomID(),
+		Parents:  []types.ID{genesis.ID},
+		Patches:  []redwood.Patch{mustParsePatch(t, `.bar = 2`)},
+		Status:   redwood.TxStatusValid,
+	}
+	tx3 := &redwood.Tx{
+		StateURI: stateURI,
+		ID:       types.RandomID(),
+		Parents:  []types.ID{tx1.ID, tx2.ID},
+		Patches:  []redwood.Patch{mustParsePatch(t, `.baz = 3`)},
+		Status:   redwood.TxStatusInMempool,
+	}
+
+	for _, tx := range []*redwood.Tx{genesis, tx1, tx2, tx3} {
+		err := c.AddTx(tx)
+		require.NoError(t, err)
+	}
+
+	t.Run("fetches txs", func(t *testing.T) {
+		fetched, err := c.FetchTx(stateURI, tx1.ID)
+		require.NoError(t, err)
+		require.Equal(t, tx1.ID, fetched.ID)
+		require.Equal(t, tx1.Parents, fetched.Parents)
+		require.Equal(t, tx1.Patches[0].String(), fetched.Patches[0].String())
+
+		fetched, err = c.FetchTx(stateURI, genesis.ID)
+		require.NoError(t, err)
+		require.ElementsMatch(t, []types.ID{tx1.ID, tx2.ID}, fetched.Children)
+
+		_, err = c.FetchTx(stateURI, types.RandomID())
+		require.True(t, errors.Cause(err) == types.Err404)
+
+		exists, err := c.TxExists(stateURI, tx2.ID)
+		require.NoError(t, err)
+		require.True(t, exists)
+
+		exists, err = c.TxExists("other.com/blah", tx2.ID)
+		require.NoError(t, err)
+		require.False(t, exists)
+	})
+
+	t.Run("iterates over txs in topological order", func(t *testing.T) {
+		iter := c.AllTxsForStateURI(stateURI, types.ID{})
+		defer iter.Cancel()
+
+		var txIDs []types.ID
+		for tx := iter.Next(); tx != nil; tx = iter.Next() {
+			txIDs = append(txIDs, tx.ID)
+		}
+		require.NoError(t, iter.Error())
+		require.Len(t, txIDs, 3)
+		require.Equal(t, genesis.ID, txIDs[0])
+		require.ElementsMatch(t, []types.ID{tx1.ID, tx2.ID}, txIDs[1:])
+	})
+
+	t.Run("tracks leaves, state URIs and the mempool", func(t *testing.T) {
+		err := c.MarkLeaf(stateURI, tx1.ID)
+		require.NoError(t, err)
+		err = c.MarkLeaf(stateURI, tx2.ID)
+		require.NoError(t, err)
+		err = c.UnmarkLeaf(stateURI, tx1.ID)
+		require.NoError(t, err)
+
+		leaves, err := c.Leaves(stateURI)
+		require.NoError(t, err)
+		require.Equal(t, []types.ID{tx2.ID}, leaves)
+
+		stateURIs, err := c.KnownStateURIs()
+		require.NoError(t, err)
+		require.Equal(t, []string{stateURI}, stateURIs)
+
+		entries, err := c.MempoolTxs(stateURI)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		require.Equal(t, tx3.ID, entries[0].Tx.ID)
+		require.WithinDuration(t, time.Now(), entries[0].Received, time.Minute)
+	})
+
+	t.Run("tracks checkpoints", func(t *testing.T) {
+		now := time.Now().UTC().Round(0)
+		checkpoint1 := redwood.Checkpoint{TxID: tx1.ID, Frontier: []types.ID{tx1.ID}, Created: now}
+		checkpoint2 := redwood.Checkpoint{TxID: genesis.ID, Frontier: []types.ID{genesis.ID}, Created: now.Add(-time.Hour)}
+
+		err := c.AddCheckpoint(stateURI, checkpoint1)
+		require.NoError(t, err)
+		err = c.AddCheckpoint(stateURI, checkpoint2)
+		require.NoError(t, err)
+
+		checkpoints, err := c.Checkpoints(stateURI)
+		require.NoError(t, err)
+		require.Len(t, checkpoints, 2)
+		require.Equal(t, checkpoint2.TxID, checkpoints[0].TxID)
+		require.Equal(t, checkpoint1.TxID, checkpoints[1].TxID)
+
+		err = c.RemoveCheckpoint(stateURI, checkpoint2)
+		require.NoError(t, err)
+
+		checkpoints, err = c.Checkpoints(stateURI)
+		require.NoError(t, err)
+		require.Len(t, checkpoints, 1)
+		require.Equal(t, checkpoint1.TxID, checkpoints[0].TxID)
+	})
+
+	t.Run("prunes txs", func(t *testing.T) {
+		err := c.PruneTxs(stateURI, []types.ID{tx3.ID})
+		require.NoError(t, err)
+
+		_, err = c.FetchTx(stateURI, tx3.ID)
+		require.True(t, errors.Cause(err) == types.Err404)
+
+		exists, err := c.TxExists(stateURI, tx3.ID)
+		require.NoError(t, err)
+		require.True(t, exists)
+
+		entries, err := c.MempoolTxs(stateURI)
+		require.NoError(t, err)
+		require.Len(t, entries, 0)
+	})
+
+	t.Run("the server can't read anything", func(t *testing.T) {
+		c.Close()
+		closeServer()
+
+		db, err := badger.Open(badger.DefaultOptions(dbPath).WithLogger(nil))
+		require.NoError(t, err)
+		defer db.Close()
+
+		err = db.View(func(txn *badger.Txn) error {
+			iter := txn.NewIterator(badger.DefaultIteratorOptions)
+			defer iter.Close()
+
+			for iter.Rewind(); iter.Valid(); iter.Next() {
+				val, err := iter.Item().ValueCopy(nil)
+				require.NoError(t, err)
+
+				for _, bs := range [][]byte{iter.Item().Key(), val} {
+					require.False(t, bytes.Contains(bs, []byte(stateURI)))
+					require.False(t, bytes.Contains(bs, []byte("hunter2")))
+					for _, tx := range []*redwood.Tx{tx1, tx2, tx3} {
+						require.False(t, bytes.Contains(bs, tx.ID[:]))
+					}
+				}
+			}
+			return nil
+		})
+		require.NoError(t, err)
+	})
+}
+
+func TestReplicatedTxStore(t *testing.T) {
+	id := randomIdentity(t)
+
+	host, _, _, cleanup := setupServer(t, id)
+	defer cleanup()
+
+	dir, err := ioutil.TempDir("", "remotestore-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	remote := remotestore.NewClient(host, id)
+	txStore := redwood.NewReplicatedTxStore(redwood.NewBadgerTxStore(dir), []redwood.TxStoreReplica{{Name: host, TxStore: remote}}, redwood.DefaultReplicatedTxStoreOpts)
+	err = txStore.Start()
+	require.NoError(t, err)
+	defer txStore.Close()
+
+	tx := &redwood.Tx{
+		StateURI: "foo.com/bar",
+		ID:       redwood.GenesisTxID,
+		Patches:  []redwood.Patch{mustParsePatch(t, `. = {}`)},
+		Status:   redwood.TxStatusValid,
+	}
+	err = txStore.AddTx(tx)
+	require.NoError(t, err)
+	err = txStore.MarkLeaf(tx.StateURI, tx.ID)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		leaves, err := remote.Leaves(tx.StateURI)
+		require.NoError(t, err)
+		return len(leaves) == 1 && leaves[0] == tx.ID
+	}, 5*time.Second, 10*time.Millisecond)
+
+	fetched, err := remote.FetchTx(tx.StateURI, tx.ID)
+	require.NoError(t, err)
+	require.Equal(t, tx.ID, fetched.ID)
+}
@@ -129,8 +129,10 @@ type AuthenticateMessage_AuthenticateChallenge struct {
 func (m *AuthenticateMessage_AuthenticateChallenge) Reset() {
 	*m = AuthenticateMessage_AuthenticateChallenge{}
 }
-func (m *AuthenticateMessage_AuthenticateChallenge) String() string { return proto.CompactTextString(m) }
-func (*AuthenticateMessage_AuthenticateChallenge) ProtoMessage()    {}
+func (m *AuthenticateMessage_AuthenticateChallenge) String() string {
+	return proto.CompactTextString(m)
+}
+func (*AuthenticateMessage_AuthenticateChallenge) ProtoMessage() {}
 func (*AuthenticateMessage_AuthenticateChallenge) Descriptor() ([]byte, []int) {
 	return fileDescriptor_946aaa5bc4ff2f06, []int{0, 0}
 }
@@ -170,8 +172,10 @@ type AuthenticateMessage_AuthenticateSignature struct {
 func (m *AuthenticateMessage_AuthenticateSignature) Reset() {
 	*m = AuthenticateMessage_AuthenticateSignature{}
 }
-func (m *AuthenticateMessage_AuthenticateSignature) String() string { return proto.CompactTextString(m) }
-func (*AuthenticateMessage_AuthenticateSignature) ProtoMessage()    {}
+func (m *AuthenticateMessage_AuthenticateSignature) String() string {
+	return proto.CompactTextString(m)
+}
+func (*AuthenticateMessage_AuthenticateSignature) ProtoMessage() {}
 func (*AuthenticateMessage_AuthenticateSignature) Descriptor() ([]byte, []int) {
 	return fileDescriptor_946aaa5bc4ff2f06, []int{0, 1}
 }
@@ -243,8 +247,9 @@ func (m *AuthenticateMessage_AuthenticateResponse) GetJwt() string {
 }
 
 type AddTxRequest struct {
-	TxHash               []byte   `protobuf:"bytes,1,opt,name=txHash,proto3" json:"txHash,omitempty"`
-	TxBytes              []byte   `protobuf:"bytes,2,opt,name=txBytes,proto3" json:"txBytes,omitempty"`
+	StateURIKey          []byte   `protobuf:"bytes,1,opt,name=stateURIKey,proto3" json:"stateURIKey,omitempty"`
+	TxKey                []byte   `protobuf:"bytes,2,opt,name=txKey,proto3" json:"txKey,omitempty"`
+	TxBytes              []byte   `protobuf:"bytes,3,opt,name=txBytes,proto3" json:"txBytes,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -275,9 +280,16 @@ func (m *AddTxRequest) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_AddTxRequest proto.InternalMessageInfo
 
-func (m *AddTxRequest) GetTxHash() []byte {
+func (m *AddTxRequest) GetStateURIKey() []byte {
+	if m != nil {
+		return m.StateURIKey
+	}
+	return nil
+}
+
+func (m *AddTxRequest) GetTxKey() []byte {
 	if m != nil {
-		return m.TxHash
+		return m.TxKey
 	}
 	return nil
 }
@@ -321,7 +333,8 @@ func (m *AddTxResponse) XXX_DiscardUnknown() {
 var xxx_messageInfo_AddTxResponse proto.InternalMessageInfo
 
 type RemoveTxRequest struct {
-	TxHash               []byte   `protobuf:"bytes,1,opt,name=txHash,proto3" json:"txHash,omitempty"`
+	StateURIKey          []byte   `protobuf:"bytes,1,opt,name=stateURIKey,proto3" json:"stateURIKey,omitempty"`
+	TxKey                []byte   `protobuf:"bytes,2,opt,name=txKey,proto3" json:"txKey,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -352,9 +365,16 @@ func (m *RemoveTxRequest) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_RemoveTxRequest proto.InternalMessageInfo
 
-func (m *RemoveTxRequest) GetTxHash() []byte {
+func (m *RemoveTxRequest) GetStateURIKey() []byte {
 	if m != nil {
-		return m.TxHash
+		return m.StateURIKey
+	}
+	return nil
+}
+
+func (m *RemoveTxRequest) GetTxKey() []byte {
+	if m != nil {
+		return m.TxKey
 	}
 	return nil
 }
@@ -391,7 +411,8 @@ func (m *RemoveTxResponse) XXX_DiscardUnknown() {
 var xxx_messageInfo_RemoveTxResponse proto.InternalMessageInfo
 
 type FetchTxRequest struct {
-	TxHash               []byte   `protobuf:"bytes,1,opt,name=txHash,proto3" json:"txHash,omitempty"`
+	StateURIKey          []byte   `protobuf:"bytes,1,opt,name=stateURIKey,proto3" json:"stateURIKey,omitempty"`
+	TxKey                []byte   `protobuf:"bytes,2,opt,name=txKey,proto3" json:"txKey,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -422,9 +443,16 @@ func (m *FetchTxRequest) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_FetchTxRequest proto.InternalMessageInfo
 
-func (m *FetchTxRequest) GetTxHash() []byte {
+func (m *FetchTxRequest) GetStateURIKey() []byte {
 	if m != nil {
-		return m.TxHash
+		return m.StateURIKey
+	}
+	return nil
+}
+
+func (m *FetchTxRequest) GetTxKey() []byte {
+	if m != nil {
+		return m.TxKey
 	}
 	return nil
 }
@@ -469,6 +497,7 @@ func (m *FetchTxResponse) GetTxBytes() []byte {
 }
 
 type AllTxsRequest struct {
+	StateURIKey          []byte   `protobuf:"bytes,1,opt,name=stateURIKey,proto3" json:"stateURIKey,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -499,6 +528,13 @@ func (m *AllTxsRequest) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_AllTxsRequest proto.InternalMessageInfo
 
+func (m *AllTxsRequest) GetStateURIKey() []byte {
+	if m != nil {
+		return m.StateURIKey
+	}
+	return nil
+}
+
 type AllTxsResponsePacket struct {
 	TxBytes              []byte   `protobuf:"bytes,1,opt,name=txBytes,proto3" json:"txBytes,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
@@ -538,6 +574,342 @@ func (m *AllTxsResponsePacket) GetTxBytes() []byte {
 	return nil
 }
 
+type SetRecordRequest struct {
+	Collection           []byte   `protobuf:"bytes,1,opt,name=collection,proto3" json:"collection,omitempty"`
+	Key                  []byte   `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	Value                []byte   `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SetRecordRequest) Reset()         { *m = SetRecordRequest{} }
+func (m *SetRecordRequest) String() string { return proto.CompactTextString(m) }
+func (*SetRecordRequest) ProtoMessage()    {}
+func (*SetRecordRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_946aaa5bc4ff2f06, []int{9}
+}
+
+func (m *SetRecordRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetRecordRequest.Unmarshal(m, b)
+}
+func (m *SetRecordRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetRecordRequest.Marshal(b, m, deterministic)
+}
+func (m *SetRecordRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetRecordRequest.Merge(m, src)
+}
+func (m *SetRecordRequest) XXX_Size() int {
+	return xxx_messageInfo_SetRecordRequest.Size(m)
+}
+func (m *SetRecordRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetRecordRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetRecordRequest proto.InternalMessageInfo
+
+func (m *SetRecordRequest) GetCollection() []byte {
+	if m != nil {
+		return m.Collection
+	}
+	return nil
+}
+
+func (m *SetRecordRequest) GetKey() []byte {
+	if m != nil {
+		return m.Key
+	}
+	return nil
+}
+
+func (m *SetRecordRequest) GetValue() []byte {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+type SetRecordResponse struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SetRecordResponse) Reset()         { *m = SetRecordResponse{} }
+func (m *SetRecordResponse) String() string { return proto.CompactTextString(m) }
+func (*SetRecordResponse) ProtoMessage()    {}
+func (*SetRecordResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_946aaa5bc4ff2f06, []int{10}
+}
+
+func (m *SetRecordResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetRecordResponse.Unmarshal(m, b)
+}
+func (m *SetRecordResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetRecordResponse.Marshal(b, m, deterministic)
+}
+func (m *SetRecordResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetRecordResponse.Merge(m, src)
+}
+func (m *SetRecordResponse) XXX_Size() int {
+	return xxx_messageInfo_SetRecordResponse.Size(m)
+}
+func (m *SetRecordResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetRecordResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetRecordResponse proto.InternalMessageInfo
+
+type DeleteRecordRequest struct {
+	Collection           []byte   `protobuf:"bytes,1,opt,name=collection,proto3" json:"collection,omitempty"`
+	Key                  []byte   `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteRecordRequest) Reset()         { *m = DeleteRecordRequest{} }
+func (m *DeleteRecordRequest) String() string { return proto.CompactTextString(m) }
+func (*DeleteRecordRequest) ProtoMessage()    {}
+func (*DeleteRecordRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_946aaa5bc4ff2f06, []int{11}
+}
+
+func (m *DeleteRecordRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteRecordRequest.Unmarshal(m, b)
+}
+func (m *DeleteRecordRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteRecordRequest.Marshal(b, m, deterministic)
+}
+func (m *DeleteRecordRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteRecordRequest.Merge(m, src)
+}
+func (m *DeleteRecordRequest) XXX_Size() int {
+	return xxx_messageInfo_DeleteRecordRequest.Size(m)
+}
+func (m *DeleteRecordRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteRecordRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteRecordRequest proto.InternalMessageInfo
+
+func (m *DeleteRecordRequest) GetCollection() []byte {
+	if m != nil {
+		return m.Collection
+	}
+	return nil
+}
+
+func (m *DeleteRecordRequest) GetKey() []byte {
+	if m != nil {
+		return m.Key
+	}
+	return nil
+}
+
+type DeleteRecordResponse struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteRecordResponse) Reset()         { *m = DeleteRecordResponse{} }
+func (m *DeleteRecordResponse) String() string { return proto.CompactTextString(m) }
+func (*DeleteRecordResponse) ProtoMessage()    {}
+func (*DeleteRecordResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_946aaa5bc4ff2f06, []int{12}
+}
+
+func (m *DeleteRecordResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteRecordResponse.Unmarshal(m, b)
+}
+func (m *DeleteRecordResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteRecordResponse.Marshal(b, m, deterministic)
+}
+func (m *DeleteRecordResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteRecordResponse.Merge(m, src)
+}
+func (m *DeleteRecordResponse) XXX_Size() int {
+	return xxx_messageInfo_DeleteRecordResponse.Size(m)
+}
+func (m *DeleteRecordResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteRecordResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteRecordResponse proto.InternalMessageInfo
+
+type FetchRecordRequest struct {
+	Collection           []byte   `protobuf:"bytes,1,opt,name=collection,proto3" json:"collection,omitempty"`
+	Key                  []byte   `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *FetchRecordRequest) Reset()         { *m = FetchRecordRequest{} }
+func (m *FetchRecordRequest) String() string { return proto.CompactTextString(m) }
+func (*FetchRecordRequest) ProtoMessage()    {}
+func (*FetchRecordRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_946aaa5bc4ff2f06, []int{13}
+}
+
+func (m *FetchRecordRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_FetchRecordRequest.Unmarshal(m, b)
+}
+func (m *FetchRecordRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_FetchRecordRequest.Marshal(b, m, deterministic)
+}
+func (m *FetchRecordRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_FetchRecordRequest.Merge(m, src)
+}
+func (m *FetchRecordRequest) XXX_Size() int {
+	return xxx_messageInfo_FetchRecordRequest.Size(m)
+}
+func (m *FetchRecordRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_FetchRecordRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_FetchRecordRequest proto.InternalMessageInfo
+
+func (m *FetchRecordRequest) GetCollection() []byte {
+	if m != nil {
+		return m.Collection
+	}
+	return nil
+}
+
+func (m *FetchRecordRequest) GetKey() []byte {
+	if m != nil {
+		return m.Key
+	}
+	return nil
+}
+
+type FetchRecordResponse struct {
+	Value                []byte   `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *FetchRecordResponse) Reset()         { *m = FetchRecordResponse{} }
+func (m *FetchRecordResponse) String() string { return proto.CompactTextString(m) }
+func (*FetchRecordResponse) ProtoMessage()    {}
+func (*FetchRecordResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_946aaa5bc4ff2f06, []int{14}
+}
+
+func (m *FetchRecordResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_FetchRecordResponse.Unmarshal(m, b)
+}
+func (m *FetchRecordResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_FetchRecordResponse.Marshal(b, m, deterministic)
+}
+func (m *FetchRecordResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_FetchRecordResponse.Merge(m, src)
+}
+func (m *FetchRecordResponse) XXX_Size() int {
+	return xxx_messageInfo_FetchRecordResponse.Size(m)
+}
+func (m *FetchRecordResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_FetchRecordResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_FetchRecordResponse proto.InternalMessageInfo
+
+func (m *FetchRecordResponse) GetValue() []byte {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+type AllRecordsRequest struct {
+	Collection           []byte   `protobuf:"bytes,1,opt,name=collection,proto3" json:"collection,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AllRecordsRequest) Reset()         { *m = AllRecordsRequest{} }
+func (m *AllRecordsRequest) String() string { return proto.CompactTextString(m) }
+func (*AllRecordsRequest) ProtoMessage()    {}
+func (*AllRecordsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_946aaa5bc4ff2f06, []int{15}
+}
+
+func (m *AllRecordsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AllRecordsRequest.Unmarshal(m, b)
+}
+func (m *AllRecordsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AllRecordsRequest.Marshal(b, m, deterministic)
+}
+func (m *AllRecordsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AllRecordsRequest.Merge(m, src)
+}
+func (m *AllRecordsRequest) XXX_Size() int {
+	return xxx_messageInfo_AllRecordsRequest.Size(m)
+}
+func (m *AllRecordsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_AllRecordsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AllRecordsRequest proto.InternalMessageInfo
+
+func (m *AllRecordsRequest) GetCollection() []byte {
+	if m != nil {
+		return m.Collection
+	}
+	return nil
+}
+
+type AllRecordsResponsePacket struct {
+	Key                  []byte   `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value                []byte   `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AllRecordsResponsePacket) Reset()         { *m = AllRecordsResponsePacket{} }
+func (m *AllRecordsResponsePacket) String() string { return proto.CompactTextString(m) }
+func (*AllRecordsResponsePacket) ProtoMessage()    {}
+func (*AllRecordsResponsePacket) Descriptor() ([]byte, []int) {
+	return fileDescriptor_946aaa5bc4ff2f06, []int{16}
+}
+
+func (m *AllRecordsResponsePacket) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AllRecordsResponsePacket.Unmarshal(m, b)
+}
+func (m *AllRecordsResponsePacket) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AllRecordsResponsePacket.Marshal(b, m, deterministic)
+}
+func (m *AllRecordsResponsePacket) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AllRecordsResponsePacket.Merge(m, src)
+}
+func (m *AllRecordsResponsePacket) XXX_Size() int {
+	return xxx_messageInfo_AllRecordsResponsePacket.Size(m)
+}
+func (m *AllRecordsResponsePacket) XXX_DiscardUnknown() {
+	xxx_messageInfo_AllRecordsResponsePacket.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AllRecordsResponsePacket proto.InternalMessageInfo
+
+func (m *AllRecordsResponsePacket) GetKey() []byte {
+	if m != nil {
+		return m.Key
+	}
+	return nil
+}
+
+func (m *AllRecordsResponsePacket) GetValue() []byte {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*AuthenticateMessage)(nil), "redwood.AuthenticateMessage")
 	proto.RegisterType((*AuthenticateMessage_AuthenticateChallenge)(nil), "redwood.AuthenticateMessage.AuthenticateChallenge")
@@ -551,50 +923,71 @@ func init() {
 	proto.RegisterType((*FetchTxResponse)(nil), "redwood.FetchTxResponse")
 	proto.RegisterType((*AllTxsRequest)(nil), "redwood.AllTxsRequest")
 	proto.RegisterType((*AllTxsResponsePacket)(nil), "redwood.AllTxsResponsePacket")
+	proto.RegisterType((*SetRecordRequest)(nil), "redwood.SetRecordRequest")
+	proto.RegisterType((*SetRecordResponse)(nil), "redwood.SetRecordResponse")
+	proto.RegisterType((*DeleteRecordRequest)(nil), "redwood.DeleteRecordRequest")
+	proto.RegisterType((*DeleteRecordResponse)(nil), "redwood.DeleteRecordResponse")
+	proto.RegisterType((*FetchRecordRequest)(nil), "redwood.FetchRecordRequest")
+	proto.RegisterType((*FetchRecordResponse)(nil), "redwood.FetchRecordResponse")
+	proto.RegisterType((*AllRecordsRequest)(nil), "redwood.AllRecordsRequest")
+	proto.RegisterType((*AllRecordsResponsePacket)(nil), "redwood.AllRecordsResponsePacket")
 }
 
-func init() { proto.RegisterFile("remotestore.proto", fileDescriptor_946aaa5bc4ff2f06) }
+func init() {
+	proto.RegisterFile("remotestore.proto", fileDescriptor_946aaa5bc4ff2f06)
+}
 
 var fileDescriptor_946aaa5bc4ff2f06 = []byte{
-	// 450 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x54, 0x41, 0x6f, 0x12, 0x41,
-	0x14, 0xde, 0x2d, 0x11, 0xe4, 0x41, 0xa5, 0x3e, 0xa1, 0xae, 0x1b, 0x4c, 0xcc, 0x9c, 0x30, 0x26,
-	0x04, 0x31, 0x26, 0x9e, 0x8c, 0xd0, 0xc4, 0x70, 0x31, 0x69, 0xa6, 0x3d, 0x79, 0x1b, 0xd9, 0x17,
-	0x68, 0xdd, 0x32, 0xb8, 0x33, 0xd8, 0xed, 0xcf, 0xf5, 0x67, 0x78, 0x33, 0x5d, 0x66, 0x67, 0x87,
-	0x65, 0x2d, 0x7a, 0x63, 0xbe, 0xf7, 0xbe, 0xef, 0x7b, 0x7c, 0x6f, 0x67, 0xe0, 0x69, 0x42, 0x37,
-	0x52, 0x93, 0xd2, 0x32, 0xa1, 0xe1, 0x3a, 0x91, 0x5a, 0x62, 0x23, 0xa1, 0xe8, 0x56, 0xca, 0x88,
-	0xfd, 0xae, 0xc1, 0xb3, 0xc9, 0x46, 0x2f, 0x69, 0xa5, 0xaf, 0xe6, 0x42, 0xd3, 0x17, 0x52, 0x4a,
-	0x2c, 0x08, 0xaf, 0xa1, 0x27, 0x1c, 0xf8, 0x6c, 0x29, 0xe2, 0x98, 0x56, 0x0b, 0x0a, 0xfc, 0x57,
-	0xfe, 0xa0, 0x35, 0x1e, 0x0f, 0x8d, 0xc0, 0xb0, 0x82, 0xbc, 0x83, 0x59, 0xe6, 0xcc, 0xe3, 0xd5,
-	0x92, 0x65, 0xaf, 0x8b, 0xab, 0xc5, 0x4a, 0xe8, 0x4d, 0x42, 0xc1, 0xd1, 0x7f, 0x7a, 0x59, 0x66,
-	0xd9, 0xcb, 0x16, 0x70, 0x01, 0x5d, 0xb7, 0xc0, 0x49, 0xad, 0xe5, 0x4a, 0x51, 0x50, 0xcb, 0xac,
-	0xde, 0xfe, 0xb3, 0x55, 0x4e, 0x9c, 0x79, 0xbc, 0x52, 0x30, 0x7c, 0x0f, 0xbd, 0xca, 0x18, 0xb0,
-	0x0f, 0xcd, 0xf9, 0x4e, 0x9a, 0x6d, 0x5e, 0x00, 0x65, 0x5a, 0x31, 0x78, 0x1f, 0x9a, 0xca, 0x06,
-	0x63, 0x68, 0x16, 0x08, 0x07, 0xd0, 0xad, 0x9a, 0x0e, 0x4f, 0xa0, 0x76, 0x7d, 0xab, 0xb3, 0xfe,
-	0x26, 0xbf, 0xff, 0x39, 0x6d, 0x42, 0x63, 0x2d, 0xee, 0x62, 0x29, 0x22, 0xf6, 0x09, 0xda, 0x93,
-	0x28, 0xba, 0x4c, 0x39, 0xfd, 0xd8, 0x90, 0xd2, 0x78, 0x0a, 0x75, 0x9d, 0xce, 0x84, 0x5a, 0x1a,
-	0x7d, 0x73, 0xc2, 0x00, 0x1a, 0x3a, 0x9d, 0xde, 0x69, 0x52, 0xd9, 0x46, 0xda, 0x3c, 0x3f, 0xb2,
-	0x0e, 0x1c, 0x1b, 0x85, 0xad, 0x1f, 0x7b, 0x0d, 0x1d, 0x4e, 0x37, 0xf2, 0x27, 0x1d, 0x54, 0x65,
-	0x08, 0x27, 0x45, 0xab, 0xa1, 0x0f, 0xe0, 0xc9, 0x67, 0xd2, 0xf3, 0xe5, 0x61, 0xf6, 0x1b, 0xe8,
-	0xd8, 0x4e, 0xf3, 0x5f, 0x9d, 0x31, 0xfd, 0xfd, 0x31, 0xe3, 0xf8, 0x32, 0x55, 0x46, 0x95, 0x8d,
-	0xa0, 0x9b, 0x03, 0x5b, 0xf2, 0xb9, 0x98, 0x7f, 0x27, 0xfd, 0x77, 0x89, 0xf1, 0xaf, 0x23, 0x68,
-	0xf1, 0xec, 0x1a, 0x5d, 0xdc, 0x5f, 0x23, 0x3c, 0x87, 0xb6, 0x1b, 0x38, 0xf6, 0x1f, 0xfa, 0x72,
-	0xc2, 0x07, 0xab, 0xcc, 0x1b, 0xf8, 0x23, 0x1f, 0x3f, 0xc0, 0xa3, 0x2c, 0x4b, 0xec, 0x15, 0xcd,
-	0xce, 0x76, 0xc2, 0xd3, 0x32, 0x6c, 0x32, 0xf3, 0x70, 0x02, 0x8f, 0xf3, 0x24, 0x31, 0xb0, 0x5d,
-	0xa5, 0x3d, 0x84, 0x2f, 0x2a, 0x2a, 0x56, 0xe2, 0x23, 0x34, 0x4c, 0x9c, 0xf8, 0xdc, 0xf6, 0xed,
-	0xae, 0x22, 0x0c, 0xf6, 0x0b, 0x96, 0x7f, 0x06, 0xf5, 0x6d, 0xa0, 0xe8, 0x8c, 0xe9, 0x46, 0x1e,
-	0xbe, 0xdc, 0xc3, 0xdd, 0xe4, 0x99, 0x37, 0xf2, 0xa7, 0xc7, 0x5f, 0x5b, 0xce, 0x4b, 0xf5, 0xad,
-	0x9e, 0x3d, 0x55, 0xef, 0xfe, 0x04, 0x00, 0x00, 0xff, 0xff, 0x01, 0x2a, 0x34, 0x61, 0xbf, 0x04,
-	0x00, 0x00,
+	// 640 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x56, 0x41, 0x6f, 0xda, 0x4c,
+	0x10, 0xc5, 0x41, 0x09, 0x1f, 0x03, 0xf9, 0x92, 0x2c, 0x24, 0x75, 0xb7, 0xa4, 0x4a, 0x7d, 0x8a,
+	0x14, 0x09, 0x25, 0x44, 0x95, 0x7a, 0xaa, 0x64, 0x12, 0xa5, 0xa4, 0x55, 0xd4, 0xc8, 0xa4, 0x97,
+	0x9c, 0xea, 0xda, 0x23, 0x20, 0x38, 0x2c, 0xb5, 0x97, 0x04, 0x7e, 0x47, 0x7f, 0x6d, 0x6f, 0x15,
+	0xf6, 0xb2, 0x5e, 0x3b, 0x5b, 0x44, 0xc5, 0x8d, 0x9d, 0xd9, 0x79, 0x6f, 0xe6, 0xcd, 0x3e, 0x64,
+	0xd8, 0x0b, 0xf1, 0x91, 0x71, 0x8c, 0x38, 0x0b, 0xb1, 0x39, 0x0e, 0x19, 0x67, 0xa4, 0x14, 0xa2,
+	0xff, 0xcc, 0x98, 0x6f, 0xfd, 0x2e, 0x42, 0xcd, 0x9e, 0xf0, 0x3e, 0x8e, 0xf8, 0xc0, 0x73, 0x39,
+	0xde, 0x60, 0x14, 0xb9, 0x3d, 0x24, 0x0f, 0xb0, 0xef, 0x2a, 0xe1, 0x8b, 0xbe, 0x1b, 0x04, 0x38,
+	0xea, 0xa1, 0x69, 0x1c, 0x19, 0xc7, 0x95, 0x56, 0xab, 0x29, 0x00, 0x9a, 0x9a, 0xe2, 0xa6, 0xad,
+	0xab, 0xec, 0x14, 0x1c, 0x3d, 0x64, 0x9e, 0xab, 0x3b, 0xe8, 0x8d, 0x5c, 0x3e, 0x09, 0xd1, 0xdc,
+	0xf8, 0x47, 0x2e, 0x59, 0x99, 0xe7, 0x92, 0x09, 0xd2, 0x83, 0xba, 0x9a, 0x70, 0x30, 0x1a, 0xb3,
+	0x51, 0x84, 0x66, 0x31, 0xa6, 0x3a, 0x5b, 0x99, 0x6a, 0x51, 0xd8, 0x29, 0x38, 0x5a, 0x40, 0xfa,
+	0x1e, 0xf6, 0xb5, 0x32, 0x90, 0x06, 0x94, 0xbd, 0x8c, 0x9a, 0x55, 0x27, 0x0d, 0xe4, 0xcb, 0xd2,
+	0xc6, 0x1b, 0x50, 0x8e, 0xa4, 0x30, 0xa2, 0x4c, 0x06, 0xe8, 0x31, 0xd4, 0x75, 0xdd, 0x91, 0x5d,
+	0x28, 0x3e, 0x3c, 0xf3, 0xf8, 0x7e, 0xd9, 0x99, 0xff, 0x6c, 0x97, 0xa1, 0x34, 0x76, 0x67, 0x01,
+	0x73, 0x7d, 0xeb, 0x3b, 0x54, 0x6d, 0xdf, 0xbf, 0x9b, 0x3a, 0xf8, 0x73, 0x82, 0x11, 0x27, 0x47,
+	0x50, 0x89, 0xb8, 0xcb, 0xf1, 0x9b, 0x73, 0xfd, 0x05, 0x67, 0x82, 0x44, 0x0d, 0x91, 0x3a, 0x6c,
+	0xf2, 0xe9, 0x3c, 0xb7, 0x11, 0xe7, 0x92, 0x03, 0x31, 0xa1, 0xc4, 0xa7, 0xed, 0x19, 0xc7, 0x28,
+	0x96, 0xb1, 0xea, 0x2c, 0x8e, 0xd6, 0x0e, 0x6c, 0x0b, 0x86, 0xa4, 0x1f, 0xeb, 0x1a, 0x76, 0x1c,
+	0x7c, 0x64, 0x4f, 0xb8, 0x36, 0xab, 0x45, 0x60, 0x37, 0x85, 0x12, 0xf0, 0x1d, 0xf8, 0xff, 0x0a,
+	0xb9, 0xd7, 0x5f, 0x1f, 0xfd, 0x04, 0x76, 0x24, 0x92, 0xd0, 0x52, 0x19, 0xd3, 0xc8, 0x8e, 0x79,
+	0x06, 0xdb, 0x76, 0x10, 0xdc, 0x4d, 0xa3, 0x95, 0x59, 0xad, 0x53, 0xa8, 0x2f, 0x4a, 0x12, 0xf8,
+	0x5b, 0xd7, 0x1b, 0x22, 0x5f, 0x42, 0x72, 0x0f, 0xbb, 0x5d, 0xe4, 0x0e, 0x7a, 0x2c, 0xf4, 0x17,
+	0x3c, 0x6f, 0x01, 0x3c, 0x16, 0x04, 0xe8, 0xf1, 0x01, 0x1b, 0x89, 0x02, 0x25, 0x32, 0x5f, 0xff,
+	0x50, 0x4e, 0x56, 0x1c, 0x26, 0xd3, 0x3e, 0xb9, 0xc1, 0x04, 0xc5, 0xa6, 0x92, 0x83, 0x55, 0x83,
+	0x3d, 0x05, 0x5b, 0x88, 0xf9, 0x09, 0x6a, 0x97, 0x18, 0x20, 0xc7, 0x35, 0x39, 0xad, 0x03, 0xa8,
+	0x67, 0x81, 0x04, 0xc1, 0x15, 0x90, 0x58, 0xe3, 0x75, 0xf1, 0x4f, 0xa0, 0x96, 0xc1, 0x11, 0xfb,
+	0x92, 0xa3, 0x1a, 0xea, 0xa8, 0xe7, 0xb0, 0x67, 0x07, 0x41, 0x72, 0x35, 0x5a, 0x91, 0xd3, 0x6a,
+	0x83, 0xa9, 0x16, 0x65, 0x36, 0x26, 0xfa, 0x31, 0x34, 0x1a, 0x6f, 0x28, 0xc4, 0xad, 0x5f, 0x9b,
+	0x50, 0x71, 0xe2, 0x3f, 0xe2, 0x2e, 0x67, 0x21, 0x92, 0x5b, 0xa8, 0xaa, 0x96, 0x25, 0x8d, 0x65,
+	0xff, 0x3d, 0x74, 0x69, 0xd6, 0x2a, 0x1c, 0x1b, 0xa7, 0x06, 0xf9, 0x00, 0x9b, 0xb1, 0xdb, 0xc8,
+	0x7e, 0x7a, 0x59, 0xf1, 0x37, 0x3d, 0xc8, 0x87, 0xc5, 0x1e, 0x0a, 0xc4, 0x86, 0xff, 0x16, 0x5e,
+	0x22, 0xa6, 0xbc, 0x95, 0x73, 0x2a, 0x7d, 0xad, 0xc9, 0x48, 0x88, 0x8f, 0x50, 0x12, 0x86, 0x21,
+	0xaf, 0xe4, 0xbd, 0xac, 0x19, 0xa9, 0xf9, 0x32, 0x21, 0xeb, 0x2f, 0x60, 0x2b, 0x31, 0x04, 0x51,
+	0xda, 0x54, 0x4d, 0x45, 0x0f, 0x5f, 0xc4, 0xd5, 0x3d, 0x58, 0x85, 0x53, 0x83, 0x5c, 0x42, 0x59,
+	0xbe, 0x63, 0x92, 0xb6, 0x9b, 0xf7, 0x0d, 0xa5, 0xba, 0x94, 0x6c, 0xe5, 0x06, 0xaa, 0xea, 0x7b,
+	0x55, 0x36, 0xa3, 0xf1, 0x03, 0x3d, 0xfc, 0x4b, 0x56, 0xc2, 0x7d, 0x86, 0x8a, 0xf2, 0x3c, 0xc9,
+	0x9b, 0xac, 0x08, 0x59, 0xb0, 0x86, 0x3e, 0x29, 0xb1, 0xbe, 0x02, 0xa4, 0x0f, 0x91, 0x50, 0x55,
+	0x91, 0xec, 0x93, 0xa6, 0xef, 0xb4, 0xb9, 0xbc, 0x62, 0xed, 0xed, 0xfb, 0x8a, 0xf2, 0x75, 0xf0,
+	0x63, 0x2b, 0xfe, 0x3c, 0x38, 0xff, 0x33, 0x00, 0xfe, 0xdb, 0xc9, 0x1b, 0x33, 0x08, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
 var _ context.Context
-var _ grpc.ClientConn
+var _ grpc.ClientConnInterface
 
 // This is a compile-time assertion to ensure that this generated file
 // is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion4
+const _ = grpc.SupportPackageIsVersion6
 
 // RemoteStoreClient is the client API for RemoteStore service.
 //
@@ -605,13 +998,17 @@ type RemoteStoreClient interface {
 	RemoveTx(ctx context.Context, in *RemoveTxRequest, opts ...grpc.CallOption) (*RemoveTxResponse, error)
 	FetchTx(ctx context.Context, in *FetchTxRequest, opts ...grpc.CallOption) (*FetchTxResponse, error)
 	AllTxs(ctx context.Context, in *AllTxsRequest, opts ...grpc.CallOption) (RemoteStore_AllTxsClient, error)
+	SetRecord(ctx context.Context, in *SetRecordRequest, opts ...grpc.CallOption) (*SetRecordResponse, error)
+	DeleteRecord(ctx context.Context, in *DeleteRecordRequest, opts ...grpc.CallOption) (*DeleteRecordResponse, error)
+	FetchRecord(ctx context.Context, in *FetchRecordRequest, opts ...grpc.CallOption) (*FetchRecordResponse, error)
+	AllRecords(ctx context.Context, in *AllRecordsRequest, opts ...grpc.CallOption) (RemoteStore_AllRecordsClient, error)
 }
 
 type remoteStoreClient struct {
-	cc *grpc.ClientConn
+	cc grpc.ClientConnInterface
 }
 
-func NewRemoteStoreClient(cc *grpc.ClientConn) RemoteStoreClient {
+func NewRemoteStoreClient(cc grpc.ClientConnInterface) RemoteStoreClient {
 	return &remoteStoreClient{cc}
 }
 
@@ -705,6 +1102,65 @@ func (x *remoteStoreAllTxsClient) Recv() (*AllTxsResponsePacket, error) {
 	return m, nil
 }
 
+func (c *remoteStoreClient) SetRecord(ctx context.Context, in *SetRecordRequest, opts ...grpc.CallOption) (*SetRecordResponse, error) {
+	out := new(SetRecordResponse)
+	err := c.cc.Invoke(ctx, "/redwood.RemoteStore/SetRecord", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *remoteStoreClient) DeleteRecord(ctx context.Context, in *DeleteRecordRequest, opts ...grpc.CallOption) (*DeleteRecordResponse, error) {
+	out := new(DeleteRecordResponse)
+	err := c.cc.Invoke(ctx, "/redwood.RemoteStore/DeleteRecord", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *remoteStoreClient) FetchRecord(ctx context.Context, in *FetchRecordRequest, opts ...grpc.CallOption) (*FetchRecordResponse, error) {
+	out := new(FetchRecordResponse)
+	err := c.cc.Invoke(ctx, "/redwood.RemoteStore/FetchRecord", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *remoteStoreClient) AllRecords(ctx context.Context, in *AllRecordsRequest, opts ...grpc.CallOption) (RemoteStore_AllRecordsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_RemoteStore_serviceDesc.Streams[2], "/redwood.RemoteStore/AllRecords", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &remoteStoreAllRecordsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type RemoteStore_AllRecordsClient interface {
+	Recv() (*AllRecordsResponsePacket, error)
+	grpc.ClientStream
+}
+
+type remoteStoreAllRecordsClient struct {
+	grpc.ClientStream
+}
+
+func (x *remoteStoreAllRecordsClient) Recv() (*AllRecordsResponsePacket, error) {
+	m := new(AllRecordsResponsePacket)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // RemoteStoreServer is the server API for RemoteStore service.
 type RemoteStoreServer interface {
 	Authenticate(RemoteStore_AuthenticateServer) error
@@ -712,6 +1168,10 @@ type RemoteStoreServer interface {
 	RemoveTx(context.Context, *RemoveTxRequest) (*RemoveTxResponse, error)
 	FetchTx(context.Context, *FetchTxRequest) (*FetchTxResponse, error)
 	AllTxs(*AllTxsRequest, RemoteStore_AllTxsServer) error
+	SetRecord(context.Context, *SetRecordRequest) (*SetRecordResponse, error)
+	DeleteRecord(context.Context, *DeleteRecordRequest) (*DeleteRecordResponse, error)
+	FetchRecord(context.Context, *FetchRecordRequest) (*FetchRecordResponse, error)
+	AllRecords(*AllRecordsRequest, RemoteStore_AllRecordsServer) error
 }
 
 // UnimplementedRemoteStoreServer can be embedded to have forward compatible implementations.
@@ -733,6 +1193,18 @@ func (*UnimplementedRemoteStoreServer) FetchTx(ctx context.Context, req *FetchTx
 func (*UnimplementedRemoteStoreServer) AllTxs(req *AllTxsRequest, srv RemoteStore_AllTxsServer) error {
 	return status.Errorf(codes.Unimplemented, "method AllTxs not implemented")
 }
+func (*UnimplementedRemoteStoreServer) SetRecord(ctx context.Context, req *SetRecordRequest) (*SetRecordResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetRecord not implemented")
+}
+func (*UnimplementedRemoteStoreServer) DeleteRecord(ctx context.Context, req *DeleteRecordRequest) (*DeleteRecordResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteRecord not implemented")
+}
+func (*UnimplementedRemoteStoreServer) FetchRecord(ctx context.Context, req *FetchRecordRequest) (*FetchRecordResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FetchRecord not implemented")
+}
+func (*UnimplementedRemoteStoreServer) AllRecords(req *AllRecordsRequest, srv RemoteStore_AllRecordsServer) error {
+	return status.Errorf(codes.Unimplemented, "method AllRecords not implemented")
+}
 
 func RegisterRemoteStoreServer(s *grpc.Server, srv RemoteStoreServer) {
 	s.RegisterService(&_RemoteStore_serviceDesc, srv)
@@ -839,6 +1311,81 @@ func (x *remoteStoreAllTxsServer) Send(m *AllTxsResponsePacket) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _RemoteStore_SetRecord_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetRecordRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RemoteStoreServer).SetRecord(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/redwood.RemoteStore/SetRecord",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RemoteStoreServer).SetRecord(ctx, req.(*SetRecordRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RemoteStore_DeleteRecord_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRecordRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RemoteStoreServer).DeleteRecord(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/redwood.RemoteStore/DeleteRecord",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RemoteStoreServer).DeleteRecord(ctx, req.(*DeleteRecordRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RemoteStore_FetchRecord_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FetchRecordRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RemoteStoreServer).FetchRecord(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/redwood.RemoteStore/FetchRecord",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RemoteStoreServer).FetchRecord(ctx, req.(*FetchRecordRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RemoteStore_AllRecords_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(AllRecordsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RemoteStoreServer).AllRecords(m, &remoteStoreAllRecordsServer{stream})
+}
+
+type RemoteStore_AllRecordsServer interface {
+	Send(*AllRecordsResponsePacket) error
+	grpc.ServerStream
+}
+
+type remoteStoreAllRecordsServer struct {
+	grpc.ServerStream
+}
+
+func (x *remoteStoreAllRecordsServer) Send(m *AllRecordsResponsePacket) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 var _RemoteStore_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "redwood.RemoteStore",
 	HandlerType: (*RemoteStoreServer)(nil),
@@ -855,6 +1402,18 @@ var _RemoteStore_serviceDesc = grpc.ServiceDesc{
 			MethodName: "FetchTx",
 			Handler:    _RemoteStore_FetchTx_Handler,
 		},
+		{
+			MethodName: "SetRecord",
+			Handler:    _RemoteStore_SetRecord_Handler,
+		},
+		{
+			MethodName: "DeleteRecord",
+			Handler:    _RemoteStore_DeleteRecord_Handler,
+		},
+		{
+			MethodName: "FetchRecord",
+			Handler:    _RemoteStore_FetchRecord_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -868,6 +1427,11 @@ var _RemoteStore_serviceDesc = grpc.ServiceDesc{
 			Handler:       _RemoteStore_AllTxs_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "AllRecords",
+			Handler:       _RemoteStore_AllRecords_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "remotestore.proto",
 }
@@ -10,6 +10,7 @@ import (
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"redwood.dev/crypto"
 	"redwood.dev/ctx"
@@ -75,8 +76,8 @@ func (s *server) Start() error {
 }
 
 func (s *server) Close() {
-	s.db.Close()
 	s.grpc.GracefulStop()
+	s.db.Close()
 }
 
 func (s *server) requireAuth(ctx context.Context) error {
@@ -121,6 +122,9 @@ func (s *server) Authenticate(authSrv RemoteStore_AuthenticateServer) error {
 			Challenge: challenge,
 		}},
 	})
+	if err != nil {
+		return err
+	}
 
 	msg, err := authSrv.Recv()
 	if err != nil {
@@ -159,18 +163,28 @@ func (s *server) Authenticate(authSrv RemoteStore_AuthenticateServer) error {
 	return err
 }
 
+func makeTxKey(stateURIKey, txKey []byte) []byte {
+	key := append([]byte("tx:"), stateURIKey...)
+	key = append(key, ':')
+	return append(key, txKey...)
+}
+
+func makeRecordKey(collection, key []byte) []byte {
+	k := append([]byte("record:"), collection...)
+	k = append(k, ':')
+	return append(k, key...)
+}
+
 func (s *server) AddTx(ctx context.Context, req *AddTxRequest) (*AddTxResponse, error) {
 	if err := s.requireAuth(ctx); err != nil {
 		return nil, err
 	}
 
-	key := append([]byte("tx:"), req.TxHash[:]...)
-
 	err := s.db.Update(func(txn *badger.Txn) error {
-		return txn.Set(key, req.TxBytes)
+		return txn.Set(makeTxKey(req.StateURIKey, req.TxKey), req.TxBytes)
 	})
 	if err != nil {
-		s.Errorf("failed to write tx %0x", req.TxHash)
+		s.Errorf("failed to write tx %0x", req.TxKey)
 		return nil, err
 	}
 	return &AddTxResponse{}, nil
@@ -181,9 +195,8 @@ func (s *server) RemoveTx(ctx context.Context, req *RemoveTxRequest) (*RemoveTxR
 		return nil, err
 	}
 
-	key := append([]byte("tx:"), req.TxHash...)
 	err := s.db.Update(func(txn *badger.Txn) error {
-		return txn.Delete(key)
+		return txn.Delete(makeTxKey(req.StateURIKey, req.TxKey))
 	})
 	if err != nil {
 		return nil, err
@@ -196,58 +209,127 @@ func (s *server) FetchTx(ctx context.Context, req *FetchTxRequest) (*FetchTxResp
 		return nil, err
 	}
 
-	key := append([]byte("tx:"), req.TxHash...)
+	txBytes, err := s.fetch(makeTxKey(req.StateURIKey, req.TxKey))
+	if err != nil {
+		return nil, err
+	}
+	return &FetchTxResponse{TxBytes: txBytes}, nil
+}
 
-	var txBytes []byte
-	err := s.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get(key)
-		if err != nil {
-			return err
-		}
+// AllTxs streams every tx stored under the given state URI key, or every tx
+// in the store if no key is given.
+func (s *server) AllTxs(req *AllTxsRequest, server RemoteStore_AllTxsServer) error {
+	if err := s.requireAuth(server.Context()); err != nil {
+		return err
+	}
 
-		return item.Value(func(val []byte) error {
-			txBytes = append([]byte{}, val...)
-			return nil
-		})
+	prefix := []byte("tx:")
+	if len(req.StateURIKey) > 0 {
+		prefix = append(append(prefix, req.StateURIKey...), ':')
+	}
+
+	return s.iterate(server.Context(), prefix, func(key, val []byte) error {
+		return server.Send(&AllTxsResponsePacket{TxBytes: val})
+	})
+}
+
+func (s *server) SetRecord(ctx context.Context, req *SetRecordRequest) (*SetRecordResponse, error) {
+	if err := s.requireAuth(ctx); err != nil {
+		return nil, err
+	}
+
+	err := s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(makeRecordKey(req.Collection, req.Key), req.Value)
 	})
 	if err != nil {
 		return nil, err
 	}
-	return &FetchTxResponse{TxBytes: txBytes}, err
+	return &SetRecordResponse{}, nil
 }
 
-func (s *server) AllTxs(req *AllTxsRequest, server RemoteStore_AllTxsServer) error {
+func (s *server) DeleteRecord(ctx context.Context, req *DeleteRecordRequest) (*DeleteRecordResponse, error) {
+	if err := s.requireAuth(ctx); err != nil {
+		return nil, err
+	}
+
+	err := s.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(makeRecordKey(req.Collection, req.Key))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &DeleteRecordResponse{}, nil
+}
+
+func (s *server) FetchRecord(ctx context.Context, req *FetchRecordRequest) (*FetchRecordResponse, error) {
+	if err := s.requireAuth(ctx); err != nil {
+		return nil, err
+	}
+
+	val, err := s.fetch(makeRecordKey(req.Collection, req.Key))
+	if err != nil {
+		return nil, err
+	}
+	return &FetchRecordResponse{Value: val}, nil
+}
+
+func (s *server) AllRecords(req *AllRecordsRequest, server RemoteStore_AllRecordsServer) error {
 	if err := s.requireAuth(server.Context()); err != nil {
 		return err
 	}
 
+	prefix := makeRecordKey(req.Collection, nil)
+
+	return s.iterate(server.Context(), prefix, func(key, val []byte) error {
+		return server.Send(&AllRecordsResponsePacket{Key: key[len(prefix):], Value: val})
+	})
+}
+
+func (s *server) fetch(key []byte) ([]byte, error) {
+	var val []byte
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+		if err == badger.ErrKeyNotFound {
+			return status.Error(codes.NotFound, "not found")
+		} else if err != nil {
+			return err
+		}
+
+		return item.Value(func(bs []byte) error {
+			val = append([]byte{}, bs...)
+			return nil
+		})
+	})
+	return val, err
+}
+
+func (s *server) iterate(ctx context.Context, prefix []byte, fn func(key, val []byte) error) error {
 	return s.db.View(func(txn *badger.Txn) error {
 		opts := badger.DefaultIteratorOptions
 		opts.PrefetchSize = 10
 		badgerIter := txn.NewIterator(opts)
 		defer badgerIter.Close()
 
-		prefix := []byte("tx:")
 		for badgerIter.Seek(prefix); badgerIter.ValidForPrefix(prefix); badgerIter.Next() {
 			item := badgerIter.Item()
 
-			var txBytes []byte
-			err := item.Value(func(val []byte) error {
-				txBytes = append([]byte{}, val...)
+			var val []byte
+			err := item.Value(func(bs []byte) error {
+				val = append([]byte{}, bs...)
 				return nil
 			})
 			if err != nil {
 				return err
 			}
 
-			err = server.Send(&AllTxsResponsePacket{TxBytes: txBytes})
+			err = fn(item.KeyCopy(nil), val)
 			if err != nil {
 				return err
 			}
 
 			select {
-			case <-server.Context().Done():
-				return server.Context().Err()
+			case <-ctx.Done():
+				return ctx.Err()
 			default:
 			}
 		}
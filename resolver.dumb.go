@@ -17,11 +17,7 @@ func (r *dumbResolver) InternalState() map[string]interface{} {
 
 func (r *dumbResolver) ResolveState(state tree.Node, refStore RefStore, sender types.Address, txID types.ID, parents []types.ID, ps []Patch) (err error) {
 	for _, p := range ps {
-		if p.Val != nil {
-			err = state.Set(p.Keypath, p.Range, p.Val)
-		} else {
-			err = state.Delete(p.Keypath, p.Range)
-		}
+		err = p.Apply(state)
 		if err != nil {
 			return err
 		}
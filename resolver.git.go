@@ -1,495 +1,766 @@
 package redwood
 
-// import (
-// 	"io"
-// 	"io/ioutil"
-// 	"math/big"
-// 	"os"
-// 	"sort"
-// 	"time"
-
-// 	"github.com/brynbellomy/git2go"
-// 	"github.com/pkg/errors"
-
-// "redwood.dev/ctx"
-// 	"redwood.dev/nelson"
-// 	"redwood.dev/tree"
-// 	"redwood.dev/types"
-// )
-
-// type gitResolver struct{}
-
-// func NewGitResolver(config tree.Node, internalState map[string]interface{}) (_ Resolver, err error) {
-// 	return &gitResolver{}, nil
-// }
-
-// func (r *gitResolver) InternalState() map[string]interface{} {
-// 	return make(map[string]interface{})
-// }
-
-// func (r *gitResolver) ResolveState(
-// 	state tree.Node,
-// 	refStore RefStore,
-// 	sender types.Address,
-// 	txID types.ID,
-// 	parents []types.ID,
-// 	patches []Patch,
-// ) (err error) {
-// 	defer utils.WithStack(&err)
-
-// 	node := tree.NewMemoryNode()
-// 	for _, patch := range patches {
-// 		err := node.Set(patch.Keypath, patch.Range, patch.Val)
-// 		if err != nil {
-// 			return err
-// 		}
-// 	}
-
-// 	refSubkeys := node.NodeAt(tree.Keypath("refs").Pushs("heads"), nil).Subkeys()
-// 	refName := string(refSubkeys[0])
-
-// 	commitSubkeys := node.NodeAt(tree.Keypath("commits"), nil).Subkeys()
-// 	commitHash := commitSubkeys[0]
-// 	commitNode := node.NodeAt(tree.Keypath("commits").Push(commitHash), nil)
-
-// 	//
-// 	// Stream the updates into the new index and the ODB
-// 	//
-// 	repo, err := git.OpenRepository("/tmp/myrepo/.git")
-// 	if err != nil {
-// 		return errors.WithStack(err)
-// 	}
-
-// 	odb, err := repo.Odb()
-// 	if err != nil {
-// 		return err
-// 	}
-
-// 	thisCommit, err := r.createCommitFromNode(commitNode, refName, refStore, repo, odb)
-// 	if err != nil {
-// 		return err
-// 	}
-
-// 	//
-// 	// Perform an auto-merge, if applicable
-// 	//
-// 	mergeCommit, err := r.maybeAutoMerge(state, thisCommit, refName, refStore, repo, odb)
-// 	if err != nil {
-// 		return err
-// 	}
-
-// 	var refOID *git.Oid
-// 	if mergeCommit != nil {
-// 		refOID = mergeCommit.Id()
-// 	} else {
-// 		refOID = thisCommit.Id()
-// 	}
-
-// 	refNameRemote := "refs/remotes/origin/" + refName
-// 	ref, err := repo.References.Lookup(refNameRemote)
-// 	if err != nil {
-// 		ref, err = repo.References.Create(refNameRemote, refOID, false, "")
-// 		if err != nil {
-// 			return err
-// 		}
-// 	}
-// 	_, err = ref.SetTarget(refOID, "")
-// 	if err != nil {
-// 		return err
-// 	}
-
-// 	//
-// 	// Apply the commit portion of the patch to the state tree
-// 	//
-// 	for _, patch := range patches {
-// 		// Use the commit hash we received from `repo.CreateCommit`
-// 		if patch.Keypath.Part(0).Equals(tree.Keypath("commits")) {
-// 			keypath := patch.Keypath.Part(0).Pushs(thisCommit.Id().String())
-// 			err := state.Set(keypath, patch.Range, patch.Val)
-// 			if err != nil {
-// 				return err
-// 			}
-// 		}
-// 	}
-
-// 	// Set the ref
-// 	return state.Set(tree.Keypath("refs").Pushs("heads").Pushs(refName), nil, refOID.String())
-// }
-
-// func (r *gitResolver) maybeAutoMerge(
-// 	state tree.Node,
-// 	thisCommit *git.Commit,
-// 	refName string,
-// 	refStore RefStore,
-// 	repo *git.Repository,
-// 	odb *git.Odb,
-// ) (_ *git.Commit, err error) {
-// 	defer utils.WithStack(&err)
-
-// 	commitsWithSharedParent := make(map[git.Oid]struct{})
-
-// 	thisCommitParents := make(map[git.Oid]struct{}, thisCommit.ParentCount())
-// 	for i := uint(0); i < thisCommit.ParentCount(); i++ {
-// 		parentOID := thisCommit.ParentId(i)
-// 		thisCommitParents[*parentOID] = struct{}{}
-// 	}
-
-// 	// @@TODO: use an index to make this efficient
-// 	err = func() error {
-// 		commitsIter := state.ChildIterator(tree.Keypath("commits"), true, 10)
-// 		defer commitsIter.Close()
-
-// 		for commitsIter.Rewind(); commitsIter.Valid(); commitsIter.Next() {
-// 			otherCommitNode := commitsIter.Node()
-
-// 			nodeType, _, numParents, err := otherCommitNode.NodeInfo(tree.Keypath("parents"))
-// 			if errors.Cause(err) == types.Err404 {
-// 				continue
-// 			} else if err != nil {
-// 				return err
-// 			} else if nodeType != tree.NodeTypeSlice {
-// 				continue
-// 			}
-
-// 			for i := uint64(0); i < numParents; i++ {
-// 				parentStr, exists, err := otherCommitNode.StringValue(tree.Keypath("parents").PushIndex(i))
-// 				if err != nil {
-// 					continue
-// 				} else if !exists {
-// 					continue
-// 				}
-
-// 				parentOid, err := git.NewOid(parentStr)
-// 				if err != nil {
-// 					continue
-// 				}
-
-// 				if _, exists := thisCommitParents[*parentOid]; exists {
-// 					shaStr := string(otherCommitNode.Keypath().Part(-1))
-// 					shaOID, err := git.NewOid(shaStr)
-// 					if err != nil {
-// 						continue
-// 					}
-// 					commitsWithSharedParent[*shaOID] = struct{}{}
-// 				}
-// 			}
-// 		}
-// 		return nil
-// 	}()
-// 	if err != nil {
-// 		return nil, err
-// 	} else if len(commitsWithSharedParent) == 0 {
-// 		return nil, nil
-// 	}
-
-// 	commitsWithSharedParentSlice := make([]git.Oid, len(commitsWithSharedParent)+1)
-// 	i := 0
-// 	for oid := range commitsWithSharedParent {
-// 		commitsWithSharedParentSlice[i] = oid
-// 		i++
-// 	}
-// 	commitsWithSharedParentSlice[len(commitsWithSharedParentSlice)-1] = *thisCommit.Id()
-
-// 	var cs []string
-// 	for _, c := range commitsWithSharedParentSlice {
-// 		cs = append(cs, c.String())
-// 	}
-
-// 	// Arbitrary (but deterministic) merge resolution: convert the commit SHAs to integers
-// 	// and order them numerically.
-// 	sort.Slice(commitsWithSharedParentSlice, func(i, j int) bool {
-// 		x := big.NewInt(0).SetBytes(commitsWithSharedParentSlice[i][:])
-// 		y := big.NewInt(0).SetBytes(commitsWithSharedParentSlice[j][:])
-// 		return x.Cmp(y) < 0
-// 	})
-
-// 	mergeCommitNode := tree.NewMemoryNode()
-
-// 	for _, commitSHA := range commitsWithSharedParentSlice {
-// 		// log.Warn(commitSHA.String())
-// 		err := func() error {
-// 			filesKeypath := tree.Keypath("commits").
-// 				Pushs(commitSHA.String()).
-// 				Pushs("files")
-
-// 			filesIter := state.ChildIterator(filesKeypath, true, 10)
-// 			defer filesIter.Close()
-
-// 			for filesIter.Rewind(); filesIter.Valid(); filesIter.Next() {
-// 				fileNode := filesIter.Node()
-// 				relKeypath := fileNode.Keypath().RelativeTo(filesKeypath)
-
-// 				fileNode, err := fileNode.CopyToMemory(nil, nil)
-// 				if err != nil {
-// 					return err
-// 				}
-
-// 				err = mergeCommitNode.Set(tree.Keypath("files").Push(relKeypath), nil, fileNode)
-// 				if err != nil {
-// 					return err
-// 				}
-// 			}
-// 			return nil
-// 		}()
-// 		if err != nil {
-// 			return nil, err
-// 		}
-// 	}
-
-// 	mergeCommitParents := make([]interface{}, len(commitsWithSharedParentSlice))
-// 	for i := 0; i < len(commitsWithSharedParentSlice); i++ {
-// 		mergeCommitParents[i] = commitsWithSharedParentSlice[i].String()
-// 	}
-
-// 	mergeCommitNode.Set(tree.Keypath("message"), nil, "Redwood auto-merge")
-// 	mergeCommitNode.Set(tree.Keypath("parents"), nil, mergeCommitParents)
-// 	mergeCommitNode.Set(tree.Keypath("author").Pushs("name"), nil, "Redwood")
-// 	mergeCommitNode.Set(tree.Keypath("author").Pushs("email"), nil, "redwood@localhost")
-// 	mergeCommitNode.Set(tree.Keypath("author").Pushs("timestamp"), nil, time.Time{}.Format(time.RFC3339))
-// 	mergeCommitNode.Set(tree.Keypath("committer").Pushs("name"), nil, "Redwood")
-// 	mergeCommitNode.Set(tree.Keypath("committer").Pushs("email"), nil, "redwood@localhost")
-// 	mergeCommitNode.Set(tree.Keypath("committer").Pushs("timestamp"), nil, time.Time{}.Format(time.RFC3339))
-
-// 	mergeCommit, err := r.createCommitFromNode(mergeCommitNode, refName, refStore, repo, odb)
-// 	if err != nil {
-// 		return nil, err
-// 	}
-
-// 	err = state.Set(tree.Keypath("commits").Push(tree.Keypath(mergeCommit.Id().String())), nil, mergeCommitNode)
-// 	if err != nil {
-// 		return nil, err
-// 	}
-
-// 	return mergeCommit, nil
-// }
-
-// func (r *gitResolver) createCommitFromNode(commitNode tree.Node, refName string, refStore RefStore, repo *git.Repository, odb *git.Odb) (_ *git.Commit, err error) {
-// 	defer utils.WithStack(&err)
-
-// 	numParents, err := commitNode.NodeAt(tree.Keypath("parents"), nil).Length()
-// 	if err != nil {
-// 		return nil, err
-// 	}
-
-// 	var parentOIDs []*git.Oid
-// 	for i := uint64(0); i < numParents; i++ {
-// 		parentOIDStr, exists, err := commitNode.StringValue(tree.Keypath("parents").PushIndex(i))
-// 		if err != nil {
-// 			return nil, err
-// 		} else if !exists {
-// 			return nil, errors.New("bad commit parent")
-// 		}
-// 		oid, err := git.NewOid(parentOIDStr)
-// 		if err != nil {
-// 			return nil, err
-// 		}
-// 		parentOIDs = append(parentOIDs, oid)
-// 	}
-
-// 	message, exists, err := commitNode.StringValue(tree.Keypath("message"))
-// 	if err != nil {
-// 		return nil, err
-// 	} else if !exists {
-// 		return nil, errors.New("commit is missing 'message' field")
-// 	}
-// 	authorName, exists, err := commitNode.StringValue(tree.Keypath("author").Pushs("name"))
-// 	if err != nil {
-// 		return nil, err
-// 	} else if !exists {
-// 		return nil, errors.New("commit is missing 'author/name' field")
-// 	}
-// 	authorEmail, exists, err := commitNode.StringValue(tree.Keypath("author").Pushs("email"))
-// 	if err != nil {
-// 		return nil, err
-// 	} else if !exists {
-// 		return nil, errors.New("commit is missing 'author/email' field")
-// 	}
-// 	authorTimestampStr, exists, err := commitNode.StringValue(tree.Keypath("author").Pushs("timestamp"))
-// 	if err != nil {
-// 		return nil, err
-// 	} else if !exists {
-// 		return nil, errors.New("commit is missing 'author/timestamp' field")
-// 	}
-// 	committerName, exists, err := commitNode.StringValue(tree.Keypath("committer").Pushs("name"))
-// 	if err != nil {
-// 		return nil, err
-// 	} else if !exists {
-// 		return nil, errors.New("commit is missing 'committer/name' field")
-// 	}
-// 	committerEmail, exists, err := commitNode.StringValue(tree.Keypath("committer").Pushs("email"))
-// 	if err != nil {
-// 		return nil, err
-// 	} else if !exists {
-// 		return nil, errors.New("commit is missing 'committer/email' field")
-// 	}
-// 	committerTimestampStr, exists, err := commitNode.StringValue(tree.Keypath("committer").Pushs("timestamp"))
-// 	if err != nil {
-// 		return nil, err
-// 	} else if !exists {
-// 		return nil, errors.New("commit is missing 'committer/timestamp' field")
-// 	}
-
-// 	authorTimestamp, err := time.Parse(time.RFC3339, authorTimestampStr)
-// 	if err != nil {
-// 		return nil, err
-// 	}
-// 	committerTimestamp, err := time.Parse(time.RFC3339, committerTimestampStr)
-// 	if err != nil {
-// 		return nil, err
-// 	}
-
-// 	var parentCommits []*git.Commit
-// 	for _, parentOID := range parentOIDs {
-// 		commit, err := repo.LookupCommit(parentOID)
-// 		if err != nil {
-// 			return nil, err
-// 		}
-// 		parentCommits = append(parentCommits, commit)
-// 	}
-
-// 	idx, err := git.NewIndex()
-// 	if err != nil {
-// 		return nil, err
-// 	}
-
-// 	iter := commitNode.Iterator(tree.Keypath("files"), true, 10)
-// 	defer iter.Close()
-
-// 	baseKeypath := commitNode.Keypath().Pushs("files")
-
-// 	for iter.Rewind(); iter.Valid(); iter.Next() {
-// 		fileNode := iter.Node()
-
-// 		_, valueType, _, err := fileNode.NodeInfo(nelson.ValueKey)
-// 		if errors.Cause(err) == types.Err404 {
-// 			continue
-// 		} else if err != nil {
-// 			return nil, err
-// 		}
-
-// 		contentType, _, err := fileNode.StringValue(nelson.ContentTypeKey)
-// 		if err != nil {
-// 			return nil, err
-// 		}
-
-// 		if contentType == "link" {
-// 			linkStr, _, err := fileNode.StringValue(nelson.ValueKey)
-// 			if err != nil {
-// 				return nil, err
-// 			}
-// 			linkType, linkValue := nelson.DetermineLinkType(linkStr)
-// 			if linkType != nelson.LinkTypeRef {
-// 				// @@TODO: might want to support state links?
-// 				continue
-// 			}
-
-// 			var refID types.RefID
-// 			err = refID.UnmarshalText([]byte(linkValue))
-// 			if err != nil {
-// 				return nil, err
-// 			} else if refID.HashAlg != types.SHA1 {
-// 				// @@TODO: warn?  use refstore to find it?
-// 				continue
-// 			}
-
-// 			objectReader, objectSize, err := refStore.Object(refID)
-// 			if err != nil {
-// 				return nil, err
-// 			}
-
-// 			filename := string(fileNode.Keypath().RelativeTo(baseKeypath))
-
-// 			blobOID, err := r.addBlobToODB(objectReader, objectSize, filename, odb, idx)
-// 			if err != nil {
-// 				return nil, err
-// 			}
-
-// 			err = idx.Add(&git.IndexEntry{
-// 				Ctime: git.IndexTime{
-// 					// Seconds: int32(ctime),
-// 				},
-// 				Mtime: git.IndexTime{
-// 					// Seconds: int32(mtime),
-// 				},
-// 				Mode: git.FilemodeBlob,
-// 				Uid:  uint32(os.Getuid()),
-// 				Gid:  uint32(os.Getgid()),
-// 				Size: uint32(objectSize),
-// 				Id:   blobOID,
-// 				Path: filename,
-// 			})
-// 			if err != nil {
-// 				return nil, err
-// 			}
-
-// 		} else if valueType == tree.ValueTypeString {
-// 			// @@TODO: handle inline files
-// 			continue
-// 		}
-// 	}
-
-// 	//
-// 	// Write the new tree object to disk
-// 	//
-// 	treeOid, err := idx.WriteTreeTo(repo)
-// 	if err != nil {
-// 		return nil, err
-// 	}
-
-// 	commitTree, err := repo.LookupTree(treeOid)
-// 	if err != nil {
-// 		return nil, err
-// 	}
-
-// 	//
-// 	// Create a commit based on the new tree
-// 	//
-// 	var (
-// 		author    = &git.Signature{Name: authorName, Email: authorEmail, When: authorTimestamp}
-// 		committer = &git.Signature{Name: committerName, Email: committerEmail, When: committerTimestamp}
-// 	)
-// 	var parentCommitStrs []string
-// 	for _, parent := range parentCommits {
-// 		parentCommitStrs = append(parentCommitStrs, parent.Id().String())
-// 	}
-
-// 	// @@TODO: support other remotes
-// 	commitOID, err := repo.CreateCommit("", author, committer, message, commitTree, parentCommits...)
-// 	if err != nil {
-// 		return nil, err
-// 	}
-
-// 	return repo.LookupCommit(commitOID)
-// }
-
-// func (r *gitResolver) addBlobToODB(objectReader io.Reader, objectSize int64, filename string, odb *git.Odb, idx *git.Index) (*git.Oid, error) {
-// 	writeStream, err := odb.NewWriteStream(objectSize, git.ObjectBlob)
-// 	if err != nil {
-// 		return nil, err
-// 	}
-// 	defer func() {
-// 		if writeStream != nil {
-// 			writeStream.Close()
-// 		}
-// 	}()
-
-// 	objectData, err := ioutil.ReadAll(objectReader)
-// 	if err != nil {
-// 		return nil, err
-// 	}
-
-// 	n, err := writeStream.Write(objectData)
-// 	if err != nil {
-// 		return nil, err
-// 	} else if n < len(objectData) {
-// 		return nil, errors.New("did not finish writing commit")
-// 	}
-
-// 	err = writeStream.Close()
-// 	if err != nil {
-// 		return nil, err
-// 	}
-// 	oid := writeStream.Id
-// 	writeStream = nil // necessary to prevent the defer statement above from panicking
-// 	return &oid, nil
-// }
+import (
+	"bytes"
+	"io/ioutil"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/pkg/errors"
+
+	"redwood.dev/ctx"
+	"redwood.dev/nelson"
+	"redwood.dev/tree"
+	"redwood.dev/types"
+	"redwood.dev/utils"
+)
+
+// gitResolver understands the state layout written by git-remote-helper:
+//
+//	commits/<sha>/{parents, message, timestamp, author, committer, files}
+//	refs/heads/<name>/{HEAD, reflog, worktree}
+//
+// Every commit that passes through the resolver is materialized (trees and commits,
+// plus any blobs whose contents are available) into a git object database backed by
+// the RefStore.  Updates to refs/heads/<name>/HEAD must be fast-forwards unless the
+// tx also sets refs/heads/<name>/force to true.  When a non-fast-forward update is
+// caused by concurrent pushes (i.e., the new commit shares a parent with commits in
+// the branch's history), the resolver creates a deterministic auto-merge commit
+// instead of rejecting the push.
+type gitResolver struct {
+	ctx.Logger
+}
+
+// Ensure gitResolver conforms to the Resolver interface
+var _ Resolver = (*gitResolver)(nil)
+
+var (
+	ErrNonFastForward = errors.New("non-fast-forward ref update")
+
+	gitCommitsKeypath  = tree.Keypath("commits")
+	gitRefsHeadKeypath = tree.Keypath("refs").Pushs("heads")
+)
+
+const (
+	gitAutoMergeMessage = "Redwood auto-merge"
+	gitAutoMergeName    = "Redwood"
+	gitAutoMergeEmail   = "redwood@localhost"
+)
+
+func NewGitResolver(config tree.Node, internalState map[string]interface{}) (_ Resolver, err error) {
+	return &gitResolver{Logger: ctx.NewLogger("resolver:git")}, nil
+}
+
+func (r *gitResolver) InternalState() map[string]interface{} {
+	return map[string]interface{}{}
+}
+
+type gitRefUpdate struct {
+	name    string
+	force   bool
+	head    *Patch
+	patches []Patch
+}
+
+func (r *gitResolver) ResolveState(
+	state tree.Node,
+	refStore RefStore,
+	sender types.Address,
+	txID types.ID,
+	parents []types.ID,
+	patches []Patch,
+) (err error) {
+	defer utils.Annotate(&err, "gitResolver.ResolveState")
+
+	var (
+		refUpdates   = make(map[string]*gitRefUpdate)
+		refNames     []string
+		commitSHAs   []string
+		commitsSeen  = make(map[string]bool)
+		otherPatches []Patch
+		objectStore  = newGitObjectStore(refStore)
+	)
+
+	for _, patch := range patches {
+		if refName, field, isRefPatch := r.parseRefPatch(patch.Keypath); isRefPatch {
+			update, exists := refUpdates[refName]
+			if !exists {
+				update = &gitRefUpdate{name: refName}
+				refUpdates[refName] = update
+				refNames = append(refNames, refName)
+			}
+			switch field {
+			case "force":
+				force, _ := patch.Val.(bool)
+				update.force = force
+			case "HEAD":
+				patch := patch
+				update.head = &patch
+				update.patches = append(update.patches, patch)
+			default:
+				update.patches = append(update.patches, patch)
+			}
+			continue
+		}
+
+		if patch.Keypath.StartsWith(gitCommitsKeypath) && patch.Keypath.NumParts() > 1 {
+			sha := string(patch.Keypath.Part(1))
+			if !commitsSeen[sha] {
+				commitsSeen[sha] = true
+				commitSHAs = append(commitSHAs, sha)
+			}
+		}
+		otherPatches = append(otherPatches, patch)
+	}
+
+	err = r.applyPatches(state, otherPatches)
+	if err != nil {
+		return err
+	}
+
+	for _, sha := range commitSHAs {
+		commitNode := state.NodeAt(gitCommitsKeypath.Pushs(sha), nil)
+		oid, err := r.materializeCommit(commitNode, objectStore, refStore)
+		if err != nil {
+			// Blobs may simply not have arrived yet.  The commit remains in the state
+			// tree and will be materialized again when it's part of an auto-merge.
+			r.Warnf("could not materialize commit %v: %v", sha, err)
+		} else if oid.String() != sha {
+			r.Warnf("materialized commit %v has a different hash (%v)", sha, oid)
+		}
+	}
+
+	for _, refName := range refNames {
+		err = r.updateRef(state, refUpdates[refName], objectStore, refStore)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *gitResolver) applyPatches(state tree.Node, patches []Patch) error {
+	for _, p := range patches {
+		err := p.Apply(state)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseRefPatch determines whether a patch targets one of the fields of a branch ref.
+// Branch names may themselves contain slashes.
+func (r *gitResolver) parseRefPatch(keypath tree.Keypath) (refName string, field string, ok bool) {
+	if !keypath.StartsWith(gitRefsHeadKeypath) || keypath.NumParts() < 4 {
+		return "", "", false
+	}
+	rest, last := keypath.Pop()
+	switch string(last) {
+	case "HEAD", "reflog", "worktree", "force":
+	default:
+		return "", "", false
+	}
+	return string(rest.RelativeTo(gitRefsHeadKeypath)), string(last), true
+}
+
+func (r *gitResolver) updateRef(state tree.Node, update *gitRefUpdate, objectStore *gitObjectStore, refStore RefStore) error {
+	refKeypath := gitRefsHeadKeypath.Push(tree.Keypath(update.name))
+
+	if update.head == nil {
+		return r.applyPatches(state, update.patches)
+	}
+
+	oldHead, _, err := state.StringValue(refKeypath.Pushs("HEAD"))
+	if err != nil && errors.Cause(err) != types.Err404 {
+		return err
+	}
+	newHead, _ := update.head.Val.(string)
+
+	switch {
+	case oldHead == "" || newHead == "" || oldHead == newHead || update.force:
+		return r.applyPatches(state, update.patches)
+
+	case r.isAncestor(state, oldHead, newHead):
+		// Fast-forward
+		return r.applyPatches(state, update.patches)
+
+	case r.isAncestor(state, newHead, oldHead):
+		// A stale update (for instance, an older push that arrived late).  Nothing
+		// to do.
+		return nil
+	}
+
+	siblings, err := r.commitsWithSharedParent(state, newHead, oldHead)
+	if err != nil {
+		return err
+	} else if len(siblings) == 0 {
+		return errors.Wrapf(ErrNonFastForward, "refs/heads/%v: %v is not a descendant of %v", update.name, newHead, oldHead)
+	}
+
+	mergeSHA, err := r.autoMerge(state, append(siblings, newHead), objectStore, refStore)
+	if err != nil {
+		return err
+	}
+
+	// If the old head was an auto-merge commit that the new one supersedes, and
+	// nobody has built on top of it, remove it so that peers receiving the same
+	// pushes in a different order end up with the same set of commits.
+	if r.isAutoMergeCommit(state, oldHead) && !r.hasChildren(state, oldHead) {
+		err = state.Delete(gitCommitsKeypath.Pushs(oldHead), nil)
+		if err != nil && errors.Cause(err) != types.Err404 {
+			return err
+		}
+	}
+
+	mergedPatches := make([]Patch, len(update.patches))
+	for i, patch := range update.patches {
+		mergedPatches[i] = patch
+		switch string(patch.Keypath.Part(-1)) {
+		case "HEAD":
+			mergedPatches[i].Val = mergeSHA
+		case "worktree":
+			mergedPatches[i].Val = replaceCommitSHA(patch.Val, newHead, mergeSHA)
+		}
+	}
+	return r.applyPatches(state, mergedPatches)
+}
+
+func replaceCommitSHA(val interface{}, oldSHA, newSHA string) interface{} {
+	switch v := val.(type) {
+	case string:
+		return strings.Replace(v, "/commits/"+oldSHA, "/commits/"+newSHA, -1)
+	case map[string]interface{}:
+		replaced := make(map[string]interface{}, len(v))
+		for k, x := range v {
+			replaced[k] = replaceCommitSHA(x, oldSHA, newSHA)
+		}
+		return replaced
+	default:
+		return v
+	}
+}
+
+func (r *gitResolver) commitParents(state tree.Node, sha string) []string {
+	val, exists, err := state.Value(gitCommitsKeypath.Pushs(sha).Pushs("parents"), nil)
+	if err != nil || !exists {
+		return nil
+	}
+	slice, _ := val.([]interface{})
+	var parents []string
+	for _, p := range slice {
+		if s, is := p.(string); is {
+			parents = append(parents, s)
+		}
+	}
+	return parents
+}
+
+// isAncestor returns true if `ancestor` is reachable from `descendant` by following
+// the parents recorded in the state tree.
+func (r *gitResolver) isAncestor(state tree.Node, ancestor, descendant string) bool {
+	seen := map[string]bool{descendant: true}
+	queue := []string{descendant}
+	for len(queue) > 0 {
+		sha := queue[0]
+		queue = queue[1:]
+		for _, parent := range r.commitParents(state, sha) {
+			if parent == ancestor {
+				return true
+			} else if !seen[parent] {
+				seen[parent] = true
+				queue = append(queue, parent)
+			}
+		}
+	}
+	return false
+}
+
+func (r *gitResolver) allCommitSHAs(state tree.Node) []string {
+	var shas []string
+	for _, key := range state.NodeAt(gitCommitsKeypath, nil).Subkeys() {
+		shas = append(shas, string(key))
+	}
+	return shas
+}
+
+func (r *gitResolver) hasChildren(state tree.Node, sha string) bool {
+	for _, other := range r.allCommitSHAs(state) {
+		for _, parent := range r.commitParents(state, other) {
+			if parent == sha {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *gitResolver) isAutoMergeCommit(state tree.Node, sha string) bool {
+	message, _, _ := state.StringValue(gitCommitsKeypath.Pushs(sha).Pushs("message"))
+	author, _, _ := state.StringValue(gitCommitsKeypath.Pushs(sha).Pushs("author").Pushs("name"))
+	return message == gitAutoMergeMessage && author == gitAutoMergeName
+}
+
+// commitsWithSharedParent returns the (non-auto-merge) commits in the current branch's
+// history that share a parent with the given commit.
+func (r *gitResolver) commitsWithSharedParent(state tree.Node, sha string, head string) ([]string, error) {
+	// @@TODO: use an index to make this efficient
+	parents := make(map[string]bool)
+	for _, parent := range r.commitParents(state, sha) {
+		parents[parent] = true
+	}
+
+	var siblings []string
+	for _, other := range r.allCommitSHAs(state) {
+		if other == sha || r.isAutoMergeCommit(state, other) {
+			continue
+		} else if other != head && !r.isAncestor(state, other, head) {
+			continue
+		}
+		for _, parent := range r.commitParents(state, other) {
+			if parents[parent] {
+				siblings = append(siblings, other)
+				break
+			}
+		}
+	}
+	return siblings, nil
+}
+
+func (r *gitResolver) autoMerge(state tree.Node, shas []string, objectStore *gitObjectStore, refStore RefStore) (string, error) {
+	// Arbitrary (but deterministic) merge resolution: convert the commit SHAs to integers
+	// and order them numerically.  Files from later commits win.
+	sort.Slice(shas, func(i, j int) bool {
+		x, _ := big.NewInt(0).SetString(shas[i], 16)
+		y, _ := big.NewInt(0).SetString(shas[j], 16)
+		if x == nil || y == nil {
+			return shas[i] < shas[j]
+		}
+		return x.Cmp(y) < 0
+	})
+
+	files := make(map[string]interface{})
+	mergeParents := make([]interface{}, len(shas))
+	for i, sha := range shas {
+		mergeParents[i] = sha
+
+		commitFiles, exists, err := state.Value(gitCommitsKeypath.Pushs(sha).Pushs("files"), nil)
+		if err != nil && errors.Cause(err) != types.Err404 {
+			return "", err
+		} else if !exists {
+			continue
+		}
+		asMap, _ := commitFiles.(map[string]interface{})
+		mergeGitFiles(files, asMap)
+	}
+
+	timestamp := time.Unix(0, 0).UTC().Format(time.RFC3339)
+	signature := map[string]interface{}{
+		"name":      gitAutoMergeName,
+		"email":     gitAutoMergeEmail,
+		"timestamp": timestamp,
+	}
+
+	mergeCommitNode := tree.NewMemoryNode()
+	err := mergeCommitNode.Set(nil, nil, map[string]interface{}{
+		"parents":   mergeParents,
+		"message":   gitAutoMergeMessage,
+		"timestamp": timestamp,
+		"author":    signature,
+		"committer": signature,
+		"files":     files,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	oid, err := r.materializeCommit(mergeCommitNode, objectStore, refStore)
+	if err != nil {
+		return "", errors.Wrap(err, "could not create auto-merge commit")
+	}
+
+	err = state.Set(gitCommitsKeypath.Pushs(oid.String()), nil, mergeCommitNode)
+	if err != nil {
+		return "", err
+	}
+	return oid.String(), nil
+}
+
+func mergeGitFiles(into, from map[string]interface{}) {
+	for name, val := range from {
+		fromDir, fromIsDir := val.(map[string]interface{})
+		intoDir, intoIsDir := into[name].(map[string]interface{})
+		if fromIsDir && !isGitFileNode(fromDir) && intoIsDir && !isGitFileNode(intoDir) {
+			mergeGitFiles(intoDir, fromDir)
+		} else {
+			into[name] = DeepCopyJSValue(val)
+		}
+	}
+}
+
+func isGitFileNode(m map[string]interface{}) bool {
+	_, hasContentType := m[string(nelson.ContentTypeKey)]
+	_, hasValue := m[string(nelson.ValueKey)]
+	return hasContentType || hasValue
+}
+
+func (r *gitResolver) materializeCommit(commitNode tree.Node, objectStore *gitObjectStore, refStore RefStore) (_ plumbing.Hash, err error) {
+	defer utils.WithStack(&err)
+
+	var parentHashes []plumbing.Hash
+	parentsVal, _, err := commitNode.Value(tree.Keypath("parents"), nil)
+	if err != nil && errors.Cause(err) != types.Err404 {
+		return plumbing.ZeroHash, err
+	}
+	parents, _ := parentsVal.([]interface{})
+	for _, p := range parents {
+		parentStr, is := p.(string)
+		if !is {
+			return plumbing.ZeroHash, errors.New("bad commit parent")
+		}
+		parentHashes = append(parentHashes, plumbing.NewHash(parentStr))
+	}
+
+	message, exists, err := commitNode.StringValue(tree.Keypath("message"))
+	if err != nil {
+		return plumbing.ZeroHash, err
+	} else if !exists {
+		return plumbing.ZeroHash, errors.New("commit is missing 'message' field")
+	}
+
+	author, err := r.signatureFromNode(commitNode, tree.Keypath("author"))
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	committer, err := r.signatureFromNode(commitNode, tree.Keypath("committer"))
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	filesVal, _, err := commitNode.Value(tree.Keypath("files"), nil)
+	if err != nil && errors.Cause(err) != types.Err404 {
+		return plumbing.ZeroHash, err
+	}
+	files, _ := filesVal.(map[string]interface{})
+
+	treeHash, err := r.writeTree(files, objectStore, refStore)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	commit := &object.Commit{
+		Author:       author,
+		Committer:    committer,
+		Message:      message,
+		TreeHash:     treeHash,
+		ParentHashes: parentHashes,
+	}
+	obj := objectStore.NewEncodedObject()
+	err = commit.Encode(obj)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return objectStore.SetEncodedObject(obj)
+}
+
+func (r *gitResolver) signatureFromNode(commitNode tree.Node, keypath tree.Keypath) (object.Signature, error) {
+	name, exists, err := commitNode.StringValue(keypath.Pushs("name"))
+	if err != nil {
+		return object.Signature{}, err
+	} else if !exists {
+		return object.Signature{}, errors.Errorf("commit is missing '%v/name' field", keypath)
+	}
+	email, exists, err := commitNode.StringValue(keypath.Pushs("email"))
+	if err != nil {
+		return object.Signature{}, err
+	} else if !exists {
+		return object.Signature{}, errors.Errorf("commit is missing '%v/email' field", keypath)
+	}
+	timestampStr, exists, err := commitNode.StringValue(keypath.Pushs("timestamp"))
+	if err != nil {
+		return object.Signature{}, err
+	} else if !exists {
+		return object.Signature{}, errors.Errorf("commit is missing '%v/timestamp' field", keypath)
+	}
+	timestamp, err := time.Parse(time.RFC3339Nano, timestampStr)
+	if err != nil {
+		return object.Signature{}, errors.WithStack(err)
+	}
+	return object.Signature{Name: name, Email: email, When: timestamp}, nil
+}
+
+func (r *gitResolver) writeTree(files map[string]interface{}, objectStore *gitObjectStore, refStore RefStore) (plumbing.Hash, error) {
+	var entries []object.TreeEntry
+	for name, val := range files {
+		m, is := val.(map[string]interface{})
+		if !is {
+			// @@TODO: handle inline files
+			continue
+		}
+
+		if isGitFileNode(m) {
+			oid, err := r.writeBlob(m, objectStore, refStore)
+			if err != nil {
+				return plumbing.ZeroHash, errors.Wrapf(err, "file '%v'", name)
+			}
+			entries = append(entries, object.TreeEntry{Name: name, Mode: gitFileMode(m["mode"]), Hash: oid})
+
+		} else {
+			oid, err := r.writeTree(m, objectStore, refStore)
+			if err != nil {
+				return plumbing.ZeroHash, errors.Wrapf(err, "directory '%v'", name)
+			}
+			entries = append(entries, object.TreeEntry{Name: name, Mode: filemode.Dir, Hash: oid})
+		}
+	}
+
+	// Git sorts tree entries as though directory names had a trailing slash
+	sortKey := func(e object.TreeEntry) string {
+		if e.Mode == filemode.Dir {
+			return e.Name + "/"
+		}
+		return e.Name
+	}
+	sort.Slice(entries, func(i, j int) bool { return sortKey(entries[i]) < sortKey(entries[j]) })
+
+	obj := objectStore.NewEncodedObject()
+	err := (&object.Tree{Entries: entries}).Encode(obj)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return objectStore.SetEncodedObject(obj)
+}
+
+// writeBlob determines the git object ID of a file in a commit's file tree and, if
+// the file's contents are available in the RefStore, stores the blob in the object
+// database.  git-remote-helper records the blob's object ID in the file's "oid" field,
+// which allows trees to be built even when the contents haven't been fetched yet.
+func (r *gitResolver) writeBlob(file map[string]interface{}, objectStore *gitObjectStore, refStore RefStore) (plumbing.Hash, error) {
+	oid := plumbing.ZeroHash
+	if oidStr, is := file["oid"].(string); is {
+		oid = plumbing.NewHash(oidStr)
+		if objectStore.HasEncodedObject(oid) == nil {
+			return oid, nil
+		}
+	}
+
+	contentType, _ := file[string(nelson.ContentTypeKey)].(string)
+	linkStr, _ := file[string(nelson.ValueKey)].(string)
+	linkType, linkValue := nelson.DetermineLinkType(linkStr)
+	if contentType != "link" || linkType != nelson.LinkTypeRef {
+		if oid.IsZero() {
+			return plumbing.ZeroHash, errors.New("file is not a ref link and has no oid")
+		}
+		return oid, nil
+	}
+
+	var refID types.RefID
+	err := refID.UnmarshalText([]byte(linkValue))
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	have, err := refStore.HaveObject(refID)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	} else if !have {
+		if oid.IsZero() {
+			return plumbing.ZeroHash, errors.Wrapf(types.Err404, "blob contents for %v", linkValue)
+		}
+		return oid, nil
+	}
+
+	reader, _, err := refStore.Object(refID)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	defer reader.Close()
+
+	contents, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return plumbing.ZeroHash, errors.WithStack(err)
+	}
+
+	obj := objectStore.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	obj.SetSize(int64(len(contents)))
+	_, err = obj.(*plumbing.MemoryObject).Write(contents)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return objectStore.SetEncodedObject(obj)
+}
+
+func gitFileMode(val interface{}) filemode.FileMode {
+	var mode uint64
+	switch v := val.(type) {
+	case float64:
+		mode = uint64(v)
+	case int64:
+		mode = uint64(v)
+	case uint64:
+		mode = v
+	case int:
+		mode = uint64(v)
+	}
+	if mode == 0 {
+		return filemode.Regular
+	}
+	return filemode.FileMode(mode)
+}
+
+// gitObjectStore is a git object database backed by a RefStore.  Objects are stored
+// in their loose format ("<type> <size>\x00<contents>"), so the SHA1 hash that the
+// RefStore computes for each object is also its git object ID.
+type gitObjectStore struct {
+	refStore RefStore
+}
+
+// Ensure gitObjectStore conforms to the storer.EncodedObjectStorer interface
+var _ storer.EncodedObjectStorer = (*gitObjectStore)(nil)
+
+func newGitObjectStore(refStore RefStore) *gitObjectStore {
+	return &gitObjectStore{refStore: refStore}
+}
+
+func (s *gitObjectStore) refIDForHash(h plumbing.Hash) types.RefID {
+	refID := types.RefID{HashAlg: types.SHA1}
+	copy(refID.Hash[:], h[:])
+	return refID
+}
+
+func (s *gitObjectStore) NewEncodedObject() plumbing.EncodedObject {
+	return &plumbing.MemoryObject{}
+}
+
+func (s *gitObjectStore) SetEncodedObject(obj plumbing.EncodedObject) (plumbing.Hash, error) {
+	hash := obj.Hash()
+
+	have, err := s.refStore.HaveObject(s.refIDForHash(hash))
+	if err != nil {
+		return plumbing.ZeroHash, err
+	} else if have {
+		return hash, nil
+	}
+
+	reader, err := obj.Reader()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	defer reader.Close()
+
+	contents, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return plumbing.ZeroHash, errors.WithStack(err)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	buf.WriteString(obj.Type().String())
+	buf.WriteByte(' ')
+	buf.WriteString(strconv.FormatInt(obj.Size(), 10))
+	buf.WriteByte(0)
+	buf.Write(contents)
+
+	sha1Hash, _, err := s.refStore.StoreObject(ioutil.NopCloser(buf))
+	if err != nil {
+		return plumbing.ZeroHash, err
+	} else if !bytes.Equal(sha1Hash[:20], hash[:]) {
+		return plumbing.ZeroHash, errors.Errorf("git object hash mismatch (expected %v, got %x)", hash, sha1Hash[:20])
+	}
+	return hash, nil
+}
+
+func (s *gitObjectStore) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	refID := s.refIDForHash(h)
+
+	have, err := s.refStore.HaveObject(refID)
+	if err != nil {
+		return nil, err
+	} else if !have {
+		return nil, plumbing.ErrObjectNotFound
+	}
+
+	reader, _, err := s.refStore.Object(refID)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// Raw file contents (as uploaded by git-remote-helper) share the RefStore with
+	// loose git objects, so anything without a valid header is treated as missing.
+	nul := bytes.IndexByte(data, 0)
+	if nul < 0 {
+		return nil, plumbing.ErrObjectNotFound
+	}
+	header := strings.SplitN(string(data[:nul]), " ", 2)
+	if len(header) != 2 {
+		return nil, plumbing.ErrObjectNotFound
+	}
+	objType, err := plumbing.ParseObjectType(header[0])
+	if err != nil {
+		return nil, plumbing.ErrObjectNotFound
+	} else if t != plumbing.AnyObject && t != objType {
+		return nil, plumbing.ErrObjectNotFound
+	}
+
+	obj := &plumbing.MemoryObject{}
+	obj.SetType(objType)
+	obj.SetSize(int64(len(data) - nul - 1))
+	_, err = obj.Write(data[nul+1:])
+	if err != nil {
+		return nil, err
+	} else if obj.Hash() != h {
+		return nil, plumbing.ErrObjectNotFound
+	}
+	return obj, nil
+}
+
+func (s *gitObjectStore) IterEncodedObjects(t plumbing.ObjectType) (storer.EncodedObjectIter, error) {
+	refIDs, err := s.refStore.AllHashes()
+	if err != nil {
+		return nil, err
+	}
+
+	var objs []plumbing.EncodedObject
+	for _, refID := range refIDs {
+		if refID.HashAlg != types.SHA1 {
+			continue
+		}
+		var h plumbing.Hash
+		copy(h[:], refID.Hash[:20])
+
+		obj, err := s.EncodedObject(t, h)
+		if err == plumbing.ErrObjectNotFound {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		objs = append(objs, obj)
+	}
+	return storer.NewEncodedObjectSliceIter(objs), nil
+}
+
+func (s *gitObjectStore) HasEncodedObject(h plumbing.Hash) error {
+	_, err := s.EncodedObject(plumbing.AnyObject, h)
+	return err
+}
+
+func (s *gitObjectStore) EncodedObjectSize(h plumbing.Hash) (int64, error) {
+	obj, err := s.EncodedObject(plumbing.AnyObject, h)
+	if err != nil {
+		return 0, err
+	}
+	return obj.Size(), nil
+}
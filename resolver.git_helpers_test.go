@@ -0,0 +1,9 @@
+package redwood
+
+import (
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+func NewGitObjectStore(refStore RefStore) storer.EncodedObjectStorer {
+	return newGitObjectStore(refStore)
+}
@@ -0,0 +1,169 @@
+package redwood_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"redwood.dev"
+	"redwood.dev/testutils"
+	"redwood.dev/tree"
+	"redwood.dev/types"
+)
+
+func setupRefStore(t *testing.T) (redwood.RefStore, func()) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "refstore-test-")
+	require.NoError(t, err)
+
+	refStore := redwood.NewRefStore(dir)
+	err = refStore.Start()
+	require.NoError(t, err)
+
+	return refStore, func() {
+		refStore.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func storeGitFile(t *testing.T, refStore redwood.RefStore, contents string, withOID bool) M {
+	t.Helper()
+
+	sha1Hash, _, err := refStore.StoreObject(ioutil.NopCloser(bytes.NewBufferString(contents)))
+	require.NoError(t, err)
+
+	file := M{
+		"Content-Type": "link",
+		"value":        "ref:sha1:" + sha1Hash.Hex()[:40],
+		"mode":         float64(0100644),
+	}
+	if withOID {
+		file["oid"] = plumbing.ComputeHash(plumbing.BlobObject, []byte(contents)).String()
+	}
+	return file
+}
+
+func gitCommitPatches(sha string, parents []string, files M) []redwood.Patch {
+	parentsVal := S{}
+	for _, p := range parents {
+		parentsVal = append(parentsVal, p)
+	}
+	signature := M{"name": "Someone", "email": "someone@example.com", "timestamp": "2021-03-01T12:00:00-05:00"}
+	return []redwood.Patch{
+		{Keypath: tree.Keypath("commits/" + sha + "/parents"), Val: parentsVal},
+		{Keypath: tree.Keypath("commits/" + sha + "/message"), Val: "commit " + sha[:4]},
+		{Keypath: tree.Keypath("commits/" + sha + "/timestamp"), Val: "2021-03-01T12:00:00-05:00"},
+		{Keypath: tree.Keypath("commits/" + sha + "/author"), Val: signature},
+		{Keypath: tree.Keypath("commits/" + sha + "/committer"), Val: signature},
+		{Keypath: tree.Keypath("commits/" + sha + "/files"), Val: files},
+	}
+}
+
+func gitRefPatches(sha string, force bool) []redwood.Patch {
+	patches := []redwood.Patch{
+		{Keypath: tree.Keypath("refs/heads/master/HEAD"), Val: sha},
+		{Keypath: tree.Keypath("refs/heads/master/worktree"), Val: M{
+			"Content-Type": "link",
+			"value":        "state:somewhere.com/repo/commits/" + sha + "/files",
+		}},
+	}
+	if force {
+		patches = append(patches, redwood.Patch{Keypath: tree.Keypath("refs/heads/master/force"), Val: true})
+	}
+	return patches
+}
+
+func TestGitResolver(t *testing.T) {
+	refStore, cleanup := setupRefStore(t)
+	defer cleanup()
+
+	var (
+		shaP = "1111111111111111111111111111111111111111"
+		shaA = "2222222222222222222222222222222222222222"
+		shaB = "3333333333333333333333333333333333333333"
+		shaX = "4444444444444444444444444444444444444444"
+	)
+
+	fileV1 := storeGitFile(t, refStore, "version 1\n", true)
+	fileV2 := storeGitFile(t, refStore, "version 2\n", true)
+	fileOther := storeGitFile(t, refStore, "other\n", false)
+
+	commits := map[string][]redwood.Patch{
+		shaP: gitCommitPatches(shaP, nil, M{"a.txt": fileV1}),
+		shaA: gitCommitPatches(shaA, []string{shaP}, M{"a.txt": fileV2}),
+		shaB: gitCommitPatches(shaB, []string{shaP}, M{"a.txt": fileV1, "dir": M{"b.txt": fileOther}}),
+		shaX: gitCommitPatches(shaX, []string{"5555555555555555555555555555555555555555"}, M{"x.txt": fileOther}),
+	}
+
+	push := func(resolver redwood.Resolver, state tree.Node, sha string, force bool) error {
+		err := resolver.ResolveState(state, refStore, testutils.RandomAddress(t), types.RandomID(), nil, commits[sha])
+		require.NoError(t, err)
+		return resolver.ResolveState(state, refStore, testutils.RandomAddress(t), types.RandomID(), nil, gitRefPatches(sha, force))
+	}
+
+	var mergeSHAs []string
+	for _, order := range [][]string{{shaP, shaA, shaB}, {shaP, shaB, shaA}} {
+		state := tree.NewMemoryNode()
+		resolver, err := redwood.NewGitResolver(nil, nil)
+		require.NoError(t, err)
+
+		for _, sha := range order {
+			err = push(resolver, state, sha, false)
+			require.NoError(t, err)
+		}
+
+		head, exists, err := state.StringValue(tree.Keypath("refs/heads/master/HEAD"))
+		require.NoError(t, err)
+		require.True(t, exists)
+		require.NotEqual(t, shaA, head)
+		require.NotEqual(t, shaB, head)
+		mergeSHAs = append(mergeSHAs, head)
+
+		parents, _, err := state.Value(tree.Keypath("commits/"+head+"/parents"), nil)
+		require.NoError(t, err)
+		require.Equal(t, S{shaA, shaB}, parents)
+
+		worktree, _, err := state.StringValue(tree.Keypath("refs/heads/master/worktree/value"))
+		require.NoError(t, err)
+		require.Equal(t, "state:somewhere.com/repo/commits/"+head+"/files", worktree)
+
+		// Unrelated history is rejected unless forced
+		err = push(resolver, state, shaX, false)
+		require.True(t, errors.Cause(err) == redwood.ErrNonFastForward)
+
+		err = resolver.ResolveState(state, refStore, testutils.RandomAddress(t), types.RandomID(), nil, gitRefPatches(shaX, true))
+		require.NoError(t, err)
+		head, _, err = state.StringValue(tree.Keypath("refs/heads/master/HEAD"))
+		require.NoError(t, err)
+		require.Equal(t, shaX, head)
+	}
+	require.Equal(t, mergeSHAs[0], mergeSHAs[1])
+
+	// The auto-merge commit was materialized into the RefStore-backed object database
+	objectStore := redwood.NewGitObjectStore(refStore)
+	mergeCommit, err := object.GetCommit(objectStore, plumbing.NewHash(mergeSHAs[0]))
+	require.NoError(t, err)
+	require.Equal(t, "Redwood auto-merge", mergeCommit.Message)
+	require.Equal(t, []plumbing.Hash{plumbing.NewHash(shaA), plumbing.NewHash(shaB)}, mergeCommit.ParentHashes)
+
+	mergeTree, err := mergeCommit.Tree()
+	require.NoError(t, err)
+	file, err := mergeTree.File("dir/b.txt")
+	require.NoError(t, err)
+	contents, err := file.Contents()
+	require.NoError(t, err)
+	require.Equal(t, "other\n", contents)
+
+	file, err = mergeTree.File("a.txt")
+	require.NoError(t, err)
+	contents, err = file.Contents()
+	require.NoError(t, err)
+	require.Equal(t, "version 1\n", contents)
+}
@@ -85,6 +85,12 @@ func (r *jsResolver) ResolveState(state tree.Node, refStore RefStore, sender typ
 		if patch.Range != nil {
 			convertedPatch["range"] = []interface{}{patch.Range.Start, patch.Range.End}
 		}
+		if patch.Op != PatchOpSet {
+			convertedPatch["op"] = string(patch.Op)
+		}
+		if patch.From != nil {
+			convertedPatch["from"] = patch.From.PartStrings()
+		}
 		convertedPatches[i] = convertedPatch
 	}
 
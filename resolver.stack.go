@@ -1,50 +1,154 @@
 package redwood
 
-//import (
-//    "github.com/pkg/errors"
-//)
-//
-//type stackResolver struct {
-//    resolver
-//    state     interface{}
-//    resolvers []Resolver
-//}
-//
-//func (r *stackResolver) InternalState() map[string]interface{} {
-//    return nil
-//}
-//
-//func NewStackResolver(params map[string]interface{}, internalState map[string]interface{}) (Resolver, error) {
-//    children, exists := getSlice(params, []string{"children"})
-//    if !exists {
-//        return nil, errors.New("stack resolver needs an array 'children' param")
-//    }
-//
-//    var resolvers []Resolver
-//    for i := range children {
-//        config, is := children[i].(map[string]interface{})
-//        if !is {
-//            return nil, errors.New("stack resolver found something that didn't look like a resolver config")
-//        }
-//
-//        resolver, err := initResolverFromConfig(config, nil) // @@TODO: stack resolver internal state...?
-//        if err != nil {
-//            return nil, err
-//        }
-//
-//        resolvers = append(resolvers, resolver)
-//    }
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+
+	"redwood.dev/tree"
+	"redwood.dev/types"
+)
+
+// stackResolver pipelines several resolvers under a single keypath.  The first
+// resolver receives the tx's patches.  Every later resolver sees the state as
+// the resolver before it left it, and receives the patches that describe what
+// that resolver changed (one set or delete per changed top-level key, or a
+// single set of the whole state if it isn't an object) rather than the tx's
+// original patches, which may no longer make sense against that state.
 //
-//    return &stackResolver{resolvers: resolvers}, nil
-//}
+// Config:
 //
-//func (r *stackResolver) ResolveState(state interface{}, sender Address, txID ID, parents []ID, patches []Patch) (interface{}, error) {
-//    var err error
-//    for _, resolver := range r.resolvers {
-//        state, err = resolver.ResolveState(state, sender, txID, parents, patches)
-//        if err != nil {
-//            return nil, err
-//        }
-//    }
-//    return state, nil
-//}
+//	"Merge-Type": {
+//	    "Content-Type": "resolver/stack",
+//	    "value": {
+//	        "resolvers": [
+//	            { "Content-Type": "resolver/sync9" },
+//	            { "Content-Type": "resolver/js", "value": { "src": ... } }
+//	        ]
+//	    }
+//	}
+type stackResolver struct {
+	resolvers []Resolver
+}
+
+var stackResolversKeypath = tree.Keypath("resolvers")
+
+func NewStackResolver(config tree.Node, internalState map[string]interface{}) (Resolver, error) {
+	nodeType, _, length, err := config.NodeInfo(stackResolversKeypath)
+	if errors.Cause(err) == types.Err404 || (err == nil && nodeType != tree.NodeTypeSlice) {
+		return nil, errors.New("stack resolver needs an array 'resolvers' param")
+	} else if err != nil {
+		return nil, err
+	} else if length == 0 {
+		return nil, errors.New("stack resolver needs at least one resolver")
+	}
+
+	var resolvers []Resolver
+	for i := uint64(0); i < length; i++ {
+		var childState map[string]interface{}
+		if s, is := internalState[strconv.FormatUint(i, 10)].(map[string]interface{}); is {
+			childState = s
+		} else {
+			childState = make(map[string]interface{})
+		}
+
+		childConfig := config.NodeAt(stackResolversKeypath.PushIndex(i), nil)
+		resolver, err := initResolverFromConfig(childConfig, childState)
+		if err != nil {
+			(&stackResolver{resolvers: resolvers}).Close()
+			return nil, errors.Wrapf(err, "stack resolver: resolver %v", i)
+		}
+		resolvers = append(resolvers, resolver)
+	}
+	return &stackResolver{resolvers: resolvers}, nil
+}
+
+// Close closes any children that hold resources.
+func (r *stackResolver) Close() error {
+	var errs error
+	for _, resolver := range r.resolvers {
+		if closer, is := resolver.(io.Closer); is {
+			errs = multierr.Append(errs, closer.Close())
+		}
+	}
+	return errs
+}
+
+func (r *stackResolver) InternalState() map[string]interface{} {
+	internalState := make(map[string]interface{}, len(r.resolvers))
+	for i, resolver := range r.resolvers {
+		internalState[strconv.Itoa(i)] = resolver.InternalState()
+	}
+	return internalState
+}
+
+func (r *stackResolver) ResolveState(state tree.Node, refStore RefStore, sender types.Address, txID types.ID, parents []types.ID, patches []Patch) error {
+	for i, resolver := range r.resolvers {
+		last := i == len(r.resolvers)-1
+
+		var before interface{}
+		if !last {
+			val, _, err := state.Value(nil, nil)
+			if err != nil {
+				return errors.Wrapf(err, "stack resolver: resolver %v", i)
+			}
+			before = DeepCopyJSValue(val)
+		}
+
+		err := resolver.ResolveState(state, refStore, sender, txID, parents, patches)
+		if err != nil {
+			return errors.Wrapf(err, "stack resolver: resolver %v", i)
+		}
+
+		if !last {
+			after, _, err := state.Value(nil, nil)
+			if err != nil {
+				return errors.Wrapf(err, "stack resolver: resolver %v", i)
+			}
+			patches = diffStackResolverState(before, DeepCopyJSValue(after))
+		}
+	}
+	return nil
+}
+
+func diffStackResolverState(before, after interface{}) []Patch {
+	beforeMap, beforeIsMap := before.(map[string]interface{})
+	afterMap, afterIsMap := after.(map[string]interface{})
+	if !beforeIsMap || !afterIsMap {
+		if jsonEqual(before, after) {
+			return nil
+		}
+		return []Patch{{Keypath: nil, Val: after}}
+	}
+
+	var patches []Patch
+	for key, val := range afterMap {
+		if prev, exists := beforeMap[key]; !exists || !jsonEqual(prev, val) {
+			patches = append(patches, Patch{Keypath: tree.Keypath(key), Val: val})
+		}
+	}
+	for key := range beforeMap {
+		if _, exists := afterMap[key]; !exists {
+			patches = append(patches, Patch{Op: PatchOpDelete, Keypath: tree.Keypath(key)})
+		}
+	}
+	sort.Slice(patches, func(i, j int) bool { return bytes.Compare(patches[i].Keypath, patches[j].Keypath) < 0 })
+	return patches
+}
+
+func jsonEqual(a, b interface{}) bool {
+	aBytes, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	bBytes, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(aBytes, bBytes)
+}
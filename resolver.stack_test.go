@@ -0,0 +1,146 @@
+package redwood_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"redwood.dev"
+	"redwood.dev/nelson"
+	"redwood.dev/testutils"
+	"redwood.dev/tree"
+	"redwood.dev/types"
+)
+
+func mustResolveConfig(t *testing.T, cfg M) tree.Node {
+	t.Helper()
+
+	node := tree.NewMemoryNode()
+	err := node.Set(nil, nil, cfg)
+	require.NoError(t, err)
+
+	config, anyMissing, err := nelson.Resolve(node, nil)
+	require.NoError(t, err)
+	require.False(t, anyMissing)
+	return config
+}
+
+func TestStackResolver(t *testing.T) {
+	// The JS resolver only sees the text after sync9 has merged the splice
+	src := `
+		global.init = function(internalState) {}
+		global.resolve_state = function(state, sender, txID, parents, patches) {
+			state.upper = state.text.toUpperCase()
+			state.count = (state.count || 0) + 1
+			return JSON.stringify({ state: state, internalState: {} })
+		}
+	`
+
+	config := mustResolveConfig(t, M{
+		"Content-Type": "resolver/stack",
+		"value": M{
+			"resolvers": S{
+				M{"Content-Type": "resolver/sync9"},
+				M{"Content-Type": "resolver/js", "value": M{"src": src}},
+			},
+		},
+	})
+
+	resolver, err := redwood.NewStackResolver(config, nil)
+	require.NoError(t, err)
+
+	var (
+		tx1 = types.IDFromString("tx1")
+		tx2 = types.IDFromString("tx2")
+	)
+
+	state := tree.NewMemoryNode()
+	err = resolver.ResolveState(state, nil, testutils.RandomAddress(t), tx1, []types.ID{redwood.GenesisTxID}, mustParsePatches(t, `.text = "hello"`))
+	require.NoError(t, err)
+	err = resolver.ResolveState(state, nil, testutils.RandomAddress(t), tx2, []types.ID{tx1}, mustParsePatches(t, `.text[5:5] = " world"`))
+	require.NoError(t, err)
+
+	val, exists, err := state.Value(nil, nil)
+	require.NoError(t, err)
+	require.True(t, exists)
+	require.Equal(t, M{"text": "hello world", "upper": "HELLO WORLD", "count": 2.0}, val)
+
+	internalState := resolver.InternalState()
+	require.Contains(t, internalState, "0")
+	require.Contains(t, internalState, "1")
+
+	// The children's internal state is handed back to them by index
+	reloaded, err := redwood.NewStackResolver(config, internalState)
+	require.NoError(t, err)
+	require.Equal(t, internalState["0"], reloaded.InternalState()["0"])
+}
+
+func TestStackResolver_LaterResolversReceiveTheChangesOfTheOneBeforeThem(t *testing.T) {
+	// If the dumb resolver were handed the tx's own patches, it would splice
+	// " world" in a second time and increment .count twice
+	config := mustResolveConfig(t, M{
+		"Content-Type": "resolver/stack",
+		"value": M{
+			"resolvers": S{
+				M{"Content-Type": "resolver/sync9"},
+				M{"Content-Type": "resolver/dumb"},
+			},
+		},
+	})
+
+	resolver, err := redwood.NewStackResolver(config, nil)
+	require.NoError(t, err)
+
+	var (
+		tx1 = types.IDFromString("tx1")
+		tx2 = types.IDFromString("tx2")
+		tx3 = types.IDFromString("tx3")
+	)
+
+	state := tree.NewMemoryNode()
+	err = resolver.ResolveState(state, nil, testutils.RandomAddress(t), tx1, []types.ID{redwood.GenesisTxID}, mustParsePatches(t,
+		`.text = "hello"`,
+		`.count = 1`,
+		`.tmp = true`,
+	))
+	require.NoError(t, err)
+	err = resolver.ResolveState(state, nil, testutils.RandomAddress(t), tx2, []types.ID{tx1}, mustParsePatches(t, `.text[5:5] = " world"`))
+	require.NoError(t, err)
+
+	val, _, err := state.Value(nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, M{"text": "hello world", "count": 1.0, "tmp": true}, val)
+
+	config = mustResolveConfig(t, M{
+		"Content-Type": "resolver/stack",
+		"value": M{
+			"resolvers": S{
+				M{"Content-Type": "resolver/dumb"},
+				M{"Content-Type": "resolver/dumb"},
+			},
+		},
+	})
+	resolver, err = redwood.NewStackResolver(config, nil)
+	require.NoError(t, err)
+
+	err = resolver.ResolveState(state, nil, testutils.RandomAddress(t), tx3, []types.ID{tx2}, mustParsePatches(t,
+		`.count += 1`,
+		`delete .tmp`,
+	))
+	require.NoError(t, err)
+
+	val, _, err = state.Value(nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, M{"text": "hello world", "count": 2.0}, val)
+}
+
+func TestStackResolver_BadConfig(t *testing.T) {
+	_, err := redwood.NewStackResolver(mustResolveConfig(t, M{"Content-Type": "resolver/stack", "value": M{}}), nil)
+	require.Error(t, err)
+
+	_, err = redwood.NewStackResolver(mustResolveConfig(t, M{
+		"Content-Type": "resolver/stack",
+		"value":        M{"resolvers": S{M{"Content-Type": "resolver/nonexistent"}}},
+	}), nil)
+	require.Error(t, err)
+}
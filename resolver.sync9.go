@@ -0,0 +1,921 @@
+package redwood
+
+import (
+	"sort"
+	"strconv"
+	"unicode/utf16"
+
+	"github.com/pkg/errors"
+
+	"redwood.dev/ctx"
+	"redwood.dev/tree"
+	"redwood.dev/types"
+	"redwood.dev/utils"
+)
+
+// sync9Resolver is a native port of the Sync9 CRDT found in redwood.js/sync9-src.js.
+// Every tx is recorded as a version in a DAG, and every string, slice, and value slot
+// in the state is represented as a "space DAG" whose nodes are tagged with the version
+// that inserted them and the versions that deleted them.  Because concurrent inserts
+// at the same position are ordered by version ID, peers that receive the same set of
+// txs in different orders converge on the same state.
+type sync9Resolver struct {
+	ctx.Logger
+	versions map[string]map[string]bool
+	leaves   map[string]bool
+	root     *sync9Val
+}
+
+// Ensure sync9Resolver conforms to the Resolver interface
+var _ Resolver = (*sync9Resolver)(nil)
+
+type sync9ValType string
+
+const (
+	sync9TypeLit sync9ValType = "lit"
+	sync9TypeVal sync9ValType = "val"
+	sync9TypeObj sync9ValType = "obj"
+	sync9TypeArr sync9ValType = "arr"
+	sync9TypeStr sync9ValType = "str"
+)
+
+// sync9Val is a node in the Sync9 value tree.  A nil *sync9Val represents an
+// absent value (for instance, a deleted map key).
+type sync9Val struct {
+	t   sync9ValType
+	lit interface{}
+	obj map[string]*sync9Val
+	S   *sync9Node
+}
+
+// sync9Node is a node in a space DAG.  Its elems are either a string (for "str"
+// values) or a slice of *sync9Val (for "arr" and "val" values).  Strings are
+// held as UTF-16 code units, because that's what the offsets in redwood.js's
+// Sync9 patches count.
+type sync9Node struct {
+	vid       string
+	elems     sync9Elems
+	deletedBy map[string]bool
+	endCap    bool
+	gash      bool
+	nexts     []*sync9Node
+	next      *sync9Node
+}
+
+type sync9Elems struct {
+	isString bool
+	str      []uint16
+	vals     []*sync9Val
+}
+
+type sync9Splice struct {
+	offset int
+	del    int
+	ins    sync9Elems
+}
+
+func NewSync9Resolver(config tree.Node, internalState map[string]interface{}) (_ Resolver, err error) {
+	defer utils.Annotate(&err, "NewSync9Resolver")
+
+	r := &sync9Resolver{
+		Logger:   ctx.NewLogger("resolver:sync9"),
+		versions: make(map[string]map[string]bool),
+		leaves:   make(map[string]bool),
+	}
+	if len(internalState) > 0 {
+		err = r.decodeInternalState(internalState)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func (r *sync9Resolver) InternalState() map[string]interface{} {
+	versions := make(map[string]interface{}, len(r.versions))
+	for vid, parents := range r.versions {
+		versions[vid] = sortedSync9Keys(parents)
+	}
+	return map[string]interface{}{
+		"T":      versions,
+		"leaves": sortedSync9Keys(r.leaves),
+		"val":    encodeSync9Val(r.root),
+	}
+}
+
+func (r *sync9Resolver) ResolveState(state tree.Node, refStore RefStore, sender types.Address, txID types.ID, parents []types.ID, patches []Patch) (err error) {
+	defer utils.Annotate(&err, "sync9Resolver.ResolveState")
+
+	// The first time we see a tx, whatever is already in the tree becomes the
+	// base (unversioned) value that all subsequent versions build upon.
+	if len(r.versions) == 0 && r.root == nil {
+		val, exists, err := state.Value(nil, nil)
+		if err != nil {
+			return err
+		} else if exists {
+			r.root = &sync9Val{t: sync9TypeLit, lit: DeepCopyJSValue(val)}
+		}
+	}
+
+	parentVids := make(map[string]bool, len(parents))
+	for _, parent := range parents {
+		parentVids[parent.Hex()] = true
+	}
+
+	err = r.addVersion(txID.Hex(), parentVids, patches)
+	if err != nil {
+		return err
+	}
+
+	// Only the top-level keys touched by this tx can have changed.  Array indices in
+	// a patch are relative to the tx's parents, so we can't be any more precise.
+	var touched []string
+	seen := make(map[string]bool)
+	for _, patch := range patches {
+		if len(patch.Keypath) == 0 {
+			touched = nil
+			break
+		}
+		key := string(patch.Keypath.Part(0))
+		if !seen[key] {
+			seen[key] = true
+			touched = append(touched, key)
+		}
+	}
+
+	if len(touched) == 0 {
+		val, _ := r.read(r.root)
+		return state.Set(nil, nil, val)
+	}
+
+	for _, key := range touched {
+		val, exists := r.readKey(key)
+		if exists {
+			err = state.Set(tree.Keypath(key), nil, val)
+		} else {
+			err = state.Delete(tree.Keypath(key), nil)
+			if errors.Cause(err) == types.Err404 {
+				err = nil
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *sync9Resolver) addVersion(vid string, parents map[string]bool, patches []Patch) error {
+	if _, exists := r.versions[vid]; exists {
+		return nil
+	}
+
+	for _, patch := range patches {
+		if patch.Op != PatchOpSet {
+			return errors.Errorf("sync9 resolver doesn't support '%v' patches", patch.Op)
+		}
+	}
+
+	ancestors := r.ancestors(parents)
+	isAnc := func(v string) bool { return ancestors[v] }
+
+	// The patches are applied to a copy of the value tree, and the version is
+	// only recorded once all of them succeed, so a tx that fails partway
+	// through leaves no trace and can be retried
+	root := r.root
+	r.root = cloneSync9Val(root)
+	for _, patch := range patches {
+		err := r.applyPatch(vid, patch, isAnc)
+		if err != nil {
+			r.root = root
+			return err
+		}
+	}
+
+	r.versions[vid] = make(map[string]bool, len(parents))
+	for parent := range parents {
+		r.versions[vid][parent] = true
+		delete(r.leaves, parent)
+	}
+	r.leaves[vid] = true
+	return nil
+}
+
+func (r *sync9Resolver) applyPatch(vid string, patch Patch, isAnc func(string) bool) error {
+	var rng *tree.Range
+	if patch.Range != nil {
+		rng = &tree.Range{Start: patch.Range.Start, End: patch.Range.End}
+	}
+	val := DeepCopyJSValue(patch.Val)
+
+	if r.root == nil || r.root.t == sync9TypeLit {
+		r.root = &sync9Val{t: sync9TypeVal, S: newSync9Node("", sync9Elems{vals: []*sync9Val{r.root}}, false)}
+	}
+	cur := r.root
+
+	var prevS *sync9Node
+	var prevI int
+
+	keys := patch.Keypath.Parts()
+	for i, key := range keys {
+		if cur.t == sync9TypeVal {
+			prevS, prevI = cur.S, 0
+			cur = sync9SpaceDAGGet(cur.S, 0, isAnc)
+
+			// Descending into a value that doesn't exist yet creates a map, just as it
+			// would with the dumb resolver.
+			if cur == nil {
+				cur = &sync9Val{t: sync9TypeLit, lit: map[string]interface{}{}}
+			}
+		}
+
+		if cur.t == sync9TypeLit {
+			switch lit := cur.lit.(type) {
+			case []interface{}:
+				cur = &sync9Val{t: sync9TypeArr, S: newSync9Node("", sync9LitElems(lit), false)}
+			case map[string]interface{}:
+				obj := make(map[string]*sync9Val, len(lit))
+				for k, v := range lit {
+					obj[k] = &sync9Val{t: sync9TypeLit, lit: v}
+				}
+				cur = &sync9Val{t: sync9TypeObj, obj: obj}
+			default:
+				return errors.Errorf("cannot descend into a %T at keypath %v", lit, patch.Keypath)
+			}
+			sync9SpaceDAGSet(prevS, prevI, cur, isAnc)
+		}
+
+		switch cur.t {
+		case sync9TypeObj:
+			child := cur.obj[string(key)]
+			if child == nil || child.t == sync9TypeLit {
+				child = &sync9Val{t: sync9TypeVal, S: newSync9Node("", sync9Elems{vals: []*sync9Val{child}}, false)}
+				cur.obj[string(key)] = child
+			}
+			cur = child
+
+		case sync9TypeArr, sync9TypeStr:
+			idx, err := strconv.ParseInt(string(key), 10, 64)
+			if err != nil {
+				return errors.Errorf("bad index '%v' at keypath %v", string(key), patch.Keypath)
+			}
+
+			if i == len(keys)-1 && rng == nil {
+				rng = &tree.Range{Start: idx, End: idx + 1}
+				if cur.t == sync9TypeArr {
+					if val != nil {
+						val = []interface{}{val}
+					} else {
+						val = []interface{}{}
+					}
+				}
+			} else if cur.t == sync9TypeArr {
+				prevS, prevI = cur.S, int(idx)
+				cur = sync9SpaceDAGGet(cur.S, int(idx), isAnc)
+				if cur == nil {
+					return errors.Errorf("index %v out of range at keypath %v", idx, patch.Keypath)
+				}
+			} else {
+				return errors.Errorf("cannot descend into a string at keypath %v", patch.Keypath)
+			}
+
+		default:
+			return errors.Errorf("bad sync9 value type '%v' at keypath %v", cur.t, patch.Keypath)
+		}
+	}
+
+	if rng == nil {
+		if cur.t != sync9TypeVal {
+			return errors.Errorf("cannot set keypath %v", patch.Keypath)
+		}
+		var newVal *sync9Val
+		if val != nil {
+			newVal = &sync9Val{t: sync9TypeLit, lit: val}
+		}
+		length := sync9SpaceDAGLength(cur.S, isAnc)
+		return sync9SpaceDAGAddVersion(cur.S, vid, []sync9Splice{{0, length, sync9Elems{vals: []*sync9Val{newVal}}}}, isAnc)
+	}
+
+	if cur.t == sync9TypeVal {
+		prevS, prevI = cur.S, 0
+		cur = sync9SpaceDAGGet(cur.S, 0, isAnc)
+		if cur == nil {
+			return errors.Errorf("cannot splice a nonexistent value at keypath %v", patch.Keypath)
+		}
+		if cur.t == sync9TypeLit {
+			switch lit := cur.lit.(type) {
+			case string:
+				cur = &sync9Val{t: sync9TypeStr, S: newSync9Node("", sync9StringElems(lit), false)}
+			case []interface{}:
+				cur = &sync9Val{t: sync9TypeArr, S: newSync9Node("", sync9LitElems(lit), false)}
+			default:
+				return errors.Wrapf(tree.ErrRangeOverNonSlice, "keypath %v", patch.Keypath)
+			}
+			sync9SpaceDAGSet(prevS, prevI, cur, isAnc)
+		}
+	}
+
+	var ins sync9Elems
+	switch cur.t {
+	case sync9TypeStr:
+		switch v := val.(type) {
+		case string:
+			ins = sync9StringElems(v)
+		case nil:
+			ins = sync9Elems{isString: true}
+		default:
+			return errors.Errorf("cannot splice a %T into a string at keypath %v", val, patch.Keypath)
+		}
+	case sync9TypeArr:
+		switch v := val.(type) {
+		case []interface{}:
+			ins = sync9LitElems(v)
+		case nil:
+			ins = sync9Elems{vals: []*sync9Val{}}
+		default:
+			return errors.Errorf("cannot splice a %T into a slice at keypath %v", val, patch.Keypath)
+		}
+	default:
+		return errors.Wrapf(tree.ErrRangeOverNonSlice, "keypath %v", patch.Keypath)
+	}
+
+	if rng.Start < 0 || rng.End < rng.Start || int(rng.End) > sync9SpaceDAGLength(cur.S, isAnc) {
+		return errors.Wrapf(tree.ErrInvalidRange, "keypath %v range %v", patch.Keypath, *rng)
+	} else if cur.t == sync9TypeStr && (sync9SpaceDAGSplitsSurrogates(cur.S, int(rng.Start), isAnc) || sync9SpaceDAGSplitsSurrogates(cur.S, int(rng.End), isAnc)) {
+		return errors.Wrapf(tree.ErrInvalidRange, "keypath %v range %v splits a UTF-16 surrogate pair", patch.Keypath, *rng)
+	}
+	return sync9SpaceDAGAddVersion(cur.S, vid, []sync9Splice{{int(rng.Start), int(rng.End - rng.Start), ins}}, isAnc)
+}
+
+func (r *sync9Resolver) ancestors(vids map[string]bool) map[string]bool {
+	ancs := make(map[string]bool)
+	var mark func(vid string)
+	mark = func(vid string) {
+		if ancs[vid] {
+			return
+		}
+		ancs[vid] = true
+		for parent := range r.versions[vid] {
+			mark(parent)
+		}
+	}
+	for vid := range vids {
+		mark(vid)
+	}
+	return ancs
+}
+
+func (r *sync9Resolver) readKey(key string) (interface{}, bool) {
+	root := r.root
+	if root != nil && root.t == sync9TypeVal {
+		root = sync9SpaceDAGGet(root.S, 0, sync9All)
+	}
+	if root == nil {
+		return nil, false
+	}
+	switch root.t {
+	case sync9TypeObj:
+		return r.read(root.obj[key])
+	case sync9TypeLit:
+		if m, is := root.lit.(map[string]interface{}); is {
+			val, exists := m[key]
+			return DeepCopyJSValue(val), exists
+		}
+	}
+	return nil, false
+}
+
+func (r *sync9Resolver) read(x *sync9Val) (interface{}, bool) {
+	if x == nil {
+		return nil, false
+	}
+	switch x.t {
+	case sync9TypeLit:
+		return DeepCopyJSValue(x.lit), true
+
+	case sync9TypeVal:
+		return r.read(sync9SpaceDAGGet(x.S, 0, sync9All))
+
+	case sync9TypeObj:
+		m := make(map[string]interface{}, len(x.obj))
+		for k, v := range x.obj {
+			if val, exists := r.read(v); exists {
+				m[k] = val
+			}
+		}
+		return m, true
+
+	case sync9TypeArr:
+		s := []interface{}{}
+		sync9TravSpaceDAG(x.S, sync9All, false, func(node *sync9Node, offset int, hasNexts bool, prev *sync9Node, vid string) bool {
+			for _, elem := range node.elems.vals {
+				val, _ := r.read(elem)
+				s = append(s, val)
+			}
+			return true
+		})
+		return s, true
+
+	case sync9TypeStr:
+		var s []uint16
+		sync9TravSpaceDAG(x.S, sync9All, false, func(node *sync9Node, offset int, hasNexts bool, prev *sync9Node, vid string) bool {
+			s = append(s, node.elems.str...)
+			return true
+		})
+		return string(utf16.Decode(s)), true
+	}
+	return nil, false
+}
+
+func sync9All(vid string) bool { return true }
+
+func sync9LitElems(lits []interface{}) sync9Elems {
+	vals := make([]*sync9Val, len(lits))
+	for i, lit := range lits {
+		vals[i] = &sync9Val{t: sync9TypeLit, lit: lit}
+	}
+	return sync9Elems{vals: vals}
+}
+
+func sync9StringElems(s string) sync9Elems {
+	return sync9Elems{isString: true, str: utf16.Encode([]rune(s))}
+}
+
+func (e sync9Elems) len() int {
+	if e.isString {
+		return len(e.str)
+	}
+	return len(e.vals)
+}
+
+func (e sync9Elems) slice(start, end int) sync9Elems {
+	if e.isString {
+		return sync9Elems{isString: true, str: e.str[start:end]}
+	}
+	vals := make([]*sync9Val, end-start)
+	copy(vals, e.vals[start:end])
+	return sync9Elems{vals: vals}
+}
+
+// cloneSync9Val deeply copies a value tree.  Literals and string elems are
+// never modified in place, so they're shared with the original.
+func cloneSync9Val(x *sync9Val) *sync9Val {
+	if x == nil {
+		return nil
+	}
+	clone := &sync9Val{t: x.t, lit: x.lit, S: cloneSync9Node(x.S)}
+	if x.obj != nil {
+		clone.obj = make(map[string]*sync9Val, len(x.obj))
+		for k, v := range x.obj {
+			clone.obj[k] = cloneSync9Val(v)
+		}
+	}
+	return clone
+}
+
+func cloneSync9Node(node *sync9Node) *sync9Node {
+	if node == nil {
+		return nil
+	}
+	elems := node.elems
+	if !elems.isString {
+		elems.vals = make([]*sync9Val, len(node.elems.vals))
+		for i, val := range node.elems.vals {
+			elems.vals[i] = cloneSync9Val(val)
+		}
+	}
+	clone := newSync9Node(node.vid, elems, node.endCap)
+	clone.gash = node.gash
+	for vid := range node.deletedBy {
+		clone.deletedBy[vid] = true
+	}
+	for _, next := range node.nexts {
+		clone.nexts = append(clone.nexts, cloneSync9Node(next))
+	}
+	clone.next = cloneSync9Node(node.next)
+	return clone
+}
+
+func newSync9Node(vid string, elems sync9Elems, endCap bool) *sync9Node {
+	return &sync9Node{
+		vid:       vid,
+		elems:     elems,
+		deletedBy: make(map[string]bool),
+		endCap:    endCap,
+	}
+}
+
+func (n *sync9Node) isDeleted(isAnc func(string) bool) bool {
+	for vid := range n.deletedBy {
+		if isAnc(vid) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *sync9Node) hasNexts(isAnc func(string) bool) bool {
+	for _, next := range n.nexts {
+		if isAnc(next.vid) {
+			return true
+		}
+	}
+	return false
+}
+
+// sync9TravSpaceDAG visits the nodes of a space DAG that are visible to the version
+// described by isAnc, in document order.  Traversal stops when cb returns false.
+func sync9TravSpaceDAG(S *sync9Node, isAnc func(string) bool, viewDeleted bool, cb func(node *sync9Node, offset int, hasNexts bool, prev *sync9Node, vid string) bool) {
+	offset := 0
+	var helper func(node, prev *sync9Node, vid string) bool
+	helper = func(node, prev *sync9Node, vid string) bool {
+		hasNexts := node.hasNexts(isAnc)
+		if viewDeleted || !node.isDeleted(isAnc) {
+			if !cb(node, offset, hasNexts, prev, vid) {
+				return false
+			}
+			offset += node.elems.len()
+		}
+		for _, next := range node.nexts {
+			if isAnc(next.vid) {
+				if !helper(next, nil, next.vid) {
+					return false
+				}
+			}
+		}
+		if node.next != nil {
+			return helper(node.next, node, vid)
+		}
+		return true
+	}
+	helper(S, nil, S.vid)
+}
+
+func sync9SpaceDAGGet(S *sync9Node, i int, isAnc func(string) bool) *sync9Val {
+	var ret *sync9Val
+	sync9TravSpaceDAG(S, isAnc, false, func(node *sync9Node, offset int, hasNexts bool, prev *sync9Node, vid string) bool {
+		if i-offset < node.elems.len() {
+			ret = node.elems.vals[i-offset]
+			return false
+		}
+		return true
+	})
+	return ret
+}
+
+func sync9SpaceDAGSet(S *sync9Node, i int, v *sync9Val, isAnc func(string) bool) {
+	sync9TravSpaceDAG(S, isAnc, false, func(node *sync9Node, offset int, hasNexts bool, prev *sync9Node, vid string) bool {
+		if i-offset < node.elems.len() {
+			node.elems.vals[i-offset] = v
+			return false
+		}
+		return true
+	})
+}
+
+// sync9SpaceDAGSplitsSurrogates returns true if offset i of a string's space
+// DAG falls between the two halves of a UTF-16 surrogate pair.
+func sync9SpaceDAGSplitsSurrogates(S *sync9Node, i int, isAnc func(string) bool) bool {
+	var splits bool
+	sync9TravSpaceDAG(S, isAnc, false, func(node *sync9Node, offset int, hasNexts bool, prev *sync9Node, vid string) bool {
+		if i-offset < node.elems.len() {
+			unit := node.elems.str[i-offset]
+			splits = unit >= 0xdc00 && unit <= 0xdfff
+			return false
+		}
+		return true
+	})
+	return splits
+}
+
+func sync9SpaceDAGLength(S *sync9Node, isAnc func(string) bool) int {
+	var count int
+	sync9TravSpaceDAG(S, isAnc, false, func(node *sync9Node, offset int, hasNexts bool, prev *sync9Node, vid string) bool {
+		count += node.elems.len()
+		return true
+	})
+	return count
+}
+
+func sync9SpaceDAGBreakNode(node *sync9Node, x int, endCap bool, newNext *sync9Node) *sync9Node {
+	tail := newSync9Node("", node.elems.slice(x, node.elems.len()), node.endCap)
+	for vid := range node.deletedBy {
+		tail.deletedBy[vid] = true
+	}
+	tail.nexts = node.nexts
+	tail.next = node.next
+
+	node.elems = node.elems.slice(0, x)
+	node.endCap = endCap
+	if endCap {
+		tail.gash = true
+	}
+	if newNext != nil {
+		node.nexts = []*sync9Node{newNext}
+	} else {
+		node.nexts = nil
+	}
+	node.next = tail
+
+	return tail
+}
+
+// sync9AddToNexts inserts a node into a list of concurrent inserts, keeping the list
+// sorted by version ID so that every peer orders concurrent inserts identically.
+func sync9AddToNexts(nexts []*sync9Node, to *sync9Node) []*sync9Node {
+	i := sort.Search(len(nexts), func(i int) bool { return nexts[i].vid >= to.vid })
+	nexts = append(nexts, nil)
+	copy(nexts[i+1:], nexts[i:])
+	nexts[i] = to
+	return nexts
+}
+
+func sync9SpaceDAGAddVersion(S *sync9Node, vid string, splices []sync9Splice, isAnc func(string) bool) (err error) {
+	var si, deleteUpTo int
+
+	cb := func(node *sync9Node, offset int, hasNexts bool, prev *sync9Node, deleted bool) bool {
+		if si >= len(splices) {
+			return false
+		}
+		s := splices[si]
+		// Mirrors the JS implementation, where an empty string is falsy but an empty
+		// array is not
+		hasIns := !s.ins.isString || len(s.ins.str) > 0
+
+		if deleted {
+			if s.del == 0 && s.offset == offset {
+				if node.elems.len() == 0 && !node.endCap && hasNexts {
+					return true
+				}
+				newNode := newSync9Node(vid, s.ins, false)
+				if node.elems.len() == 0 && !node.endCap {
+					node.nexts = sync9AddToNexts(node.nexts, newNode)
+				} else {
+					sync9SpaceDAGBreakNode(node, 0, false, newNode)
+				}
+				si++
+			}
+			return true
+		}
+
+		if s.del == 0 {
+			d := s.offset - (offset + node.elems.len())
+			if d > 0 {
+				return true
+			}
+			if d == 0 && !node.endCap && hasNexts {
+				return true
+			}
+			newNode := newSync9Node(vid, s.ins, false)
+			if d == 0 && !node.endCap {
+				node.nexts = sync9AddToNexts(node.nexts, newNode)
+			} else {
+				sync9SpaceDAGBreakNode(node, s.offset-offset, false, newNode)
+			}
+			si++
+			return true
+		}
+
+		if deleteUpTo <= offset {
+			d := s.offset - (offset + node.elems.len())
+			if d >= 0 {
+				return true
+			}
+			deleteUpTo = s.offset + s.del
+
+			if hasIns {
+				newNode := newSync9Node(vid, s.ins, false)
+				if s.offset == offset && node.gash {
+					if prev == nil || !prev.endCap {
+						err = errors.New("sync9: gash without end cap")
+						return false
+					}
+					prev.nexts = sync9AddToNexts(prev.nexts, newNode)
+				} else {
+					sync9SpaceDAGBreakNode(node, s.offset-offset, true, newNode)
+					return true
+				}
+			} else if s.offset != offset {
+				sync9SpaceDAGBreakNode(node, s.offset-offset, false, nil)
+				return true
+			}
+		}
+
+		if deleteUpTo > offset {
+			if deleteUpTo <= offset+node.elems.len() {
+				if deleteUpTo < offset+node.elems.len() {
+					sync9SpaceDAGBreakNode(node, deleteUpTo-offset, false, nil)
+				}
+				si++
+			}
+			node.deletedBy[vid] = true
+		}
+		return true
+	}
+
+	offset := 0
+	var helper func(node, prev *sync9Node) bool
+	helper = func(node, prev *sync9Node) bool {
+		hasNexts := node.hasNexts(isAnc)
+		deleted := node.isDeleted(isAnc)
+		if !cb(node, offset, hasNexts, prev, deleted) {
+			return false
+		}
+		if !deleted {
+			offset += node.elems.len()
+		}
+		for _, next := range node.nexts {
+			if isAnc(next.vid) {
+				if !helper(next, nil) {
+					return false
+				}
+			}
+		}
+		if node.next != nil {
+			return helper(node.next, node)
+		}
+		return true
+	}
+	helper(S, nil)
+	return err
+}
+
+func sortedSync9Keys(m map[string]bool) []interface{} {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]interface{}, len(keys))
+	for i := range keys {
+		out[i] = keys[i]
+	}
+	return out
+}
+
+func encodeSync9Val(x *sync9Val) interface{} {
+	if x == nil {
+		return nil
+	}
+	switch x.t {
+	case sync9TypeLit:
+		return map[string]interface{}{"t": string(x.t), "S": x.lit}
+	case sync9TypeObj:
+		obj := make(map[string]interface{}, len(x.obj))
+		for k, v := range x.obj {
+			obj[k] = encodeSync9Val(v)
+		}
+		return map[string]interface{}{"t": string(x.t), "S": obj}
+	default:
+		return map[string]interface{}{"t": string(x.t), "S": encodeSync9Node(x.S)}
+	}
+}
+
+func encodeSync9Node(node *sync9Node) interface{} {
+	if node == nil {
+		return nil
+	}
+	var elems interface{}
+	if node.elems.isString {
+		elems = string(utf16.Decode(node.elems.str))
+	} else {
+		vals := make([]interface{}, len(node.elems.vals))
+		for i, val := range node.elems.vals {
+			vals[i] = encodeSync9Val(val)
+		}
+		elems = vals
+	}
+	nexts := make([]interface{}, len(node.nexts))
+	for i, next := range node.nexts {
+		nexts[i] = encodeSync9Node(next)
+	}
+	return map[string]interface{}{
+		"vid":        node.vid,
+		"elems":      elems,
+		"deleted_by": sortedSync9Keys(node.deletedBy),
+		"end_cap":    node.endCap,
+		"gash":       node.gash,
+		"nexts":      nexts,
+		"next":       encodeSync9Node(node.next),
+	}
+}
+
+func (r *sync9Resolver) decodeInternalState(internalState map[string]interface{}) error {
+	if versions, is := internalState["T"].(map[string]interface{}); is {
+		for vid, parents := range versions {
+			parentSlice, _ := parents.([]interface{})
+			r.versions[vid] = make(map[string]bool, len(parentSlice))
+			for _, parent := range parentSlice {
+				if p, is := parent.(string); is {
+					r.versions[vid][p] = true
+				}
+			}
+		}
+	}
+	if leaves, is := internalState["leaves"].([]interface{}); is {
+		for _, leaf := range leaves {
+			if l, is := leaf.(string); is {
+				r.leaves[l] = true
+			}
+		}
+	}
+	root, err := decodeSync9Val(internalState["val"])
+	if err != nil {
+		return err
+	}
+	r.root = root
+	return nil
+}
+
+func decodeSync9Val(x interface{}) (*sync9Val, error) {
+	if x == nil {
+		return nil, nil
+	}
+	m, is := x.(map[string]interface{})
+	if !is {
+		return nil, errors.Errorf("bad sync9 internal state: expected value object, got %T", x)
+	}
+	t, _ := m["t"].(string)
+	switch sync9ValType(t) {
+	case sync9TypeLit:
+		return &sync9Val{t: sync9TypeLit, lit: m["S"]}, nil
+	case sync9TypeObj:
+		encoded, _ := m["S"].(map[string]interface{})
+		obj := make(map[string]*sync9Val, len(encoded))
+		for k, v := range encoded {
+			val, err := decodeSync9Val(v)
+			if err != nil {
+				return nil, err
+			}
+			obj[k] = val
+		}
+		return &sync9Val{t: sync9TypeObj, obj: obj}, nil
+	case sync9TypeVal, sync9TypeArr, sync9TypeStr:
+		node, err := decodeSync9Node(m["S"])
+		if err != nil {
+			return nil, err
+		} else if node == nil {
+			return nil, errors.Errorf("bad sync9 internal state: missing space DAG for '%v' value", t)
+		}
+		return &sync9Val{t: sync9ValType(t), S: node}, nil
+	default:
+		return nil, errors.Errorf("bad sync9 internal state: unknown value type '%v'", t)
+	}
+}
+
+func decodeSync9Node(x interface{}) (*sync9Node, error) {
+	if x == nil {
+		return nil, nil
+	}
+	m, is := x.(map[string]interface{})
+	if !is {
+		return nil, errors.Errorf("bad sync9 internal state: expected space DAG node, got %T", x)
+	}
+
+	var elems sync9Elems
+	switch e := m["elems"].(type) {
+	case string:
+		elems = sync9StringElems(e)
+	case []interface{}:
+		elems.vals = make([]*sync9Val, len(e))
+		for i := range e {
+			val, err := decodeSync9Val(e[i])
+			if err != nil {
+				return nil, err
+			}
+			elems.vals[i] = val
+		}
+	case nil:
+		elems.vals = []*sync9Val{}
+	default:
+		return nil, errors.Errorf("bad sync9 internal state: bad elems (%T)", e)
+	}
+
+	vid, _ := m["vid"].(string)
+	endCap, _ := m["end_cap"].(bool)
+	node := newSync9Node(vid, elems, endCap)
+	node.gash, _ = m["gash"].(bool)
+
+	deletedBy, _ := m["deleted_by"].([]interface{})
+	for _, d := range deletedBy {
+		if dvid, is := d.(string); is {
+			node.deletedBy[dvid] = true
+		}
+	}
+
+	nexts, _ := m["nexts"].([]interface{})
+	for _, n := range nexts {
+		next, err := decodeSync9Node(n)
+		if err != nil {
+			return nil, err
+		}
+		node.nexts = append(node.nexts, next)
+	}
+
+	next, err := decodeSync9Node(m["next"])
+	if err != nil {
+		return nil, err
+	}
+	node.next = next
+	return node, nil
+}
@@ -0,0 +1,244 @@
+package redwood_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"redwood.dev"
+	"redwood.dev/testutils"
+	"redwood.dev/tree"
+	"redwood.dev/types"
+)
+
+type M = map[string]interface{}
+type S = []interface{}
+
+func mustParsePatches(t *testing.T, patchStrs ...string) []redwood.Patch {
+	t.Helper()
+
+	var patches []redwood.Patch
+	for _, s := range patchStrs {
+		patch, err := redwood.ParsePatch([]byte(s))
+		require.NoError(t, err)
+		patches = append(patches, patch)
+	}
+	return patches
+}
+
+func TestSync9Resolver_Converges(t *testing.T) {
+	type version struct {
+		id      types.ID
+		parents []types.ID
+		patches []redwood.Patch
+	}
+
+	var (
+		tx1 = types.IDFromString("tx1")
+		tx2 = types.IDFromString("tx2")
+		tx3 = types.IDFromString("tx3")
+		tx4 = types.IDFromString("tx4")
+	)
+
+	versions := map[types.ID]version{
+		tx1: {tx1, []types.ID{redwood.GenesisTxID}, mustParsePatches(t,
+			`.text[5:5] = " world"`,
+			`.list[3:3] = [4, 5]`,
+		)},
+		tx2: {tx2, []types.ID{tx1}, mustParsePatches(t,
+			`.text[0:0] = "A"`,
+			`.list[1:2] = []`,
+			`.title = "from tx2"`,
+		)},
+		tx3: {tx3, []types.ID{tx1}, mustParsePatches(t,
+			`.text[0:0] = "B"`,
+			`.text[6:11] = "there"`,
+			`.list[1:1] = ["x"]`,
+			`.title = "from tx3"`,
+		)},
+		tx4: {tx4, []types.ID{tx2, tx3}, mustParsePatches(t,
+			`.text[2:2] = "-"`,
+		)},
+	}
+
+	orders := [][]types.ID{
+		{tx1, tx2, tx3, tx4},
+		{tx1, tx3, tx2, tx4},
+	}
+
+	var results []interface{}
+	for _, order := range orders {
+		state := tree.NewMemoryNode()
+		err := state.Set(nil, nil, M{
+			"text": "hello",
+			"list": S{1.0, 2.0, 3.0},
+		})
+		require.NoError(t, err)
+
+		resolver, err := redwood.NewSync9Resolver(nil, nil)
+		require.NoError(t, err)
+
+		for _, txID := range order {
+			v := versions[txID]
+			err = resolver.ResolveState(state, nil, testutils.RandomAddress(t), v.id, v.parents, v.patches)
+			require.NoError(t, err)
+		}
+
+		val, exists, err := state.Value(nil, nil)
+		require.NoError(t, err)
+		require.True(t, exists)
+		results = append(results, val)
+	}
+
+	require.Equal(t, results[0], results[1])
+
+	text, _ := results[0].(M)["text"].(string)
+	require.Len(t, text, len("AB-hello there"))
+	require.Contains(t, []string{"AB-hello there", "BA-hello there"}, text)
+	require.Equal(t, S{1.0, "x", 3.0, 4.0, 5.0}, results[0].(M)["list"])
+	require.Contains(t, []string{"from tx2", "from tx3"}, results[0].(M)["title"])
+}
+
+func TestSync9Resolver_InternalState(t *testing.T) {
+	var (
+		tx1 = types.IDFromString("tx1")
+		tx2 = types.IDFromString("tx2")
+		tx3 = types.IDFromString("tx3")
+	)
+
+	state := tree.NewMemoryNode()
+	resolver, err := redwood.NewSync9Resolver(nil, nil)
+	require.NoError(t, err)
+
+	err = resolver.ResolveState(state, nil, testutils.RandomAddress(t), tx1, []types.ID{redwood.GenesisTxID}, mustParsePatches(t, `.text = "abc"`))
+	require.NoError(t, err)
+	err = resolver.ResolveState(state, nil, testutils.RandomAddress(t), tx2, []types.ID{tx1}, mustParsePatches(t, `.text[1:2] = "xyz"`))
+	require.NoError(t, err)
+
+	// Simulate the internal state being persisted and reloaded
+	bs, err := json.Marshal(resolver.InternalState())
+	require.NoError(t, err)
+	var internalState map[string]interface{}
+	err = json.Unmarshal(bs, &internalState)
+	require.NoError(t, err)
+
+	reloaded, err := redwood.NewSync9Resolver(nil, internalState)
+	require.NoError(t, err)
+
+	// tx3 is concurrent with tx2, so its range refers to the "abc" version of the string
+	err = reloaded.ResolveState(state, nil, testutils.RandomAddress(t), tx3, []types.ID{tx1}, mustParsePatches(t, `.text[3:3] = "!"`))
+	require.NoError(t, err)
+
+	text, exists, err := state.StringValue(tree.Keypath("text"))
+	require.NoError(t, err)
+	require.True(t, exists)
+	require.Equal(t, "axyzc!", text)
+}
+
+func TestSync9Resolver_RejectsUnsupportedPatchesWithoutChangingState(t *testing.T) {
+	var (
+		tx1 = types.IDFromString("tx1")
+		tx2 = types.IDFromString("tx2")
+	)
+
+	state := tree.NewMemoryNode()
+	resolver, err := redwood.NewSync9Resolver(nil, nil)
+	require.NoError(t, err)
+
+	err = resolver.ResolveState(state, nil, testutils.RandomAddress(t), tx1, []types.ID{redwood.GenesisTxID}, mustParsePatches(t, `.text = "abc"`))
+	require.NoError(t, err)
+	before, err := json.Marshal(resolver.InternalState())
+	require.NoError(t, err)
+
+	err = resolver.ResolveState(state, nil, testutils.RandomAddress(t), tx2, []types.ID{tx1}, mustParsePatches(t,
+		`.text[0:0] = "x"`,
+		`move .text to .other`,
+	))
+	require.Error(t, err)
+
+	after, err := json.Marshal(resolver.InternalState())
+	require.NoError(t, err)
+	require.JSONEq(t, string(before), string(after))
+}
+
+func TestSync9Resolver_RejectsOutOfRangePatchesWithoutChangingState(t *testing.T) {
+	var (
+		tx1 = types.IDFromString("tx1")
+		tx2 = types.IDFromString("tx2")
+	)
+
+	state := tree.NewMemoryNode()
+	resolver, err := redwood.NewSync9Resolver(nil, nil)
+	require.NoError(t, err)
+
+	err = resolver.ResolveState(state, nil, testutils.RandomAddress(t), tx1, []types.ID{redwood.GenesisTxID}, mustParsePatches(t, `.text = "abc"`))
+	require.NoError(t, err)
+	before, err := json.Marshal(resolver.InternalState())
+	require.NoError(t, err)
+
+	err = resolver.ResolveState(state, nil, testutils.RandomAddress(t), tx2, []types.ID{tx1}, mustParsePatches(t,
+		`.text[0:0] = "x"`,
+		`.text[10:20] = "y"`,
+	))
+	require.Error(t, err)
+
+	after, err := json.Marshal(resolver.InternalState())
+	require.NoError(t, err)
+	require.JSONEq(t, string(before), string(after))
+
+	// The failed tx left no trace, so it can be retried with valid patches
+	err = resolver.ResolveState(state, nil, testutils.RandomAddress(t), tx2, []types.ID{tx1}, mustParsePatches(t,
+		`.text[0:0] = "x"`,
+		`.text[3:3] = "y"`,
+	))
+	require.NoError(t, err)
+
+	text, exists, err := state.StringValue(tree.Keypath("text"))
+	require.NoError(t, err)
+	require.True(t, exists)
+	require.Equal(t, "xabcy", text)
+}
+
+func TestSync9Resolver_IndexesStringsByUTF16CodeUnit(t *testing.T) {
+	tests := []struct {
+		name     string
+		initial  string
+		patch    string
+		expected string
+		wantErr  bool
+	}{
+		{"two-byte rune", `"héllo"`, `.text[2:2] = "X"`, "héXllo", false},
+		{"surrogate pair", `"a😀b"`, `.text[3:3] = "X"`, "a😀Xb", false},
+		{"splits surrogate pair", `"a😀b"`, `.text[2:2] = "X"`, "a😀b", true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				tx1 = types.IDFromString("tx1")
+				tx2 = types.IDFromString("tx2")
+			)
+
+			state := tree.NewMemoryNode()
+			resolver, err := redwood.NewSync9Resolver(nil, nil)
+			require.NoError(t, err)
+
+			err = resolver.ResolveState(state, nil, testutils.RandomAddress(t), tx1, []types.ID{redwood.GenesisTxID}, mustParsePatches(t, `.text = `+test.initial))
+			require.NoError(t, err)
+
+			err = resolver.ResolveState(state, nil, testutils.RandomAddress(t), tx2, []types.ID{tx1}, mustParsePatches(t, test.patch))
+			if test.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			text, exists, err := state.StringValue(tree.Keypath("text"))
+			require.NoError(t, err)
+			require.True(t, exists)
+			require.Equal(t, test.expected, text)
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package redwood
+
+import (
+	"github.com/pkg/errors"
+
+	"redwood.dev/tree"
+	"redwood.dev/types"
+	"redwood.dev/utils"
+)
+
+// wasmResolver hands each tx to a WASM module's `resolve_state` export.  The
+// module reads the current state with `state_get` and describes the new state
+// by emitting patches, which are applied once the module returns successfully.
+//
+// The input passed to `resolve_state` looks like:
+//
+//	{
+//	    "sender": "96216849c49358b10257cb55b28ea603c874b05e",
+//	    "txID": "...",
+//	    "parents": ["..."],
+//	    "patches": [".foo.bar = 123"],
+//	    "internalState": {}
+//	}
+//
+// A module may persist its internal state by emitting patches whose keypaths
+// begin with `.internalState`.
+type wasmResolver struct {
+	sandbox       *wasmSandbox
+	internalState map[string]interface{}
+}
+
+// Ensure wasmResolver conforms to the Resolver interface
+var _ Resolver = (*wasmResolver)(nil)
+
+var wasmInternalStateKeypath = tree.Keypath("internalState")
+
+func NewWASMResolver(config tree.Node, internalState map[string]interface{}) (_ Resolver, err error) {
+	defer utils.Annotate(&err, "NewWASMResolver")
+
+	sandbox, err := newWasmSandbox(config, "resolver:wasm", "resolve_state")
+	if err != nil {
+		return nil, err
+	}
+	if internalState == nil {
+		internalState = make(map[string]interface{})
+	}
+	return &wasmResolver{sandbox: sandbox, internalState: internalState}, nil
+}
+
+// Close releases the resolver's WASM runtime.
+func (r *wasmResolver) Close() error {
+	return r.sandbox.close()
+}
+
+func (r *wasmResolver) InternalState() map[string]interface{} {
+	return r.internalState
+}
+
+func (r *wasmResolver) ResolveState(state tree.Node, refStore RefStore, sender types.Address, txID types.ID, parents []types.ID, patches []Patch) (err error) {
+	defer utils.Annotate(&err, "wasmResolver.ResolveState")
+
+	parentStrs := make([]string, len(parents))
+	for i := range parents {
+		parentStrs[i] = parents[i].String()
+	}
+
+	call := &wasmCall{state: state, allowPatches: true}
+	err = r.sandbox.call(call, map[string]interface{}{
+		"sender":        sender.Hex(),
+		"txID":          txID.String(),
+		"parents":       parentStrs,
+		"patches":       patches,
+		"internalState": r.internalState,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Apply the module's internal state patches to a copy, so that the old
+	// internal state survives if any of them are bad
+	internalState := tree.NewMemoryNode()
+	err = internalState.Set(nil, nil, DeepCopyJSValue(r.internalState))
+	if err != nil {
+		return err
+	}
+
+	var statePatches []Patch
+	for _, patch := range call.patches {
+		if patch.Keypath.StartsWith(wasmInternalStateKeypath) {
+			patch.Keypath = patch.Keypath.RelativeTo(wasmInternalStateKeypath)
+			err = patch.Apply(internalState)
+			if err != nil {
+				return err
+			}
+		} else {
+			statePatches = append(statePatches, patch)
+		}
+	}
+
+	newInternalState := make(map[string]interface{})
+	val, exists, err := internalState.Value(nil, nil)
+	if err != nil {
+		return err
+	} else if exists {
+		asMap, isMap := val.(map[string]interface{})
+		if !isMap {
+			return errors.New("internalState must be a map")
+		}
+		newInternalState = asMap
+	}
+
+	for _, patch := range statePatches {
+		err = patch.Apply(state)
+		if err != nil {
+			return err
+		}
+	}
+	r.internalState = newInternalState
+	return nil
+}
@@ -0,0 +1,97 @@
+package redwood
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"redwood.dev/crypto"
+	"redwood.dev/pb"
+	"redwood.dev/types"
+)
+
+type TrustedRPCClient struct {
+	pb.TrustedRPCClient
+	conn *grpc.ClientConn
+	jwt  string
+}
+
+func NewTrustedRPCClient(dialAddr string) (*TrustedRPCClient, error) {
+	c := &TrustedRPCClient{}
+
+	conn, err := grpc.Dial(dialAddr,
+		grpc.WithInsecure(),
+		grpc.WithUnaryInterceptor(func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			return invoker(c.withJWT(ctx), method, req, reply, cc, opts...)
+		}),
+		grpc.WithStreamInterceptor(func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return streamer(c.withJWT(ctx), desc, cc, method, opts...)
+		}),
+	)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	c.conn = conn
+	c.TrustedRPCClient = pb.NewTrustedRPCClient(conn)
+	return c, nil
+}
+
+func (c *TrustedRPCClient) withJWT(ctx context.Context) context.Context {
+	if c.jwt == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "bearer "+c.jwt)
+}
+
+func (c *TrustedRPCClient) Close() error {
+	return c.conn.Close()
+}
+
+// Authorize obtains a JWT from a server with a whitelist.  It's attached to
+// every subsequent request.
+func (c *TrustedRPCClient) Authorize(ctx context.Context, signingKeypair *crypto.SigningKeypair) error {
+	authClient, err := c.Authenticate(ctx)
+	if err != nil {
+		return err
+	}
+	defer authClient.CloseSend()
+
+	msg, err := authClient.Recv()
+	if err != nil {
+		return err
+	}
+
+	challenge := msg.GetAuthenticateChallenge()
+	if challenge == nil {
+		return ErrProtocol
+	}
+
+	sig, err := signingKeypair.SignHash(types.HashBytes(challenge.Challenge))
+	if err != nil {
+		return err
+	}
+
+	err = authClient.Send(&pb.AuthenticateMessage{
+		Payload: &pb.AuthenticateMessage_AuthenticateSignature_{AuthenticateSignature: &pb.AuthenticateMessage_AuthenticateSignature{
+			Signature: sig,
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	msg, err = authClient.Recv()
+	if err != nil {
+		return err
+	}
+
+	resp := msg.GetAuthenticateResponse()
+	if resp == nil {
+		return ErrProtocol
+	}
+	c.jwt = resp.Jwt
+	return nil
+}
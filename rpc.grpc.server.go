@@ -0,0 +1,660 @@
+package redwood
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	grpc_auth "github.com/grpc-ecosystem/go-grpc-middleware/auth"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"redwood.dev/ctx"
+	"redwood.dev/pb"
+	"redwood.dev/tree"
+	"redwood.dev/types"
+)
+
+func StartTrustedRPC(svc pb.TrustedRPCServer, config *TrustedRPCConfig) (*grpc.Server, error) {
+	if config == nil || !config.Enabled {
+		return nil, nil
+	}
+
+	listener, err := net.Listen("tcp", config.ListenHost)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var opts []grpc.ServerOption
+	if config.Whitelist.Enabled {
+		whitelist := newGRPCWhitelist(config.Whitelist.PermittedAddrs)
+		opts = append(opts,
+			grpc.UnaryInterceptor(whitelist.unaryInterceptor),
+			grpc.StreamInterceptor(whitelist.streamInterceptor),
+		)
+	}
+
+	server := grpc.NewServer(opts...)
+	pb.RegisterTrustedRPCServer(server, svc)
+	go server.Serve(listener)
+
+	return server, nil
+}
+
+type TrustedRPCServer struct {
+	pb.UnimplementedTrustedRPCServer
+	ctx.Logger
+	host            Host
+	refStore        RefStore
+	subscriptions   map[trustedRPCSubscriptionKey]map[*trustedRPCSubscription]struct{}
+	subscriptionsMu sync.Mutex
+}
+
+var _ pb.TrustedRPCServer = (*TrustedRPCServer)(nil)
+
+// trustedRPCSubscriptionKey identifies the subscriptions that an Unsubscribe
+// call closes.  Callers can only close their own subscriptions.
+type trustedRPCSubscriptionKey struct {
+	caller           types.Address
+	subscriptionType SubscriptionType
+	stateURI         string
+	keypath          string
+}
+
+type trustedRPCSubscription struct {
+	ReadableSubscription
+	chUnsubscribed chan struct{}
+}
+
+func NewTrustedRPCServer(host Host, refStore RefStore) *TrustedRPCServer {
+	return &TrustedRPCServer{
+		Logger:        ctx.NewLogger("trusted rpc"),
+		host:          host,
+		refStore:      refStore,
+		subscriptions: make(map[trustedRPCSubscriptionKey]map[*trustedRPCSubscription]struct{}),
+	}
+}
+
+const trustedRPCRefChunkSize = 64 * 1024
+
+func (s *TrustedRPCServer) SetHDMnemonic(ctx context.Context, req *pb.SetHDMnemonicReq) (*pb.SetHDMnemonicResp, error) {
+	err := s.host.SetHDMnemonic(req.Mnemonic)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.SetHDMnemonicResp{}, nil
+}
+
+// SubscribeToStates first sends the entire state at the requested keypath, and
+// then, for every new tx, the values at the keypaths that the tx changed.  All
+// keypaths are relative to the requested keypath.
+func (s *TrustedRPCServer) SubscribeToStates(req *pb.SubscribeToStatesReq, srv pb.TrustedRPC_SubscribeToStatesServer) error {
+	if req.StateURI == "" {
+		return status.Error(codes.InvalidArgument, "missing stateURI")
+	}
+	keypath := trustedRPCKeypath(req.Keypath)
+	key := trustedRPCSubscriptionKey{
+		caller:           trustedRPCCaller(srv.Context()),
+		subscriptionType: SubscriptionType_States,
+		stateURI:         req.StateURI,
+		keypath:          string(keypath),
+	}
+
+	return s.serveSubscription(srv.Context(), key, func(msg *SubscriptionMsg) error {
+		packet, err := statePacketForMsg(msg, keypath)
+		if err != nil {
+			s.Errorf("error preparing state packet: %v", err)
+			return err
+		} else if len(packet.State) == 0 {
+			return nil
+		}
+		return srv.Send(packet)
+	})
+}
+
+// UnsubscribeFromStates ends the caller's SubscribeToStates calls for the
+// given state URI and keypath.
+func (s *TrustedRPCServer) UnsubscribeFromStates(ctx context.Context, req *pb.UnsubscribeFromStatesReq) (*pb.UnsubscribeFromStatesResp, error) {
+	if req.StateURI == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing stateURI")
+	}
+	s.unsubscribe(trustedRPCSubscriptionKey{
+		caller:           trustedRPCCaller(ctx),
+		subscriptionType: SubscriptionType_States,
+		stateURI:         req.StateURI,
+		keypath:          string(trustedRPCKeypath(req.Keypath)),
+	})
+	return &pb.UnsubscribeFromStatesResp{}, nil
+}
+
+// SubscribeToTxs sends every new tx in the given state URI.
+func (s *TrustedRPCServer) SubscribeToTxs(req *pb.SubscribeToTxsReq, srv pb.TrustedRPC_SubscribeToTxsServer) error {
+	if req.StateURI == "" {
+		return status.Error(codes.InvalidArgument, "missing stateURI")
+	}
+	key := trustedRPCSubscriptionKey{
+		caller:           trustedRPCCaller(srv.Context()),
+		subscriptionType: SubscriptionType_Txs,
+		stateURI:         req.StateURI,
+	}
+
+	return s.serveSubscription(srv.Context(), key, func(msg *SubscriptionMsg) error {
+		if msg.Tx == nil {
+			return nil
+		}
+		return srv.Send(txPacketForTx(msg.Tx))
+	})
+}
+
+// UnsubscribeFromTxs ends the caller's SubscribeToTxs calls for the given
+// state URI.
+func (s *TrustedRPCServer) UnsubscribeFromTxs(ctx context.Context, req *pb.UnsubscribeFromTxsReq) (*pb.UnsubscribeFromTxsResp, error) {
+	if req.StateURI == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing stateURI")
+	}
+	s.unsubscribe(trustedRPCSubscriptionKey{
+		caller:           trustedRPCCaller(ctx),
+		subscriptionType: SubscriptionType_Txs,
+		stateURI:         req.StateURI,
+	})
+	return &pb.UnsubscribeFromTxsResp{}, nil
+}
+
+// serveSubscription subscribes to a state URI and hands each message to send
+// until the client goes away or unsubscribes.  The response headers are sent
+// as soon as the subscription is open.
+func (s *TrustedRPCServer) serveSubscription(srvCtx context.Context, key trustedRPCSubscriptionKey, send func(msg *SubscriptionMsg) error) error {
+	ctx, cancel := context.WithTimeout(srvCtx, 15*time.Second)
+	defer cancel()
+
+	var keypath tree.Keypath
+	if key.keypath != "" {
+		keypath = tree.Keypath(key.keypath)
+	}
+	readableSub, err := s.host.Subscribe(ctx, key.stateURI, key.subscriptionType, keypath, nil)
+	if err != nil {
+		return errors.Wrap(err, "error subscribing to "+key.stateURI)
+	}
+	sub := &trustedRPCSubscription{ReadableSubscription: readableSub, chUnsubscribed: make(chan struct{})}
+
+	s.subscriptionsMu.Lock()
+	if s.subscriptions[key] == nil {
+		s.subscriptions[key] = make(map[*trustedRPCSubscription]struct{})
+	}
+	s.subscriptions[key][sub] = struct{}{}
+	s.subscriptionsMu.Unlock()
+
+	defer func() {
+		s.subscriptionsMu.Lock()
+		defer s.subscriptionsMu.Unlock()
+		delete(s.subscriptions[key], sub)
+		if len(s.subscriptions[key]) == 0 {
+			delete(s.subscriptions, key)
+		}
+	}()
+
+	// Clients can wait for the headers to know that they won't miss anything
+	err = grpc.SendHeader(srvCtx, metadata.MD{})
+	if err != nil {
+		return err
+	}
+
+	// Reading blocks until the subscription is closed, so we close it when
+	// the client goes away
+	chReadDone := make(chan struct{})
+	defer close(chReadDone)
+	go func() {
+		select {
+		case <-srvCtx.Done():
+		case <-chReadDone:
+		}
+		sub.Close()
+	}()
+
+	for {
+		msg, err := sub.Read()
+		if err != nil {
+			select {
+			case <-sub.chUnsubscribed:
+				return nil
+			default:
+			}
+			if srvCtx.Err() != nil {
+				return srvCtx.Err()
+			}
+			return err
+		}
+
+		err = send(msg)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *TrustedRPCServer) unsubscribe(key trustedRPCSubscriptionKey) {
+	s.subscriptionsMu.Lock()
+	subs := s.subscriptions[key]
+	delete(s.subscriptions, key)
+	s.subscriptionsMu.Unlock()
+
+	for sub := range subs {
+		close(sub.chUnsubscribed)
+		sub.Close()
+	}
+}
+
+func trustedRPCKeypath(keypath string) tree.Keypath {
+	if tree.Keypath(keypath).Equals(tree.KeypathSeparator) {
+		return nil
+	}
+	return tree.Keypath(keypath)
+}
+
+func statePacketForMsg(msg *SubscriptionMsg, keypath tree.Keypath) (*pb.StatePacket, error) {
+	packet := &pb.StatePacket{Leaves: idsToBytes(msg.Leaves)}
+	if msg.State == nil {
+		return packet, nil
+	}
+
+	var changed []tree.Keypath
+	if msg.Tx == nil {
+		changed = []tree.Keypath{nil}
+	} else {
+		for _, patch := range msg.Tx.Patches {
+			if patch.Keypath.StartsWith(keypath) {
+				changed = append(changed, patch.Keypath.RelativeTo(keypath))
+			} else if keypath.StartsWith(patch.Keypath) {
+				// The patch replaced the entire subtree that the subscriber wants
+				changed = []tree.Keypath{nil}
+				break
+			}
+		}
+	}
+
+	for _, relKeypath := range changed {
+		val, _, err := msg.State.Value(relKeypath, nil)
+		if err != nil {
+			return nil, err
+		}
+		bs, err := json.Marshal(val)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		packet.State = append(packet.State, &pb.KeypathValue{Keypath: string(relKeypath), Value: bs})
+	}
+	return packet, nil
+}
+
+func txPacketForTx(tx *Tx) *pb.TxPacket {
+	hash := tx.Hash()
+	packet := &pb.TxPacket{
+		StateURI:   tx.StateURI,
+		Id:         tx.ID.Bytes(),
+		Parents:    idsToBytes(tx.Parents),
+		Children:   idsToBytes(tx.Children),
+		From:       tx.From.Bytes(),
+		Sig:        tx.Sig,
+		Attachment: tx.Attachment,
+		Status:     txStatusToProto(tx.Status),
+		Hash:       hash[:],
+	}
+	for _, patch := range tx.Patches {
+		packet.Patches = append(packet.Patches, patch.String())
+	}
+	for _, recipient := range tx.Recipients {
+		packet.Recipients = append(packet.Recipients, recipient.Bytes())
+	}
+	return packet
+}
+
+func txStatusToProto(status TxStatus) pb.TxPacket_TxStatus {
+	switch status {
+	case TxStatusInMempool:
+		return pb.TxPacket_IN_MEMPOOL
+	case TxStatusInvalid:
+		return pb.TxPacket_INVALID
+	case TxStatusValid:
+		return pb.TxPacket_VALID
+	default:
+		return pb.TxPacket_UNKNOWN
+	}
+}
+
+func (s *TrustedRPCServer) GetState(ctx context.Context, req *pb.GetStateReq) (*pb.StatePacket, error) {
+	if req.StateURI == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing stateURI")
+	}
+
+	var version *types.ID
+	if len(req.Version) > 0 {
+		v := types.IDFromBytes(req.Version)
+		version = &v
+	}
+
+	state, err := s.host.StateAtVersion(req.StateURI, version)
+	if errors.Cause(err) == ErrNoController {
+		return nil, status.Error(codes.NotFound, err.Error())
+	} else if err != nil {
+		return nil, err
+	}
+	defer state.Close()
+
+	var leaves []types.ID
+	if version != nil {
+		leaves = []types.ID{*version}
+	} else {
+		leaves, err = s.host.Controllers().Leaves(req.StateURI)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	keypath := trustedRPCKeypath(req.Keypath)
+	val, exists, err := state.Value(keypath, nil)
+	if err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, status.Errorf(codes.NotFound, "nothing at keypath %v", keypath)
+	}
+	bs, err := json.Marshal(val)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return &pb.StatePacket{
+		State:  []*pb.KeypathValue{{Keypath: "", Value: bs}},
+		Leaves: idsToBytes(leaves),
+	}, nil
+}
+
+func (s *TrustedRPCServer) SendTx(ctx context.Context, req *pb.SendTxReq) (*pb.SendTxResp, error) {
+	if req.StateURI == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing stateURI")
+	}
+
+	tx := Tx{
+		StateURI:   req.StateURI,
+		Attachment: req.Attachment,
+	}
+	if len(req.Id) > 0 {
+		tx.ID = types.IDFromBytes(req.Id)
+	} else {
+		tx.ID = types.RandomID()
+	}
+	for _, parent := range req.Parents {
+		tx.Parents = append(tx.Parents, types.IDFromBytes(parent))
+	}
+	for _, patchStr := range req.Patches {
+		patch, err := ParsePatch([]byte(patchStr))
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "bad patch %q: %v", patchStr, err)
+		}
+		tx.Patches = append(tx.Patches, patch)
+	}
+
+	err := s.host.SendTx(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.SendTxResp{StateURI: tx.StateURI, Id: tx.ID[:]}, nil
+}
+
+func (s *TrustedRPCServer) AddRef(srv pb.TrustedRPC_AddRefServer) error {
+	pr, pw := io.Pipe()
+	go func() {
+		var err error
+		defer func() { pw.CloseWithError(err) }()
+
+		for {
+			var packet *pb.RefPacket
+			packet, err = srv.Recv()
+			if err == io.EOF {
+				err = nil
+				return
+			} else if err != nil {
+				return
+			}
+
+			_, err = pw.Write(packet.Data)
+			if err != nil {
+				return
+			} else if packet.End {
+				return
+			}
+		}
+	}()
+
+	sha1Hash, sha3Hash, err := s.host.AddRef(pr)
+	if err != nil {
+		return err
+	}
+	return srv.SendAndClose(&pb.AddRefResp{
+		Sha1Hash: sha1Hash[:20],
+		Sha3Hash: sha3Hash[:],
+	})
+}
+
+// FetchRef streams a ref to the client, fetching it from the network first if
+// we don't have it yet.
+func (s *TrustedRPCServer) FetchRef(req *pb.FetchRefReq, srv pb.TrustedRPC_FetchRefServer) error {
+	var refID types.RefID
+	switch hash := req.Hash.(type) {
+	case *pb.FetchRefReq_Sha1:
+		refID.HashAlg = types.SHA1
+		copy(refID.Hash[:], hash.Sha1)
+	case *pb.FetchRefReq_Sha3:
+		refID.HashAlg = types.SHA3
+		copy(refID.Hash[:], hash.Sha3)
+	default:
+		return status.Error(codes.InvalidArgument, "missing hash")
+	}
+
+	have, err := s.refStore.HaveObject(refID)
+	if err != nil {
+		return err
+	} else if !have {
+		s.host.FetchRef(srv.Context(), refID)
+
+		have, err = s.refStore.HaveObject(refID)
+		if err != nil {
+			return err
+		} else if !have {
+			return status.Errorf(codes.NotFound, "ref %v not found", refID)
+		}
+	}
+
+	reader, _, err := s.refStore.Object(refID)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	buf := make([]byte, trustedRPCRefChunkSize)
+	for {
+		n, err := io.ReadFull(reader, buf)
+		if err == io.EOF {
+			return srv.Send(&pb.RefPacket{End: true})
+		} else if err == io.ErrUnexpectedEOF {
+			return srv.Send(&pb.RefPacket{Data: buf[:n], End: true})
+		} else if err != nil {
+			return err
+		}
+
+		err = srv.Send(&pb.RefPacket{Data: buf[:n]})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *TrustedRPCServer) AddPeer(ctx context.Context, req *pb.AddPeerReq) (*pb.AddPeerResp, error) {
+	transportName, err := transportNameFromProto(req.Transport)
+	if err != nil {
+		return nil, err
+	}
+	for _, dialAddr := range req.DialAddrs {
+		s.host.AddPeer(PeerDialInfo{TransportName: transportName, DialAddr: dialAddr})
+	}
+	return &pb.AddPeerResp{}, nil
+}
+
+func (s *TrustedRPCServer) DropPeer(ctx context.Context, req *pb.DropPeerReq) (*pb.DropPeerResp, error) {
+	var dialInfos []PeerDialInfo
+	switch identifier := req.Identifier.(type) {
+	case *pb.DropPeerReq_PeerDialInfo_:
+		transportName, err := transportNameFromProto(identifier.PeerDialInfo.Transport)
+		if err != nil {
+			return nil, err
+		}
+		for _, dialAddr := range identifier.PeerDialInfo.DialAddrs {
+			dialInfos = append(dialInfos, PeerDialInfo{TransportName: transportName, DialAddr: dialAddr})
+		}
+
+	case *pb.DropPeerReq_Address:
+		addr := types.AddressFromBytes(identifier.Address)
+		for _, peer := range s.host.Peers() {
+			for _, peerAddr := range peer.Addresses() {
+				if peerAddr == addr {
+					dialInfos = append(dialInfos, peer.DialInfo())
+					break
+				}
+			}
+		}
+
+	default:
+		return nil, status.Error(codes.InvalidArgument, "missing peer identifier")
+	}
+
+	s.host.RemovePeers(dialInfos)
+	return &pb.DropPeerResp{}, nil
+}
+
+func transportNameFromProto(transport pb.Transport) (string, error) {
+	switch transport {
+	case pb.Transport_LIBP2P:
+		return "libp2p", nil
+	case pb.Transport_HTTPS:
+		return "http", nil
+	case pb.Transport_WEBSOCKET:
+		return "websocket", nil
+	case pb.Transport_WEBRTC:
+		return "webrtc", nil
+	default:
+		return "", status.Errorf(codes.InvalidArgument, "unknown transport %v", transport)
+	}
+}
+
+func idsToBytes(ids []types.ID) [][]byte {
+	bss := make([][]byte, len(ids))
+	for i := range ids {
+		bss[i] = ids[i].Bytes()
+	}
+	return bss
+}
+
+// grpcWhitelist gives the gRPC server the same semantics as the HTTP RPC
+// server's whitelistMiddleware.  Anyone can complete the Authenticate
+// handshake to obtain a JWT, but only the permitted addresses may call
+// anything else.
+type grpcWhitelist struct {
+	rpcWhitelist
+}
+
+const trustedRPCAuthenticateMethod = "/trustedrpc.TrustedRPC/Authenticate"
+
+type trustedRPCCallerKey struct{}
+
+// trustedRPCCaller returns the address that made a call, or the zero address
+// if the server doesn't have a whitelist.
+func trustedRPCCaller(ctx context.Context) types.Address {
+	addr, _ := ctx.Value(trustedRPCCallerKey{}).(types.Address)
+	return addr
+}
+
+func newGRPCWhitelist(permittedAddrs []types.Address) *grpcWhitelist {
+	return &grpcWhitelist{newRPCWhitelist(permittedAddrs)}
+}
+
+func (wl *grpcWhitelist) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := wl.checkCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (wl *grpcWhitelist) streamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if info.FullMethod == trustedRPCAuthenticateMethod {
+		return wl.authenticate(stream)
+	}
+	ctx, err := wl.checkCaller(stream.Context())
+	if err != nil {
+		return err
+	}
+	wrapped := grpc_middleware.WrapServerStream(stream)
+	wrapped.WrappedContext = ctx
+	return handler(srv, wrapped)
+}
+
+func (wl *grpcWhitelist) authenticate(stream grpc.ServerStream) error {
+	challenge, err := types.GenerateChallengeMsg()
+	if err != nil {
+		return err
+	}
+
+	err = stream.SendMsg(&pb.AuthenticateMessage{
+		Payload: &pb.AuthenticateMessage_AuthenticateChallenge_{AuthenticateChallenge: &pb.AuthenticateMessage_AuthenticateChallenge{
+			Challenge: challenge,
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	var msg pb.AuthenticateMessage
+	err = stream.RecvMsg(&msg)
+	if err != nil {
+		return err
+	}
+
+	sig := msg.GetAuthenticateSignature()
+	if sig == nil {
+		return status.Error(codes.InvalidArgument, ErrProtocol.Error())
+	}
+
+	jwtTokenString, err := wl.issueJWT(challenge, sig.Signature)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return stream.SendMsg(&pb.AuthenticateMessage{
+		Payload: &pb.AuthenticateMessage_AuthenticateResponse_{AuthenticateResponse: &pb.AuthenticateMessage_AuthenticateResponse{
+			Jwt: jwtTokenString,
+		}},
+	})
+}
+
+// checkCaller checks the JWT attached to a call, and returns a context that
+// carries the caller's address.
+func (wl *grpcWhitelist) checkCaller(ctx context.Context) (context.Context, error) {
+	jwtToken, err := grpc_auth.AuthFromMD(ctx, "bearer")
+	if err != nil {
+		return nil, err
+	}
+
+	addr, err := wl.checkJWT(jwtToken)
+	if errors.Cause(err) == types.Err403 {
+		return nil, status.Error(codes.PermissionDenied, "nope")
+	} else if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return context.WithValue(ctx, trustedRPCCallerKey{}, addr), nil
+}
@@ -0,0 +1,269 @@
+package redwood_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"redwood.dev"
+	"redwood.dev/crypto"
+	"redwood.dev/pb"
+	"redwood.dev/types"
+)
+
+func setupTrustedRPC(t *testing.T, permittedAddrs []types.Address) (redwood.Host, string, func()) {
+	t.Helper()
+
+	h, _, cleanupHost := setupWebRTCHost(t, false)
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	listenAddr := listener.Addr().String()
+	listener.Close()
+
+	server, err := redwood.StartTrustedRPC(redwood.NewTrustedRPCServer(h, redwood.RefStoreOf(h)), &redwood.TrustedRPCConfig{
+		Enabled:    true,
+		ListenHost: listenAddr,
+		Whitelist:  redwood.WhitelistConfig{Enabled: true, PermittedAddrs: permittedAddrs},
+	})
+	require.NoError(t, err)
+
+	return h, listenAddr, func() {
+		server.Stop()
+		cleanupHost()
+	}
+}
+
+func newAuthorizedTrustedRPCClient(t *testing.T, dialAddr string, signingKeypair *crypto.SigningKeypair) *redwood.TrustedRPCClient {
+	t.Helper()
+
+	client, err := redwood.NewTrustedRPCClient(dialAddr)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err = client.Authorize(ctx, signingKeypair)
+	require.NoError(t, err)
+	return client
+}
+
+func TestTrustedRPC_Whitelist(t *testing.T) {
+	permitted, err := crypto.GenerateSigningKeypair()
+	require.NoError(t, err)
+	notPermitted, err := crypto.GenerateSigningKeypair()
+	require.NoError(t, err)
+
+	_, dialAddr, cleanup := setupTrustedRPC(t, []types.Address{permitted.Address()})
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	anonymous, err := redwood.NewTrustedRPCClient(dialAddr)
+	require.NoError(t, err)
+	defer anonymous.Close()
+	_, err = anonymous.AddPeer(ctx, &pb.AddPeerReq{})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	client := newAuthorizedTrustedRPCClient(t, dialAddr, notPermitted)
+	defer client.Close()
+	_, err = client.AddPeer(ctx, &pb.AddPeerReq{})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	client = newAuthorizedTrustedRPCClient(t, dialAddr, permitted)
+	defer client.Close()
+	_, err = client.AddPeer(ctx, &pb.AddPeerReq{})
+	require.NoError(t, err)
+}
+
+func TestTrustedRPC_StatesAndTxs(t *testing.T) {
+	signingKeypair, err := crypto.GenerateSigningKeypair()
+	require.NoError(t, err)
+
+	h, dialAddr, cleanup := setupTrustedRPC(t, []types.Address{signingKeypair.Address()})
+	defer cleanup()
+
+	client := newAuthorizedTrustedRPCClient(t, dialAddr, signingKeypair)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	stateURI := "foo.com/bar"
+
+	_, err = client.SendTx(ctx, &pb.SendTxReq{
+		StateURI: stateURI,
+		Id:       redwood.GenesisTxID[:],
+		Patches:  []string{`. = {"count": 1, "text": "hello"}`},
+	})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		have, err := h.Controllers().HaveTx(stateURI, redwood.GenesisTxID)
+		return err == nil && have
+	}, 10*time.Second, 50*time.Millisecond)
+
+	state, err := client.GetState(ctx, &pb.GetStateReq{StateURI: stateURI, Keypath: "text"})
+	require.NoError(t, err)
+	require.Len(t, state.State, 1)
+	require.JSONEq(t, `"hello"`, string(state.State[0].Value))
+	require.Equal(t, [][]byte{redwood.GenesisTxID[:]}, state.Leaves)
+
+	sub, err := client.SubscribeToStates(ctx, &pb.SubscribeToStatesReq{StateURI: stateURI})
+	require.NoError(t, err)
+
+	// The first packet contains the entire state
+	packet, err := sub.Recv()
+	require.NoError(t, err)
+	require.Len(t, packet.State, 1)
+	require.Equal(t, "", packet.State[0].Keypath)
+	require.JSONEq(t, `{"count": 1, "text": "hello"}`, string(packet.State[0].Value))
+
+	// Later packets only contain what changed
+	resp, err := client.SendTx(ctx, &pb.SendTxReq{
+		StateURI: stateURI,
+		Patches:  []string{`.count = 2`},
+	})
+	require.NoError(t, err)
+	require.Equal(t, stateURI, resp.StateURI)
+
+	packet, err = sub.Recv()
+	require.NoError(t, err)
+	require.Len(t, packet.State, 1)
+	require.Equal(t, "count", packet.State[0].Keypath)
+	require.JSONEq(t, `2`, string(packet.State[0].Value))
+	require.Equal(t, [][]byte{resp.Id}, packet.Leaves)
+
+	_, err = client.SendTx(ctx, &pb.SendTxReq{StateURI: stateURI, Patches: []string{`nope`}})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	txSub, err := client.SubscribeToTxs(ctx, &pb.SubscribeToTxsReq{StateURI: stateURI})
+	require.NoError(t, err)
+
+	// The headers arrive once the subscription is open
+	_, err = txSub.Header()
+	require.NoError(t, err)
+
+	resp2, err := client.SendTx(ctx, &pb.SendTxReq{
+		StateURI: stateURI,
+		Patches:  []string{`.count = 3`},
+	})
+	require.NoError(t, err)
+
+	txPacket, err := txSub.Recv()
+	require.NoError(t, err)
+	require.Equal(t, stateURI, txPacket.StateURI)
+	require.Equal(t, resp2.Id, txPacket.Id)
+	require.Equal(t, [][]byte{resp.Id}, txPacket.Parents)
+	require.Equal(t, []string{`.count = 3`}, txPacket.Patches)
+
+	// Unsubscribing ends the streams
+	_, err = client.UnsubscribeFromTxs(ctx, &pb.UnsubscribeFromTxsReq{StateURI: stateURI})
+	require.NoError(t, err)
+	_, err = txSub.Recv()
+	require.Equal(t, io.EOF, err)
+
+	_, err = client.UnsubscribeFromStates(ctx, &pb.UnsubscribeFromStatesReq{StateURI: stateURI})
+	require.NoError(t, err)
+	for {
+		_, err = sub.Recv()
+		if err != nil {
+			break
+		}
+	}
+	require.Equal(t, io.EOF, err)
+}
+
+func TestTrustedRPC_Refs(t *testing.T) {
+	signingKeypair, err := crypto.GenerateSigningKeypair()
+	require.NoError(t, err)
+
+	_, dialAddr, cleanup := setupTrustedRPC(t, []types.Address{signingKeypair.Address()})
+	defer cleanup()
+
+	client := newAuthorizedTrustedRPCClient(t, dialAddr, signingKeypair)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	data := bytes.Repeat([]byte("redwood"), 3*redwood.TrustedRPCRefChunkSize/7)
+
+	addRef, err := client.AddRef(ctx)
+	require.NoError(t, err)
+	for i := 0; i < len(data); i += 1000 {
+		end := i + 1000
+		if end > len(data) {
+			end = len(data)
+		}
+		err = addRef.Send(&pb.RefPacket{Data: data[i:end]})
+		require.NoError(t, err)
+	}
+	err = addRef.Send(&pb.RefPacket{End: true})
+	require.NoError(t, err)
+	hashes, err := addRef.CloseAndRecv()
+	require.NoError(t, err)
+	require.Len(t, hashes.Sha1Hash, 20)
+	require.Len(t, hashes.Sha3Hash, 32)
+
+	fetchRef, err := client.FetchRef(ctx, &pb.FetchRefReq{Hash: &pb.FetchRefReq_Sha3{Sha3: hashes.Sha3Hash}})
+	require.NoError(t, err)
+
+	var fetched []byte
+	for {
+		packet, err := fetchRef.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		fetched = append(fetched, packet.Data...)
+		if packet.End {
+			break
+		}
+	}
+	require.Equal(t, data, fetched)
+}
+
+func TestTrustedRPC_PeersAndKeys(t *testing.T) {
+	signingKeypair, err := crypto.GenerateSigningKeypair()
+	require.NoError(t, err)
+
+	h, dialAddr, cleanup := setupTrustedRPC(t, []types.Address{signingKeypair.Address()})
+	defer cleanup()
+
+	client := newAuthorizedTrustedRPCClient(t, dialAddr, signingKeypair)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	dialInfo := redwood.PeerDialInfo{TransportName: "http", DialAddr: "http://asdf.dev:1234"}
+	_, err = client.AddPeer(ctx, &pb.AddPeerReq{Transport: pb.Transport_HTTPS, DialAddrs: []string{dialInfo.DialAddr}})
+	require.NoError(t, err)
+	require.True(t, redwood.PeerStoreOf(h).IsKnownPeer(dialInfo))
+
+	_, err = client.DropPeer(ctx, &pb.DropPeerReq{Identifier: &pb.DropPeerReq_PeerDialInfo_{PeerDialInfo: &pb.DropPeerReq_PeerDialInfo{
+		Transport: pb.Transport_HTTPS,
+		DialAddrs: []string{dialInfo.DialAddr},
+	}}})
+	require.NoError(t, err)
+	require.False(t, redwood.PeerStoreOf(h).IsKnownPeer(dialInfo))
+
+	mnemonic, err := crypto.GenerateMnemonic()
+	require.NoError(t, err)
+	_, err = client.SetHDMnemonic(ctx, &pb.SetHDMnemonicReq{Mnemonic: mnemonic})
+	require.NoError(t, err)
+
+	expected, err := crypto.SigningKeypairFromHDMnemonic(mnemonic, 0)
+	require.NoError(t, err)
+	identities, err := h.Identities()
+	require.NoError(t, err)
+	require.Equal(t, expected.Address(), identities[0].Address())
+}
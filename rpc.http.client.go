@@ -123,3 +123,69 @@ func (c *HTTPRPCClient) KnownStateURIs() ([]string, error) {
 func (c *HTTPRPCClient) SendTx(args RPCSendTxArgs) error {
 	return c.rpcClient.Call("RPC.SendTx", args, nil)
 }
+
+func (c *HTTPRPCClient) Unsubscribe(args RPCUnsubscribeArgs) error {
+	return c.rpcClient.Call("RPC.Unsubscribe", args, nil)
+}
+
+func (c *HTTPRPCClient) StateAtVersion(args RPCStateAtVersionArgs) (interface{}, error) {
+	var resp RPCStateAtVersionResponse
+	err := c.rpcClient.Call("RPC.StateAtVersion", args, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.State, nil
+}
+
+func (c *HTTPRPCClient) QueryIndex(args RPCQueryIndexArgs) (interface{}, error) {
+	var resp RPCQueryIndexResponse
+	err := c.rpcClient.Call("RPC.QueryIndex", args, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Index, nil
+}
+
+func (c *HTTPRPCClient) Peers() ([]RPCPeer, error) {
+	var resp RPCPeersResponse
+	err := c.rpcClient.Call("RPC.Peers", nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Peers, nil
+}
+
+func (c *HTTPRPCClient) RemovePeer(args RPCRemovePeerArgs) error {
+	return c.rpcClient.Call("RPC.RemovePeer", args, nil)
+}
+
+func (c *HTTPRPCClient) BanPeer(args RPCBanPeerArgs) error {
+	return c.rpcClient.Call("RPC.BanPeer", args, nil)
+}
+
+func (c *HTTPRPCClient) FetchHistory(args RPCFetchHistoryArgs) ([]Tx, error) {
+	var resp RPCFetchHistoryResponse
+	err := c.rpcClient.Call("RPC.FetchHistory", args, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Txs, nil
+}
+
+func (c *HTTPRPCClient) StoreRef(data []byte) (sha1 types.Hash, sha3 types.Hash, _ error) {
+	var resp RPCStoreRefResponse
+	err := c.rpcClient.Call("RPC.StoreRef", RPCStoreRefArgs{Data: data}, &resp)
+	if err != nil {
+		return types.Hash{}, types.Hash{}, err
+	}
+	return resp.SHA1, resp.SHA3, nil
+}
+
+func (c *HTTPRPCClient) FetchRef(refID types.RefID) ([]byte, error) {
+	var resp RPCFetchRefResponse
+	err := c.rpcClient.Call("RPC.FetchRef", RPCFetchRefArgs{RefID: refID}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
@@ -1,22 +1,21 @@
 package redwood
 
 import (
+	"bytes"
 	"context"
-	"crypto/rand"
 	"encoding/hex"
-	"fmt"
+	"io/ioutil"
 	"net/http"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gorilla/rpc/v2"
 	"github.com/gorilla/rpc/v2/json2"
 	"github.com/pkg/errors"
 
-	"redwood.dev/crypto"
 	"redwood.dev/ctx"
+	"redwood.dev/query"
 	"redwood.dev/tree"
 	"redwood.dev/types"
 )
@@ -172,33 +171,318 @@ func (s *HTTPRPCServer) SendTx(r *http.Request, args *RPCSendTxArgs, resp *RPCSe
 	return s.host.SendTx(context.Background(), args.Tx)
 }
 
-type whitelistMiddleware struct {
-	permittedAddrs          map[types.Address]struct{}
-	nextHandler             http.Handler
-	jwtSecret               []byte
-	pendingAuthorizations   map[string]struct{}
-	pendingAuthorizationsMu sync.Mutex
+type (
+	RPCUnsubscribeArgs struct {
+		StateURI string
+	}
+	RPCUnsubscribeResponse struct{}
+)
+
+func (s *HTTPRPCServer) Unsubscribe(r *http.Request, args *RPCUnsubscribeArgs, resp *RPCUnsubscribeResponse) error {
+	if args.StateURI == "" {
+		return errors.New("missing StateURI")
+	}
+	return s.host.Unsubscribe(args.StateURI)
 }
 
-type WhitelistConfig struct {
-	Enabled        bool            `yaml:"Enabled"`
-	PermittedAddrs []types.Address `yaml:"PermittedAddrs"`
+type (
+	RPCStateAtVersionArgs struct {
+		StateURI string
+		Keypath  string
+		Version  *types.ID
+		Query    string // If set, State is a list of the query's results
+	}
+	RPCStateAtVersionResponse struct {
+		State interface{}
+	}
+)
+
+func (s *HTTPRPCServer) StateAtVersion(r *http.Request, args *RPCStateAtVersionArgs, resp *RPCStateAtVersionResponse) error {
+	if args.StateURI == "" {
+		return errors.New("missing StateURI")
+	}
+
+	state, err := s.host.StateAtVersion(args.StateURI, args.Version)
+	if err != nil {
+		return err
+	}
+	defer state.Close()
+
+	if args.Query != "" {
+		q, err := query.Parse(args.Query)
+		if err != nil {
+			return err
+		}
+		results, err := q.Evaluate(state.NodeAt(tree.Keypath(args.Keypath), nil), nil)
+		if err != nil {
+			return err
+		}
+		resp.State = results
+		return nil
+	}
+
+	val, exists, err := state.Value(tree.Keypath(args.Keypath), nil)
+	if err != nil {
+		return err
+	} else if !exists {
+		return errors.Wrapf(types.Err404, "nothing at keypath %v", args.Keypath)
+	}
+	resp.State = val
+	return nil
 }
 
-func NewWhitelistMiddleware(permittedAddrs []types.Address, nextHandler http.Handler) *whitelistMiddleware {
-	jwtSecret := make([]byte, 64)
-	_, err := rand.Read(jwtSecret)
+type (
+	RPCQueryIndexArgs struct {
+		StateURI   string
+		Version    *types.ID
+		Keypath    string
+		IndexName  string
+		QueryParam string
+		Range      *tree.Range
+	}
+	RPCQueryIndexResponse struct {
+		Index interface{}
+	}
+)
+
+func (s *HTTPRPCServer) QueryIndex(r *http.Request, args *RPCQueryIndexArgs, resp *RPCQueryIndexResponse) error {
+	if args.StateURI == "" {
+		return errors.New("missing StateURI")
+	} else if args.IndexName == "" {
+		return errors.New("missing IndexName")
+	}
+
+	index, err := s.host.Controllers().QueryIndex(
+		args.StateURI,
+		args.Version,
+		tree.Keypath(args.Keypath),
+		tree.Keypath(args.IndexName),
+		tree.Keypath(args.QueryParam),
+		args.Range,
+	)
+	if err != nil {
+		return err
+	}
+
+	val, _, err := index.Value(nil, nil)
 	if err != nil {
-		panic(err)
+		return err
+	}
+	resp.Index = val
+	return nil
+}
+
+type (
+	RPCPeersArgs     struct{}
+	RPCPeersResponse struct {
+		Peers []RPCPeer
+	}
+	RPCPeer struct {
+		Identities  []RPCPeerIdentity
+		Transport   string
+		DialAddr    string
+		StateURIs   []string
+		LastContact uint64
+		LastFailure uint64
+		Failures    uint64
+	}
+	RPCPeerIdentity struct {
+		Address             types.Address
+		SigningPublicKey    []byte
+		EncryptingPublicKey []byte
+	}
+)
+
+func (s *HTTPRPCServer) Peers(r *http.Request, args *RPCPeersArgs, resp *RPCPeersResponse) error {
+	for _, peer := range s.host.Peers() {
+		var identities []RPCPeerIdentity
+		for _, addr := range peer.Addresses() {
+			identity := RPCPeerIdentity{Address: addr}
+			sigpubkey, encpubkey := peer.PublicKeys(addr)
+			if sigpubkey != nil {
+				identity.SigningPublicKey = sigpubkey.Bytes()
+			}
+			if encpubkey != nil {
+				identity.EncryptingPublicKey = encpubkey.Bytes()
+			}
+			identities = append(identities, identity)
+		}
+
+		var lastContact, lastFailure uint64
+		if !peer.LastContact().IsZero() {
+			lastContact = uint64(peer.LastContact().UTC().Unix())
+		}
+		if !peer.LastFailure().IsZero() {
+			lastFailure = uint64(peer.LastFailure().UTC().Unix())
+		}
+
+		resp.Peers = append(resp.Peers, RPCPeer{
+			Identities:  identities,
+			Transport:   peer.DialInfo().TransportName,
+			DialAddr:    peer.DialInfo().DialAddr,
+			StateURIs:   peer.StateURIs().Slice(),
+			LastContact: lastContact,
+			LastFailure: lastFailure,
+			Failures:    peer.Failures(),
+		})
 	}
-	paddrs := make(map[types.Address]struct{}, len(permittedAddrs))
-	for _, addr := range permittedAddrs {
-		paddrs[addr] = struct{}{}
+	return nil
+}
+
+type (
+	RPCRemovePeerArgs struct {
+		TransportName string
+		DialAddr      string
 	}
+	RPCRemovePeerResponse struct{}
+)
+
+func (s *HTTPRPCServer) RemovePeer(r *http.Request, args *RPCRemovePeerArgs, resp *RPCRemovePeerResponse) error {
+	s.host.RemovePeers([]PeerDialInfo{{TransportName: args.TransportName, DialAddr: args.DialAddr}})
+	return nil
+}
+
+type (
+	RPCBanPeerArgs struct {
+		TransportName string
+		DialAddr      string
+	}
+	RPCBanPeerResponse struct{}
+)
+
+func (s *HTTPRPCServer) BanPeer(r *http.Request, args *RPCBanPeerArgs, resp *RPCBanPeerResponse) error {
+	if args.DialAddr == "" {
+		return errors.New("missing DialAddr")
+	}
+	s.host.BanPeers([]PeerDialInfo{{TransportName: args.TransportName, DialAddr: args.DialAddr}})
+	return nil
+}
+
+type (
+	RPCFetchHistoryArgs struct {
+		StateURI string
+		Opts     FetchHistoryOpts
+	}
+	RPCFetchHistoryResponse struct {
+		Txs []Tx
+	}
+)
+
+func (s *HTTPRPCServer) FetchHistory(r *http.Request, args *RPCFetchHistoryArgs, resp *RPCFetchHistoryResponse) error {
+	if args.StateURI == "" {
+		return errors.New("missing StateURI")
+	}
+
+	collector := &txHistoryCollector{stateURI: args.StateURI}
+	err := s.host.HandleFetchHistoryRequest(args.StateURI, args.Opts, collector)
+	if err != nil {
+		return err
+	}
+	resp.Txs = collector.txs
+	return nil
+}
+
+// txHistoryCollector is a WritableSubscription that simply accumulates the
+// txs written to it, so that history can be returned in a single response.
+type txHistoryCollector struct {
+	stateURI string
+	txs      []Tx
+}
+
+var _ WritableSubscription = (*txHistoryCollector)(nil)
+
+func (c *txHistoryCollector) StateURI() string       { return c.stateURI }
+func (c *txHistoryCollector) Type() SubscriptionType { return SubscriptionType_Txs }
+func (c *txHistoryCollector) Keypath() tree.Keypath  { return nil }
+func (c *txHistoryCollector) Close() error           { return nil }
+
+func (c *txHistoryCollector) EnqueueWrite(tx *Tx, state tree.Node, leaves []types.ID) {
+	if tx != nil {
+		c.txs = append(c.txs, *tx)
+	}
+}
+
+type (
+	RPCMempoolStatsArgs struct {
+		StateURI string
+	}
+	RPCMempoolStatsResponse struct {
+		Stats MempoolStats
+	}
+)
+
+func (s *HTTPRPCServer) MempoolStats(r *http.Request, args *RPCMempoolStatsArgs, resp *RPCMempoolStatsResponse) error {
+	if args.StateURI == "" {
+		return errors.New("missing StateURI")
+	}
+	stats, err := s.host.Controllers().MempoolStats(args.StateURI)
+	if err != nil {
+		return err
+	}
+	resp.Stats = stats
+	return nil
+}
+
+type (
+	RPCStoreRefArgs struct {
+		Data []byte
+	}
+	RPCStoreRefResponse struct {
+		SHA1 types.Hash
+		SHA3 types.Hash
+	}
+)
+
+func (s *HTTPRPCServer) StoreRef(r *http.Request, args *RPCStoreRefArgs, resp *RPCStoreRefResponse) error {
+	sha1, sha3, err := s.host.AddRef(ioutil.NopCloser(bytes.NewReader(args.Data)))
+	if err != nil {
+		return err
+	}
+	resp.SHA1 = sha1
+	resp.SHA3 = sha3
+	return nil
+}
+
+type (
+	RPCFetchRefArgs struct {
+		RefID types.RefID
+	}
+	RPCFetchRefResponse struct {
+		Data []byte
+	}
+)
+
+// FetchRef returns the contents of a ref, fetching it from the network first
+// if we don't have it yet.
+func (s *HTTPRPCServer) FetchRef(r *http.Request, args *RPCFetchRefArgs, resp *RPCFetchRefResponse) error {
+	reader, _, err := s.host.Controllers().RefObjectReader(args.RefID)
+	if err != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+		defer cancel()
+
+		s.host.FetchRef(ctx, args.RefID)
+		reader, _, err = s.host.Controllers().RefObjectReader(args.RefID)
+	}
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	resp.Data, err = ioutil.ReadAll(reader)
+	return errors.WithStack(err)
+}
+
+type whitelistMiddleware struct {
+	rpcWhitelist
+	nextHandler             http.Handler
+	pendingAuthorizations   map[string]struct{}
+	pendingAuthorizationsMu sync.Mutex
+}
+
+func NewWhitelistMiddleware(permittedAddrs []types.Address, nextHandler http.Handler) *whitelistMiddleware {
 	return &whitelistMiddleware{
-		permittedAddrs:        paddrs,
+		rpcWhitelist:          newRPCWhitelist(permittedAddrs),
 		nextHandler:           nextHandler,
-		jwtSecret:             jwtSecret,
 		pendingAuthorizations: make(map[string]struct{}),
 	}
 }
@@ -253,7 +537,7 @@ func (mw *whitelistMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request)
 				return
 			}
 
-			sigpubkey, err := crypto.RecoverSigningPubkey(types.HashBytes(challenge), sig)
+			jwtTokenString, err := mw.issueJWT(challenge, sig)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
 				return
@@ -261,18 +545,6 @@ func (mw *whitelistMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request)
 
 			delete(mw.pendingAuthorizations, string(challenge)) // @@TODO: expiration/garbage collection for failed auths
 
-			jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-				"address": sigpubkey.Address().Hex(),
-				"nbf":     time.Date(2015, 10, 10, 12, 0, 0, 0, time.UTC).Unix(),
-			})
-
-			// Sign and get the complete encoded token as a string using the secret
-			jwtTokenString, err := jwtToken.SignedString(mw.jwtSecret)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-
 			respondJSON(w, struct {
 				JWT string `json:"jwt"`
 			}{jwtTokenString})
@@ -287,35 +559,13 @@ func (mw *whitelistMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request)
 
 		jwtToken := strings.TrimSpace(authHeader[len("Bearer "):])
 
-		token, err := jwt.Parse(jwtToken, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
-			}
-			return mw.jwtSecret, nil
-		})
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok || !token.Valid {
-			http.Error(w, "invalid jwt token", http.StatusBadRequest)
-			return
-		}
-		addrHex, ok := claims["address"].(string)
-		if err != nil {
-			http.Error(w, "jwt does not contain 'address' claim", http.StatusBadRequest)
-			return
-		}
-		addr, err := types.AddressFromHex(addrHex)
-		if err != nil {
-			http.Error(w, "jwt 'address' claim contains invalid data", http.StatusBadRequest)
-			return
-		}
-		_, exists := mw.permittedAddrs[addr]
-		if !exists {
+		_, err := mw.checkJWT(jwtToken)
+		if errors.Cause(err) == types.Err403 {
 			http.Error(w, "nope", http.StatusForbidden)
 			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
 
 		mw.nextHandler.ServeHTTP(w, r)
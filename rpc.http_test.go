@@ -0,0 +1,188 @@
+package redwood_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"redwood.dev"
+	"redwood.dev/query"
+	"redwood.dev/types"
+)
+
+func setupHTTPRPC(t *testing.T) (redwood.Host, *redwood.HTTPRPCServer, *http.Request, func()) {
+	t.Helper()
+
+	h, _, cleanupHost := setupWebRTCHost(t, false)
+	req := httptest.NewRequest("POST", "/", nil)
+	return h, redwood.NewHTTPRPCServer(h), req, cleanupHost
+}
+
+func TestHTTPRPC_StatesAndHistory(t *testing.T) {
+	h, server, req, cleanup := setupHTTPRPC(t)
+	defer cleanup()
+
+	stateURI := "foo.com/bar"
+	tx1 := redwood.Tx{
+		StateURI:   stateURI,
+		ID:         redwood.GenesisTxID,
+		Checkpoint: true,
+		Patches:    []redwood.Patch{mustParsePatch(t, `. = {"count": 1, "text": "hello"}`)},
+	}
+	tx2 := redwood.Tx{
+		StateURI: stateURI,
+		ID:       types.RandomID(),
+		Parents:  []types.ID{redwood.GenesisTxID},
+		Patches:  []redwood.Patch{mustParsePatch(t, `.count = 2`)},
+	}
+	for _, tx := range []redwood.Tx{tx1, tx2} {
+		err := server.SendTx(req, &redwood.RPCSendTxArgs{Tx: tx}, &redwood.RPCSendTxResponse{})
+		require.NoError(t, err)
+		txID := tx.ID
+		require.Eventually(t, func() bool {
+			have, err := h.Controllers().HaveTx(stateURI, txID)
+			return err == nil && have
+		}, 10*time.Second, 50*time.Millisecond)
+	}
+
+	var stateResp redwood.RPCStateAtVersionResponse
+	err := server.StateAtVersion(req, &redwood.RPCStateAtVersionArgs{StateURI: stateURI, Keypath: "count"}, &stateResp)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, stateResp.State)
+
+	stateResp = redwood.RPCStateAtVersionResponse{}
+	err = server.StateAtVersion(req, &redwood.RPCStateAtVersionArgs{StateURI: stateURI, Version: &redwood.GenesisTxID}, &stateResp)
+	require.NoError(t, err)
+	bs, err := json.Marshal(stateResp.State)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"count": 1, "text": "hello"}`, string(bs))
+
+	err = server.StateAtVersion(req, &redwood.RPCStateAtVersionArgs{StateURI: stateURI, Keypath: "nope"}, &redwood.RPCStateAtVersionResponse{})
+	require.Equal(t, types.Err404, errors.Cause(err))
+
+	stateResp = redwood.RPCStateAtVersionResponse{}
+	err = server.StateAtVersion(req, &redwood.RPCStateAtVersionArgs{StateURI: stateURI, Query: `{count, greeting: .text}`}, &stateResp)
+	require.NoError(t, err)
+	bs, err = json.Marshal(stateResp.State)
+	require.NoError(t, err)
+	require.JSONEq(t, `[{"count": 2, "greeting": "hello"}]`, string(bs))
+
+	err = server.StateAtVersion(req, &redwood.RPCStateAtVersionArgs{StateURI: stateURI, Query: `select(`}, &redwood.RPCStateAtVersionResponse{})
+	require.Equal(t, query.ErrInvalidQuery, errors.Cause(err))
+
+	var historyResp redwood.RPCFetchHistoryResponse
+	err = server.FetchHistory(req, &redwood.RPCFetchHistoryArgs{StateURI: stateURI}, &historyResp)
+	require.NoError(t, err)
+	require.Len(t, historyResp.Txs, 2)
+	require.Equal(t, tx1.ID, historyResp.Txs[0].ID)
+	require.Equal(t, tx2.ID, historyResp.Txs[1].ID)
+
+	historyResp = redwood.RPCFetchHistoryResponse{}
+	err = server.FetchHistory(req, &redwood.RPCFetchHistoryArgs{
+		StateURI: stateURI,
+		Opts:     redwood.FetchHistoryOpts{SinceLeaves: []types.ID{redwood.GenesisTxID}},
+	}, &historyResp)
+	require.NoError(t, err)
+	require.Len(t, historyResp.Txs, 1)
+	require.Equal(t, tx2.ID, historyResp.Txs[0].ID)
+}
+
+func TestHTTPRPC_Peers(t *testing.T) {
+	h, server, req, cleanup := setupHTTPRPC(t)
+	defer cleanup()
+
+	dialInfo1 := redwood.PeerDialInfo{TransportName: "http", DialAddr: "http://asdf.dev:1234"}
+	dialInfo2 := redwood.PeerDialInfo{TransportName: "http", DialAddr: "http://xyzzy.dev:1234"}
+	addPeers := func() {
+		for _, dialInfo := range []redwood.PeerDialInfo{dialInfo1, dialInfo2} {
+			err := server.AddPeer(req, &redwood.RPCAddPeerArgs{TransportName: dialInfo.TransportName, DialAddr: dialInfo.DialAddr}, &redwood.RPCAddPeerResponse{})
+			require.NoError(t, err)
+		}
+	}
+	addPeers()
+
+	var peersResp redwood.RPCPeersResponse
+	err := server.Peers(req, &redwood.RPCPeersArgs{}, &peersResp)
+	require.NoError(t, err)
+	require.Len(t, peersResp.Peers, 2)
+
+	err = server.RemovePeer(req, &redwood.RPCRemovePeerArgs{TransportName: dialInfo1.TransportName, DialAddr: dialInfo1.DialAddr}, &redwood.RPCRemovePeerResponse{})
+	require.NoError(t, err)
+	require.False(t, redwood.PeerStoreOf(h).IsKnownPeer(dialInfo1))
+
+	err = server.BanPeer(req, &redwood.RPCBanPeerArgs{TransportName: dialInfo2.TransportName, DialAddr: dialInfo2.DialAddr}, &redwood.RPCBanPeerResponse{})
+	require.NoError(t, err)
+	require.False(t, redwood.PeerStoreOf(h).IsKnownPeer(dialInfo2))
+
+	// Removed peers can be added again, banned peers can't
+	addPeers()
+
+	peersResp = redwood.RPCPeersResponse{}
+	err = server.Peers(req, &redwood.RPCPeersArgs{}, &peersResp)
+	require.NoError(t, err)
+	require.Len(t, peersResp.Peers, 1)
+	require.Equal(t, dialInfo1.DialAddr, peersResp.Peers[0].DialAddr)
+}
+
+func TestHTTPRPC_Refs(t *testing.T) {
+	_, server, req, cleanup := setupHTTPRPC(t)
+	defer cleanup()
+
+	data := []byte("redwood redwood redwood")
+
+	var storeResp redwood.RPCStoreRefResponse
+	err := server.StoreRef(req, &redwood.RPCStoreRefArgs{Data: data}, &storeResp)
+	require.NoError(t, err)
+
+	var fetchResp redwood.RPCFetchRefResponse
+	err = server.FetchRef(req, &redwood.RPCFetchRefArgs{RefID: types.RefID{HashAlg: types.SHA3, Hash: storeResp.SHA3}}, &fetchResp)
+	require.NoError(t, err)
+	require.Equal(t, data, fetchResp.Data)
+
+	fetchResp = redwood.RPCFetchRefResponse{}
+	err = server.FetchRef(req, &redwood.RPCFetchRefArgs{RefID: types.RefID{HashAlg: types.SHA1, Hash: storeResp.SHA1}}, &fetchResp)
+	require.NoError(t, err)
+	require.Equal(t, data, fetchResp.Data)
+}
+
+func TestHTTPRPC_MempoolStats(t *testing.T) {
+	h, server, req, cleanup := setupHTTPRPC(t)
+	defer cleanup()
+
+	stateURI := "foo.com/bar"
+	err := server.SendTx(req, &redwood.RPCSendTxArgs{Tx: redwood.Tx{
+		StateURI: stateURI,
+		ID:       redwood.GenesisTxID,
+		Patches:  []redwood.Patch{mustParsePatch(t, `.count = 1`)},
+	}}, &redwood.RPCSendTxResponse{})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		have, err := h.Controllers().HaveTx(stateURI, redwood.GenesisTxID)
+		return err == nil && have
+	}, 10*time.Second, 50*time.Millisecond)
+
+	// A tx whose parent nobody has waits in the mempool
+	missingParent := types.RandomID()
+	err = server.SendTx(req, &redwood.RPCSendTxArgs{Tx: redwood.Tx{
+		StateURI: stateURI,
+		ID:       types.RandomID(),
+		Parents:  []types.ID{missingParent},
+		Patches:  []redwood.Patch{mustParsePatch(t, `.count = 2`)},
+	}}, &redwood.RPCSendTxResponse{})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		var resp redwood.RPCMempoolStatsResponse
+		err := server.MempoolStats(req, &redwood.RPCMempoolStatsArgs{StateURI: stateURI}, &resp)
+		require.NoError(t, err)
+		return resp.Stats.NumTxs == 1 && resp.Stats.MissingParents[missingParent] == 1
+	}, 10*time.Second, 50*time.Millisecond)
+
+	err = server.MempoolStats(req, &redwood.RPCMempoolStatsArgs{}, &redwood.RPCMempoolStatsResponse{})
+	require.Error(t, err)
+}
@@ -0,0 +1,95 @@
+package redwood
+
+import (
+	"crypto/rand"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+
+	"redwood.dev/crypto"
+	"redwood.dev/types"
+)
+
+type WhitelistConfig struct {
+	Enabled        bool            `yaml:"Enabled"`
+	PermittedAddrs []types.Address `yaml:"PermittedAddrs"`
+}
+
+// rpcWhitelist is the part of the RPC whitelist that the HTTP and gRPC servers
+// share.  A client proves that it controls an address by signing a challenge,
+// and receives a JWT that names the address.  After that, only JWTs that name
+// one of the permitted addresses are accepted.
+type rpcWhitelist struct {
+	permittedAddrs map[types.Address]struct{}
+	jwtSecret      []byte
+}
+
+func newRPCWhitelist(permittedAddrs []types.Address) rpcWhitelist {
+	jwtSecret := make([]byte, 64)
+	_, err := rand.Read(jwtSecret)
+	if err != nil {
+		panic(err)
+	}
+	paddrs := make(map[types.Address]struct{}, len(permittedAddrs))
+	for _, addr := range permittedAddrs {
+		paddrs[addr] = struct{}{}
+	}
+	return rpcWhitelist{
+		permittedAddrs: paddrs,
+		jwtSecret:      jwtSecret,
+	}
+}
+
+// issueJWT returns a JWT for the address that signed the challenge.  Anyone
+// can get one, because checkJWT is what enforces the whitelist.
+func (wl rpcWhitelist) issueJWT(challenge, sig []byte) (string, error) {
+	sigpubkey, err := crypto.RecoverSigningPubkey(types.HashBytes(challenge), sig)
+	if err != nil {
+		return "", err
+	}
+
+	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"address": sigpubkey.Address().Hex(),
+		"nbf":     time.Date(2015, 10, 10, 12, 0, 0, 0, time.UTC).Unix(),
+	})
+
+	// Sign and get the complete encoded token as a string using the secret
+	jwtTokenString, err := jwtToken.SignedString(wl.jwtSecret)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return jwtTokenString, nil
+}
+
+// checkJWT returns the address named by a JWT that we issued.  If the address
+// isn't permitted, it returns types.Err403, and any other error means that the
+// JWT itself is bad.
+func (wl rpcWhitelist) checkJWT(jwtToken string) (types.Address, error) {
+	token, err := jwt.Parse(jwtToken, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return wl.jwtSecret, nil
+	})
+	if err != nil {
+		return types.Address{}, err
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return types.Address{}, errors.New("invalid jwt token")
+	}
+	addrHex, ok := claims["address"].(string)
+	if !ok {
+		return types.Address{}, errors.New("jwt does not contain 'address' claim")
+	}
+	addr, err := types.AddressFromHex(addrHex)
+	if err != nil {
+		return types.Address{}, errors.New("jwt 'address' claim contains invalid data")
+	}
+	_, exists := wl.permittedAddrs[addr]
+	if !exists {
+		return types.Address{}, errors.WithStack(types.Err403)
+	}
+	return addr, nil
+}
@@ -0,0 +1,109 @@
+package redwood
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"redwood.dev/crypto"
+	"redwood.dev/types"
+)
+
+// A Checkpoint records the txs that formed the leaves of a state URI's DAG at
+// the moment a checkpoint tx was applied.  The state as of that moment is kept
+// in the versioned state DB under the checkpoint tx's ID.
+type Checkpoint struct {
+	TxID     types.ID   `json:"txID"`
+	Frontier []types.ID `json:"frontier"`
+	Created  time.Time  `json:"created"`
+}
+
+// A Snapshot is a signed copy of a state tree as of a checkpoint tx, along with
+// the txs that made up the DAG frontier at that point.  A new node can import
+// a snapshot instead of replaying the state URI's entire history, and then only
+// needs the txs that came after it.  `Leaves` are the exporting node's leaves
+// at the time of export, which tell the importer which txs to fetch to catch up.
+//
+// Nothing in a snapshot can be checked against the history it summarizes, so
+// it's only as trustworthy as the node that signed it.
+type Snapshot struct {
+	StateURI     string          `json:"stateURI"`
+	CheckpointID types.ID        `json:"checkpointID"`
+	Frontier     []*Tx           `json:"frontier"`
+	State        json.RawMessage `json:"state"`
+	Leaves       []types.ID      `json:"leaves"`
+	From         types.Address   `json:"from"`
+	Sig          types.Signature `json:"sig,omitempty"`
+}
+
+var (
+	ErrInvalidSnapshot     = errors.New("invalid snapshot")
+	ErrSnapshotUnnecessary = errors.New("state URI already has history")
+)
+
+func (s Snapshot) Hash() types.Hash {
+	var bs []byte
+	bs = append(bs, []byte("redwood snapshot\n")...)
+	bs = append(bs, []byte(s.StateURI)...)
+	bs = append(bs, s.CheckpointID[:]...)
+	for _, tx := range s.Frontier {
+		txHash := tx.Hash()
+		bs = append(bs, txHash[:]...)
+	}
+	stateHash := types.HashBytes(s.State)
+	bs = append(bs, stateHash[:]...)
+	for _, txID := range s.Leaves {
+		bs = append(bs, txID[:]...)
+	}
+	return types.HashBytes(bs)
+}
+
+// VerifySignature returns true if the snapshot was signed by its `From` address.
+func (s Snapshot) VerifySignature() bool {
+	if len(s.Sig) == 0 {
+		return false
+	}
+	pubkey, err := crypto.RecoverSigningPubkey(s.Hash(), s.Sig)
+	if err != nil {
+		return false
+	}
+	return pubkey.Address() == s.From && pubkey.VerifySignature(s.Hash(), s.Sig)
+}
+
+// Validate checks the snapshot's signature and makes sure that its frontier
+// is made of properly signed txs that include the checkpoint tx itself.
+func (s Snapshot) Validate() error {
+	if !s.VerifySignature() {
+		return errors.Wrap(ErrInvalidSnapshot, "bad signature")
+	} else if len(s.State) == 0 {
+		return errors.Wrap(ErrInvalidSnapshot, "missing state")
+	}
+
+	var foundCheckpoint bool
+	for _, tx := range s.Frontier {
+		if tx == nil {
+			return errors.Wrap(ErrInvalidSnapshot, "nil tx in frontier")
+		} else if tx.StateURI != s.StateURI {
+			return errors.Wrapf(ErrInvalidSnapshot, "frontier tx %v has the wrong state URI", tx.ID.Pretty())
+		}
+
+		sigPubKey, err := crypto.RecoverSigningPubkey(tx.Hash(), tx.Sig)
+		if err != nil {
+			return errors.Wrapf(ErrInvalidSnapshot, "frontier tx %v: %v", tx.ID.Pretty(), err)
+		} else if sigPubKey.Address() != tx.From || !sigPubKey.VerifySignature(tx.Hash(), tx.Sig) {
+			return errors.Wrapf(ErrInvalidSnapshot, "frontier tx %v has a bad signature", tx.ID.Pretty())
+		}
+
+		if tx.ID == s.CheckpointID {
+			if !tx.Checkpoint {
+				return errors.Wrapf(ErrInvalidSnapshot, "tx %v is not a checkpoint", tx.ID.Pretty())
+			}
+			foundCheckpoint = true
+		}
+	}
+	if !foundCheckpoint {
+		return errors.Wrap(ErrInvalidSnapshot, "checkpoint tx is not in the frontier")
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package redwood_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"redwood.dev"
+	"redwood.dev/crypto"
+	"redwood.dev/tree"
+	"redwood.dev/types"
+)
+
+func setupControllerHub(t *testing.T) (redwood.ControllerHub, redwood.RefStore, <-chan types.ID, func()) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "controller-hub-test-")
+	require.NoError(t, err)
+
+	txStore := redwood.NewBadgerTxStore(dir + "/txs")
+	err = txStore.Start()
+	require.NoError(t, err)
+
+	err = os.MkdirAll(dir+"/refs", 0777)
+	require.NoError(t, err)
+	refStore := redwood.NewRefStore(dir + "/refs")
+	err = refStore.Start()
+	require.NoError(t, err)
+
+	hub := redwood.NewControllerHub(dir, tree.DefaultKVEngine, txStore, refStore)
+	err = hub.Start()
+	require.NoError(t, err)
+
+	chNewState := make(chan types.ID, 10)
+	hub.OnNewState(func(tx *redwood.Tx, state tree.Node, leaves []types.ID) {
+		chNewState <- tx.ID
+	})
+
+	return hub, refStore, chNewState, func() {
+		hub.Close()
+		refStore.Close()
+		txStore.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestSnapshot_ExportImport(t *testing.T) {
+	signer, err := crypto.GenerateSigningKeypair()
+	require.NoError(t, err)
+
+	stateURI := "foo.com/bar"
+
+	mustSignTx := func(tx *redwood.Tx) *redwood.Tx {
+		t.Helper()
+		tx.StateURI = stateURI
+		tx.From = signer.Address()
+		sig, err := signer.SignHash(tx.Hash())
+		require.NoError(t, err)
+		tx.Sig = sig
+		return tx
+	}
+
+	waitForState := func(ch <-chan types.ID, txID types.ID) {
+		t.Helper()
+		for {
+			select {
+			case id := <-ch:
+				if id == txID {
+					return
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("timed out waiting for tx %v", txID.Pretty())
+			}
+		}
+	}
+
+	genesis := mustSignTx(&redwood.Tx{ID: redwood.GenesisTxID, Patches: mustParsePatches(t, `.foo = "bar"`)})
+	checkpoint := mustSignTx(&redwood.Tx{ID: types.RandomID(), Parents: []types.ID{genesis.ID}, Checkpoint: true, Patches: mustParsePatches(t, `.count = 1`)})
+	after := mustSignTx(&redwood.Tx{ID: types.RandomID(), Parents: []types.ID{checkpoint.ID}, Patches: mustParsePatches(t, `.after = true`)})
+
+	hubA, _, chNewStateA, cleanupA := setupControllerHub(t)
+	defer cleanupA()
+
+	for _, tx := range []*redwood.Tx{genesis, checkpoint, after} {
+		txCopy := *tx
+		require.NoError(t, hubA.AddTx(&txCopy, false))
+		waitForState(chNewStateA, tx.ID)
+	}
+
+	snapshot, err := hubA.ExportSnapshot(stateURI, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, checkpoint.ID, snapshot.CheckpointID)
+	require.Len(t, snapshot.Frontier, 1)
+	require.JSONEq(t, `{"foo": "bar", "count": 1}`, string(snapshot.State))
+
+	// Unsigned snapshots are rejected
+	hubB, _, chNewStateB, cleanupB := setupControllerHub(t)
+	defer cleanupB()
+
+	err = hubB.ImportSnapshot(snapshot)
+	require.Equal(t, redwood.ErrInvalidSnapshot, errors.Cause(err))
+
+	snapshot.From = signer.Address()
+	snapshot.Sig, err = signer.SignHash(snapshot.Hash())
+	require.NoError(t, err)
+
+	// So are snapshots that have been tampered with
+	tampered := *snapshot
+	tampered.State = []byte(`{"foo": "baz", "count": 1}`)
+	err = hubB.ImportSnapshot(&tampered)
+	require.Equal(t, redwood.ErrInvalidSnapshot, errors.Cause(err))
+
+	err = hubB.ImportSnapshot(snapshot)
+	require.NoError(t, err)
+	waitForState(chNewStateB, checkpoint.ID)
+
+	leaves, err := hubB.Leaves(stateURI)
+	require.NoError(t, err)
+	require.Equal(t, []types.ID{checkpoint.ID}, leaves)
+
+	state, err := hubB.StateAtVersion(stateURI, nil)
+	require.NoError(t, err)
+	foo, _, err := state.StringValue(tree.Keypath("foo"))
+	require.NoError(t, err)
+	require.Equal(t, "bar", foo)
+	state.Close()
+
+	// Txs after the checkpoint apply on top of the snapshot
+	require.NoError(t, hubB.AddTx(after, false))
+	waitForState(chNewStateB, after.ID)
+
+	state, err = hubB.StateAtVersion(stateURI, nil)
+	require.NoError(t, err)
+	isAfter, _, err := state.BoolValue(tree.Keypath("after"))
+	require.NoError(t, err)
+	require.True(t, isAfter)
+	state.Close()
+
+	// The snapshot's own checkpoint version is available too
+	checkpointState, err := hubB.StateAtVersion(stateURI, &checkpoint.ID)
+	require.NoError(t, err)
+	_, exists, err := checkpointState.BoolValue(tree.Keypath("after"))
+	require.NoError(t, err)
+	require.False(t, exists)
+	checkpointState.Close()
+
+	// History from before the snapshot is never applied
+	require.NoError(t, hubB.AddTx(genesis, false))
+	require.Eventually(t, func() bool {
+		tx, err := hubB.FetchTx(stateURI, genesis.ID)
+		return err == nil && tx.Status == redwood.TxStatusInvalid
+	}, 5*time.Second, 10*time.Millisecond)
+
+	state, err = hubB.StateAtVersion(stateURI, nil)
+	require.NoError(t, err)
+	count, _, err := state.Value(tree.Keypath("count"), nil)
+	require.NoError(t, err)
+	require.Equal(t, float64(1), count)
+	state.Close()
+
+	// A node that already has history doesn't need a snapshot
+	err = hubB.ImportSnapshot(snapshot)
+	require.Equal(t, redwood.ErrSnapshotUnnecessary, errors.Cause(err))
+}
@@ -0,0 +1,83 @@
+package testutils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"redwood.dev"
+	"redwood.dev/identity"
+	"redwood.dev/tree"
+	"redwood.dev/utils"
+)
+
+// TransportsFunc builds the transports of a host started by StartHost.
+type TransportsFunc func(
+	controllerHub redwood.ControllerHub,
+	keyStore identity.KeyStore,
+	refStore redwood.RefStore,
+	peerStore redwood.PeerStore,
+) ([]redwood.Transport, error)
+
+// StartHost starts a host whose stores live in a new temporary directory.  The
+// returned function stops the host and deletes its data.
+func StartHost(t *testing.T, makeTransports TransportsFunc) (redwood.Host, identity.KeyStore, func()) {
+	t.Helper()
+
+	var closers []func()
+	cleanup := func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "redwood-test-")
+	require.NoError(t, err)
+	closers = append(closers, func() { os.RemoveAll(dir) })
+
+	txStore := redwood.NewBadgerTxStore(filepath.Join(dir, "txs"))
+	err = txStore.Start()
+	require.NoError(t, err)
+	closers = append(closers, txStore.Close)
+
+	err = os.MkdirAll(filepath.Join(dir, "refs"), 0777)
+	require.NoError(t, err)
+	refStore := redwood.NewRefStore(filepath.Join(dir, "refs"))
+	err = refStore.Start()
+	require.NoError(t, err)
+	closers = append(closers, refStore.Close)
+
+	peerDB, err := tree.NewDBTree(filepath.Join(dir, "peers"))
+	require.NoError(t, err)
+	closers = append(closers, func() { peerDB.Close() })
+	peerStore := redwood.NewPeerStore(peerDB)
+
+	keyDB, err := tree.NewDBTree(filepath.Join(dir, "keys"))
+	require.NoError(t, err)
+	closers = append(closers, func() { keyDB.Close() })
+	keyStore := identity.NewBadgerKeyStore(keyDB, identity.FastScryptParams)
+	err = keyStore.Unlock("")
+	require.NoError(t, err)
+
+	controllerHub := redwood.NewControllerHub(dir, tree.DefaultKVEngine, txStore, refStore)
+
+	transports, err := makeTransports(controllerHub, keyStore, refStore, peerStore)
+	require.NoError(t, err)
+
+	config, err := redwood.ReadConfigAtPath("redwood-test", filepath.Join(dir, ".redwoodrc"))
+	require.NoError(t, err)
+	config.Node.DataRoot = dir
+	config.Node.SubscribedStateURIs = utils.NewStringSet(nil)
+
+	h, err := redwood.NewHost(transports, controllerHub, keyStore, refStore, peerStore, config)
+	require.NoError(t, err)
+	err = h.Start()
+	require.NoError(t, err)
+
+	// Hosts must be closed before their stores
+	closers = append(closers, h.Close)
+	return h, keyStore, cleanup
+}
@@ -0,0 +1,162 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"redwood.dev"
+	"redwood.dev/identity"
+	"redwood.dev/types"
+)
+
+// Swarm is a set of hosts that run in the same process and talk to each other
+// over a redwood.LoopbackNetwork.  Tests can change the network's conditions at
+// any time and then check that the hosts still converge.
+type Swarm struct {
+	Network *redwood.LoopbackNetwork
+	Hosts   []redwood.Host
+
+	t       *testing.T
+	closers []func()
+}
+
+// NewSwarm starts numHosts hosts on a new loopback network.  The seed controls
+// every random decision that the network makes.
+func NewSwarm(t *testing.T, numHosts int, seed int64) *Swarm {
+	t.Helper()
+
+	s := &Swarm{
+		Network: redwood.NewLoopbackNetwork(seed),
+		t:       t,
+	}
+	for i := 0; i < numHosts; i++ {
+		s.Hosts = append(s.Hosts, s.startHost(s.Addr(i)))
+	}
+	return s
+}
+
+func (s *Swarm) startHost(addr string) redwood.Host {
+	t := s.t
+	t.Helper()
+
+	h, _, cleanup := StartHost(t, func(controllerHub redwood.ControllerHub, keyStore identity.KeyStore, refStore redwood.RefStore, peerStore redwood.PeerStore) ([]redwood.Transport, error) {
+		transport, err := redwood.NewLoopbackTransport(s.Network, addr, controllerHub, keyStore, refStore, peerStore)
+		if err != nil {
+			return nil, err
+		}
+		return []redwood.Transport{transport}, nil
+	})
+	s.closers = append(s.closers, cleanup)
+	return h
+}
+
+// Addr returns the loopback address of the i-th host.
+func (s *Swarm) Addr(i int) string {
+	return fmt.Sprintf("host-%v", i)
+}
+
+// Close stops every host and deletes their data.
+func (s *Swarm) Close() {
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		s.closers[i]()
+	}
+	s.closers = nil
+}
+
+// ReconcileAll has every pair of hosts that can reach each other exchange the
+// txs that the other is missing, as they would when a subscription connects.
+func (s *Swarm) ReconcileAll(stateURI string) {
+	t := s.t
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for i, h := range s.Hosts {
+		for j := range s.Hosts {
+			if i == j {
+				continue
+			}
+			peer, err := h.Transport("loopback").NewPeerConn(ctx, s.Addr(j))
+			require.NoError(t, err)
+
+			err = h.ReconcileWithPeer(ctx, stateURI, peer)
+			if errors.Cause(err) == types.ErrConnection {
+				// Partitioned
+				continue
+			}
+			require.NoError(t, err)
+		}
+	}
+}
+
+// RequireConverged fails the test unless every host ends up with the same
+// leaves and the same state for the given state URI before the timeout.
+func (s *Swarm) RequireConverged(stateURI string, timeout time.Duration) {
+	t := s.t
+	t.Helper()
+
+	var leaves [][]types.ID
+	var states []interface{}
+	converged := func() bool {
+		leaves = make([][]types.ID, len(s.Hosts))
+		states = make([]interface{}, len(s.Hosts))
+		for i, h := range s.Hosts {
+			hostLeaves, err := h.Controllers().Leaves(stateURI)
+			if err != nil {
+				return false
+			}
+			sort.Slice(hostLeaves, func(a, b int) bool { return hostLeaves[a].Hex() < hostLeaves[b].Hex() })
+			leaves[i] = hostLeaves
+
+			state, err := h.StateAtVersion(stateURI, nil)
+			if err != nil {
+				return false
+			}
+			val, _, err := state.Value(nil, nil)
+			state.Close()
+			if err != nil {
+				return false
+			}
+			states[i] = val
+		}
+
+		for i := 1; i < len(s.Hosts); i++ {
+			if !equalIDs(leaves[0], leaves[i]) || !reflect.DeepEqual(states[0], states[i]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	deadline := time.Now().Add(timeout)
+	for !converged() {
+		if time.Now().After(deadline) {
+			for i := 1; i < len(s.Hosts); i++ {
+				require.Equal(t, leaves[0], leaves[i], "leaves of %v and %v differ", s.Addr(0), s.Addr(i))
+				require.Equal(t, states[0], states[i], "states of %v and %v differ", s.Addr(0), s.Addr(i))
+			}
+			require.FailNow(t, "hosts did not converge")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func equalIDs(a, b []types.ID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
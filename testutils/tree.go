@@ -10,11 +10,15 @@ import (
 	"redwood.dev/tree"
 )
 
+// TreeKVEngine is the storage engine used by the DBTrees and VersionedDBTrees
+// that this package sets up.
+var TreeKVEngine = tree.DefaultKVEngine
+
 func SetupDBTree(t *testing.T) *tree.DBTree {
 	t.Helper()
 
 	i := rand.Int()
-	db, err := tree.NewDBTree(fmt.Sprintf("/tmp/tree-badger-test-%v", i))
+	db, err := tree.NewDBTreeWithEngine(TreeKVEngine, fmt.Sprintf("/tmp/tree-badger-test-%v", i))
 	require.NoError(t, err)
 	return db
 }
@@ -24,7 +28,7 @@ func SetupDBTreeWithValue(t *testing.T, keypath tree.Keypath, val interface{}) *
 
 	i := rand.Int()
 
-	db, err := tree.NewDBTree(fmt.Sprintf("/tmp/tree-badger-test-%v", i))
+	db, err := tree.NewDBTreeWithEngine(TreeKVEngine, fmt.Sprintf("/tmp/tree-badger-test-%v", i))
 	require.NoError(t, err)
 
 	state := db.State(true)
@@ -40,7 +44,7 @@ func SetupVersionedDBTree(t *testing.T) *tree.VersionedDBTree {
 	t.Helper()
 
 	i := rand.Int()
-	db, err := tree.NewVersionedDBTree(fmt.Sprintf("/tmp/tree-badger-test-%v", i))
+	db, err := tree.NewVersionedDBTreeWithEngine(TreeKVEngine, fmt.Sprintf("/tmp/tree-badger-test-%v", i))
 	require.NoError(t, err)
 	return db
 }
@@ -50,7 +54,7 @@ func SetupVersionedDBTreeWithValue(t *testing.T, keypath tree.Keypath, val inter
 
 	i := rand.Int()
 
-	db, err := tree.NewVersionedDBTree(fmt.Sprintf("/tmp/tree-badger-test-%v", i))
+	db, err := tree.NewVersionedDBTreeWithEngine(TreeKVEngine, fmt.Sprintf("/tmp/tree-badger-test-%v", i))
 	require.NoError(t, err)
 
 	state := db.StateAtVersion(nil, true)
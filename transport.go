@@ -33,6 +33,9 @@ type Peer interface {
 
 	// Transactions
 	Subscribe(ctx context.Context, stateURI string) (ReadableSubscription, error)
+	FetchHistory(ctx context.Context, stateURI string, opts FetchHistoryOpts) (ReadableSubscription, error)
+	FetchSnapshot(ctx context.Context, stateURI string) (*Snapshot, error)
+	Reconcile(ctx context.Context, req ReconcileRequest) (ReconcileResponse, error)
 	Put(ctx context.Context, tx *Tx, state tree.Node, leaves []types.ID) error
 	Ack(stateURI string, txID types.ID) error
 
@@ -32,6 +32,7 @@ import (
 	"redwood.dev/ctx"
 	"redwood.dev/identity"
 	"redwood.dev/nelson"
+	"redwood.dev/query"
 	"redwood.dev/tree"
 	"redwood.dev/types"
 	"redwood.dev/utils"
@@ -263,15 +264,21 @@ func (t *httpTransport) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				// @@TODO: this is hacky
 				t.serveBraidJS(w, r)
 			} else if strings.HasPrefix(r.URL.Path, "/__tx/") {
-				t.serveGetTx(w, r)
+				t.serveGetTx(w, r, address)
+			} else if r.URL.Path == "/__snapshot" {
+				t.serveGetSnapshot(w, r, address)
 			} else {
-				t.serveGetState(w, r)
+				t.serveGetState(w, r, address)
 			}
 		}
 
 	case "POST":
 		if r.Header.Get("Ref") == "true" {
 			t.servePostRef(w, r)
+		} else if r.URL.Path == "/__reconcile" {
+			t.servePostReconcile(w, r, address)
+		} else if r.URL.Path == "/__webrtc" {
+			t.servePostWebRTCOffer(w, r)
 		}
 
 	case "ACK":
@@ -401,14 +408,10 @@ func (t *httpTransport) serveSubscription(w http.ResponseWriter, r *http.Request
 
 	f.Flush()
 
-	var fetchHistoryOpts *FetchHistoryOpts
-	if fromTxHeader := r.Header.Get("From-Tx"); fromTxHeader != "" {
-		fromTxID, err := types.IDFromHex(fromTxHeader)
-		if err != nil {
-			http.Error(w, "could not parse From-Tx header", http.StatusBadRequest)
-			return
-		}
-		fetchHistoryOpts = &FetchHistoryOpts{FromTxID: fromTxID}
+	fetchHistoryOpts, err := fetchHistoryOptsFromHeaders(r.Header)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
 	writeSub := newWritableSubscription(t.host, stateURI, tree.Keypath(keypath), subscriptionType, httpWriteSub)
@@ -418,6 +421,103 @@ func (t *httpTransport) serveSubscription(w http.ResponseWriter, r *http.Request
 	<-writeSub.chDone
 }
 
+const fromTxLeavesPrefix = "leaves="
+
+// fetchHistoryOptsFromHeaders parses the history request (if any) made by a
+// subscriber.  The From-Tx header contains either a single tx ID, or
+// "leaves=" followed by a comma-separated list of the subscriber's leaves, in
+// which case only the txs it's missing are sent.  To-Tx and Max-Txs further
+// bound the txs that are sent.  Fetch-Txs requests specific txs by ID.
+func fetchHistoryOptsFromHeaders(header http.Header) (*FetchHistoryOpts, error) {
+	if fetchTxsHeader := header.Get("Fetch-Txs"); fetchTxsHeader != "" {
+		txIDs, err := parseTxIDList(fetchTxsHeader)
+		if err != nil {
+			return nil, errors.New("could not parse Fetch-Txs header")
+		}
+		return &FetchHistoryOpts{TxIDs: txIDs}, nil
+	}
+
+	fromTxHeader := header.Get("From-Tx")
+	if fromTxHeader == "" {
+		return nil, nil
+	}
+
+	var opts FetchHistoryOpts
+	if strings.HasPrefix(fromTxHeader, fromTxLeavesPrefix) {
+		leaves, err := parseTxIDList(fromTxHeader[len(fromTxLeavesPrefix):])
+		if err != nil {
+			return nil, errors.New("could not parse From-Tx header")
+		}
+		opts.SinceLeaves = leaves
+	} else {
+		fromTxID, err := types.IDFromHex(fromTxHeader)
+		if err != nil {
+			return nil, errors.New("could not parse From-Tx header")
+		}
+		opts.FromTxID = fromTxID
+	}
+
+	if toTxHeader := header.Get("To-Tx"); toTxHeader != "" {
+		toTxID, err := types.IDFromHex(toTxHeader)
+		if err != nil {
+			return nil, errors.New("could not parse To-Tx header")
+		}
+		opts.ToTxID = toTxID
+	}
+
+	if maxTxsHeader := header.Get("Max-Txs"); maxTxsHeader != "" {
+		limit, err := strconv.ParseUint(maxTxsHeader, 10, 64)
+		if err != nil {
+			return nil, errors.New("could not parse Max-Txs header")
+		}
+		opts.Limit = limit
+	}
+	return &opts, nil
+}
+
+func setFetchHistoryOptsHeaders(header http.Header, opts FetchHistoryOpts) {
+	if len(opts.TxIDs) > 0 {
+		header.Set("Fetch-Txs", formatTxIDList(opts.TxIDs))
+		return
+	}
+
+	if len(opts.SinceLeaves) > 0 {
+		header.Set("From-Tx", fromTxLeavesPrefix+formatTxIDList(opts.SinceLeaves))
+	} else {
+		header.Set("From-Tx", opts.FromTxID.Hex())
+	}
+	if opts.ToTxID != (types.ID{}) {
+		header.Set("To-Tx", opts.ToTxID.Hex())
+	}
+	if opts.Limit > 0 {
+		header.Set("Max-Txs", strconv.FormatUint(opts.Limit, 10))
+	}
+}
+
+func parseTxIDList(s string) ([]types.ID, error) {
+	var txIDs []types.ID
+	for _, txIDStr := range strings.Split(s, ",") {
+		txIDStr = strings.TrimSpace(txIDStr)
+		if txIDStr == "" {
+			continue
+		}
+		txID, err := types.IDFromHex(txIDStr)
+		if err != nil {
+			return nil, err
+		}
+		txIDs = append(txIDs, txID)
+	}
+	return txIDs, nil
+}
+
+func formatTxIDList(txIDs []types.ID) string {
+	txIDStrs := make([]string, len(txIDs))
+	for i, txID := range txIDs {
+		txIDStrs[i] = txID.Hex()
+	}
+	return strings.Join(txIDStrs, ",")
+}
+
 func (t *httpTransport) serveBraidJS(w http.ResponseWriter, r *http.Request) {
 	f, err := pkger.Open("/braidjs/dist/braid.js")
 	if err != nil {
@@ -429,7 +529,7 @@ func (t *httpTransport) serveBraidJS(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
-func (t *httpTransport) serveGetTx(w http.ResponseWriter, r *http.Request) {
+func (t *httpTransport) serveGetTx(w http.ResponseWriter, r *http.Request, address types.Address) {
 	stateURI := r.Header.Get("State-URI")
 	if stateURI == "" {
 		http.Error(w, "missing State-URI header", http.StatusBadRequest)
@@ -450,10 +550,97 @@ func (t *httpTransport) serveGetTx(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	for _, patch := range tx.Patches {
+		err = t.controllerHub.ValidateRead(stateURI, patch.Keypath, []types.Address{address})
+		if errors.Cause(err) == types.Err403 {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		} else if err != nil {
+			http.Error(w, fmt.Sprintf("error: %+v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
 	respondJSON(w, tx)
 }
 
-func (t *httpTransport) serveGetState(w http.ResponseWriter, r *http.Request) {
+func (t *httpTransport) serveGetSnapshot(w http.ResponseWriter, r *http.Request, address types.Address) {
+	stateURI := r.Header.Get("State-URI")
+	if stateURI == "" {
+		http.Error(w, "missing State-URI header", http.StatusBadRequest)
+		return
+	}
+
+	snapshot, err := t.host.HandleFetchSnapshotRequest(stateURI, t.makePeerWithAddress(w, nil, address))
+	if errors.Cause(err) == types.Err403 {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	} else if errors.Cause(err) == types.Err404 || errors.Cause(err) == ErrNoController {
+		http.Error(w, fmt.Sprintf("not found: %v", err), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("error: %+v", err), http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, snapshot)
+}
+
+func (t *httpTransport) servePostReconcile(w http.ResponseWriter, r *http.Request, address types.Address) {
+	var req ReconcileRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("could not parse request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := t.host.HandleReconcileRequest(req, t.makePeerWithAddress(w, nil, address))
+	if errors.Cause(err) == types.Err403 {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	} else if errors.Cause(err) == ErrNoController {
+		http.Error(w, fmt.Sprintf("not found: %v", err), http.StatusNotFound)
+		return
+	} else if errors.Cause(err) == ErrProtocol {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("error: %+v", err), http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, resp)
+}
+
+// servePostWebRTCOffer relays a WebRTC offer to the webrtc transport, which
+// identifies the peer itself once the connection is up.
+func (t *httpTransport) servePostWebRTCOffer(w http.ResponseWriter, r *http.Request) {
+	webrtcTpt, ok := t.host.Transport("webrtc").(*webrtcTransport)
+	if !ok {
+		http.Error(w, "webrtc transport is not enabled", http.StatusNotFound)
+		return
+	}
+
+	var signal webrtcSignal
+	err := json.NewDecoder(r.Body).Decode(&signal)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("could not parse request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	answer, err := webrtcTpt.handleOffer(r.Context(), signal)
+	if errors.Cause(err) == ErrProtocol {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("error: %+v", err), http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, answer)
+}
+
+func (t *httpTransport) serveGetState(w http.ResponseWriter, r *http.Request, address types.Address) {
 
 	keypathStrs := filterEmptyStrings(strings.Split(r.URL.Path[1:], "/"))
 
@@ -554,6 +741,21 @@ func (t *httpTransport) serveGetState(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	requesters := []types.Address{address}
+	requestedKeypath := keypath
+
+	err := t.controllerHub.ValidateRead(stateURI, keypath, requesters)
+	if errors.Cause(err) == types.Err403 {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	} else if errors.Cause(err) == ErrNoController {
+		http.Error(w, fmt.Sprintf("not found: %+v", err), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("error: %+v", err), http.StatusInternalServerError)
+		return
+	}
+
 	indexName, indexArg := parseIndexParams(r)
 	raw, err := parseRawParam(r)
 	if err != nil {
@@ -561,22 +763,44 @@ func (t *httpTransport) serveGetState(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if queryStr := r.URL.Query().Get("query"); queryStr != "" {
+		t.serveStateQuery(w, stateURI, version, keypath, requesters, queryStr)
+		return
+	}
+
 	var state tree.Node
 	var anyMissing bool
 
 	if indexName != "" {
 		// Index query
 
-		// You can specify an index_arg of * in order to fetch the entire index
+		// You can specify an index_arg of * in order to fetch the entire index.  An
+		// "indexer/sorted" index takes a JSON SortedIndexQuery instead, and an
+		// "indexer/fulltext" index takes words to look for in the search param.
 		var indexArgKeypath tree.Keypath
 		if indexArg != "*" {
 			indexArgKeypath = tree.Keypath(indexArg)
 		}
 
+		// Index entries don't record which node they were copied from, so they
+		// can't be redacted one by one.  Only requesters who may read everything
+		// that was indexed may query the index.
+		err = t.controllerHub.ValidateReadSubtree(stateURI, keypath, requesters)
+		if errors.Cause(err) == types.Err403 {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		} else if err != nil {
+			http.Error(w, fmt.Sprintf("error: %+v", err), http.StatusInternalServerError)
+			return
+		}
+
 		state, err = t.controllerHub.QueryIndex(stateURI, version, keypath, tree.Keypath(indexName), indexArgKeypath, rng)
 		if errors.Cause(err) == types.Err404 {
 			http.Error(w, fmt.Sprintf("not found: %+v", err), http.StatusNotFound)
 			return
+		} else if errors.Cause(err) == ErrInvalidIndexQuery {
+			http.Error(w, fmt.Sprintf("bad request: %+v", err), http.StatusBadRequest)
+			return
 		} else if err != nil {
 			http.Error(w, fmt.Sprintf("error: %+v", err), http.StatusInternalServerError)
 			return
@@ -592,7 +816,20 @@ func (t *httpTransport) serveGetState(w http.ResponseWriter, r *http.Request) {
 		defer state.Close()
 
 		if raw {
-			state = state.NodeAt(keypath, rng)
+			state, err = state.CopyToMemory(keypath, rng)
+			if errors.Cause(err) == types.Err404 {
+				http.Error(w, fmt.Sprintf("not found: %+v", err), http.StatusNotFound)
+				return
+			} else if err != nil {
+				http.Error(w, fmt.Sprintf("error: %+v", err), http.StatusInternalServerError)
+				return
+			}
+
+			err = t.controllerHub.RedactUnreadable(stateURI, state, requestedKeypath, requesters)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("error: %+v", err), http.StatusInternalServerError)
+				return
+			}
 
 		} else {
 			var exists bool
@@ -617,6 +854,12 @@ func (t *httpTransport) serveGetState(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
+			err = t.controllerHub.RedactUnreadable(stateURI, state, requestedKeypath, requesters)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("error: %+v", err), http.StatusInternalServerError)
+				return
+			}
+
 			state, anyMissing, err = nelson.Resolve(state, t.controllerHub)
 			if err != nil {
 				http.Error(w, fmt.Sprintf("error: %+v", err), http.StatusInternalServerError)
@@ -707,6 +950,39 @@ func (t *httpTransport) serveGetState(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// serveStateQuery evaluates a query (see the query package) against the raw
+// state at keypath, and responds with a JSON list of its results.
+func (t *httpTransport) serveStateQuery(w http.ResponseWriter, stateURI string, version *types.ID, keypath tree.Keypath, requesters []types.Address, queryStr string) {
+	q, err := query.Parse(queryStr)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("bad request: %+v", err), http.StatusBadRequest)
+		return
+	}
+
+	state, err := t.controllerHub.StateAtVersion(stateURI, version)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("not found: %+v", err), http.StatusNotFound)
+		return
+	}
+	defer state.Close()
+
+	results, err := q.Evaluate(state.NodeAt(keypath, nil), func(node tree.Node, keypath tree.Keypath) error {
+		err := t.controllerHub.ValidateRead(stateURI, keypath, requesters)
+		if err != nil {
+			return err
+		}
+		return t.controllerHub.RedactUnreadable(stateURI, node, keypath, requesters)
+	})
+	if errors.Cause(err) == types.Err403 {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("error: %+v", err), http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, results)
+}
+
 func (t *httpTransport) serveAck(w http.ResponseWriter, r *http.Request, address types.Address) {
 	defer r.Body.Close()
 
@@ -839,6 +1115,15 @@ func (t *httpTransport) servePostTx(w http.ResponseWriter, r *http.Request, addr
 		checkpoint = true
 	}
 
+	var timestamp int64
+	if timestampStr := r.Header.Get("Timestamp"); timestampStr != "" {
+		timestamp, err = strconv.ParseInt(timestampStr, 10, 64)
+		if err != nil {
+			http.Error(w, "bad Timestamp header", http.StatusBadRequest)
+			return
+		}
+	}
+
 	stateURI := r.Header.Get("State-URI")
 	if stateURI == "" {
 		stateURI = t.defaultStateURI
@@ -907,6 +1192,7 @@ func (t *httpTransport) servePostTx(w http.ResponseWriter, r *http.Request, addr
 		Attachment: attachment,
 		StateURI:   stateURI,
 		Checkpoint: checkpoint,
+		Timestamp:  timestamp,
 	}
 
 	// @@TODO: remove .From entirely
@@ -945,6 +1231,9 @@ func parseRawParam(r *http.Request) (bool, error) {
 func parseIndexParams(r *http.Request) (string, string) {
 	indexName := r.URL.Query().Get("index")
 	indexArg := r.URL.Query().Get("index_arg")
+	if search := r.URL.Query().Get("search"); search != "" {
+		indexArg = search
+	}
 	return indexName, indexArg
 }
 
@@ -1212,6 +1501,14 @@ func (p *httpPeer) EnsureConnected(ctx context.Context) error {
 }
 
 func (p *httpPeer) Subscribe(ctx context.Context, stateURI string) (_ ReadableSubscription, err error) {
+	return p.subscribe(ctx, stateURI, nil)
+}
+
+func (p *httpPeer) FetchHistory(ctx context.Context, stateURI string, opts FetchHistoryOpts) (_ ReadableSubscription, err error) {
+	return p.subscribe(ctx, stateURI, &opts)
+}
+
+func (p *httpPeer) subscribe(ctx context.Context, stateURI string, fetchHistoryOpts *FetchHistoryOpts) (_ ReadableSubscription, err error) {
 	defer func() { p.UpdateConnStats(err == nil) }()
 
 	if p.DialInfo().DialAddr == "" {
@@ -1233,6 +1530,10 @@ func (p *httpPeer) Subscribe(ctx context.Context, stateURI string) (_ ReadableSu
 	}
 	req.Header.Set("Subscribe", string(subTypeBytes))
 
+	if fetchHistoryOpts != nil {
+		setFetchHistoryOptsHeaders(req.Header, *fetchHistoryOpts)
+	}
+
 	var client http.Client
 	resp, err := client.Do(req)
 	if err != nil {
@@ -1247,10 +1548,89 @@ func (p *httpPeer) Subscribe(ctx context.Context, stateURI string) (_ ReadableSu
 		client:  &client,
 		peer:    p,
 		stream:  resp.Body,
+		reader:  bufio.NewReader(resp.Body),
 		private: resp.Header.Get("Private") == "true",
 	}, nil
 }
 
+func (p *httpPeer) FetchSnapshot(ctx context.Context, stateURI string) (_ *Snapshot, err error) {
+	defer func() { p.UpdateConnStats(err == nil) }()
+
+	if p.DialInfo().DialAddr == "" {
+		return nil, errors.New("peer has no DialAddr")
+	}
+
+	ctx, cancel := utils.CombinedContext(ctx, 30*time.Second, p.t.chStop)
+	defer cancel()
+
+	snapshotURL, err := url.Parse(p.DialInfo().DialAddr)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	snapshotURL.Path = "/__snapshot"
+
+	req, err := http.NewRequestWithContext(ctx, "GET", snapshotURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("State-URI", stateURI)
+
+	resp, err := p.t.doRequest(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error fetching snapshot from peer (%v) (state URI: %v)", p.DialInfo().DialAddr, stateURI)
+	}
+	defer resp.Body.Close()
+
+	var snapshot Snapshot
+	err = json.NewDecoder(resp.Body).Decode(&snapshot)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &snapshot, nil
+}
+
+func (p *httpPeer) Reconcile(ctx context.Context, req ReconcileRequest) (_ ReconcileResponse, err error) {
+	defer func() { p.UpdateConnStats(err == nil) }()
+
+	if p.DialInfo().DialAddr == "" {
+		return ReconcileResponse{}, errors.New("peer has no DialAddr")
+	}
+
+	ctx, cancel := utils.CombinedContext(ctx, 30*time.Second, p.t.chStop)
+	defer cancel()
+
+	reconcileURL, err := url.Parse(p.DialInfo().DialAddr)
+	if err != nil {
+		return ReconcileResponse{}, errors.WithStack(err)
+	}
+	reconcileURL.Path = "/__reconcile"
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return ReconcileResponse{}, errors.WithStack(err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", reconcileURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return ReconcileResponse{}, err
+	}
+	httpReq.Header.Set("State-URI", req.StateURI)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.t.doRequest(httpReq)
+	if err != nil {
+		return ReconcileResponse{}, errors.Wrapf(err, "error reconciling with peer (%v) (state URI: %v)", p.DialInfo().DialAddr, req.StateURI)
+	}
+	defer resp.Body.Close()
+
+	var reconcileResp ReconcileResponse
+	err = json.NewDecoder(resp.Body).Decode(&reconcileResp)
+	if err != nil {
+		return ReconcileResponse{}, errors.WithStack(err)
+	}
+	return reconcileResp, nil
+}
+
 func (p *httpPeer) Put(ctx context.Context, tx *Tx, state tree.Node, leaves []types.ID) (err error) {
 	defer func() { p.UpdateConnStats(err == nil) }()
 
@@ -1424,6 +1804,7 @@ func (p *httpPeer) Close() error {
 type httpReadableSubscription struct {
 	client  *http.Client
 	stream  io.ReadCloser
+	reader  *bufio.Reader
 	peer    *httpPeer
 	private bool
 }
@@ -1431,13 +1812,16 @@ type httpReadableSubscription struct {
 func (s *httpReadableSubscription) Read() (_ *SubscriptionMsg, err error) {
 	defer func() { s.peer.UpdateConnStats(err == nil) }()
 
-	r := bufio.NewReader(s.stream)
-	bs, err := r.ReadBytes(byte('\n'))
-	if err != nil {
-		return nil, err
+	// Events are separated by blank lines
+	var bs []byte
+	for len(bs) == 0 {
+		bs, err = s.reader.ReadBytes(byte('\n'))
+		if err != nil {
+			return nil, err
+		}
+		bs = bytes.TrimPrefix(bs, []byte("data: "))
+		bs = bytes.Trim(bs, "\n ")
 	}
-	bs = bytes.TrimPrefix(bs, []byte("data: "))
-	bs = bytes.Trim(bs, "\n ")
 
 	var msg SubscriptionMsg
 	err = json.Unmarshal(bs, &msg)
@@ -394,11 +394,12 @@ func (t *libp2pTransport) handleIncomingStream(stream netp2p.Stream) {
 
 	switch msg.Type {
 	case MsgType_Subscribe:
-		stateURI, ok := msg.Payload.(string)
+		payload, ok := msg.Payload.(libp2pSubscribeMsg)
 		if !ok {
 			t.Errorf("Subscribe message: bad payload: (%T) %v", msg.Payload, msg.Payload)
 			return
 		}
+		stateURI := payload.StateURI
 
 		writeSub := newWritableSubscription(t.host, stateURI, nil, SubscriptionType_Txs, &libp2pWritableSubscription{peer})
 		func() {
@@ -410,8 +411,70 @@ func (t *libp2pTransport) handleIncomingStream(stream netp2p.Stream) {
 			t.writeSubsByPeerID[peer.pinfo.ID][stream] = writeSub
 		}()
 
-		fetchHistoryOpts := &FetchHistoryOpts{} // Fetch all history (@@TODO)
-		t.host.HandleWritableSubscriptionOpened(writeSub, fetchHistoryOpts)
+		t.host.HandleWritableSubscriptionOpened(writeSub, payload.FetchHistoryOpts)
+
+	case MsgType_FetchHistory:
+		payload, ok := msg.Payload.(libp2pFetchHistoryMsg)
+		if !ok {
+			t.Errorf("FetchHistory message: bad payload: (%T) %v", msg.Payload, msg.Payload)
+			return
+		}
+
+		writeSub := newWritableSubscription(t.host, payload.StateURI, nil, SubscriptionType_Txs, &libp2pWritableSubscription{peer})
+		func() {
+			t.writeSubsByPeerIDMu.Lock()
+			defer t.writeSubsByPeerIDMu.Unlock()
+			if _, exists := t.writeSubsByPeerID[peer.pinfo.ID]; !exists {
+				t.writeSubsByPeerID[peer.pinfo.ID] = make(map[netp2p.Stream]WritableSubscription)
+			}
+			t.writeSubsByPeerID[peer.pinfo.ID][stream] = writeSub
+		}()
+
+		t.host.HandleWritableSubscriptionOpened(writeSub, &payload.Opts)
+
+	case MsgType_FetchSnapshot:
+		defer peer.Close()
+
+		stateURI, ok := msg.Payload.(string)
+		if !ok {
+			t.Errorf("FetchSnapshot message: bad payload: (%T) %v", msg.Payload, msg.Payload)
+			return
+		}
+
+		var resp libp2pFetchSnapshotResponse
+		snapshot, err := t.host.HandleFetchSnapshotRequest(stateURI, peer)
+		if err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp.Snapshot = snapshot
+		}
+
+		err = peer.writeMsg(Msg{Type: MsgType_FetchSnapshotResponse, Payload: resp})
+		if err != nil {
+			t.Errorf("error writing snapshot to peer: %v", err)
+		}
+
+	case MsgType_Reconcile:
+		defer peer.Close()
+
+		req, ok := msg.Payload.(ReconcileRequest)
+		if !ok {
+			t.Errorf("Reconcile message: bad payload: (%T) %v", msg.Payload, msg.Payload)
+			return
+		}
+
+		var resp libp2pReconcileResponse
+		reconcileResp, err := t.host.HandleReconcileRequest(req, peer)
+		if err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp.Response = &reconcileResp
+		}
+
+		err = peer.writeMsg(Msg{Type: MsgType_ReconcileResponse, Payload: resp})
+		if err != nil {
+			t.Errorf("error writing reconcile response to peer: %v", err)
+		}
 
 	case MsgType_Put:
 		defer peer.Close()
@@ -879,7 +942,16 @@ func (peer *libp2pPeer) Subscribe(ctx context.Context, stateURI string) (_ Reada
 		return nil, err
 	}
 
-	err = peer.writeMsg(Msg{Type: MsgType_Subscribe, Payload: stateURI})
+	// Only ask for the txs that we don't have yet
+	leaves, err := peer.t.host.Controllers().Leaves(stateURI)
+	if err != nil && errors.Cause(err) != ErrNoController {
+		return nil, err
+	}
+
+	err = peer.writeMsg(Msg{Type: MsgType_Subscribe, Payload: libp2pSubscribeMsg{
+		StateURI:         stateURI,
+		FetchHistoryOpts: &FetchHistoryOpts{SinceLeaves: leaves},
+	}})
 	if err != nil {
 		return nil, err
 	}
@@ -887,6 +959,113 @@ func (peer *libp2pPeer) Subscribe(ctx context.Context, stateURI string) (_ Reada
 	return &libp2pReadableSubscription{peer}, nil
 }
 
+func (peer *libp2pPeer) FetchHistory(ctx context.Context, stateURI string, opts FetchHistoryOpts) (_ ReadableSubscription, err error) {
+	defer func() { peer.UpdateConnStats(err == nil) }()
+
+	err = peer.EnsureConnected(ctx)
+	if err != nil {
+		peer.t.Errorf("error connecting to peer: %v", err)
+		return nil, err
+	}
+
+	err = peer.writeMsg(Msg{Type: MsgType_FetchHistory, Payload: libp2pFetchHistoryMsg{StateURI: stateURI, Opts: opts}})
+	if err != nil {
+		return nil, err
+	}
+
+	return &libp2pReadableSubscription{peer}, nil
+}
+
+func (peer *libp2pPeer) FetchSnapshot(ctx context.Context, stateURI string) (_ *Snapshot, err error) {
+	defer func() { peer.UpdateConnStats(err == nil) }()
+
+	err = peer.EnsureConnected(ctx)
+	if err != nil {
+		peer.t.Errorf("error connecting to peer: %v", err)
+		return nil, err
+	}
+
+	// The other side closes the stream after responding, so we use a fresh one
+	// rather than the peer's long-lived stream
+	stream, err := peer.t.libp2pHost.NewStream(ctx, peer.pinfo.ID, PROTO_MAIN)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer stream.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		err = stream.SetReadDeadline(deadline)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	req := &libp2pPeer{PeerDetails: peer.PeerDetails, t: peer.t, pinfo: peer.pinfo, stream: stream}
+	err = req.writeMsg(Msg{Type: MsgType_FetchSnapshot, Payload: stateURI})
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := req.readMsg()
+	if err != nil {
+		return nil, err
+	}
+	resp, ok := msg.Payload.(libp2pFetchSnapshotResponse)
+	if !ok {
+		return nil, ErrProtocol
+	} else if resp.Error != "" {
+		return nil, errors.Errorf("peer could not export snapshot: %v", resp.Error)
+	} else if resp.Snapshot == nil {
+		return nil, ErrProtocol
+	}
+	return resp.Snapshot, nil
+}
+
+func (peer *libp2pPeer) Reconcile(ctx context.Context, reconcileReq ReconcileRequest) (_ ReconcileResponse, err error) {
+	defer func() { peer.UpdateConnStats(err == nil) }()
+
+	err = peer.EnsureConnected(ctx)
+	if err != nil {
+		peer.t.Errorf("error connecting to peer: %v", err)
+		return ReconcileResponse{}, err
+	}
+
+	// The other side closes the stream after responding, so we use a fresh one
+	// rather than the peer's long-lived stream
+	stream, err := peer.t.libp2pHost.NewStream(ctx, peer.pinfo.ID, PROTO_MAIN)
+	if err != nil {
+		return ReconcileResponse{}, errors.WithStack(err)
+	}
+	defer stream.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		err = stream.SetReadDeadline(deadline)
+		if err != nil {
+			return ReconcileResponse{}, errors.WithStack(err)
+		}
+	}
+
+	req := &libp2pPeer{PeerDetails: peer.PeerDetails, t: peer.t, pinfo: peer.pinfo, stream: stream}
+	err = req.writeMsg(Msg{Type: MsgType_Reconcile, Payload: reconcileReq})
+	if err != nil {
+		return ReconcileResponse{}, err
+	}
+
+	msg, err := req.readMsg()
+	if err != nil {
+		return ReconcileResponse{}, err
+	}
+	resp, ok := msg.Payload.(libp2pReconcileResponse)
+	if !ok {
+		return ReconcileResponse{}, ErrProtocol
+	} else if resp.Error != "" {
+		return ReconcileResponse{}, errors.Errorf("peer could not reconcile: %v", resp.Error)
+	} else if resp.Response == nil {
+		return ReconcileResponse{}, ErrProtocol
+	}
+	return *resp.Response, nil
+}
+
 func (peer *libp2pPeer) Put(ctx context.Context, tx *Tx, state tree.Node, leaves []types.ID) error {
 	// Note: libp2p peers ignore `state` and `leaves`
 	if tx.IsPrivate() {
@@ -922,6 +1101,26 @@ type libp2pAckMsg struct {
 	TxID     types.ID `json:"txID"`
 }
 
+type libp2pSubscribeMsg struct {
+	StateURI         string            `json:"stateURI"`
+	FetchHistoryOpts *FetchHistoryOpts `json:"fetchHistoryOpts,omitempty"`
+}
+
+type libp2pFetchHistoryMsg struct {
+	StateURI string           `json:"stateURI"`
+	Opts     FetchHistoryOpts `json:"opts"`
+}
+
+type libp2pFetchSnapshotResponse struct {
+	Snapshot *Snapshot `json:"snapshot,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+type libp2pReconcileResponse struct {
+	Response *ReconcileResponse `json:"response,omitempty"`
+	Error    string             `json:"error,omitempty"`
+}
+
 func (p *libp2pPeer) Ack(stateURI string, txID types.ID) error {
 	return p.writeMsg(Msg{Type: MsgType_Ack, Payload: libp2pAckMsg{stateURI, txID}})
 }
@@ -1291,6 +1490,11 @@ type MsgType string
 
 const (
 	MsgType_Subscribe                 MsgType = "subscribe"
+	MsgType_FetchHistory              MsgType = "fetch history"
+	MsgType_FetchSnapshot             MsgType = "fetch snapshot"
+	MsgType_FetchSnapshotResponse     MsgType = "fetch snapshot response"
+	MsgType_Reconcile                 MsgType = "reconcile"
+	MsgType_ReconcileResponse         MsgType = "reconcile response"
 	MsgType_Unsubscribe               MsgType = "unsubscribe"
 	MsgType_Put                       MsgType = "put"
 	MsgType_Private                   MsgType = "private"
@@ -1341,8 +1545,61 @@ func (msg *Msg) UnmarshalJSON(bs []byte) error {
 
 	switch msg.Type {
 	case MsgType_Subscribe:
-		url := string(m.PayloadBytes)
-		msg.Payload = url[1 : len(url)-1] // remove quotes
+		var payload libp2pSubscribeMsg
+		if len(m.PayloadBytes) > 0 && m.PayloadBytes[0] == '"' {
+			// Older peers send only the state URI and expect all of its history
+			err := json.Unmarshal(m.PayloadBytes, &payload.StateURI)
+			if err != nil {
+				return err
+			}
+			payload.FetchHistoryOpts = &FetchHistoryOpts{}
+		} else {
+			err := json.Unmarshal(m.PayloadBytes, &payload)
+			if err != nil {
+				return err
+			}
+		}
+		msg.Payload = payload
+
+	case MsgType_FetchHistory:
+		var payload libp2pFetchHistoryMsg
+		err := json.Unmarshal(m.PayloadBytes, &payload)
+		if err != nil {
+			return err
+		}
+		msg.Payload = payload
+
+	case MsgType_FetchSnapshot:
+		var stateURI string
+		err := json.Unmarshal(m.PayloadBytes, &stateURI)
+		if err != nil {
+			return err
+		}
+		msg.Payload = stateURI
+
+	case MsgType_FetchSnapshotResponse:
+		var resp libp2pFetchSnapshotResponse
+		err := json.Unmarshal(m.PayloadBytes, &resp)
+		if err != nil {
+			return err
+		}
+		msg.Payload = resp
+
+	case MsgType_Reconcile:
+		var req ReconcileRequest
+		err := json.Unmarshal(m.PayloadBytes, &req)
+		if err != nil {
+			return err
+		}
+		msg.Payload = req
+
+	case MsgType_ReconcileResponse:
+		var resp libp2pReconcileResponse
+		err := json.Unmarshal(m.PayloadBytes, &resp)
+		if err != nil {
+			return err
+		}
+		msg.Payload = resp
 
 	case MsgType_Put:
 		var tx Tx
@@ -0,0 +1,541 @@
+package redwood
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"redwood.dev/identity"
+	"redwood.dev/types"
+	"redwood.dev/utils"
+)
+
+// LoopbackNetwork connects the loopback transports of hosts that are running in
+// the same process, so that the behavior of a whole swarm can be tested without
+// opening any sockets.  It can simulate latency, packet loss, reordering and
+// partitions.  Every random decision is drawn from a single seeded source, so
+// a run can be repeated with the same seed.
+//
+// Like libp2p streams, loopback streams carry `Msg`s, and every request opens a
+// new one.  Messages are encoded as JSON on their way through the network, so
+// hosts never share memory.
+type LoopbackNetwork struct {
+	conditions   LoopbackConditions
+	rand         *rand.Rand
+	transports   map[string]*loopbackTransport
+	partitions   map[string]int
+	streams      map[*loopbackStream]struct{}
+	refProviders map[types.RefID]map[string]struct{}
+	mu           sync.Mutex
+}
+
+// LoopbackConditions describe how a LoopbackNetwork treats messages in
+// flight.
+type LoopbackConditions struct {
+	// Latency is the minimum time that a message takes to arrive.
+	Latency time.Duration
+	// Jitter is the maximum random delay that is added to Latency.  Messages on
+	// a stream still arrive in order unless Reordering is set.
+	Jitter time.Duration
+	// PacketLoss is the probability (from 0 to 1) that a message is dropped.
+	// Closing a stream is never lost, just as a dropped connection is always
+	// noticed eventually.
+	PacketLoss float64
+	// Reordering is the probability (from 0 to 1) that a message overtakes the
+	// message sent before it on the same stream.  This only happens while that
+	// message is still in flight, so it has no effect without Latency.
+	Reordering float64
+}
+
+func NewLoopbackNetwork(seed int64) *LoopbackNetwork {
+	return &LoopbackNetwork{
+		rand:         rand.New(rand.NewSource(seed)),
+		transports:   make(map[string]*loopbackTransport),
+		partitions:   make(map[string]int),
+		streams:      make(map[*loopbackStream]struct{}),
+		refProviders: make(map[types.RefID]map[string]struct{}),
+	}
+}
+
+func (n *LoopbackNetwork) SetConditions(conditions LoopbackConditions) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.conditions = conditions
+}
+
+// Partition splits the network so that transports can only reach the others in
+// the same group.  Transports that aren't in any group can reach each other.
+// Streams that cross the partition are closed.
+func (n *LoopbackNetwork) Partition(groups ...[]string) {
+	n.mu.Lock()
+	n.partitions = make(map[string]int)
+	for i, group := range groups {
+		for _, addr := range group {
+			n.partitions[addr] = i + 1
+		}
+	}
+
+	var broken []*loopbackStream
+	for stream := range n.streams {
+		if !n.reachable(stream.localAddr, stream.remoteAddr) {
+			broken = append(broken, stream)
+		}
+	}
+	n.mu.Unlock()
+
+	for _, stream := range broken {
+		stream.closeLocally()
+	}
+}
+
+// Heal removes all partitions.
+func (n *LoopbackNetwork) Heal() {
+	n.Partition()
+}
+
+// reachable must be called with n.mu held.
+func (n *LoopbackNetwork) reachable(from, to string) bool {
+	return n.partitions[from] == n.partitions[to]
+}
+
+func (n *LoopbackNetwork) register(t *loopbackTransport) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if _, exists := n.transports[t.addr]; exists {
+		return errors.Errorf("loopback address %v is already in use", t.addr)
+	}
+	n.transports[t.addr] = t
+	return nil
+}
+
+func (n *LoopbackNetwork) unregister(t *loopbackTransport) {
+	n.mu.Lock()
+	delete(n.transports, t.addr)
+
+	var streams []*loopbackStream
+	for stream := range n.streams {
+		if stream.localAddr == t.addr || stream.remoteAddr == t.addr {
+			streams = append(streams, stream)
+		}
+	}
+	n.mu.Unlock()
+
+	for _, stream := range streams {
+		stream.closeLocally()
+	}
+}
+
+// reachableAddrs returns the addresses of every other transport that the given
+// one can reach.
+func (n *LoopbackNetwork) reachableAddrs(from string) []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var addrs []string
+	for addr := range n.transports {
+		if addr != from && n.reachable(from, addr) {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+func (n *LoopbackNetwork) openStream(from *loopbackTransport, to string) (*loopbackStream, error) {
+	n.mu.Lock()
+	target, exists := n.transports[to]
+	if !exists {
+		n.mu.Unlock()
+		return nil, errors.Wrapf(types.ErrConnection, "no loopback transport at %v", to)
+	} else if !n.reachable(from.addr, to) {
+		n.mu.Unlock()
+		return nil, errors.Wrapf(types.ErrConnection, "%v is unreachable from %v", to, from.addr)
+	}
+
+	local := newLoopbackStream(n, from.addr, to)
+	remote := newLoopbackStream(n, to, from.addr)
+	local.remote = remote
+	remote.remote = local
+	n.streams[local] = struct{}{}
+	n.streams[remote] = struct{}{}
+	n.mu.Unlock()
+
+	go target.acceptStream(remote)
+	return local, nil
+}
+
+func (n *LoopbackNetwork) removeStream(stream *loopbackStream) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.streams, stream)
+}
+
+// send decides the fate of a message written to the given stream.  It returns
+// false if the message is lost.
+func (n *LoopbackNetwork) send(stream *loopbackStream) (deliverAt time.Time, reorder bool, ok bool, err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if !n.reachable(stream.localAddr, stream.remoteAddr) {
+		return time.Time{}, false, false, errors.Wrapf(types.ErrConnection, "%v is unreachable from %v", stream.remoteAddr, stream.localAddr)
+	} else if n.conditions.PacketLoss > 0 && n.rand.Float64() < n.conditions.PacketLoss {
+		return time.Time{}, false, false, nil
+	}
+	reorder = n.conditions.Reordering > 0 && n.rand.Float64() < n.conditions.Reordering
+	return time.Now().Add(n.delay()), reorder, true, nil
+}
+
+// delay must be called with n.mu held.
+func (n *LoopbackNetwork) delay() time.Duration {
+	delay := n.conditions.Latency
+	if n.conditions.Jitter > 0 {
+		delay += time.Duration(n.rand.Int63n(int64(n.conditions.Jitter)))
+	}
+	return delay
+}
+
+func (n *LoopbackNetwork) closeDelay() time.Duration {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.delay()
+}
+
+func (n *LoopbackNetwork) announceRef(refID types.RefID, addr string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.refProviders[refID] == nil {
+		n.refProviders[refID] = make(map[string]struct{})
+	}
+	n.refProviders[refID][addr] = struct{}{}
+}
+
+func (n *LoopbackNetwork) providersOfRef(refID types.RefID, from string) []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var addrs []string
+	for addr := range n.refProviders[refID] {
+		if _, exists := n.transports[addr]; exists && addr != from && n.reachable(from, addr) {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+type loopbackTransport struct {
+	msgStreamTransport
+	chStop chan struct{}
+
+	network *LoopbackNetwork
+	addr    string
+}
+
+func NewLoopbackTransport(
+	network *LoopbackNetwork,
+	addr string,
+	controllerHub ControllerHub,
+	keyStore identity.KeyStore,
+	refStore RefStore,
+	peerStore PeerStore,
+) (Transport, error) {
+	t := &loopbackTransport{
+		chStop:  make(chan struct{}),
+		network: network,
+		addr:    addr,
+	}
+	t.msgStreamTransport = newMsgStreamTransport(t, controllerHub, keyStore, refStore, peerStore)
+	return t, nil
+}
+
+func (t *loopbackTransport) Start() error {
+	t.SetLogLabel("loopback " + t.addr)
+
+	err := t.network.register(t)
+	if err != nil {
+		return err
+	}
+
+	// Update our node's info in the peer store
+	t.peerStore.AddDialInfos([]PeerDialInfo{{t.Name(), t.addr}})
+	return nil
+}
+
+func (t *loopbackTransport) Close() {
+	close(t.chStop)
+	t.network.unregister(t)
+}
+
+func (t *loopbackTransport) Name() string {
+	return "loopback"
+}
+
+// acceptStream serves a stream that another transport opened.  Unlike most
+// transports, we always know how to dial back the node that opened it.
+func (t *loopbackTransport) acceptStream(stream *loopbackStream) {
+	peer := t.makePeer(stream.remoteAddr)
+	peer.stream = stream
+	t.handleIncomingStream(peer)
+}
+
+// connTo never fails, because the loopback network has no connections.  Every
+// stream is opened (or refused) on its own.
+func (t *loopbackTransport) connTo(ctx context.Context, dialAddr string) (msgConn, error) {
+	return loopbackConn{t: t, remoteAddr: dialAddr}, nil
+}
+
+func (t *loopbackTransport) NewPeerConn(ctx context.Context, dialAddr string) (Peer, error) {
+	if dialAddr == t.addr {
+		return nil, errors.WithStack(ErrPeerIsSelf)
+	}
+	return t.makePeer(dialAddr), nil
+}
+
+// ProvidersOfStateURI returns every transport that we can reach.  The network
+// plays the role of a DHT that knows about everyone.
+func (t *loopbackTransport) ProvidersOfStateURI(ctx context.Context, stateURI string) (<-chan Peer, error) {
+	return t.peersAt(ctx, t.network.reachableAddrs(t.addr)), nil
+}
+
+func (t *loopbackTransport) ProvidersOfRef(ctx context.Context, refID types.RefID) (<-chan Peer, error) {
+	return t.peersAt(ctx, t.network.providersOfRef(refID, t.addr)), nil
+}
+
+func (t *loopbackTransport) PeersClaimingAddress(ctx context.Context, address types.Address) (<-chan Peer, error) {
+	var addrs []string
+	for _, peerDetails := range t.peerStore.PeersFromTransportWithAddress(t.Name(), address) {
+		addrs = append(addrs, peerDetails.DialInfo().DialAddr)
+	}
+	return t.peersAt(ctx, addrs), nil
+}
+
+func (t *loopbackTransport) AnnounceRef(ctx context.Context, refID types.RefID) error {
+	t.network.announceRef(refID, t.addr)
+	return nil
+}
+
+func (t *loopbackTransport) peersAt(ctx context.Context, addrs []string) <-chan Peer {
+	ch := make(chan Peer)
+	go func() {
+		defer close(ch)
+		for _, addr := range addrs {
+			select {
+			case ch <- t.makePeer(addr):
+			case <-ctx.Done():
+				return
+			case <-t.chStop:
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+type loopbackConn struct {
+	t          *loopbackTransport
+	remoteAddr string
+}
+
+func (c loopbackConn) openStream(ctx context.Context) (msgStream, error) {
+	stream, err := c.t.network.openStream(c.t, c.remoteAddr)
+	if err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+func (c loopbackConn) isClosed() bool {
+	return false
+}
+
+func (c loopbackConn) incomingID() string {
+	return ""
+}
+
+// loopbackStream is one end of a stream.  Messages written to it are queued at
+// the other end until the network delivers them.
+type loopbackStream struct {
+	network    *LoopbackNetwork
+	localAddr  string
+	remoteAddr string
+	remote     *loopbackStream
+	inbox      *utils.Mailbox
+
+	queue    []loopbackPacket
+	queueMu  sync.Mutex
+	chQueued chan struct{}
+
+	chClosed  chan struct{}
+	closeOnce sync.Once
+}
+
+type loopbackPacket struct {
+	msg       []byte
+	close     bool
+	deliverAt time.Time
+}
+
+func newLoopbackStream(network *LoopbackNetwork, localAddr, remoteAddr string) *loopbackStream {
+	stream := &loopbackStream{
+		network:    network,
+		localAddr:  localAddr,
+		remoteAddr: remoteAddr,
+		inbox:      utils.NewMailbox(0),
+		chQueued:   make(chan struct{}, 1),
+		chClosed:   make(chan struct{}),
+	}
+	go stream.deliverLoop()
+	return stream
+}
+
+func (s *loopbackStream) writeMsg(msg Msg) error {
+	if s.isClosed() {
+		return errors.Wrap(types.ErrConnection, "stream is closed")
+	}
+
+	bs, err := json.Marshal(msg)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	deliverAt, reorder, ok, err := s.network.send(s)
+	if err != nil {
+		s.closeLocally()
+		s.remote.closeLocally()
+		return err
+	} else if !ok {
+		return nil
+	}
+	s.remote.enqueue(loopbackPacket{msg: bs, deliverAt: deliverAt}, reorder)
+	return nil
+}
+
+// enqueue adds a packet to the queue of packets in flight.  Packets are
+// delivered in order, unless `reorder` is true, in which case the packet
+// overtakes the one before it.
+func (s *loopbackStream) enqueue(packet loopbackPacket, reorder bool) {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	n := len(s.queue)
+	if n > 0 && packet.deliverAt.Before(s.queue[n-1].deliverAt) {
+		packet.deliverAt = s.queue[n-1].deliverAt
+	}
+
+	if reorder && n > 0 && !s.queue[n-1].close {
+		prev := s.queue[n-1]
+		packet.deliverAt, prev.deliverAt = prev.deliverAt, packet.deliverAt
+		s.queue[n-1] = packet
+		s.queue = append(s.queue, prev)
+	} else {
+		s.queue = append(s.queue, packet)
+	}
+
+	select {
+	case s.chQueued <- struct{}{}:
+	default:
+	}
+}
+
+func (s *loopbackStream) deliverLoop() {
+	for {
+		s.queueMu.Lock()
+		var packet *loopbackPacket
+		if len(s.queue) > 0 {
+			packet = &s.queue[0]
+		}
+		s.queueMu.Unlock()
+
+		if packet == nil {
+			select {
+			case <-s.chQueued:
+				continue
+			case <-s.chClosed:
+				return
+			}
+		}
+
+		// Packets can be reordered while we wait, so check the queue again
+		// afterwards
+		if wait := time.Until(packet.deliverAt); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+				continue
+			case <-s.chClosed:
+				timer.Stop()
+				return
+			}
+		}
+
+		s.queueMu.Lock()
+		next := s.queue[0]
+		s.queue = s.queue[1:]
+		s.queueMu.Unlock()
+
+		if next.close {
+			s.closeLocally()
+			return
+		}
+
+		var msg Msg
+		err := json.Unmarshal(next.msg, &msg)
+		if err != nil {
+			panic(err)
+		}
+		s.inbox.Deliver(msg)
+	}
+}
+
+// readMsg returns the next message on the stream.  Messages that arrived before
+// the stream was closed are still returned, followed by io.EOF.
+func (s *loopbackStream) readMsg(ctx context.Context) (Msg, error) {
+	for {
+		if x := s.inbox.Retrieve(); x != nil {
+			return x.(Msg), nil
+		}
+
+		select {
+		case <-s.inbox.Notify():
+		case <-s.chClosed:
+			if x := s.inbox.Retrieve(); x != nil {
+				return x.(Msg), nil
+			}
+			return Msg{}, io.EOF
+		case <-ctx.Done():
+			return Msg{}, ctx.Err()
+		}
+	}
+}
+
+func (s *loopbackStream) isClosed() bool {
+	select {
+	case <-s.chClosed:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close closes our end of the stream immediately, and the other end once every
+// message that we've written has arrived.
+func (s *loopbackStream) Close() error {
+	if s.isClosed() {
+		return nil
+	}
+	s.closeLocally()
+	s.remote.enqueue(loopbackPacket{close: true, deliverAt: time.Now().Add(s.network.closeDelay())}, false)
+	return nil
+}
+
+func (s *loopbackStream) closeLocally() {
+	s.closeOnce.Do(func() {
+		close(s.chClosed)
+		s.network.removeStream(s)
+	})
+}
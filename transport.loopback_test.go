@@ -0,0 +1,169 @@
+package redwood_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"redwood.dev"
+	"redwood.dev/testutils"
+	"redwood.dev/types"
+)
+
+func sendGenesis(t *testing.T, swarm *testutils.Swarm, stateURI string) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	genesis := redwood.Tx{ID: redwood.GenesisTxID, StateURI: stateURI, Patches: mustParsePatches(t, `. = {}`)}
+	err := swarm.Hosts[0].SendTx(ctx, genesis)
+	require.NoError(t, err)
+	swarm.RequireConverged(stateURI, 10*time.Second)
+}
+
+// writeFromHost has the given host write a chain of numTxs txs.  Every host
+// writes to its own key, so the final state doesn't depend on the order in
+// which hosts receive each other's txs.
+func writeFromHost(t *testing.T, swarm *testutils.Swarm, i int, numTxs int, stateURI string) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for n := 1; n <= numTxs; n++ {
+		tx := redwood.Tx{ID: types.RandomID(), StateURI: stateURI, Patches: mustParsePatches(t, fmt.Sprintf(`.host%v = %v`, i, n))}
+		err := swarm.Hosts[i].SendTx(ctx, tx)
+		require.NoError(t, err)
+
+		// Wait for the tx to be processed, so that the next one is its child
+		require.Eventually(t, func() bool {
+			leaves, err := swarm.Hosts[i].Controllers().Leaves(stateURI)
+			if err != nil {
+				return false
+			}
+			for _, leaf := range leaves {
+				if leaf == tx.ID {
+					return true
+				}
+			}
+			return false
+		}, 10*time.Second, 10*time.Millisecond)
+	}
+}
+
+func TestLoopbackSwarm_Gossip(t *testing.T) {
+	swarm := testutils.NewSwarm(t, 5, 1)
+	defer swarm.Close()
+
+	// Txs overtake each other, so hosts receive children before their parents
+	swarm.Network.SetConditions(redwood.LoopbackConditions{
+		Latency:    5 * time.Millisecond,
+		Jitter:     20 * time.Millisecond,
+		Reordering: 0.3,
+	})
+
+	stateURI := "foo.com/bar"
+	sendGenesis(t, swarm, stateURI)
+
+	for i := range swarm.Hosts {
+		writeFromHost(t, swarm, i, 3, stateURI)
+	}
+	swarm.RequireConverged(stateURI, 20*time.Second)
+
+	state, err := swarm.Hosts[0].StateAtVersion(stateURI, nil)
+	require.NoError(t, err)
+	defer state.Close()
+	for i := range swarm.Hosts {
+		val, exists, err := state.Value([]byte(fmt.Sprintf("host%v", i)), nil)
+		require.NoError(t, err)
+		require.True(t, exists)
+		require.EqualValues(t, 3, val)
+	}
+}
+
+func TestLoopbackSwarm_Partition(t *testing.T) {
+	swarm := testutils.NewSwarm(t, 4, 2)
+	defer swarm.Close()
+
+	stateURI := "foo.com/bar"
+	sendGenesis(t, swarm, stateURI)
+
+	swarm.Network.Partition(
+		[]string{swarm.Addr(0), swarm.Addr(1)},
+		[]string{swarm.Addr(2), swarm.Addr(3)},
+	)
+
+	writeFromHost(t, swarm, 0, 2, stateURI)
+	writeFromHost(t, swarm, 2, 2, stateURI)
+
+	// Each side only sees its own writes
+	leaves0, err := swarm.Hosts[0].Controllers().Leaves(stateURI)
+	require.NoError(t, err)
+	leaves2, err := swarm.Hosts[2].Controllers().Leaves(stateURI)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		have, err := swarm.Hosts[1].Controllers().HaveTx(stateURI, leaves0[0])
+		return err == nil && have
+	}, 10*time.Second, 50*time.Millisecond)
+	require.Eventually(t, func() bool {
+		have, err := swarm.Hosts[3].Controllers().HaveTx(stateURI, leaves2[0])
+		return err == nil && have
+	}, 10*time.Second, 50*time.Millisecond)
+
+	for _, i := range []int{2, 3} {
+		have, err := swarm.Hosts[i].Controllers().HaveTx(stateURI, leaves0[0])
+		require.NoError(t, err)
+		require.False(t, have)
+	}
+	for _, i := range []int{0, 1} {
+		have, err := swarm.Hosts[i].Controllers().HaveTx(stateURI, leaves2[0])
+		require.NoError(t, err)
+		require.False(t, have)
+	}
+
+	// Once the partition heals, reconciling brings both sides together
+	swarm.Network.Heal()
+	swarm.ReconcileAll(stateURI)
+	swarm.RequireConverged(stateURI, 20*time.Second)
+}
+
+func TestLoopbackSwarm_PacketLoss(t *testing.T) {
+	swarm := testutils.NewSwarm(t, 4, 3)
+	defer swarm.Close()
+
+	stateURI := "foo.com/bar"
+	sendGenesis(t, swarm, stateURI)
+
+	swarm.Network.SetConditions(redwood.LoopbackConditions{PacketLoss: 0.5})
+	for i := range swarm.Hosts {
+		writeFromHost(t, swarm, i, 2, stateURI)
+	}
+
+	swarm.Network.SetConditions(redwood.LoopbackConditions{})
+	swarm.ReconcileAll(stateURI)
+	swarm.RequireConverged(stateURI, 20*time.Second)
+}
+
+func TestLoopbackTransport_PartitionRefusesConnections(t *testing.T) {
+	swarm := testutils.NewSwarm(t, 2, 4)
+	defer swarm.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	swarm.Network.Partition([]string{swarm.Addr(0)})
+
+	peer, err := swarm.Hosts[1].Transport("loopback").NewPeerConn(ctx, swarm.Addr(0))
+	require.NoError(t, err)
+	err = peer.EnsureConnected(ctx)
+	require.Equal(t, types.ErrConnection, errors.Cause(err))
+
+	swarm.Network.Heal()
+	err = peer.EnsureConnected(ctx)
+	require.NoError(t, err)
+}
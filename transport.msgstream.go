@@ -0,0 +1,795 @@
+package redwood
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"redwood.dev/crypto"
+	"redwood.dev/ctx"
+	"redwood.dev/identity"
+	"redwood.dev/tree"
+	"redwood.dev/types"
+)
+
+// The websocket, webrtc and loopback transports all speak the libp2p
+// transport's protocol over ordered streams of `Msg`s.  Every request opens a
+// new stream, and its first message determines what the stream is for.  Those
+// transports only differ in how they set up connections and frame messages,
+// so everything else lives here.
+
+// msgStream is an ordered, bidirectional stream of messages.  Messages that
+// arrived before the stream was closed are still returned by readMsg, followed
+// by io.EOF.
+type msgStream interface {
+	writeMsg(msg Msg) error
+	readMsg(ctx context.Context) (Msg, error)
+	isClosed() bool
+	Close() error
+}
+
+// msgConn is a connection to another node that can carry any number of
+// streams.
+type msgConn interface {
+	openStream(ctx context.Context) (msgStream, error)
+	isClosed() bool
+	// incomingID distinguishes the connections that other nodes opened to
+	// us.  It's empty for connections that we dialed.
+	incomingID() string
+}
+
+// msgStreamDialer is implemented by each transport that's built on
+// msgStreamTransport.
+type msgStreamDialer interface {
+	Transport
+	// connTo returns the open connection to the given dial address, dialing
+	// it if there isn't one yet.
+	connTo(ctx context.Context, dialAddr string) (msgConn, error)
+}
+
+type msgStreamTransport struct {
+	ctx.Logger
+	dialer msgStreamDialer
+
+	host          Host
+	controllerHub ControllerHub
+	keyStore      identity.KeyStore
+	refStore      RefStore
+	peerStore     PeerStore
+}
+
+func newMsgStreamTransport(
+	dialer msgStreamDialer,
+	controllerHub ControllerHub,
+	keyStore identity.KeyStore,
+	refStore RefStore,
+	peerStore PeerStore,
+) msgStreamTransport {
+	return msgStreamTransport{
+		Logger:        ctx.NewLogger(dialer.Name()),
+		dialer:        dialer,
+		controllerHub: controllerHub,
+		keyStore:      keyStore,
+		refStore:      refStore,
+		peerStore:     peerStore,
+	}
+}
+
+func (t *msgStreamTransport) SetHost(h Host) {
+	t.host = h
+}
+
+// identifyPeer challenges the node on the other end of a connection that it
+// opened to us to prove which addresses it holds.
+func (t *msgStreamTransport) identifyPeer(ctx context.Context, conn msgConn) (PeerDetails, error) {
+	stream, err := conn.openStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	challengeMsg, err := types.GenerateChallengeMsg()
+	if err != nil {
+		return nil, err
+	}
+
+	err = stream.writeMsg(Msg{Type: MsgType_ChallengeIdentityRequest, Payload: types.ChallengeMsg(challengeMsg)})
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := stream.readMsg(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, ok := msg.Payload.([]ChallengeIdentityResponse)
+	if !ok || len(resp) == 0 {
+		return nil, ErrProtocol
+	}
+
+	var peerDetails PeerDetails
+	for _, proof := range resp {
+		sigpubkey, err := crypto.RecoverSigningPubkey(types.HashBytes(challengeMsg), proof.Signature)
+		if err != nil {
+			return nil, err
+		}
+		var encpubkey crypto.EncryptingPublicKey
+		if len(proof.EncryptingPublicKey) > 0 {
+			encpubkey = crypto.EncryptingPublicKeyFromBytes(proof.EncryptingPublicKey)
+		}
+
+		t.peerStore.AddVerifiedCredentials(PeerDialInfo{TransportName: t.dialer.Name()}, sigpubkey.Address(), sigpubkey, encpubkey)
+		if peerDetails == nil {
+			peerDetails = t.peerWithAddress(sigpubkey.Address())
+		}
+	}
+	if peerDetails == nil {
+		return nil, errors.New("peer store has no details for verified peer")
+	}
+	return peerDetails, nil
+}
+
+// peerWithAddress returns the details of a peer that dialed us and proved that
+// it holds the given address.
+func (t *msgStreamTransport) peerWithAddress(address types.Address) PeerDetails {
+	for _, peerDetails := range t.peerStore.PeersFromTransportWithAddress(t.dialer.Name(), address) {
+		if peerDetails.DialInfo().DialAddr == "" {
+			return peerDetails
+		}
+	}
+	return nil
+}
+
+// handleIncomingStream serves a stream that the given peer opened.
+func (t *msgStreamTransport) handleIncomingStream(peer *msgStreamPeer) {
+	stream := peer.stream
+
+	msg, err := stream.readMsg(context.Background())
+	if err == io.EOF {
+		return
+	} else if err != nil {
+		t.Errorf("incoming stream error: %v", err)
+		stream.Close()
+		return
+	}
+
+	switch msg.Type {
+	case MsgType_Subscribe:
+		payload, ok := msg.Payload.(libp2pSubscribeMsg)
+		if !ok {
+			t.Errorf("Subscribe message: bad payload: (%T) %v", msg.Payload, msg.Payload)
+			stream.Close()
+			return
+		}
+		t.serveSubscriber(peer, payload.StateURI, payload.FetchHistoryOpts)
+
+	case MsgType_FetchHistory:
+		payload, ok := msg.Payload.(libp2pFetchHistoryMsg)
+		if !ok {
+			t.Errorf("FetchHistory message: bad payload: (%T) %v", msg.Payload, msg.Payload)
+			stream.Close()
+			return
+		}
+		t.serveSubscriber(peer, payload.StateURI, &payload.Opts)
+
+	case MsgType_FetchSnapshot:
+		defer peer.Close()
+
+		stateURI, ok := msg.Payload.(string)
+		if !ok {
+			t.Errorf("FetchSnapshot message: bad payload: (%T) %v", msg.Payload, msg.Payload)
+			return
+		}
+
+		var resp libp2pFetchSnapshotResponse
+		snapshot, err := t.host.HandleFetchSnapshotRequest(stateURI, peer)
+		if err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp.Snapshot = snapshot
+		}
+
+		err = stream.writeMsg(Msg{Type: MsgType_FetchSnapshotResponse, Payload: resp})
+		if err != nil {
+			t.Errorf("error writing snapshot to peer: %v", err)
+		}
+
+	case MsgType_Reconcile:
+		defer peer.Close()
+
+		req, ok := msg.Payload.(ReconcileRequest)
+		if !ok {
+			t.Errorf("Reconcile message: bad payload: (%T) %v", msg.Payload, msg.Payload)
+			return
+		}
+
+		var resp libp2pReconcileResponse
+		reconcileResp, err := t.host.HandleReconcileRequest(req, peer)
+		if err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp.Response = &reconcileResp
+		}
+
+		err = stream.writeMsg(Msg{Type: MsgType_ReconcileResponse, Payload: resp})
+		if err != nil {
+			t.Errorf("error writing reconcile response to peer: %v", err)
+		}
+
+	case MsgType_Put:
+		defer peer.Close()
+
+		tx, ok := msg.Payload.(Tx)
+		if !ok {
+			t.Errorf("Put message: bad payload: (%T) %v", msg.Payload, msg.Payload)
+			return
+		}
+		t.host.HandleTxReceived(tx, peer)
+
+	case MsgType_Private:
+		defer peer.Close()
+
+		encryptedTx, ok := msg.Payload.(EncryptedTx)
+		if !ok {
+			t.Errorf("Private message: bad payload: (%T) %v", msg.Payload, msg.Payload)
+			return
+		}
+		tx, err := t.decryptTx(encryptedTx)
+		if err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+		t.host.HandleTxReceived(tx, peer)
+
+	case MsgType_Ack:
+		defer peer.Close()
+
+		ackMsg, ok := msg.Payload.(libp2pAckMsg)
+		if !ok {
+			t.Errorf("Ack message: bad payload: (%T) %v", msg.Payload, msg.Payload)
+			return
+		}
+		t.host.HandleAckReceived(ackMsg.StateURI, ackMsg.TxID, peer)
+
+	case MsgType_ChallengeIdentityRequest:
+		challengeMsg, ok := msg.Payload.(types.ChallengeMsg)
+		if !ok {
+			t.Errorf("MsgType_ChallengeIdentityRequest message: bad payload: (%T) %v", msg.Payload, msg.Payload)
+			peer.Close()
+			return
+		}
+
+		err := t.host.HandleChallengeIdentity(challengeMsg, peer)
+		if err != nil {
+			t.Errorf("MsgType_ChallengeIdentityRequest: error from verifyAddressHandler: %v", err)
+			return
+		}
+
+	case MsgType_FetchRef:
+		defer peer.Close()
+
+		refID, ok := msg.Payload.(types.RefID)
+		if !ok {
+			t.Errorf("FetchRef message: bad payload: (%T) %v", msg.Payload, msg.Payload)
+			return
+		}
+		t.host.HandleFetchRefReceived(refID, peer)
+
+	case MsgType_AnnouncePeers:
+		defer peer.Close()
+
+		tuples, ok := msg.Payload.([]PeerDialInfo)
+		if !ok {
+			t.Errorf("Announce peers: bad payload: (%T) %v", msg.Payload, msg.Payload)
+			return
+		}
+		t.peerStore.AddDialInfos(tuples)
+
+	default:
+		t.Errorf("protocol error: unexpected message type %v", msg.Type)
+		peer.Close()
+	}
+}
+
+// serveSubscriber writes txs to a peer that subscribed on its stream.
+// Subscriptions are bidirectional: the subscriber acks the txs that we send
+// and may send its own txs on the same stream, until it closes it.
+func (t *msgStreamTransport) serveSubscriber(peer *msgStreamPeer, stateURI string, fetchHistoryOpts *FetchHistoryOpts) {
+	writeSub := newWritableSubscription(t.host, stateURI, nil, SubscriptionType_Txs, &msgStreamWritableSubscription{peer})
+	defer writeSub.Close()
+
+	t.host.HandleWritableSubscriptionOpened(writeSub, fetchHistoryOpts)
+
+	for {
+		msg, err := peer.stream.readMsg(context.Background())
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			t.Errorf("error reading from subscriber: %v", err)
+			return
+		}
+
+		switch msg.Type {
+		case MsgType_Ack:
+			ackMsg, ok := msg.Payload.(libp2pAckMsg)
+			if !ok {
+				t.Errorf("Ack message: bad payload: (%T) %v", msg.Payload, msg.Payload)
+				continue
+			}
+			t.host.HandleAckReceived(ackMsg.StateURI, ackMsg.TxID, peer)
+
+		case MsgType_Put:
+			tx, ok := msg.Payload.(Tx)
+			if !ok {
+				t.Errorf("Put message: bad payload: (%T) %v", msg.Payload, msg.Payload)
+				continue
+			}
+			t.host.HandleTxReceived(tx, peer)
+
+		case MsgType_Private:
+			encryptedTx, ok := msg.Payload.(EncryptedTx)
+			if !ok {
+				t.Errorf("Private message: bad payload: (%T) %v", msg.Payload, msg.Payload)
+				continue
+			}
+			tx, err := t.decryptTx(encryptedTx)
+			if err != nil {
+				t.Errorf("%v", err)
+				continue
+			}
+			t.host.HandleTxReceived(tx, peer)
+
+		default:
+			t.Errorf("protocol error: unexpected message type %v on subscription", msg.Type)
+		}
+	}
+}
+
+func (t *msgStreamTransport) decryptTx(encryptedTx EncryptedTx) (Tx, error) {
+	bs, err := t.keyStore.OpenMessageFrom(
+		encryptedTx.RecipientAddress,
+		crypto.EncryptingPublicKeyFromBytes(encryptedTx.SenderPublicKey),
+		encryptedTx.EncryptedPayload,
+	)
+	if err != nil {
+		return Tx{}, errors.Errorf("error decrypting tx: %v", err)
+	}
+
+	var tx Tx
+	err = json.Unmarshal(bs, &tx)
+	if err != nil {
+		return Tx{}, errors.Errorf("error decoding tx: %v", err)
+	} else if encryptedTx.TxID != tx.ID {
+		return Tx{}, errors.Errorf("private tx id does not match")
+	}
+	return tx, nil
+}
+
+// peersFromPeerStore returns every peer in the peer store that we know how to
+// dial, except for ourselves.
+func (t *msgStreamTransport) peersFromPeerStore(ctx context.Context, ownAddr string) <-chan Peer {
+	// @@TODO: validate peer as an authorized provider via web of trust, certificate authority,
+	// whitelist, etc.
+	var peers []Peer
+	for _, peerDetails := range t.peerStore.PeersFromTransport(t.dialer.Name()) {
+		dialAddr := peerDetails.DialInfo().DialAddr
+		if dialAddr == "" || dialAddr == ownAddr {
+			continue
+		}
+		peers = append(peers, &msgStreamPeer{PeerDetails: peerDetails, t: t, dialAddr: dialAddr})
+	}
+
+	ch := make(chan Peer)
+	go func() {
+		defer close(ch)
+		for _, peer := range peers {
+			select {
+			case ch <- peer:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+func (t *msgStreamTransport) makePeer(dialAddr string) *msgStreamPeer {
+	peer := &msgStreamPeer{t: t, dialAddr: dialAddr}
+
+	peerDetails := t.peerStore.PeerWithDialInfo(PeerDialInfo{t.dialer.Name(), dialAddr})
+	if peerDetails == nil {
+		t.peerStore.AddDialInfos([]PeerDialInfo{{t.dialer.Name(), dialAddr}})
+		peerDetails = t.peerStore.PeerWithDialInfo(PeerDialInfo{t.dialer.Name(), dialAddr})
+	}
+	if peerDetails != nil {
+		peer.PeerDetails = peerDetails
+	}
+	return peer
+}
+
+type msgStreamPeer struct {
+	PeerDetails
+	t        *msgStreamTransport
+	dialAddr string
+	conn     msgConn
+	stream   msgStream
+	mu       sync.Mutex
+}
+
+func (peer *msgStreamPeer) Transport() Transport {
+	return peer.t.dialer
+}
+
+func (peer *msgStreamPeer) EnsureConnected(ctx context.Context) error {
+	peer.mu.Lock()
+	defer peer.mu.Unlock()
+
+	if peer.stream != nil && !peer.stream.isClosed() {
+		return nil
+	}
+
+	conn, err := peer.ensureConn(ctx)
+	if err != nil {
+		return err
+	}
+	stream, err := conn.openStream(ctx)
+	if err != nil {
+		return err
+	}
+	peer.stream = stream
+	return nil
+}
+
+// ensureConn must be called with peer.mu held.
+func (peer *msgStreamPeer) ensureConn(ctx context.Context) (msgConn, error) {
+	if peer.conn != nil && !peer.conn.isClosed() {
+		return peer.conn, nil
+	} else if peer.dialAddr == "" {
+		return nil, errors.Wrap(types.ErrConnection, "peer disconnected and has no dial address")
+	}
+
+	conn, err := peer.t.dialer.connTo(ctx, peer.dialAddr)
+	if err != nil {
+		peer.UpdateConnStats(false)
+		return nil, err
+	}
+	peer.UpdateConnStats(true)
+	peer.conn = conn
+	return conn, nil
+}
+
+func (peer *msgStreamPeer) Subscribe(ctx context.Context, stateURI string) (_ ReadableSubscription, err error) {
+	defer func() { peer.UpdateConnStats(err == nil) }()
+
+	err = peer.EnsureConnected(ctx)
+	if err != nil {
+		peer.t.Errorf("error connecting to peer: %v", err)
+		return nil, err
+	}
+
+	// Only ask for the txs that we don't have yet
+	leaves, err := peer.t.host.Controllers().Leaves(stateURI)
+	if err != nil && errors.Cause(err) != ErrNoController {
+		return nil, err
+	}
+
+	err = peer.writeMsg(Msg{Type: MsgType_Subscribe, Payload: libp2pSubscribeMsg{
+		StateURI:         stateURI,
+		FetchHistoryOpts: &FetchHistoryOpts{SinceLeaves: leaves},
+	}})
+	if err != nil {
+		return nil, err
+	}
+
+	return &msgStreamReadableSubscription{peer}, nil
+}
+
+func (peer *msgStreamPeer) FetchHistory(ctx context.Context, stateURI string, opts FetchHistoryOpts) (_ ReadableSubscription, err error) {
+	defer func() { peer.UpdateConnStats(err == nil) }()
+
+	err = peer.EnsureConnected(ctx)
+	if err != nil {
+		peer.t.Errorf("error connecting to peer: %v", err)
+		return nil, err
+	}
+
+	err = peer.writeMsg(Msg{Type: MsgType_FetchHistory, Payload: libp2pFetchHistoryMsg{StateURI: stateURI, Opts: opts}})
+	if err != nil {
+		return nil, err
+	}
+
+	return &msgStreamReadableSubscription{peer}, nil
+}
+
+func (peer *msgStreamPeer) FetchSnapshot(ctx context.Context, stateURI string) (_ *Snapshot, err error) {
+	defer func() { peer.UpdateConnStats(err == nil) }()
+
+	msg, err := peer.request(ctx, Msg{Type: MsgType_FetchSnapshot, Payload: stateURI})
+	if err != nil {
+		return nil, err
+	}
+	resp, ok := msg.Payload.(libp2pFetchSnapshotResponse)
+	if !ok {
+		return nil, ErrProtocol
+	} else if resp.Error != "" {
+		return nil, errors.Errorf("peer could not export snapshot: %v", resp.Error)
+	} else if resp.Snapshot == nil {
+		return nil, ErrProtocol
+	}
+	return resp.Snapshot, nil
+}
+
+func (peer *msgStreamPeer) Reconcile(ctx context.Context, reconcileReq ReconcileRequest) (_ ReconcileResponse, err error) {
+	defer func() { peer.UpdateConnStats(err == nil) }()
+
+	msg, err := peer.request(ctx, Msg{Type: MsgType_Reconcile, Payload: reconcileReq})
+	if err != nil {
+		return ReconcileResponse{}, err
+	}
+	resp, ok := msg.Payload.(libp2pReconcileResponse)
+	if !ok {
+		return ReconcileResponse{}, ErrProtocol
+	} else if resp.Error != "" {
+		return ReconcileResponse{}, errors.Errorf("peer could not reconcile: %v", resp.Error)
+	} else if resp.Response == nil {
+		return ReconcileResponse{}, ErrProtocol
+	}
+	return *resp.Response, nil
+}
+
+// request sends a message on a fresh stream and waits for the response.  The
+// other side closes the stream after responding, so we don't use the peer's
+// long-lived stream.
+func (peer *msgStreamPeer) request(ctx context.Context, msg Msg) (Msg, error) {
+	peer.mu.Lock()
+	conn, err := peer.ensureConn(ctx)
+	peer.mu.Unlock()
+	if err != nil {
+		peer.t.Errorf("error connecting to peer: %v", err)
+		return Msg{}, err
+	}
+
+	stream, err := conn.openStream(ctx)
+	if err != nil {
+		return Msg{}, err
+	}
+	defer stream.Close()
+
+	err = stream.writeMsg(msg)
+	if err != nil {
+		return Msg{}, err
+	}
+	return stream.readMsg(ctx)
+}
+
+func (peer *msgStreamPeer) Put(ctx context.Context, tx *Tx, state tree.Node, leaves []types.ID) error {
+	// Note: these peers ignore `state` and `leaves`
+	if tx.IsPrivate() {
+		marshalledTx, err := json.Marshal(tx)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		peerAddrs := types.OverlappingAddresses(tx.Recipients, peer.Addresses())
+		if len(peerAddrs) == 0 {
+			return errors.New("tx not intended for this peer")
+		}
+		peerSigPubkey, peerEncPubkey := peer.PublicKeys(peerAddrs[0])
+		if peerEncPubkey == nil {
+			return errors.New("peer has no encrypting public key")
+		}
+
+		var ownIdentity identity.Identity
+		for _, addr := range tx.Recipients {
+			ownIdentity, err = peer.t.keyStore.IdentityWithAddress(addr)
+			if err != nil {
+				return err
+			}
+			if ownIdentity != (identity.Identity{}) {
+				break
+			}
+		}
+		if ownIdentity == (identity.Identity{}) {
+			return errors.New("private tx Recipients field must contain own address")
+		}
+
+		encryptedTxBytes, err := peer.t.keyStore.SealMessageFor(ownIdentity.Address(), peerEncPubkey, marshalledTx)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		etx := EncryptedTx{
+			TxID:             tx.ID,
+			EncryptedPayload: encryptedTxBytes,
+			SenderPublicKey:  ownIdentity.Encrypting.EncryptingPublicKey.Bytes(),
+			RecipientAddress: peerSigPubkey.Address(),
+		}
+		return peer.writeMsg(Msg{Type: MsgType_Private, Payload: etx})
+	}
+	return peer.writeMsg(Msg{Type: MsgType_Put, Payload: tx})
+}
+
+func (p *msgStreamPeer) Ack(stateURI string, txID types.ID) error {
+	return p.writeMsg(Msg{Type: MsgType_Ack, Payload: libp2pAckMsg{stateURI, txID}})
+}
+
+func (p *msgStreamPeer) ChallengeIdentity(challengeMsg types.ChallengeMsg) error {
+	return p.writeMsg(Msg{Type: MsgType_ChallengeIdentityRequest, Payload: challengeMsg})
+}
+
+func (p *msgStreamPeer) ReceiveChallengeIdentityResponse() ([]ChallengeIdentityResponse, error) {
+	msg, err := p.readMsg()
+	if err != nil {
+		return nil, err
+	}
+	resp, ok := msg.Payload.([]ChallengeIdentityResponse)
+	if !ok {
+		return nil, ErrProtocol
+	}
+	return resp, nil
+}
+
+func (p *msgStreamPeer) RespondChallengeIdentity(challengeIdentityResponse []ChallengeIdentityResponse) error {
+	return p.writeMsg(Msg{Type: MsgType_ChallengeIdentityResponse, Payload: challengeIdentityResponse})
+}
+
+func (p *msgStreamPeer) FetchRef(refID types.RefID) error {
+	return p.writeMsg(Msg{Type: MsgType_FetchRef, Payload: refID})
+}
+
+func (p *msgStreamPeer) SendRefHeader() error {
+	return p.writeMsg(Msg{Type: MsgType_FetchRefResponse, Payload: FetchRefResponse{Header: &FetchRefResponseHeader{}}})
+}
+
+func (p *msgStreamPeer) SendRefPacket(data []byte, end bool) error {
+	return p.writeMsg(Msg{Type: MsgType_FetchRefResponse, Payload: FetchRefResponse{Body: &FetchRefResponseBody{Data: data, End: end}}})
+}
+
+func (p *msgStreamPeer) ReceiveRefPacket() (FetchRefResponseBody, error) {
+	msg, err := p.readMsg()
+	if err != nil {
+		return FetchRefResponseBody{}, errors.Errorf("error reading from peer: %v", err)
+	} else if msg.Type != MsgType_FetchRefResponse {
+		return FetchRefResponseBody{}, ErrProtocol
+	}
+
+	resp, is := msg.Payload.(FetchRefResponse)
+	if !is {
+		return FetchRefResponseBody{}, ErrProtocol
+	} else if resp.Body == nil {
+		return FetchRefResponseBody{}, ErrProtocol
+	}
+	return *resp.Body, nil
+}
+
+func (p *msgStreamPeer) ReceiveRefHeader() (FetchRefResponseHeader, error) {
+	msg, err := p.readMsg()
+	if err != nil {
+		return FetchRefResponseHeader{}, errors.Errorf("error reading from peer: %v", err)
+	} else if msg.Type != MsgType_FetchRefResponse {
+		return FetchRefResponseHeader{}, ErrProtocol
+	}
+
+	resp, is := msg.Payload.(FetchRefResponse)
+	if !is {
+		return FetchRefResponseHeader{}, ErrProtocol
+	} else if resp.Header == nil {
+		return FetchRefResponseHeader{}, ErrProtocol
+	}
+	return *resp.Header, nil
+}
+
+func (p *msgStreamPeer) AnnouncePeers(ctx context.Context, peerDialInfos []PeerDialInfo) error {
+	err := p.EnsureConnected(ctx)
+	if err != nil {
+		return err
+	}
+	return p.writeMsg(Msg{Type: MsgType_AnnouncePeers, Payload: peerDialInfos})
+}
+
+func (p *msgStreamPeer) writeMsg(msg Msg) (err error) {
+	defer func() { p.UpdateConnStats(err == nil) }()
+
+	if p.stream == nil {
+		return errors.Wrap(types.ErrConnection, "not connected")
+	}
+	return p.stream.writeMsg(msg)
+}
+
+func (p *msgStreamPeer) readMsg() (msg Msg, err error) {
+	defer func() { p.UpdateConnStats(err == nil) }()
+
+	if p.stream == nil {
+		return Msg{}, errors.Wrap(types.ErrConnection, "not connected")
+	}
+	return p.stream.readMsg(context.Background())
+}
+
+func (p *msgStreamPeer) Close() error {
+	if p.stream != nil {
+		return p.stream.Close()
+	}
+	return nil
+}
+
+// DialInfo tells apart the peers that dialed us by their connections.  They
+// can't be dialed back, so the peer store keeps a single entry with an empty
+// dial address for all of them, but the host needs to track which txs each of
+// them has seen.
+func (p *msgStreamPeer) DialInfo() PeerDialInfo {
+	if p.conn != nil {
+		if id := p.conn.incomingID(); id != "" {
+			return PeerDialInfo{TransportName: p.t.dialer.Name(), DialAddr: id}
+		}
+	}
+	return p.PeerDetails.DialInfo()
+}
+
+// UpdateConnStats is a no-op for peers whose identity hasn't been verified yet.
+func (p *msgStreamPeer) UpdateConnStats(success bool) {
+	if p.PeerDetails != nil {
+		p.PeerDetails.UpdateConnStats(success)
+	}
+}
+
+type msgStreamReadableSubscription struct {
+	*msgStreamPeer
+}
+
+func (sub *msgStreamReadableSubscription) Read() (_ *SubscriptionMsg, err error) {
+	defer func() { sub.UpdateConnStats(err == nil) }()
+
+	for {
+		msg, err := sub.readMsg()
+		if err != nil {
+			return nil, errors.Errorf("error reading from subscription: %v", err)
+		}
+
+		switch msg.Type {
+		case MsgType_Put:
+			tx, ok := msg.Payload.(Tx)
+			if !ok {
+				return nil, errors.Errorf("Put message: bad payload: (%T) %v", msg.Payload, msg.Payload)
+			}
+			return &SubscriptionMsg{Tx: &tx}, nil
+
+		case MsgType_Private:
+			encryptedTx, ok := msg.Payload.(EncryptedTx)
+			if !ok {
+				return nil, errors.Errorf("Private message: bad payload: (%T) %v", msg.Payload, msg.Payload)
+			}
+			tx, err := sub.t.decryptTx(encryptedTx)
+			if err != nil {
+				return nil, err
+			}
+			return &SubscriptionMsg{Tx: &tx, EncryptedTx: &encryptedTx}, nil
+
+		case MsgType_Ack:
+			// Acks for the txs that we've written to the subscription
+			ackMsg, ok := msg.Payload.(libp2pAckMsg)
+			if !ok {
+				return nil, errors.Errorf("Ack message: bad payload: (%T) %v", msg.Payload, msg.Payload)
+			}
+			sub.t.host.HandleAckReceived(ackMsg.StateURI, ackMsg.TxID, sub.msgStreamPeer)
+
+		default:
+			return nil, errors.New("protocol error, expecting MsgType_Put or MsgType_Private")
+		}
+	}
+}
+
+type msgStreamWritableSubscription struct {
+	*msgStreamPeer
+}
+
+func (sub *msgStreamWritableSubscription) Put(ctx context.Context, tx *Tx, state tree.Node, leaves []types.ID) (err error) {
+	defer func() { sub.UpdateConnStats(err == nil) }()
+
+	if sub.stream.isClosed() {
+		return errors.Wrap(types.ErrConnection, "subscriber closed the subscription")
+	}
+	return sub.msgStreamPeer.Put(ctx, tx, state, leaves)
+}
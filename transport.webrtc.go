@@ -0,0 +1,633 @@
+package redwood
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/pkg/errors"
+
+	"redwood.dev/identity"
+	"redwood.dev/types"
+	"redwood.dev/utils"
+)
+
+// The webrtc transport connects nodes directly over WebRTC data channels.  It
+// carries the same messages as the websocket transport, but because a WebRTC
+// connection can carry any number of data channels, every request simply opens
+// a new one (just as the libp2p transport opens a new stream), and its first
+// message determines what the channel is for.
+//
+// redwood.js's webrtc transport speaks a different protocol (PeerJS signaling
+// and unframed JSON), so browsers can't connect to this transport yet.
+//
+// WebRTC connections have to be negotiated over some other channel.  Nodes
+// running the http transport accept offers at `POST /__webrtc` and reply with
+// an answer, so a webrtc peer's dial address is the URL of its http transport.
+// ICE candidates aren't trickled: each side finishes gathering them before it
+// sends its session description.
+//
+// Data channel messages are limited in size, so each message is encoded as JSON
+// and split into chunks.  The first byte of each chunk is 1 if it's the last
+// chunk of a message and 0 otherwise.
+//
+// Nodes that dial us are identified by challenging them over their own
+// connection, exactly as the websocket transport does.
+type webrtcTransport struct {
+	msgStreamTransport
+	chStop chan struct{}
+
+	ownURL       string
+	api          *webrtc.API
+	rtcConfig    webrtc.Configuration
+	signalClient *utils.HTTPClient
+
+	conns           map[*webrtcConn]struct{}
+	connsByDialAddr map[string]*webrtcConn
+	connsMu         sync.Mutex
+}
+
+// webrtcSignal is the body of a `POST /__webrtc` request.
+type webrtcSignal struct {
+	Offer       webrtc.SessionDescription `json:"offer"`
+	ReachableAt string                    `json:"reachableAt,omitempty"`
+}
+
+const (
+	webrtcConnectTimeout = 30 * time.Second
+	webrtcChunkSize      = 16 * 1024
+	webrtcMaxMsgSize     = 64 * 1024 * 1024
+	webrtcControlLabel   = "redwood-control"
+	webrtcChannelLabel   = "redwood"
+)
+
+func NewWebRTCTransport(
+	iceServers []string,
+	controllerHub ControllerHub,
+	keyStore identity.KeyStore,
+	refStore RefStore,
+	peerStore PeerStore,
+) (Transport, error) {
+	var settingEngine webrtc.SettingEngine
+	// Nodes on the same machine can connect over loopback
+	settingEngine.SetIncludeLoopbackCandidate(true)
+
+	var rtcConfig webrtc.Configuration
+	if len(iceServers) > 0 {
+		rtcConfig.ICEServers = []webrtc.ICEServer{{URLs: iceServers}}
+	}
+
+	t := &webrtcTransport{
+		chStop:          make(chan struct{}),
+		api:             webrtc.NewAPI(webrtc.WithSettingEngine(settingEngine)),
+		rtcConfig:       rtcConfig,
+		signalClient:    utils.MakeHTTPClient(webrtcConnectTimeout, 30*time.Second),
+		conns:           make(map[*webrtcConn]struct{}),
+		connsByDialAddr: make(map[string]*webrtcConn),
+	}
+	t.msgStreamTransport = newMsgStreamTransport(t, controllerHub, keyStore, refStore, peerStore)
+	return t, nil
+}
+
+func (t *webrtcTransport) Start() error {
+	t.SetLogLabel("webrtc")
+
+	// Offers reach us through the http transport, so without it, we can dial
+	// other nodes but they can't dial us
+	httpTpt, ok := t.host.Transport("http").(*httpTransport)
+	if !ok {
+		t.Warnf("http transport is disabled, webrtc peers will not be able to dial us")
+		return nil
+	}
+	t.ownURL = httpTpt.ownURL
+	t.Infof(0, "accepting webrtc offers at %v", t.ownURL)
+
+	// Update our node's info in the peer store
+	t.peerStore.AddDialInfos([]PeerDialInfo{{t.Name(), t.ownURL}})
+	return nil
+}
+
+func (t *webrtcTransport) Close() {
+	close(t.chStop)
+
+	t.connsMu.Lock()
+	var conns []*webrtcConn
+	for conn := range t.conns {
+		conns = append(conns, conn)
+	}
+	t.connsMu.Unlock()
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+	t.signalClient.Close()
+}
+
+func (t *webrtcTransport) Name() string {
+	return "webrtc"
+}
+
+// handleOffer answers an offer that was POSTed to the http transport.  The
+// connection is set up in the background once the offering node receives our
+// answer.
+func (t *webrtcTransport) handleOffer(ctx context.Context, signal webrtcSignal) (webrtc.SessionDescription, error) {
+	if signal.Offer.Type != webrtc.SDPTypeOffer {
+		return webrtc.SessionDescription{}, errors.Wrap(ErrProtocol, "expected an offer")
+	}
+
+	pc, err := t.api.NewPeerConnection(t.rtcConfig)
+	if err != nil {
+		return webrtc.SessionDescription{}, errors.WithStack(err)
+	}
+	conn := t.newConn(pc, "", true)
+
+	answer, err := func() (webrtc.SessionDescription, error) {
+		err := pc.SetRemoteDescription(signal.Offer)
+		if err != nil {
+			return webrtc.SessionDescription{}, errors.Wrap(ErrProtocol, err.Error())
+		}
+		answer, err := pc.CreateAnswer(nil)
+		if err != nil {
+			return webrtc.SessionDescription{}, errors.WithStack(err)
+		}
+		return t.setLocalDescription(ctx, pc, answer)
+	}()
+	if err != nil {
+		conn.Close()
+		return webrtc.SessionDescription{}, err
+	}
+
+	// Other Go nodes tell us where they accept offers.  We don't trust this
+	// until we've verified it by dialing them, so it's only a hint for the
+	// peer store.
+	if signal.ReachableAt != "" && signal.ReachableAt != t.ownURL {
+		t.peerStore.AddDialInfos([]PeerDialInfo{{t.Name(), signal.ReachableAt}})
+	}
+
+	go func() {
+		err := t.identifyIncomingConn(conn)
+		if err != nil {
+			t.Errorf("could not verify identity of webrtc peer: %v", err)
+			conn.Close()
+		}
+	}()
+	return answer, nil
+}
+
+// setLocalDescription waits for ICE gathering to finish so that the returned
+// description includes all of our candidates.
+func (t *webrtcTransport) setLocalDescription(ctx context.Context, pc *webrtc.PeerConnection, desc webrtc.SessionDescription) (webrtc.SessionDescription, error) {
+	chGathered := webrtc.GatheringCompletePromise(pc)
+
+	err := pc.SetLocalDescription(desc)
+	if err != nil {
+		return webrtc.SessionDescription{}, errors.WithStack(err)
+	}
+
+	select {
+	case <-chGathered:
+	case <-ctx.Done():
+		return webrtc.SessionDescription{}, ctx.Err()
+	}
+	return *pc.LocalDescription(), nil
+}
+
+// identifyIncomingConn challenges the node on the other end of a connection
+// that we answered to prove which addresses it holds.  Incoming channels aren't
+// handled until this succeeds.
+func (t *webrtcTransport) identifyIncomingConn(conn *webrtcConn) error {
+	ctx, cancel := utils.CombinedContext(t.chStop, conn.chClosed, webrtcConnectTimeout)
+	defer cancel()
+
+	peerDetails, err := t.identifyPeer(ctx, conn)
+	if err != nil {
+		return err
+	}
+	conn.setPeerDetails(peerDetails)
+	return nil
+}
+
+func (t *webrtcTransport) newConn(pc *webrtc.PeerConnection, dialAddr string, incoming bool) *webrtcConn {
+	conn := &webrtcConn{
+		t:        t,
+		pc:       pc,
+		dialAddr: dialAddr,
+		connID:   types.RandomID().Hex(),
+		incoming: incoming,
+		channels: make(map[*webrtcChannel]struct{}),
+		chReady:  make(chan struct{}),
+		chClosed: make(chan struct{}),
+	}
+
+	pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		switch state {
+		case webrtc.PeerConnectionStateFailed, webrtc.PeerConnectionStateClosed:
+			conn.Close()
+		}
+	})
+	pc.OnDataChannel(func(dc *webrtc.DataChannel) {
+		// The control channel only exists so that the offer negotiates a
+		// data channel transport
+		if dc.Label() == webrtcControlLabel {
+			return
+		}
+		channel := conn.addChannel(dc)
+		go t.handleIncomingChannel(conn, channel)
+	})
+
+	t.connsMu.Lock()
+	t.conns[conn] = struct{}{}
+	if dialAddr != "" {
+		t.connsByDialAddr[dialAddr] = conn
+	}
+	t.connsMu.Unlock()
+	return conn
+}
+
+func (t *webrtcTransport) removeConn(conn *webrtcConn) {
+	t.connsMu.Lock()
+	defer t.connsMu.Unlock()
+
+	delete(t.conns, conn)
+	if conn.dialAddr != "" && t.connsByDialAddr[conn.dialAddr] == conn {
+		delete(t.connsByDialAddr, conn.dialAddr)
+	}
+}
+
+// connTo returns the open connection to the given dial address, dialing it if
+// there isn't one yet.
+func (t *webrtcTransport) connTo(ctx context.Context, dialAddr string) (msgConn, error) {
+	t.connsMu.Lock()
+	conn, exists := t.connsByDialAddr[dialAddr]
+	t.connsMu.Unlock()
+	if exists && !conn.isClosed() {
+		return conn, nil
+	}
+
+	ctx, cancel := utils.CombinedContext(ctx, t.chStop, webrtcConnectTimeout)
+	defer cancel()
+
+	pc, err := t.api.NewPeerConnection(t.rtcConfig)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	conn = t.newConn(pc, dialAddr, false)
+
+	err = func() error {
+		// An offer has to include at least one data channel
+		control, err := pc.CreateDataChannel(webrtcControlLabel, nil)
+		if err != nil {
+			return err
+		}
+		chOpen := make(chan struct{})
+		control.OnOpen(func() { close(chOpen) })
+
+		offer, err := pc.CreateOffer(nil)
+		if err != nil {
+			return err
+		}
+		offer, err = t.setLocalDescription(ctx, pc, offer)
+		if err != nil {
+			return err
+		}
+
+		answer, err := t.signal(ctx, dialAddr, offer)
+		if err != nil {
+			return err
+		}
+		err = pc.SetRemoteDescription(answer)
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-chOpen:
+			return nil
+		case <-conn.chClosed:
+			return errors.New("connection failed")
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}()
+	if err != nil {
+		conn.Close()
+		return nil, errors.Wrapf(types.ErrConnection, "(peer %v): %v", dialAddr, err)
+	}
+
+	conn.setPeerDetails(t.makePeer(dialAddr).PeerDetails)
+	return conn, nil
+}
+
+// signal sends our offer to the http transport of the node at the given dial
+// address and returns its answer.
+func (t *webrtcTransport) signal(ctx context.Context, dialAddr string, offer webrtc.SessionDescription) (webrtc.SessionDescription, error) {
+	body, err := json.Marshal(webrtcSignal{Offer: offer, ReachableAt: t.ownURL})
+	if err != nil {
+		return webrtc.SessionDescription{}, errors.WithStack(err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimSuffix(dialAddr, "/")+"/__webrtc", bytes.NewReader(body))
+	if err != nil {
+		return webrtc.SessionDescription{}, errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.signalClient.Do(req)
+	if err != nil {
+		return webrtc.SessionDescription{}, errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		bs, _ := ioutil.ReadAll(resp.Body)
+		return webrtc.SessionDescription{}, errors.Errorf("signaling request errored: (%v) %v", resp.StatusCode, string(bs))
+	}
+
+	var answer webrtc.SessionDescription
+	err = json.NewDecoder(resp.Body).Decode(&answer)
+	if err != nil {
+		return webrtc.SessionDescription{}, errors.WithStack(err)
+	} else if answer.Type != webrtc.SDPTypeAnswer {
+		return webrtc.SessionDescription{}, errors.Wrap(ErrProtocol, "expected an answer")
+	}
+	return answer, nil
+}
+
+func (t *webrtcTransport) handleIncomingChannel(conn *webrtcConn, channel *webrtcChannel) {
+	select {
+	case <-conn.chReady:
+	case <-conn.chClosed:
+		return
+	}
+	t.handleIncomingStream(&msgStreamPeer{PeerDetails: conn.peerDetails, t: &t.msgStreamTransport, dialAddr: conn.dialAddr, conn: conn, stream: channel})
+}
+
+func (t *webrtcTransport) NewPeerConn(ctx context.Context, dialAddr string) (Peer, error) {
+	if dialAddr == t.ownURL {
+		return nil, errors.WithStack(ErrPeerIsSelf)
+	}
+	return t.makePeer(dialAddr), nil
+}
+
+// ProvidersOfStateURI returns every webrtc peer that we know how to dial.  Like
+// the websocket transport, we rely on the host's peer store to find the rest.
+func (t *webrtcTransport) ProvidersOfStateURI(ctx context.Context, stateURI string) (<-chan Peer, error) {
+	return t.peersFromPeerStore(ctx, t.ownURL), nil
+}
+
+func (t *webrtcTransport) ProvidersOfRef(ctx context.Context, refID types.RefID) (<-chan Peer, error) {
+	return nil, types.ErrUnimplemented
+}
+
+func (t *webrtcTransport) PeersClaimingAddress(ctx context.Context, address types.Address) (<-chan Peer, error) {
+	return nil, types.ErrUnimplemented
+}
+
+func (t *webrtcTransport) AnnounceRef(ctx context.Context, refID types.RefID) error {
+	return types.ErrUnimplemented
+}
+
+type webrtcConn struct {
+	t        *webrtcTransport
+	pc       *webrtc.PeerConnection
+	dialAddr string
+	connID   string
+	incoming bool
+
+	peerDetails PeerDetails
+	chReady     chan struct{}
+
+	channels   map[*webrtcChannel]struct{}
+	channelsMu sync.Mutex
+	chClosed   chan struct{}
+	closeOnce  sync.Once
+}
+
+func (conn *webrtcConn) setPeerDetails(peerDetails PeerDetails) {
+	conn.peerDetails = peerDetails
+	close(conn.chReady)
+}
+
+func (conn *webrtcConn) isClosed() bool {
+	select {
+	case <-conn.chClosed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (conn *webrtcConn) Close() {
+	conn.closeOnce.Do(func() {
+		close(conn.chClosed)
+		conn.t.removeConn(conn)
+
+		conn.channelsMu.Lock()
+		channels := conn.channels
+		conn.channels = make(map[*webrtcChannel]struct{})
+		conn.channelsMu.Unlock()
+
+		for channel := range channels {
+			channel.closeLocally()
+		}
+
+		err := conn.pc.Close()
+		if err != nil {
+			conn.t.Debugf("error closing webrtc connection: %v", err)
+		}
+	})
+}
+
+// openChannel opens a new data channel and waits for the other side to accept
+// it.
+func (conn *webrtcConn) openChannel(ctx context.Context) (*webrtcChannel, error) {
+	dc, err := conn.pc.CreateDataChannel(webrtcChannelLabel, nil)
+	if err != nil {
+		return nil, errors.Wrap(types.ErrConnection, err.Error())
+	}
+	channel := conn.addChannel(dc)
+
+	select {
+	case <-channel.chOpen:
+		return channel, nil
+	case <-channel.chClosed:
+		return nil, errors.Wrap(types.ErrConnection, "channel closed before it opened")
+	case <-ctx.Done():
+		channel.Close()
+		return nil, ctx.Err()
+	}
+}
+
+func (conn *webrtcConn) openStream(ctx context.Context) (msgStream, error) {
+	channel, err := conn.openChannel(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return channel, nil
+}
+
+func (conn *webrtcConn) incomingID() string {
+	if conn.incoming {
+		return conn.connID
+	}
+	return ""
+}
+
+func (conn *webrtcConn) addChannel(dc *webrtc.DataChannel) *webrtcChannel {
+	channel := &webrtcChannel{
+		conn:     conn,
+		dc:       dc,
+		inbox:    utils.NewMailbox(0),
+		chOpen:   make(chan struct{}),
+		chClosed: make(chan struct{}),
+	}
+	dc.OnOpen(func() { close(channel.chOpen) })
+	dc.OnMessage(channel.handleChunk)
+	dc.OnClose(channel.closeLocally)
+
+	conn.channelsMu.Lock()
+	connClosed := conn.isClosed()
+	if !connClosed {
+		conn.channels[channel] = struct{}{}
+	}
+	conn.channelsMu.Unlock()
+
+	if connClosed {
+		channel.closeLocally()
+	}
+	return channel
+}
+
+type webrtcChannel struct {
+	conn      *webrtcConn
+	dc        *webrtc.DataChannel
+	inbox     *utils.Mailbox
+	partial   []byte
+	writeMu   sync.Mutex
+	chOpen    chan struct{}
+	chClosed  chan struct{}
+	closeOnce sync.Once
+}
+
+func (ch *webrtcChannel) writeMsg(msg Msg) error {
+	if ch.isClosed() {
+		return errors.Wrap(types.ErrConnection, "channel is closed")
+	}
+
+	bs, err := json.Marshal(msg)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// The chunks of one message must not be interleaved with another's
+	ch.writeMu.Lock()
+	defer ch.writeMu.Unlock()
+
+	for {
+		n := len(bs)
+		final := byte(1)
+		if n > webrtcChunkSize {
+			n = webrtcChunkSize
+			final = 0
+		}
+
+		chunk := make([]byte, n+1)
+		chunk[0] = final
+		copy(chunk[1:], bs[:n])
+
+		err := ch.dc.Send(chunk)
+		if err != nil {
+			return errors.Wrap(types.ErrConnection, err.Error())
+		}
+
+		bs = bs[n:]
+		if final == 1 {
+			return nil
+		}
+	}
+}
+
+// handleChunk is called by the data channel for each chunk that arrives, in
+// order.
+func (ch *webrtcChannel) handleChunk(dcMsg webrtc.DataChannelMessage) {
+	if len(dcMsg.Data) == 0 {
+		return
+	}
+
+	ch.partial = append(ch.partial, dcMsg.Data[1:]...)
+	if len(ch.partial) > webrtcMaxMsgSize {
+		ch.conn.t.Errorf("message on webrtc channel is too large")
+		ch.Close()
+		return
+	} else if dcMsg.Data[0] != 1 {
+		return
+	}
+
+	bs := ch.partial
+	ch.partial = nil
+
+	var msg Msg
+	err := json.Unmarshal(bs, &msg)
+	if err != nil {
+		ch.conn.t.Errorf("bad message on webrtc channel: %v", err)
+		ch.Close()
+		return
+	}
+	ch.inbox.Deliver(msg)
+}
+
+// readMsg returns the next message on the channel.  Messages that arrived
+// before the channel was closed are still returned, followed by io.EOF.
+func (ch *webrtcChannel) readMsg(ctx context.Context) (Msg, error) {
+	for {
+		if x := ch.inbox.Retrieve(); x != nil {
+			return x.(Msg), nil
+		}
+
+		select {
+		case <-ch.inbox.Notify():
+		case <-ch.chClosed:
+			if x := ch.inbox.Retrieve(); x != nil {
+				return x.(Msg), nil
+			}
+			return Msg{}, io.EOF
+		case <-ctx.Done():
+			return Msg{}, ctx.Err()
+		}
+	}
+}
+
+func (ch *webrtcChannel) isClosed() bool {
+	select {
+	case <-ch.chClosed:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close closes the data channel, which closes it on the other side as well.
+func (ch *webrtcChannel) Close() error {
+	if ch.isClosed() {
+		return nil
+	}
+	ch.closeLocally()
+	return ch.dc.Close()
+}
+
+func (ch *webrtcChannel) closeLocally() {
+	ch.closeOnce.Do(func() {
+		close(ch.chClosed)
+
+		ch.conn.channelsMu.Lock()
+		defer ch.conn.channelsMu.Unlock()
+		delete(ch.conn.channels, ch)
+	})
+}
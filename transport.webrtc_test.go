@@ -0,0 +1,142 @@
+package redwood_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"redwood.dev"
+	"redwood.dev/identity"
+	"redwood.dev/testutils"
+	"redwood.dev/types"
+)
+
+// setupWebRTCHost starts a host with a webrtc transport.  If withHTTP is true,
+// it also runs an http transport, so that other nodes can dial it.
+func setupWebRTCHost(t *testing.T, withHTTP bool) (redwood.Host, identity.KeyStore, func()) {
+	t.Helper()
+
+	return testutils.StartHost(t, func(controllerHub redwood.ControllerHub, keyStore identity.KeyStore, refStore redwood.RefStore, peerStore redwood.PeerStore) ([]redwood.Transport, error) {
+		webrtcTpt, err := redwood.NewWebRTCTransport(nil, controllerHub, keyStore, refStore, peerStore)
+		if err != nil {
+			return nil, err
+		}
+		transports := []redwood.Transport{webrtcTpt}
+
+		if withHTTP {
+			listener, err := net.Listen("tcp", "localhost:0")
+			if err != nil {
+				return nil, err
+			}
+			listenAddr := listener.Addr().String()
+			listener.Close()
+
+			httpTpt, err := redwood.NewHTTPTransport(listenAddr, "", "", controllerHub, keyStore, refStore, peerStore, [32]byte{}, "", "", true)
+			if err != nil {
+				return nil, err
+			}
+			transports = append(transports, httpTpt)
+		}
+		return transports, nil
+	})
+}
+
+func TestWebRTCTransport_Subscribe(t *testing.T) {
+	// A accepts offers over its http transport.  B only runs the webrtc
+	// transport, so everything that it receives came over a data channel.
+	hostA, keyStoreA, cleanupA := setupWebRTCHost(t, true)
+	defer cleanupA()
+	hostB, keyStoreB, cleanupB := setupWebRTCHost(t, false)
+	defer cleanupB()
+
+	stateURI := "foo.com/bar"
+
+	waitForTx := func(h redwood.Host, txID types.ID) {
+		t.Helper()
+		require.Eventually(t, func() bool {
+			have, err := h.Controllers().HaveTx(stateURI, txID)
+			return err == nil && have
+		}, 30*time.Second, 100*time.Millisecond, fmt.Sprintf("tx %v never arrived", txID.Pretty()))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	genesis := redwood.Tx{ID: redwood.GenesisTxID, StateURI: stateURI, Patches: []redwood.Patch{mustParsePatch(t, `.count = 1`)}}
+	err := hostA.SendTx(ctx, genesis)
+	require.NoError(t, err)
+	waitForTx(hostA, redwood.GenesisTxID)
+
+	// B dials A and receives its history
+	hostB.AddPeer(redwood.PeerDialInfo{TransportName: "webrtc", DialAddr: redwood.WebRTCOwnURL(hostA)})
+	_, err = hostB.Subscribe(ctx, stateURI, redwood.SubscriptionType_Txs, nil, nil)
+	require.NoError(t, err)
+	waitForTx(hostB, redwood.GenesisTxID)
+
+	// A verified B's identity when B connected
+	identityB, err := keyStoreB.DefaultPublicIdentity()
+	require.NoError(t, err)
+	require.NotEmpty(t, redwood.PeerStoreOf(hostA).PeersFromTransportWithAddress("webrtc", identityB.Address()))
+
+	// New txs flow in both directions over the same connection
+	tx1 := redwood.Tx{ID: types.RandomID(), StateURI: stateURI, Patches: []redwood.Patch{mustParsePatch(t, `.count = 2`)}}
+	err = hostA.SendTx(ctx, tx1)
+	require.NoError(t, err)
+	waitForTx(hostB, tx1.ID)
+
+	tx2 := redwood.Tx{ID: types.RandomID(), StateURI: stateURI, Parents: []types.ID{tx1.ID}, Patches: []redwood.Patch{mustParsePatch(t, `.count = 3`)}}
+	err = hostB.SendTx(ctx, tx2)
+	require.NoError(t, err)
+	waitForTx(hostA, tx2.ID)
+
+	identityA, err := keyStoreA.DefaultPublicIdentity()
+	require.NoError(t, err)
+	tx, err := hostB.Controllers().FetchTx(stateURI, tx1.ID)
+	require.NoError(t, err)
+	require.Equal(t, identityA.Address(), tx.From)
+}
+
+func TestWebRTCChannel_LargeMessages(t *testing.T) {
+	hostA, _, cleanupA := setupWebRTCHost(t, true)
+	defer cleanupA()
+	hostB, _, cleanupB := setupWebRTCHost(t, false)
+	defer cleanupB()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// A tx that's much larger than a single chunk
+	stateURI := "foo.com/bar"
+	bigValue := make([]byte, 10*redwood.WebRTCChunkSize)
+	for i := range bigValue {
+		bigValue[i] = 'a' + byte(i%26)
+	}
+	genesis := redwood.Tx{ID: redwood.GenesisTxID, StateURI: stateURI, Patches: []redwood.Patch{mustParsePatch(t, fmt.Sprintf(`.text = "%s"`, bigValue))}}
+	err := hostA.SendTx(ctx, genesis)
+	require.NoError(t, err)
+
+	peer, err := hostB.Transport("webrtc").NewPeerConn(ctx, redwood.WebRTCOwnURL(hostA))
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		have, err := hostA.Controllers().HaveTx(stateURI, redwood.GenesisTxID)
+		return err == nil && have
+	}, 10*time.Second, 100*time.Millisecond)
+
+	sub, err := peer.FetchHistory(ctx, stateURI, redwood.FetchHistoryOpts{})
+	require.NoError(t, err)
+	defer sub.Close()
+
+	msg, err := sub.Read()
+	require.NoError(t, err)
+	require.Equal(t, redwood.GenesisTxID, msg.Tx.ID)
+	require.Len(t, msg.Tx.Patches, 1)
+
+	val, is := msg.Tx.Patches[0].Val.(string)
+	require.True(t, is)
+	require.Equal(t, string(bigValue), val)
+}
@@ -0,0 +1,526 @@
+package redwood
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+
+	"redwood.dev/identity"
+	"redwood.dev/types"
+	"redwood.dev/utils"
+)
+
+// The websocket transport carries the same messages as the libp2p transport.
+// Because a websocket is a single ordered stream, each connection is split
+// into logical channels that play the role of libp2p streams: every request
+// opens a new channel, and its first message determines what the channel is
+// for.  The side that dialed the connection uses odd channel IDs, and the side
+// that accepted it uses even ones, so that both can open channels without
+// coordinating.
+//
+// Nodes that dial us are always identified by challenging them over their own
+// connection.  This lets browsers (which we can't dial) subscribe, write txs
+// and receive private txs over a single connection.
+type websocketTransport struct {
+	msgStreamTransport
+	chStop chan struct{}
+
+	listenAddr string
+	ownURL     string
+	srv        *http.Server
+	upgrader   websocket.Upgrader
+	dialer     *websocket.Dialer
+
+	conns           map[*wsConn]struct{}
+	connsByDialAddr map[string]*wsConn
+	connsMu         sync.Mutex
+}
+
+const (
+	wsWriteTimeout    = 10 * time.Second
+	wsPingInterval    = 30 * time.Second
+	wsIdentifyTimeout = 10 * time.Second
+)
+
+func NewWebSocketTransport(
+	listenAddr string,
+	reachableAt string,
+	controllerHub ControllerHub,
+	keyStore identity.KeyStore,
+	refStore RefStore,
+	peerStore PeerStore,
+) (Transport, error) {
+	var ownURL string
+	if reachableAt != "" {
+		ownURL = reachableAt
+	} else {
+		ownURL = "ws://" + listenAddr
+		if len(listenAddr) > 0 && listenAddr[0] == ':' {
+			ownURL = "ws://localhost" + listenAddr
+		}
+	}
+
+	t := &websocketTransport{
+		chStop:     make(chan struct{}),
+		listenAddr: listenAddr,
+		ownURL:     ownURL,
+		upgrader: websocket.Upgrader{
+			// Browsers connect from pages served by any origin
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+		dialer:          &websocket.Dialer{HandshakeTimeout: 10 * time.Second},
+		conns:           make(map[*wsConn]struct{}),
+		connsByDialAddr: make(map[string]*wsConn),
+	}
+	t.msgStreamTransport = newMsgStreamTransport(t, controllerHub, keyStore, refStore, peerStore)
+	return t, nil
+}
+
+func (t *websocketTransport) Start() error {
+	t.SetLogLabel("websocket")
+	t.Infof(0, "opening websocket transport at %v", t.listenAddr)
+
+	listener, err := net.Listen("tcp", t.listenAddr)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// Update our node's info in the peer store
+	t.peerStore.AddDialInfos([]PeerDialInfo{{t.Name(), t.ownURL}})
+
+	t.srv = &http.Server{Handler: t}
+	go func() {
+		err := t.srv.Serve(listener)
+		if err != nil && err != http.ErrServerClosed {
+			t.Errorf("websocket transport stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+func (t *websocketTransport) Close() {
+	close(t.chStop)
+
+	err := t.srv.Close()
+	if err != nil {
+		t.Errorf("error closing websocket server: %v", err)
+	}
+
+	t.connsMu.Lock()
+	var conns []*wsConn
+	for conn := range t.conns {
+		conns = append(conns, conn)
+	}
+	t.connsMu.Unlock()
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
+
+func (t *websocketTransport) Name() string {
+	return "websocket"
+}
+
+func (t *websocketTransport) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	wsconn, err := t.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		t.Errorf("error upgrading websocket connection: %v", err)
+		return
+	}
+
+	// Other Go nodes tell us where they can be reached.  We don't trust this
+	// until we've verified it by dialing them, so it's only a hint for the
+	// peer store.
+	if reachableAt := r.Header.Get("Reachable-At"); reachableAt != "" && reachableAt != t.ownURL {
+		t.peerStore.AddDialInfos([]PeerDialInfo{{t.Name(), reachableAt}})
+	}
+
+	conn := t.newConn(wsconn, "", r.RemoteAddr, true)
+	go func() {
+		err := t.identifyIncomingConn(conn)
+		if err != nil {
+			t.Errorf("could not verify identity of websocket peer %v: %v", r.RemoteAddr, err)
+			conn.Close()
+		}
+	}()
+}
+
+// identifyIncomingConn challenges the node on the other end of a connection
+// that it accepted to prove which addresses it holds.  Incoming channels aren't
+// handled until this succeeds.
+func (t *websocketTransport) identifyIncomingConn(conn *wsConn) error {
+	ctx, cancel := utils.CombinedContext(t.chStop, conn.chClosed, wsIdentifyTimeout)
+	defer cancel()
+
+	peerDetails, err := t.identifyPeer(ctx, conn)
+	if err != nil {
+		return err
+	}
+	conn.setPeerDetails(peerDetails)
+	return nil
+}
+
+func (t *websocketTransport) newConn(wsconn *websocket.Conn, dialAddr, remoteAddr string, incoming bool) *wsConn {
+	conn := &wsConn{
+		t:          t,
+		wsconn:     wsconn,
+		dialAddr:   dialAddr,
+		remoteAddr: remoteAddr,
+		incoming:   incoming,
+		channels:   make(map[uint64]*wsChannel),
+		chReady:    make(chan struct{}),
+		chClosed:   make(chan struct{}),
+	}
+	if incoming {
+		conn.nextChannelID = 2
+	} else {
+		conn.nextChannelID = 1
+	}
+
+	t.connsMu.Lock()
+	t.conns[conn] = struct{}{}
+	if dialAddr != "" {
+		t.connsByDialAddr[dialAddr] = conn
+	}
+	t.connsMu.Unlock()
+
+	go conn.readLoop()
+	go conn.pingLoop()
+	return conn
+}
+
+func (t *websocketTransport) removeConn(conn *wsConn) {
+	t.connsMu.Lock()
+	defer t.connsMu.Unlock()
+
+	delete(t.conns, conn)
+	if conn.dialAddr != "" && t.connsByDialAddr[conn.dialAddr] == conn {
+		delete(t.connsByDialAddr, conn.dialAddr)
+	}
+}
+
+// connTo returns the open connection to the given dial address, dialing it if
+// there isn't one yet.
+func (t *websocketTransport) connTo(ctx context.Context, dialAddr string) (msgConn, error) {
+	t.connsMu.Lock()
+	conn, exists := t.connsByDialAddr[dialAddr]
+	t.connsMu.Unlock()
+	if exists && !conn.isClosed() {
+		return conn, nil
+	}
+
+	header := http.Header{}
+	header.Set("Reachable-At", t.ownURL)
+
+	wsconn, _, err := t.dialer.DialContext(ctx, dialAddr, header)
+	if err != nil {
+		return nil, errors.Wrapf(types.ErrConnection, "(peer %v): %v", dialAddr, err)
+	}
+
+	conn = t.newConn(wsconn, dialAddr, dialAddr, false)
+	conn.setPeerDetails(t.makePeer(dialAddr).PeerDetails)
+	return conn, nil
+}
+
+func (t *websocketTransport) handleIncomingChannel(conn *wsConn, channel *wsChannel) {
+	select {
+	case <-conn.chReady:
+	case <-conn.chClosed:
+		return
+	}
+	t.handleIncomingStream(&msgStreamPeer{PeerDetails: conn.peerDetails, t: &t.msgStreamTransport, dialAddr: conn.dialAddr, conn: conn, stream: channel})
+}
+
+func (t *websocketTransport) NewPeerConn(ctx context.Context, dialAddr string) (Peer, error) {
+	if dialAddr == t.ownURL {
+		return nil, errors.WithStack(ErrPeerIsSelf)
+	}
+	return t.makePeer(dialAddr), nil
+}
+
+// ProvidersOfStateURI returns every websocket peer that we know how to dial.
+// Websockets have no content routing of their own, so the host's peer store
+// (which remembers which peers have served which state URIs) does the rest.
+func (t *websocketTransport) ProvidersOfStateURI(ctx context.Context, stateURI string) (<-chan Peer, error) {
+	return t.peersFromPeerStore(ctx, t.ownURL), nil
+}
+
+func (t *websocketTransport) ProvidersOfRef(ctx context.Context, refID types.RefID) (<-chan Peer, error) {
+	return nil, types.ErrUnimplemented
+}
+
+func (t *websocketTransport) PeersClaimingAddress(ctx context.Context, address types.Address) (<-chan Peer, error) {
+	return nil, types.ErrUnimplemented
+}
+
+func (t *websocketTransport) AnnounceRef(ctx context.Context, refID types.RefID) error {
+	return types.ErrUnimplemented
+}
+
+// wsFrame is the unit that's written to a websocket.  A frame either carries a
+// message on a channel or closes the channel.
+type wsFrame struct {
+	Channel uint64          `json:"channel"`
+	Msg     json.RawMessage `json:"msg,omitempty"`
+	Close   bool            `json:"close,omitempty"`
+}
+
+type wsConn struct {
+	t          *websocketTransport
+	wsconn     *websocket.Conn
+	dialAddr   string
+	remoteAddr string
+	incoming   bool
+	writeMu    sync.Mutex
+
+	peerDetails PeerDetails
+	chReady     chan struct{}
+
+	channels           map[uint64]*wsChannel
+	nextChannelID      uint64
+	maxRemoteChannelID uint64
+	channelsMu         sync.Mutex
+	chClosed           chan struct{}
+	closeOnce          sync.Once
+}
+
+func (conn *wsConn) setPeerDetails(peerDetails PeerDetails) {
+	conn.peerDetails = peerDetails
+	close(conn.chReady)
+}
+
+func (conn *wsConn) isClosed() bool {
+	select {
+	case <-conn.chClosed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (conn *wsConn) Close() {
+	conn.closeOnce.Do(func() {
+		close(conn.chClosed)
+		conn.wsconn.Close()
+		conn.t.removeConn(conn)
+
+		conn.channelsMu.Lock()
+		channels := conn.channels
+		conn.channels = make(map[uint64]*wsChannel)
+		conn.channelsMu.Unlock()
+
+		for _, channel := range channels {
+			channel.closeLocally()
+		}
+	})
+}
+
+// openChannel opens a new channel that we initiated.  If `id` is given, the
+// other side initiated it.
+func (conn *wsConn) openChannel(id *uint64) *wsChannel {
+	conn.channelsMu.Lock()
+	defer conn.channelsMu.Unlock()
+
+	channel := &wsChannel{
+		conn:     conn,
+		inbox:    utils.NewMailbox(0),
+		chClosed: make(chan struct{}),
+	}
+	if id != nil {
+		channel.id = *id
+	} else {
+		channel.id = conn.nextChannelID
+		conn.nextChannelID += 2
+	}
+
+	if conn.isClosed() {
+		close(channel.chClosed)
+		return channel
+	}
+	conn.channels[channel.id] = channel
+	return channel
+}
+
+func (conn *wsConn) openStream(ctx context.Context) (msgStream, error) {
+	return conn.openChannel(nil), nil
+}
+
+func (conn *wsConn) incomingID() string {
+	if conn.incoming {
+		return conn.remoteAddr
+	}
+	return ""
+}
+
+func (conn *wsConn) readLoop() {
+	defer conn.Close()
+
+	for {
+		_, bs, err := conn.wsconn.ReadMessage()
+		if err != nil {
+			if !conn.isClosed() && !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				conn.t.Debugf("websocket connection closed: %v", err)
+			}
+			return
+		}
+
+		var frame wsFrame
+		err = json.Unmarshal(bs, &frame)
+		if err != nil {
+			conn.t.Errorf("bad websocket frame: %v", err)
+			return
+		}
+		conn.handleFrame(frame)
+	}
+}
+
+func (conn *wsConn) handleFrame(frame wsFrame) {
+	conn.channelsMu.Lock()
+	channel, exists := conn.channels[frame.Channel]
+	if !exists {
+		// Channels that the other side opens have the other parity, and their
+		// IDs always increase, so older IDs belong to channels that are closed
+		isRemote := (frame.Channel%2 == 1) == conn.incoming
+		if !isRemote || frame.Close || frame.Channel <= conn.maxRemoteChannelID {
+			conn.channelsMu.Unlock()
+			return
+		}
+		conn.maxRemoteChannelID = frame.Channel
+		conn.channelsMu.Unlock()
+
+		channel = conn.openChannel(&frame.Channel)
+		go conn.t.handleIncomingChannel(conn, channel)
+	} else {
+		conn.channelsMu.Unlock()
+	}
+
+	if frame.Close {
+		channel.closeLocally()
+		return
+	}
+
+	var msg Msg
+	err := json.Unmarshal(frame.Msg, &msg)
+	if err != nil {
+		conn.t.Errorf("bad message on websocket channel: %v", err)
+		channel.Close()
+		return
+	}
+	channel.inbox.Deliver(msg)
+}
+
+func (conn *wsConn) writeFrame(frame wsFrame) error {
+	bs, err := json.Marshal(frame)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	conn.writeMu.Lock()
+	defer conn.writeMu.Unlock()
+
+	err = conn.wsconn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if err != nil {
+		return err
+	}
+	return conn.wsconn.WriteMessage(websocket.TextMessage, bs)
+}
+
+// pingLoop keeps idle connections from being dropped by proxies and NATs.
+// Pongs are handled by the websocket library.
+func (conn *wsConn) pingLoop() {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err := conn.wsconn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout))
+			if err != nil {
+				conn.Close()
+				return
+			}
+		case <-conn.chClosed:
+			return
+		}
+	}
+}
+
+type wsChannel struct {
+	id        uint64
+	conn      *wsConn
+	inbox     *utils.Mailbox
+	chClosed  chan struct{}
+	closeOnce sync.Once
+}
+
+func (ch *wsChannel) writeMsg(msg Msg) error {
+	if ch.isClosed() {
+		return errors.Wrap(types.ErrConnection, "channel is closed")
+	}
+
+	bs, err := json.Marshal(msg)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return ch.conn.writeFrame(wsFrame{Channel: ch.id, Msg: bs})
+}
+
+// readMsg returns the next message on the channel.  Messages that arrived
+// before the channel was closed are still returned, followed by io.EOF.
+func (ch *wsChannel) readMsg(ctx context.Context) (Msg, error) {
+	for {
+		if x := ch.inbox.Retrieve(); x != nil {
+			return x.(Msg), nil
+		}
+
+		select {
+		case <-ch.inbox.Notify():
+		case <-ch.chClosed:
+			if x := ch.inbox.Retrieve(); x != nil {
+				return x.(Msg), nil
+			}
+			return Msg{}, io.EOF
+		case <-ctx.Done():
+			return Msg{}, ctx.Err()
+		}
+	}
+}
+
+func (ch *wsChannel) isClosed() bool {
+	select {
+	case <-ch.chClosed:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close closes the channel and tells the other side to do the same.
+func (ch *wsChannel) Close() error {
+	if ch.isClosed() {
+		return nil
+	}
+	ch.closeLocally()
+	if ch.conn.isClosed() {
+		return nil
+	}
+	return ch.conn.writeFrame(wsFrame{Channel: ch.id, Close: true})
+}
+
+func (ch *wsChannel) closeLocally() {
+	ch.closeOnce.Do(func() {
+		close(ch.chClosed)
+
+		ch.conn.channelsMu.Lock()
+		defer ch.conn.channelsMu.Unlock()
+		delete(ch.conn.channels, ch.id)
+	})
+}
@@ -0,0 +1,93 @@
+package redwood_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"redwood.dev"
+	"redwood.dev/identity"
+	"redwood.dev/testutils"
+	"redwood.dev/types"
+)
+
+func setupWebSocketHost(t *testing.T) (redwood.Host, identity.KeyStore, func()) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	listenAddr := listener.Addr().String()
+	listener.Close()
+
+	return testutils.StartHost(t, func(controllerHub redwood.ControllerHub, keyStore identity.KeyStore, refStore redwood.RefStore, peerStore redwood.PeerStore) ([]redwood.Transport, error) {
+		tpt, err := redwood.NewWebSocketTransport(listenAddr, "", controllerHub, keyStore, refStore, peerStore)
+		if err != nil {
+			return nil, err
+		}
+		return []redwood.Transport{tpt}, nil
+	})
+}
+
+func TestWebSocketTransport_Subscribe(t *testing.T) {
+	hostA, keyStoreA, cleanupA := setupWebSocketHost(t)
+	defer cleanupA()
+	hostB, keyStoreB, cleanupB := setupWebSocketHost(t)
+	defer cleanupB()
+
+	stateURI := "foo.com/bar"
+
+	waitForTx := func(h redwood.Host, txID types.ID) {
+		t.Helper()
+		require.Eventually(t, func() bool {
+			have, err := h.Controllers().HaveTx(stateURI, txID)
+			return err == nil && have
+		}, 10*time.Second, 100*time.Millisecond, fmt.Sprintf("tx %v never arrived", txID.Pretty()))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	genesis := redwood.Tx{ID: redwood.GenesisTxID, StateURI: stateURI, Patches: []redwood.Patch{mustParsePatch(t, `.count = 1`)}}
+	err := hostA.SendTx(ctx, genesis)
+	require.NoError(t, err)
+	waitForTx(hostA, redwood.GenesisTxID)
+
+	// B subscribes to A over a websocket and receives its history
+	hostB.AddPeer(redwood.PeerDialInfo{TransportName: "websocket", DialAddr: redwood.WebSocketOwnURL(hostA)})
+	_, err = hostB.Subscribe(ctx, stateURI, redwood.SubscriptionType_Txs, nil, nil)
+	require.NoError(t, err)
+	waitForTx(hostB, redwood.GenesisTxID)
+
+	// A verified B's identity when B connected
+	identityB, err := keyStoreB.DefaultPublicIdentity()
+	require.NoError(t, err)
+	require.NotEmpty(t, redwood.PeerStoreOf(hostA).PeersFromTransportWithAddress("websocket", identityB.Address()))
+
+	// New txs flow in both directions over the same connection
+	tx1 := redwood.Tx{ID: types.RandomID(), StateURI: stateURI, Patches: []redwood.Patch{mustParsePatch(t, `.count = 2`)}}
+	err = hostA.SendTx(ctx, tx1)
+	require.NoError(t, err)
+	waitForTx(hostB, tx1.ID)
+
+	tx2 := redwood.Tx{ID: types.RandomID(), StateURI: stateURI, Parents: []types.ID{tx1.ID}, Patches: []redwood.Patch{mustParsePatch(t, `.count = 3`)}}
+	err = hostB.SendTx(ctx, tx2)
+	require.NoError(t, err)
+	waitForTx(hostA, tx2.ID)
+
+	identityA, err := keyStoreA.DefaultPublicIdentity()
+	require.NoError(t, err)
+	tx, err := hostB.Controllers().FetchTx(stateURI, tx1.ID)
+	require.NoError(t, err)
+	require.Equal(t, identityA.Address(), tx.From)
+}
+
+func mustParsePatch(t *testing.T, s string) redwood.Patch {
+	t.Helper()
+	patch, err := redwood.ParsePatch([]byte(s))
+	require.NoError(t, err)
+	return patch
+}
@@ -1,15 +1,11 @@
 package tree
 
-import (
-	"github.com/dgraph-io/badger/v2"
-)
-
 type dbIterator struct {
-	iter           *badger.Iterator
-	tx             *badger.Txn
+	iter           KVIterator
+	tx             KVTxn
 	rootKeypath    Keypath
 	scanPrefix     Keypath
-	rootItem       *badger.Item
+	atRoot         bool
 	rootNode       *DBNode
 	iterNode       *DBNode
 	activeIterator *activeIterator
@@ -18,7 +14,7 @@ type dbIterator struct {
 // Ensure that dbIterator implements the Iterator interface
 var _ Iterator = (*dbIterator)(nil)
 
-func newIteratorFromBadgerIterator(iter *badger.Iterator, relKeypath Keypath, rootNode *DBNode) *dbIterator {
+func newIteratorFromKVIterator(iter KVIterator, relKeypath Keypath, rootNode *DBNode) *dbIterator {
 	rootKeypath := rootNode.rootKeypath.Push(relKeypath)
 	scanPrefix := rootNode.addKeyPrefix(rootKeypath)
 	if len(scanPrefix) != len(rootNode.keyPrefix) {
@@ -40,19 +36,20 @@ func (iter *dbIterator) RootKeypath() Keypath {
 }
 
 func (iter *dbIterator) Rewind() {
-	rootItem, err := iter.tx.Get(iter.rootNode.addKeyPrefix(iter.rootKeypath))
-	if err == badger.ErrKeyNotFound || err != nil { // Just being explicit here
+	rootKey := iter.rootNode.addKeyPrefix(iter.rootKeypath)
+	_, err := iter.tx.Get(rootKey)
+	if err != nil {
 		// Ignore the root.  Just start iterating from the first actual iterator keypath
-		iter.rootItem = nil
+		iter.atRoot = false
 		iter.iter.Seek(iter.scanPrefix)
 		if !iter.iter.ValidForPrefix(iter.scanPrefix) {
 			return
 		}
-		iter.setNode(iter.iter.Item())
+		iter.setNode(iter.iter.Key())
 		return
 	}
-	iter.rootItem = rootItem
-	iter.setNode(rootItem)
+	iter.atRoot = true
+	iter.setNode(rootKey)
 }
 
 func (iter *dbIterator) SeekTo(relKeypath Keypath) {
@@ -60,18 +57,18 @@ func (iter *dbIterator) SeekTo(relKeypath Keypath) {
 		iter.Rewind()
 		return
 	}
-	iter.rootItem = nil
+	iter.atRoot = false
 	absKeypath := iter.rootKeypath.Push(relKeypath)
 	iter.iter.Seek(iter.rootNode.addKeyPrefix(absKeypath))
 	if !iter.iter.ValidForPrefix(iter.scanPrefix) {
 		return
 	}
-	iter.setNode(iter.iter.Item())
+	iter.setNode(iter.iter.Key())
 }
 
 func (iter *dbIterator) Next() {
-	if iter.rootItem != nil {
-		iter.rootItem = nil
+	if iter.atRoot {
+		iter.atRoot = false
 		iter.iter.Seek(iter.scanPrefix)
 		if !iter.Valid() {
 			return
@@ -85,11 +82,11 @@ func (iter *dbIterator) Next() {
 	if !iter.Valid() {
 		return
 	}
-	iter.setNode(iter.iter.Item())
+	iter.setNode(iter.iter.Key())
 }
 
-func (iter *dbIterator) setNode(item *badger.Item) {
-	iter.iterNode.rootKeypath = item.KeyCopy(iter.iterNode.rootKeypath)
+func (iter *dbIterator) setNode(key []byte) {
+	iter.iterNode.rootKeypath = append(iter.iterNode.rootKeypath[:0], key...)
 	iter.iterNode.rootKeypath = iter.rootNode.rmKeyPrefix(iter.iterNode.rootKeypath)
 	if len(iter.iterNode.rootKeypath) == 0 {
 		iter.iterNode.rootKeypath = nil
@@ -104,7 +101,7 @@ func (iter *dbIterator) Node() Node {
 }
 
 func (iter *dbIterator) Valid() bool {
-	if iter.rootItem != nil {
+	if iter.atRoot {
 		return true
 	}
 	return iter.iter.ValidForPrefix(iter.scanPrefix)
@@ -120,8 +117,8 @@ type dbChildIterator struct {
 	strippedAbsKeypathParts int
 }
 
-func newChildIteratorFromBadgerIterator(iter *badger.Iterator, keypath Keypath, rootNode *DBNode) *dbChildIterator {
-	dbIter := newIteratorFromBadgerIterator(iter, keypath, rootNode)
+func newChildIteratorFromKVIterator(iter KVIterator, keypath Keypath, rootNode *DBNode) *dbChildIterator {
+	dbIter := newIteratorFromKVIterator(iter, keypath, rootNode)
 	return &dbChildIterator{
 		Iterator:                dbIter,
 		strippedAbsKeypathParts: dbIter.rootKeypath.NumParts(),
@@ -151,39 +148,39 @@ type reusableIterator struct {
 	Iterator
 	originalKeypath Keypath
 	rootNode        *DBNode
-	badgerIter      *badger.Iterator
+	kvIter          KVIterator
 }
 
 func newReusableIterator(originalIterator Iterator, keypath Keypath, rootNode *DBNode) Iterator {
-	var badgerIter *badger.Iterator
+	var kvIter KVIterator
 	switch oi := originalIterator.(type) {
 	case *dbIterator:
-		badgerIter = oi.iter
+		kvIter = oi.iter
 	case *dbChildIterator:
-		badgerIter = oi.Iterator.(*dbIterator).iter
+		kvIter = oi.Iterator.(*dbIterator).iter
 	default:
 		panic("you can only use a reusableIterator with a dbIterator or a dbChildIterator")
 	}
 	return &reusableIterator{
-		Iterator:        newIteratorFromBadgerIterator(badgerIter, keypath, rootNode),
+		Iterator:        newIteratorFromKVIterator(kvIter, keypath, rootNode),
 		originalKeypath: originalIterator.Node().Keypath(),
 		rootNode:        rootNode,
-		badgerIter:      badgerIter,
+		kvIter:          kvIter,
 	}
 }
 
 func (ri *reusableIterator) Close() {
-	ri.badgerIter.Seek(ri.rootNode.addKeyPrefix(ri.originalKeypath))
+	ri.kvIter.Seek(ri.rootNode.addKeyPrefix(ri.originalKeypath))
 }
 
 type dbDepthFirstIterator struct {
-	iter        *badger.Iterator
+	iter        KVIterator
 	rootKeypath Keypath
 	scanPrefix  Keypath
-	tx          *badger.Txn
+	tx          KVTxn
 	rootNode    *DBNode
 	iterNode    *DBNode
-	rootItem    *badger.Item
+	atRoot      bool
 	done        bool
 }
 
@@ -195,7 +192,7 @@ func (iter *dbDepthFirstIterator) RootKeypath() Keypath {
 }
 
 func (iter *dbDepthFirstIterator) Valid() bool {
-	if iter.rootItem != nil {
+	if iter.atRoot {
 		return true
 	} else if iter.done {
 		return false
@@ -203,8 +200,8 @@ func (iter *dbDepthFirstIterator) Valid() bool {
 	return iter.iter.ValidForPrefix(iter.scanPrefix)
 }
 
-func (iter *dbDepthFirstIterator) setNode(item *badger.Item) {
-	iter.iterNode.rootKeypath = item.KeyCopy(iter.iterNode.rootKeypath)
+func (iter *dbDepthFirstIterator) setNode(key []byte) {
+	iter.iterNode.rootKeypath = append(iter.iterNode.rootKeypath[:0], key...)
 	iter.iterNode.rootKeypath = iter.rootNode.rmKeyPrefix(iter.iterNode.rootKeypath)
 	if len(iter.iterNode.rootKeypath) == 0 {
 		iter.iterNode.rootKeypath = nil
@@ -212,7 +209,7 @@ func (iter *dbDepthFirstIterator) setNode(item *badger.Item) {
 }
 
 func (iter *dbDepthFirstIterator) Rewind() {
-	iter.rootItem = nil
+	iter.atRoot = false
 	iter.done = false
 	iter.iter.Seek(append(iter.scanPrefix, byte(0xff)))
 	iter.syncAfterJump()
@@ -234,8 +231,8 @@ func (iter *dbDepthFirstIterator) Node() Node {
 func (iter *dbDepthFirstIterator) Next() {
 	if iter.done {
 		return
-	} else if iter.rootItem != nil {
-		iter.rootItem = nil
+	} else if iter.atRoot {
+		iter.atRoot = false
 		iter.done = true
 		return
 	}
@@ -245,25 +242,24 @@ func (iter *dbDepthFirstIterator) Next() {
 
 func (iter *dbDepthFirstIterator) syncAfterJump() {
 	if !iter.iter.Valid() {
-		iter.rootItem = nil
+		iter.atRoot = false
 		iter.done = true
 		return
 	}
 
-	item := iter.iter.Item()
-	kp := iter.rootNode.rmKeyPrefix(item.Key())
+	key := iter.iter.Key()
+	kp := iter.rootNode.rmKeyPrefix(key)
 	if kp.Equals(iter.rootKeypath) {
-		item := iter.iter.Item()
-		iter.setNode(item)
-		iter.rootItem = item
+		iter.setNode(key)
+		iter.atRoot = true
 		iter.done = false
 
 	} else if iter.iter.ValidForPrefix(iter.scanPrefix) {
-		iter.setNode(item)
+		iter.setNode(key)
 		iter.done = false
 
 	} else {
-		iter.rootItem = nil
+		iter.atRoot = false
 		iter.done = true
 	}
 }
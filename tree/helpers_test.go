@@ -1,17 +1,13 @@
 package tree
 
-import (
-	"github.com/dgraph-io/badger/v2"
-)
-
 type ReusableIterator = reusableIterator
 type DBIterator = dbIterator
 
-func (iter *dbIterator) BadgerIter() *badger.Iterator {
+func (iter *dbIterator) KVIter() KVIterator {
 	return iter.iter
 }
 
-func (t *VersionedDBTree) BadgerDB() *badger.DB {
+func (t *VersionedDBTree) KVStore() KVStore {
 	return t.db
 }
 
@@ -0,0 +1,110 @@
+package tree
+
+import (
+	"github.com/dgraph-io/badger/v2"
+
+	"redwood.dev/types"
+)
+
+type badgerKVStore struct {
+	db *badger.DB
+}
+
+var _ KVStore = (*badgerKVStore)(nil)
+
+func NewBadgerKVStore(dbFilename string) (*badgerKVStore, error) {
+	opts := badger.DefaultOptions(dbFilename)
+	opts.Logger = nil
+
+	db, err := badger.Open(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &badgerKVStore{db}, nil
+}
+
+func (s *badgerKVStore) NewTxn(mutable bool) KVTxn {
+	return &badgerKVTxn{s.db.NewTransaction(mutable)}
+}
+
+// DeletePrefix deletes keys in batches rather than dropping the prefix, which
+// would stop all writes to the DB until it was done.
+func (s *badgerKVStore) DeletePrefix(prefix []byte) error {
+	batch := s.db.NewWriteBatch()
+	defer batch.Cancel()
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = prefix
+		iter := txn.NewIterator(opts)
+		defer iter.Close()
+
+		for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
+			err := batch.Delete(iter.Item().KeyCopy(nil))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return batch.Flush()
+}
+
+func (s *badgerKVStore) Close() error {
+	return s.db.Close()
+}
+
+type badgerKVTxn struct {
+	txn *badger.Txn
+}
+
+func (txn *badgerKVTxn) Get(key []byte) ([]byte, error) {
+	item, err := txn.txn.Get(key)
+	if err == badger.ErrKeyNotFound {
+		return nil, types.Err404
+	} else if err != nil {
+		return nil, err
+	}
+	return item.ValueCopy(nil)
+}
+
+func (txn *badgerKVTxn) Set(key, val []byte) error {
+	return txn.txn.Set(key, val)
+}
+
+func (txn *badgerKVTxn) Delete(key []byte) error {
+	return txn.txn.Delete(key)
+}
+
+func (txn *badgerKVTxn) NewIterator(opts KVIteratorOptions) KVIterator {
+	badgerOpts := badger.DefaultIteratorOptions
+	badgerOpts.Reverse = opts.Reverse
+	badgerOpts.PrefetchValues = opts.PrefetchValues
+	badgerOpts.PrefetchSize = opts.PrefetchSize
+	badgerOpts.Prefix = opts.Prefix
+	return badgerKVIterator{txn.txn.NewIterator(badgerOpts)}
+}
+
+func (txn *badgerKVTxn) Commit() error {
+	return txn.txn.Commit()
+}
+
+func (txn *badgerKVTxn) Discard() {
+	txn.txn.Discard()
+}
+
+type badgerKVIterator struct {
+	*badger.Iterator
+}
+
+func (iter badgerKVIterator) Key() []byte {
+	return iter.Item().Key()
+}
+
+func (iter badgerKVIterator) Value() ([]byte, error) {
+	return iter.Item().ValueCopy(nil)
+}
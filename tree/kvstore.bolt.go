@@ -0,0 +1,196 @@
+package tree
+
+import (
+	"bytes"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+
+	"redwood.dev/types"
+)
+
+var boltBucket = []byte("tree")
+
+// boltKVStore keeps a tree in a single bbolt B-tree file.  Bolt only allows one
+// writer at a time, so writes are buffered in each KVTxn and only applied (in
+// a short-lived bolt transaction) when it's committed.  Reads happen in a bolt
+// read transaction that stays open until the KVTxn is committed or discarded.
+type boltKVStore struct {
+	db *bolt.DB
+
+	snapshotsMu sync.Mutex
+	snapshots   map[*boltSnapshot]struct{}
+}
+
+var _ KVStore = (*boltKVStore)(nil)
+
+func NewBoltKVStore(dirname string) (*boltKVStore, error) {
+	db, err := bolt.Open(filepath.Join(dirname, "tree.bolt"), 0600, &bolt.Options{
+		Timeout: 5 * time.Second,
+		// A writer has to wait for every reader to finish before bolt can grow
+		// its memory map.  Starting with a large map keeps that rare.
+		InitialMmapSize: 1 << 30,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &boltKVStore{db: db, snapshots: make(map[*boltSnapshot]struct{})}, nil
+}
+
+func (s *boltKVStore) NewTxn(mutable bool) KVTxn {
+	tx, err := s.db.Begin(false)
+	snapshot := &boltSnapshot{store: s, tx: tx, err: err}
+	if err == nil {
+		snapshot.bucket = tx.Bucket(boltBucket)
+
+		s.snapshotsMu.Lock()
+		s.snapshots[snapshot] = struct{}{}
+		s.snapshotsMu.Unlock()
+	}
+
+	if !mutable {
+		return newOverlayTxn(snapshot, nil)
+	}
+	return newOverlayTxn(snapshot, s.commit)
+}
+
+func (s *boltKVStore) commit(sets map[string][]byte, deletes map[string]struct{}) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		for key := range deletes {
+			err := bucket.Delete([]byte(key))
+			if err != nil {
+				return err
+			}
+		}
+		for key, val := range sets {
+			err := bucket.Put([]byte(key), val)
+			if err != nil {
+				return errors.Wrapf(err, "while setting key %v", key)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *boltKVStore) DeletePrefix(prefix []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		c := tx.Bucket(boltBucket).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Seek(prefix) {
+			err := c.Delete()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Close rolls back any read transactions that were never committed or
+// discarded, since bolt can't close the DB while they're open.
+func (s *boltKVStore) Close() error {
+	s.snapshotsMu.Lock()
+	for snapshot := range s.snapshots {
+		snapshot.tx.Rollback()
+	}
+	s.snapshots = make(map[*boltSnapshot]struct{})
+	s.snapshotsMu.Unlock()
+
+	return s.db.Close()
+}
+
+type boltSnapshot struct {
+	store  *boltKVStore
+	tx     *bolt.Tx
+	bucket *bolt.Bucket
+	err    error
+}
+
+func (s *boltSnapshot) get(key []byte) ([]byte, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	val := s.bucket.Get(key)
+	if val == nil {
+		return nil, types.Err404
+	}
+	return append([]byte(nil), val...), nil
+}
+
+func (s *boltSnapshot) newIterator(reverse bool) kvSnapshotIterator {
+	if s.err != nil {
+		return &boltSnapshotIterator{}
+	}
+	return &boltSnapshotIterator{cursor: s.bucket.Cursor(), reverse: reverse}
+}
+
+func (s *boltSnapshot) release() {
+	if s.tx == nil {
+		return
+	}
+	s.store.snapshotsMu.Lock()
+	defer s.store.snapshotsMu.Unlock()
+
+	if _, open := s.store.snapshots[s]; open {
+		s.tx.Rollback()
+		delete(s.store.snapshots, s)
+	}
+}
+
+type boltSnapshotIterator struct {
+	cursor  *bolt.Cursor
+	reverse bool
+	k, v    []byte
+}
+
+func (iter *boltSnapshotIterator) seek(key []byte) {
+	if iter.cursor == nil {
+		return
+	}
+	iter.k, iter.v = iter.cursor.Seek(key)
+	if !iter.reverse {
+		return
+	}
+	// Reverse iterators start from the last key <= the one being sought
+	if iter.k == nil {
+		iter.k, iter.v = iter.cursor.Last()
+	} else if !bytes.Equal(iter.k, key) {
+		iter.k, iter.v = iter.cursor.Prev()
+	}
+}
+
+func (iter *boltSnapshotIterator) next() {
+	if iter.cursor == nil {
+		return
+	} else if iter.reverse {
+		iter.k, iter.v = iter.cursor.Prev()
+	} else {
+		iter.k, iter.v = iter.cursor.Next()
+	}
+}
+
+func (iter *boltSnapshotIterator) valid() bool {
+	return iter.k != nil
+}
+
+func (iter *boltSnapshotIterator) key() []byte {
+	return iter.k
+}
+
+func (iter *boltSnapshotIterator) value() ([]byte, error) {
+	return append([]byte(nil), iter.v...), nil
+}
+
+func (iter *boltSnapshotIterator) close() {}
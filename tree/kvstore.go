@@ -0,0 +1,305 @@
+package tree
+
+import (
+	"bytes"
+	"os"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"redwood.dev/types"
+)
+
+// KVStore is the ordered key/value storage engine underneath a DBTree or a
+// VersionedDBTree.  Each DBNode does all of its work inside of a single KVTxn.
+type KVStore interface {
+	NewTxn(mutable bool) KVTxn
+	// DeletePrefix removes every key that starts with the given prefix.  It
+	// may do so over several transactions.
+	DeletePrefix(prefix []byte) error
+	Close() error
+}
+
+// KVTxn is a transaction against a KVStore.  Reads see a consistent snapshot
+// of the store, plus the transaction's own writes.  Get returns types.Err404
+// when a key doesn't exist.
+type KVTxn interface {
+	Get(key []byte) ([]byte, error)
+	Set(key, val []byte) error
+	Delete(key []byte) error
+	NewIterator(opts KVIteratorOptions) KVIterator
+	Commit() error
+	Discard()
+}
+
+// KVIterator walks the keys of a KVTxn in sorted order (or reverse sorted
+// order).  The slice returned by Key is only valid until the next call to
+// Seek or Next.  Like Badger's iterators, a KVIterator only sees the writes
+// that its transaction had made when it was created.
+type KVIterator interface {
+	Seek(key []byte)
+	Next()
+	Valid() bool
+	ValidForPrefix(prefix []byte) bool
+	Key() []byte
+	Value() ([]byte, error)
+	Close()
+}
+
+type KVIteratorOptions struct {
+	Reverse        bool
+	PrefetchValues bool
+	PrefetchSize   int
+	// If set, the iterator is only Valid over keys with this prefix
+	Prefix []byte
+}
+
+type KVEngine string
+
+const (
+	KVEngineBadger KVEngine = "badger"
+	KVEngineBolt   KVEngine = "bolt"
+	KVEngineMemory KVEngine = "memory"
+)
+
+var DefaultKVEngine = KVEngineBadger
+
+// OpenKVStore opens a KVStore of the given engine at the given path.  The
+// memory engine ignores the path.
+func OpenKVStore(engine KVEngine, path string) (KVStore, error) {
+	switch engine {
+	case KVEngineBadger, "":
+		return NewBadgerKVStore(path)
+	case KVEngineBolt:
+		err := os.MkdirAll(path, 0777|os.ModeDir)
+		if err != nil {
+			return nil, err
+		}
+		return NewBoltKVStore(path)
+	case KVEngineMemory:
+		return NewMemoryKVStore(), nil
+	default:
+		return nil, errors.Errorf("unknown kv engine: %v", engine)
+	}
+}
+
+// overlayTxn gives engines without optimistic transactions of their own
+// (bolt, memory) Badger's semantics: writes are buffered in memory on top of
+// a read-only snapshot, and are only applied to the store by Commit.
+type overlayTxn struct {
+	snapshot kvSnapshot
+	pending  map[string][]byte
+	deleted  map[string]struct{}
+	commit   func(sets map[string][]byte, deletes map[string]struct{}) error
+	done     bool
+}
+
+// kvSnapshot is the read-only view of a store underneath an overlayTxn.
+type kvSnapshot interface {
+	get(key []byte) ([]byte, error)
+	newIterator(reverse bool) kvSnapshotIterator
+	release()
+}
+
+type kvSnapshotIterator interface {
+	seek(key []byte)
+	next()
+	valid() bool
+	key() []byte
+	value() ([]byte, error)
+	close()
+}
+
+func newOverlayTxn(snapshot kvSnapshot, commit func(sets map[string][]byte, deletes map[string]struct{}) error) *overlayTxn {
+	return &overlayTxn{
+		snapshot: snapshot,
+		pending:  make(map[string][]byte),
+		deleted:  make(map[string]struct{}),
+		commit:   commit,
+	}
+}
+
+func (txn *overlayTxn) Get(key []byte) ([]byte, error) {
+	if val, exists := txn.pending[string(key)]; exists {
+		return append([]byte(nil), val...), nil
+	} else if _, deleted := txn.deleted[string(key)]; deleted {
+		return nil, types.Err404
+	}
+	return txn.snapshot.get(key)
+}
+
+func (txn *overlayTxn) Set(key, val []byte) error {
+	if txn.commit == nil {
+		return errors.New("can't write to a read-only transaction")
+	}
+	txn.pending[string(key)] = append([]byte(nil), val...)
+	delete(txn.deleted, string(key))
+	return nil
+}
+
+func (txn *overlayTxn) Delete(key []byte) error {
+	if txn.commit == nil {
+		return errors.New("can't write to a read-only transaction")
+	}
+	delete(txn.pending, string(key))
+	txn.deleted[string(key)] = struct{}{}
+	return nil
+}
+
+func (txn *overlayTxn) Commit() error {
+	if txn.done {
+		return errors.New("transaction has already been committed or discarded")
+	}
+	txn.Discard()
+	if txn.commit == nil || (len(txn.pending) == 0 && len(txn.deleted) == 0) {
+		return nil
+	}
+	return txn.commit(txn.pending, txn.deleted)
+}
+
+func (txn *overlayTxn) Discard() {
+	if txn.done {
+		return
+	}
+	txn.done = true
+	txn.snapshot.release()
+}
+
+func (txn *overlayTxn) NewIterator(opts KVIteratorOptions) KVIterator {
+	// Take a copy of the pending writes so that the iterator isn't affected by
+	// anything written after it was created
+	overlay := make([]overlayEntry, 0, len(txn.pending)+len(txn.deleted))
+	for key, val := range txn.pending {
+		overlay = append(overlay, overlayEntry{key: []byte(key), val: val})
+	}
+	for key := range txn.deleted {
+		overlay = append(overlay, overlayEntry{key: []byte(key), deleted: true})
+	}
+	sort.Slice(overlay, func(i, j int) bool { return bytes.Compare(overlay[i].key, overlay[j].key) < 0 })
+
+	return &overlayIterator{
+		base:    txn.snapshot.newIterator(opts.Reverse),
+		overlay: overlay,
+		reverse: opts.Reverse,
+		prefix:  opts.Prefix,
+	}
+}
+
+type overlayEntry struct {
+	key     []byte
+	val     []byte
+	deleted bool
+}
+
+// overlayIterator merges a snapshot's iterator with a transaction's pending
+// writes, which take precedence over the snapshot.
+type overlayIterator struct {
+	base       kvSnapshotIterator
+	overlay    []overlayEntry
+	overlayIdx int
+	reverse    bool
+	prefix     []byte
+
+	// Whether the current item is from the overlay, and whether the base
+	// iterator is sitting on the same key (and so must be skipped)
+	fromOverlay bool
+	baseShadow  bool
+}
+
+func (iter *overlayIterator) Seek(key []byte) {
+	iter.base.seek(key)
+	if iter.reverse {
+		// The last entry <= key
+		iter.overlayIdx = sort.Search(len(iter.overlay), func(i int) bool { return bytes.Compare(iter.overlay[i].key, key) > 0 }) - 1
+	} else {
+		// The first entry >= key
+		iter.overlayIdx = sort.Search(len(iter.overlay), func(i int) bool { return bytes.Compare(iter.overlay[i].key, key) >= 0 })
+	}
+	iter.settle()
+}
+
+func (iter *overlayIterator) overlayValid() bool {
+	return iter.overlayIdx >= 0 && iter.overlayIdx < len(iter.overlay)
+}
+
+func (iter *overlayIterator) advanceOverlay() {
+	if iter.reverse {
+		iter.overlayIdx--
+	} else {
+		iter.overlayIdx++
+	}
+}
+
+// settle positions the iterator on the next live key, skipping deleted keys
+// and snapshot keys that have been overwritten.
+func (iter *overlayIterator) settle() {
+	for {
+		iter.fromOverlay = false
+		iter.baseShadow = false
+
+		if !iter.overlayValid() {
+			return
+		} else if !iter.base.valid() {
+			iter.fromOverlay = true
+		} else {
+			cmp := bytes.Compare(iter.overlay[iter.overlayIdx].key, iter.base.key())
+			if iter.reverse {
+				cmp = -cmp
+			}
+			if cmp > 0 {
+				return
+			}
+			iter.fromOverlay = true
+			iter.baseShadow = cmp == 0
+		}
+
+		if !iter.overlay[iter.overlayIdx].deleted {
+			return
+		}
+		if iter.baseShadow {
+			iter.base.next()
+		}
+		iter.advanceOverlay()
+	}
+}
+
+func (iter *overlayIterator) Next() {
+	if iter.fromOverlay {
+		if iter.baseShadow {
+			iter.base.next()
+		}
+		iter.advanceOverlay()
+	} else {
+		iter.base.next()
+	}
+	iter.settle()
+}
+
+func (iter *overlayIterator) Valid() bool {
+	if !iter.fromOverlay && !iter.base.valid() {
+		return false
+	}
+	return iter.prefix == nil || bytes.HasPrefix(iter.Key(), iter.prefix)
+}
+
+func (iter *overlayIterator) ValidForPrefix(prefix []byte) bool {
+	return iter.Valid() && bytes.HasPrefix(iter.Key(), prefix)
+}
+
+func (iter *overlayIterator) Key() []byte {
+	if iter.fromOverlay {
+		return iter.overlay[iter.overlayIdx].key
+	}
+	return iter.base.key()
+}
+
+func (iter *overlayIterator) Value() ([]byte, error) {
+	if iter.fromOverlay {
+		return append([]byte(nil), iter.overlay[iter.overlayIdx].val...), nil
+	}
+	return iter.base.value()
+}
+
+func (iter *overlayIterator) Close() {
+	iter.base.close()
+}
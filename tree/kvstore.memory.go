@@ -0,0 +1,138 @@
+package tree
+
+import (
+	"sort"
+	"sync"
+
+	"redwood.dev/types"
+)
+
+// memoryKVStore keeps everything in a sorted map that's replaced wholesale on
+// every commit, so it's only suitable for tests and small, short-lived trees.
+type memoryKVStore struct {
+	mu       sync.RWMutex
+	snapshot *memorySnapshot
+}
+
+var _ KVStore = (*memoryKVStore)(nil)
+
+// memorySnapshot is never modified once it's been created.
+type memorySnapshot struct {
+	keys []string
+	vals map[string][]byte
+}
+
+func NewMemoryKVStore() *memoryKVStore {
+	return &memoryKVStore{
+		snapshot: &memorySnapshot{vals: make(map[string][]byte)},
+	}
+}
+
+func (s *memoryKVStore) NewTxn(mutable bool) KVTxn {
+	s.mu.RLock()
+	snapshot := s.snapshot
+	s.mu.RUnlock()
+
+	if !mutable {
+		return newOverlayTxn(snapshot, nil)
+	}
+	return newOverlayTxn(snapshot, s.commit)
+}
+
+func (s *memoryKVStore) commit(sets map[string][]byte, deletes map[string]struct{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	vals := make(map[string][]byte, len(s.snapshot.vals)+len(sets))
+	for key, val := range s.snapshot.vals {
+		vals[key] = val
+	}
+	for key := range deletes {
+		delete(vals, key)
+	}
+	for key, val := range sets {
+		vals[key] = val
+	}
+	s.snapshot = newMemorySnapshot(vals)
+	return nil
+}
+
+func (s *memoryKVStore) DeletePrefix(prefix []byte) error {
+	txn := s.NewTxn(true)
+	defer txn.Discard()
+
+	iter := txn.NewIterator(KVIteratorOptions{Prefix: prefix})
+	defer iter.Close()
+
+	for iter.Seek(prefix); iter.Valid(); iter.Next() {
+		err := txn.Delete(iter.Key())
+		if err != nil {
+			return err
+		}
+	}
+	return txn.Commit()
+}
+
+func (s *memoryKVStore) Close() error {
+	return nil
+}
+
+func newMemorySnapshot(vals map[string][]byte) *memorySnapshot {
+	keys := make([]string, 0, len(vals))
+	for key := range vals {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return &memorySnapshot{keys: keys, vals: vals}
+}
+
+func (s *memorySnapshot) get(key []byte) ([]byte, error) {
+	val, exists := s.vals[string(key)]
+	if !exists {
+		return nil, types.Err404
+	}
+	return append([]byte(nil), val...), nil
+}
+
+func (s *memorySnapshot) newIterator(reverse bool) kvSnapshotIterator {
+	return &memorySnapshotIterator{snapshot: s, reverse: reverse, idx: -1}
+}
+
+func (s *memorySnapshot) release() {}
+
+type memorySnapshotIterator struct {
+	snapshot *memorySnapshot
+	reverse  bool
+	idx      int
+}
+
+func (iter *memorySnapshotIterator) seek(key []byte) {
+	keys := iter.snapshot.keys
+	if iter.reverse {
+		iter.idx = sort.Search(len(keys), func(i int) bool { return keys[i] > string(key) }) - 1
+	} else {
+		iter.idx = sort.Search(len(keys), func(i int) bool { return keys[i] >= string(key) })
+	}
+}
+
+func (iter *memorySnapshotIterator) next() {
+	if iter.reverse {
+		iter.idx--
+	} else {
+		iter.idx++
+	}
+}
+
+func (iter *memorySnapshotIterator) valid() bool {
+	return iter.idx >= 0 && iter.idx < len(iter.snapshot.keys)
+}
+
+func (iter *memorySnapshotIterator) key() []byte {
+	return []byte(iter.snapshot.keys[iter.idx])
+}
+
+func (iter *memorySnapshotIterator) value() ([]byte, error) {
+	return append([]byte(nil), iter.snapshot.vals[iter.snapshot.keys[iter.idx]]...), nil
+}
+
+func (iter *memorySnapshotIterator) close() {}
@@ -0,0 +1,129 @@
+package tree_test
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"redwood.dev/tree"
+	"redwood.dev/types"
+)
+
+func setupKVStore(t *testing.T, engine tree.KVEngine) (tree.KVStore, func()) {
+	t.Helper()
+
+	path := fmt.Sprintf("/tmp/tree-kvstore-test-%v", rand.Int())
+	kvStore, err := tree.OpenKVStore(engine, path)
+	require.NoError(t, err)
+	return kvStore, func() {
+		kvStore.Close()
+		os.RemoveAll(path)
+	}
+}
+
+func iterKeys(iter tree.KVIterator, seek string) []string {
+	var keys []string
+	for iter.Seek([]byte(seek)); iter.Valid(); iter.Next() {
+		keys = append(keys, string(iter.Key()))
+	}
+	return keys
+}
+
+func TestKVStore(t *testing.T) {
+	for _, engine := range []tree.KVEngine{tree.KVEngineBadger, tree.KVEngineBolt, tree.KVEngineMemory} {
+		engine := engine
+
+		t.Run(string(engine), func(t *testing.T) {
+			kvStore, cleanup := setupKVStore(t, engine)
+			defer cleanup()
+
+			txn := kvStore.NewTxn(true)
+			for _, key := range []string{"a/1", "a/2", "a/3", "b/1", "c/1"} {
+				err := txn.Set([]byte(key), []byte("val "+key))
+				require.NoError(t, err)
+			}
+			err := txn.Commit()
+			require.NoError(t, err)
+
+			t.Run("reads committed values", func(t *testing.T) {
+				txn := kvStore.NewTxn(false)
+				defer txn.Discard()
+
+				val, err := txn.Get([]byte("a/2"))
+				require.NoError(t, err)
+				require.Equal(t, []byte("val a/2"), val)
+
+				_, err = txn.Get([]byte("a/4"))
+				require.Equal(t, types.Err404, errors.Cause(err))
+			})
+
+			t.Run("iterates forwards and backwards", func(t *testing.T) {
+				txn := kvStore.NewTxn(false)
+				defer txn.Discard()
+
+				iter := txn.NewIterator(tree.KVIteratorOptions{Prefix: []byte("a/")})
+				require.Equal(t, []string{"a/2", "a/3"}, iterKeys(iter, "a/2"))
+				iter.Close()
+
+				iter = txn.NewIterator(tree.KVIteratorOptions{Reverse: true})
+				require.Equal(t, []string{"b/1", "a/3", "a/2", "a/1"}, iterKeys(iter, "b/5"))
+				iter.Close()
+			})
+
+			t.Run("sees its own writes, but other txns don't until commit", func(t *testing.T) {
+				txn := kvStore.NewTxn(true)
+				defer txn.Discard()
+
+				err := txn.Set([]byte("a/22"), []byte("new"))
+				require.NoError(t, err)
+				err = txn.Delete([]byte("a/3"))
+				require.NoError(t, err)
+
+				_, err = txn.Get([]byte("a/3"))
+				require.Equal(t, types.Err404, errors.Cause(err))
+
+				iter := txn.NewIterator(tree.KVIteratorOptions{Prefix: []byte("a/")})
+				defer iter.Close()
+
+				// Writes made after the iterator was created aren't visible to it
+				err = txn.Set([]byte("a/4"), []byte("new"))
+				require.NoError(t, err)
+
+				require.Equal(t, []string{"a/1", "a/2", "a/22"}, iterKeys(iter, "a/"))
+				iter.Close()
+
+				other := kvStore.NewTxn(false)
+				val, err := other.Get([]byte("a/3"))
+				require.NoError(t, err)
+				require.Equal(t, []byte("val a/3"), val)
+				other.Discard()
+
+				err = txn.Commit()
+				require.NoError(t, err)
+
+				other = kvStore.NewTxn(false)
+				defer other.Discard()
+
+				iter = other.NewIterator(tree.KVIteratorOptions{Prefix: []byte("a/")})
+				defer iter.Close()
+				require.Equal(t, []string{"a/1", "a/2", "a/22", "a/4"}, iterKeys(iter, "a/"))
+			})
+
+			t.Run("deletes prefixes", func(t *testing.T) {
+				err := kvStore.DeletePrefix([]byte("a/"))
+				require.NoError(t, err)
+
+				txn := kvStore.NewTxn(false)
+				defer txn.Discard()
+
+				iter := txn.NewIterator(tree.KVIteratorOptions{})
+				defer iter.Close()
+				require.Equal(t, []string{"b/1", "c/1"}, iterKeys(iter, ""))
+			})
+		})
+	}
+}
@@ -2,18 +2,16 @@ package tree
 
 import (
 	"bytes"
-	"context"
 	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
 	"reflect"
+	"sort"
 	"strings"
-	"sync"
 
 	"github.com/brynbellomy/go-structomancer"
-	"github.com/dgraph-io/badger/v2"
-	badgerpb "github.com/dgraph-io/badger/v2/pb"
 	"github.com/pkg/errors"
 
 	"redwood.dev/ctx"
@@ -22,16 +20,17 @@ import (
 )
 
 type DBTree struct {
-	db       *badger.DB
+	db       KVStore
 	filename string
 	ctx.Logger
 }
 
 func NewDBTree(dbFilename string) (*DBTree, error) {
-	opts := badger.DefaultOptions(dbFilename)
-	opts.Logger = nil
+	return NewDBTreeWithEngine(KVEngineBadger, dbFilename)
+}
 
-	db, err := badger.Open(opts)
+func NewDBTreeWithEngine(engine KVEngine, dbFilename string) (*DBTree, error) {
+	db, err := OpenKVStore(engine, dbFilename)
 	if err != nil {
 		return nil, err
 	}
@@ -56,7 +55,7 @@ func (t *DBTree) State(mutable bool) *DBNode {
 		diff = NewDiff()
 	}
 	return &DBNode{
-		tx:        t.db.NewTransaction(mutable),
+		tx:        t.db.NewTxn(mutable),
 		keyPrefix: KeypathSeparator,
 		diff:      diff,
 		activeIterator: &activeIterator{
@@ -66,16 +65,17 @@ func (t *DBTree) State(mutable bool) *DBNode {
 }
 
 type VersionedDBTree struct {
-	db       *badger.DB
+	db       KVStore
 	filename string
 	ctx.Logger
 }
 
 func NewVersionedDBTree(dbFilename string) (*VersionedDBTree, error) {
-	opts := badger.DefaultOptions(dbFilename)
-	opts.Logger = nil
+	return NewVersionedDBTreeWithEngine(KVEngineBadger, dbFilename)
+}
 
-	db, err := badger.Open(opts)
+func NewVersionedDBTreeWithEngine(engine KVEngine, dbFilename string) (*VersionedDBTree, error) {
+	db, err := OpenKVStore(engine, dbFilename)
 	if err != nil {
 		return nil, err
 	}
@@ -110,6 +110,11 @@ func (t *VersionedDBTree) makeIndexKeyPrefix(version types.ID, keypath Keypath,
 	return bytes.Join([][]byte{[]byte("i"), version[:], keypath, indexName, []byte{}}, []byte(":"))
 }
 
+func (t *VersionedDBTree) makeIndexVersionPrefix(version types.ID) []byte {
+	// i:<version>:
+	return bytes.Join([][]byte{[]byte("i"), version[:], []byte{}}, []byte(":"))
+}
+
 func (t *VersionedDBTree) StateAtVersion(version *types.ID, mutable bool) *DBNode {
 	if version == nil {
 		version = &CurrentVersion
@@ -119,7 +124,7 @@ func (t *VersionedDBTree) StateAtVersion(version *types.ID, mutable bool) *DBNod
 		diff = NewDiff()
 	}
 	return &DBNode{
-		tx:        t.db.NewTransaction(mutable),
+		tx:        t.db.NewTxn(mutable),
 		keyPrefix: t.makeStateKeyPrefix(*version),
 		diff:      diff,
 		activeIterator: &activeIterator{
@@ -133,7 +138,7 @@ func (t *VersionedDBTree) IndexAtVersion(version *types.ID, keypath Keypath, ind
 		version = &CurrentVersion
 	}
 	return &DBNode{
-		tx:        t.db.NewTransaction(mutable),
+		tx:        t.db.NewTxn(mutable),
 		keyPrefix: t.makeIndexKeyPrefix(*version, keypath, indexName),
 		activeIterator: &activeIterator{
 			mutable: mutable,
@@ -142,7 +147,7 @@ func (t *VersionedDBTree) IndexAtVersion(version *types.ID, keypath Keypath, ind
 }
 
 type DBNode struct {
-	tx             *badger.Txn
+	tx             KVTxn
 	diff           *Diff
 	keyPrefix      []byte
 	rootKeypath    Keypath
@@ -163,14 +168,7 @@ func (tx *DBNode) Close() {
 }
 
 func (tx *DBNode) Save() error {
-	var err error
-	var wg sync.WaitGroup
-	wg.Add(1)
-	tx.tx.CommitWith(func(innerErr error) {
-		err = innerErr
-		wg.Done()
-	})
-	return err
+	return tx.tx.Commit()
 }
 
 func (tx *DBNode) addKeyPrefix(keypath Keypath) Keypath {
@@ -201,9 +199,7 @@ func (n *DBNode) ParentNodeFor(keypath Keypath) (Node, Keypath) {
 }
 
 func (tx *DBNode) Subkeys() []Keypath {
-	opts := badger.DefaultIteratorOptions
-	opts.PrefetchSize = 10
-	iter := tx.tx.NewIterator(opts)
+	iter := tx.tx.NewIterator(KVIteratorOptions{PrefetchSize: 10})
 	defer iter.Close()
 
 	startKeypath := append(tx.addKeyPrefix(tx.rootKeypath), KeypathSeparator[0])
@@ -211,8 +207,7 @@ func (tx *DBNode) Subkeys() []Keypath {
 	var keypaths []Keypath
 	keypathsMap := make(map[string]struct{})
 	for iter.Seek(startKeypath); iter.ValidForPrefix(startKeypath); iter.Next() {
-		item := iter.Item()
-		absKeypath := Keypath(item.Key())
+		absKeypath := Keypath(iter.Key())
 		subkey := tx.rmKeyPrefix(absKeypath).RelativeTo(tx.rootKeypath).Part(0)
 		_, exists := keypathsMap[string(subkey)]
 		if !exists && len(subkey) > 0 {
@@ -224,20 +219,14 @@ func (tx *DBNode) Subkeys() []Keypath {
 }
 
 func (n *DBNode) NodeInfo(keypath Keypath) (NodeType, ValueType, uint64, error) {
-	item, err := n.tx.Get(n.addKeyPrefix(n.rootKeypath.Push(keypath)))
-	if err == badger.ErrKeyNotFound {
+	val, err := n.tx.Get(n.addKeyPrefix(n.rootKeypath.Push(keypath)))
+	if err == types.Err404 {
 		return 0, 0, 0, errors.Wrap(types.Err404, n.addKeyPrefix(n.rootKeypath.Push(keypath)).String())
 	} else if err != nil {
 		return 0, 0, 0, errors.WithStack(err)
 	}
 
-	var nodeType NodeType
-	var valueType ValueType
-	var length uint64
-	err = item.Value(func(bs []byte) error {
-		nodeType, valueType, length, _, err = decodeNode(bs)
-		return err
-	})
+	nodeType, valueType, length, _, err := decodeNode(val)
 	if err != nil {
 		return 0, 0, 0, errors.WithStack(err)
 	}
@@ -246,7 +235,7 @@ func (n *DBNode) NodeInfo(keypath Keypath) (NodeType, ValueType, uint64, error)
 
 func (n *DBNode) Exists(keypath Keypath) (bool, error) {
 	_, err := n.tx.Get(n.addKeyPrefix(n.rootKeypath.Push(keypath)))
-	if err == badger.ErrKeyNotFound {
+	if err == types.Err404 {
 		return false, nil
 	} else if err != nil {
 		return false, err
@@ -268,19 +257,14 @@ func (tx *DBNode) Value(relKeypath Keypath, rng *Range) (_ interface{}, _ bool,
 
 	rootKeypath := tx.addKeyPrefix(tx.rootKeypath.Push(relKeypath))
 
-	item, err := tx.tx.Get(rootKeypath)
+	val, err := tx.tx.Get(rootKeypath)
 	if err != nil {
-		if err == badger.ErrKeyNotFound {
+		if err == types.Err404 {
 			return nil, false, nil
 		}
 		return nil, false, err
 	}
 
-	val, err := item.ValueCopy(nil)
-	if err != nil {
-		return nil, false, err
-	}
-
 	rootNodeType, valueType, length, data, err := decodeNode(val)
 	if err != nil {
 		return nil, false, err
@@ -317,8 +301,7 @@ func (tx *DBNode) Value(relKeypath Keypath, rng *Range) (_ interface{}, _ bool,
 		}
 	}
 
-	var valueBuf []byte
-	err = tx.scanChildrenForward(rootNodeType, relKeypath, rng, length, true, func(absKeypath Keypath, item *badger.Item) error {
+	err = tx.scanChildrenForward(rootNodeType, relKeypath, rng, length, true, func(absKeypath Keypath, kvIter KVIterator) error {
 		relKeypath := absKeypath.RelativeTo(rootKeypath)
 
 		// If we're ranging over a slice, transpose its indices to start from 0.
@@ -330,7 +313,7 @@ func (tx *DBNode) Value(relKeypath Keypath, rng *Range) (_ interface{}, _ bool,
 		// Decode the value from the DB into a Go value
 		var val interface{}
 		{
-			valueBuf, err = item.ValueCopy(valueBuf)
+			valueBuf, err := kvIter.Value()
 			if err != nil {
 				return err
 			}
@@ -724,19 +707,13 @@ func (node *DBNode) Scan(into interface{}) error {
 }
 
 func (tx *DBNode) Length() (uint64, error) {
-	item, err := tx.tx.Get(tx.addKeyPrefix(tx.rootKeypath))
-	if err == badger.ErrKeyNotFound {
+	val, err := tx.tx.Get(tx.addKeyPrefix(tx.rootKeypath))
+	if err == types.Err404 {
 		return 0, nil
 	} else if err != nil {
 		return 0, err
 	}
 
-	var val []byte
-	item.Value(func(bs []byte) error {
-		val = bs
-		return nil
-	})
-
 	nodeType, valueType, length, _, err := decodeNode(val)
 	if err != nil {
 		return 0, err
@@ -783,22 +760,14 @@ func (tx *DBNode) Set(relKeypath Keypath, rng *Range, val interface{}) error {
 			return errors.WithStack(ErrInvalidRange)
 		}
 
-		item, err := tx.tx.Get(tx.addKeyPrefix(absKeypath))
-		if err == badger.ErrKeyNotFound {
+		encodedVal, err := tx.tx.Get(tx.addKeyPrefix(absKeypath))
+		if err == types.Err404 {
 			// @@TODO: ??
 			return errors.WithStack(ErrRangeOverNonSlice)
 		} else if err != nil {
 			return errors.Errorf("error fetching keypath %v while setting range", absKeypath)
 		}
 
-		var encodedVal []byte
-		if item != nil {
-			encodedVal, err = item.ValueCopy(nil)
-			if err != nil {
-				return errors.WithStack(err)
-			}
-		}
-
 		switch spliceVal := val.(type) {
 		case []byte:
 			return tx.setRangeBytes(absKeypath, rng, encodedVal, spliceVal)
@@ -863,10 +832,11 @@ func (tx *DBNode) setRangeSlice(absKeypath Keypath, rng *Range, encodedVal []byt
 
 	// Delete deleted items
 	{
-		opts := badger.DefaultIteratorOptions
-		opts.PrefetchValues = false
-		opts.Reverse = false
-		opts.Prefix = append(absKeypath, KeypathSeparator[0])
+		opts := KVIteratorOptions{
+			PrefetchValues: false,
+			Reverse:        false,
+			Prefix:         append(absKeypath, KeypathSeparator[0]),
+		}
 
 		iter := tx.tx.NewIterator(opts)
 		defer iter.Close()
@@ -875,8 +845,7 @@ func (tx *DBNode) setRangeSlice(absKeypath Keypath, rng *Range, encodedVal []byt
 		endKeypath := absKeypath.PushIndex(endIdx)
 
 		for iter.Seek(startKeypath); iter.ValidForPrefix(opts.Prefix); iter.Next() {
-			item := iter.Item()
-			keypath := Keypath(item.KeyCopy(nil))
+			keypath := Keypath(iter.Key()).Copy()
 			if keypath.Equals(endKeypath) {
 				break
 			}
@@ -892,9 +861,10 @@ func (tx *DBNode) setRangeSlice(absKeypath Keypath, rng *Range, encodedVal []byt
 
 	// Shift indices of trailing items
 	if newLen != oldLen && oldLen != 0 {
-		opts := badger.DefaultIteratorOptions
-		opts.PrefetchValues = true
-		opts.Reverse = !shrink
+		opts := KVIteratorOptions{
+			PrefetchValues: true,
+			Reverse:        !shrink,
+		}
 
 		scanPrefix := append(absKeypath, KeypathSeparator[0])
 
@@ -912,10 +882,8 @@ func (tx *DBNode) setRangeSlice(absKeypath Keypath, rng *Range, encodedVal []byt
 		}
 		prefixLen := len(scanPrefix)
 
-		var keypathBuf []byte
 		for iter.Seek(startKeypath); iter.ValidForPrefix(scanPrefix); iter.Next() {
-			item := iter.Item()
-			oldKeypath := Keypath(item.KeyCopy(keypathBuf))
+			oldKeypath := Keypath(iter.Key()).Copy()
 			if oldKeypath.Equals(endKeypath) {
 				break
 			}
@@ -931,7 +899,7 @@ func (tx *DBNode) setRangeSlice(absKeypath Keypath, rng *Range, encodedVal []byt
 				newIdx = oldIdx + (newLen - oldLen)
 			}
 
-			valueBuf, err := item.ValueCopy(nil)
+			valueBuf, err := iter.Value()
 			if err != nil {
 				return err
 			}
@@ -1000,8 +968,8 @@ func (tx *DBNode) setNoRange(absKeypath Keypath, value interface{}) error {
 	for i := 0; i < numParts; i++ {
 		partialKeypath := tx.addKeyPrefix(absKeypath.FirstNParts(i))
 
-		item, err := tx.tx.Get(partialKeypath)
-		if err == badger.ErrKeyNotFound || item.IsDeletedOrExpired() {
+		_, err := tx.tx.Get(partialKeypath)
+		if err == types.Err404 {
 			encoded, err := encodeNode(NodeTypeMap, ValueTypeInvalid, 1, nil)
 			if err != nil {
 				return err
@@ -1061,11 +1029,7 @@ func (tx *DBNode) setNoRange(absKeypath Keypath, value interface{}) error {
 }
 
 func (tx *DBNode) encodedBytes(absKeypath Keypath) ([]byte, error) {
-	item, err := tx.tx.Get(tx.addKeyPrefix(absKeypath))
-	if err != nil {
-		return nil, err
-	}
-	return item.ValueCopy(nil)
+	return tx.tx.Get(tx.addKeyPrefix(absKeypath))
 }
 
 func (n *DBNode) innerNode(relKeypath Keypath) Node {
@@ -1150,18 +1114,13 @@ func (tx *DBNode) Delete(relKeypath Keypath, rng *Range) (err error) {
 
 	rootKeypath := tx.addKeyPrefix(tx.rootKeypath.Push(relKeypath))
 
-	item, err := tx.tx.Get(rootKeypath)
-	if err != nil && err == badger.ErrKeyNotFound {
+	val, err := tx.tx.Get(rootKeypath)
+	if err == types.Err404 {
 		return nil
 	} else if err != nil {
 		return err
 	}
 
-	val, err := item.ValueCopy(nil)
-	if err != nil {
-		return err
-	}
-
 	rootNodeType, valueType, length, data, err := decodeNode(val)
 	if err != nil {
 		return err
@@ -1206,7 +1165,7 @@ func (tx *DBNode) Delete(relKeypath Keypath, rng *Range) (err error) {
 
 	// Delete child nodes
 	{
-		tx.scanChildrenForward(rootNodeType, relKeypath, rng, length, false, func(absKeypath Keypath, item *badger.Item) error {
+		tx.scanChildrenForward(rootNodeType, relKeypath, rng, length, false, func(absKeypath Keypath, kvIter KVIterator) error {
 			// This .Copy() is necessary.  See https://github.com/dgraph-io/badger/issues/494
 			err := tx.tx.Delete(absKeypath.Copy())
 			if err != nil {
@@ -1233,8 +1192,8 @@ func (tx *DBNode) Delete(relKeypath Keypath, rng *Range) (err error) {
 			renumberRange := &Range{int64(endIdx), int64(length)}
 			delta := -int64(rng.Size())
 
-			err := tx.scanChildrenForward(NodeTypeSlice, relKeypath, renumberRange, length, true, func(absKeypath Keypath, item *badger.Item) error {
-				valueBuf, err := item.ValueCopy(nil)
+			err := tx.scanChildrenForward(NodeTypeSlice, relKeypath, renumberRange, length, true, func(absKeypath Keypath, kvIter KVIterator) error {
+				valueBuf, err := kvIter.Value()
 				if err != nil {
 					return err
 				}
@@ -1298,14 +1257,7 @@ func (tx *DBNode) CopyToMemory(relKeypath Keypath, rng *Range) (n Node, err erro
 
 	rootKeypath := tx.addKeyPrefix(tx.rootKeypath.Push(relKeypath))
 
-	item, err := tx.tx.Get(rootKeypath)
-	if errors.Cause(err) == badger.ErrKeyNotFound {
-		return nil, types.Err404
-	} else if err != nil {
-		return nil, err
-	}
-
-	valBytes, err := item.ValueCopy(nil)
+	valBytes, err := tx.tx.Get(rootKeypath)
 	if err != nil {
 		return nil, err
 	}
@@ -1356,9 +1308,8 @@ func (tx *DBNode) CopyToMemory(relKeypath Keypath, rng *Range) (n Node, err erro
 	}
 
 	var newKeypaths []Keypath
-	var valBuf []byte
 
-	err = tx.scanChildrenForward(rootNodeType, relKeypath, rng, length, true, func(absKeypath Keypath, item *badger.Item) error {
+	err = tx.scanChildrenForward(rootNodeType, relKeypath, rng, length, true, func(absKeypath Keypath, kvIter KVIterator) error {
 		relKeypath := absKeypath.RelativeTo(rootKeypath).Copy()
 
 		// If we're ranging over a slice, transpose its indices so that they start from 0
@@ -1366,10 +1317,7 @@ func (tx *DBNode) CopyToMemory(relKeypath Keypath, rng *Range) (n Node, err erro
 			relKeypath = renumberSliceIndexKeypath(rootKeypath, absKeypath, -int64(startIdx))
 		}
 
-		err := item.Value(func(bs []byte) error {
-			valBuf = bs
-			return nil
-		})
+		valBuf, err := kvIter.Value()
 		if err != nil {
 			return err
 		}
@@ -1436,26 +1384,54 @@ func (tx *DBNode) DebugPrint(printFn func(inFormat string, args ...interface{}),
 	printFn(indent + "}")
 }
 
+// CopyVersion copies a version of the state, along with any indices that were
+// built for it, to a new version.
 func (t *VersionedDBTree) CopyVersion(dstVersion, srcVersion types.ID) error {
-	return t.db.Update(func(tx *badger.Txn) error {
-		stream := t.db.NewStream()
-		stream.NumGo = 16
-		stream.Prefix = append(srcVersion[:], ':')
-
-		stream.Send = func(list *badgerpb.KVList) error {
-			for _, kv := range list.Kv {
-				newKey := kv.Key
-				copy(newKey[:stateKeyPrefixLen], dstVersion[:])
-				err := tx.Set(newKey, kv.Value)
-				if err != nil {
-					return err
-				}
-			}
-			return nil
+	txn := t.db.NewTxn(true)
+	defer txn.Discard()
+
+	err := t.copyPrefix(txn, t.makeStateKeyPrefix(srcVersion), t.makeStateKeyPrefix(dstVersion))
+	if err != nil {
+		return err
+	}
+	err = t.copyPrefix(txn, t.makeIndexVersionPrefix(srcVersion), t.makeIndexVersionPrefix(dstVersion))
+	if err != nil {
+		return err
+	}
+	return txn.Commit()
+}
+
+func (t *VersionedDBTree) copyPrefix(txn KVTxn, srcPrefix, dstPrefix []byte) error {
+	iter := txn.NewIterator(KVIteratorOptions{PrefetchValues: true, PrefetchSize: 100, Prefix: srcPrefix})
+	defer iter.Close()
+
+	for iter.Seek(srcPrefix); iter.Valid(); iter.Next() {
+		newKey := append(append([]byte(nil), dstPrefix...), iter.Key()[len(srcPrefix):]...)
+
+		val, err := iter.Value()
+		if err != nil {
+			return err
+		}
+		err = txn.Set(newKey, val)
+		if err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		return stream.Orchestrate(context.TODO())
-	})
+// DeleteVersion removes a saved version of the state and its indices.  How
+// quickly the space is reclaimed, and whether writers are blocked meanwhile,
+// depends on the KVStore.
+func (t *VersionedDBTree) DeleteVersion(version types.ID) error {
+	if version == CurrentVersion {
+		return errors.New("can't delete the current version")
+	}
+	err := t.db.DeletePrefix(t.makeStateKeyPrefix(version))
+	if err != nil {
+		return err
+	}
+	return t.db.DeletePrefix(t.makeIndexVersionPrefix(version))
 }
 
 func (n *DBNode) MarshalJSON() ([]byte, error) {
@@ -1470,10 +1446,11 @@ func (t *DBTree) DebugPrint(keypathPrefix Keypath, rng *Range) ([]Keypath, []int
 	keypaths := make([]Keypath, 0)
 	values := make([]interface{}, 0)
 
-	err := t.db.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		opts.PrefetchValues = true
-		iter := txn.NewIterator(opts)
+	err := func() error {
+		txn := t.db.NewTxn(false)
+		defer txn.Discard()
+
+		iter := txn.NewIterator(KVIteratorOptions{PrefetchValues: true})
 		defer iter.Close()
 
 		startKeypath := keypathPrefix
@@ -1484,11 +1461,11 @@ func (t *DBTree) DebugPrint(keypathPrefix Keypath, rng *Range) ([]Keypath, []int
 		}
 
 		for iter.Seek(startKeypath); iter.ValidForPrefix(keypathPrefix); iter.Next() {
-			if endKeypath != nil && endKeypath.Equals(iter.Item().Key()) {
+			if endKeypath != nil && endKeypath.Equals(iter.Key()) {
 				break
 			}
-			keypaths = append(keypaths, Keypath(iter.Item().KeyCopy(nil)))
-			encoded, err := iter.Item().ValueCopy(nil)
+			keypaths = append(keypaths, Keypath(iter.Key()).Copy())
+			encoded, err := iter.Value()
 			if err != nil {
 				return err
 			}
@@ -1505,7 +1482,7 @@ func (t *DBTree) DebugPrint(keypathPrefix Keypath, rng *Range) ([]Keypath, []int
 			values = append(values, val)
 		}
 		return nil
-	})
+	}()
 	return keypaths, values, err
 }
 
@@ -1515,12 +1492,12 @@ func (n *DBNode) Iterator(keypath Keypath, prefetchValues bool, prefetchSize int
 		return newReusableIterator(n.activeIterator.iter, keypath, n)
 	}
 
-	opts := badger.DefaultIteratorOptions
-	opts.Reverse = false
-	opts.PrefetchValues = prefetchValues
-	opts.PrefetchSize = prefetchSize
-	badgerIter := n.tx.NewIterator(opts)
-	iter := newIteratorFromBadgerIterator(badgerIter, keypath, n)
+	kvIter := n.tx.NewIterator(KVIteratorOptions{
+		Reverse:        false,
+		PrefetchValues: prefetchValues,
+		PrefetchSize:   prefetchSize,
+	})
+	iter := newIteratorFromKVIterator(kvIter, keypath, n)
 	n.activeIterator.iter = iter
 	return iter
 }
@@ -1534,11 +1511,11 @@ func (n *DBNode) ChildIterator(keypath Keypath, prefetchValues bool, prefetchSiz
 }
 
 func (n *DBNode) DepthFirstIterator(keypath Keypath, prefetchValues bool, prefetchSize int) Iterator {
-	opts := badger.DefaultIteratorOptions
-	opts.Reverse = true
-	opts.PrefetchValues = prefetchValues
-	opts.PrefetchSize = prefetchSize
-	iter := n.tx.NewIterator(opts)
+	iter := n.tx.NewIterator(KVIteratorOptions{
+		Reverse:        true,
+		PrefetchValues: prefetchValues,
+		PrefetchSize:   prefetchSize,
+	})
 
 	rootKeypath := n.rootKeypath.Push(keypath)
 	scanPrefix := n.addKeyPrefix(rootKeypath)
@@ -1560,11 +1537,152 @@ type Indexer interface {
 	IndexNode(relKeypath Keypath, state Node) (Keypath, Node, error)
 }
 
+// UniqueKeyIndexer is implemented by indexers that give each child an index
+// key of its own (for instance, by appending the child's key to it).  Their
+// entries are never grouped, even when the indexed node is a slice.
+type UniqueKeyIndexer interface {
+	Indexer
+	UniqueKeys() bool
+}
+
+// MultiKeyIndexer is implemented by indexers that file each child under any
+// number of index keys (for instance, a full-text indexer files it under each
+// of its words).  Each entry is stored at <index key>/<child key>, with the
+// child key hex encoded.  Its IndexNode method is never called.
+type MultiKeyIndexer interface {
+	Indexer
+	IndexNodeKeys(relKeypath Keypath, node Node) ([]IndexEntry, error)
+}
+
+type IndexEntry struct {
+	Key  Keypath
+	Node Node
+}
+
+func groupsIndexEntries(nodeType NodeType, indexer Indexer) bool {
+	if nodeType != NodeTypeSlice {
+		return false
+	} else if _, is := indexer.(MultiKeyIndexer); is {
+		return false
+	}
+	u, is := indexer.(UniqueKeyIndexer)
+	return !is || !u.UniqueKeys()
+}
+
+// indexEntries returns the entries that a child is filed under, keyed by their
+// keypaths within the index.  It doesn't handle grouped entries.
+func indexEntries(indexer Indexer, childKey Keypath, child Node) ([]IndexEntry, error) {
+	if multiKeyIndexer, is := indexer.(MultiKeyIndexer); is {
+		entries, err := multiKeyIndexer.IndexNodeKeys(childKey, child)
+		if err != nil {
+			return nil, err
+		}
+		childKeyHex := Keypath(hex.EncodeToString(childKey))
+		for i := range entries {
+			entries[i].Key = entries[i].Key.Push(childKeyHex)
+		}
+		return entries, nil
+	}
+
+	indexKey, indexNode, err := indexer.IndexNode(childKey, child)
+	if err != nil {
+		return nil, err
+	} else if indexKey == nil || indexNode == nil {
+		return nil, nil
+	}
+	return []IndexEntry{{Key: indexKey, Node: indexNode}}, nil
+}
+
+// KeyRange selects some of a map node's children by key.  Keys are compared
+// bytewise.  Nil bounds are open, and a Limit of 0 means no limit.
+type KeyRange struct {
+	Start   Keypath // inclusive
+	End     Keypath // exclusive
+	Prefix  Keypath
+	Limit   uint64
+	Reverse bool
+}
+
+// ChildKeysInRange returns the keys of the node's children that fall within
+// the given KeyRange, in sorted (or reverse sorted) order.
+func (n *DBNode) ChildKeysInRange(keyRange KeyRange) ([]Keypath, error) {
+	lower, upper := keyRange.Start, keyRange.End
+	if keyRange.Prefix != nil {
+		if bytes.Compare(keyRange.Prefix, lower) > 0 {
+			lower = keyRange.Prefix
+		}
+		prefixEnd := prefixSuccessor(keyRange.Prefix)
+		if prefixEnd != nil && (upper == nil || bytes.Compare(prefixEnd, upper) < 0) {
+			upper = prefixEnd
+		}
+	}
+
+	scanPrefix := append(Keypath(nil), n.addKeyPrefix(n.rootKeypath)...)
+	if len(scanPrefix) != len(n.keyPrefix) {
+		scanPrefix = append(scanPrefix, KeypathSeparator[0])
+	}
+
+	iter := n.tx.NewIterator(KVIteratorOptions{Reverse: keyRange.Reverse, Prefix: scanPrefix})
+	defer iter.Close()
+
+	var seekKey []byte
+	if !keyRange.Reverse {
+		seekKey = append(append([]byte(nil), scanPrefix...), lower...)
+	} else if upper != nil {
+		seekKey = append(append([]byte(nil), scanPrefix...), upper...)
+	} else {
+		seekKey = append(append([]byte(nil), scanPrefix...), 0xff)
+	}
+
+	var keys []Keypath
+	for iter.Seek(seekKey); iter.Valid(); iter.Next() {
+		key := Keypath(iter.Key()[len(scanPrefix):])
+		if len(key) == 0 || key.IndexByte(KeypathSeparator[0]) != -1 {
+			// Skip the node itself and its grandchildren
+			continue
+		}
+
+		if lower != nil && bytes.Compare(key, lower) < 0 {
+			if keyRange.Reverse {
+				break
+			}
+			continue
+		} else if upper != nil && bytes.Compare(key, upper) >= 0 {
+			if !keyRange.Reverse {
+				break
+			}
+			continue
+		}
+
+		keys = append(keys, key.Copy())
+		if keyRange.Limit > 0 && uint64(len(keys)) == keyRange.Limit {
+			break
+		}
+	}
+	return keys, nil
+}
+
+// prefixSuccessor returns the smallest key that's greater than every key with
+// the given prefix, or nil if there isn't one.
+func prefixSuccessor(prefix []byte) []byte {
+	end := append([]byte(nil), prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
 func prettyJSON(x interface{}) string {
 	j, _ := json.MarshalIndent(x, "", "    ")
 	return string(j)
 }
 
+// BuildIndex indexes every child of the given node.  Map children are stored
+// under their index key.  Slice children are grouped into a slice under their
+// index key, in their original order, unless the indexer is a UniqueKeyIndexer.
 func (t *VersionedDBTree) BuildIndex(version *types.ID, keypath Keypath, node Node, indexName Keypath, indexer Indexer) (err error) {
 	defer utils.Annotate(&err, "BuildIndex")
 
@@ -1582,11 +1700,28 @@ func (t *VersionedDBTree) BuildIndex(version *types.ID, keypath Keypath, node No
 		return err
 	}
 
+	grouped := groupsIndexEntries(rootNodeType, indexer)
+
 	children := make(map[string]map[string]struct{})
 	for iter.Rewind(); iter.Valid(); iter.Next() {
 		childNode := iter.Node()
 		relKeypath := childNode.Keypath().RelativeTo(node.Keypath())
 
+		if !grouped {
+			entries, err := indexEntries(indexer, relKeypath, childNode)
+			if err != nil {
+				return err
+			}
+			for _, entry := range entries {
+				err = index.Set(entry.Key, nil, entry.Node)
+				if err != nil {
+					t.Error(err)
+					return err
+				}
+			}
+			continue
+		}
+
 		indexKey, indexNode, err := indexer.IndexNode(relKeypath, childNode)
 		if err != nil {
 			return err
@@ -1599,17 +1734,10 @@ func (t *VersionedDBTree) BuildIndex(version *types.ID, keypath Keypath, node No
 		}
 		children[string(indexKey)][string(relKeypath.Part(0))] = struct{}{}
 
-		if rootNodeType == NodeTypeSlice {
-			// If it's a slice, we have to renumber its children
-			newIdx := uint64(len(children[string(indexKey)])) - 1
-			_, rest := relKeypath.Shift()
-			relKeypath = rest.Unshift(EncodeSliceIndex(newIdx))
-
-		} else if rootNodeType == NodeTypeMap {
-			// If it's a map, we have to replace the root key with the indexKey
-			_, rest := relKeypath.Shift()
-			relKeypath = rest.Unshift(indexKey)
-		}
+		// If it's a slice, we have to renumber its children
+		newIdx := uint64(len(children[string(indexKey)])) - 1
+		_, rest := relKeypath.Shift()
+		relKeypath = rest.Unshift(EncodeSliceIndex(newIdx)).Unshift(indexKey)
 
 		err = index.Set(relKeypath, nil, indexNode)
 		if err != nil {
@@ -1618,16 +1746,13 @@ func (t *VersionedDBTree) BuildIndex(version *types.ID, keypath Keypath, node No
 		}
 	}
 
-	// Set the index's node types to the type of the original keypath being indexed
-	for indexKey, child := range children {
-		encoded, err := encodeNode(rootNodeType, 0, uint64(len(child)), nil)
-		if err != nil {
-			return err
-		}
-
-		err = index.tx.Set(index.addKeyPrefix(Keypath(indexKey)), encoded)
-		if err != nil {
-			return err
+	// Set the node types of a slice's groups
+	if grouped {
+		for indexKey, child := range children {
+			err = index.setIndexGroupLength(Keypath(indexKey), uint64(len(child)))
+			if err != nil {
+				return err
+			}
 		}
 	}
 
@@ -1643,13 +1768,198 @@ func (t *VersionedDBTree) BuildIndex(version *types.ID, keypath Keypath, node No
 	return index.Save()
 }
 
+// UpdateIndex brings an index made by BuildIndex up to date after some of the
+// indexed node's children have changed.  oldNode and newNode are the indexed
+// node before and after the change, and changedKeypaths holds the keypaths
+// (relative to the indexed node) that were added or removed.  Map children are moved or
+// removed one at a time, and new slice children are appended to their group.
+// Anything else (a slice being spliced or modified in place, or the node
+// changing type) rebuilds the index from newNode.
+func (t *VersionedDBTree) UpdateIndex(version *types.ID, keypath Keypath, indexName Keypath, oldNode, newNode Node, changedKeypaths []Keypath, indexer Indexer) (err error) {
+	defer utils.Annotate(&err, "UpdateIndex")
+
+	rebuild, err := t.updateIndex(version, keypath, indexName, oldNode, newNode, changedKeypaths, indexer)
+	if err != nil {
+		return err
+	} else if rebuild {
+		return t.RebuildIndex(version, keypath, newNode, indexName, indexer)
+	}
+	return nil
+}
+
+func (t *VersionedDBTree) updateIndex(version *types.ID, keypath Keypath, indexName Keypath, oldNode, newNode Node, changedKeypaths []Keypath, indexer Indexer) (rebuild bool, err error) {
+	index := t.IndexAtVersion(version, keypath, indexName, true)
+	defer index.Close()
+
+	exists, err := index.Exists(nil)
+	if err != nil {
+		return false, err
+	} else if !exists {
+		return true, nil
+	}
+
+	oldNodeType, _, oldLen, err := oldNode.NodeInfo(nil)
+	if errors.Cause(err) == types.Err404 {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	newNodeType, _, _, err := newNode.NodeInfo(nil)
+	if errors.Cause(err) == types.Err404 {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	} else if oldNodeType != newNodeType {
+		return true, nil
+	}
+
+	switch newNodeType {
+	case NodeTypeMap:
+		changedChildren := make(map[string]struct{})
+		for _, kp := range changedKeypaths {
+			changedChildren[string(kp.Part(0))] = struct{}{}
+		}
+
+		for key := range changedChildren {
+			childKey := Keypath(key)
+			oldEntries, err := indexChild(indexer, oldNode, childKey)
+			if err != nil {
+				return false, err
+			}
+			newEntries, err := indexChild(indexer, newNode, childKey)
+			if err != nil {
+				return false, err
+			}
+
+			newEntryKeys := make(map[string]struct{}, len(newEntries))
+			for _, entry := range newEntries {
+				newEntryKeys[string(entry.Key)] = struct{}{}
+			}
+			for _, entry := range oldEntries {
+				if _, exists := newEntryKeys[string(entry.Key)]; !exists {
+					err = index.Delete(entry.Key, nil)
+					if err != nil {
+						return false, err
+					}
+				}
+			}
+			for _, entry := range newEntries {
+				err = index.Set(entry.Key, nil, entry.Node)
+				if err != nil {
+					return false, err
+				}
+			}
+		}
+
+	case NodeTypeSlice:
+		changedChildren := make(map[uint64]struct{})
+		for _, kp := range changedKeypaths {
+			if len(kp) < 8 {
+				return true, nil
+			}
+			idx := DecodeSliceIndex(kp[:8])
+			if idx < oldLen {
+				return true, nil
+			}
+			changedChildren[idx] = struct{}{}
+		}
+
+		indices := make([]uint64, 0, len(changedChildren))
+		for idx := range changedChildren {
+			indices = append(indices, idx)
+		}
+		sort.Slice(indices, func(i, j int) bool { return indices[i] < indices[j] })
+
+		for _, idx := range indices {
+			entries, err := indexChild(indexer, newNode, EncodeSliceIndex(idx))
+			if err != nil {
+				return false, err
+			}
+
+			if !groupsIndexEntries(newNodeType, indexer) {
+				for _, entry := range entries {
+					err = index.Set(entry.Key, nil, entry.Node)
+					if err != nil {
+						return false, err
+					}
+				}
+				continue
+			} else if len(entries) == 0 {
+				continue
+			}
+			indexKey, indexNode := entries[0].Key, entries[0].Node
+
+			_, _, groupLen, err := index.NodeInfo(indexKey)
+			if err != nil && errors.Cause(err) != types.Err404 {
+				return false, err
+			}
+			err = index.Set(indexKey.PushIndex(groupLen), nil, indexNode)
+			if err != nil {
+				return false, err
+			}
+			err = index.setIndexGroupLength(indexKey, groupLen+1)
+			if err != nil {
+				return false, err
+			}
+		}
+
+	default:
+		return true, nil
+	}
+	return false, index.Save()
+}
+
+// indexChild runs an indexer over a single child of a node.  There are no
+// entries if the child doesn't exist or the indexer skipped it.
+func indexChild(indexer Indexer, node Node, childKey Keypath) ([]IndexEntry, error) {
+	child, err := node.CopyToMemory(childKey, nil)
+	if errors.Cause(err) == types.Err404 {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return indexEntries(indexer, childKey, child)
+}
+
+func (index *DBNode) setIndexGroupLength(indexKey Keypath, length uint64) error {
+	encoded, err := encodeNode(NodeTypeSlice, 0, length, nil)
+	if err != nil {
+		return err
+	}
+	return index.tx.Set(index.addKeyPrefix(indexKey), encoded)
+}
+
+// RebuildIndex discards an index and builds it again from the given node.  If
+// the node doesn't exist, the index is simply removed.
+func (t *VersionedDBTree) RebuildIndex(version *types.ID, keypath Keypath, node Node, indexName Keypath, indexer Indexer) error {
+	err := t.DeleteIndex(version, keypath, indexName)
+	if err != nil {
+		return err
+	}
+
+	nodeToIndex, err := node.CopyToMemory(nil, nil)
+	if errors.Cause(err) == types.Err404 {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return t.BuildIndex(version, keypath, nodeToIndex, indexName, indexer)
+}
+
+func (t *VersionedDBTree) DeleteIndex(version *types.ID, keypath Keypath, indexName Keypath) error {
+	if version == nil {
+		version = &CurrentVersion
+	}
+	return t.db.DeletePrefix(t.makeIndexKeyPrefix(*version, keypath, indexName))
+}
+
 func (n *DBNode) scanChildrenForward(
 	rootNodeType NodeType,
 	relKeypath Keypath,
 	rng *Range,
 	length uint64,
 	prefetchValues bool,
-	fn func(absKeypath Keypath, item *badger.Item) error,
+	fn func(absKeypath Keypath, kvIter KVIterator) error,
 ) error {
 	var startKeypath Keypath
 	var endKeypath Keypath
@@ -1702,16 +2012,16 @@ func (n *DBNode) scanChildrenForward(
 	var prevKeypath Keypath
 	var shouldStop bool
 	for ; iter.Valid() && !shouldStop; iter.Next() {
-		var item *badger.Item
+		var kvIter KVIterator
 		switch i := iter.(type) {
 		case *dbIterator:
-			item = i.iter.Item()
+			kvIter = i.iter
 		case *reusableIterator:
-			item = i.badgerIter.Item()
+			kvIter = i.kvIter
 		default:
 			panic("this should never happen")
 		}
-		absKeypath := Keypath(item.Key())
+		absKeypath := Keypath(kvIter.Key())
 
 		// If we have a range, we have to figure out when to stop iterating
 		if rng != nil {
@@ -1734,7 +2044,7 @@ func (n *DBNode) scanChildrenForward(
 			}
 		}
 
-		err := fn(absKeypath, item)
+		err := fn(absKeypath, kvIter)
 		if err != nil {
 			return err
 		}
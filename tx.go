@@ -2,6 +2,7 @@ package redwood
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"sort"
@@ -9,6 +10,7 @@ import (
 
 	proto "github.com/golang/protobuf/proto"
 	any "github.com/golang/protobuf/ptypes/any"
+	"github.com/pkg/errors"
 
 	"redwood.dev/pb"
 	"redwood.dev/tree"
@@ -35,6 +37,7 @@ type Tx struct {
 	Recipients []types.Address `json:"recipients,omitempty"`
 	Checkpoint bool            `json:"checkpoint"` // @@TODO: probably not ideal
 	Attachment []byte          `json:"attachment,omitempty"`
+	Timestamp  int64           `json:"timestamp,omitempty"` // Unix seconds, signed by the sender
 
 	Status TxStatus   `json:"status"`
 	hash   types.Hash `json:"-"`
@@ -69,6 +72,13 @@ func (tx Tx) Hash() types.Hash {
 			txBytes = append(txBytes, tx.Recipients[i][:]...)
 		}
 
+		// Left out when unset so that the hashes of untimed txs don't change
+		if tx.Timestamp != 0 {
+			var timestamp [8]byte
+			binary.BigEndian.PutUint64(timestamp[:], uint64(tx.Timestamp))
+			txBytes = append(txBytes, timestamp[:]...)
+		}
+
 		tx.hash = types.HashBytes(txBytes)
 	}
 
@@ -126,6 +136,7 @@ func (tx *Tx) Copy() *Tx {
 		Recipients: recipients,
 		Checkpoint: tx.Checkpoint,
 		Attachment: attachment,
+		Timestamp:  tx.Timestamp,
 		Status:     tx.Status,
 		hash:       tx.hash,
 	}
@@ -171,8 +182,10 @@ func (tx Tx) MarshalProto() ([]byte, error) {
 		}
 
 		patches[i] = &pb.Patch{
+			Op:      string(patch.Op),
 			Keypath: []byte(patch.Keypath),
 			Range:   rng,
+			From:    []byte(patch.From),
 			Value:   &any.Any{Value: valueBytes},
 		}
 	}
@@ -194,6 +207,7 @@ func (tx Tx) MarshalProto() ([]byte, error) {
 		Checkpoint: tx.Checkpoint,
 		Attachment: tx.Attachment,
 		Status:     string(tx.Status),
+		Timestamp:  tx.Timestamp,
 	})
 }
 
@@ -222,7 +236,11 @@ func (tx *Tx) UnmarshalProto(bs []byte) error {
 
 	tx.Patches = make([]Patch, len(pbtx.Patches))
 	for i, patch := range pbtx.Patches {
+		tx.Patches[i].Op = PatchOp(patch.Op)
 		tx.Patches[i].Keypath = tree.Keypath(patch.Keypath)
+		if len(patch.From) > 0 {
+			tx.Patches[i].From = tree.Keypath(patch.From)
+		}
 		if patch.Range != nil {
 			tx.Patches[i].Range = &tree.Range{
 				Start: patch.Range.Start,
@@ -242,55 +260,173 @@ func (tx *Tx) UnmarshalProto(bs []byte) error {
 
 	tx.Checkpoint = pbtx.Checkpoint
 	tx.Attachment = pbtx.Attachment
+	tx.Timestamp = pbtx.Timestamp
 	tx.Status = TxStatus(pbtx.Status)
 	return nil
 }
 
 type Patch struct {
+	Op      PatchOp
 	Keypath tree.Keypath
 	Range   *tree.Range
+	From    tree.Keypath // The source of a move or copy
 	Val     interface{}
 }
 
+// PatchOp is the operation that a patch performs at its keypath.  The zero
+// value sets the keypath to the patch's value.
+type PatchOp string
+
+const (
+	PatchOpSet       PatchOp = ""
+	PatchOpDelete    PatchOp = "delete"
+	PatchOpMove      PatchOp = "move"
+	PatchOpCopy      PatchOp = "copy"
+	PatchOpIncrement PatchOp = "increment"
+	PatchOpTest      PatchOp = "test"
+)
+
+// ErrPatchTestFailed is returned when a test patch's precondition doesn't
+// hold, which causes the whole tx to be rejected.
+var ErrPatchTestFailed = errors.New("patch test failed")
+
 type Range struct {
 	Start int64
 	End   int64
 }
 
 func (p Patch) String() string {
-	parts := p.Keypath.Parts()
-	var keypathParts []string
-	for _, key := range parts {
-		if bytes.IndexByte(key, '.') > -1 {
-			keypathParts = append(keypathParts, `["`+string(key)+`"]`)
-		} else {
-			keypathParts = append(keypathParts, KeypathSeparator+string(key))
-		}
-	}
-	s := strings.Join(keypathParts, "")
-
+	s := patchKeypathString(p.Keypath)
 	if p.Range != nil {
 		s += fmt.Sprintf("[%v:%v]", p.Range.Start, p.Range.End)
 	}
 
+	switch p.Op {
+	case PatchOpDelete:
+		return "delete " + s
+	case PatchOpMove, PatchOpCopy:
+		return string(p.Op) + " " + patchKeypathString(p.From) + " to " + s
+	}
+
 	val, err := json.Marshal(p.Val)
 	if err != nil {
 		panic(err)
 	}
 
-	s += " = " + string(val)
+	switch p.Op {
+	case PatchOpIncrement:
+		return s + " += " + string(val)
+	case PatchOpTest:
+		return "test " + s + " == " + string(val)
+	default:
+		return s + " = " + string(val)
+	}
+}
 
-	return s
+func patchKeypathString(keypath tree.Keypath) string {
+	var keypathParts []string
+	for _, key := range keypath.Parts() {
+		if bytes.IndexByte(key, '.') > -1 {
+			keypathParts = append(keypathParts, `["`+string(key)+`"]`)
+		} else {
+			keypathParts = append(keypathParts, KeypathSeparator+string(key))
+		}
+	}
+	return strings.Join(keypathParts, "")
 }
 
 func (p Patch) Copy() Patch {
+	var from tree.Keypath
+	if p.From != nil {
+		from = p.From.Copy()
+	}
 	return Patch{
+		Op:      p.Op,
 		Keypath: p.Keypath.Copy(),
 		Range:   p.Range.Copy(),
+		From:    from,
 		Val:     DeepCopyJSValue(p.Val), // @@TODO?
 	}
 }
 
+// Apply performs the patch's operation on state.
+func (p Patch) Apply(state tree.Node) error {
+	switch p.Op {
+	case PatchOpSet:
+		return state.Set(p.Keypath, p.Range, p.Val)
+
+	case PatchOpDelete:
+		return state.Delete(p.Keypath, p.Range)
+
+	case PatchOpMove, PatchOpCopy:
+		if p.Op == PatchOpMove && p.Keypath.StartsWith(p.From) {
+			if p.Keypath.Equals(p.From) && p.Range == nil {
+				return nil
+			}
+			return errors.Errorf("can't move %v into itself", p.From)
+		}
+		val, exists, err := state.Value(p.From, nil)
+		if err != nil {
+			return err
+		} else if !exists {
+			return errors.Wrapf(types.Err404, "nothing to %v at %v", p.Op, p.From)
+		}
+		if p.Op == PatchOpMove {
+			err = state.Delete(p.From, nil)
+			if err != nil {
+				return err
+			}
+		}
+		return state.Set(p.Keypath, p.Range, val)
+
+	case PatchOpIncrement:
+		delta, is := p.Val.(float64)
+		if !is {
+			return errors.Errorf("can't increment %v by a %T", p.Keypath, p.Val)
+		}
+		val, exists, err := state.Value(p.Keypath, nil)
+		if err != nil {
+			return err
+		}
+		var n float64
+		if exists {
+			switch v := val.(type) {
+			case float64:
+				n = v
+			case int64:
+				n = float64(v)
+			case uint64:
+				n = float64(v)
+			default:
+				return errors.Errorf("can't increment a %T at %v", val, p.Keypath)
+			}
+		}
+		return state.Set(p.Keypath, nil, n+delta)
+
+	case PatchOpTest:
+		val, _, err := state.Value(p.Keypath, p.Range)
+		if err != nil && errors.Cause(err) != types.Err404 {
+			return err
+		}
+		// Compare JSON encodings so that numbers of different types can be equal
+		have, err := json.Marshal(val)
+		if err != nil {
+			return err
+		}
+		want, err := json.Marshal(p.Val)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(have, want) {
+			return errors.Wrapf(ErrPatchTestFailed, "%v is %s, not %s", p.Keypath, have, want)
+		}
+		return nil
+
+	default:
+		return errors.Errorf("unknown patch op '%v'", p.Op)
+	}
+}
+
 func (p *Patch) UnmarshalJSON(bs []byte) error {
 	var err error
 	var s string
@@ -0,0 +1,95 @@
+package redwood_test
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"redwood.dev"
+	"redwood.dev/testutils"
+	"redwood.dev/tree"
+	"redwood.dev/types"
+)
+
+func TestPatch_Apply(t *testing.T) {
+	type M = map[string]interface{}
+	type S = []interface{}
+
+	tests := []struct {
+		patch    string
+		expected interface{}
+		wantErr  bool
+	}{
+		{`.a = null`, M{"a": nil, "b": M{"c": "x"}, "n": float64(1), "list": S{"p", "q"}}, false},
+		{`delete .a`, M{"b": M{"c": "x"}, "n": float64(1), "list": S{"p", "q"}}, false},
+		{`delete .list[0:1]`, M{"a": float64(1), "b": M{"c": "x"}, "n": float64(1), "list": S{"q"}}, false},
+		{`move .b.c to .d`, M{"a": float64(1), "b": M{}, "d": "x", "n": float64(1), "list": S{"p", "q"}}, false},
+		{`move .b to .b.c`, nil, true},
+		{`copy .list to .list[2:2]`, M{"a": float64(1), "b": M{"c": "x"}, "n": float64(1), "list": S{"p", "q", "p", "q"}}, false},
+		{`copy .b to .e`, M{"a": float64(1), "b": M{"c": "x"}, "e": M{"c": "x"}, "n": float64(1), "list": S{"p", "q"}}, false},
+		{`.n += 2.5`, M{"a": float64(1), "b": M{"c": "x"}, "n": float64(3.5), "list": S{"p", "q"}}, false},
+		{`.m += 2`, M{"a": float64(1), "b": M{"c": "x"}, "m": float64(2), "n": float64(1), "list": S{"p", "q"}}, false},
+		{`.b += 2`, nil, true},
+		{`test .b == {"c": "x"}`, M{"a": float64(1), "b": M{"c": "x"}, "n": float64(1), "list": S{"p", "q"}}, false},
+		{`test .b.c == "y"`, nil, true},
+		{`test .nope == null`, M{"a": float64(1), "b": M{"c": "x"}, "n": float64(1), "list": S{"p", "q"}}, false},
+	}
+
+	for _, test := range tests {
+		db := testutils.SetupDBTreeWithValue(t, nil, M{"a": float64(1), "b": M{"c": "x"}, "n": float64(1), "list": S{"p", "q"}})
+		state := db.State(true)
+
+		patch, err := redwood.ParsePatch([]byte(test.patch))
+		require.NoError(t, err, test.patch)
+
+		err = patch.Apply(state)
+		if test.wantErr {
+			require.Error(t, err, test.patch)
+		} else {
+			require.NoError(t, err, test.patch)
+
+			val, _, err := state.Value(nil, nil)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, val, test.patch)
+		}
+
+		state.Close()
+		db.DeleteDB()
+	}
+}
+
+func TestPatch_Apply_TestFailed(t *testing.T) {
+	state := tree.NewMemoryNode()
+	err := state.Set(tree.Keypath("a"), nil, "x")
+	require.NoError(t, err)
+
+	patch, err := redwood.ParsePatch([]byte(`test .a == "y"`))
+	require.NoError(t, err)
+	err = patch.Apply(state)
+	require.Equal(t, redwood.ErrPatchTestFailed, errors.Cause(err))
+}
+
+func TestTx_MarshalProto_PatchOps(t *testing.T) {
+	tx := redwood.Tx{
+		ID:        types.RandomID(),
+		StateURI:  "foo.bar/baz",
+		Timestamp: 1609459200,
+		Patches: []redwood.Patch{
+			{Keypath: tree.Keypath("a"), Val: "x"},
+			{Op: redwood.PatchOpDelete, Keypath: tree.Keypath("a"), Range: &tree.Range{Start: 0, End: 1}},
+			{Op: redwood.PatchOpMove, Keypath: tree.Keypath("b"), From: tree.Keypath("a/c")},
+			{Op: redwood.PatchOpIncrement, Keypath: tree.Keypath("n"), Val: float64(3)},
+		},
+	}
+
+	bs, err := tx.MarshalProto()
+	require.NoError(t, err)
+
+	var tx2 redwood.Tx
+	err = tx2.UnmarshalProto(bs)
+	require.NoError(t, err)
+	require.Equal(t, tx.Patches, tx2.Patches)
+	require.Equal(t, tx.Timestamp, tx2.Timestamp)
+	require.Equal(t, tx.Hash(), tx2.Hash())
+}
@@ -1,6 +1,10 @@
 package redwood
 
 import (
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
 	"github.com/dgraph-io/badger/v2"
 	"github.com/pkg/errors"
 
@@ -48,6 +52,26 @@ func makeTxKey(stateURI string, txID types.ID) []byte {
 	return append([]byte("tx:"+stateURI+":"), txID[:]...)
 }
 
+func makeMempoolKey(stateURI string, txID types.ID) []byte {
+	return append([]byte("mempool:"+stateURI+":"), txID[:]...)
+}
+
+func makePrunedKey(stateURI string, txID types.ID) []byte {
+	return append([]byte("pruned:"+stateURI+":"), txID[:]...)
+}
+
+func makeCheckpointKeyPrefix(stateURI string) []byte {
+	return []byte("checkpoint:" + stateURI + ":")
+}
+
+func makeCheckpointKey(stateURI string, checkpoint Checkpoint) []byte {
+	// checkpoint:<stateURI>:<created (big endian nanos)><txID>, so that they sort oldest first
+	created := make([]byte, 8)
+	binary.BigEndian.PutUint64(created, uint64(checkpoint.Created.UnixNano()))
+	key := append(makeCheckpointKeyPrefix(stateURI), created...)
+	return append(key, checkpoint.TxID[:]...)
+}
+
 func (p *badgerTxStore) AddTx(tx *Tx) (err error) {
 	defer utils.Annotate(&err, "badgerTxStore#AddTx")
 
@@ -64,11 +88,34 @@ func (p *badgerTxStore) AddTx(tx *Tx) (err error) {
 			return err
 		}
 
+		// Keep track of which txs are waiting in the mempool, and since when, so
+		// that the mempool can be restored after a restart
+		mempoolKey := makeMempoolKey(tx.StateURI, tx.ID)
+		if tx.Status == TxStatusInMempool {
+			_, err := txn.Get(mempoolKey)
+			if err == badger.ErrKeyNotFound {
+				received := make([]byte, 8)
+				binary.BigEndian.PutUint64(received, uint64(time.Now().UnixNano()))
+				err = txn.Set(mempoolKey, received)
+			}
+			if err != nil {
+				return err
+			}
+		} else {
+			err := txn.Delete(mempoolKey)
+			if err != nil {
+				return err
+			}
+		}
+
 		// Add the new tx to the `.Children` slice on each of its parents
 		if tx.Status == TxStatusValid {
 			for _, parentID := range tx.Parents {
 				item, err := txn.Get(makeTxKey(tx.StateURI, parentID))
-				if err != nil {
+				if err == badger.ErrKeyNotFound {
+					// History older than an imported snapshot is never fetched
+					continue
+				} else if err != nil {
 					return errors.Wrapf(err, "can't find parent %v of tx %v", parentID, tx.ID)
 				}
 				var parentTx Tx
@@ -109,26 +156,29 @@ func (p *badgerTxStore) AddTx(tx *Tx) (err error) {
 }
 
 func (p *badgerTxStore) RemoveTx(stateURI string, txID types.ID) error {
-	key := makeTxKey(stateURI, txID)
 	return p.db.Update(func(txn *badger.Txn) error {
-		return txn.Delete(key)
+		err := txn.Delete(makeMempoolKey(stateURI, txID))
+		if err != nil {
+			return err
+		}
+		return txn.Delete(makeTxKey(stateURI, txID))
 	})
 }
 
+// TxExists returns true if the tx is in the store, or if it was pruned from it.
 func (p *badgerTxStore) TxExists(stateURI string, txID types.ID) (bool, error) {
-	key := makeTxKey(stateURI, txID)
-
 	var exists bool
 	err := p.db.View(func(txn *badger.Txn) error {
-		_, err := txn.Get(key)
-		if err == badger.ErrKeyNotFound {
-			exists = false
+		for _, key := range [][]byte{makeTxKey(stateURI, txID), makePrunedKey(stateURI, txID)} {
+			_, err := txn.Get(key)
+			if err == badger.ErrKeyNotFound {
+				continue
+			} else if err != nil {
+				return errors.WithStack(err)
+			}
+			exists = true
 			return nil
-		} else if err != nil {
-			return errors.WithStack(err)
 		}
-
-		exists = true
 		return nil
 	})
 	return exists, err
@@ -158,59 +208,98 @@ func (p *badgerTxStore) FetchTx(stateURI string, txID types.ID) (*Tx, error) {
 	return &tx, err
 }
 
+// AllTxsForStateURI iterates over fromTxID and all of its descendants in
+// topological order (every tx comes after all of its parents).  If fromTxID
+// has been pruned, iteration starts at the oldest retained checkpoint's
+// frontier instead (see HistoryRoots).
 func (p *badgerTxStore) AllTxsForStateURI(stateURI string, fromTxID types.ID) TxIterator {
-	if fromTxID == (types.ID{}) {
-		fromTxID = GenesisTxID
-	}
-
 	txIter := &txIterator{
 		ch:       make(chan *Tx),
 		chCancel: make(chan struct{}),
 	}
 
+	roots, err := HistoryRoots(p, stateURI, fromTxID)
+	if err != nil {
+		txIter.err = err
+		close(txIter.ch)
+		return txIter
+	}
+
 	go func() {
 		defer close(txIter.ch)
 
-		stack := []types.ID{fromTxID}
-		sent := make(map[types.ID]struct{})
-
 		txIter.err = p.db.View(func(txn *badger.Txn) error {
-			for len(stack) > 0 {
-				txID := stack[0]
-				stack = stack[1:]
-
-				if _, wasSent := sent[txID]; wasSent {
-					continue
-				}
-
+			fetchTx := func(txID types.ID) (*Tx, error) {
 				item, err := txn.Get(makeTxKey(stateURI, txID))
 				if err != nil {
-					return err
+					return nil, err
 				}
-
 				var tx Tx
 				err = item.Value(func(val []byte) error {
 					return tx.UnmarshalProto(val)
 				})
+				if err != nil {
+					return nil, err
+				}
+				return &tx, nil
+			}
+
+			// First, find every descendant and count how many of its parents
+			// are also descendants
+			numParents := make(map[types.ID]int)
+			children := make(map[types.ID][]types.ID)
+			var queue []types.ID
+			for _, txID := range roots {
+				if _, seen := numParents[txID]; !seen {
+					numParents[txID] = 0
+					queue = append(queue, txID)
+				}
+			}
+			starts := append([]types.ID(nil), queue...)
+			for len(queue) > 0 {
+				txID := queue[0]
+				queue = queue[1:]
+
+				tx, err := fetchTx(txID)
 				if err != nil {
 					return err
 				}
+				children[txID] = tx.Children
+				for _, childID := range tx.Children {
+					if _, seen := numParents[childID]; !seen {
+						queue = append(queue, childID)
+					}
+					numParents[childID]++
+				}
+			}
 
-				// for _, parentID := range tx.Parents {
-				// 	if _, wasSent := sent[parentID]; !wasSent {
-				// 		stack = append(stack, tx.ID)
-				// 		continue OuterLoop
-				// 	}
-				// }
+			// Then send each tx once all of its parents have been sent
+			for _, txID := range starts {
+				if numParents[txID] == 0 {
+					queue = append(queue, txID)
+				}
+			}
+			for len(queue) > 0 {
+				txID := queue[0]
+				queue = queue[1:]
+
+				tx, err := fetchTx(txID)
+				if err != nil {
+					return err
+				}
 
 				select {
 				case <-txIter.chCancel:
 					return nil
-				case txIter.ch <- &tx:
+				case txIter.ch <- tx:
 				}
 
-				sent[txID] = struct{}{}
-				stack = append(stack, tx.Children...)
+				for _, childID := range children[txID] {
+					numParents[childID]--
+					if numParents[childID] == 0 {
+						queue = append(queue, childID)
+					}
+				}
 			}
 			return nil
 		})
@@ -267,3 +356,110 @@ func (s *badgerTxStore) Leaves(stateURI string) ([]types.ID, error) {
 	})
 	return leaves, err
 }
+
+func (s *badgerTxStore) MempoolTxs(stateURI string) ([]MempoolEntry, error) {
+	var entries []MempoolEntry
+	err := s.db.View(func(txn *badger.Txn) error {
+		iter := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer iter.Close()
+
+		prefix := []byte("mempool:" + stateURI + ":")
+
+		for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
+			txID := types.IDFromBytes(iter.Item().Key()[len(prefix):])
+
+			var received time.Time
+			err := iter.Item().Value(func(val []byte) error {
+				if len(val) != 8 {
+					return errors.Errorf("bad mempool entry for tx %v", txID.Pretty())
+				}
+				received = time.Unix(0, int64(binary.BigEndian.Uint64(val)))
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			item, err := txn.Get(makeTxKey(stateURI, txID))
+			if err == badger.ErrKeyNotFound {
+				continue
+			} else if err != nil {
+				return err
+			}
+
+			var tx Tx
+			err = item.Value(func(val []byte) error {
+				return tx.UnmarshalProto(val)
+			})
+			if err != nil {
+				return err
+			}
+			entries = append(entries, MempoolEntry{Tx: &tx, Received: received})
+		}
+		return nil
+	})
+	return entries, err
+}
+
+func (s *badgerTxStore) AddCheckpoint(stateURI string, checkpoint Checkpoint) error {
+	bs, err := json.Marshal(checkpoint)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(makeCheckpointKey(stateURI, checkpoint), bs)
+	})
+}
+
+func (s *badgerTxStore) RemoveCheckpoint(stateURI string, checkpoint Checkpoint) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(makeCheckpointKey(stateURI, checkpoint))
+	})
+}
+
+// Checkpoints returns the checkpoints recorded for the given state URI, oldest first.
+func (s *badgerTxStore) Checkpoints(stateURI string) ([]Checkpoint, error) {
+	var checkpoints []Checkpoint
+	err := s.db.View(func(txn *badger.Txn) error {
+		iter := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer iter.Close()
+
+		prefix := makeCheckpointKeyPrefix(stateURI)
+
+		for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
+			var checkpoint Checkpoint
+			err := iter.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &checkpoint)
+			})
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			checkpoints = append(checkpoints, checkpoint)
+		}
+		return nil
+	})
+	return checkpoints, err
+}
+
+// PruneTxs deletes the given txs, but remembers that they existed so that they
+// aren't accepted again if a peer resends them.
+func (s *badgerTxStore) PruneTxs(stateURI string, txIDs []types.ID) error {
+	batch := s.db.NewWriteBatch()
+	defer batch.Cancel()
+
+	for _, txID := range txIDs {
+		err := batch.Delete(makeTxKey(stateURI, txID))
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		err = batch.Delete(makeMempoolKey(stateURI, txID))
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		err = batch.Set(makePrunedKey(stateURI, txID), nil)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return batch.Flush()
+}
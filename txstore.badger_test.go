@@ -0,0 +1,75 @@
+package redwood_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"redwood.dev"
+	"redwood.dev/types"
+)
+
+func TestBadgerTxStore_AllTxsForStateURI_TopologicalOrder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "txstore-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	txStore := redwood.NewBadgerTxStore(dir)
+	err = txStore.Start()
+	require.NoError(t, err)
+	defer txStore.Close()
+
+	stateURI := "foo.com/bar"
+
+	// genesis -> a -> b -> c -> d
+	//         \-> e ---------/
+	var (
+		a = types.RandomID()
+		b = types.RandomID()
+		c = types.RandomID()
+		d = types.RandomID()
+		e = types.RandomID()
+	)
+	parents := map[types.ID][]types.ID{
+		redwood.GenesisTxID: nil,
+		a:                   {redwood.GenesisTxID},
+		e:                   {redwood.GenesisTxID},
+		b:                   {a},
+		c:                   {b},
+		d:                   {c, e},
+	}
+	for _, txID := range []types.ID{redwood.GenesisTxID, a, e, b, c, d} {
+		err := txStore.AddTx(&redwood.Tx{ID: txID, StateURI: stateURI, Parents: parents[txID], Status: redwood.TxStatusValid})
+		require.NoError(t, err)
+	}
+
+	iter := txStore.AllTxsForStateURI(stateURI, types.ID{})
+	defer iter.Cancel()
+
+	sent := make(map[types.ID]bool)
+	for {
+		tx := iter.Next()
+		if tx == nil {
+			break
+		}
+		for _, parentID := range tx.Parents {
+			require.True(t, sent[parentID], "tx %v was sent before its parent %v", tx.ID.Pretty(), parentID.Pretty())
+		}
+		sent[tx.ID] = true
+	}
+	require.NoError(t, iter.Error())
+	require.Len(t, sent, 6)
+
+	// Starting partway through only yields descendants
+	iter = txStore.AllTxsForStateURI(stateURI, b)
+	defer iter.Cancel()
+
+	var txIDs []types.ID
+	for tx := iter.Next(); tx != nil; tx = iter.Next() {
+		txIDs = append(txIDs, tx.ID)
+	}
+	require.NoError(t, iter.Error())
+	require.Equal(t, []types.ID{b, c, d}, txIDs)
+}
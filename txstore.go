@@ -1,6 +1,8 @@
 package redwood
 
 import (
+	"github.com/pkg/errors"
+
 	"redwood.dev/types"
 )
 
@@ -17,6 +19,46 @@ type TxStore interface {
 	MarkLeaf(stateURI string, txID types.ID) error
 	UnmarkLeaf(stateURI string, txID types.ID) error
 	Leaves(stateURI string) ([]types.ID, error)
+	MempoolTxs(stateURI string) ([]MempoolEntry, error)
+	AddCheckpoint(stateURI string, checkpoint Checkpoint) error
+	RemoveCheckpoint(stateURI string, checkpoint Checkpoint) error
+	Checkpoints(stateURI string) ([]Checkpoint, error)
+	PruneTxs(stateURI string, txIDs []types.ID) error
+}
+
+// HistoryRoots returns the txs that an iteration starting at fromTxID should
+// begin with.  Usually that's just fromTxID (or the genesis tx, if it's zero),
+// but once history has been pruned, or if the state URI was imported from a
+// snapshot, the earliest txs the store still has are the frontier of its
+// oldest checkpoint.
+func HistoryRoots(txStore TxStore, stateURI string, fromTxID types.ID) ([]types.ID, error) {
+	if fromTxID == (types.ID{}) {
+		fromTxID = GenesisTxID
+	}
+
+	_, err := txStore.FetchTx(stateURI, fromTxID)
+	if err == nil {
+		return []types.ID{fromTxID}, nil
+	} else if errors.Cause(err) != types.Err404 {
+		return nil, err
+	}
+
+	if fromTxID != GenesisTxID {
+		pruned, err := txStore.TxExists(stateURI, fromTxID)
+		if err != nil {
+			return nil, err
+		} else if !pruned {
+			return nil, errors.Wrapf(types.Err404, "tx %v", fromTxID.Pretty())
+		}
+	}
+
+	checkpoints, err := txStore.Checkpoints(stateURI)
+	if err != nil {
+		return nil, err
+	} else if len(checkpoints) == 0 {
+		return nil, errors.Wrapf(types.Err404, "tx %v", fromTxID.Pretty())
+	}
+	return checkpoints[0].Frontier, nil
 }
 
 type TxIterator interface {
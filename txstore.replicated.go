@@ -0,0 +1,551 @@
+package redwood
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"redwood.dev/ctx"
+	"redwood.dev/types"
+)
+
+// ReplicatedTxStore is a TxStore that writes to a primary store and
+// asynchronously mirrors those writes to any number of replicas.
+type ReplicatedTxStore interface {
+	TxStore
+	ReplicaStats() []TxStoreReplicaStats
+	RepairReplicas()
+}
+
+// TxStoreReplica is a secondary TxStore, named so that its stats can be told
+// apart from the others'.
+type TxStoreReplica struct {
+	Name string
+	TxStore
+}
+
+// ReplicatedTxStoreOpts controls how far a replica may fall behind before its
+// writes are dropped, and how often replicas are compared against the primary
+// to repair anything that they missed.
+type ReplicatedTxStoreOpts struct {
+	MaxPendingWrites int
+	RepairInterval   time.Duration
+}
+
+var DefaultReplicatedTxStoreOpts = ReplicatedTxStoreOpts{
+	MaxPendingWrites: 10000,
+	RepairInterval:   10 * time.Minute,
+}
+
+type TxStoreReplicaStats struct {
+	Name string
+	// The number of writes waiting to be applied to the replica
+	PendingWrites int
+	// How long the oldest pending write has been waiting
+	Lag           time.Duration
+	FailedWrites  uint64
+	DroppedWrites uint64
+	LastError     string
+	LastWrite     time.Time
+	LastRepair    time.Time
+	// The number of txs, leaves and checkpoints copied to the replica by repairs
+	NumRepaired uint64
+}
+
+type replicatedTxStore struct {
+	ctx.Logger
+	chStop   chan struct{}
+	wgDone   sync.WaitGroup
+	primary  TxStore
+	replicas []*txStoreReplica
+	opts     ReplicatedTxStoreOpts
+}
+
+type txStoreReplica struct {
+	TxStoreReplica
+	chNotify chan struct{}
+	chRepair chan struct{}
+
+	mu    sync.Mutex
+	queue []replicaWrite
+	stats TxStoreReplicaStats
+}
+
+type replicaWrite struct {
+	fn     func(replica TxStore) error
+	queued time.Time
+}
+
+var _ ReplicatedTxStore = (*replicatedTxStore)(nil)
+
+func NewReplicatedTxStore(primary TxStore, replicas []TxStoreReplica, opts ReplicatedTxStoreOpts) *replicatedTxStore {
+	s := &replicatedTxStore{
+		Logger:  ctx.NewLogger("txstore"),
+		chStop:  make(chan struct{}),
+		primary: primary,
+		opts:    opts,
+	}
+	for _, replica := range replicas {
+		s.replicas = append(s.replicas, &txStoreReplica{
+			TxStoreReplica: replica,
+			chNotify:       make(chan struct{}, 1),
+			chRepair:       make(chan struct{}, 1),
+			stats:          TxStoreReplicaStats{Name: replica.Name},
+		})
+	}
+	return s
+}
+
+func (s *replicatedTxStore) Start() error {
+	err := s.primary.Start()
+	if err != nil {
+		return err
+	}
+	for i, replica := range s.replicas {
+		err := replica.Start()
+		if err != nil {
+			for _, started := range s.replicas[:i] {
+				started.Close()
+			}
+			s.primary.Close()
+			return errors.Wrapf(err, "while starting tx store replica %v", replica.Name)
+		}
+	}
+
+	for _, replica := range s.replicas {
+		s.wgDone.Add(1)
+		go s.replicateLoop(replica)
+	}
+
+	// Catch up on anything that was missed while we were offline
+	s.RepairReplicas()
+	return nil
+}
+
+// Close stops replication and closes every store.  Writes that haven't been
+// applied to a replica yet are discarded, and are restored by the first
+// repair after the next Start.
+func (s *replicatedTxStore) Close() {
+	close(s.chStop)
+	s.wgDone.Wait()
+
+	for _, replica := range s.replicas {
+		replica.Close()
+	}
+	s.primary.Close()
+}
+
+func (s *replicatedTxStore) replicateLoop(replica *txStoreReplica) {
+	defer s.wgDone.Done()
+
+	var chRepairTimer <-chan time.Time
+	if s.opts.RepairInterval > 0 {
+		ticker := time.NewTicker(s.opts.RepairInterval)
+		defer ticker.Stop()
+		chRepairTimer = ticker.C
+	}
+
+	for {
+		select {
+		case <-s.chStop:
+			return
+		case <-replica.chNotify:
+			s.applyPendingWrites(replica)
+		case <-replica.chRepair:
+			s.repair(replica)
+		case <-chRepairTimer:
+			s.repair(replica)
+		}
+	}
+}
+
+func (s *replicatedTxStore) replicate(fn func(replica TxStore) error) {
+	now := time.Now()
+	for _, replica := range s.replicas {
+		replica.mu.Lock()
+		if s.opts.MaxPendingWrites > 0 && len(replica.queue) >= s.opts.MaxPendingWrites {
+			// The replica has fallen too far behind.  Drop the write and let
+			// the next repair copy over whatever it's missing.
+			replica.stats.DroppedWrites++
+			replica.mu.Unlock()
+			continue
+		}
+		replica.queue = append(replica.queue, replicaWrite{fn: fn, queued: now})
+		replica.mu.Unlock()
+
+		select {
+		case replica.chNotify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *replicatedTxStore) applyPendingWrites(replica *txStoreReplica) {
+	for {
+		select {
+		case <-s.chStop:
+			return
+		default:
+		}
+
+		// Writes stay in the queue until they've been applied so that they
+		// count towards the replica's lag
+		replica.mu.Lock()
+		if len(replica.queue) == 0 {
+			replica.mu.Unlock()
+			return
+		}
+		write := replica.queue[0]
+		replica.mu.Unlock()
+
+		err := write.fn(replica.TxStore)
+
+		replica.mu.Lock()
+		replica.queue = replica.queue[1:]
+		if err != nil {
+			replica.stats.FailedWrites++
+			replica.stats.LastError = err.Error()
+		} else {
+			replica.stats.LastWrite = time.Now()
+		}
+		replica.mu.Unlock()
+
+		if err != nil {
+			s.Errorf("error writing to tx store replica %v: %v", replica.Name, err)
+		}
+	}
+}
+
+// RepairReplicas asks every replica to compare itself against the primary and
+// copy over any txs, leaves and checkpoints that it's missing.  Repairs run in
+// the background.
+func (s *replicatedTxStore) RepairReplicas() {
+	for _, replica := range s.replicas {
+		select {
+		case replica.chRepair <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *replicatedTxStore) repair(replica *txStoreReplica) {
+	numRepaired, err := s.repairReplica(replica.TxStore)
+
+	replica.mu.Lock()
+	replica.stats.NumRepaired += numRepaired
+	if err != nil {
+		replica.stats.LastError = err.Error()
+	} else {
+		replica.stats.LastRepair = time.Now()
+	}
+	replica.mu.Unlock()
+
+	if err != nil {
+		s.Errorf("error repairing tx store replica %v: %v", replica.Name, err)
+	} else if numRepaired > 0 {
+		s.Infof(0, "repaired %v items in tx store replica %v", numRepaired, replica.Name)
+	}
+}
+
+func (s *replicatedTxStore) repairReplica(replica TxStore) (numRepaired uint64, _ error) {
+	stateURIs, err := s.primary.KnownStateURIs()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, stateURI := range stateURIs {
+		n, err := s.repairTxs(replica, stateURI)
+		numRepaired += n
+		if err != nil {
+			return numRepaired, errors.Wrapf(err, "while repairing txs for %v", stateURI)
+		}
+
+		n, err = s.repairLeaves(replica, stateURI)
+		numRepaired += n
+		if err != nil {
+			return numRepaired, errors.Wrapf(err, "while repairing leaves for %v", stateURI)
+		}
+
+		n, err = s.repairCheckpoints(replica, stateURI)
+		numRepaired += n
+		if err != nil {
+			return numRepaired, errors.Wrapf(err, "while repairing checkpoints for %v", stateURI)
+		}
+	}
+	return numRepaired, nil
+}
+
+func (s *replicatedTxStore) repairTxs(replica TxStore, stateURI string) (numRepaired uint64, _ error) {
+	addIfMissing := func(tx *Tx) error {
+		exists, err := replica.TxExists(stateURI, tx.ID)
+		if err != nil {
+			return err
+		} else if exists {
+			return nil
+		}
+		err = replica.AddTx(tx)
+		if err != nil {
+			return err
+		}
+		numRepaired++
+		return nil
+	}
+
+	// Txs are visited in topological order so that parents are always
+	// added before their children
+	iter := s.primary.AllTxsForStateURI(stateURI, GenesisTxID)
+	defer iter.Cancel()
+
+	for tx := iter.Next(); tx != nil; tx = iter.Next() {
+		select {
+		case <-s.chStop:
+			return numRepaired, nil
+		default:
+		}
+
+		err := addIfMissing(tx)
+		if err != nil {
+			return numRepaired, err
+		}
+	}
+	if iter.Error() != nil {
+		return numRepaired, iter.Error()
+	}
+
+	entries, err := s.primary.MempoolTxs(stateURI)
+	if err != nil {
+		return numRepaired, err
+	}
+	for _, entry := range entries {
+		err := addIfMissing(entry.Tx)
+		if err != nil {
+			return numRepaired, err
+		}
+	}
+	return numRepaired, nil
+}
+
+func (s *replicatedTxStore) repairLeaves(replica TxStore, stateURI string) (numRepaired uint64, _ error) {
+	primaryLeaves, err := s.primary.Leaves(stateURI)
+	if err != nil {
+		return 0, err
+	}
+	replicaLeaves, err := replica.Leaves(stateURI)
+	if err != nil {
+		return 0, err
+	}
+
+	isPrimaryLeaf := make(map[types.ID]bool, len(primaryLeaves))
+	for _, txID := range primaryLeaves {
+		isPrimaryLeaf[txID] = true
+	}
+	isReplicaLeaf := make(map[types.ID]bool, len(replicaLeaves))
+	for _, txID := range replicaLeaves {
+		isReplicaLeaf[txID] = true
+	}
+
+	for _, txID := range primaryLeaves {
+		if isReplicaLeaf[txID] {
+			continue
+		}
+		err := replica.MarkLeaf(stateURI, txID)
+		if err != nil {
+			return numRepaired, err
+		}
+		numRepaired++
+	}
+	for _, txID := range replicaLeaves {
+		if isPrimaryLeaf[txID] {
+			continue
+		}
+		err := replica.UnmarkLeaf(stateURI, txID)
+		if err != nil {
+			return numRepaired, err
+		}
+		numRepaired++
+	}
+	return numRepaired, nil
+}
+
+func (s *replicatedTxStore) repairCheckpoints(replica TxStore, stateURI string) (numRepaired uint64, _ error) {
+	primaryCheckpoints, err := s.primary.Checkpoints(stateURI)
+	if err != nil {
+		return 0, err
+	}
+	replicaCheckpoints, err := replica.Checkpoints(stateURI)
+	if err != nil {
+		return 0, err
+	}
+
+	inPrimary := make(map[types.ID]bool, len(primaryCheckpoints))
+	for _, checkpoint := range primaryCheckpoints {
+		inPrimary[checkpoint.TxID] = true
+	}
+	inReplica := make(map[types.ID]bool, len(replicaCheckpoints))
+	for _, checkpoint := range replicaCheckpoints {
+		inReplica[checkpoint.TxID] = true
+	}
+
+	for _, checkpoint := range primaryCheckpoints {
+		if inReplica[checkpoint.TxID] {
+			continue
+		}
+		err := replica.AddCheckpoint(stateURI, checkpoint)
+		if err != nil {
+			return numRepaired, err
+		}
+		numRepaired++
+	}
+	for _, checkpoint := range replicaCheckpoints {
+		if inPrimary[checkpoint.TxID] {
+			continue
+		}
+		err := replica.RemoveCheckpoint(stateURI, checkpoint)
+		if err != nil {
+			return numRepaired, err
+		}
+		numRepaired++
+	}
+	return numRepaired, nil
+}
+
+// ReplicaStats reports how far behind each replica is.
+func (s *replicatedTxStore) ReplicaStats() []TxStoreReplicaStats {
+	now := time.Now()
+	stats := make([]TxStoreReplicaStats, len(s.replicas))
+	for i, replica := range s.replicas {
+		replica.mu.Lock()
+		stats[i] = replica.stats
+		stats[i].PendingWrites = len(replica.queue)
+		if len(replica.queue) > 0 {
+			stats[i].Lag = now.Sub(replica.queue[0].queued)
+		}
+		replica.mu.Unlock()
+	}
+	return stats
+}
+
+func (s *replicatedTxStore) AddTx(tx *Tx) error {
+	err := s.primary.AddTx(tx)
+	if err != nil {
+		return err
+	}
+	// The caller may go on to modify the tx before the replicas get to it
+	tx = tx.Copy()
+	s.replicate(func(replica TxStore) error { return replica.AddTx(tx) })
+	return nil
+}
+
+func (s *replicatedTxStore) RemoveTx(stateURI string, txID types.ID) error {
+	err := s.primary.RemoveTx(stateURI, txID)
+	if err != nil {
+		return err
+	}
+	s.replicate(func(replica TxStore) error { return replica.RemoveTx(stateURI, txID) })
+	return nil
+}
+
+func (s *replicatedTxStore) MarkLeaf(stateURI string, txID types.ID) error {
+	err := s.primary.MarkLeaf(stateURI, txID)
+	if err != nil {
+		return err
+	}
+	s.replicate(func(replica TxStore) error { return replica.MarkLeaf(stateURI, txID) })
+	return nil
+}
+
+func (s *replicatedTxStore) UnmarkLeaf(stateURI string, txID types.ID) error {
+	err := s.primary.UnmarkLeaf(stateURI, txID)
+	if err != nil {
+		return err
+	}
+	s.replicate(func(replica TxStore) error { return replica.UnmarkLeaf(stateURI, txID) })
+	return nil
+}
+
+func (s *replicatedTxStore) AddCheckpoint(stateURI string, checkpoint Checkpoint) error {
+	err := s.primary.AddCheckpoint(stateURI, checkpoint)
+	if err != nil {
+		return err
+	}
+	s.replicate(func(replica TxStore) error { return replica.AddCheckpoint(stateURI, checkpoint) })
+	return nil
+}
+
+func (s *replicatedTxStore) RemoveCheckpoint(stateURI string, checkpoint Checkpoint) error {
+	err := s.primary.RemoveCheckpoint(stateURI, checkpoint)
+	if err != nil {
+		return err
+	}
+	s.replicate(func(replica TxStore) error { return replica.RemoveCheckpoint(stateURI, checkpoint) })
+	return nil
+}
+
+func (s *replicatedTxStore) PruneTxs(stateURI string, txIDs []types.ID) error {
+	err := s.primary.PruneTxs(stateURI, txIDs)
+	if err != nil {
+		return err
+	}
+	s.replicate(func(replica TxStore) error { return replica.PruneTxs(stateURI, txIDs) })
+	return nil
+}
+
+// TxExists and FetchTx fall back to the replicas when the primary doesn't have
+// a tx, in case it was lost from the primary but made it to a replica.
+func (s *replicatedTxStore) TxExists(stateURI string, txID types.ID) (bool, error) {
+	exists, err := s.primary.TxExists(stateURI, txID)
+	if err != nil {
+		return false, err
+	} else if exists {
+		return true, nil
+	}
+	for _, replica := range s.replicas {
+		exists, err := replica.TxExists(stateURI, txID)
+		if err != nil {
+			s.Errorf("error reading from tx store replica %v: %v", replica.Name, err)
+			continue
+		} else if exists {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *replicatedTxStore) FetchTx(stateURI string, txID types.ID) (*Tx, error) {
+	tx, err := s.primary.FetchTx(stateURI, txID)
+	if errors.Cause(err) != types.Err404 {
+		return tx, err
+	}
+	for _, replica := range s.replicas {
+		tx, err := replica.FetchTx(stateURI, txID)
+		if errors.Cause(err) == types.Err404 {
+			continue
+		} else if err != nil {
+			s.Errorf("error reading from tx store replica %v: %v", replica.Name, err)
+			continue
+		}
+		return tx, nil
+	}
+	return nil, err
+}
+
+func (s *replicatedTxStore) AllTxsForStateURI(stateURI string, fromTxID types.ID) TxIterator {
+	return s.primary.AllTxsForStateURI(stateURI, fromTxID)
+}
+
+func (s *replicatedTxStore) KnownStateURIs() ([]string, error) {
+	return s.primary.KnownStateURIs()
+}
+
+func (s *replicatedTxStore) Leaves(stateURI string) ([]types.ID, error) {
+	return s.primary.Leaves(stateURI)
+}
+
+func (s *replicatedTxStore) MempoolTxs(stateURI string) ([]MempoolEntry, error) {
+	return s.primary.MempoolTxs(stateURI)
+}
+
+func (s *replicatedTxStore) Checkpoints(stateURI string) ([]Checkpoint, error) {
+	return s.primary.Checkpoints(stateURI)
+}
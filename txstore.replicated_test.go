@@ -0,0 +1,203 @@
+package redwood_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"redwood.dev"
+	"redwood.dev/types"
+)
+
+func setupBadgerTxStore(t *testing.T) (redwood.TxStore, string, func()) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "txstore-test-")
+	require.NoError(t, err)
+	return redwood.NewBadgerTxStore(dir), dir, func() { os.RemoveAll(dir) }
+}
+
+type blockingTxStore struct {
+	redwood.TxStore
+	chUnblock chan struct{}
+}
+
+func (s blockingTxStore) MarkLeaf(stateURI string, txID types.ID) error {
+	<-s.chUnblock
+	return s.TxStore.MarkLeaf(stateURI, txID)
+}
+
+func TestReplicatedTxStore_Replication(t *testing.T) {
+	primary, _, cleanup := setupBadgerTxStore(t)
+	defer cleanup()
+	replica1, _, cleanup := setupBadgerTxStore(t)
+	defer cleanup()
+	replica2, _, cleanup := setupBadgerTxStore(t)
+	defer cleanup()
+
+	txStore := redwood.NewReplicatedTxStore(primary, []redwood.TxStoreReplica{
+		{Name: "replica1", TxStore: replica1},
+		{Name: "replica2", TxStore: replica2},
+	}, redwood.DefaultReplicatedTxStoreOpts)
+	err := txStore.Start()
+	require.NoError(t, err)
+	defer txStore.Close()
+
+	stateURI := "foo.com/bar"
+	tx := &redwood.Tx{ID: redwood.GenesisTxID, StateURI: stateURI, Status: redwood.TxStatusValid}
+	checkpoint := redwood.Checkpoint{TxID: tx.ID, Frontier: []types.ID{tx.ID}, Created: time.Now()}
+
+	err = txStore.AddTx(tx)
+	require.NoError(t, err)
+	err = txStore.MarkLeaf(stateURI, tx.ID)
+	require.NoError(t, err)
+	err = txStore.AddCheckpoint(stateURI, checkpoint)
+	require.NoError(t, err)
+
+	for _, replica := range []redwood.TxStore{replica1, replica2} {
+		require.Eventually(t, func() bool {
+			checkpoints, err := replica.Checkpoints(stateURI)
+			require.NoError(t, err)
+			return len(checkpoints) == 1
+		}, 5*time.Second, 10*time.Millisecond)
+
+		exists, err := replica.TxExists(stateURI, tx.ID)
+		require.NoError(t, err)
+		require.True(t, exists)
+
+		leaves, err := replica.Leaves(stateURI)
+		require.NoError(t, err)
+		require.Equal(t, []types.ID{tx.ID}, leaves)
+	}
+
+	stats := txStore.ReplicaStats()
+	require.Len(t, stats, 2)
+	for i, name := range []string{"replica1", "replica2"} {
+		require.Equal(t, name, stats[i].Name)
+		require.Equal(t, 0, stats[i].PendingWrites)
+		require.Equal(t, time.Duration(0), stats[i].Lag)
+		require.Equal(t, uint64(0), stats[i].FailedWrites)
+		require.False(t, stats[i].LastWrite.IsZero())
+	}
+}
+
+func TestReplicatedTxStore_ReadsFallBackToReplicas(t *testing.T) {
+	primary, _, cleanup := setupBadgerTxStore(t)
+	defer cleanup()
+	replica, _, cleanup := setupBadgerTxStore(t)
+	defer cleanup()
+
+	opts := redwood.DefaultReplicatedTxStoreOpts
+	opts.RepairInterval = 0
+
+	txStore := redwood.NewReplicatedTxStore(primary, []redwood.TxStoreReplica{{Name: "replica", TxStore: replica}}, opts)
+	err := txStore.Start()
+	require.NoError(t, err)
+	defer txStore.Close()
+
+	stateURI := "foo.com/bar"
+	tx := &redwood.Tx{ID: types.RandomID(), StateURI: stateURI, Status: redwood.TxStatusValid}
+
+	// Write only to the replica
+	err = replica.AddTx(tx)
+	require.NoError(t, err)
+
+	exists, err := primary.TxExists(stateURI, tx.ID)
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	exists, err = txStore.TxExists(stateURI, tx.ID)
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	fetched, err := txStore.FetchTx(stateURI, tx.ID)
+	require.NoError(t, err)
+	require.Equal(t, tx.ID, fetched.ID)
+
+	_, err = txStore.FetchTx(stateURI, types.RandomID())
+	require.Equal(t, types.Err404, errors.Cause(err))
+}
+
+func TestReplicatedTxStore_Repair(t *testing.T) {
+	primary, primaryDir, cleanup := setupBadgerTxStore(t)
+	defer cleanup()
+	replica, _, cleanup := setupBadgerTxStore(t)
+	defer cleanup()
+
+	stateURI := "foo.com/bar"
+	genesis := &redwood.Tx{ID: redwood.GenesisTxID, StateURI: stateURI, Status: redwood.TxStatusValid}
+	tx1 := &redwood.Tx{ID: types.RandomID(), StateURI: stateURI, Parents: []types.ID{genesis.ID}, Status: redwood.TxStatusValid}
+	checkpoint := redwood.Checkpoint{TxID: tx1.ID, Frontier: []types.ID{tx1.ID}, Created: time.Now()}
+
+	// The primary has txs that the replica never saw, and the replica has a
+	// leaf that the primary no longer does
+	err := primary.Start()
+	require.NoError(t, err)
+	for _, tx := range []*redwood.Tx{genesis, tx1} {
+		err := primary.AddTx(tx)
+		require.NoError(t, err)
+	}
+	err = primary.MarkLeaf(stateURI, tx1.ID)
+	require.NoError(t, err)
+	err = primary.AddCheckpoint(stateURI, checkpoint)
+	require.NoError(t, err)
+	primary.Close()
+
+	err = replica.Start()
+	require.NoError(t, err)
+	err = replica.MarkLeaf(stateURI, genesis.ID)
+	require.NoError(t, err)
+	replica.Close()
+
+	primary = redwood.NewBadgerTxStore(primaryDir)
+	replica = blockingTxStore{replica, make(chan struct{})}
+
+	opts := redwood.DefaultReplicatedTxStoreOpts
+	opts.RepairInterval = 0
+	opts.MaxPendingWrites = 2
+
+	txStore := redwood.NewReplicatedTxStore(primary, []redwood.TxStoreReplica{{Name: "replica", TxStore: replica}}, opts)
+	err = txStore.Start()
+	require.NoError(t, err)
+	defer txStore.Close()
+
+	// While the replica is stuck, writes pile up and then start being dropped
+	for i := 0; i < 4; i++ {
+		err := txStore.MarkLeaf(stateURI, tx1.ID)
+		require.NoError(t, err)
+	}
+	stats := txStore.ReplicaStats()
+	require.Equal(t, 2, stats[0].PendingWrites)
+	require.Equal(t, uint64(2), stats[0].DroppedWrites)
+	require.True(t, stats[0].Lag > 0)
+
+	close(replica.(blockingTxStore).chUnblock)
+	txStore.RepairReplicas()
+
+	require.Eventually(t, func() bool {
+		leaves, err := replica.Leaves(stateURI)
+		require.NoError(t, err)
+		return len(leaves) == 1 && leaves[0] == tx1.ID
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.Eventually(t, func() bool {
+		checkpoints, err := replica.Checkpoints(stateURI)
+		require.NoError(t, err)
+		return len(checkpoints) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	for _, tx := range []*redwood.Tx{genesis, tx1} {
+		exists, err := replica.TxExists(stateURI, tx.ID)
+		require.NoError(t, err)
+		require.True(t, exists)
+	}
+
+	require.Eventually(t, func() bool {
+		stats := txStore.ReplicaStats()
+		return stats[0].PendingWrites == 0 && !stats[0].LastRepair.IsZero() && stats[0].NumRepaired > 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
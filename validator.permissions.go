@@ -3,19 +3,107 @@ package redwood
 import (
 	"bytes"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
+	"redwood.dev/crypto"
 	"redwood.dev/nelson"
 	"redwood.dev/tree"
 	"redwood.dev/types"
 )
 
+// permissionsValidator controls who may read and write the subtree it governs.
+// Rules map keypath regexes (in patch syntax, i.e. `^\.posts(\..*)?$`) to the
+// operations they allow.  Rules can be attached directly to a subject, or
+// bundled into named roles which are granted to subjects.  A subject is an
+// address, "*" (anyone), or "group:<name>".
+//
+// Config:
+//
+//	{
+//	    "*": { "^\\.public(\\..*)?$": { "read": true } },
+//	    "96216849c49358b10257cb55b28ea603c874b05e": { "^.*$": { "read": true, "write": true } },
+//	    "roles": {
+//	        "editor": { "^\\.posts(\\..*)?$": { "read": true, "write": true } }
+//	    },
+//	    "grants": {
+//	        "group:staff": ["editor"],
+//	        "f3e1b2...": [{ "role": "editor", "notBefore": 1609459200, "expires": 1612137600 }]
+//	    }
+//	}
+//
+// Group membership lives in the state tree at `Groups/<name>/<address> = true`
+// (relative to the validator's node), so it can be managed with ordinary txs.
+//
+// Roles can be delegated with signed capability tokens stored at
+// `Capabilities/<id>`.  Anyone may write a token whose signature is valid and
+// whose issuer currently holds the delegated role to an unused id, and only the
+// token's issuer or audience may replace or delete it.  A token stops conferring its role as soon as it
+// expires or its issuer loses the role.  Tokens name the state URI and keypath
+// of the validator they're meant for, and aren't valid anywhere else.
+//
+// So that every peer reaches the same verdict on a tx, no matter when it's
+// validated, "notBefore" and "expires" are checked against the tx's signed
+// timestamp rather than the clock.  The controller won't let that timestamp
+// fall behind the newest tx it has already applied, so a tx can't be backdated
+// into a grant's window after the grant expires.  A tx without a timestamp
+// can't use a time-limited grant or token.  Reads are checked against the
+// clock.
+//
+// Reads are only restricted once a config mentions "read" at least once, so that
+// configs written before read rules were enforced keep working.
 type permissionsValidator struct {
-	permissions map[string]interface{}
+	rules       map[string]permissionRules // map[subject]...
+	roles       map[string]permissionRules // map[role]...
+	grants      map[string][]permissionGrant
+	enforceRead bool
+	now         func() time.Time
+	stateURI    string
+	keypath     tree.Keypath
 }
 
+type permissionRules map[string]permissionRule // map[pattern]...
+
+type permissionRule struct {
+	Read  bool
+	Write bool
+}
+
+type permissionOp int
+
+const (
+	permissionOpRead permissionOp = iota
+	permissionOpWrite
+)
+
+type permissionGrant struct {
+	Role      string
+	NotBefore int64
+	Expires   int64
+}
+
+// CapabilityToken delegates a role held by its issuer to its audience.
+type CapabilityToken struct {
+	Issuer    types.Address   `json:"issuer"`
+	Audience  types.Address   `json:"audience"`
+	Role      string          `json:"role"`
+	StateURI  string          `json:"stateURI"`
+	Keypath   string          `json:"keypath"` // In tree.Keypath form, i.e. "a/b"
+	NotBefore int64           `json:"notBefore,omitempty"`
+	Expires   int64           `json:"expires,omitempty"`
+	Sig       types.Signature `json:"sig"`
+}
+
+var (
+	PermissionsGroupsKeypath       = tree.Keypath("Groups")
+	PermissionsCapabilitiesKeypath = tree.Keypath("Capabilities")
+)
+
+const maxCapabilityDelegationDepth = 4
+
 func NewPermissionsValidator(config tree.Node) (Validator, error) {
 	cfg, exists, err := nelson.GetValueRecursive(config, nil, nil)
 	if err != nil {
@@ -28,52 +116,442 @@ func NewPermissionsValidator(config tree.Node) (Validator, error) {
 	if !isMap {
 		return nil, errors.New("permissions validator needs a map of permissions as its config")
 	}
-	lowercasePerms := make(map[string]interface{}, len(asMap))
-	for address, perms := range asMap {
-		lowercasePerms[strings.ToLower(address)] = perms
+
+	v := &permissionsValidator{
+		rules:  make(map[string]permissionRules),
+		roles:  make(map[string]permissionRules),
+		grants: make(map[string][]permissionGrant),
+		now:    time.Now,
 	}
-	return &permissionsValidator{permissions: lowercasePerms}, nil
-}
 
-var senderRegexp = regexp.MustCompile(`\$\(sender\)`)
+	for key, val := range asMap {
+		switch key {
+		case "roles":
+			roles, isMap := val.(map[string]interface{})
+			if !isMap {
+				return nil, errors.New("permissions validator: 'roles' must be a map")
+			}
+			for role, rulesVal := range roles {
+				rules, err := v.parseRules(rulesVal)
+				if err != nil {
+					return nil, errors.Wrapf(err, "permissions validator: role '%v'", role)
+				}
+				v.roles[role] = rules
+			}
 
-func (v *permissionsValidator) ValidateTx(state tree.Node, tx *Tx) error {
-	perms, exists := v.permissions[strings.ToLower(tx.From.Hex())]
-	if !exists {
-		perms, exists = v.permissions["*"]
-		if !exists {
-			return errors.WithStack(errors.Wrapf(types.Err403, "permissions key for user '%v' does not exist", tx.From.Hex()))
+		case "grants":
+			grants, isMap := val.(map[string]interface{})
+			if !isMap {
+				return nil, errors.New("permissions validator: 'grants' must be a map")
+			}
+			for subject, grantsVal := range grants {
+				parsed, err := parsePermissionGrants(grantsVal)
+				if err != nil {
+					return nil, errors.Wrapf(err, "permissions validator: grants for '%v'", subject)
+				}
+				v.grants[normalizePermissionSubject(subject)] = parsed
+			}
+
+		default:
+			rules, err := v.parseRules(val)
+			if err != nil {
+				return nil, errors.Wrapf(err, "permissions validator: subject '%v'", key)
+			}
+			v.rules[normalizePermissionSubject(key)] = rules
 		}
 	}
-	permsMap, isMap := perms.(map[string]interface{})
+
+	for subject, grants := range v.grants {
+		for _, grant := range grants {
+			if _, exists := v.roles[grant.Role]; !exists {
+				return nil, errors.Errorf("permissions validator: '%v' is granted unknown role '%v'", subject, grant.Role)
+			}
+		}
+	}
+	return v, nil
+}
+
+func (v *permissionsValidator) parseRules(val interface{}) (permissionRules, error) {
+	asMap, isMap := val.(map[string]interface{})
 	if !isMap {
-		return errors.WithStack(errors.Wrapf(types.Err403, "permissions key for user '%v' does not contain a map", tx.From.Hex()))
+		return nil, errors.New("rules must be a map of keypath patterns")
 	}
+	rules := make(permissionRules, len(asMap))
+	for pattern, ruleVal := range asMap {
+		ruleMap, isMap := ruleVal.(map[string]interface{})
+		if !isMap {
+			return nil, errors.Errorf("rule for pattern '%v' must be a map", pattern)
+		}
+		var rule permissionRule
+		if read, exists := ruleMap["read"]; exists {
+			rule.Read = read == true
+			v.enforceRead = true
+		}
+		rule.Write = ruleMap["write"] == true
+		rules[pattern] = rule
+	}
+	return rules, nil
+}
 
+func parsePermissionGrants(val interface{}) ([]permissionGrant, error) {
+	asSlice, isSlice := val.([]interface{})
+	if !isSlice {
+		return nil, errors.New("grants must be an array")
+	}
+	var grants []permissionGrant
+	for _, x := range asSlice {
+		switch x := x.(type) {
+		case string:
+			grants = append(grants, permissionGrant{Role: x})
+		case map[string]interface{}:
+			role, _ := x["role"].(string)
+			if role == "" {
+				return nil, errors.New("grant is missing a 'role'")
+			}
+			notBefore, _ := x["notBefore"].(float64)
+			expires, _ := x["expires"].(float64)
+			grants = append(grants, permissionGrant{Role: role, NotBefore: int64(notBefore), Expires: int64(expires)})
+		default:
+			return nil, errors.Errorf("bad grant (%T)", x)
+		}
+	}
+	return grants, nil
+}
+
+func normalizePermissionSubject(subject string) string {
+	if strings.HasPrefix(subject, "group:") || subject == "*" {
+		return subject
+	}
+	return strings.ToLower(subject)
+}
+
+var senderRegexp = regexp.MustCompile(`\$\(sender\)`)
+
+// SetScope tells the validator which state URI and keypath it governs, so that
+// it only honors capability tokens issued for them.
+func (v *permissionsValidator) SetScope(stateURI string, keypath tree.Keypath) {
+	v.stateURI = stateURI
+	v.keypath = keypath
+}
+
+func (v *permissionsValidator) ValidateTx(state tree.Node, tx *Tx) error {
 	for _, patch := range tx.Patches {
-		var valid bool
+		if patch.Keypath.StartsWith(PermissionsCapabilitiesKeypath) {
+			ok, err := v.validateCapabilityPatch(state, tx.From, patch, tx.Timestamp)
+			if err != nil {
+				return err
+			} else if ok {
+				continue
+			}
+		}
 
-		// @@TODO: hacky
-		keypath := KeypathSeparator + string(bytes.ReplaceAll(patch.Keypath, tree.KeypathSeparator, []byte(KeypathSeparator)))
-		for pattern := range permsMap {
-			expandedPattern := string(senderRegexp.ReplaceAll([]byte(pattern), []byte(tx.From.Hex())))
-			matched, err := regexp.MatchString(expandedPattern, keypath)
+		// A test only reads its keypath.  A move also writes to its source, and
+		// a copy reads from it.
+		type check struct {
+			keypath tree.Keypath
+			op      permissionOp
+		}
+		checks := []check{{patch.Keypath, permissionOpWrite}}
+		switch patch.Op {
+		case PatchOpTest:
+			checks[0].op = permissionOpRead
+		case PatchOpMove:
+			checks = append(checks, check{patch.From, permissionOpWrite})
+		case PatchOpCopy:
+			checks = append(checks, check{patch.From, permissionOpRead})
+		}
+
+		for _, c := range checks {
+			allowed, err := v.isAllowed(state, tx.From, c.keypath, c.op, tx.Timestamp, 0)
 			if err != nil {
-				return errors.Wrapf(types.Err403, "error executing regex")
+				return err
+			} else if !allowed {
+				return errors.WithStack(errors.Wrapf(types.Err403, "could not find a matching rule (user: %v, patch: %v)", tx.From.String(), patch.String()))
 			}
+		}
+	}
+	return nil
+}
 
-			if matched {
-				canWrite, _ := getValue(permsMap, []string{pattern, "write"})
-				if canWrite == true {
-					valid = true
-					break
-				}
+// ValidateRead returns types.Err403 if the requester may not read the given
+// keypath (relative to the validator's node).
+func (v *permissionsValidator) ValidateRead(state tree.Node, keypath tree.Keypath, requester types.Address) error {
+	if !v.enforceRead {
+		return nil
+	}
+	allowed, err := v.isAllowed(state, requester, keypath, permissionOpRead, v.now().Unix(), 0)
+	if err != nil {
+		return err
+	} else if !allowed {
+		return errors.WithStack(errors.Wrapf(types.Err403, "user %v may not read %v", requester.String(), keypath))
+	}
+	return nil
+}
+
+// isAllowed checks the rules that apply to addr at the time now (in Unix
+// seconds, or 0 if unknown).
+func (v *permissionsValidator) isAllowed(state tree.Node, addr types.Address, keypath tree.Keypath, op permissionOp, now int64, depth int) (bool, error) {
+	// @@TODO: hacky
+	keypathStr := KeypathSeparator + string(bytes.ReplaceAll(keypath, tree.KeypathSeparator, []byte(KeypathSeparator)))
+
+	subjects, err := v.subjectsFor(state, addr)
+	if err != nil {
+		return false, err
+	}
+
+	for _, subject := range subjects {
+		matched, err := v.rulesMatch(v.rules[subject], addr, keypathStr, op)
+		if err != nil || matched {
+			return matched, err
+		}
+	}
+
+	roles, err := v.rolesFor(state, addr, subjects, now, depth)
+	if err != nil {
+		return false, err
+	}
+	for role := range roles {
+		matched, err := v.rulesMatch(v.roles[role], addr, keypathStr, op)
+		if err != nil || matched {
+			return matched, err
+		}
+	}
+	return false, nil
+}
+
+func (v *permissionsValidator) rulesMatch(rules permissionRules, addr types.Address, keypathStr string, op permissionOp) (bool, error) {
+	for pattern, rule := range rules {
+		if (op == permissionOpRead && !rule.Read) || (op == permissionOpWrite && !rule.Write) {
+			continue
+		}
+		expandedPattern := string(senderRegexp.ReplaceAll([]byte(pattern), []byte(addr.Hex())))
+		matched, err := regexp.MatchString(expandedPattern, keypathStr)
+		if err != nil {
+			return false, errors.Wrapf(types.Err403, "error executing regex")
+		} else if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// subjectsFor returns every subject whose rules and grants apply to the given
+// address: the address itself, "*", and any groups it belongs to.
+func (v *permissionsValidator) subjectsFor(state tree.Node, addr types.Address) ([]string, error) {
+	addrHex := strings.ToLower(addr.Hex())
+	subjects := []string{addrHex, "*"}
+	if state == nil {
+		return subjects, nil
+	}
+
+	groups := state.NodeAt(PermissionsGroupsKeypath, nil)
+	for _, group := range groups.Subkeys() {
+		isMember, _, err := groups.BoolValue(group.Pushs(addrHex))
+		if err != nil && errors.Cause(err) != types.Err404 {
+			return nil, err
+		} else if isMember {
+			subjects = append(subjects, "group:"+string(group))
+		}
+	}
+	return subjects, nil
+}
+
+// rolesFor returns the roles currently held by the given address, whether by
+// grant or by capability token.
+func (v *permissionsValidator) rolesFor(state tree.Node, addr types.Address, subjects []string, now int64, depth int) (map[string]struct{}, error) {
+	roles := make(map[string]struct{})
+	for _, subject := range subjects {
+		for _, grant := range v.grants[subject] {
+			if !inTimeWindow(now, grant.NotBefore, grant.Expires) {
+				continue
 			}
+			roles[grant.Role] = struct{}{}
 		}
-		if !valid {
-			return errors.Wrapf(types.Err403, "could not find a matching rule (user: %v, patch: %v)", tx.From.String(), patch.String())
+	}
+
+	if state == nil || depth >= maxCapabilityDelegationDepth {
+		return roles, nil
+	}
+
+	capabilities := state.NodeAt(PermissionsCapabilitiesKeypath, nil)
+	for _, key := range capabilities.Subkeys() {
+		token, err := capabilityTokenFromNode(capabilities.NodeAt(key, nil))
+		if err != nil || token.Audience != addr {
+			continue
+		} else if _, alreadyHeld := roles[token.Role]; alreadyHeld {
+			continue
+		}
+		valid, err := v.capabilityIsValid(state, token, now, depth+1)
+		if err != nil {
+			return nil, err
+		} else if valid {
+			roles[token.Role] = struct{}{}
 		}
 	}
+	return roles, nil
+}
 
-	return nil
+func (v *permissionsValidator) capabilityIsValid(state tree.Node, token CapabilityToken, now int64, depth int) (bool, error) {
+	if !inTimeWindow(now, token.NotBefore, token.Expires) {
+		return false, nil
+	} else if token.StateURI != v.stateURI || token.Keypath != string(v.keypath) {
+		return false, nil
+	} else if _, exists := v.roles[token.Role]; !exists {
+		return false, nil
+	} else if !token.VerifySignature() {
+		return false, nil
+	}
+
+	subjects, err := v.subjectsFor(state, token.Issuer)
+	if err != nil {
+		return false, err
+	}
+	roles, err := v.rolesFor(state, token.Issuer, subjects, now, depth)
+	if err != nil {
+		return false, err
+	}
+	_, holdsRole := roles[token.Role]
+	return holdsRole, nil
+}
+
+// inTimeWindow returns true if now (in Unix seconds, or 0 if unknown) falls
+// within a grant's or token's bounds.  An unknown time only satisfies a window
+// with no bounds.
+func inTimeWindow(now, notBefore, expires int64) bool {
+	if notBefore == 0 && expires == 0 {
+		return true
+	} else if now == 0 {
+		return false
+	}
+	return (notBefore == 0 || now >= notBefore) && (expires == 0 || now < expires)
+}
+
+// validateCapabilityPatch allows anyone to store a valid capability token under
+// an unused key, and allows a token's issuer or audience to replace or delete
+// it.  Any other patch under `Capabilities` falls back to the ordinary rules.
+func (v *permissionsValidator) validateCapabilityPatch(state tree.Node, sender types.Address, patch Patch, now int64) (bool, error) {
+	if patch.Range != nil || patch.Keypath.NumParts() != 2 {
+		return false, nil
+	}
+
+	exists, err := state.Exists(patch.Keypath)
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		existing, err := capabilityTokenFromNode(state.NodeAt(patch.Keypath, nil))
+		if err != nil || (sender != existing.Issuer && sender != existing.Audience) {
+			return false, nil
+		}
+	}
+
+	switch patch.Op {
+	case PatchOpSet:
+	case PatchOpDelete:
+		return exists, nil
+	default:
+		return false, nil
+	}
+
+	node := tree.NewMemoryNode()
+	err = node.Set(nil, nil, patch.Val)
+	if err != nil {
+		return false, nil
+	}
+	token, err := capabilityTokenFromNode(node)
+	if err != nil {
+		return false, errors.Wrapf(types.Err403, "bad capability token: %v", err)
+	}
+	valid, err := v.capabilityIsValid(state, token, now, 1)
+	if err != nil {
+		return false, err
+	} else if !valid {
+		return false, errors.Wrapf(types.Err403, "capability token from %v is invalid or its issuer lacks the '%v' role", token.Issuer, token.Role)
+	}
+	return true, nil
+}
+
+// Hash returns the hash that the issuer signs to create the token's signature.
+func (t CapabilityToken) Hash() types.Hash {
+	parts := []string{
+		"redwood capability",
+		t.Issuer.Hex(),
+		t.Audience.Hex(),
+		t.Role,
+		t.StateURI,
+		t.Keypath,
+		strconv.FormatInt(t.NotBefore, 10),
+		strconv.FormatInt(t.Expires, 10),
+	}
+	return types.HashBytes([]byte(strings.Join(parts, "\n")))
+}
+
+// VerifySignature returns true if the token was signed by its issuer.
+func (t CapabilityToken) VerifySignature() bool {
+	if len(t.Sig) == 0 {
+		return false
+	}
+	pubkey, err := crypto.RecoverSigningPubkey(t.Hash(), t.Sig)
+	if err != nil {
+		return false
+	}
+	return pubkey.Address() == t.Issuer && pubkey.VerifySignature(t.Hash(), t.Sig)
+}
+
+func capabilityTokenFromNode(node tree.Node) (CapabilityToken, error) {
+	var token CapabilityToken
+
+	for _, field := range []string{"issuer", "audience", "role", "sig"} {
+		s, exists, err := node.StringValue(tree.Keypath(field))
+		if err != nil {
+			return token, err
+		} else if !exists {
+			return token, errors.Errorf("missing '%v'", field)
+		}
+
+		switch field {
+		case "issuer":
+			token.Issuer, err = types.AddressFromHex(s)
+		case "audience":
+			token.Audience, err = types.AddressFromHex(s)
+		case "role":
+			token.Role = s
+		case "sig":
+			token.Sig, err = types.SignatureFromHex(s)
+		}
+		if err != nil {
+			return token, errors.Wrapf(err, "bad '%v'", field)
+		}
+	}
+
+	for _, field := range []string{"stateURI", "keypath"} {
+		s, _, err := node.StringValue(tree.Keypath(field))
+		if err != nil && errors.Cause(err) != types.Err404 {
+			return token, err
+		}
+		if field == "stateURI" {
+			token.StateURI = s
+		} else {
+			token.Keypath = s
+		}
+	}
+
+	for _, field := range []string{"notBefore", "expires"} {
+		val, exists, err := node.Value(tree.Keypath(field), nil)
+		if err != nil {
+			return token, err
+		} else if !exists {
+			continue
+		}
+		f, isFloat := val.(float64)
+		if !isFloat {
+			return token, errors.Errorf("'%v' must be a number", field)
+		}
+		if field == "notBefore" {
+			token.NotBefore = int64(f)
+		} else {
+			token.Expires = int64(f)
+		}
+	}
+	return token, nil
 }
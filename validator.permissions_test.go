@@ -0,0 +1,250 @@
+package redwood_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"redwood.dev"
+	"redwood.dev/crypto"
+	"redwood.dev/testutils"
+	"redwood.dev/tree"
+	"redwood.dev/types"
+)
+
+func TestPermissionsValidator(t *testing.T) {
+	alice := testutils.RandomAddress(t)
+	bob := testutils.RandomAddress(t)
+	carol := testutils.RandomAddress(t)
+	dave := testutils.RandomAddress(t)
+
+	hour := int64(time.Hour / time.Second)
+	now := time.Now().Unix()
+
+	validator, err := redwood.NewPermissionsValidator(mustResolveConfig(t, M{
+		"Content-Type": "validator/permissions",
+		"value": M{
+			"*":                          M{`^\.public(\..*)?$`: M{"read": true}},
+			strings.ToLower(alice.Hex()): M{`^\.users\.$(sender)(\..*)?$`: M{"write": true}},
+			strings.ToLower(dave.Hex()):  M{`^\.drafts(\..*)?$`: M{"read": true, "write": true}},
+			"roles": M{
+				"editor": M{`^\.(posts|Groups)(\..*)?$`: M{"read": true, "write": true}},
+			},
+			"grants": M{
+				"group:staff":                S{"editor"},
+				strings.ToLower(carol.Hex()): S{M{"role": "editor", "expires": float64(now - hour)}},
+				strings.ToLower(dave.Hex()):  S{M{"role": "editor", "notBefore": float64(now - hour), "expires": float64(now + hour)}},
+			},
+		},
+	}))
+	require.NoError(t, err)
+
+	state := tree.NewMemoryNode()
+	err = state.Set(tree.Keypath("Groups/staff/"+strings.ToLower(alice.Hex())), nil, true)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		from      types.Address
+		timestamp int64
+		patch     string
+		wantErr   bool
+	}{
+		{"subject rule with $(sender)", alice, now, `.users.` + alice.Hex() + `.name = "alice"`, false},
+		{"subject rule for someone else", alice, now, `.users.` + bob.Hex() + `.name = "bob"`, true},
+		{"role via group", alice, now, `.posts.foo = "hi"`, false},
+		{"role via group, untimed tx", alice, 0, `.posts.foo = "hi"`, false},
+		{"no role", bob, now, `.posts.foo = "hi"`, true},
+		{"expired grant", carol, now, `.posts.foo = "hi"`, true},
+		{"expired grant, backdated tx", carol, now - 2*hour, `.posts.foo = "hi"`, false},
+		{"current grant", dave, now, `.posts.foo = "hi"`, false},
+		{"current grant, tx from after it expires", dave, now + 2*hour, `.posts.foo = "hi"`, true},
+		{"current grant, untimed tx", dave, 0, `.posts.foo = "hi"`, true},
+		{"read-only rule", bob, now, `.public.foo = "hi"`, true},
+		{"group membership is governed by the rules", bob, now, `.Groups.staff.` + strings.ToLower(bob.Hex()) + ` = true`, true},
+	}
+
+	for _, test := range tests {
+		err := validator.ValidateTx(state, &redwood.Tx{ID: types.RandomID(), From: test.from, Timestamp: test.timestamp, Patches: mustParsePatches(t, test.patch)})
+		if test.wantErr {
+			require.Error(t, err, test.name)
+			require.Equal(t, types.Err403, errors.Cause(err), test.name)
+		} else {
+			require.NoError(t, err, test.name)
+		}
+	}
+
+	readValidator, is := validator.(redwood.ReadValidator)
+	require.True(t, is)
+
+	reads := []struct {
+		requester types.Address
+		keypath   string
+		wantErr   bool
+	}{
+		{bob, "public/foo", false},
+		{bob, "posts/foo", true},
+		{types.Address{}, "public", false},
+		{types.Address{}, "drafts", true},
+		{alice, "posts/foo", false},
+		{dave, "drafts/foo", false},
+	}
+	for _, read := range reads {
+		err := readValidator.ValidateRead(state, tree.Keypath(read.keypath), read.requester)
+		if read.wantErr {
+			require.Error(t, err, read.keypath)
+			require.Equal(t, types.Err403, errors.Cause(err), read.keypath)
+		} else {
+			require.NoError(t, err, read.keypath)
+		}
+	}
+}
+
+func TestPermissionsValidator_ReadsUnrestrictedWithoutReadRules(t *testing.T) {
+	validator, err := redwood.NewPermissionsValidator(mustResolveConfig(t, M{
+		"Content-Type": "validator/permissions",
+		"value":        M{"*": M{`^.*$`: M{"write": true}}},
+	}))
+	require.NoError(t, err)
+
+	err = validator.(redwood.ReadValidator).ValidateRead(tree.NewMemoryNode(), tree.Keypath("foo"), testutils.RandomAddress(t))
+	require.NoError(t, err)
+}
+
+func TestPermissionsValidator_CapabilityTokens(t *testing.T) {
+	alice, err := crypto.GenerateSigningKeypair()
+	require.NoError(t, err)
+	bob, err := crypto.GenerateSigningKeypair()
+	require.NoError(t, err)
+	carol, err := crypto.GenerateSigningKeypair()
+	require.NoError(t, err)
+
+	validator, err := redwood.NewPermissionsValidator(mustResolveConfig(t, M{
+		"Content-Type": "validator/permissions",
+		"value": M{
+			"roles": M{
+				"editor": M{`^\.posts(\..*)?$`: M{"read": true, "write": true}},
+			},
+			"grants": M{
+				strings.ToLower(alice.Address().Hex()): S{"editor"},
+			},
+		},
+	}))
+	require.NoError(t, err)
+	validator.(redwood.ScopedValidator).SetScope("chat.local/room", tree.Keypath("room"))
+
+	mustSignScopedToken := func(issuer *crypto.SigningKeypair, audience types.Address, expires int64, stateURI, keypath string) M {
+		t.Helper()
+		token := redwood.CapabilityToken{
+			Issuer:   issuer.Address(),
+			Audience: audience,
+			Role:     "editor",
+			StateURI: stateURI,
+			Keypath:  keypath,
+			Expires:  expires,
+		}
+		sig, err := issuer.SignHash(token.Hash())
+		require.NoError(t, err)
+
+		asMap := M{
+			"issuer":   token.Issuer.Hex(),
+			"audience": token.Audience.Hex(),
+			"role":     token.Role,
+			"stateURI": token.StateURI,
+			"keypath":  token.Keypath,
+			"sig":      types.Signature(sig).Hex(),
+		}
+		if expires != 0 {
+			asMap["expires"] = float64(expires)
+		}
+		return asMap
+	}
+	mustSignToken := func(issuer *crypto.SigningKeypair, audience types.Address, expires int64) M {
+		t.Helper()
+		return mustSignScopedToken(issuer, audience, expires, "chat.local/room", "room")
+	}
+
+	validateTx := func(state tree.Node, from types.Address, keypath string, val interface{}) error {
+		t.Helper()
+		patch := redwood.Patch{Keypath: tree.Keypath(keypath), Val: val}
+		if val == nil {
+			patch.Op = redwood.PatchOpDelete
+		}
+		return validator.ValidateTx(state, &redwood.Tx{
+			ID:        types.RandomID(),
+			From:      from,
+			Timestamp: time.Now().Unix(),
+			Patches:   []redwood.Patch{patch},
+		})
+	}
+
+	state := tree.NewMemoryNode()
+
+	// Bob can't write to .posts until alice delegates her role to him
+	err = validateTx(state, bob.Address(), "posts/foo", "hi")
+	require.Equal(t, types.Err403, errors.Cause(err))
+
+	// Bob can't delegate a role he doesn't hold
+	err = validateTx(state, bob.Address(), "Capabilities/carol", mustSignToken(bob, carol.Address(), 0))
+	require.Equal(t, types.Err403, errors.Cause(err))
+
+	// Forged signatures are rejected
+	forged := mustSignToken(bob, bob.Address(), 0)
+	forged["issuer"] = alice.Address().Hex()
+	err = validateTx(state, bob.Address(), "Capabilities/bob", forged)
+	require.Equal(t, types.Err403, errors.Cause(err))
+
+	// Tokens issued for another tree, or another validator in the same tree,
+	// are rejected
+	err = validateTx(state, bob.Address(), "Capabilities/bob", mustSignScopedToken(alice, bob.Address(), 0, "other.local/room", "room"))
+	require.Equal(t, types.Err403, errors.Cause(err))
+	err = validateTx(state, bob.Address(), "Capabilities/bob", mustSignScopedToken(alice, bob.Address(), 0, "chat.local/room", "other"))
+	require.Equal(t, types.Err403, errors.Cause(err))
+
+	// Anyone can store a valid token
+	token := mustSignToken(alice, bob.Address(), 0)
+	err = validateTx(state, carol.Address(), "Capabilities/bob", token)
+	require.NoError(t, err)
+	err = state.Set(tree.Keypath("Capabilities/bob"), nil, token)
+	require.NoError(t, err)
+
+	err = validateTx(state, bob.Address(), "posts/foo", "hi")
+	require.NoError(t, err)
+	err = validator.(redwood.ReadValidator).ValidateRead(state, tree.Keypath("posts/foo"), bob.Address())
+	require.NoError(t, err)
+
+	// Tokens can be chained
+	err = state.Set(tree.Keypath("Capabilities/carol"), nil, mustSignToken(bob, carol.Address(), 0))
+	require.NoError(t, err)
+	err = validateTx(state, carol.Address(), "posts/foo", "hi")
+	require.NoError(t, err)
+
+	// Only the issuer or audience may replace a token, even with a valid one
+	err = validateTx(state, carol.Address(), "Capabilities/bob", mustSignToken(alice, carol.Address(), 0))
+	require.Equal(t, types.Err403, errors.Cause(err))
+	err = validateTx(state, alice.Address(), "Capabilities/bob", mustSignToken(alice, bob.Address(), time.Now().Add(time.Hour).Unix()))
+	require.NoError(t, err)
+	err = validateTx(state, bob.Address(), "Capabilities/bob", mustSignToken(alice, bob.Address(), time.Now().Add(time.Hour).Unix()))
+	require.NoError(t, err)
+
+	// Only the issuer or audience may revoke a token
+	err = validateTx(state, carol.Address(), "Capabilities/bob", nil)
+	require.Equal(t, types.Err403, errors.Cause(err))
+	err = validateTx(state, alice.Address(), "Capabilities/bob", nil)
+	require.NoError(t, err)
+
+	// Revoking a token revokes everything delegated through it
+	err = state.Delete(tree.Keypath("Capabilities/bob"), nil)
+	require.NoError(t, err)
+	err = validateTx(state, carol.Address(), "posts/foo", "hi")
+	require.Equal(t, types.Err403, errors.Cause(err))
+
+	// Expired tokens confer nothing
+	err = state.Set(tree.Keypath("Capabilities/bob"), nil, mustSignToken(alice, bob.Address(), time.Now().Add(-time.Minute).Unix()))
+	require.NoError(t, err)
+	err = validateTx(state, bob.Address(), "posts/foo", "hi")
+	require.Equal(t, types.Err403, errors.Cause(err))
+}
@@ -0,0 +1,141 @@
+package redwood
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/xeipuuv/gojsonschema"
+
+	"redwood.dev/nelson"
+	"redwood.dev/tree"
+	"redwood.dev/types"
+	"redwood.dev/utils"
+)
+
+// schemaValidator rejects any tx that would leave its subtree in a state that
+// doesn't conform to a JSON Schema.  The schema can be given inline or as a
+// `ref:` link.  Because NelSON treats any object with a "value" key as a frame,
+// schemas that describe a "value" property must be linked rather than inlined.
+//
+// Config:
+//
+//	"Validator": {
+//	    "Content-Type": "validator/schema",
+//	    "value": {
+//	        "schema": {
+//	            "type": "object",
+//	            "properties": { "messages": { "type": "array" } }
+//	        }
+//	    }
+//	}
+type schemaValidator struct {
+	schema *gojsonschema.Schema
+}
+
+// Ensure schemaValidator conforms to the Validator interface
+var _ Validator = (*schemaValidator)(nil)
+
+var schemaValidatorSchemaKeypath = tree.Keypath("schema")
+
+func NewSchemaValidator(config tree.Node) (_ Validator, err error) {
+	defer utils.Annotate(&err, "NewSchemaValidator")
+
+	schemaVal, exists, err := nelson.GetValueRecursive(config, schemaValidatorSchemaKeypath, nil)
+	if err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, errors.New("schema validator needs a 'schema' param")
+	}
+
+	var loader gojsonschema.JSONLoader
+	switch schemaVal.(type) {
+	case map[string]interface{}, bool:
+		loader = gojsonschema.NewGoLoader(schemaVal)
+	default:
+		readableSchema, ok := nelson.GetReadCloser(schemaVal)
+		if !ok {
+			return nil, errors.Errorf("schema validator needs a 'schema' param of type object, string, []byte, or io.ReadCloser (got %T)", schemaVal)
+		}
+		defer readableSchema.Close()
+
+		bs, err := ioutil.ReadAll(readableSchema)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		loader = gojsonschema.NewBytesLoader(bs)
+	}
+
+	schema, err := gojsonschema.NewSchema(loader)
+	if err != nil {
+		return nil, errors.Wrap(err, "schema validator could not compile schema")
+	}
+	return &schemaValidator{schema: schema}, nil
+}
+
+func (v *schemaValidator) ValidateTx(state tree.Node, tx *Tx) error {
+	// Compute the post-patch state of the subtree without touching the real one
+	postState, err := state.CopyToMemory(nil, nil)
+	if errors.Cause(err) == types.Err404 {
+		postState = tree.NewMemoryNode()
+	} else if err != nil {
+		return err
+	}
+
+	for _, patch := range tx.Patches {
+		err = patch.Apply(postState)
+		if err != nil {
+			return errors.Wrapf(err, "could not apply patch %v", patch.String())
+		}
+	}
+
+	// Behavior config lives alongside the data it governs, but isn't part of it
+	for _, keypath := range []tree.Keypath{MergeTypeKeypath, ValidatorKeypath} {
+		err = postState.Delete(keypath, nil)
+		if err != nil && errors.Cause(err) != types.Err404 {
+			return err
+		}
+	}
+
+	val, _, err := postState.Value(nil, nil)
+	if err != nil {
+		return err
+	}
+
+	// Round-trip through JSON so that the schema sees the same types a client would
+	bs, err := json.Marshal(val)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	result, err := v.schema.Validate(gojsonschema.NewBytesLoader(bs))
+	if err != nil {
+		return errors.WithStack(err)
+	} else if result.Valid() {
+		return nil
+	}
+
+	var msgs []string
+	for _, resultErr := range result.Errors() {
+		msgs = append(msgs, "at '"+v.errorKeypath(state.Keypath(), resultErr.Context()).String()+"': "+resultErr.Description())
+	}
+	return errors.Errorf("schema violation %v", strings.Join(msgs, "; "))
+}
+
+// errorKeypath converts a gojsonschema error context (e.g. "(root).foo.0.bar")
+// into an absolute state keypath.
+func (v *schemaValidator) errorKeypath(base tree.Keypath, context *gojsonschema.JsonContext) tree.Keypath {
+	keypath := base.Copy()
+	if context == nil {
+		return keypath
+	}
+	parts := strings.Split(context.String(string(tree.KeypathSeparator)), string(tree.KeypathSeparator))
+	for _, part := range parts {
+		if part == gojsonschema.STRING_CONTEXT_ROOT {
+			continue
+		}
+		keypath = keypath.Pushs(part)
+	}
+	return keypath
+}
@@ -0,0 +1,107 @@
+package redwood_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"redwood.dev"
+	"redwood.dev/nelson"
+	"redwood.dev/testutils"
+	"redwood.dev/tree"
+	"redwood.dev/types"
+)
+
+func TestSchemaValidator(t *testing.T) {
+	schema := M{
+		"type":                 "object",
+		"required":             S{"title"},
+		"additionalProperties": false,
+		"properties": M{
+			"title": M{"type": "string"},
+			"count": M{"type": "integer", "minimum": 0.0},
+			"tags":  M{"type": "array", "items": M{"type": "string"}},
+		},
+	}
+	schemaJSON := []byte(`{
+		"type": "object",
+		"required": ["title"],
+		"additionalProperties": false,
+		"properties": {
+			"title": {"type": "string"},
+			"count": {"type": "integer", "minimum": 0},
+			"tags":  {"type": "array", "items": {"type": "string"}}
+		}
+	}`)
+	schemaRefID := types.RefID{HashAlg: types.SHA3, Hash: types.HashBytes(schemaJSON)}
+
+	configs := map[string]M{
+		"inline": {"Content-Type": "validator/schema", "value": M{"schema": schema}},
+		"linked": {"Content-Type": "validator/schema", "value": M{
+			"schema": M{"Content-Type": "link", "value": "ref:" + schemaRefID.String()},
+		}},
+	}
+
+	for name, cfg := range configs {
+		cfg := cfg
+		t.Run(name, func(t *testing.T) {
+			configNode := tree.NewMemoryNode()
+			err := configNode.Set(nil, nil, cfg)
+			require.NoError(t, err)
+
+			refResolver := &refResolverMock{objects: map[types.RefID][]byte{schemaRefID: schemaJSON}}
+			config, anyMissing, err := nelson.Resolve(configNode, refResolver)
+			require.NoError(t, err)
+			require.False(t, anyMissing)
+
+			validator, err := redwood.NewSchemaValidator(config)
+			require.NoError(t, err)
+
+			// The validator's own config lives in the subtree, but isn't
+			// subject to the schema
+			root := tree.NewMemoryNode()
+			err = root.Set(nil, nil, M{
+				"chat": M{
+					"Validator": cfg,
+					"title":     "hello",
+					"tags":      S{"a"},
+				},
+			})
+			require.NoError(t, err)
+			state := root.NodeAt(tree.Keypath("chat"), nil)
+
+			tests := []struct {
+				patch   string
+				wantErr string
+			}{
+				{`.title = "goodbye"`, ""},
+				{`.count = 3`, ""},
+				{`.count = -1`, "chat/count"},
+				{`.tags = ["a", 1]`, "chat/tags/1"},
+				{`delete .title`, "'chat'"},
+				{`.title = null`, "chat/title"},
+				{`.extra = true`, "'chat'"},
+			}
+
+			for _, test := range tests {
+				tx := &redwood.Tx{
+					ID:      types.RandomID(),
+					From:    testutils.RandomAddress(t),
+					Patches: mustParsePatches(t, test.patch),
+				}
+				err := validator.ValidateTx(state, tx)
+				if test.wantErr == "" {
+					require.NoError(t, err, test.patch)
+				} else {
+					require.Error(t, err, test.patch)
+					require.Contains(t, err.Error(), test.wantErr, test.patch)
+				}
+			}
+
+			// Validation must not modify the state
+			title, _, err := state.StringValue(tree.Keypath("title"))
+			require.NoError(t, err)
+			require.Equal(t, "hello", title)
+		})
+	}
+}
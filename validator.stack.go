@@ -1,53 +1,151 @@
 package redwood
 
-// import (
-// 	"github.com/pkg/errors"
-// )
-
-// type stackValidator struct {
-// 	validators []Validator
-// }
-
-// func NewStackValidator(params map[string]interface{}) (Validator, error) {
-// 	children, exists := M(params).GetSlice("children")
-// 	if !exists {
-// 		return nil, errors.New("stack validator needs an array 'children' param")
-// 	}
-
-// 	var validators []Validator
-// 	for i := range children {
-// 		config, is := children[i].(map[string]interface{})
-// 		if !is {
-// 			return nil, errors.New("stack validator found something that didn't look like a validator config")
-// 		}
-
-// 		validator, err := initValidatorFromConfig(config)
-// 		if err != nil {
-// 			return nil, err
-// 		}
-
-// 		validators = append(validators, validator)
-// 	}
-
-// 	return &stackValidator{validators: validators}, nil
-// }
-
-// func (v *stackValidator) ValidateFetch(state interface{}, keypath []string, requester Address) error {
-// 	for i := range v.validators {
-// 		err := v.validators[i].ValidateFetch(state, keypath, requester)
-// 		if err != nil {
-// 			return err
-// 		}
-// 	}
-// 	return nil
-// }
-
-// func (v *stackValidator) ValidateTx(state interface{}, txs, validTxs map[ID]*Tx, tx Tx) error {
-// 	for i := range v.validators {
-// 		err := v.validators[i].ValidateTx(state, txs, validTxs, tx)
-// 		if err != nil {
-// 			return err
-// 		}
-// 	}
-// 	return nil
-// }
+import (
+	"io"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+
+	"redwood.dev/tree"
+	"redwood.dev/types"
+)
+
+// stackValidator combines several validators under a single keypath.  In "and"
+// mode (the default), every validator must accept a tx.  In "or" mode, any one
+// of them is sufficient.
+//
+// Config:
+//
+//	"Validator": {
+//	    "Content-Type": "validator/stack",
+//	    "value": {
+//	        "mode": "and",
+//	        "validators": [
+//	            { "Content-Type": "validator/permissions", "value": { ... } },
+//	            { "Content-Type": "validator/schema", "value": { ... } }
+//	        ]
+//	    }
+//	}
+type stackValidator struct {
+	mode       stackValidatorMode
+	validators []Validator
+}
+
+type stackValidatorMode string
+
+const (
+	stackValidatorModeAnd stackValidatorMode = "and"
+	stackValidatorModeOr  stackValidatorMode = "or"
+)
+
+var (
+	stackValidatorModeKeypath       = tree.Keypath("mode")
+	stackValidatorValidatorsKeypath = tree.Keypath("validators")
+)
+
+func NewStackValidator(config tree.Node) (Validator, error) {
+	modeStr, exists, err := config.StringValue(stackValidatorModeKeypath)
+	if err != nil {
+		return nil, err
+	} else if !exists {
+		modeStr = string(stackValidatorModeAnd)
+	}
+
+	mode := stackValidatorMode(modeStr)
+	switch mode {
+	case stackValidatorModeAnd, stackValidatorModeOr:
+	default:
+		return nil, errors.Errorf("stack validator: unknown mode '%v'", modeStr)
+	}
+
+	nodeType, _, length, err := config.NodeInfo(stackValidatorValidatorsKeypath)
+	if errors.Cause(err) == types.Err404 || (err == nil && nodeType != tree.NodeTypeSlice) {
+		return nil, errors.New("stack validator needs an array 'validators' param")
+	} else if err != nil {
+		return nil, err
+	} else if length == 0 {
+		return nil, errors.New("stack validator needs at least one validator")
+	}
+
+	var validators []Validator
+	for i := uint64(0); i < length; i++ {
+		childConfig := config.NodeAt(stackValidatorValidatorsKeypath.PushIndex(i), nil)
+		validator, err := initValidatorFromConfig(childConfig)
+		if err != nil {
+			(&stackValidator{validators: validators}).Close()
+			return nil, errors.Wrapf(err, "stack validator: validator %v", i)
+		}
+		validators = append(validators, validator)
+	}
+	return &stackValidator{mode: mode, validators: validators}, nil
+}
+
+// Close closes any children that hold resources.
+func (v *stackValidator) Close() error {
+	var errs error
+	for i := range v.validators {
+		if closer, is := v.validators[i].(io.Closer); is {
+			errs = multierr.Append(errs, closer.Close())
+		}
+	}
+	return errs
+}
+
+func (v *stackValidator) ValidateTx(state tree.Node, tx *Tx) error {
+	switch v.mode {
+	case stackValidatorModeOr:
+		var errs error
+		for i := range v.validators {
+			err := v.validators[i].ValidateTx(state, tx)
+			if err == nil {
+				return nil
+			}
+			errs = multierr.Append(errs, err)
+		}
+		return errors.Wrapf(types.Err403, "no validator accepted the tx: %v", errs)
+
+	default:
+		for i := range v.validators {
+			err := v.validators[i].ValidateTx(state, tx)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// SetScope passes the scope along to any children that need it.
+func (v *stackValidator) SetScope(stateURI string, keypath tree.Keypath) {
+	for i := range v.validators {
+		if scoped, is := v.validators[i].(ScopedValidator); is {
+			scoped.SetScope(stateURI, keypath)
+		}
+	}
+}
+
+// ValidateRead applies the same mode to any children that restrict reads.
+// Children that don't are ignored.
+func (v *stackValidator) ValidateRead(state tree.Node, keypath tree.Keypath, requester types.Address) error {
+	var errs error
+	var numReadValidators int
+	for i := range v.validators {
+		readValidator, is := v.validators[i].(ReadValidator)
+		if !is {
+			continue
+		}
+		numReadValidators++
+
+		err := readValidator.ValidateRead(state, keypath, requester)
+		if err == nil && v.mode == stackValidatorModeOr {
+			return nil
+		} else if err != nil && v.mode != stackValidatorModeOr {
+			return err
+		}
+		errs = multierr.Append(errs, err)
+	}
+	if v.mode == stackValidatorModeOr && numReadValidators > 0 {
+		return errors.Wrapf(types.Err403, "no validator allowed the read: %v", errs)
+	}
+	return nil
+}
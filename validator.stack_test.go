@@ -0,0 +1,84 @@
+package redwood_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"redwood.dev"
+	"redwood.dev/testutils"
+	"redwood.dev/tree"
+	"redwood.dev/types"
+)
+
+func TestStackValidator(t *testing.T) {
+	alice := testutils.RandomAddress(t)
+	bob := testutils.RandomAddress(t)
+
+	// Anyone may write to .public, only alice may write anywhere
+	publicPerms := M{"*": M{`^\.public.*$`: M{"write": true}}}
+	alicePerms := M{strings.ToLower(alice.Hex()): M{`^.*$`: M{"write": true}}}
+
+	newValidator := func(t *testing.T, mode string) redwood.Validator {
+		t.Helper()
+		config := mustResolveConfig(t, M{
+			"Content-Type": "validator/stack",
+			"value": M{
+				"mode": mode,
+				"validators": S{
+					M{"Content-Type": "validator/permissions", "value": publicPerms},
+					M{"Content-Type": "validator/permissions", "value": alicePerms},
+				},
+			},
+		})
+		validator, err := redwood.NewStackValidator(config)
+		require.NoError(t, err)
+		return validator
+	}
+
+	newTx := func(from types.Address, patchStr string) *redwood.Tx {
+		return &redwood.Tx{
+			ID:      types.RandomID(),
+			From:    from,
+			Patches: mustParsePatches(t, patchStr),
+		}
+	}
+
+	tests := []struct {
+		mode    string
+		from    types.Address
+		patch   string
+		wantErr bool
+	}{
+		{"and", alice, `.public.foo = 1`, false},
+		{"and", alice, `.private.foo = 1`, true},
+		{"and", bob, `.public.foo = 1`, true},
+		{"or", alice, `.private.foo = 1`, false},
+		{"or", bob, `.public.foo = 1`, false},
+		{"or", bob, `.private.foo = 1`, true},
+	}
+
+	for _, test := range tests {
+		validator := newValidator(t, test.mode)
+		err := validator.ValidateTx(tree.NewMemoryNode(), newTx(test.from, test.patch))
+		if test.wantErr {
+			require.Error(t, err, "%v %v", test.mode, test.patch)
+			require.Equal(t, types.Err403, errors.Cause(err))
+		} else {
+			require.NoError(t, err, "%v %v", test.mode, test.patch)
+		}
+	}
+}
+
+func TestStackValidator_BadMode(t *testing.T) {
+	_, err := redwood.NewStackValidator(mustResolveConfig(t, M{
+		"Content-Type": "validator/stack",
+		"value": M{
+			"mode":       "xor",
+			"validators": S{M{"Content-Type": "validator/permissions", "value": M{}}},
+		},
+	}))
+	require.Error(t, err)
+}
@@ -0,0 +1,62 @@
+package redwood
+
+import (
+	"github.com/pkg/errors"
+
+	"redwood.dev/tree"
+	"redwood.dev/types"
+	"redwood.dev/utils"
+)
+
+// wasmValidator hands each tx to a WASM module's `validate_tx` export.  The
+// module may read the current state with `state_get`, but can't emit patches.
+// A non-zero return rejects the tx, along with any message passed to
+// `set_error`.
+//
+// The input passed to `validate_tx` looks like:
+//
+//	{
+//	    "id": "...",
+//	    "from": "96216849c49358b10257cb55b28ea603c874b05e",
+//	    "parents": ["..."],
+//	    "patches": [".foo.bar = 123"]
+//	}
+type wasmValidator struct {
+	sandbox *wasmSandbox
+}
+
+// Ensure wasmValidator conforms to the Validator interface
+var _ Validator = (*wasmValidator)(nil)
+
+func NewWASMValidator(config tree.Node) (_ Validator, err error) {
+	defer utils.Annotate(&err, "NewWASMValidator")
+
+	sandbox, err := newWasmSandbox(config, "validator:wasm", "validate_tx")
+	if err != nil {
+		return nil, err
+	}
+	return &wasmValidator{sandbox: sandbox}, nil
+}
+
+// Close releases the validator's WASM runtime.
+func (v *wasmValidator) Close() error {
+	return v.sandbox.close()
+}
+
+func (v *wasmValidator) ValidateTx(state tree.Node, tx *Tx) error {
+	parentStrs := make([]string, len(tx.Parents))
+	for i := range tx.Parents {
+		parentStrs[i] = tx.Parents[i].String()
+	}
+
+	err := v.sandbox.call(&wasmCall{state: state}, map[string]interface{}{
+		"id":      tx.ID.String(),
+		"from":    tx.From.Hex(),
+		"parents": parentStrs,
+		"patches": tx.Patches,
+	})
+	if err != nil {
+		return errors.Wrapf(types.Err403, "%v", err)
+	}
+	return nil
+}
@@ -0,0 +1,568 @@
+package redwood
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// meterWasmModule rewrites a WASM module so that it burns fuel as it runs and
+// traps once it runs out, which bounds the work that a plugin can do in a way
+// that doesn't depend on how fast the host happens to be.
+//
+// The fuel lives in a new mutable i64 global, exported as wasmFuelExport and
+// initialized to the budget.  Every function body and every loop body starts
+// by subtracting the number of instructions between it and the next loop (or
+// the end of the function), and traps with `unreachable` if the result is
+// negative.  Only backward branches (to a loop) and calls can make a module
+// run longer than its size, so charging at those points is enough to bound
+// it.  Charging a whole segment up front overestimates the cost of code that
+// branches forward, but the count is the same on every host.
+//
+// No functions or imports are added, so the module's function indices don't
+// change.
+func meterWasmModule(src []byte, fuel uint64) (_ []byte, err error) {
+	defer func() {
+		if err != nil {
+			err = errors.Wrap(err, "could not meter wasm module")
+		}
+	}()
+
+	if len(src) < 8 || !bytes.Equal(src[:4], []byte{0x00, 0x61, 0x73, 0x6d}) {
+		return nil, errors.New("bad magic number")
+	}
+	if fuel > math.MaxInt64 {
+		fuel = math.MaxInt64
+	}
+
+	type section struct {
+		id       byte
+		contents []byte
+	}
+	var sections []section
+	r := &wasmReader{bs: src, pos: 8}
+	for !r.done() {
+		id, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		size, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+		contents, err := r.bytes(int(size))
+		if err != nil {
+			return nil, err
+		}
+		sections = append(sections, section{id, contents})
+	}
+
+	// The fuel global goes after every imported and defined global
+	var fuelGlobal uint32
+	for _, s := range sections {
+		switch s.id {
+		case wasmSectionImport:
+			n, err := countWasmImportedGlobals(s.contents)
+			if err != nil {
+				return nil, err
+			}
+			fuelGlobal += n
+		case wasmSectionGlobal:
+			n, err := (&wasmReader{bs: s.contents}).u32()
+			if err != nil {
+				return nil, err
+			}
+			fuelGlobal += n
+		}
+	}
+
+	globalEntry := []byte{0x7e, 0x01, 0x42} // i64, mutable, i64.const
+	globalEntry = appendSLEB128(globalEntry, int64(fuel))
+	globalEntry = append(globalEntry, 0x0b)
+
+	exportEntry := appendULEB128(nil, uint64(len(wasmFuelExport)))
+	exportEntry = append(exportEntry, wasmFuelExport...)
+	exportEntry = append(exportEntry, 0x03) // global
+	exportEntry = appendULEB128(exportEntry, uint64(fuelGlobal))
+
+	out := append([]byte(nil), src[:8]...)
+	writeSection := func(id byte, contents []byte) {
+		out = append(out, id)
+		out = appendULEB128(out, uint64(len(contents)))
+		out = append(out, contents...)
+	}
+
+	var wroteGlobals, wroteExports bool
+	writeMissingSections := func(beforeOrder int) {
+		if !wroteGlobals && beforeOrder > wasmSectionOrder[wasmSectionGlobal] {
+			writeSection(wasmSectionGlobal, append([]byte{0x01}, globalEntry...))
+			wroteGlobals = true
+		}
+		if !wroteExports && beforeOrder > wasmSectionOrder[wasmSectionExport] {
+			writeSection(wasmSectionExport, append([]byte{0x01}, exportEntry...))
+			wroteExports = true
+		}
+	}
+
+	for _, s := range sections {
+		if s.id != wasmSectionCustom {
+			writeMissingSections(wasmSectionOrder[s.id])
+		}
+
+		switch s.id {
+		case wasmSectionGlobal:
+			contents, err := appendWasmVecItem(s.contents, globalEntry)
+			if err != nil {
+				return nil, err
+			}
+			writeSection(s.id, contents)
+			wroteGlobals = true
+
+		case wasmSectionExport:
+			contents, err := appendWasmVecItem(s.contents, exportEntry)
+			if err != nil {
+				return nil, err
+			}
+			writeSection(s.id, contents)
+			wroteExports = true
+
+		case wasmSectionCode:
+			contents, err := meterWasmCode(s.contents, fuelGlobal)
+			if err != nil {
+				return nil, err
+			}
+			writeSection(s.id, contents)
+
+		default:
+			writeSection(s.id, s.contents)
+		}
+	}
+	writeMissingSections(math.MaxInt32)
+	return out, nil
+}
+
+const (
+	wasmFuelExport = "redwood_fuel"
+
+	wasmSectionCustom = 0
+	wasmSectionImport = 2
+	wasmSectionGlobal = 6
+	wasmSectionExport = 7
+	wasmSectionCode   = 10
+)
+
+// wasmSectionOrder is the order in which the non-custom sections must appear.
+// It differs from their IDs because the data count section (12) was added
+// after the others were numbered.
+var wasmSectionOrder = map[byte]int{
+	1: 1, 2: 2, 3: 3, 4: 4, 5: 5, 6: 6, 7: 7, 8: 8, 9: 9, 12: 10, 10: 11, 11: 12,
+}
+
+func countWasmImportedGlobals(contents []byte) (uint32, error) {
+	r := &wasmReader{bs: contents}
+	n, err := r.u32()
+	if err != nil {
+		return 0, err
+	}
+	var globals uint32
+	for i := uint32(0); i < n; i++ {
+		for j := 0; j < 2; j++ { // module and field names
+			nameLen, err := r.u32()
+			if err != nil {
+				return 0, err
+			}
+			_, err = r.bytes(int(nameLen))
+			if err != nil {
+				return 0, err
+			}
+		}
+		kind, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		switch kind {
+		case 0x00: // func
+			_, err = r.u32()
+		case 0x01: // table
+			_, err = r.byte()
+			if err == nil {
+				err = r.skipLimits()
+			}
+		case 0x02: // memory
+			err = r.skipLimits()
+		case 0x03: // global
+			_, err = r.bytes(2)
+			globals++
+		default:
+			err = errors.Errorf("unknown import kind 0x%x", kind)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return globals, nil
+}
+
+// appendWasmVecItem appends an item to the encoded vector that makes up the
+// contents of a section.
+func appendWasmVecItem(contents []byte, item []byte) ([]byte, error) {
+	r := &wasmReader{bs: contents}
+	n, err := r.u32()
+	if err != nil {
+		return nil, err
+	}
+	out := appendULEB128(nil, uint64(n)+1)
+	out = append(out, contents[r.pos:]...)
+	return append(out, item...), nil
+}
+
+func meterWasmCode(contents []byte, fuelGlobal uint32) ([]byte, error) {
+	r := &wasmReader{bs: contents}
+	n, err := r.u32()
+	if err != nil {
+		return nil, err
+	}
+	out := appendULEB128(nil, uint64(n))
+	for i := uint32(0); i < n; i++ {
+		size, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+		body, err := r.bytes(int(size))
+		if err != nil {
+			return nil, err
+		}
+		metered, err := meterWasmFunc(body, fuelGlobal)
+		if err != nil {
+			return nil, errors.Wrapf(err, "function %v", i)
+		}
+		out = appendULEB128(out, uint64(len(metered)))
+		out = append(out, metered...)
+	}
+	return out, nil
+}
+
+func meterWasmFunc(body []byte, fuelGlobal uint32) ([]byte, error) {
+	r := &wasmReader{bs: body}
+	numLocalDecls, err := r.u32()
+	if err != nil {
+		return nil, err
+	}
+	for i := uint32(0); i < numLocalDecls; i++ {
+		_, err = r.u32()
+		if err != nil {
+			return nil, err
+		}
+		_, err = r.byte()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Split the body into segments that start at the top of the function and
+	// at the top of each loop, and count the instructions in each
+	starts := []int{r.pos}
+	var costs []int64
+	var cost int64
+	for !r.done() {
+		op, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		err = r.skipImmediates(op)
+		if err != nil {
+			return nil, err
+		}
+		cost++
+		if op == 0x03 { // loop
+			costs = append(costs, cost)
+			starts = append(starts, r.pos)
+			cost = 0
+		}
+	}
+	costs = append(costs, cost)
+
+	out := append([]byte(nil), body[:starts[0]]...)
+	for i, start := range starts {
+		end := len(body)
+		if i < len(starts)-1 {
+			end = starts[i+1]
+		}
+		out = appendWasmFuelCharge(out, fuelGlobal, costs[i])
+		out = append(out, body[start:end]...)
+	}
+	return out, nil
+}
+
+func appendWasmFuelCharge(out []byte, fuelGlobal uint32, cost int64) []byte {
+	if cost < 1 {
+		cost = 1
+	}
+	out = append(out, 0x23) // global.get
+	out = appendULEB128(out, uint64(fuelGlobal))
+	out = append(out, 0x42) // i64.const
+	out = appendSLEB128(out, cost)
+	out = append(out, 0x7d, 0x24) // i64.sub; global.set
+	out = appendULEB128(out, uint64(fuelGlobal))
+	out = append(out, 0x23) // global.get
+	out = appendULEB128(out, uint64(fuelGlobal))
+	return append(out,
+		0x42, 0x00, // i64.const 0
+		0x53,       // i64.lt_s
+		0x04, 0x40, // if
+		0x00, // unreachable
+		0x0b, // end
+	)
+}
+
+type wasmReader struct {
+	bs  []byte
+	pos int
+}
+
+func (r *wasmReader) done() bool {
+	return r.pos >= len(r.bs)
+}
+
+func (r *wasmReader) byte() (byte, error) {
+	if r.done() {
+		return 0, errors.New("unexpected end of module")
+	}
+	b := r.bs[r.pos]
+	r.pos++
+	return b, nil
+}
+
+func (r *wasmReader) bytes(n int) ([]byte, error) {
+	if n < 0 || r.pos+n > len(r.bs) {
+		return nil, errors.New("unexpected end of module")
+	}
+	bs := r.bs[r.pos : r.pos+n]
+	r.pos += n
+	return bs, nil
+}
+
+func (r *wasmReader) u32() (uint32, error) {
+	x, n := binary.Uvarint(r.bs[r.pos:])
+	if n <= 0 || x > math.MaxUint32 {
+		return 0, errors.New("bad LEB128 integer")
+	}
+	r.pos += n
+	return uint32(x), nil
+}
+
+// skipLEB128 skips a signed or unsigned LEB128 integer of up to 64 bits.
+func (r *wasmReader) skipLEB128() error {
+	for i := 0; i < 10; i++ {
+		b, err := r.byte()
+		if err != nil {
+			return err
+		} else if b&0x80 == 0 {
+			return nil
+		}
+	}
+	return errors.New("bad LEB128 integer")
+}
+
+func (r *wasmReader) skipLimits() error {
+	flags, err := r.byte()
+	if err != nil {
+		return err
+	}
+	_, err = r.u32()
+	if err != nil {
+		return err
+	}
+	if flags&0x01 != 0 {
+		_, err = r.u32()
+	}
+	return err
+}
+
+func (r *wasmReader) skipBlockType() error {
+	if r.done() {
+		return errors.New("unexpected end of module")
+	}
+	switch r.bs[r.pos] {
+	case 0x40, 0x7f, 0x7e, 0x7d, 0x7c, 0x7b, 0x70, 0x6f:
+		r.pos++
+		return nil
+	default:
+		return r.skipLEB128() // type index, as an s33
+	}
+}
+
+func (r *wasmReader) skipMemarg() error {
+	err := r.skipLEB128()
+	if err != nil {
+		return err
+	}
+	return r.skipLEB128()
+}
+
+// skipImmediates skips the immediate operands of the instruction whose opcode
+// was just read.  It understands the instructions that the runtime does: the
+// MVP plus sign extension, saturating truncation, bulk memory, reference
+// types, multiple values, and SIMD.
+func (r *wasmReader) skipImmediates(op byte) (err error) {
+	switch {
+	case op == 0x02 || op == 0x03 || op == 0x04: // block, loop, if
+		return r.skipBlockType()
+
+	case op == 0x0c || op == 0x0d: // br, br_if
+		return r.skipLEB128()
+
+	case op == 0x0e: // br_table
+		n, err := r.u32()
+		if err != nil {
+			return err
+		}
+		for i := uint32(0); i <= n; i++ {
+			err = r.skipLEB128()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case op == 0x10: // call
+		return r.skipLEB128()
+
+	case op == 0x11: // call_indirect
+		err = r.skipLEB128()
+		if err != nil {
+			return err
+		}
+		return r.skipLEB128()
+
+	case op == 0x1c: // select t*
+		n, err := r.u32()
+		if err != nil {
+			return err
+		}
+		_, err = r.bytes(int(n))
+		return err
+
+	case op >= 0x20 && op <= 0x26: // local.*, global.*, table.get, table.set
+		return r.skipLEB128()
+
+	case op >= 0x28 && op <= 0x3e: // loads and stores
+		return r.skipMemarg()
+
+	case op == 0x3f || op == 0x40: // memory.size, memory.grow
+		_, err = r.byte()
+		return err
+
+	case op == 0x41 || op == 0x42: // i32.const, i64.const
+		return r.skipLEB128()
+
+	case op == 0x43: // f32.const
+		_, err = r.bytes(4)
+		return err
+
+	case op == 0x44: // f64.const
+		_, err = r.bytes(8)
+		return err
+
+	case op == 0xd0: // ref.null
+		_, err = r.byte()
+		return err
+
+	case op == 0xd2: // ref.func
+		return r.skipLEB128()
+
+	case op == 0xfc:
+		return r.skipPrefixedFCImmediates()
+
+	case op == 0xfd:
+		return r.skipPrefixedFDImmediates()
+
+	case op == 0x00, op == 0x01, op == 0x05, op == 0x0b, op == 0x0f, op == 0x1a, op == 0x1b,
+		op >= 0x45 && op <= 0xc4, op == 0xd1:
+		return nil
+
+	default:
+		return errors.Errorf("unsupported instruction 0x%x", op)
+	}
+}
+
+func (r *wasmReader) skipPrefixedFCImmediates() error {
+	op, err := r.u32()
+	if err != nil {
+		return err
+	}
+	switch {
+	case op <= 7: // saturating truncation
+		return nil
+	case op == 8: // memory.init
+		err = r.skipLEB128()
+		if err != nil {
+			return err
+		}
+		_, err = r.byte()
+		return err
+	case op == 9, op == 13, op >= 15 && op <= 17: // data.drop, elem.drop, table.grow/size/fill
+		return r.skipLEB128()
+	case op == 10: // memory.copy
+		_, err = r.bytes(2)
+		return err
+	case op == 11: // memory.fill
+		_, err = r.byte()
+		return err
+	case op == 12 || op == 14: // table.init, table.copy
+		err = r.skipLEB128()
+		if err != nil {
+			return err
+		}
+		return r.skipLEB128()
+	default:
+		return errors.Errorf("unsupported instruction 0xfc %v", op)
+	}
+}
+
+func (r *wasmReader) skipPrefixedFDImmediates() error {
+	op, err := r.u32()
+	if err != nil {
+		return err
+	}
+	switch {
+	case op <= 11, op == 92, op == 93: // loads and stores
+		return r.skipMemarg()
+	case op == 12, op == 13: // v128.const, i8x16.shuffle
+		_, err = r.bytes(16)
+		return err
+	case op >= 21 && op <= 34: // extract_lane, replace_lane
+		_, err = r.byte()
+		return err
+	case op >= 84 && op <= 91: // load_lane, store_lane
+		err = r.skipMemarg()
+		if err != nil {
+			return err
+		}
+		_, err = r.byte()
+		return err
+	case op <= 255:
+		return nil
+	default:
+		return errors.Errorf("unsupported instruction 0xfd %v", op)
+	}
+}
+
+func appendULEB128(out []byte, x uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], x)
+	return append(out, buf[:n]...)
+}
+
+func appendSLEB128(out []byte, x int64) []byte {
+	for {
+		b := byte(x & 0x7f)
+		x >>= 7
+		if (x == 0 && b&0x40 == 0) || (x == -1 && b&0x40 != 0) {
+			return append(out, b)
+		}
+		out = append(out, b|0x80)
+	}
+}
@@ -0,0 +1,367 @@
+package redwood
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+
+	"redwood.dev/ctx"
+	"redwood.dev/nelson"
+	"redwood.dev/tree"
+	"redwood.dev/utils"
+)
+
+// wasmSandbox runs a single WASM plugin module on behalf of a resolver,
+// validator, or indexer.  All three plugin kinds share the same host ABI,
+// which is exposed to the guest as the "redwood" import module:
+//
+//	state_get(keypath_ptr, keypath_len i32) i64
+//	    Returns the JSON encoding of the value at the given keypath (relative
+//	    to the plugin's state node), packed as (ptr << 32 | len).  The host
+//	    allocates the buffer using the guest's exported `alloc`.  Returns 0 if
+//	    the keypath doesn't exist.
+//	emit_patch(patch_ptr, patch_len i32) i32
+//	    Emits a patch in Redwood's patch syntax (i.e. `.foo.bar = {"x": 1}`).
+//	    Returns 0 on success and 1 if the patch couldn't be parsed or patches
+//	    aren't permitted for this plugin kind (validators can't emit patches).
+//	emit_index_key(key_ptr, key_len i32)
+//	    Sets the key under which an indexer wants the current node indexed.
+//	set_error(msg_ptr, msg_len i32)
+//	    Sets the error message that accompanies a non-zero return code.
+//	log(msg_ptr, msg_len i32)
+//	    Writes a debug message to the host's log.
+//
+// The guest must export `memory`, `alloc(size i32) i32`, and the entrypoint
+// for its plugin kind (`resolve_state`, `validate_tx`, or `index_node`).
+// Entrypoints take a pointer and length of a JSON input object and return 0
+// on success.  Every call runs in a fresh instance of the module, so nothing
+// (memory, globals) carries over from one call to the next, and the guest
+// never needs to free what it allocates.
+//
+// Each call may spend at most `fuel` units, roughly one per instruction (see
+// meterWasmModule), so every node agrees on whether a call ran too long.
+//
+// Config:
+//
+//	{
+//	    "src": { "Content-Type": "link", "value": "ref:sha1:..." },
+//	    "memoryLimitPages": 256,
+//	    "fuel": 100000000
+//	}
+type wasmSandbox struct {
+	ctx.Logger
+	entrypoint string
+	fuel       uint64
+	runtime    wazero.Runtime
+	compiled   wazero.CompiledModule
+}
+
+type wasmCall struct {
+	state        tree.Node
+	allowPatches bool
+	patches      []Patch
+	indexKey     tree.Keypath
+	errMsg       string
+	hostErr      error
+}
+
+type wasmCallKey struct{}
+
+const (
+	wasmHostModuleName          = "redwood"
+	wasmDefaultMemoryLimitPages = 256 // 16MiB
+	wasmDefaultFuel             = 100000000
+)
+
+var (
+	wasmSrcKeypath              = tree.Keypath("src")
+	wasmMemoryLimitPagesKeypath = tree.Keypath("memoryLimitPages")
+	wasmFuelKeypath             = tree.Keypath("fuel")
+)
+
+func newWasmSandbox(config tree.Node, label string, entrypoint string) (_ *wasmSandbox, err error) {
+	defer utils.Annotate(&err, "newWasmSandbox")
+
+	srcval, exists, err := nelson.GetValueRecursive(config, wasmSrcKeypath, nil)
+	if err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, errors.Errorf("%v needs a 'src' param", label)
+	}
+
+	readableSrc, ok := nelson.GetReadCloser(srcval)
+	if !ok {
+		return nil, errors.Errorf("%v needs a 'src' param of type []byte or io.ReadCloser (got %T)", label, srcval)
+	}
+	defer readableSrc.Close()
+
+	src, err := ioutil.ReadAll(readableSrc)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	memoryLimitPages, err := wasmConfigUint(config, wasmMemoryLimitPagesKeypath, wasmDefaultMemoryLimitPages)
+	if err != nil {
+		return nil, err
+	}
+	fuel, err := wasmConfigUint(config, wasmFuelKeypath, wasmDefaultFuel)
+	if err != nil {
+		return nil, err
+	}
+
+	src, err = meterWasmModule(src, fuel)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%v", label)
+	}
+
+	runtimeConfig := wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(uint32(memoryLimitPages))
+
+	bgctx := context.Background()
+	runtime := wazero.NewRuntimeWithConfig(bgctx, runtimeConfig)
+
+	sandbox := &wasmSandbox{
+		Logger:     ctx.NewLogger(label),
+		entrypoint: entrypoint,
+		fuel:       fuel,
+		runtime:    runtime,
+	}
+
+	_, err = runtime.NewHostModuleBuilder(wasmHostModuleName).
+		NewFunctionBuilder().WithFunc(sandbox.hostStateGet).Export("state_get").
+		NewFunctionBuilder().WithFunc(sandbox.hostEmitPatch).Export("emit_patch").
+		NewFunctionBuilder().WithFunc(sandbox.hostEmitIndexKey).Export("emit_index_key").
+		NewFunctionBuilder().WithFunc(sandbox.hostSetError).Export("set_error").
+		NewFunctionBuilder().WithFunc(sandbox.hostLog).Export("log").
+		Instantiate(bgctx)
+	if err != nil {
+		runtime.Close(bgctx)
+		return nil, errors.WithStack(err)
+	}
+
+	sandbox.compiled, err = runtime.CompileModule(bgctx, src)
+	if err != nil {
+		runtime.Close(bgctx)
+		return nil, errors.Wrapf(err, "%v could not compile module", label)
+	}
+
+	if _, exists := sandbox.compiled.ExportedFunctions()[entrypoint]; !exists {
+		runtime.Close(bgctx)
+		return nil, errors.Errorf("%v module must export '%v'", label, entrypoint)
+	} else if _, exists := sandbox.compiled.ExportedFunctions()["alloc"]; !exists {
+		runtime.Close(bgctx)
+		return nil, errors.Errorf("%v module must export 'alloc'", label)
+	}
+
+	// Make sure that the module can be instantiated at all, so that a bad
+	// module is rejected up front rather than on every call
+	module, err := sandbox.instantiate(bgctx)
+	if err != nil {
+		runtime.Close(bgctx)
+		return nil, err
+	}
+	module.Close(bgctx)
+	return sandbox, nil
+}
+
+func wasmConfigUint(config tree.Node, keypath tree.Keypath, defaultVal uint64) (uint64, error) {
+	val, exists, err := config.Value(keypath, nil)
+	if err != nil {
+		return 0, err
+	} else if !exists {
+		return defaultVal, nil
+	}
+	switch v := val.(type) {
+	case float64:
+		if v <= 0 {
+			break
+		}
+		return uint64(v), nil
+	case int64:
+		if v <= 0 {
+			break
+		}
+		return uint64(v), nil
+	case uint64:
+		if v == 0 {
+			break
+		}
+		return v, nil
+	}
+	return 0, errors.Errorf("'%v' must be a positive number (got %v)", keypath, val)
+}
+
+func (s *wasmSandbox) instantiate(ctx context.Context) (api.Module, error) {
+	// Leave the instance anonymous so that instances never collide with one
+	// another's names
+	moduleConfig := wazero.NewModuleConfig().WithName("")
+	module, err := s.runtime.InstantiateModule(ctx, s.compiled, moduleConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not instantiate wasm module")
+	}
+	return module, nil
+}
+
+// call invokes the sandbox's entrypoint with the given JSON-encoded input.
+func (s *wasmSandbox) call(call *wasmCall, input interface{}) error {
+	inputBytes, err := json.Marshal(input)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	callCtx := context.WithValue(context.Background(), wasmCallKey{}, call)
+
+	module, err := s.instantiate(callCtx)
+	if err != nil {
+		return err
+	}
+	defer module.Close(context.Background())
+
+	inputPtr, err := s.writeToGuest(callCtx, module, inputBytes)
+	if err != nil {
+		return err
+	}
+
+	results, err := module.ExportedFunction(s.entrypoint).Call(callCtx, uint64(inputPtr), uint64(len(inputBytes)))
+	if err != nil && int64(module.ExportedGlobal(wasmFuelExport).Get()) < 0 {
+		return errors.Errorf("wasm module exceeded its fuel budget of %v", s.fuel)
+	} else if err != nil {
+		return errors.Wrapf(err, "wasm module trapped in '%v'", s.entrypoint)
+	} else if call.hostErr != nil {
+		return call.hostErr
+	} else if len(results) != 1 {
+		return errors.Errorf("wasm '%v' must return a single i32", s.entrypoint)
+	} else if uint32(results[0]) != 0 {
+		if call.errMsg == "" {
+			return errors.Errorf("wasm '%v' returned %v", s.entrypoint, uint32(results[0]))
+		}
+		return errors.New(call.errMsg)
+	}
+	return nil
+}
+
+func (s *wasmSandbox) writeToGuest(ctx context.Context, m api.Module, bs []byte) (uint32, error) {
+	results, err := m.ExportedFunction("alloc").Call(ctx, uint64(len(bs)))
+	if err != nil {
+		return 0, errors.Wrap(err, "wasm module trapped in 'alloc'")
+	} else if len(results) != 1 {
+		return 0, errors.New("wasm 'alloc' must return a single i32")
+	}
+	ptr := uint32(results[0])
+	if !m.Memory().Write(ptr, bs) {
+		return 0, errors.Errorf("wasm 'alloc' returned an out-of-bounds pointer (%v)", ptr)
+	}
+	return ptr, nil
+}
+
+func (s *wasmSandbox) readFromGuest(m api.Module, ptr, length uint32) ([]byte, error) {
+	bs, ok := m.Memory().Read(ptr, length)
+	if !ok {
+		return nil, errors.Errorf("out-of-bounds memory access (ptr: %v, len: %v)", ptr, length)
+	}
+	// The returned slice aliases guest memory, which can be reallocated
+	cp := make([]byte, len(bs))
+	copy(cp, bs)
+	return cp, nil
+}
+
+func (s *wasmSandbox) close() error {
+	return s.runtime.Close(context.Background())
+}
+
+func currentWasmCall(ctx context.Context) *wasmCall {
+	call, _ := ctx.Value(wasmCallKey{}).(*wasmCall)
+	return call
+}
+
+func (s *wasmSandbox) hostStateGet(ctx context.Context, m api.Module, keypathPtr, keypathLen uint32) uint64 {
+	call := currentWasmCall(ctx)
+	if call == nil || call.hostErr != nil || call.state == nil {
+		return 0
+	}
+
+	keypath, err := s.readFromGuest(m, keypathPtr, keypathLen)
+	if err != nil {
+		call.hostErr = errors.Wrap(err, "state_get")
+		return 0
+	}
+
+	val, exists, err := call.state.Value(tree.Keypath(keypath), nil)
+	if err != nil {
+		call.hostErr = errors.Wrap(err, "state_get")
+		return 0
+	} else if !exists {
+		return 0
+	}
+
+	bs, err := json.Marshal(val)
+	if err != nil {
+		call.hostErr = errors.Wrap(err, "state_get")
+		return 0
+	}
+
+	ptr, err := s.writeToGuest(ctx, m, bs)
+	if err != nil {
+		call.hostErr = errors.Wrap(err, "state_get")
+		return 0
+	}
+	return uint64(ptr)<<32 | uint64(len(bs))
+}
+
+func (s *wasmSandbox) hostEmitPatch(ctx context.Context, m api.Module, patchPtr, patchLen uint32) uint32 {
+	call := currentWasmCall(ctx)
+	if call == nil || !call.allowPatches {
+		return 1
+	}
+
+	bs, err := s.readFromGuest(m, patchPtr, patchLen)
+	if err != nil {
+		return 1
+	}
+
+	patch, err := ParsePatch(bs)
+	if err != nil {
+		s.Debugf("wasm module emitted a bad patch: %v", err)
+		return 1
+	}
+	call.patches = append(call.patches, patch)
+	return 0
+}
+
+func (s *wasmSandbox) hostEmitIndexKey(ctx context.Context, m api.Module, keyPtr, keyLen uint32) {
+	call := currentWasmCall(ctx)
+	if call == nil {
+		return
+	}
+	key, err := s.readFromGuest(m, keyPtr, keyLen)
+	if err != nil {
+		call.hostErr = errors.Wrap(err, "emit_index_key")
+		return
+	}
+	call.indexKey = tree.Keypath(key)
+}
+
+func (s *wasmSandbox) hostSetError(ctx context.Context, m api.Module, msgPtr, msgLen uint32) {
+	call := currentWasmCall(ctx)
+	if call == nil {
+		return
+	}
+	msg, err := s.readFromGuest(m, msgPtr, msgLen)
+	if err != nil {
+		call.hostErr = errors.Wrap(err, "set_error")
+		return
+	}
+	call.errMsg = string(msg)
+}
+
+func (s *wasmSandbox) hostLog(ctx context.Context, m api.Module, msgPtr, msgLen uint32) {
+	msg, err := s.readFromGuest(m, msgPtr, msgLen)
+	if err != nil {
+		return
+	}
+	s.Debugf("%v", string(msg))
+}
@@ -0,0 +1,370 @@
+package redwood_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"redwood.dev"
+	"redwood.dev/nelson"
+	"redwood.dev/testutils"
+	"redwood.dev/tree"
+	"redwood.dev/types"
+)
+
+type refResolverMock struct {
+	objects map[types.RefID][]byte
+}
+
+func (r *refResolverMock) StateAtVersion(stateURI string, version *types.ID) (tree.Node, error) {
+	return nil, errors.WithStack(types.Err404)
+}
+
+func (r *refResolverMock) RefObjectReader(refID types.RefID) (io.ReadCloser, int64, error) {
+	bs, exists := r.objects[refID]
+	if !exists {
+		return nil, 0, errors.WithStack(types.Err404)
+	}
+	return ioutil.NopCloser(bytes.NewReader(bs)), int64(len(bs)), nil
+}
+
+// mustResolveWasmConfig stores the given module in a mock ref store and returns
+// a resolved plugin config that links to it.
+func mustResolveWasmConfig(t *testing.T, contentType string, module []byte, params M) tree.Node {
+	t.Helper()
+
+	refID := types.RefID{HashAlg: types.SHA3, Hash: types.HashBytes(module)}
+	refResolver := &refResolverMock{objects: map[types.RefID][]byte{refID: module}}
+
+	value := M{"src": M{"Content-Type": "link", "value": "ref:" + refID.String()}}
+	for k, v := range params {
+		value[k] = v
+	}
+
+	node := tree.NewMemoryNode()
+	err := node.Set(nil, nil, M{"Content-Type": contentType, "value": value})
+	require.NoError(t, err)
+
+	config, anyMissing, err := nelson.Resolve(node, refResolver)
+	require.NoError(t, err)
+	require.False(t, anyMissing)
+	return config
+}
+
+func TestWASMResolver(t *testing.T) {
+	// resolve_state copies .text to .copy and sets .internalState.seen.  alloc
+	// always returns 8 so that state_get writes its JSON directly after the
+	// ".copy = " prefix in the data segment.  The rest of the data lives well
+	// past the input JSON, which is also written at 8.
+	module := buildWasmModule(wasmModuleSpec{
+		data: map[uint32]string{0: `.copy = `, 2048: `text`, 2064: `.internalState.seen = true`},
+		alloc: []byte{
+			0x41, 0x08, // i32.const 8
+		},
+		entrypoint: "resolve_state",
+		locals:     []byte{0x01, 0x7e}, // 1 x i64
+		body: []byte{
+			0x41, 0x80, 0x10, 0x41, 0x04, 0x10, 0x00, // call state_get(2048, 4)
+			0x21, 0x02, // local.set 2
+			0x41, 0x00, // i32.const 0
+			0x20, 0x02, 0xa7, // local.get 2; i32.wrap_i64
+			0x41, 0x08, 0x6a, // i32.const 8; i32.add
+			0x10, 0x01, 0x1a, // call emit_patch; drop
+			0x41, 0x90, 0x10, 0x41, 0x1a, 0x10, 0x01, 0x1a, // call emit_patch(2064, 26); drop
+			0x41, 0x00, // i32.const 0
+		},
+	})
+
+	resolver, err := redwood.NewWASMResolver(mustResolveWasmConfig(t, "resolver/wasm", module, nil), nil)
+	require.NoError(t, err)
+
+	// The module's patches replace the tx's patches, so .text is left alone
+	state := tree.NewMemoryNode()
+	err = state.Set(nil, nil, M{"text": "hello"})
+	require.NoError(t, err)
+	err = resolver.ResolveState(state, nil, testutils.RandomAddress(t), types.RandomID(), nil, mustParsePatches(t, `.text = "goodbye"`))
+	require.NoError(t, err)
+
+	val, exists, err := state.Value(nil, nil)
+	require.NoError(t, err)
+	require.True(t, exists)
+	require.Equal(t, M{"text": "hello", "copy": "hello"}, val)
+	require.Equal(t, M{"seen": true}, resolver.InternalState())
+}
+
+func TestWASMValidator(t *testing.T) {
+	reject := buildWasmModule(wasmModuleSpec{
+		data:       map[uint32]string{0: `nope`},
+		alloc:      []byte{0x41, 0x80, 0x08}, // i32.const 1024
+		entrypoint: "validate_tx",
+		body: []byte{
+			0x41, 0x00, 0x41, 0x04, 0x10, 0x03, // call set_error(0, 4)
+			0x41, 0x01, // i32.const 1
+		},
+	})
+	accept := buildWasmModule(wasmModuleSpec{
+		alloc:      []byte{0x41, 0x80, 0x08},
+		entrypoint: "validate_tx",
+		body:       []byte{0x41, 0x00},
+	})
+
+	tx := &redwood.Tx{
+		ID:      types.RandomID(),
+		From:    testutils.RandomAddress(t),
+		Patches: mustParsePatches(t, `.foo = 1`),
+	}
+
+	validator, err := redwood.NewWASMValidator(mustResolveWasmConfig(t, "validator/wasm", reject, nil))
+	require.NoError(t, err)
+	err = validator.ValidateTx(tree.NewMemoryNode(), tx)
+	require.Error(t, err)
+	require.Equal(t, types.Err403, errors.Cause(err))
+	require.Contains(t, err.Error(), "nope")
+
+	validator, err = redwood.NewWASMValidator(mustResolveWasmConfig(t, "validator/wasm", accept, nil))
+	require.NoError(t, err)
+	err = validator.ValidateTx(tree.NewMemoryNode(), tx)
+	require.NoError(t, err)
+}
+
+func TestWASMIndexer(t *testing.T) {
+	module := buildWasmModule(wasmModuleSpec{
+		data:       map[uint32]string{0: `by-key`},
+		alloc:      []byte{0x41, 0x80, 0x08},
+		entrypoint: "index_node",
+		body: []byte{
+			0x41, 0x00, 0x41, 0x06, 0x10, 0x02, // call emit_index_key(0, 6)
+			0x41, 0x00,
+		},
+	})
+
+	indexer, err := redwood.NewWASMIndexer(mustResolveWasmConfig(t, "indexer/wasm", module, nil))
+	require.NoError(t, err)
+
+	node := tree.NewMemoryNode()
+	err = node.Set(nil, nil, M{"name": "xyzzy"})
+	require.NoError(t, err)
+
+	indexKey, indexedNode, err := indexer.IndexNode(tree.Keypath("foo"), node)
+	require.NoError(t, err)
+	require.Equal(t, tree.Keypath("by-key"), indexKey)
+	require.Equal(t, node, indexedNode)
+}
+
+func TestWASMSandbox_Limits(t *testing.T) {
+	t.Run("fuel budget", func(t *testing.T) {
+		module := buildWasmModule(wasmModuleSpec{
+			alloc:      []byte{0x41, 0x80, 0x08},
+			entrypoint: "validate_tx",
+			body: []byte{
+				0x03, 0x40, 0x0c, 0x00, 0x0b, // loop; br 0; end
+				0x41, 0x00,
+			},
+		})
+
+		validator, err := redwood.NewWASMValidator(mustResolveWasmConfig(t, "validator/wasm", module, M{"fuel": 1000.0}))
+		require.NoError(t, err)
+
+		// The second call ensures that the sandbox recovers from the trap
+		for i := 0; i < 2; i++ {
+			err = validator.ValidateTx(tree.NewMemoryNode(), &redwood.Tx{ID: types.RandomID()})
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "fuel budget")
+		}
+	})
+
+	t.Run("fuel is metered deterministically", func(t *testing.T) {
+		// A loop of 100 iterations, each of which costs the same, so it either
+		// always fits in the budget or never does
+		module := buildWasmModule(wasmModuleSpec{
+			alloc:      []byte{0x41, 0x80, 0x08},
+			entrypoint: "validate_tx",
+			locals:     []byte{0x01, 0x7f}, // 1 x i32
+			body: []byte{
+				0x41, 0xe4, 0x00, 0x21, 0x02, // local.set 2 (i32.const 100)
+				0x03, 0x40, // loop
+				0x20, 0x02, 0x41, 0x01, 0x6b, 0x22, 0x02, // local.tee 2 (local.get 2 - 1)
+				0x0d, 0x00, // br_if 0
+				0x0b, // end
+				0x41, 0x00,
+			},
+		})
+
+		enough, err := redwood.NewWASMValidator(mustResolveWasmConfig(t, "validator/wasm", module, M{"fuel": 1000.0}))
+		require.NoError(t, err)
+		tooLittle, err := redwood.NewWASMValidator(mustResolveWasmConfig(t, "validator/wasm", module, M{"fuel": 500.0}))
+		require.NoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			err = enough.ValidateTx(tree.NewMemoryNode(), &redwood.Tx{ID: types.RandomID()})
+			require.NoError(t, err)
+
+			err = tooLittle.ValidateTx(tree.NewMemoryNode(), &redwood.Tx{ID: types.RandomID()})
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "fuel budget")
+		}
+	})
+
+	t.Run("memory limit", func(t *testing.T) {
+		module := buildWasmModule(wasmModuleSpec{
+			memoryPages: 4,
+			alloc:       []byte{0x41, 0x80, 0x08},
+			entrypoint:  "validate_tx",
+			body:        []byte{0x41, 0x00},
+		})
+
+		_, err := redwood.NewWASMValidator(mustResolveWasmConfig(t, "validator/wasm", module, M{"memoryLimitPages": 2.0}))
+		require.Error(t, err)
+
+		_, err = redwood.NewWASMValidator(mustResolveWasmConfig(t, "validator/wasm", module, M{"memoryLimitPages": 4.0}))
+		require.NoError(t, err)
+	})
+}
+
+func TestWASMSandbox_FreshInstancePerCall(t *testing.T) {
+	// validate_tx fails if the word at address 0 is set, and then sets it, so
+	// it only ever succeeds if every call gets a fresh copy of memory
+	module := buildWasmModule(wasmModuleSpec{
+		alloc:      []byte{0x41, 0x80, 0x08},
+		entrypoint: "validate_tx",
+		body: []byte{
+			0x41, 0x00, 0x28, 0x02, 0x00, // i32.load(0)
+			0x04, 0x40, 0x41, 0x01, 0x0f, 0x0b, // if; return 1; end
+			0x41, 0x00, 0x41, 0x01, 0x36, 0x02, 0x00, // i32.store(0, 1)
+			0x41, 0x00,
+		},
+	})
+
+	validator, err := redwood.NewWASMValidator(mustResolveWasmConfig(t, "validator/wasm", module, nil))
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		err = validator.ValidateTx(tree.NewMemoryNode(), &redwood.Tx{ID: types.RandomID()})
+		require.NoError(t, err)
+	}
+}
+
+// wasmModuleSpec describes a module importing the full Redwood host ABI and
+// exporting `memory`, `alloc`, and a single entrypoint.  Function indices 0-4
+// are the imports (state_get, emit_patch, emit_index_key, set_error, log).
+type wasmModuleSpec struct {
+	memoryPages byte
+	data        map[uint32]string
+	alloc       []byte // body of alloc(size i32) i32, minus the trailing `end`
+	entrypoint  string
+	locals      []byte // local declarations of the entrypoint, minus the count
+	body        []byte // body of entrypoint(ptr, len i32) i32, minus the trailing `end`
+}
+
+func buildWasmModule(spec wasmModuleSpec) []byte {
+	const (
+		i32 = 0x7f
+		i64 = 0x7e
+	)
+
+	vec := func(items ...[]byte) []byte {
+		out := []byte{byte(len(items))}
+		for _, item := range items {
+			out = append(out, item...)
+		}
+		return out
+	}
+	name := func(s string) []byte {
+		return append([]byte{byte(len(s))}, s...)
+	}
+	section := func(id byte, contents []byte) []byte {
+		return append([]byte{id}, append(uleb128(uint32(len(contents))), contents...)...)
+	}
+	funcType := func(params, results []byte) []byte {
+		return append(append([]byte{0x60, byte(len(params))}, params...), append([]byte{byte(len(results))}, results...)...)
+	}
+	importFunc := func(field string, typeIdx byte) []byte {
+		return append(append(name("redwood"), name(field)...), 0x00, typeIdx)
+	}
+	exportItem := func(field string, kind, idx byte) []byte {
+		return append(name(field), kind, idx)
+	}
+	funcBody := func(locals, body []byte) []byte {
+		var numLocalDecls byte
+		if len(locals) > 0 {
+			numLocalDecls = byte(len(locals) / 2)
+		}
+		code := append(append([]byte{numLocalDecls}, locals...), body...)
+		code = append(code, 0x0b)
+		return append(uleb128(uint32(len(code))), code...)
+	}
+
+	memoryPages := spec.memoryPages
+	if memoryPages == 0 {
+		memoryPages = 1
+	}
+
+	var data [][]byte
+	for offset, contents := range spec.data {
+		segment := []byte{0x00, 0x41}
+		segment = append(segment, sleb128(int32(offset))...)
+		segment = append(segment, 0x0b)
+		segment = append(segment, uleb128(uint32(len(contents)))...)
+		segment = append(segment, contents...)
+		data = append(data, segment)
+	}
+
+	module := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	module = append(module, section(1, vec(
+		funcType([]byte{i32, i32}, []byte{i64}), // 0: state_get
+		funcType([]byte{i32, i32}, []byte{i32}), // 1: emit_patch, entrypoint
+		funcType([]byte{i32, i32}, nil),         // 2: emit_index_key, set_error, log
+		funcType([]byte{i32}, []byte{i32}),      // 3: alloc
+	))...)
+	module = append(module, section(2, vec(
+		importFunc("state_get", 0),
+		importFunc("emit_patch", 1),
+		importFunc("emit_index_key", 2),
+		importFunc("set_error", 2),
+		importFunc("log", 2),
+	))...)
+	module = append(module, section(3, vec([]byte{3}, []byte{1}))...)
+	module = append(module, section(5, vec([]byte{0x00, memoryPages}))...)
+	module = append(module, section(7, vec(
+		exportItem("memory", 0x02, 0),
+		exportItem("alloc", 0x00, 5),
+		exportItem(spec.entrypoint, 0x00, 6),
+	))...)
+	module = append(module, section(10, vec(
+		funcBody(nil, spec.alloc),
+		funcBody(spec.locals, spec.body),
+	))...)
+	if len(data) > 0 {
+		module = append(module, section(11, vec(data...))...)
+	}
+	return module
+}
+
+func uleb128(x uint32) []byte {
+	var out []byte
+	for {
+		b := byte(x & 0x7f)
+		x >>= 7
+		if x != 0 {
+			out = append(out, b|0x80)
+		} else {
+			return append(out, b)
+		}
+	}
+}
+
+func sleb128(x int32) []byte {
+	var out []byte
+	for {
+		b := byte(x & 0x7f)
+		x >>= 7
+		if (x == 0 && b&0x40 == 0) || (x == -1 && b&0x40 != 0) {
+			return append(out, b)
+		}
+		out = append(out, b|0x80)
+	}
+}